@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gofrs/uuid"
@@ -471,6 +472,45 @@ func jsonFunction(args *ParsedParams) (Function, error) {
 
 //------------------------------------------------------------------------------
 
+var _ = registerSimpleFunction(
+	NewFunctionSpec(
+		FunctionCategoryMessage, "lineage_id",
+		"Returns the unique lineage identifier of a message when [lineage tracking](/docs/configuration/lineage) is enabled for the stream, otherwise `null` is returned.",
+		NewExampleSpec("",
+			`meta lineage_id = lineage_id()`,
+		),
+	).Beta(),
+	func(ctx FunctionContext) (interface{}, error) {
+		if n := lineage.Get(ctx.MsgBatch.Get(ctx.Index)); n != nil {
+			return n.ID(), nil
+		}
+		return nil, nil
+	},
+)
+
+var _ = registerSimpleFunction(
+	NewFunctionSpec(
+		FunctionCategoryMessage, "lineage_origins",
+		"Returns an array of the lineage identifiers of the input messages that a message originated from when [lineage tracking](/docs/configuration/lineage) is enabled for the stream, otherwise `null` is returned. Messages that have not been derived from others originate from themselves.",
+		NewExampleSpec("",
+			`root.origins = lineage_origins()`,
+		),
+	).Beta(),
+	func(ctx FunctionContext) (interface{}, error) {
+		n := lineage.Get(ctx.MsgBatch.Get(ctx.Index))
+		if n == nil {
+			return nil, nil
+		}
+		origins := make([]interface{}, len(n.Origins()))
+		for i, o := range n.Origins() {
+			origins[i] = o
+		}
+		return origins, nil
+	},
+)
+
+//------------------------------------------------------------------------------
+
 var _ = registerFunction(
 	NewFunctionSpec(
 		FunctionCategoryMessage, "meta",
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Jeffail/gabs/v2"
@@ -250,6 +251,33 @@ var _ = registerSimpleMethod(
 
 //------------------------------------------------------------------------------
 
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"diff",
+		"Compares an object or array against another value and returns a [JSON Patch](https://datatracker.ietf.org/doc/html/rfc6902) document, which is an array of operations that transforms the target into the other value when applied with the [`json_patch`](#json_patch) method. Arrays are compared element by element, with elements added or removed from the end.",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		"",
+		NewExampleSpec("",
+			`root = this.before.diff(this.after)`,
+			`{"before":{"name":"foo","tags":["a","b"],"age":10},"after":{"name":"bar","tags":["a"],"active":true}}`,
+			`[{"op":"add","path":"/active","value":true},{"op":"remove","path":"/age"},{"op":"replace","path":"/name","value":"bar"},{"op":"remove","path":"/tags/1"}]`,
+		),
+	).Param(ParamAny("other", "The value to compare the target value against.")).Beta(),
+	func(args *ParsedParams) (simpleMethod, error) {
+		other, err := args.Field("other")
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			ops := jsonDiff(nil, v, other, []interface{}{})
+			return ops, nil
+		}, nil
+	},
+)
+
+//------------------------------------------------------------------------------
+
 var _ = registerSimpleMethod(
 	NewMethodSpec(
 		"enumerated",
@@ -573,6 +601,53 @@ var _ = registerSimpleMethod(
 
 //------------------------------------------------------------------------------
 
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"json_patch",
+		"Applies a [JSON Patch](https://datatracker.ietf.org/doc/html/rfc6902) document, which is an array of operations, to the target value and returns the result. The operations `add`, `remove`, `replace`, `move`, `copy` and `test` are supported, and an error is returned if any operation fails, in which case none of the operations are applied.",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		"",
+		NewExampleSpec("",
+			`root = this.doc.json_patch(this.patch)`,
+			`{"doc":{"name":"foo","tags":["a"]},"patch":[{"op":"replace","path":"/name","value":"bar"},{"op":"add","path":"/tags/-","value":"b"}]}`,
+			`{"name":"bar","tags":["a","b"]}`,
+		),
+		NewExampleSpec("A `test` operation can be used in order to only apply a patch when the target is in an expected state.",
+			`root = this.doc.json_patch([{"op":"test","path":"/version","value":1},{"op":"replace","path":"/version","value":2}]).catch(this.doc)`,
+			`{"doc":{"version":1}}`,
+			`{"version":2}`,
+			`{"doc":{"version":3}}`,
+			`{"version":3}`,
+		),
+	).Param(ParamArray("patch", "An array of JSON Patch operations to apply.")).Beta(),
+	func(args *ParsedParams) (simpleMethod, error) {
+		patch, err := args.FieldArray("patch")
+		if err != nil {
+			return nil, err
+		}
+		ops := make([]map[string]interface{}, len(patch))
+		for i, p := range patch {
+			var ok bool
+			if ops[i], ok = p.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("operation %v: %w", i, NewTypeError(p, ValueObject))
+			}
+		}
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			doc := IClone(v)
+			for i, op := range ops {
+				var err error
+				if doc, err = jsonPatchApplyOp(doc, op); err != nil {
+					return nil, fmt.Errorf("operation %v: %w", i, err)
+				}
+			}
+			return doc, nil
+		}, nil
+	},
+)
+
+//------------------------------------------------------------------------------
+
 var _ = registerSimpleMethod(
 	NewMethodSpec(
 		"json_schema",
@@ -976,6 +1051,32 @@ func assigner(destination, source interface{}) interface{} {
 
 //------------------------------------------------------------------------------
 
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"merge_patch",
+		"Applies a [JSON Merge Patch](https://datatracker.ietf.org/doc/html/rfc7386) to the target value and returns the result. Fields of the patch object replace those of the target recursively, fields of the patch with a `null` value are removed from the target, and a patch that isn't an object replaces the target entirely.",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		"",
+		NewExampleSpec("",
+			`root = this.doc.merge_patch(this.patch)`,
+			`{"doc":{"name":"foo","address":{"city":"London","street":"Baker St"},"age":10},"patch":{"address":{"street":"Abbey Rd"},"age":null}}`,
+			`{"address":{"city":"London","street":"Abbey Rd"},"name":"foo"}`,
+		),
+	).Param(ParamAny("patch", "The merge patch to apply.")).Beta(),
+	func(args *ParsedParams) (simpleMethod, error) {
+		patch, err := args.Field("patch")
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			return jsonMergePatch(IClone(v), patch), nil
+		}, nil
+	},
+)
+
+//------------------------------------------------------------------------------
+
 var _ = registerSimpleMethod(
 	NewMethodSpec(
 		"not_empty", "",
@@ -1562,3 +1663,376 @@ func mapWithout(m map[string]interface{}, paths [][]string) map[string]interface
 	}
 	return newMap
 }
+
+//------------------------------------------------------------------------------
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("json pointer '%v' must begin with '/'", pointer)
+	}
+	path := strings.Split(pointer[1:], "/")
+	for i, p := range path {
+		path[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~")
+	}
+	return path, nil
+}
+
+func jsonPointerFromPath(path []string) string {
+	var buf strings.Builder
+	for _, p := range path {
+		buf.WriteByte('/')
+		buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(p, "~", "~0"), "/", "~1"))
+	}
+	return buf.String()
+}
+
+func jsonPointerIndex(key string, length int, allowEnd bool) (int, error) {
+	if allowEnd && key == "-" {
+		return length, nil
+	}
+	if key == "" || (len(key) > 1 && key[0] == '0') {
+		return 0, fmt.Errorf("invalid array index '%v'", key)
+	}
+	index := 0
+	for _, c := range key {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid array index '%v'", key)
+		}
+		index = index*10 + int(c-'0')
+		if index > length {
+			break
+		}
+	}
+	upper := length - 1
+	if allowEnd {
+		upper = length
+	}
+	if index > upper {
+		return 0, fmt.Errorf("array index '%v' is out of bounds", key)
+	}
+	return index, nil
+}
+
+func jsonPatchGet(doc interface{}, path []string) (interface{}, error) {
+	for i, key := range path {
+		switch t := doc.(type) {
+		case map[string]interface{}:
+			v, exists := t[key]
+			if !exists {
+				return nil, fmt.Errorf("path '%v' does not exist", jsonPointerFromPath(path[:i+1]))
+			}
+			doc = v
+		case []interface{}:
+			index, err := jsonPointerIndex(key, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = t[index]
+		default:
+			return nil, fmt.Errorf("path '%v' does not exist", jsonPointerFromPath(path[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// jsonPatchSet walks a path of a document and either adds a value at the end
+// of it, replaces an existing value at the end of it, or removes the value at
+// the end of it when the value is nil. The resulting document is returned
+// along with the previous value at the path, if any.
+func jsonPatchSet(doc interface{}, path []string, value interface{}, insert, remove bool) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		if remove {
+			return nil, nil, errors.New("cannot remove the root of a document")
+		}
+		return value, doc, nil
+	}
+
+	key := path[0]
+	switch t := doc.(type) {
+	case map[string]interface{}:
+		existing, exists := t[key]
+		if len(path) > 1 {
+			if !exists {
+				return nil, nil, fmt.Errorf("path '%v' does not exist", jsonPointerFromPath(path[:1]))
+			}
+			child, prev, err := jsonPatchSet(existing, path[1:], value, insert, remove)
+			if err != nil {
+				return nil, nil, err
+			}
+			t[key] = child
+			return t, prev, nil
+		}
+		if !exists && !insert {
+			return nil, nil, fmt.Errorf("path '%v' does not exist", jsonPointerFromPath(path[:1]))
+		}
+		if remove {
+			delete(t, key)
+		} else {
+			t[key] = value
+		}
+		return t, existing, nil
+	case []interface{}:
+		if len(path) > 1 {
+			index, err := jsonPointerIndex(key, len(t), false)
+			if err != nil {
+				return nil, nil, err
+			}
+			child, prev, err := jsonPatchSet(t[index], path[1:], value, insert, remove)
+			if err != nil {
+				return nil, nil, err
+			}
+			t[index] = child
+			return t, prev, nil
+		}
+		index, err := jsonPointerIndex(key, len(t), insert)
+		if err != nil {
+			return nil, nil, err
+		}
+		if insert {
+			t = append(t, nil)
+			copy(t[index+1:], t[index:])
+			t[index] = value
+			return t, nil, nil
+		}
+		existing := t[index]
+		if remove {
+			return append(t[:index], t[index+1:]...), existing, nil
+		}
+		t[index] = value
+		return t, existing, nil
+	}
+	return nil, nil, fmt.Errorf("path '%v' does not exist", jsonPointerFromPath(path[:1]))
+}
+
+func jsonPatchOpField(op map[string]interface{}, field string) (string, error) {
+	v, exists := op[field]
+	if !exists {
+		return "", fmt.Errorf("missing field '%v'", field)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("field '%v': %w", field, NewTypeError(v, ValueString))
+	}
+	return s, nil
+}
+
+func jsonPatchApplyOp(doc interface{}, op map[string]interface{}) (interface{}, error) {
+	opType, err := jsonPatchOpField(op, "op")
+	if err != nil {
+		return nil, err
+	}
+	pathStr, err := jsonPatchOpField(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	path, err := parseJSONPointer(pathStr)
+	if err != nil {
+		return nil, err
+	}
+
+	getValue := func() (interface{}, error) {
+		v, exists := op["value"]
+		if !exists {
+			return nil, errors.New("missing field 'value'")
+		}
+		return IClone(v), nil
+	}
+	getFrom := func() ([]string, error) {
+		fromStr, err := jsonPatchOpField(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		return parseJSONPointer(fromStr)
+	}
+
+	switch opType {
+	case "add":
+		value, err := getValue()
+		if err != nil {
+			return nil, err
+		}
+		doc, _, err = jsonPatchSet(doc, path, value, true, false)
+		return doc, err
+	case "remove":
+		doc, _, err = jsonPatchSet(doc, path, nil, false, true)
+		return doc, err
+	case "replace":
+		value, err := getValue()
+		if err != nil {
+			return nil, err
+		}
+		doc, _, err = jsonPatchSet(doc, path, value, false, false)
+		return doc, err
+	case "move":
+		from, err := getFrom()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && jsonPointerFromPath(path[:len(from)]) == jsonPointerFromPath(from) {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		var value interface{}
+		if doc, value, err = jsonPatchSet(doc, from, nil, false, true); err != nil {
+			return nil, err
+		}
+		doc, _, err = jsonPatchSet(doc, path, value, true, false)
+		return doc, err
+	case "copy":
+		from, err := getFrom()
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonPatchGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		doc, _, err = jsonPatchSet(doc, path, IClone(value), true, false)
+		return doc, err
+	case "test":
+		expected, err := getValue()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := jsonPatchGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonValuesEqual(actual, expected) {
+			return nil, fmt.Errorf("test failed, value at path '%v' does not match", pathStr)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unrecognised operation '%v'", opType)
+}
+
+func jsonValuesEqual(lhs, rhs interface{}) bool {
+	switch lt := lhs.(type) {
+	case map[string]interface{}:
+		rt, ok := rhs.(map[string]interface{})
+		if !ok || len(lt) != len(rt) {
+			return false
+		}
+		for k, lv := range lt {
+			rv, exists := rt[k]
+			if !exists || !jsonValuesEqual(lv, rv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		rt, ok := rhs.([]interface{})
+		if !ok || len(lt) != len(rt) {
+			return false
+		}
+		for i, lv := range lt {
+			if !jsonValuesEqual(lv, rt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return restrictForComparison(lhs) == restrictForComparison(rhs)
+}
+
+func jsonMergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return IClone(patch)
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+		} else {
+			targetObj[k] = jsonMergePatch(targetObj[k], v)
+		}
+	}
+	return targetObj
+}
+
+func jsonDiff(path []string, from, to interface{}, ops []interface{}) []interface{} {
+	pathWith := func(key string) []string {
+		newPath := make([]string, len(path)+1)
+		copy(newPath, path)
+		newPath[len(path)] = key
+		return newPath
+	}
+
+	switch ft := from.(type) {
+	case map[string]interface{}:
+		tt, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(ft)+len(tt))
+		for k := range ft {
+			keys = append(keys, k)
+		}
+		for k := range tt {
+			if _, exists := ft[k]; !exists {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fv, fExists := ft[k]
+			tv, tExists := tt[k]
+			switch {
+			case !tExists:
+				ops = append(ops, map[string]interface{}{
+					"op":   "remove",
+					"path": jsonPointerFromPath(pathWith(k)),
+				})
+			case !fExists:
+				ops = append(ops, map[string]interface{}{
+					"op":    "add",
+					"path":  jsonPointerFromPath(pathWith(k)),
+					"value": IClone(tv),
+				})
+			default:
+				ops = jsonDiff(pathWith(k), fv, tv, ops)
+			}
+		}
+		return ops
+	case []interface{}:
+		tt, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		common := len(ft)
+		if len(tt) < common {
+			common = len(tt)
+		}
+		for i := 0; i < common; i++ {
+			ops = jsonDiff(pathWith(strconv.Itoa(i)), ft[i], tt[i], ops)
+		}
+		for i := len(ft) - 1; i >= common; i-- {
+			ops = append(ops, map[string]interface{}{
+				"op":   "remove",
+				"path": jsonPointerFromPath(pathWith(strconv.Itoa(i))),
+			})
+		}
+		for i := common; i < len(tt); i++ {
+			ops = append(ops, map[string]interface{}{
+				"op":    "add",
+				"path":  jsonPointerFromPath(pathWith(strconv.Itoa(i))),
+				"value": IClone(tt[i]),
+			})
+		}
+		return ops
+	}
+	if !jsonValuesEqual(from, to) {
+		ops = append(ops, map[string]interface{}{
+			"op":    "replace",
+			"path":  jsonPointerFromPath(path),
+			"value": IClone(to),
+		})
+	}
+	return ops
+}
@@ -100,6 +100,46 @@ func TestMethodImmutability(t *testing.T) {
 				"baz": "buz",
 			},
 		},
+
+		{
+			name:   "json patch",
+			method: "json_patch",
+			target: map[string]interface{}{"foo": map[string]interface{}{"bar": []interface{}{"a"}}},
+			args: []interface{}{
+				[]interface{}{
+					map[string]interface{}{"op": "add", "path": "/foo/bar/0", "value": "b"},
+					map[string]interface{}{"op": "copy", "from": "/foo", "path": "/baz"},
+					map[string]interface{}{"op": "remove", "path": "/foo/bar/1"},
+				},
+			},
+			exp: map[string]interface{}{
+				"foo": map[string]interface{}{"bar": []interface{}{"b"}},
+				"baz": map[string]interface{}{"bar": []interface{}{"b", "a"}},
+			},
+		},
+		{
+			name:   "merge patch",
+			method: "merge_patch",
+			target: map[string]interface{}{"foo": map[string]interface{}{"bar": "a", "baz": "b"}},
+			args: []interface{}{
+				map[string]interface{}{"foo": map[string]interface{}{"bar": nil}, "qux": map[string]interface{}{"quz": "c"}},
+			},
+			exp: map[string]interface{}{
+				"foo": map[string]interface{}{"baz": "b"},
+				"qux": map[string]interface{}{"quz": "c"},
+			},
+		},
+		{
+			name:   "diff",
+			method: "diff",
+			target: map[string]interface{}{"foo": []interface{}{"a"}},
+			args: []interface{}{
+				map[string]interface{}{"foo": []interface{}{"a", map[string]interface{}{"b": "c"}}},
+			},
+			exp: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/foo/1", "value": map[string]interface{}{"b": "c"}},
+			},
+		},
 	}
 
 	for _, test := range testCases {
@@ -124,3 +164,180 @@ func TestMethodImmutability(t *testing.T) {
 		})
 	}
 }
+
+func TestMethodJSONPatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		target      interface{}
+		patch       []interface{}
+		exp         interface{}
+		errContains string
+	}{
+		{
+			name:   "escaped keys",
+			target: map[string]interface{}{"a/b": "foo", "c~d": "bar"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "replace", "path": "/a~1b", "value": "baz"},
+				map[string]interface{}{"op": "remove", "path": "/c~0d"},
+			},
+			exp: map[string]interface{}{"a/b": "baz"},
+		},
+		{
+			name:   "replace root",
+			target: map[string]interface{}{"foo": "bar"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "replace", "path": "", "value": []interface{}{"baz"}},
+			},
+			exp: []interface{}{"baz"},
+		},
+		{
+			name:   "move within array",
+			target: []interface{}{"a", "b", "c"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "move", "from": "/0", "path": "/-"},
+			},
+			exp: []interface{}{"b", "c", "a"},
+		},
+		{
+			name:   "test numbers of differing types",
+			target: map[string]interface{}{"foo": int64(5)},
+			patch: []interface{}{
+				map[string]interface{}{"op": "test", "path": "/foo", "value": float64(5)},
+			},
+			exp: map[string]interface{}{"foo": int64(5)},
+		},
+		{
+			name:   "test fails",
+			target: map[string]interface{}{"foo": "bar"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "test", "path": "/foo", "value": "baz"},
+			},
+			errContains: "operation 0: test failed",
+		},
+		{
+			name:   "add to missing parent",
+			target: map[string]interface{}{},
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/foo/bar", "value": "baz"},
+			},
+			errContains: "path '/foo' does not exist",
+		},
+		{
+			name:   "replace missing field",
+			target: map[string]interface{}{},
+			patch: []interface{}{
+				map[string]interface{}{"op": "replace", "path": "/foo", "value": "baz"},
+			},
+			errContains: "path '/foo' does not exist",
+		},
+		{
+			name:   "array index out of bounds",
+			target: []interface{}{"a"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/2", "value": "b"},
+			},
+			errContains: "out of bounds",
+		},
+		{
+			name:   "array index leading zero",
+			target: []interface{}{"a", "b"},
+			patch: []interface{}{
+				map[string]interface{}{"op": "remove", "path": "/01"},
+			},
+			errContains: "invalid array index",
+		},
+		{
+			name:   "move into child",
+			target: map[string]interface{}{"foo": map[string]interface{}{}},
+			patch: []interface{}{
+				map[string]interface{}{"op": "move", "from": "/foo", "path": "/foo/bar"},
+			},
+			errContains: "into one of its children",
+		},
+		{
+			name:   "unknown op",
+			target: map[string]interface{}{},
+			patch: []interface{}{
+				map[string]interface{}{"op": "nope", "path": "/foo"},
+			},
+			errContains: "unrecognised operation 'nope'",
+		},
+		{
+			name:   "missing value",
+			target: map[string]interface{}{},
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/foo"},
+			},
+			errContains: "missing field 'value'",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fn, err := InitMethodHelper("json_patch", NewLiteralFunction("", test.target), test.patch)
+			require.NoError(t, err)
+
+			res, err := fn.Exec(FunctionContext{})
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, res)
+		})
+	}
+}
+
+func TestMethodDiffRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		from interface{}
+		to   interface{}
+	}{
+		{
+			name: "nested objects",
+			from: map[string]interface{}{"a": map[string]interface{}{"b": "c", "d": "e"}, "f": "g"},
+			to:   map[string]interface{}{"a": map[string]interface{}{"b": "x", "y/z": "e"}, "h": []interface{}{"i"}},
+		},
+		{
+			name: "shrinking arrays",
+			from: []interface{}{"a", "b", "c", "d"},
+			to:   []interface{}{"a", "x"},
+		},
+		{
+			name: "growing arrays",
+			from: []interface{}{map[string]interface{}{"a": "b"}},
+			to:   []interface{}{map[string]interface{}{"a": "c"}, "d", "e"},
+		},
+		{
+			name: "differing types",
+			from: map[string]interface{}{"a": []interface{}{"b"}},
+			to:   map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		},
+		{
+			name: "no changes",
+			from: map[string]interface{}{"a": int64(1)},
+			to:   map[string]interface{}{"a": float64(1)},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			diffFn, err := InitMethodHelper("diff", NewLiteralFunction("", test.from), test.to)
+			require.NoError(t, err)
+
+			patch, err := diffFn.Exec(FunctionContext{})
+			require.NoError(t, err)
+
+			patchFn, err := InitMethodHelper("json_patch", NewLiteralFunction("", test.from), patch)
+			require.NoError(t, err)
+
+			res, err := patchFn.Exec(FunctionContext{})
+			require.NoError(t, err)
+			assert.True(t, jsonValuesEqual(test.to, res), "%v != %v", test.to, res)
+		})
+	}
+}
@@ -46,16 +46,19 @@ type NewManagement interface {
 
 	AccessCache(ctx context.Context, name string, fn func(types.Cache)) error
 	StoreCache(ctx context.Context, name string, conf cache.Config) error
+	SwapCache(ctx context.Context, name string, conf cache.Config) error
 
 	AccessInput(ctx context.Context, name string, fn func(types.Input)) error
 	StoreInput(ctx context.Context, name string, conf input.Config) error
 
 	AccessProcessor(ctx context.Context, name string, fn func(types.Processor)) error
 	StoreProcessor(ctx context.Context, name string, conf processor.Config) error
+	SwapProcessor(ctx context.Context, name string, conf processor.Config) error
 
 	AccessOutput(ctx context.Context, name string, fn func(types.OutputWriter)) error
 	StoreOutput(ctx context.Context, name string, conf output.Config) error
 
 	AccessRateLimit(ctx context.Context, name string, fn func(types.RateLimit)) error
 	StoreRateLimit(ctx context.Context, name string, conf ratelimit.Config) error
+	SwapRateLimit(ctx context.Context, name string, conf ratelimit.Config) error
 }
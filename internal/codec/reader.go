@@ -75,6 +75,28 @@ type Reader interface {
 	Close(context.Context) error
 }
 
+// ByteOffsetReader is implemented by reader codecs that are able to report the
+// number of bytes of the underlying io.ReadCloser consumed by the messages
+// read so far, excluding any bytes that were buffered ahead of them. Since the
+// offset is of the io.ReadCloser provided to the codec it refers to
+// decompressed bytes when chained after codecs such as gzip.
+type ByteOffsetReader interface {
+	Reader
+	ByteOffset() int64
+}
+
+// offsetSplitFunc wraps a split function in order to count the bytes that it
+// consumes.
+func offsetSplitFunc(offset *int64, split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if err == nil && advance > 0 {
+			*offset += int64(advance)
+		}
+		return advance, token, err
+	}
+}
+
 type ioReaderConstructor func(string, io.ReadCloser) (io.ReadCloser, error)
 
 // ReaderConstructor creates a reader from a filename, an io.ReadCloser and an
@@ -345,6 +367,7 @@ type linesReader struct {
 	buf       *bufio.Scanner
 	r         io.ReadCloser
 	sourceAck ReaderAckFn
+	offset    int64
 
 	mut      sync.Mutex
 	finished bool
@@ -356,11 +379,17 @@ func newLinesReader(conf ReaderConfig, r io.ReadCloser, ackFn ReaderAckFn) (Read
 	if conf.MaxScanTokenSize != bufio.MaxScanTokenSize {
 		scanner.Buffer([]byte{}, conf.MaxScanTokenSize)
 	}
-	return &linesReader{
+	a := &linesReader{
 		buf:       scanner,
 		r:         r,
 		sourceAck: ackOnce(ackFn),
-	}, nil
+	}
+	scanner.Split(offsetSplitFunc(&a.offset, bufio.ScanLines))
+	return a, nil
+}
+
+func (a *linesReader) ByteOffset() int64 {
+	return a.offset
 }
 
 func (a *linesReader) ack(ctx context.Context, err error) error {
@@ -512,6 +541,7 @@ type customDelimReader struct {
 	buf       *bufio.Scanner
 	r         io.ReadCloser
 	sourceAck ReaderAckFn
+	offset    int64
 
 	mut      sync.Mutex
 	finished bool
@@ -525,8 +555,13 @@ func newCustomDelimReader(conf ReaderConfig, r io.ReadCloser, delim string, ackF
 	}
 
 	delimBytes := []byte(delim)
+	a := &customDelimReader{
+		buf:       scanner,
+		r:         r,
+		sourceAck: ackOnce(ackFn),
+	}
 
-	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	scanner.Split(offsetSplitFunc(&a.offset, func(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		if atEOF && len(data) == 0 {
 			return 0, nil, nil
 		}
@@ -543,13 +578,12 @@ func newCustomDelimReader(conf ReaderConfig, r io.ReadCloser, delim string, ackF
 
 		// Request more data.
 		return 0, nil, nil
-	})
+	}))
+	return a, nil
+}
 
-	return &customDelimReader{
-		buf:       scanner,
-		r:         r,
-		sourceAck: ackOnce(ackFn),
-	}, nil
+func (a *customDelimReader) ByteOffset() int64 {
+	return a.offset
 }
 
 func (a *customDelimReader) ack(ctx context.Context, err error) error {
@@ -610,6 +644,7 @@ type chunkerReader struct {
 	buf       *bytes.Buffer
 	r         io.ReadCloser
 	sourceAck ReaderAckFn
+	offset    int64
 
 	mut      sync.Mutex
 	finished bool
@@ -645,7 +680,8 @@ func (a *chunkerReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, er
 		return nil, nil, io.EOF
 	}
 
-	_, err := io.CopyN(a.buf, a.r, a.chunkSize)
+	n, err := io.CopyN(a.buf, a.r, a.chunkSize)
+	a.offset += n
 
 	a.mut.Lock()
 	defer a.mut.Unlock()
@@ -672,6 +708,10 @@ func (a *chunkerReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, er
 	return nil, nil, err
 }
 
+func (a *chunkerReader) ByteOffset() int64 {
+	return a.offset
+}
+
 func (a *chunkerReader) Close(ctx context.Context) error {
 	a.mut.Lock()
 	defer a.mut.Unlock()
@@ -821,6 +861,7 @@ type regexReader struct {
 	buf       *bufio.Scanner
 	r         io.ReadCloser
 	sourceAck ReaderAckFn
+	offset    int64
 
 	mut      sync.Mutex
 	finished bool
@@ -839,7 +880,13 @@ func newRexExpSplitReader(conf ReaderConfig, r io.ReadCloser, regex string, ackF
 		return nil, err
 	}
 
-	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	a := &regexReader{
+		buf:       scanner,
+		r:         r,
+		sourceAck: ackOnce(ackFn),
+	}
+
+	scanner.Split(offsetSplitFunc(&a.offset, func(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		if atEOF && len(data) == 0 {
 			return 0, nil, nil
 		}
@@ -865,13 +912,12 @@ func newRexExpSplitReader(conf ReaderConfig, r io.ReadCloser, regex string, ackF
 			return loc[1][0], data[0:loc[1][0]], nil
 		}
 		return loc[0][0], data[0:loc[0][0]], nil
-	})
+	}))
+	return a, nil
+}
 
-	return &regexReader{
-		buf:       scanner,
-		r:         r,
-		sourceAck: ackOnce(ackFn),
-	}, nil
+func (a *regexReader) ByteOffset() int64 {
+	return a.offset
 }
 
 func (a *regexReader) ack(ctx context.Context, err error) error {
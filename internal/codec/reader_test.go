@@ -332,6 +332,57 @@ func TestLinesReader(t *testing.T) {
 	testReaderSuite(t, "lines", "", data)
 }
 
+func TestReaderByteOffsets(t *testing.T) {
+	tests := []struct {
+		codec   string
+		data    string
+		offsets []int64
+	}{
+		{codec: "lines", data: "foo\r\nbar\n\nbaz", offsets: []int64{5, 9, 10, 13}},
+		{codec: "delim:XY", data: "fooXYbarXYbaz", offsets: []int64{5, 10, 13}},
+		{codec: "chunker:4", data: "foobarbaz", offsets: []int64{4, 8, 9}},
+		{codec: "regex:(?m)^#", data: "#foo\n#bar\n#baz", offsets: []int64{5, 10, 14}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.codec, func(t *testing.T) {
+			ctor, err := GetReader(test.codec, NewReaderConfig())
+			require.NoError(t, err)
+
+			r, err := ctor("", noopCloser{microReader{bytes.NewReader([]byte(test.data))}, false}, func(ctx context.Context, err error) error {
+				return nil
+			})
+			require.NoError(t, err)
+
+			offsetReader, ok := r.(ByteOffsetReader)
+			require.True(t, ok)
+
+			var offsets []int64
+			for {
+				_, ackFn, err := r.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				require.NoError(t, ackFn(context.Background(), nil))
+				offsets = append(offsets, offsetReader.ByteOffset())
+			}
+			assert.Equal(t, test.offsets, offsets)
+			require.NoError(t, r.Close(context.Background()))
+		})
+	}
+
+	ctor, err := GetReader("csv", NewReaderConfig())
+	require.NoError(t, err)
+	r, err := ctor("", noopCloser{bytes.NewReader([]byte("a,b\n1,2")), false}, func(ctx context.Context, err error) error {
+		return nil
+	})
+	require.NoError(t, err)
+	_, ok := r.(ByteOffsetReader)
+	assert.False(t, ok)
+}
+
 func TestCSVReader(t *testing.T) {
 	data := []byte("col1,col2,col3\nfoo1,bar1,baz1\nfoo2,bar2,baz2\nfoo3,bar3,baz3")
 	testReaderSuite(
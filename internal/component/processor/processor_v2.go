@@ -40,6 +40,31 @@ type V2Batched interface {
 	Close(ctx context.Context) error
 }
 
+// V2BatchedFlusher is an optional interface implemented by V2Batched
+// processors that emit batches periodically regardless of the batches being
+// processed, such as snapshots of aggregated state.
+type V2BatchedFlusher interface {
+	// FlushInterval returns the period at which Flush should be called, where
+	// zero means the processor does not need flushing.
+	FlushInterval() time.Duration
+
+	// Flush returns any batches that are due to be emitted.
+	Flush(ctx context.Context) [][]types.Part
+}
+
+// Flusher is implemented by processors that emit messages periodically
+// regardless of the messages being processed. Pipelines call Flush on their
+// top level processors at each flush interval and send the resulting messages
+// through the processors that follow.
+type Flusher interface {
+	// FlushInterval returns the period at which Flush should be called, where
+	// zero means the processor does not need flushing.
+	FlushInterval() time.Duration
+
+	// Flush returns any messages that are due to be emitted.
+	Flush() []types.Message
+}
+
 //------------------------------------------------------------------------------
 
 // Implements types.Processor
@@ -195,6 +220,31 @@ func (a *v2BatchedToV1Processor) ProcessMessage(msg types.Message) ([]types.Mess
 	return outputBatches, nil
 }
 
+func (a *v2BatchedToV1Processor) FlushInterval() time.Duration {
+	if f, ok := a.p.(V2BatchedFlusher); ok {
+		return f.FlushInterval()
+	}
+	return 0
+}
+
+func (a *v2BatchedToV1Processor) Flush() []types.Message {
+	f, ok := a.p.(V2BatchedFlusher)
+	if !ok {
+		return nil
+	}
+	var msgs []types.Message
+	for _, batch := range f.Flush(context.Background()) {
+		if len(batch) == 0 {
+			continue
+		}
+		a.mSent.Incr(int64(len(batch)))
+		msg := message.New(nil)
+		msg.SetAll(batch)
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
 func (a *v2BatchedToV1Processor) CloseAsync() {
 	go func() {
 		if err := a.p.Close(context.Background()); err == nil {
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/config"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// A tag that can be added to a sequence within an overlay config in order
+	// to append it to the sequence of the config it overlays rather than
+	// replacing it.
+	overlayAppendTag = "!append"
+
+	// A tag that can be added to any value within an overlay config in order
+	// to replace the value of the config it overlays rather than merging it.
+	overlayReplaceTag = "!replace"
+)
+
+// origins tracks the file that each node of a merged config originated from.
+type origins map[*yaml.Node]string
+
+// mark sets the origin of a node and all of its children that do not already
+// have an origin.
+func (o origins) mark(node *yaml.Node, origin string) {
+	if o == nil || node == nil {
+		return
+	}
+	if _, exists := o[node]; !exists {
+		o[node] = origin
+	}
+	for _, c := range node.Content {
+		o.mark(c, origin)
+	}
+}
+
+// markProfile adds the name of a profile to the origin of a node and all of
+// its children.
+func (o origins) markProfile(node *yaml.Node, profile string) {
+	if o == nil || node == nil {
+		return
+	}
+	o[node] = fmt.Sprintf("%v (profile %v)", o[node], profile)
+	for _, c := range node.Content {
+		o.markProfile(c, profile)
+	}
+}
+
+// annotate adds a line comment to each scalar value of a config describing the
+// file it originated from.
+func (o origins) annotate(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, c := range node.Content {
+			o.annotate(c)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if v.Kind == yaml.ScalarNode || len(v.Content) == 0 {
+				if origin, exists := o[v]; exists {
+					k.LineComment, v.LineComment = "", "from: "+origin
+				}
+				continue
+			}
+			o.annotate(v)
+		}
+	case yaml.SequenceNode:
+		for _, c := range node.Content {
+			if c.Kind == yaml.ScalarNode {
+				if origin, exists := o[c]; exists {
+					c.LineComment = "from: " + origin
+				}
+				continue
+			}
+			o.annotate(c)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func stripOverlayTag(node *yaml.Node) string {
+	tag := node.Tag
+	if tag == overlayAppendTag || tag == overlayReplaceTag {
+		node.Tag = ""
+	}
+	return tag
+}
+
+// stripOverlayTags removes all overlay tags from a config that is not being
+// merged onto anything, as otherwise they would break decoding.
+func stripOverlayTags(node *yaml.Node) {
+	_ = stripOverlayTag(node)
+	for _, c := range node.Content {
+		stripOverlayTags(c)
+	}
+}
+
+// mergeOverlay deep merges an overlay node onto a destination node following
+// a field spec, and returns the resulting node. Mappings are merged key by
+// key, with values of the overlay taking precedence, and sequences are
+// replaced unless the overlay sequence is tagged with !append. Any value
+// tagged with !replace within the overlay replaces the destination entirely.
+// Components are also replaced entirely when the overlay component is of a
+// different type.
+func mergeOverlay(spec docs.FieldSpec, dst, src *yaml.Node) *yaml.Node {
+	tag := stripOverlayTag(src)
+	if tag == overlayReplaceTag || dst == nil {
+		stripOverlayTags(src)
+		return src
+	}
+	if dst.Kind == yaml.DocumentNode && len(dst.Content) > 0 {
+		dst.Content[0] = mergeOverlay(spec, dst.Content[0], src)
+		return dst
+	}
+	if src.Kind == yaml.DocumentNode && len(src.Content) > 0 {
+		return mergeOverlay(spec, dst, src.Content[0])
+	}
+
+	if src.Kind == yaml.SequenceNode {
+		if tag == overlayAppendTag && dst.Kind == yaml.SequenceNode {
+			for _, c := range src.Content {
+				stripOverlayTags(c)
+			}
+			dst.Content = append(dst.Content, src.Content...)
+			return dst
+		}
+		stripOverlayTags(src)
+		return src
+	}
+
+	if src.Kind != yaml.MappingNode || dst.Kind != yaml.MappingNode {
+		stripOverlayTags(src)
+		return src
+	}
+
+	switch spec.Kind {
+	case docs.KindMap:
+		return mergeOverlayMapping(func(string) docs.FieldSpec { return spec.Scalar() }, dst, src)
+	case docs.KindScalar, "":
+		if coreType, isCore := spec.Type.IsCoreComponent(); isCore {
+			return mergeOverlayComponent(coreType, dst, src)
+		}
+	}
+	return mergeOverlayMapping(fieldSpecLookup(spec.Children), dst, src)
+}
+
+func fieldSpecLookup(specs docs.FieldSpecs) func(string) docs.FieldSpec {
+	return func(name string) docs.FieldSpec {
+		for _, s := range specs {
+			if s.Name == name {
+				return s
+			}
+		}
+		return docs.FieldSpec{}
+	}
+}
+
+func mergeOverlayComponent(coreType docs.Type, dst, src *yaml.Node) *yaml.Node {
+	dstName, cSpec, err := docs.GetInferenceCandidateFromYAML(nil, coreType, "", dst)
+	if err != nil {
+		stripOverlayTags(src)
+		return src
+	}
+	srcName, _, err := docs.GetInferenceCandidateFromYAML(nil, coreType, "", src)
+	if err != nil || srcName != dstName {
+		stripOverlayTags(src)
+		return src
+	}
+	return mergeOverlayMapping(func(name string) docs.FieldSpec {
+		switch {
+		case name == dstName || (name == "plugin" && cSpec.Plugin):
+			return cSpec.Config
+		case name == "processors" && (coreType == docs.TypeInput || coreType == docs.TypeOutput):
+			return docs.FieldCommon("processors", "").Array().HasType(docs.FieldTypeProcessor)
+		}
+		return docs.FieldSpec{}
+	}, dst, src)
+}
+
+func mergeOverlayMapping(specFn func(string) docs.FieldSpec, dst, src *yaml.Node) *yaml.Node {
+	for i := 0; i < len(src.Content)-1; i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		found := false
+		for j := 0; j < len(dst.Content)-1; j += 2 {
+			if dst.Content[j].Value == key.Value {
+				dst.Content[j+1] = mergeOverlay(specFn(key.Value), dst.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			stripOverlayTags(value)
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return dst
+}
+
+// extractProfile removes the profiles field from the root of a config and, if
+// a profile name is provided, returns the config of that profile.
+func extractProfile(root *yaml.Node, profile string) (*yaml.Node, error) {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	var profiles *yaml.Node
+	newContent := make([]*yaml.Node, 0, len(root.Content))
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == config.ProfilesField.Name {
+			profiles = root.Content[i+1]
+			continue
+		}
+		newContent = append(newContent, root.Content[i], root.Content[i+1])
+	}
+	root.Content = newContent
+
+	if profile == "" {
+		return nil, nil
+	}
+	if profiles != nil {
+		for i := 0; i < len(profiles.Content)-1; i += 2 {
+			if profiles.Content[i].Value == profile {
+				return profiles.Content[i+1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("profile '%v' was not found", profile)
+}
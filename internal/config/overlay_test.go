@@ -0,0 +1,243 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	iconfig "github.com/Jeffail/benthos/v3/internal/config"
+	"github.com/Jeffail/benthos/v3/lib/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlaysOfFile(t *testing.T) {
+	dir := t.TempDir()
+
+	mainPath := filepath.Join(dir, "main.yaml")
+	require.NoError(t, os.WriteFile(mainPath, []byte(`
+input:
+  kafka:
+    addresses: [ foobar.com, barbaz.com ]
+    topics: [ meow1, meow2 ]
+    consumer_group: foogroup
+pipeline:
+  processors:
+    - bloblang: 'root = this'
+output:
+  kafka:
+    addresses: [ foobar.com ]
+    topic: foo
+`), 0o644))
+
+	overlayPath := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, os.WriteFile(overlayPath, []byte(`
+input:
+  kafka:
+    topics: [ meow3 ]
+    client_id: barclient
+pipeline:
+  processors: !append
+    - bloblang: 'root.foo = "bar"'
+output:
+  drop: {}
+`), 0o644))
+
+	secondOverlayPath := filepath.Join(dir, "overlay2.yaml")
+	require.NoError(t, os.WriteFile(secondOverlayPath, []byte(`
+input:
+  kafka:
+    addresses: !replace [ nope.com ]
+`), 0o644))
+
+	conf := config.New()
+	rdr := iconfig.NewReader(mainPath, nil, iconfig.OptAddOverlays(overlayPath, secondOverlayPath))
+
+	lints, err := rdr.Read(&conf)
+	require.NoError(t, err)
+	assert.Empty(t, lints)
+
+	assert.Equal(t, "kafka", conf.Input.Type)
+	assert.Equal(t, []string{"nope.com"}, conf.Input.Kafka.Addresses)
+	assert.Equal(t, []string{"meow3"}, conf.Input.Kafka.Topics)
+	assert.Equal(t, "foogroup", conf.Input.Kafka.ConsumerGroup)
+	assert.Equal(t, "barclient", conf.Input.Kafka.ClientID)
+
+	require.Len(t, conf.Pipeline.Processors, 2)
+	assert.Equal(t, "root = this", string(conf.Pipeline.Processors[0].Bloblang))
+	assert.Equal(t, `root.foo = "bar"`, string(conf.Pipeline.Processors[1].Bloblang))
+
+	assert.Equal(t, "drop", conf.Output.Type)
+	assert.Equal(t, "benthos_stream", conf.Output.Kafka.Topic, "replaced component should not retain fields")
+}
+
+func TestOverlayLints(t *testing.T) {
+	dir := t.TempDir()
+
+	mainPath := filepath.Join(dir, "main.yaml")
+	require.NoError(t, os.WriteFile(mainPath, []byte(`
+input:
+  generate:
+    mapping: 'root = "hello world"'
+`), 0o644))
+
+	overlayPath := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, os.WriteFile(overlayPath, []byte(`
+input:
+  generate:
+    meow: nope
+`), 0o644))
+
+	conf := config.New()
+	rdr := iconfig.NewReader(mainPath, nil, iconfig.OptAddOverlays(overlayPath))
+
+	lints, err := rdr.Read(&conf)
+	require.NoError(t, err)
+	require.Len(t, lints, 1)
+	assert.Contains(t, lints[0], "/overlay.yaml: line 4: field meow ")
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	mainPath := filepath.Join(dir, "main.yaml")
+	require.NoError(t, os.WriteFile(mainPath, []byte(`
+input:
+  generate:
+    mapping: 'root = "hello world"'
+    interval: 1s
+output:
+  drop: {}
+profiles:
+  dev:
+    input:
+      generate:
+        interval: 10ms
+    output:
+      stdout: {}
+`), 0o644))
+
+	overlayPath := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, os.WriteFile(overlayPath, []byte(`
+profiles:
+  prod:
+    logger:
+      level: WARN
+`), 0o644))
+
+	t.Run("no profile", func(t *testing.T) {
+		conf := config.New()
+		rdr := iconfig.NewReader(mainPath, nil)
+
+		lints, err := rdr.Read(&conf)
+		require.NoError(t, err)
+		assert.Empty(t, lints)
+
+		assert.Equal(t, "1s", conf.Input.Generate.Interval)
+		assert.Equal(t, "drop", conf.Output.Type)
+		assert.Empty(t, conf.Profiles)
+	})
+
+	t.Run("dev profile", func(t *testing.T) {
+		conf := config.New()
+		rdr := iconfig.NewReader(mainPath, nil, iconfig.OptSetProfile("dev"), iconfig.OptAddOverrides("input.generate.count=5"))
+
+		lints, err := rdr.Read(&conf)
+		require.NoError(t, err)
+		assert.Empty(t, lints)
+
+		assert.Equal(t, `root = "hello world"`, conf.Input.Generate.Mapping)
+		assert.Equal(t, "10ms", conf.Input.Generate.Interval)
+		assert.Equal(t, 5, conf.Input.Generate.Count)
+		assert.Equal(t, "stdout", conf.Output.Type)
+	})
+
+	t.Run("overlay profile", func(t *testing.T) {
+		conf := config.New()
+		rdr := iconfig.NewReader(mainPath, nil, iconfig.OptAddOverlays(overlayPath), iconfig.OptSetProfile("prod"))
+
+		lints, err := rdr.Read(&conf)
+		require.NoError(t, err)
+		assert.Empty(t, lints)
+
+		assert.Equal(t, "1s", conf.Input.Generate.Interval)
+		assert.Equal(t, "drop", conf.Output.Type)
+		assert.Equal(t, "WARN", conf.Logger.LogLevel)
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		conf := config.New()
+		rdr := iconfig.NewReader(mainPath, nil, iconfig.OptSetProfile("prod"))
+
+		_, err := rdr.Read(&conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "profile 'prod' was not found")
+	})
+}
+
+func TestProfileLints(t *testing.T) {
+	dir := t.TempDir()
+
+	mainPath := filepath.Join(dir, "main.yaml")
+	require.NoError(t, os.WriteFile(mainPath, []byte(`
+input:
+  generate:
+    mapping: 'root = "hello world"'
+profiles:
+  dev:
+    input:
+      generate:
+        meow: nope
+`), 0o644))
+
+	conf := config.New()
+	rdr := iconfig.NewReader(mainPath, nil)
+
+	lints, err := rdr.Read(&conf)
+	require.NoError(t, err)
+	require.Len(t, lints, 1)
+	assert.Contains(t, lints[0], "/main.yaml: line 9: field meow ")
+}
+
+func TestResolvedYAML(t *testing.T) {
+	dir := t.TempDir()
+
+	mainPath := filepath.Join(dir, "main.yaml")
+	require.NoError(t, os.WriteFile(mainPath, []byte(`
+input:
+  generate:
+    mapping: 'root = "hello world"'
+    interval: 1s
+output:
+  drop: {}
+profiles:
+  dev:
+    output:
+      stdout: {}
+`), 0o644))
+
+	overlayPath := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, os.WriteFile(overlayPath, []byte(`
+input:
+  generate:
+    interval: 5s
+`), 0o644))
+
+	rdr := iconfig.NewReader(mainPath, nil,
+		iconfig.OptAddOverlays(overlayPath),
+		iconfig.OptSetProfile("dev"),
+		iconfig.OptAddOverrides("input.generate.count=10"),
+	)
+
+	resolved, err := rdr.ResolvedYAML()
+	require.NoError(t, err)
+
+	assert.Equal(t, `input:
+  generate:
+    mapping: 'root = "hello world"' # from: `+mainPath+`
+    interval: 5s # from: `+overlayPath+`
+    count: 10 # from: --set
+output:
+  stdout: {} # from: `+mainPath+` (profile dev)
+`, string(resolved))
+}
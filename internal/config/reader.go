@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -12,8 +13,10 @@ import (
 
 	"github.com/Jeffail/benthos/v3/internal/bundle"
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/secrets"
 	"github.com/Jeffail/benthos/v3/lib/config"
 	"github.com/Jeffail/benthos/v3/lib/stream"
+	uconfig "github.com/Jeffail/benthos/v3/lib/util/config"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
@@ -43,6 +46,8 @@ type Reader struct {
 	testSuffix string
 
 	mainPath      string
+	overlayPaths  []string
+	profile       string
 	resourcePaths []string
 	streamsPaths  []string
 	overrides     []string
@@ -53,6 +58,12 @@ type Reader struct {
 	// Tracks the details of the config file when we last read it.
 	configFileInfo configFileInfo
 
+	// Tracks the resources and stream fields of the main config when we last
+	// applied it, allowing resource changes to be applied without restarting
+	// the stream.
+	mainResourceInfo *resourceFileInfo
+	mainStreamConf   []byte
+
 	// Tracks the details of stream config files when we last read them.
 	streamFileInfo map[string]streamFileInfo
 
@@ -66,6 +77,16 @@ type Reader struct {
 	streamUpdateFn StreamUpdateFunc
 	watcher        *fsnotify.Watcher
 
+	// Serialises reactions to config changes between the file watcher and the
+	// secrets refresh loop.
+	reactMut           sync.Mutex
+	secretsRefreshStop chan struct{}
+	secretsRefreshDone chan struct{}
+
+	// Whether secret references are resolved when reading config files,
+	// which is only the case when configs are going to be run.
+	resolveSecrets bool
+
 	changeFlushPeriod time.Duration
 	changeDelayPeriod time.Duration
 }
@@ -109,6 +130,32 @@ func OptAddOverrides(overrides ...string) OptFunc {
 	}
 }
 
+// OptAddOverlays adds one or more config file paths to be merged on top of the
+// main config in the order given, where each overlay takes precedence over the
+// files before it.
+func OptAddOverlays(paths ...string) OptFunc {
+	return func(r *Reader) {
+		r.overlayPaths = append(r.overlayPaths, paths...)
+	}
+}
+
+// OptSetProfile selects a named profile from the profiles field of the config,
+// which is merged on top of the config after all overlays.
+func OptSetProfile(name string) OptFunc {
+	return func(r *Reader) {
+		r.profile = name
+	}
+}
+
+// OptResolveSecrets enables the resolution of secret references within config
+// files, which requires access to the secret providers and should therefore
+// only be enabled when the config is going to be run.
+func OptResolveSecrets() OptFunc {
+	return func(r *Reader) {
+		r.resolveSecrets = true
+	}
+}
+
 // OptSetStreamPaths marks this config reader as operating in streams mode, and
 // adds a list of paths to obtain individual stream configs from.
 func OptSetStreamPaths(streamsPaths ...string) OptFunc {
@@ -125,6 +172,7 @@ func (r *Reader) Read(conf *config.Type) (lints []string, err error) {
 	if lints, err = r.readMain(conf); err != nil {
 		return
 	}
+	r.setMainApplied(conf)
 	var rLints []string
 	if rLints, err = r.readResources(&conf.ResourceConfig); err != nil {
 		return
@@ -229,14 +277,7 @@ func (r *Reader) BeginFileWatching(mgr bundle.NewManagement, strict bool) error
 					if time.Since(changed) < r.changeDelayPeriod {
 						continue
 					}
-					var succeeded bool
-					if nameClean == filepath.Clean(r.mainPath) {
-						succeeded = r.reactMainUpdate(mgr, strict)
-					} else if _, exists := r.streamFileInfo[nameClean]; exists {
-						succeeded = r.reactStreamUpdate(mgr, strict, nameClean)
-					} else {
-						succeeded = r.reactResourceUpdate(mgr, strict, nameClean)
-					}
+					succeeded := r.reactFileUpdate(mgr, strict, nameClean)
 					if succeeded {
 						delete(collapsedChanges, nameClean)
 					} else {
@@ -263,6 +304,12 @@ func (r *Reader) BeginFileWatching(mgr bundle.NewManagement, strict bool) error
 			_ = watcher.Close()
 			return err
 		}
+		for _, p := range r.overlayPaths {
+			if err := watcher.Add(p); err != nil {
+				_ = watcher.Close()
+				return err
+			}
+		}
 	}
 	for _, p := range r.streamsPaths {
 		if err := watcher.Add(p); err != nil {
@@ -279,8 +326,120 @@ func (r *Reader) BeginFileWatching(mgr bundle.NewManagement, strict bool) error
 	return nil
 }
 
+func (r *Reader) reactFileUpdate(mgr bundle.NewManagement, strict bool, nameClean string) bool {
+	r.reactMut.Lock()
+	defer r.reactMut.Unlock()
+
+	if r.isMainPath(nameClean) {
+		return r.reactMainUpdate(mgr, strict)
+	}
+	if _, exists := r.streamFileInfo[nameClean]; exists {
+		return r.reactStreamUpdate(mgr, strict, nameClean)
+	}
+	return r.reactResourceUpdate(mgr, strict, nameClean)
+}
+
+// BeginSecretsRefresh creates a goroutine that periodically refreshes all
+// secrets that have been resolved from config files. When the value of a
+// secret changes any main, resource or stream config files that reference it
+// are reloaded the same as if the file itself had changed.
+//
+// WARNING: Either SubscribeConfigChanges or SubscribeStreamChanges must be
+// called before this, as otherwise it is unsafe to register them during
+// refreshing.
+func (r *Reader) BeginSecretsRefresh(mgr bundle.NewManagement, strict bool, period time.Duration) error {
+	if r.secretsRefreshStop != nil {
+		return errors.New("a secrets refresh has already been started")
+	}
+	if period <= 0 {
+		return errors.New("the secrets refresh period must be greater than zero")
+	}
+	stopChan, doneChan := make(chan struct{}), make(chan struct{})
+	r.secretsRefreshStop, r.secretsRefreshDone = stopChan, doneChan
+
+	go func() {
+		defer close(doneChan)
+
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-stopChan:
+				return
+			}
+
+			changed, err := secrets.Refresh(context.Background())
+			if err != nil {
+				mgr.Logger().Errorf("Secrets refresh error: %v", err)
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			mgr.Logger().Infof("Detected %v changed secrets, reloading affected configs.", len(changed))
+			for _, nameClean := range r.pathsReferencingSecrets(changed) {
+				if !r.reactFileUpdate(mgr, strict, nameClean) {
+					mgr.Logger().Errorf("Failed to reload config %v with refreshed secrets", nameClean)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// pathsReferencingSecrets returns the cleaned paths of all active config files
+// that contain any of the provided secret references.
+func (r *Reader) pathsReferencingSecrets(refs []string) []string {
+	var candidates []string
+	if !r.streamsMode && r.mainPath != "" {
+		candidates = append(candidates, r.mainPath)
+		candidates = append(candidates, r.overlayPaths...)
+	}
+	for p := range r.streamFileInfo {
+		candidates = append(candidates, p)
+	}
+	r.resourceFileInfoMut.Lock()
+	for p := range r.resourceFileInfo {
+		candidates = append(candidates, p)
+	}
+	r.resourceFileInfoMut.Unlock()
+
+	var paths []string
+	seen := map[string]struct{}{}
+	for _, p := range candidates {
+		nameClean := filepath.Clean(p)
+		if _, exists := seen[nameClean]; exists {
+			continue
+		}
+		seen[nameClean] = struct{}{}
+
+		fileBytes, err := os.ReadFile(nameClean)
+		if err != nil {
+			continue
+		}
+		for _, ref := range refs {
+			if bytes.Contains(fileBytes, []byte(ref)) {
+				paths = append(paths, nameClean)
+				break
+			}
+		}
+	}
+	return paths
+}
+
 // Close the reader, when this method exits all reloading will be stopped.
 func (r *Reader) Close(ctx context.Context) error {
+	if r.secretsRefreshStop != nil {
+		close(r.secretsRefreshStop)
+		doneChan := r.secretsRefreshDone
+		r.secretsRefreshStop, r.secretsRefreshDone = nil, nil
+		select {
+		case <-doneChan:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	if r.watcher != nil {
 		return r.watcher.Close()
 	}
@@ -313,26 +472,74 @@ func applyOverrides(specs docs.FieldSpecs, root *yaml.Node, overrides ...string)
 	return nil
 }
 
-func (r *Reader) readMain(conf *config.Type) (lints []string, err error) {
-	defer func() {
-		if err != nil && r.mainPath != "" {
-			err = fmt.Errorf("%v: %w", r.mainPath, err)
+func (r *Reader) mainSpec() docs.FieldSpecs {
+	if r.streamsMode {
+		// Spec is limited to just non-stream fields when in streams mode (no
+		// input, output, etc)
+		return config.SpecWithoutStream()
+	}
+	return config.Spec()
+}
+
+func (r *Reader) isMainPath(nameClean string) bool {
+	if nameClean == filepath.Clean(r.mainPath) {
+		return true
+	}
+	for _, p := range r.overlayPaths {
+		if nameClean == filepath.Clean(p) {
+			return true
 		}
-	}()
+	}
+	return false
+}
+
+// readConfigFile reads a config file with environment variables replaced, and
+// secrets resolved when enabled.
+func (r *Reader) readConfigFile(path string) ([]byte, []string, error) {
+	if r.resolveSecrets {
+		return config.ReadWithJSONPointersAndSecretsLinted(path)
+	}
+	return config.ReadWithJSONPointersLinted(path, true)
+}
 
-	if r.mainPath == "" && len(r.overrides) == 0 {
+func (r *Reader) readConfigFileNode(path string) (node *yaml.Node, confBytes []byte, lints []string, err error) {
+	if confBytes, lints, err = r.readConfigFile(path); err != nil {
 		return
 	}
+	node = &yaml.Node{}
+	err = yaml.Unmarshal(confBytes, node)
+	return
+}
+
+func lintConfigFileNode(spec docs.FieldSpecs, path string, confBytes []byte, node *yaml.Node) (lints []string) {
+	if bytes.HasPrefix(confBytes, []byte("# BENTHOS LINT DISABLE")) {
+		return nil
+	}
+	lintFilePrefix := ""
+	if path != "" {
+		lintFilePrefix = fmt.Sprintf("%v: ", path)
+	}
+	lintCtx := docs.NewLintContext()
+	for _, lint := range append(spec.LintYAML(lintCtx, node), config.LintProfilesYAML(lintCtx, spec, node)...) {
+		lints = append(lints, fmt.Sprintf("%vline %v: %v", lintFilePrefix, lint.Line, lint.What))
+	}
+	return
+}
 
-	var rawNode yaml.Node
+// readMainNode reads the main config file along with any overlays, profiles
+// and overrides into a single merged YAML node. When origins is non-nil it is
+// populated with the source of each node.
+func (r *Reader) readMainNode(o origins) (rawNode *yaml.Node, lints []string, err error) {
 	var confBytes []byte
+	rawNode = &yaml.Node{}
 	if r.mainPath != "" {
-		if confBytes, lints, err = config.ReadWithJSONPointersLinted(r.mainPath, true); err != nil {
-			return
-		}
-		if err = yaml.Unmarshal(confBytes, &rawNode); err != nil {
+		var mainLints []string
+		if rawNode, confBytes, mainLints, err = r.readConfigFileNode(r.mainPath); err != nil {
+			err = fmt.Errorf("%v: %w", r.mainPath, err)
 			return
 		}
+		lints = append(lints, mainLints...)
+		o.mark(rawNode, r.mainPath)
 	}
 
 	// This is an unlikely race condition as the file could've been updated
@@ -342,30 +549,99 @@ func (r *Reader) readMain(conf *config.Type) (lints []string, err error) {
 	// now (ignoring the issue).
 	r.configFileInfo.updatedAt = time.Now()
 
-	confSpec := config.Spec()
-	if r.streamsMode {
-		// Spec is limited to just non-stream fields when in streams mode (no
-		// input, output, etc)
-		confSpec = config.SpecWithoutStream()
-	}
-	if err = applyOverrides(confSpec, &rawNode, r.overrides...); err != nil {
+	confSpec := r.mainSpec()
+	rootSpec := docs.FieldCommon("", "").WithChildren(confSpec...)
+
+	if len(r.overlayPaths) == 0 && r.profile == "" {
+		// Without layering we lint after overrides in order to retain line
+		// numbers that match the single config file.
+		if err = applyOverrides(confSpec, rawNode, r.overrides...); err != nil {
+			err = r.wrapMainErr(err)
+			return
+		}
+		o.mark(rawNode, "--set")
+		lints = append(lints, lintConfigFileNode(confSpec, r.mainPath, confBytes, rawNode)...)
+		_, _ = extractProfile(rawNode, "")
 		return
 	}
 
-	if !bytes.HasPrefix(confBytes, []byte("# BENTHOS LINT DISABLE")) {
-		lintFilePrefix := ""
-		if r.mainPath != "" {
-			lintFilePrefix = fmt.Sprintf("%v: ", r.mainPath)
+	lints = append(lints, lintConfigFileNode(confSpec, r.mainPath, confBytes, rawNode)...)
+	for _, p := range r.overlayPaths {
+		overlayNode, overlayBytes, overlayLints, oErr := r.readConfigFileNode(p)
+		if oErr != nil {
+			err = fmt.Errorf("%v: %w", p, oErr)
+			return
 		}
-		for _, lint := range confSpec.LintYAML(docs.NewLintContext(), &rawNode) {
-			lints = append(lints, fmt.Sprintf("%vline %v: %v", lintFilePrefix, lint.Line, lint.What))
+		lints = append(lints, overlayLints...)
+		lints = append(lints, lintConfigFileNode(confSpec, p, overlayBytes, overlayNode)...)
+		o.mark(overlayNode, p)
+		if rawNode.Kind == 0 {
+			stripOverlayTags(overlayNode)
+			rawNode = overlayNode
+			continue
 		}
+		if overlayNode.Kind == 0 {
+			continue
+		}
+		rawNode = mergeOverlay(rootSpec, rawNode, overlayNode)
+	}
+
+	if rawNode.Kind == 0 {
+		rawNode = &yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	var profileNode *yaml.Node
+	if profileNode, err = extractProfile(rawNode, r.profile); err != nil {
+		err = r.wrapMainErr(err)
+		return
+	}
+	if profileNode != nil {
+		o.markProfile(profileNode, r.profile)
+		rawNode = mergeOverlay(rootSpec, rawNode, profileNode)
+	}
+
+	if err = applyOverrides(confSpec, rawNode, r.overrides...); err != nil {
+		err = r.wrapMainErr(err)
+		return
+	}
+	o.mark(rawNode, "--set")
+	return
+}
+
+func (r *Reader) wrapMainErr(err error) error {
+	if err != nil && r.mainPath != "" {
+		return fmt.Errorf("%v: %w", r.mainPath, err)
+	}
+	return err
+}
+
+func (r *Reader) readMain(conf *config.Type) (lints []string, err error) {
+	if r.mainPath == "" && len(r.overrides) == 0 && len(r.overlayPaths) == 0 {
+		return
 	}
 
-	err = rawNode.Decode(conf)
+	var rawNode *yaml.Node
+	if rawNode, lints, err = r.readMainNode(nil); err != nil {
+		return
+	}
+	err = r.wrapMainErr(rawNode.Decode(conf))
 	return
 }
 
+// ResolvedYAML returns the main config after all overlays, profiles and
+// overrides have been merged, where each value is annotated with a comment
+// describing the file it originated from. Fields that were not explicitly set
+// within any config file are omitted.
+func (r *Reader) ResolvedYAML() ([]byte, error) {
+	o := origins{}
+	rawNode, _, err := r.readMainNode(o)
+	if err != nil {
+		return nil, err
+	}
+	o.annotate(rawNode)
+	return uconfig.MarshalYAML(rawNode)
+}
+
 func (r *Reader) reactMainUpdate(mgr bundle.NewManagement, strict bool) bool {
 	if r.mainUpdateFn == nil {
 		return true
@@ -394,9 +670,28 @@ func (r *Reader) reactMainUpdate(mgr bundle.NewManagement, strict bool) bool {
 	}
 
 	// Update any resources within the file.
-	if newInfo := resInfoFromConfig(&conf.ResourceConfig); !newInfo.applyChanges(mgr) {
+	newInfo := resInfoFromConfig(&conf.ResourceConfig)
+	if !newInfo.applyChanges(r.mainResourceInfo, mgr) {
+		return false
+	}
+	r.mainResourceInfo = &newInfo
+
+	// When only resources have changed there's no need to restart the stream,
+	// as they've already been swapped out.
+	if streamConf, err := yaml.Marshal(conf.Config); err == nil && r.mainStreamConf != nil && bytes.Equal(streamConf, r.mainStreamConf) {
+		mgr.Logger().Infoln("Stream fields of the main config are unchanged, skipping pipeline update.")
+		return true
+	}
+
+	if !r.mainUpdateFn(conf.Config) {
 		return false
 	}
+	r.setMainApplied(&conf)
+	return true
+}
 
-	return r.mainUpdateFn(conf.Config)
+func (r *Reader) setMainApplied(conf *config.Type) {
+	resInfo := resInfoFromConfig(&conf.ResourceConfig)
+	r.mainResourceInfo = &resInfo
+	r.mainStreamConf, _ = yaml.Marshal(conf.Config)
 }
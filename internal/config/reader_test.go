@@ -1,15 +1,18 @@
 package config
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/config"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/manager"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/stream"
+	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -126,3 +129,128 @@ output:
 	assert.Equal(t, "kafka", updatedConf.Input.Type)
 	assert.Equal(t, "aws_s3", updatedConf.Output.Type)
 }
+
+func TestReaderSecretsRefresh(t *testing.T) {
+	confDir := t.TempDir()
+
+	secretPath := filepath.Join(confDir, "secret")
+	require.NoError(t, os.WriteFile(secretPath, []byte("first"), 0o600))
+
+	confFilePath := filepath.Join(confDir, "main.yaml")
+	require.NoError(t, os.WriteFile(confFilePath, []byte(`
+input:
+  kafka:
+    topics: [ "${secret:file:`+secretPath+`}" ]
+output:
+  drop: {}
+`), 0o644))
+
+	// Secrets are left unresolved unless enabled.
+	conf := config.New()
+	_, err := newDummyReader(confFilePath).Read(&conf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"${secret:file:" + secretPath + "}"}, conf.Input.Kafka.Topics)
+
+	rdr := newDummyReader(confFilePath)
+	OptResolveSecrets()(rdr)
+
+	conf = config.New()
+	_, err = rdr.Read(&conf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, conf.Input.Kafka.Topics)
+
+	changeChan := make(chan struct{})
+	var updatedConf stream.Config
+	require.NoError(t, rdr.SubscribeConfigChanges(func(conf stream.Config) bool {
+		updatedConf = conf
+		close(changeChan)
+		return true
+	}))
+
+	testMgr, err := manager.NewV2(manager.NewResourceConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, rdr.BeginSecretsRefresh(testMgr, true, time.Millisecond))
+	t.Cleanup(func() {
+		_ = rdr.Close(context.Background())
+	})
+
+	require.NoError(t, os.WriteFile(secretPath, []byte("second"), 0o600))
+
+	select {
+	case <-changeChan:
+	case <-time.After(time.Second):
+		require.FailNow(t, "Expected a config change to be triggered")
+	}
+
+	assert.Equal(t, []string{"second"}, updatedConf.Input.Kafka.Topics)
+}
+
+func TestReaderResourceOnlyChange(t *testing.T) {
+	confDir := t.TempDir()
+
+	confFilePath := filepath.Join(confDir, "main.yaml")
+	require.NoError(t, os.WriteFile(confFilePath, []byte(`
+input:
+  kafka: {}
+output:
+  drop: {}
+cache_resources:
+  - label: foo
+    memory:
+      ttl: 60
+`), 0o644))
+
+	rdr := newDummyReader(confFilePath)
+
+	conf := config.New()
+	_, err := rdr.Read(&conf)
+	require.NoError(t, err)
+
+	var updates []stream.Config
+	require.NoError(t, rdr.SubscribeConfigChanges(func(conf stream.Config) bool {
+		updates = append(updates, conf)
+		return true
+	}))
+
+	testMgr, err := manager.NewV2(conf.ResourceConfig, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	require.NoError(t, testMgr.AccessCache(context.Background(), "foo", func(c types.Cache) {
+		require.NoError(t, c.Set("key", []byte("value")))
+	}))
+
+	require.NoError(t, os.WriteFile(confFilePath, []byte(`
+input:
+  kafka: {}
+output:
+  drop: {}
+cache_resources:
+  - label: foo
+    memory:
+      ttl: 120
+`), 0o644))
+
+	require.True(t, rdr.reactMainUpdate(testMgr, true))
+	assert.Empty(t, updates, "stream should not be updated when only resources change")
+
+	// The cache should have been swapped for a new one.
+	require.NoError(t, testMgr.AccessCache(context.Background(), "foo", func(c types.Cache) {
+		_, err := c.Get("key")
+		assert.Equal(t, types.ErrKeyNotFound, err)
+	}))
+
+	require.NoError(t, os.WriteFile(confFilePath, []byte(`
+input:
+  kafka: {}
+output:
+  stdout: {}
+cache_resources:
+  - label: foo
+    memory:
+      ttl: 120
+`), 0o644))
+
+	require.True(t, rdr.reactMainUpdate(testMgr, true))
+	require.Len(t, updates, 1)
+	assert.Equal(t, "stdout", updates[0].Output.Type)
+}
@@ -49,38 +49,49 @@ func resInfoFromConfig(conf *manager.ResourceConfig) resourceFileInfo {
 
 	// Old style
 	for k, v := range conf.Manager.Inputs {
+		v := v
 		resInfo.inputs[k] = &v
 	}
 	for k, v := range conf.Manager.Conditions {
+		v := v
 		resInfo.conditions[k] = &v
 	}
 	for k, v := range conf.Manager.Processors {
+		v := v
 		resInfo.processors[k] = &v
 	}
 	for k, v := range conf.Manager.Outputs {
+		v := v
 		resInfo.outputs[k] = &v
 	}
 	for k, v := range conf.Manager.Caches {
+		v := v
 		resInfo.caches[k] = &v
 	}
 	for k, v := range conf.Manager.RateLimits {
+		v := v
 		resInfo.rateLimits[k] = &v
 	}
 
 	// New style
 	for _, c := range conf.ResourceInputs {
+		c := c
 		resInfo.inputs[c.Label] = &c
 	}
 	for _, c := range conf.ResourceProcessors {
+		c := c
 		resInfo.processors[c.Label] = &c
 	}
 	for _, c := range conf.ResourceOutputs {
+		c := c
 		resInfo.outputs[c.Label] = &c
 	}
 	for _, c := range conf.ResourceCaches {
+		c := c
 		resInfo.caches[c.Label] = &c
 	}
 	for _, c := range conf.ResourceRateLimits {
+		c := c
 		resInfo.rateLimits[c.Label] = &c
 	}
 
@@ -95,7 +106,7 @@ func (r *Reader) readResources(conf *manager.ResourceConfig) (lints []string, er
 	for _, path := range resourcesPaths {
 		rconf := manager.NewResourceConfig()
 		var rLints []string
-		if rLints, err = readResource(path, &rconf); err != nil {
+		if rLints, err = r.readResource(path, &rconf); err != nil {
 			return
 		}
 		lints = append(lints, rLints...)
@@ -109,7 +120,7 @@ func (r *Reader) readResources(conf *manager.ResourceConfig) (lints []string, er
 	return
 }
 
-func readResource(path string, conf *manager.ResourceConfig) (lints []string, err error) {
+func (r *Reader) readResource(path string, conf *manager.ResourceConfig) (lints []string, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("%v: %w", path, err)
@@ -117,7 +128,7 @@ func readResource(path string, conf *manager.ResourceConfig) (lints []string, er
 	}()
 
 	var confBytes []byte
-	if confBytes, lints, err = config.ReadWithJSONPointersLinted(path, true); err != nil {
+	if confBytes, lints, err = r.readConfigFile(path); err != nil {
 		return
 	}
 
@@ -150,7 +161,7 @@ func (r *Reader) reactResourceUpdate(mgr bundle.NewManagement, strict bool, path
 	mgr.Logger().Infof("Resource %v config updated, attempting to update resources.", path)
 
 	newResConf := manager.NewResourceConfig()
-	lints, err := readResource(path, &newResConf)
+	lints, err := r.readResource(path, &newResConf)
 	if err != nil {
 		mgr.Logger().Errorf("Failed to read updated resources config: %v", err)
 		return true
@@ -166,11 +177,11 @@ func (r *Reader) reactResourceUpdate(mgr bundle.NewManagement, strict bool, path
 	}
 
 	// TODO: Should we error out if the new config is missing some resources?
-	// (as they will continue to exist). Also, we could avoid restarting
-	// resources where the config hasn't changed.
+	// (as they will continue to exist).
 
+	prevInfo := r.resourceFileInfo[path]
 	newInfo := resInfoFromConfig(&newResConf)
-	if !newInfo.applyChanges(mgr) {
+	if !newInfo.applyChanges(&prevInfo, mgr) {
 		return false
 	}
 
@@ -178,7 +189,24 @@ func (r *Reader) reactResourceUpdate(mgr bundle.NewManagement, strict bool, path
 	return true
 }
 
-func (i *resourceFileInfo) applyChanges(mgr bundle.NewManagement) bool {
+// configsEqual returns true if two component configs are equivalent.
+func configsEqual(a, b interface{}) bool {
+	aBytes, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := yaml.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// applyChanges updates the resources of a manager to match the resource info.
+// When the resource info that was previously applied is provided then only
+// resources that have changed are updated, and caches, processors and rate
+// limits are hot swapped without interrupting components that use them.
+func (i *resourceFileInfo) applyChanges(prev *resourceFileInfo, mgr bundle.NewManagement) bool {
 	// Kind of arbitrary, but I feel better about having some sort of timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
@@ -187,27 +215,56 @@ func (i *resourceFileInfo) applyChanges(mgr bundle.NewManagement) bool {
 	// with components that could be dependencies of other components. This is
 	// a "best attempt", so not all edge cases need to be accounted for.
 	for k, v := range i.rateLimits {
-		if err := mgr.StoreRateLimit(ctx, k, *v); err != nil {
+		var err error
+		if prev == nil {
+			err = mgr.StoreRateLimit(ctx, k, *v)
+		} else if pv, exists := prev.rateLimits[k]; exists && configsEqual(pv, v) {
+			continue
+		} else {
+			err = mgr.SwapRateLimit(ctx, k, *v)
+		}
+		if err != nil {
 			mgr.Logger().Errorf("Failed to update resource %v: %v", k, err)
 			return false
 		}
 		mgr.Logger().Infof("Updated resource %v config from file.", k)
 	}
 	for k, v := range i.caches {
-		if err := mgr.StoreCache(ctx, k, *v); err != nil {
+		var err error
+		if prev == nil {
+			err = mgr.StoreCache(ctx, k, *v)
+		} else if pv, exists := prev.caches[k]; exists && configsEqual(pv, v) {
+			continue
+		} else {
+			err = mgr.SwapCache(ctx, k, *v)
+		}
+		if err != nil {
 			mgr.Logger().Errorf("Failed to update resource %v: %v", k, err)
 			return false
 		}
 		mgr.Logger().Infof("Updated resource %v config from file.", k)
 	}
 	for k, v := range i.processors {
-		if err := mgr.StoreProcessor(ctx, k, *v); err != nil {
+		var err error
+		if prev == nil {
+			err = mgr.StoreProcessor(ctx, k, *v)
+		} else if pv, exists := prev.processors[k]; exists && configsEqual(pv, v) {
+			continue
+		} else {
+			err = mgr.SwapProcessor(ctx, k, *v)
+		}
+		if err != nil {
 			mgr.Logger().Errorf("Failed to update resource %v: %v", k, err)
 			return false
 		}
 		mgr.Logger().Infof("Updated resource %v config from file.", k)
 	}
 	for k, v := range i.inputs {
+		if prev != nil {
+			if pv, exists := prev.inputs[k]; exists && configsEqual(pv, v) {
+				continue
+			}
+		}
 		if err := mgr.StoreInput(ctx, k, *v); err != nil {
 			mgr.Logger().Errorf("Failed to update resource %v: %v", k, err)
 			return false
@@ -215,6 +272,11 @@ func (i *resourceFileInfo) applyChanges(mgr bundle.NewManagement) bool {
 		mgr.Logger().Infof("Updated resource %v config from file.", k)
 	}
 	for k, v := range i.outputs {
+		if prev != nil {
+			if pv, exists := prev.outputs[k]; exists && configsEqual(pv, v) {
+				continue
+			}
+		}
 		if err := mgr.StoreOutput(ctx, k, *v); err != nil {
 			mgr.Logger().Errorf("Failed to update resource %v: %v", k, err)
 			return false
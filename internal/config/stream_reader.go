@@ -42,10 +42,20 @@ func InferStreamID(dir, path string) (string, error) {
 
 // ReadStreamFile attempts to read a stream config and returns the result
 func ReadStreamFile(path string) (conf stream.Config, lints []string, err error) {
+	return readStreamFileWith(path, func(path string) ([]byte, []string, error) {
+		return config.ReadWithJSONPointersLinted(path, true)
+	})
+}
+
+func (r *Reader) readStreamConfigFile(path string) (conf stream.Config, lints []string, err error) {
+	return readStreamFileWith(path, r.readConfigFile)
+}
+
+func readStreamFileWith(path string, readFn func(path string) ([]byte, []string, error)) (conf stream.Config, lints []string, err error) {
 	conf = stream.NewConfig()
 
 	var confBytes []byte
-	if confBytes, lints, err = config.ReadWithJSONPointersLinted(path, true); err != nil {
+	if confBytes, lints, err = readFn(path); err != nil {
 		return
 	}
 
@@ -82,7 +92,7 @@ func (r *Reader) readStreamFile(dir, path string, confs map[string]stream.Config
 		return nil, fmt.Errorf("stream id (%v) collision from file: %v", id, path)
 	}
 
-	conf, lints, err := ReadStreamFile(path)
+	conf, lints, err := r.readStreamConfigFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +165,7 @@ func (r *Reader) reactStreamUpdate(mgr bundle.NewManagement, strict bool, path s
 
 	mgr.Logger().Infof("Stream %v config updated, attempting to update stream.", info.id)
 
-	conf, lints, err := ReadStreamFile(path)
+	conf, lints, err := r.readStreamConfigFile(path)
 	if err != nil {
 		mgr.Logger().Errorf("Failed to read updated stream config: %v", err)
 		return true
@@ -126,6 +126,7 @@ type FieldSpec struct {
 
 	omitWhenFn   func(field, parent interface{}) (why string, shouldOmit bool)
 	customLintFn LintFunc
+	customFixFn  FixFunc
 	skipLint     bool
 }
 
@@ -258,6 +259,14 @@ func (f FieldSpec) Linter(fn LintFunc) FieldSpec {
 	return f
 }
 
+// Fixer adds a function to a field that attempts to automatically fix problems
+// with the field, such as the use of deprecated functionality, when a config is
+// linted with fixes enabled.
+func (f FieldSpec) Fixer(fn FixFunc) FieldSpec {
+	f.customFixFn = fn
+	return f
+}
+
 // LintOptions enforces that a field value matches one of the provided options
 // and returns a linting error if that is not the case. This is currently opt-in
 // because some fields express options that are only a subset due to deprecated
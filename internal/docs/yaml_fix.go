@@ -0,0 +1,412 @@
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FixFunc is a function that attempts to automatically fix the value of a field
+// within a config. The parent mapping node of the field is provided along with
+// the value node itself, and therefore the function is free to restructure
+// the parent (replace the field with another, etc). A lint describing each fix
+// that was applied should be returned.
+type FixFunc func(ctx LintContext, parent, value *yaml.Node) []Lint
+
+// NewLintFix returns a lint describing a fix that has been applied to a config.
+func NewLintFix(line int, msg string) Lint {
+	return Lint{Line: line, Level: LintWarning, What: msg}
+}
+
+func (f FieldSpec) isDefaultYAML(node *yaml.Node) bool {
+	if f.Default == nil || node.Kind == yaml.AliasNode {
+		return false
+	}
+	if _, isCore := f.Type.IsCoreComponent(); isCore {
+		return false
+	}
+
+	v, err := f.YAMLToValue(node, ToValueConfig{
+		FallbackToInterface: true,
+	})
+	if err != nil {
+		return false
+	}
+	if s, ok := v.([]interface{}); ok && s == nil {
+		v = []interface{}{}
+	}
+
+	normalise := func(v interface{}) (interface{}, bool) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		var n interface{}
+		if err := json.Unmarshal(b, &n); err != nil {
+			return nil, false
+		}
+		return n, true
+	}
+
+	nodeV, ok := normalise(v)
+	if !ok {
+		return false
+	}
+	defV, ok := normalise(*f.Default)
+	if !ok {
+		return false
+	}
+	return reflect.DeepEqual(nodeV, defV)
+}
+
+// FixYAML attempts to automatically fix problems within a component config
+// node, modifying it in place. Fields that are ineffective or match their
+// default value are removed, and fields with a custom fixer are given the
+// opportunity to replace deprecated functionality with modern equivalents.
+// Returns a list of lints describing the fixes that were applied.
+func FixYAML(ctx LintContext, cType Type, node *yaml.Node) []Lint {
+	if cType == "condition" {
+		return nil
+	}
+
+	node = unwrapDocumentNode(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var name string
+	var keys []string
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == "type" {
+			name = node.Content[i+1].Value
+			break
+		} else {
+			keys = append(keys, node.Content[i].Value)
+		}
+	}
+	if name == "" {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		var err error
+		if name, _, err = getInferenceCandidateFromList(ctx.DocsProvider, cType, "", keys); err != nil {
+			return nil
+		}
+	}
+
+	cSpec, exists := GetDocs(ctx.DocsProvider, name, cType)
+	if !exists {
+		return nil
+	}
+
+	var fixes []Lint
+	reservedFields := reservedFieldsByType(cType)
+
+	newNodes := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == name || (key.Value == "plugin" && cSpec.Plugin) {
+			fixes = append(fixes, cSpec.Config.FixYAML(ctx, value)...)
+		} else if spec, exists := reservedFields[key.Value]; exists && key.Value != "type" {
+			if why, omit := spec.shouldOmitYAML(nil, value, node); omit {
+				fixes = append(fixes, NewLintFix(key.Line, fmt.Sprintf("removed field %v: %v", key.Value, why)))
+				continue
+			}
+			fixes = append(fixes, spec.FixYAML(ctx, value)...)
+		}
+		newNodes = append(newNodes, key, value)
+	}
+	node.Content = newNodes
+	return fixes
+}
+
+// FixYAML attempts to automatically fix problems within a yaml node by
+// referencing a field definition, modifying the node in place. Returns a list
+// of lints describing the fixes that were applied.
+func (f FieldSpec) FixYAML(ctx LintContext, node *yaml.Node) []Lint {
+	if f.skipLint {
+		return nil
+	}
+
+	node = unwrapDocumentNode(node)
+
+	var fixes []Lint
+	switch f.Kind {
+	case Kind2DArray:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i := 0; i < len(node.Content); i++ {
+			fixes = append(fixes, f.Array().FixYAML(ctx, node.Content[i])...)
+		}
+		return fixes
+	case KindArray:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i := 0; i < len(node.Content); i++ {
+			fixes = append(fixes, f.Scalar().FixYAML(ctx, node.Content[i])...)
+		}
+		return fixes
+	case KindMap:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			fixes = append(fixes, f.Scalar().FixYAML(ctx, node.Content[i+1])...)
+		}
+		return fixes
+	}
+
+	if coreType, isCore := f.Type.IsCoreComponent(); isCore {
+		return FixYAML(ctx, coreType, node)
+	}
+	if len(f.Children) > 0 {
+		return f.Children.FixYAML(ctx, node)
+	}
+	return nil
+}
+
+// FixYAML attempts to automatically fix problems within a yaml mapping node by
+// referencing a list of field definitions, modifying the node in place.
+// Returns a list of lints describing the fixes that were applied.
+func (f FieldSpecs) FixYAML(ctx LintContext, node *yaml.Node) []Lint {
+	node = unwrapDocumentNode(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	specNames := map[string]FieldSpec{}
+	for _, field := range f {
+		specNames[field.Name] = field
+	}
+
+	var fixes []Lint
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if spec, exists := specNames[node.Content[i].Value]; exists {
+			fixes = append(fixes, spec.FixYAML(ctx, node.Content[i+1])...)
+		}
+	}
+
+	// Custom fixers are free to restructure the parent node, and therefore
+	// we look up the value of each field from scratch.
+	for _, spec := range f {
+		if spec.customFixFn == nil || spec.skipLint {
+			continue
+		}
+		value, err := getFieldFromMapping(spec.Name, false, node)
+		if err != nil {
+			continue
+		}
+		if _, omit := spec.shouldOmitYAML(f, value, node); !omit {
+			fixes = append(fixes, spec.customFixFn(ctx, node, value)...)
+		}
+	}
+
+	newNodes := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if spec, exists := specNames[key.Value]; exists && !spec.skipLint {
+			if why, omit := spec.shouldOmitYAML(f, value, node); omit {
+				fixes = append(fixes, NewLintFix(key.Line, fmt.Sprintf("removed field %v: %v", key.Value, why)))
+				continue
+			}
+			if spec.isDefaultYAML(value) {
+				fixes = append(fixes, NewLintFix(key.Line, fmt.Sprintf("removed field %v as it matches the default value", key.Value)))
+				continue
+			}
+		}
+		newNodes = append(newNodes, key, value)
+	}
+	node.Content = newNodes
+	return fixes
+}
+
+//------------------------------------------------------------------------------
+
+// FixConditionToCheck is a FixFunc for deprecated condition fields that have a
+// sibling `check` field, where if possible the condition is converted into an
+// equivalent Bloblang query and moved into the check field.
+//
+// TODO: V4 Remove this
+func FixConditionToCheck(ctx LintContext, parent, value *yaml.Node) []Lint {
+	checkIndex := -1
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		if parent.Content[i].Value == "check" {
+			if parent.Content[i+1].Value != "" {
+				return nil
+			}
+			checkIndex = i
+		}
+	}
+
+	query, ok := ConditionYAMLToBloblang(value)
+	if !ok {
+		return nil
+	}
+
+	newNodes := make([]*yaml.Node, 0, len(parent.Content))
+	var fixes []Lint
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		if i == checkIndex {
+			continue
+		}
+		key := parent.Content[i]
+		if key.Value != "condition" {
+			newNodes = append(newNodes, key, parent.Content[i+1])
+			continue
+		}
+		if query == "true" {
+			fixes = append(fixes, NewLintFix(key.Line, "removed deprecated field condition as it always passes"))
+			continue
+		}
+		key.Value = "check"
+		newNodes = append(newNodes, key, &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Tag:   "!!str",
+			Value: query,
+		})
+		fixes = append(fixes, NewLintFix(key.Line, "replaced deprecated field condition with an equivalent check"))
+	}
+	parent.Content = newNodes
+	return fixes
+}
+
+// ConditionYAMLToBloblang attempts to convert a deprecated condition config
+// into an equivalent Bloblang query. Returns false if the condition could not
+// be converted.
+//
+// TODO: V4 Remove this
+func ConditionYAMLToBloblang(node *yaml.Node) (string, bool) {
+	node = unwrapDocumentNode(node)
+	if node.Kind != yaml.MappingNode {
+		return "", false
+	}
+
+	var name string
+	var keys []string
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value == "type" {
+			name = node.Content[i+1].Value
+		} else {
+			keys = append(keys, node.Content[i].Value)
+		}
+	}
+	if name == "" {
+		if len(keys) != 1 {
+			return "", false
+		}
+		name = keys[0]
+	}
+
+	body, err := getFieldFromMapping(name, false, node)
+	if err != nil {
+		body = nil
+	}
+	decode := func(v interface{}) bool {
+		if body == nil {
+			return true
+		}
+		return body.Decode(v) == nil
+	}
+
+	switch name {
+	case "static":
+		var b bool
+		if !decode(&b) {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case "bloblang":
+		var s string
+		if !decode(&s) || s == "" {
+			return "", false
+		}
+		return strings.TrimSpace(s), true
+	case "not":
+		if body == nil {
+			return "", false
+		}
+		inner, ok := ConditionYAMLToBloblang(body)
+		if !ok {
+			return "", false
+		}
+		return "!(" + inner + ")", true
+	case "and", "or":
+		if body == nil || body.Kind != yaml.SequenceNode || len(body.Content) == 0 {
+			return "", false
+		}
+		op := " && "
+		if name == "or" {
+			op = " || "
+		}
+		var children []string
+		for _, c := range body.Content {
+			inner, ok := ConditionYAMLToBloblang(c)
+			if !ok {
+				return "", false
+			}
+			children = append(children, "("+inner+")")
+		}
+		return strings.Join(children, op), true
+	case "processor_failed":
+		conf := struct {
+			Part int `yaml:"part"`
+		}{}
+		if !decode(&conf) || conf.Part != 0 {
+			return "", false
+		}
+		return "errored()", true
+	case "text":
+		conf := struct {
+			Operator string `yaml:"operator"`
+			Part     int    `yaml:"part"`
+			Arg      string `yaml:"arg"`
+		}{Operator: "equals_cs"}
+		if !decode(&conf) || conf.Part != 0 {
+			return "", false
+		}
+		content, arg := "content().string()", conf.Arg
+		if !strings.HasSuffix(conf.Operator, "_cs") && conf.Operator != "regexp_partial" && conf.Operator != "regexp_exact" {
+			content, arg = content+".lowercase()", strings.ToLower(arg)
+		}
+		switch strings.TrimSuffix(conf.Operator, "_cs") {
+		case "equals":
+			return fmt.Sprintf("%v == %v", content, strconv.Quote(arg)), true
+		case "contains":
+			return fmt.Sprintf("%v.contains(%v)", content, strconv.Quote(arg)), true
+		case "prefix":
+			return fmt.Sprintf("%v.has_prefix(%v)", content, strconv.Quote(arg)), true
+		case "suffix":
+			return fmt.Sprintf("%v.has_suffix(%v)", content, strconv.Quote(arg)), true
+		case "regexp_partial":
+			return fmt.Sprintf("%v.re_match(%v)", content, strconv.Quote(arg)), true
+		}
+	case "metadata":
+		conf := struct {
+			Operator string `yaml:"operator"`
+			Part     int    `yaml:"part"`
+			Key      string `yaml:"key"`
+			Arg      string `yaml:"arg"`
+		}{Operator: "equals_cs"}
+		if !decode(&conf) || conf.Part != 0 {
+			return "", false
+		}
+		value := fmt.Sprintf("meta(%v).or(\"\")", strconv.Quote(conf.Key))
+		switch conf.Operator {
+		case "equals_cs":
+			return fmt.Sprintf("%v == %v", value, strconv.Quote(conf.Arg)), true
+		case "equals":
+			return fmt.Sprintf("%v.lowercase() == %v", value, strconv.Quote(strings.ToLower(conf.Arg))), true
+		case "exists":
+			return fmt.Sprintf("%v != \"\"", value), true
+		case "has_prefix":
+			return fmt.Sprintf("%v.has_prefix(%v)", value, strconv.Quote(conf.Arg)), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,195 @@
+package docs_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLComponentFixing(t *testing.T) {
+	for _, t := range docs.Types() {
+		docs.RegisterDocs(docs.ComponentSpec{
+			Name: fmt.Sprintf("testfixfoo%v", string(t)),
+			Type: t,
+			Config: docs.FieldComponent().WithChildren(
+				docs.FieldString("foo1", "").HasDefault("default"),
+				docs.FieldInt("foo2", "").HasDefault(10),
+				docs.FieldString("foo3", "").Array().HasDefault([]string{}),
+				docs.FieldString("foo4", "").OmitWhen(func(field, parent interface{}) (string, bool) {
+					return "because foo", field == "drop me"
+				}).Optional(),
+				docs.FieldCommon("foo5", "").Array().HasType(docs.FieldTypeProcessor).Optional(),
+				docs.FieldDeprecated("foo6").Fixer(func(ctx docs.LintContext, parent, value *yaml.Node) []docs.Lint {
+					for i := 0; i < len(parent.Content)-1; i += 2 {
+						if parent.Content[i].Value == "foo6" {
+							parent.Content[i].Value = "foo1"
+						}
+					}
+					return []docs.Lint{docs.NewLintFix(value.Line, "replaced foo6 with foo1")}
+				}).Optional(),
+			),
+		})
+	}
+
+	tests := []struct {
+		name      string
+		inputType docs.Type
+		inputConf string
+		res       string
+		fixes     []docs.Lint
+	}{
+		{
+			name:      "no fixes",
+			inputType: docs.TypeProcessor,
+			inputConf: `testfixfooprocessor:
+    # keep me
+    foo1: hello world # and me
+`,
+			res: `testfixfooprocessor:
+    # keep me
+    foo1: hello world # and me
+`,
+		},
+		{
+			name:      "remove defaults",
+			inputType: docs.TypeProcessor,
+			inputConf: `testfixfooprocessor:
+    foo1: default
+    foo2: 10
+    foo3: []
+    foo4: keep me
+`,
+			res: `testfixfooprocessor:
+    foo4: keep me
+`,
+			fixes: []docs.Lint{
+				docs.NewLintFix(2, "removed field foo1 as it matches the default value"),
+				docs.NewLintFix(3, "removed field foo2 as it matches the default value"),
+				docs.NewLintFix(4, "removed field foo3 as it matches the default value"),
+			},
+		},
+		{
+			name:      "omit and custom fixer",
+			inputType: docs.TypeInput,
+			inputConf: `testfixfooinput:
+    foo4: drop me
+    foo6: was deprecated
+processors: []
+`,
+			res: `testfixfooinput:
+    foo1: was deprecated
+`,
+			fixes: []docs.Lint{
+				docs.NewLintFix(3, "replaced foo6 with foo1"),
+				docs.NewLintFix(2, "removed field foo4: because foo"),
+				docs.NewLintFix(4, "removed field processors: field processors is empty and can be removed"),
+			},
+		},
+		{
+			name:      "nested components",
+			inputType: docs.TypeOutput,
+			inputConf: `testfixfoooutput:
+    foo5:
+      - testfixfooprocessor:
+          foo2: 10
+          foo4: keep me
+`,
+			res: `testfixfoooutput:
+    foo5:
+        - testfixfooprocessor:
+            foo4: keep me
+`,
+			fixes: []docs.Lint{
+				docs.NewLintFix(4, "removed field foo2 as it matches the default value"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var node yaml.Node
+			require.NoError(t, yaml.Unmarshal([]byte(test.inputConf), &node))
+
+			fixes := docs.FixYAML(docs.NewLintContext(), test.inputType, &node)
+			assert.Equal(t, test.fixes, fixes)
+
+			resBytes, err := yaml.Marshal(&node)
+			require.NoError(t, err)
+			assert.Equal(t, test.res, string(resBytes))
+		})
+	}
+}
+
+func TestConditionYAMLToBloblang(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		res   string
+		ok    bool
+	}{
+		{
+			name:  "bloblang",
+			input: `bloblang: 'this.foo == "bar"'`,
+			res:   `this.foo == "bar"`,
+			ok:    true,
+		},
+		{
+			name: "explicit type",
+			input: `type: static
+static: false`,
+			res: `false`,
+			ok:  true,
+		},
+		{
+			name: "logical operators",
+			input: `and:
+  - processor_failed: {}
+  - not:
+      or:
+        - text:
+            operator: prefix_cs
+            arg: foo
+        - metadata:
+            operator: exists
+            key: bar`,
+			res: `(errored()) && (!((content().string().has_prefix("foo")) || (meta("bar").or("") != "")))`,
+			ok:  true,
+		},
+		{
+			name: "case insensitive text",
+			input: `text:
+  operator: equals
+  arg: FOO`,
+			res: `content().string().lowercase() == "foo"`,
+			ok:  true,
+		},
+		{
+			name: "unsupported part",
+			input: `text:
+  operator: equals_cs
+  part: 1
+  arg: foo`,
+		},
+		{
+			name:  "unsupported type",
+			input: `jmespath: {query: foo}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var node yaml.Node
+			require.NoError(t, yaml.Unmarshal([]byte(test.input), &node))
+
+			res, ok := docs.ConditionYAMLToBloblang(&node)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.res, res)
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Package auth contains implementations of the authentication methods of HTTP
+// server components, such as basic authentication, JSON Web Tokens and HMAC
+// signatures.
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// BasicConfig contains configuration for authenticating requests with
+// basic authentication.
+type BasicConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Realm    string `json:"realm" yaml:"realm"`
+}
+
+// JWTConfig contains configuration for authenticating requests with a
+// bearer JSON Web Token.
+type JWTConfig struct {
+	Enabled           bool              `json:"enabled" yaml:"enabled"`
+	JWKSFile          string            `json:"jwks_file" yaml:"jwks_file"`
+	JWKSURL           string            `json:"jwks_url" yaml:"jwks_url"`
+	JWKSRefreshPeriod string            `json:"jwks_refresh_period" yaml:"jwks_refresh_period"`
+	Issuer            string            `json:"issuer" yaml:"issuer"`
+	Audience          string            `json:"audience" yaml:"audience"`
+	ClaimsToMetadata  map[string]string `json:"claims_to_metadata" yaml:"claims_to_metadata"`
+}
+
+// HMACConfig contains configuration for authenticating requests with an
+// HMAC signature of the request body.
+type HMACConfig struct {
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	Scheme      string `json:"scheme" yaml:"scheme"`
+	Secret      string `json:"secret" yaml:"secret"`
+	Header      string `json:"header" yaml:"header"`
+	Algorithm   string `json:"algorithm" yaml:"algorithm"`
+	Encoding    string `json:"encoding" yaml:"encoding"`
+	Prefix      string `json:"prefix" yaml:"prefix"`
+	Tolerance   string `json:"tolerance" yaml:"tolerance"`
+	MaxBodySize int64  `json:"max_body_size" yaml:"max_body_size"`
+}
+
+// Config contains configuration for authenticating requests.
+type Config struct {
+	Basic BasicConfig `json:"basic" yaml:"basic"`
+	JWT   JWTConfig   `json:"jwt" yaml:"jwt"`
+	HMAC  HMACConfig  `json:"hmac" yaml:"hmac"`
+}
+
+// NewConfig returns a new server auth config with default fields.
+func NewConfig() Config {
+	return Config{
+		Basic: BasicConfig{
+			Enabled:  false,
+			Username: "",
+			Password: "",
+			Realm:    "restricted",
+		},
+		JWT: JWTConfig{
+			Enabled:           false,
+			JWKSFile:          "",
+			JWKSURL:           "",
+			JWKSRefreshPeriod: "1h",
+			Issuer:            "",
+			Audience:          "",
+			ClaimsToMetadata:  map[string]string{},
+		},
+		HMAC: HMACConfig{
+			Enabled:     false,
+			Scheme:      "github",
+			Secret:      "",
+			Header:      "X-Hub-Signature-256",
+			Algorithm:   "sha256",
+			Encoding:    "hex",
+			Prefix:      "sha256=",
+			Tolerance:   "5m",
+			MaxBodySize: 10 * 1024 * 1024,
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ErrUnauthorized is returned when a request fails authentication.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrBodyTooLarge is returned when the body of a request that must be read in
+// order to be authenticated exceeds the maximum size.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Authenticator authenticates HTTP requests following a Config.
+type Authenticator struct {
+	conf Config
+
+	jwks *jwksProvider
+
+	hmacHash      func() hash.Hash
+	hmacTolerance time.Duration
+	nowFn         func() time.Time
+}
+
+// NewAuthenticator creates an authenticator from the config, or returns nil if
+// no authentication methods are enabled.
+func (conf Config) NewAuthenticator() (*Authenticator, error) {
+	if !conf.Basic.Enabled && !conf.JWT.Enabled && !conf.HMAC.Enabled {
+		return nil, nil
+	}
+
+	a := &Authenticator{
+		conf:  conf,
+		nowFn: time.Now,
+	}
+
+	if conf.Basic.Enabled && conf.Basic.Username == "" {
+		return nil, errors.New("basic auth requires a username")
+	}
+
+	if conf.JWT.Enabled {
+		var refreshPeriod time.Duration
+		if conf.JWT.JWKSRefreshPeriod != "" {
+			var err error
+			if refreshPeriod, err = time.ParseDuration(conf.JWT.JWKSRefreshPeriod); err != nil {
+				return nil, fmt.Errorf("failed to parse jwks refresh period: %w", err)
+			}
+		}
+		var err error
+		if a.jwks, err = newJWKSProvider(conf.JWT.JWKSFile, conf.JWT.JWKSURL, refreshPeriod); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.HMAC.Enabled {
+		if conf.HMAC.Secret == "" {
+			return nil, errors.New("hmac auth requires a secret")
+		}
+		if conf.HMAC.Header == "" {
+			return nil, errors.New("hmac auth requires a header")
+		}
+		if conf.HMAC.MaxBodySize <= 0 {
+			return nil, errors.New("hmac auth requires a max body size greater than zero")
+		}
+		switch conf.HMAC.Algorithm {
+		case "sha1":
+			a.hmacHash = sha1.New
+		case "sha256":
+			a.hmacHash = sha256.New
+		case "sha512":
+			a.hmacHash = sha512.New
+		default:
+			return nil, fmt.Errorf("hmac algorithm not recognised: %v", conf.HMAC.Algorithm)
+		}
+		switch conf.HMAC.Encoding {
+		case "hex", "base64":
+		default:
+			return nil, fmt.Errorf("hmac encoding not recognised: %v", conf.HMAC.Encoding)
+		}
+		switch conf.HMAC.Scheme {
+		case "github":
+		case "stripe":
+			if conf.HMAC.Tolerance != "" {
+				var err error
+				if a.hmacTolerance, err = time.ParseDuration(conf.HMAC.Tolerance); err != nil {
+					return nil, fmt.Errorf("failed to parse hmac tolerance: %w", err)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("hmac scheme not recognised: %v", conf.HMAC.Scheme)
+		}
+	}
+	return a, nil
+}
+
+// Authenticate a request, returning metadata extracted from the credentials of
+// the request when successful. When HMAC signatures are enabled the body of the
+// request is read and replaced with a buffer of its contents, and ErrBodyTooLarge
+// is returned if it exceeds the configured maximum size.
+func (a *Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) (map[string]string, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	if a.conf.Basic.Enabled {
+		user, pass, ok := r.BasicAuth()
+		if !ok {
+			return nil, fmt.Errorf("%w: missing basic auth credentials", ErrUnauthorized)
+		}
+		userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(a.conf.Basic.Username)) == 1
+		passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(a.conf.Basic.Password)) == 1
+		if !userMatch || !passMatch {
+			return nil, fmt.Errorf("%w: invalid basic auth credentials", ErrUnauthorized)
+		}
+	}
+
+	var meta map[string]string
+	if a.conf.JWT.Enabled {
+		var err error
+		if meta, err = a.authenticateJWT(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.conf.HMAC.Enabled {
+		if err := a.authenticateHMAC(w, r); err != nil {
+			return nil, err
+		}
+	}
+	return meta, nil
+}
+
+// WriteUnauthorized writes an unauthorized response.
+func (a *Authenticator) WriteUnauthorized(w http.ResponseWriter) {
+	if a.conf.Basic.Enabled {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+a.conf.Basic.Realm+`", charset="UTF-8"`)
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+func (a *Authenticator) authenticateJWT(r *http.Request) (map[string]string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) < 7 || !strings.EqualFold(authHeader[:7], "bearer ") {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(strings.TrimSpace(authHeader[7:]), claims, a.jwks.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: invalid bearer token: %v", ErrUnauthorized, err)
+	}
+	if a.conf.JWT.Issuer != "" && !claims.VerifyIssuer(a.conf.JWT.Issuer, true) {
+		return nil, fmt.Errorf("%w: token issuer does not match", ErrUnauthorized)
+	}
+	if a.conf.JWT.Audience != "" && !claims.VerifyAudience(a.conf.JWT.Audience, true) {
+		return nil, fmt.Errorf("%w: token audience does not match", ErrUnauthorized)
+	}
+
+	meta := make(map[string]string, len(a.conf.JWT.ClaimsToMetadata))
+	for claim, key := range a.conf.JWT.ClaimsToMetadata {
+		v, exists := claims[claim]
+		if !exists {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			meta[key] = s
+		} else if b, err := json.Marshal(v); err == nil {
+			meta[key] = string(b)
+		}
+	}
+	return meta, nil
+}
+
+func (a *Authenticator) authenticateHMAC(w http.ResponseWriter, r *http.Request) error {
+	sigHeader := r.Header.Get(a.conf.HMAC.Header)
+	if sigHeader == "" {
+		return fmt.Errorf("%w: missing signature header %v", ErrUnauthorized, a.conf.HMAC.Header)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, a.conf.HMAC.MaxBodySize))
+	if err != nil {
+		if int64(len(body)) >= a.conf.HMAC.MaxBodySize {
+			return ErrBodyTooLarge
+		}
+		return err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if a.conf.HMAC.Scheme == "stripe" {
+		return a.verifyStripeSignature(sigHeader, body)
+	}
+
+	if !strings.HasPrefix(sigHeader, a.conf.HMAC.Prefix) {
+		return fmt.Errorf("%w: signature header is missing prefix %v", ErrUnauthorized, a.conf.HMAC.Prefix)
+	}
+
+	var sig []byte
+	sigStr := strings.TrimPrefix(sigHeader, a.conf.HMAC.Prefix)
+	if a.conf.HMAC.Encoding == "base64" {
+		sig, err = base64.StdEncoding.DecodeString(sigStr)
+	} else {
+		sig, err = hex.DecodeString(sigStr)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode signature: %v", ErrUnauthorized, err)
+	}
+
+	if !hmac.Equal(sig, a.sign(body)) {
+		return fmt.Errorf("%w: signature does not match", ErrUnauthorized)
+	}
+	return nil
+}
+
+func (a *Authenticator) verifyStripeSignature(sigHeader string, body []byte) error {
+	var timestamp string
+	var sigs [][]byte
+	for _, pair := range strings.Split(sigHeader, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	if timestamp == "" || len(sigs) == 0 {
+		return fmt.Errorf("%w: signature header is malformed", ErrUnauthorized)
+	}
+
+	if a.hmacTolerance > 0 {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: failed to parse signature timestamp: %v", ErrUnauthorized, err)
+		}
+		if age := a.nowFn().Sub(time.Unix(ts, 0)); age > a.hmacTolerance || age < -a.hmacTolerance {
+			return fmt.Errorf("%w: signature timestamp is outside of the tolerance", ErrUnauthorized)
+		}
+	}
+
+	payload := make([]byte, 0, len(timestamp)+1+len(body))
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	payload = append(payload, body...)
+
+	expected := a.sign(payload)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature does not match", ErrUnauthorized)
+}
+
+func (a *Authenticator) sign(payload []byte) []byte {
+	mac := hmac.New(a.hmacHash, []byte(a.conf.HMAC.Secret))
+	_, _ = mac.Write(payload)
+	return mac.Sum(nil)
+}
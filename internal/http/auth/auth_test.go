@@ -0,0 +1,399 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerAuthDisabled(t *testing.T) {
+	a, err := NewConfig().NewAuthenticator()
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	meta, err := a.Authenticate(httptest.NewRecorder(), httptest.NewRequest("POST", "/", http.NoBody))
+	require.NoError(t, err)
+	assert.Empty(t, meta)
+}
+
+func TestServerAuthBasic(t *testing.T) {
+	conf := NewConfig()
+	conf.Basic.Enabled = true
+	conf.Basic.Username = "foo"
+	conf.Basic.Password = "bar"
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/", http.NoBody)
+	req.SetBasicAuth("foo", "bar")
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+
+	req = httptest.NewRequest("POST", "/", http.NoBody)
+	req.SetBasicAuth("foo", "baz")
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = a.Authenticate(httptest.NewRecorder(), httptest.NewRequest("POST", "/", http.NoBody))
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	res := httptest.NewRecorder()
+	a.WriteUnauthorized(res)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, `Basic realm="restricted", charset="UTF-8"`, res.Header().Get("WWW-Authenticate"))
+}
+
+func testJWKS(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+			{
+				"kty": "RSA",
+				"kid": "encryption",
+				"use": "enc",
+			},
+		},
+	})
+	require.NoError(t, err)
+	return b
+}
+
+func testToken(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestServerAuthJWTFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksPath, testJWKS(t, "foo", &key.PublicKey), 0o644))
+
+	conf := NewConfig()
+	conf.JWT.Enabled = true
+	conf.JWT.JWKSFile = jwksPath
+	conf.JWT.Issuer = "benthos"
+	conf.JWT.ClaimsToMetadata = map[string]string{
+		"sub":    "user_id",
+		"scopes": "user_scopes",
+	}
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		token    string
+		expected map[string]string
+	}{
+		{
+			name: "valid token",
+			token: testToken(t, "foo", key, jwt.MapClaims{
+				"iss":    "benthos",
+				"sub":    "bob",
+				"scopes": []string{"read", "write"},
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}),
+			expected: map[string]string{
+				"user_id":     "bob",
+				"user_scopes": `["read","write"]`,
+			},
+		},
+		{
+			name: "expired token",
+			token: testToken(t, "foo", key, jwt.MapClaims{
+				"iss": "benthos",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}),
+		},
+		{
+			name: "wrong issuer",
+			token: testToken(t, "foo", key, jwt.MapClaims{
+				"iss": "someone else",
+			}),
+		},
+		{
+			name: "wrong key",
+			token: testToken(t, "foo", otherKey, jwt.MapClaims{
+				"iss": "benthos",
+			}),
+		},
+		{
+			name: "unknown key",
+			token: testToken(t, "bar", key, jwt.MapClaims{
+				"iss": "benthos",
+			}),
+		},
+		{
+			name: "wrong signing method",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "benthos"})
+				token.Header["kid"] = "foo"
+				s, err := token.SignedString(key.PublicKey.N.Bytes())
+				require.NoError(t, err)
+				return s
+			}(),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			meta, err := a.Authenticate(httptest.NewRecorder(), req)
+			if test.expected == nil {
+				require.ErrorIs(t, err, ErrUnauthorized)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, meta)
+		})
+	}
+
+	_, err = a.Authenticate(httptest.NewRecorder(), httptest.NewRequest("POST", "/", http.NoBody))
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestServerAuthJWTURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write(testJWKS(t, "foo", &key.PublicKey))
+	}))
+	t.Cleanup(server.Close)
+
+	conf := NewConfig()
+	conf.JWT.Enabled = true
+	conf.JWT.JWKSURL = server.URL
+	conf.JWT.Audience = "things"
+	conf.JWT.ClaimsToMetadata = map[string]string{"sub": "user_id"}
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fetches))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+testToken(t, "foo", key, jwt.MapClaims{
+			"aud": "things",
+			"sub": "bob",
+		}))
+
+		meta, err := a.Authenticate(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"user_id": "bob"}, meta)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	req := httptest.NewRequest("POST", "/", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, "foo", key, jwt.MapClaims{
+		"aud": "other things",
+	}))
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestServerAuthHMACGitHub(t *testing.T) {
+	conf := NewConfig()
+	conf.HMAC.Enabled = true
+	conf.HMAC.Secret = "shh"
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+
+	body := `{"action":"opened"}`
+	mac := hmac.New(sha256.New, []byte("shh"))
+	_, _ = mac.Write([]byte(body))
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", sig)
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+
+	// The body must remain readable after authentication.
+	resBody, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(resBody))
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"action":"closed"}`))
+	req.Header.Set("X-Hub-Signature-256", sig)
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", strings.TrimPrefix(sig, "sha256="))
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = a.Authenticate(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestServerAuthHMACStripe(t *testing.T) {
+	conf := NewConfig()
+	conf.HMAC.Enabled = true
+	conf.HMAC.Scheme = "stripe"
+	conf.HMAC.Header = "Stripe-Signature"
+	conf.HMAC.Secret = "shh"
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+
+	now := time.Unix(1600000000, 0)
+	a.nowFn = func() time.Time {
+		return now
+	}
+
+	body := `{"type":"charge.succeeded"}`
+	sign := func(ts int64) string {
+		mac := hmac.New(sha256.New, []byte("shh"))
+		_, _ = mac.Write([]byte(strconv.FormatInt(ts, 10) + "." + body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{
+			name:   "valid signature",
+			header: "t=1600000000,v1=" + sign(1600000000),
+			valid:  true,
+		},
+		{
+			name:   "valid signature among others",
+			header: "t=1600000000,v1=deadbeef,v0=" + sign(1600000000) + ",v1=" + sign(1600000000),
+			valid:  true,
+		},
+		{
+			name:   "timestamp outside of tolerance",
+			header: "t=1599999000,v1=" + sign(1599999000),
+		},
+		{
+			name:   "signature of another timestamp",
+			header: "t=1600000001,v1=" + sign(1600000000),
+		},
+		{
+			name:   "malformed header",
+			header: sign(1600000000),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Set("Stripe-Signature", test.header)
+
+			_, err := a.Authenticate(httptest.NewRecorder(), req)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrUnauthorized)
+			}
+		})
+	}
+}
+
+func TestServerAuthHMACBodyTooLarge(t *testing.T) {
+	conf := NewConfig()
+	conf.HMAC.Enabled = true
+	conf.HMAC.Secret = "shh"
+	conf.HMAC.MaxBodySize = 10
+
+	a, err := conf.NewAuthenticator()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("this body is too large"))
+	req.Header.Set("X-Hub-Signature-256", "sha256=nope")
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	body := "just right"
+	mac := hmac.New(sha256.New, []byte("shh"))
+	_, _ = mac.Write([]byte(body))
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	_, err = a.Authenticate(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+}
+
+func TestServerAuthBadConfig(t *testing.T) {
+	tests := map[string]func(c *Config){
+		"basic without username": func(c *Config) {
+			c.Basic.Enabled = true
+		},
+		"jwt without jwks": func(c *Config) {
+			c.JWT.Enabled = true
+		},
+		"jwt with both jwks": func(c *Config) {
+			c.JWT.Enabled = true
+			c.JWT.JWKSFile = "./foo.json"
+			c.JWT.JWKSURL = "http://localhost/foo.json"
+		},
+		"hmac without secret": func(c *Config) {
+			c.HMAC.Enabled = true
+		},
+		"hmac bad algorithm": func(c *Config) {
+			c.HMAC.Enabled = true
+			c.HMAC.Secret = "shh"
+			c.HMAC.Algorithm = "md5"
+		},
+		"hmac bad scheme": func(c *Config) {
+			c.HMAC.Enabled = true
+			c.HMAC.Secret = "shh"
+			c.HMAC.Scheme = "nope"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewConfig()
+		fn(&conf)
+		_, err := conf.NewAuthenticator()
+		assert.Error(t, err, name)
+	}
+}
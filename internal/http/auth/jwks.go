@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// jwksMinRefetchPeriod is the minimum period between fetches of a key set
+// triggered by tokens signed with unknown keys, which prevents clients from
+// forcing a fetch for every request.
+const jwksMinRefetchPeriod = time.Second * 30
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Symmetric keys
+	K string `json:"k"`
+}
+
+func decodeJWKField(name, v string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode field %v: %w", name, err)
+	}
+	return b, nil
+}
+
+// publicKey returns the key that verifies signatures for this JWK.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		nBytes, err := decodeJWKField("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		eBytes, err := decodeJWKField("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		e := new(big.Int).SetBytes(eBytes)
+		if !e.IsInt64() || e.Int64() > int64(^uint32(0)>>1) {
+			return nil, errors.New("rsa exponent is too large")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(e.Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve not supported: %v", k.Crv)
+		}
+		xBytes, err := decodeJWKField("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		yBytes, err := decodeJWKField("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return pub, nil
+	case "oct":
+		return decodeJWKField("k", k.K)
+	}
+	return nil, fmt.Errorf("key type not supported: %v", k.Kty)
+}
+
+// parseJWKS parses a JSON Web Key Set into a map of key IDs to the keys that
+// verify signatures. Keys that are intended for encryption are ignored.
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwks key %v: %w", i, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks does not contain any signing keys")
+	}
+	return keys, nil
+}
+
+//------------------------------------------------------------------------------
+
+// jwksProvider provides the keys of a JSON Web Key Set loaded from either a
+// file or a URL.
+type jwksProvider struct {
+	url           string
+	refreshPeriod time.Duration
+	client        *http.Client
+
+	mut       sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func newJWKSProvider(file, url string, refreshPeriod time.Duration) (*jwksProvider, error) {
+	if (file == "") == (url == "") {
+		return nil, errors.New("jwt auth requires exactly one of jwks_file or jwks_url")
+	}
+
+	p := &jwksProvider{
+		url:           url,
+		refreshPeriod: refreshPeriod,
+		client:        &http.Client{Timeout: time.Second * 10},
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwks file: %w", err)
+		}
+		if p.keys, err = parseJWKS(data); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *jwksProvider) fetch() error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, p.url, http.NoBody)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("failed to fetch jwks: unexpected status code %v", res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	p.keys = keys
+	return nil
+}
+
+// key returns the key with a given ID, fetching the key set when it has not yet
+// been fetched, is due a refresh, or does not contain the key.
+func (p *jwksProvider) key(kid string) (interface{}, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.url != "" {
+		sinceFetch := time.Since(p.fetchedAt)
+		_, exists := p.keys[kid]
+		if (p.keys == nil && sinceFetch > time.Second) ||
+			(p.refreshPeriod > 0 && sinceFetch > p.refreshPeriod) ||
+			(!exists && sinceFetch > jwksMinRefetchPeriod) {
+			// Failing to refresh the key set doesn't prevent the use of keys
+			// that were previously fetched.
+			p.fetchedAt = time.Now()
+			if err := p.fetch(); err != nil && p.keys == nil {
+				return nil, err
+			}
+		}
+		if p.keys == nil {
+			return nil, errors.New("jwks has not been fetched")
+		}
+	}
+
+	if k, exists := p.keys[kid]; exists {
+		return k, nil
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("key '%v' was not found within the jwks", kid)
+}
+
+// keyFunc provides the key that verifies the signature of a token, ensuring
+// that the signing method of the token matches the type of the key.
+func (p *jwksProvider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, err := p.key(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	var methodMatches bool
+	switch k.(type) {
+	case *rsa.PublicKey:
+		_, methodMatches = token.Method.(*jwt.SigningMethodRSA)
+		if !methodMatches {
+			_, methodMatches = token.Method.(*jwt.SigningMethodRSAPSS)
+		}
+	case *ecdsa.PublicKey:
+		_, methodMatches = token.Method.(*jwt.SigningMethodECDSA)
+	case []byte:
+		_, methodMatches = token.Method.(*jwt.SigningMethodHMAC)
+	}
+	if !methodMatches {
+		return nil, fmt.Errorf("signing method %v does not match the key '%v'", token.Method.Alg(), kid)
+	}
+	return k, nil
+}
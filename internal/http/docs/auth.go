@@ -0,0 +1,43 @@
+package docs
+
+import (
+	"github.com/Jeffail/benthos/v3/internal/docs"
+)
+
+// ServerAuthFieldSpec returns a field spec for an http server auth component.
+func ServerAuthFieldSpec() docs.FieldSpec {
+	return docs.FieldAdvanced("auth", "Authenticate requests before they are consumed. When multiple methods are enabled a request must satisfy all of them, and requests that fail authentication are rejected with a 401 status code.").WithChildren(
+		docs.FieldAdvanced("basic", "Require requests to provide [basic authentication](https://en.wikipedia.org/wiki/Basic_access_authentication) credentials.").WithChildren(
+			docs.FieldBool("enabled", "Whether to require basic authentication.").HasDefault(false),
+			docs.FieldString("username", "The username that requests must provide.").HasDefault(""),
+			docs.FieldString("password", "The password that requests must provide.").HasDefault(""),
+			docs.FieldString("realm", "The realm returned to clients that fail authentication.").HasDefault("restricted").Advanced(),
+		),
+		docs.FieldAdvanced("jwt", "Require requests to provide a bearer [JSON Web Token](https://jwt.io/) within the `Authorization` header, which is validated against a [JSON Web Key Set](https://datatracker.ietf.org/doc/html/rfc7517). Tokens must be signed using RSA, ECDSA or HMAC algorithms, and expired tokens are rejected.").WithChildren(
+			docs.FieldBool("enabled", "Whether to require a JSON Web Token.").HasDefault(false),
+			docs.FieldString("jwks_file", "The path of a file containing a JSON Web Key Set.").HasDefault(""),
+			docs.FieldString("jwks_url", "A URL to fetch a JSON Web Key Set from. The key set is fetched again periodically, and also when a token is signed with a key that is not within the current key set.", "https://example.com/.well-known/jwks.json").HasDefault(""),
+			docs.FieldString("jwks_refresh_period", "The period after which a key set fetched from a URL is refreshed.").HasDefault("1h").Advanced(),
+			docs.FieldString("issuer", "An optional issuer that tokens must specify within the claim `iss`.").HasDefault(""),
+			docs.FieldString("audience", "An optional audience that tokens must specify within the claim `aud`.").HasDefault(""),
+			docs.FieldString(
+				"claims_to_metadata", "A map of token claims to the metadata keys they are added to messages as. Claims that are not strings are added as JSON.",
+				map[string]string{"sub": "user_id"},
+			).Map().HasDefault(map[string]string{}),
+		),
+		docs.FieldAdvanced("hmac", "Require requests to provide an HMAC signature of the request body within a header, as is common with webhooks.").WithChildren(
+			docs.FieldBool("enabled", "Whether to require an HMAC signature.").HasDefault(false),
+			docs.FieldString("scheme", "The scheme of the signature header.").HasAnnotatedOptions(
+				"github", "The header contains the signature of the request body with an optional `prefix`, as used by GitHub and many other webhook providers.",
+				"stripe", "The header contains a timestamp and signatures of the form `t=<timestamp>,v1=<signature>`, where the signed payload is the timestamp followed by a period and the request body, as used by Stripe. The `encoding` and `prefix` fields are ignored.",
+			).HasDefault("github"),
+			docs.FieldString("secret", "The secret used to sign requests.").HasDefault(""),
+			docs.FieldString("header", "The header containing the signature.", "X-Hub-Signature-256", "Stripe-Signature").HasDefault("X-Hub-Signature-256"),
+			docs.FieldString("algorithm", "The hash algorithm of the signature.").HasOptions("sha1", "sha256", "sha512").HasDefault("sha256").Advanced(),
+			docs.FieldString("encoding", "The encoding of the signature.").HasOptions("hex", "base64").HasDefault("hex").Advanced(),
+			docs.FieldString("prefix", "A prefix of the signature header value, which is removed before the signature is compared.").HasDefault("sha256=").Advanced(),
+			docs.FieldString("tolerance", "The maximum age of a signature timestamp when using the `stripe` scheme, where zero disables the check.").HasDefault("5m").Advanced(),
+			docs.FieldInt("max_body_size", "The maximum size in bytes of request bodies, which are read in full in order to verify their signature. Requests with larger bodies are rejected with a 413 status code.").HasDefault(10*1024*1024).Advanced(),
+		),
+	).AtVersion("3.65.0")
+}
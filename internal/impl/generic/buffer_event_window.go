@@ -0,0 +1,896 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+func eventWindowBufferConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Windowing").
+		Summary("Chops a stream of messages into tumbling or sliding windows of fixed temporal size following the event time of messages, where windows are flushed by watermarks rather than the system clock.").
+		Description(`
+A window is a grouping of messages that fit within a discrete measure of time. Unlike the `+"[`system_window` buffer](/docs/components/buffers/system_window)"+` this buffer allocates messages to windows by their event time, as extracted with the `+"[`timestamp_mapping` field](#timestamp_mapping)"+`, and windows are flushed once a watermark derived from the event times of ingested messages surpasses their end. This means that replayed or delayed data is allocated to the windows it belongs to regardless of when it is consumed.
+
+Windows are aligned to the UTC clock and the beginning of a window immediately follows the end of a prior window. When a window is flushed each message has the metadata fields `+"`window_start_timestamp`"+` and `+"`window_end_timestamp`"+` added to it containing the timestamps of the beginning and end of the window as RFC3339 strings.
+
+## Watermarks
+
+The watermark is the latest event timestamp observed minus the `+"[`max_out_of_orderness`](#max_out_of_orderness)"+` duration, and represents the point in event time before which no more messages are expected to arrive. A window is flushed once the watermark surpasses the end of the window plus any `+"[`allowed_lateness`](#allowed_lateness)"+`.
+
+Since the watermark only advances as messages arrive, the final windows of a stream that has stopped receiving data would never be flushed. An `+"[`idle_timeout`](#idle_timeout)"+` can be specified in order to flush all open windows once no messages have been received for that duration of time according to the system clock.
+
+## Late Data
+
+Messages that arrive with an event timestamp belonging only to windows that have already been flushed are considered late. By default late messages are dropped, but they can instead be routed to a `+"[`late_output`](#late_output)"+` in order to be stored or processed separately.
+
+## Checkpointing
+
+By default the state of open windows is held only in memory and messages are not acknowledged until the window they belong to has been delivered. When a `+"[`checkpoint.cache`](#checkpointcache)"+` is specified the state of all open windows, along with the watermark, is periodically written to the cache resource, and messages are acknowledged once a snapshot containing them has been written successfully. On start up the snapshot is restored and therefore windows are resumed without re-consuming (and double counting) messages, and late replayed data is recognised as such.
+
+## Delivery Guarantees
+
+When a window is flushed and its delivery fails it is retried until successful. When this buffer is configured with a slide duration it is possible for messages to belong to multiple windows, and therefore be delivered multiple times.
+
+During graceful termination any open windows are either written to the checkpoint cache, or when checkpointing is disabled their messages are nacked such that they are re-consumed the next time the service starts.
+`).
+		Field(service.NewBloblangField("timestamp_mapping").
+			Description(`
+A [Bloblang mapping](/docs/guides/bloblang/about) applied to each message during ingestion that provides the event timestamp to use for allocating it a window.
+
+The timestamp value assigned to `+"`root`"+` must either be a numerical unix time in seconds (with up to nanosecond precision via decimals), or a string in ISO 8601 format.
+`).
+			Example("root = this.created_at").Example(`root = meta("kafka_timestamp_unix").number()`)).
+		Field(service.NewBloblangField("group_by").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) applied to each message during ingestion that provides a key, where messages of each key within a window are flushed as their own batch with the metadata field `window_key` added.").
+			Optional().
+			Example(`root = this.user_id`)).
+		Field(service.NewStringField("size").
+			Description("A duration string describing the size of each window.").
+			Example("30s").Example("10m")).
+		Field(service.NewStringField("slide").
+			Description("An optional duration string describing by how much time the beginning of each window should be offset from the beginning of the previous, and therefore creates sliding windows instead of tumbling. When specified this duration must be smaller than the `size` of the window.").
+			Default("").
+			Example("30s").Example("10m")).
+		Field(service.NewStringField("offset").
+			Description("An optional duration string to offset the beginning of each window by, otherwise they are aligned to the zeroth minute and zeroth hour on the UTC clock. The offset cannot be a larger or equal measure to the window size or the slide.").
+			Default("").
+			Example("-6h").Example("30m")).
+		Field(service.NewStringField("max_out_of_orderness").
+			Description("An optional duration string describing how far behind the latest observed event timestamp the watermark should trail, allowing out of order messages to be included in their windows.").
+			Default("").
+			Example("5s").Example("1m")).
+		Field(service.NewStringField("allowed_lateness").
+			Description("An optional duration string describing the length of time after a window has ended, according to the watermark, to wait before flushing it.").
+			Default("").
+			Example("10s").Example("1m")).
+		Field(service.NewStringField("idle_timeout").
+			Description("An optional duration string describing a period of time, following the system clock, after which all open windows are flushed if no messages have been received.").
+			Default("").
+			Example("1m").Example("1h")).
+		Field(service.NewOutputField("late_output").
+			Description("An optional output to route late messages to, otherwise late messages are dropped.").
+			Optional().
+			Advanced()).
+		Field(service.NewObjectField("checkpoint",
+			service.NewStringField("cache").
+				Description("An optional [cache resource](/docs/components/caches/about) to write snapshots of open windows to.").
+				Default(""),
+			service.NewStringField("key").
+				Description("The key under which snapshots are stored, when multiple event window buffers share a cache resource each must have a unique key.").
+				Default("benthos_event_window"),
+			service.NewStringField("interval").
+				Description("A duration string describing how often to write snapshots. A snapshot is only written when the state of windows has changed since the last one.").
+				Default("10s"),
+		).Description("Allows the state of open windows to be periodically written to a cache resource and restored on start up.")).
+		Example("Hourly Purchases Per Store", `Given a stream of purchase events that may arrive up to a minute out of order we can create hourly summaries of each store, where purchases arriving after their hour has been summarised are written to a file for manual auditing, and windows are checkpointed to a Redis cache so that a restart does not lose or double count purchases:`,
+			`
+buffer:
+  event_window:
+    timestamp_mapping: root = this.purchased_at
+    group_by: root = this.store_id
+    size: 1h
+    max_out_of_orderness: 1m
+    idle_timeout: 10m
+    late_output:
+      file:
+        path: ./late_purchases.jsonl
+        codec: lines
+    checkpoint:
+      cache: windows
+
+cache_resources:
+  - label: windows
+    redis:
+      url: tcp://localhost:6379
+
+pipeline:
+  processors:
+    - bloblang: |
+        root = if batch_index() == 0 {
+          {
+            "store_id": meta("window_key"),
+            "hour": meta("window_start_timestamp"),
+            "purchases": batch_size(),
+            "total": json("price").from_all().sum(),
+          }
+        } else { deleted() }
+`,
+		)
+}
+
+func init() {
+	err := service.RegisterBatchBuffer(
+		"event_window", eventWindowBufferConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchBuffer, error) {
+			tsMapping, err := conf.FieldBloblang("timestamp_mapping")
+			if err != nil {
+				return nil, err
+			}
+			var groupBy *bloblang.Executor
+			if conf.Contains("group_by") {
+				if groupBy, err = conf.FieldBloblang("group_by"); err != nil {
+					return nil, err
+				}
+			}
+			size, err := getDuration(conf, true, "size")
+			if err != nil {
+				return nil, err
+			}
+			if size <= 0 {
+				return nil, fmt.Errorf("invalid window size '%v' must be greater than zero", size)
+			}
+			slide, err := getDuration(conf, false, "slide")
+			if err != nil {
+				return nil, err
+			}
+			if slide >= size {
+				return nil, fmt.Errorf("invalid window slide '%v' must be lower than the size '%v'", slide, size)
+			}
+			offset, err := getDuration(conf, false, "offset")
+			if err != nil {
+				return nil, err
+			}
+			if offset >= size {
+				return nil, fmt.Errorf("invalid offset '%v' must be lower than the size '%v'", offset, size)
+			}
+			if slide > 0 && offset >= slide {
+				return nil, fmt.Errorf("invalid offset '%v' must be lower than the slide '%v'", offset, slide)
+			}
+			maxOutOfOrderness, err := getDuration(conf, false, "max_out_of_orderness")
+			if err != nil {
+				return nil, err
+			}
+			allowedLateness, err := getDuration(conf, false, "allowed_lateness")
+			if err != nil {
+				return nil, err
+			}
+
+			w := newEventWindowBuffer(tsMapping, groupBy, func() time.Time {
+				return time.Now().UTC()
+			}, size, slide, offset, maxOutOfOrderness, allowedLateness, mgr.Logger())
+
+			if w.idleTimeout, err = getDuration(conf, false, "idle_timeout"); err != nil {
+				return nil, err
+			}
+			if conf.Contains("late_output") {
+				if w.lateOutput, err = conf.FieldOutput("late_output"); err != nil {
+					return nil, err
+				}
+			}
+
+			checkpointConf := conf.Namespace("checkpoint")
+			cacheName, err := checkpointConf.FieldString("cache")
+			if err != nil {
+				return nil, err
+			}
+			if cacheName != "" {
+				cacheKey, err := checkpointConf.FieldString("key")
+				if err != nil {
+					return nil, err
+				}
+				interval, err := getDuration(checkpointConf, true, "interval")
+				if err != nil {
+					return nil, err
+				}
+				w.checkpoint = &eventWindowCheckpoint{
+					key:      cacheKey,
+					interval: interval,
+					accessCache: func(ctx context.Context, fn func(c service.Cache)) error {
+						return mgr.AccessCache(ctx, cacheName, fn)
+					},
+				}
+			}
+
+			w.start()
+			return w, nil
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// batchWriter is the subset of service.OwnedOutput used for routing late
+// messages.
+type batchWriter interface {
+	WriteBatch(ctx context.Context, b service.MessageBatch) error
+	Close(ctx context.Context) error
+}
+
+type eventWindowCheckpoint struct {
+	key         string
+	interval    time.Duration
+	accessCache func(ctx context.Context, fn func(c service.Cache)) error
+}
+
+// eventWindowMessage is a message pending within one or more open windows.
+type eventWindowMessage struct {
+	ts    time.Time
+	key   string
+	m     *service.Message
+	ackFn service.AckFunc
+
+	// The serialised form of the message within snapshots, which is encoded
+	// once by the first snapshot that contains it.
+	encoded json.RawMessage
+}
+
+// eventWindowFlush is a batch of a flushed window that is yet to be
+// successfully delivered.
+type eventWindowFlush struct {
+	b      service.MessageBatch
+	ackFns []service.AckFunc
+
+	encoded []json.RawMessage
+}
+
+type eventWindowBuffer struct {
+	logger *service.Logger
+
+	tsMapping, groupBy                                      *bloblang.Executor
+	clock                                                   utcNowProvider
+	size, slide, offset, maxOutOfOrderness, allowedLateness time.Duration
+	idleTimeout                                             time.Duration
+
+	lateOutput batchWriter
+	checkpoint *eventWindowCheckpoint
+
+	restoreMut sync.Mutex
+	restored   bool
+
+	mut            sync.Mutex
+	maxTS          time.Time
+	lastFlushedEnd time.Time
+	lastWrite      time.Time
+	pending        []*eventWindowMessage
+
+	// Set whenever the state of windows changes and cleared once a snapshot
+	// of that state has been written.
+	dirty bool
+
+	// Flushed windows that are yet to be delivered, and the queue of those
+	// that are yet to be read (or must be read again after a failed
+	// delivery).
+	nextFlushID uint64
+	undelivered map[uint64]*eventWindowFlush
+	readQueue   []uint64
+
+	// Signalled whenever a write occurs or a delivery fails so that the
+	// reader can reevaluate which windows are ready.
+	notifyChan chan struct{}
+
+	endOfInputChan      chan struct{}
+	closeEndOfInputOnce sync.Once
+
+	shutSig  chan struct{}
+	shutOnce sync.Once
+	loopDone chan struct{}
+}
+
+func newEventWindowBuffer(
+	tsMapping, groupBy *bloblang.Executor,
+	clock utcNowProvider,
+	size, slide, offset, maxOutOfOrderness, allowedLateness time.Duration,
+	logger *service.Logger,
+) *eventWindowBuffer {
+	return &eventWindowBuffer{
+		logger:            logger,
+		tsMapping:         tsMapping,
+		groupBy:           groupBy,
+		clock:             clock,
+		size:              size,
+		slide:             slide,
+		offset:            offset,
+		maxOutOfOrderness: maxOutOfOrderness,
+		allowedLateness:   allowedLateness,
+		lastWrite:         clock(),
+		undelivered:       map[uint64]*eventWindowFlush{},
+		notifyChan:        make(chan struct{}, 1),
+		endOfInputChan:    make(chan struct{}),
+		shutSig:           make(chan struct{}),
+		loopDone:          make(chan struct{}),
+	}
+}
+
+// start begins the periodic checkpointing loop, if enabled.
+func (w *eventWindowBuffer) start() {
+	if w.checkpoint == nil {
+		close(w.loopDone)
+		return
+	}
+	go func() {
+		defer close(w.loopDone)
+
+		ticker := time.NewTicker(w.checkpoint.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-w.shutSig:
+				return
+			}
+			ctx, done := context.WithTimeout(context.Background(), w.checkpoint.interval)
+			if err := w.snapshot(ctx); err != nil {
+				w.logger.Errorf("Failed to write window checkpoint: %v", err)
+			}
+			done()
+		}
+	}()
+}
+
+func (w *eventWindowBuffer) notify() {
+	select {
+	case w.notifyChan <- struct{}{}:
+	default:
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// latestWindowStart returns the start of the latest window that a timestamp
+// belongs to.
+func (w *eventWindowBuffer) latestWindowStart(ts time.Time) time.Time {
+	epoch := w.size
+	if w.slide > 0 {
+		epoch = w.slide
+	}
+	start := ts.Add(-w.offset).Truncate(epoch).Add(w.offset)
+	if start.After(ts) {
+		start = start.Add(-epoch)
+	}
+	return start
+}
+
+// earliestOpenWindowStart returns the start of the earliest window that a
+// timestamp belongs to and that has not yet been flushed, or false if all
+// windows of the timestamp have been flushed.
+func (w *eventWindowBuffer) earliestOpenWindowStart(ts time.Time) (time.Time, bool) {
+	latest := w.latestWindowStart(ts)
+	if !latest.Add(w.size).After(w.lastFlushedEnd) {
+		return time.Time{}, false
+	}
+	if w.slide == 0 {
+		return latest, true
+	}
+	start := latest
+	for {
+		prev := start.Add(-w.slide)
+		if !prev.Add(w.size).After(ts) || !prev.Add(w.size).After(w.lastFlushedEnd) {
+			return start, true
+		}
+		start = prev
+	}
+}
+
+func (w *eventWindowBuffer) watermark() time.Time {
+	return w.maxTS.Add(-w.maxOutOfOrderness)
+}
+
+//------------------------------------------------------------------------------
+
+type eventWindowSnapshotMessage struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Content   []byte            `json:"content"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// eventWindowSnapshot is the persisted state of all windows, messages are
+// stored in their encoded form so that only those new to a snapshot need to be
+// serialised.
+type eventWindowSnapshot struct {
+	MaxTimestamp   time.Time           `json:"max_timestamp"`
+	LastFlushedEnd time.Time           `json:"last_flushed_end"`
+	Pending        []json.RawMessage   `json:"pending"`
+	Undelivered    [][]json.RawMessage `json:"undelivered,omitempty"`
+}
+
+func encodeSnapshotMessage(ts time.Time, key string, m *service.Message) (json.RawMessage, error) {
+	content, err := m.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	sMsg := eventWindowSnapshotMessage{
+		Timestamp: ts,
+		Key:       key,
+		Content:   content,
+	}
+	_ = m.MetaWalk(func(k, v string) error {
+		if sMsg.Metadata == nil {
+			sMsg.Metadata = map[string]string{}
+		}
+		sMsg.Metadata[k] = v
+		return nil
+	})
+	return json.Marshal(sMsg)
+}
+
+func decodeSnapshotMessage(data json.RawMessage) (eventWindowSnapshotMessage, *service.Message, error) {
+	var sMsg eventWindowSnapshotMessage
+	if err := json.Unmarshal(data, &sMsg); err != nil {
+		return sMsg, nil, err
+	}
+	m := service.NewMessage(sMsg.Content)
+	for k, v := range sMsg.Metadata {
+		m.MetaSet(k, v)
+	}
+	return sMsg, m, nil
+}
+
+func (w *eventWindowBuffer) ensureRestored(ctx context.Context) error {
+	if w.checkpoint == nil {
+		return nil
+	}
+
+	w.restoreMut.Lock()
+	defer w.restoreMut.Unlock()
+	if w.restored {
+		return nil
+	}
+
+	var data []byte
+	var getErr error
+	if err := w.checkpoint.accessCache(ctx, func(c service.Cache) {
+		data, getErr = c.Get(ctx, w.checkpoint.key)
+	}); err != nil {
+		return fmt.Errorf("failed to access checkpoint cache: %w", err)
+	}
+	if getErr != nil {
+		if errors.Is(getErr, service.ErrKeyNotFound) {
+			w.restored = true
+			return nil
+		}
+		return fmt.Errorf("failed to read checkpoint: %w", getErr)
+	}
+
+	var snap eventWindowSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+
+	restoredPending := make([]*eventWindowMessage, 0, len(snap.Pending))
+	for _, data := range snap.Pending {
+		sMsg, m, err := decodeSnapshotMessage(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse checkpoint: %w", err)
+		}
+		restoredPending = append(restoredPending, &eventWindowMessage{
+			ts:      sMsg.Timestamp,
+			key:     sMsg.Key,
+			m:       m,
+			ackFn:   noopAckFn,
+			encoded: data,
+		})
+	}
+	restoredFlushes := make([]*eventWindowFlush, 0, len(snap.Undelivered))
+	for _, sBatch := range snap.Undelivered {
+		f := &eventWindowFlush{encoded: sBatch}
+		for _, data := range sBatch {
+			_, m, err := decodeSnapshotMessage(data)
+			if err != nil {
+				return fmt.Errorf("failed to parse checkpoint: %w", err)
+			}
+			f.b = append(f.b, m)
+		}
+		restoredFlushes = append(restoredFlushes, f)
+	}
+
+	w.mut.Lock()
+	if snap.MaxTimestamp.After(w.maxTS) {
+		w.maxTS = snap.MaxTimestamp
+	}
+	w.lastFlushedEnd = snap.LastFlushedEnd
+	w.pending = append(restoredPending, w.pending...)
+	for _, f := range restoredFlushes {
+		w.nextFlushID++
+		w.undelivered[w.nextFlushID] = f
+		w.readQueue = append(w.readQueue, w.nextFlushID)
+	}
+	w.mut.Unlock()
+
+	w.logger.Infof("Restored %v pending messages and %v undelivered windows from checkpoint", len(snap.Pending), len(snap.Undelivered))
+	w.restored = true
+	w.notify()
+	return nil
+}
+
+func noopAckFn(context.Context, error) error {
+	return nil
+}
+
+// snapshot writes the current state of all windows to the checkpoint cache if
+// it has changed since the last snapshot, and once successful acknowledges all
+// messages within the snapshot. Messages are only serialised the first time
+// they're included in a snapshot.
+func (w *eventWindowBuffer) snapshot(ctx context.Context) error {
+	if w.checkpoint == nil {
+		return nil
+	}
+
+	// Never overwrite a checkpoint that is yet to be restored.
+	w.restoreMut.Lock()
+	restored := w.restored
+	w.restoreMut.Unlock()
+	if !restored {
+		return nil
+	}
+
+	w.mut.Lock()
+	if !w.dirty {
+		w.mut.Unlock()
+		return nil
+	}
+	snap := eventWindowSnapshot{
+		MaxTimestamp:   w.maxTS,
+		LastFlushedEnd: w.lastFlushedEnd,
+		Pending:        make([]json.RawMessage, 0, len(w.pending)),
+	}
+	var ackFns []service.AckFunc
+	for _, p := range w.pending {
+		if p.encoded == nil {
+			var err error
+			if p.encoded, err = encodeSnapshotMessage(p.ts, p.key, p.m); err != nil {
+				w.mut.Unlock()
+				return err
+			}
+		}
+		snap.Pending = append(snap.Pending, p.encoded)
+		ackFns = append(ackFns, p.ackFn)
+	}
+	ids := make([]uint64, 0, len(w.undelivered))
+	for id := range w.undelivered {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		f := w.undelivered[id]
+		if f.encoded == nil {
+			f.encoded = make([]json.RawMessage, 0, len(f.b))
+			for _, m := range f.b {
+				data, err := encodeSnapshotMessage(time.Time{}, "", m)
+				if err != nil {
+					w.mut.Unlock()
+					return err
+				}
+				f.encoded = append(f.encoded, data)
+			}
+		}
+		snap.Undelivered = append(snap.Undelivered, f.encoded)
+		ackFns = append(ackFns, f.ackFns...)
+	}
+	w.dirty = false
+	w.mut.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		w.mut.Lock()
+		w.dirty = true
+		w.mut.Unlock()
+		return err
+	}
+
+	var setErr error
+	if err := w.checkpoint.accessCache(ctx, func(c service.Cache) {
+		setErr = c.Set(ctx, w.checkpoint.key, data, nil)
+	}); err == nil {
+		err = setErr
+	}
+	if err != nil {
+		// Try again with the next snapshot.
+		w.mut.Lock()
+		w.dirty = true
+		w.mut.Unlock()
+		return err
+	}
+
+	// Every message within the snapshot is now persisted and therefore we can
+	// acknowledge them, this is a no-op for messages already acknowledged.
+	for _, aFn := range ackFns {
+		_ = aFn(ctx, nil)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func (w *eventWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageBatch, aFn service.AckFunc) error {
+	if err := w.ensureRestored(ctx); err != nil {
+		return err
+	}
+
+	// Extract timestamps and keys before modifying any state so that a
+	// failed mapping rejects the batch as a whole.
+	timestamps := make([]time.Time, len(msgBatch))
+	keys := make([]string, len(msgBatch))
+	for i := range msgBatch {
+		var err error
+		if timestamps[i], err = getWindowTimestamp(w.logger, w.tsMapping, i, msgBatch); err != nil {
+			return err
+		}
+		if keys[i], err = getWindowKey(w.logger, w.groupBy, i, msgBatch); err != nil {
+			return err
+		}
+	}
+
+	w.mut.Lock()
+
+	derived := false
+	aggregatedAck := batch.NewCombinedAcker(batch.AckFunc(aFn))
+
+	var lateBatch service.MessageBatch
+	var lateAckFn service.AckFunc
+
+	for i, msg := range msgBatch {
+		ts := timestamps[i]
+		if _, open := w.earliestOpenWindowStart(ts); !open {
+			// All windows of this message have been flushed.
+			if w.lateOutput == nil {
+				w.logger.Debugf("Dropping late message with timestamp %v", ts.Format(time.RFC3339Nano))
+				continue
+			}
+			if lateAckFn == nil {
+				lateAckFn = service.AckFunc(aggregatedAck.Derive())
+				derived = true
+			}
+			lateBatch = append(lateBatch, msg)
+			continue
+		}
+
+		derived = true
+		w.dirty = true
+		w.pending = append(w.pending, &eventWindowMessage{
+			ts: ts, key: keys[i], m: msg, ackFn: service.AckFunc(aggregatedAck.Derive()),
+		})
+		if ts.After(w.maxTS) {
+			w.maxTS = ts
+		}
+	}
+	w.lastWrite = w.clock()
+	w.mut.Unlock()
+
+	if !derived {
+		// If none of the messages have fit into a window we reject them by
+		// acknowledging the batch.
+		_ = aFn(ctx, nil)
+	}
+	if len(lateBatch) > 0 {
+		_ = lateAckFn(ctx, w.lateOutput.WriteBatch(ctx, lateBatch))
+	}
+
+	w.notify()
+	return nil
+}
+
+// flushNextWindow flushes the earliest open window if the watermark (or an
+// idle timeout) permits it, and returns whether a window was flushed. Must be
+// called whilst holding mut.
+func (w *eventWindowBuffer) flushNextWindow() bool {
+	var start time.Time
+	found := false
+	for _, p := range w.pending {
+		if s, open := w.earliestOpenWindowStart(p.ts); open && (!found || s.Before(start)) {
+			start, found = s, true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	end := start.Add(w.size)
+	idle := w.idleTimeout > 0 && !w.clock().Before(w.lastWrite.Add(w.idleTimeout))
+	if !idle && w.watermark().Before(end.Add(w.allowedLateness)) {
+		return false
+	}
+
+	var keys []string
+	keyFlushes := map[string]*eventWindowFlush{}
+
+	newPending := make([]*eventWindowMessage, 0, len(w.pending))
+	for _, p := range w.pending {
+		if !p.ts.Before(start) && p.ts.Before(end) {
+			f, exists := keyFlushes[p.key]
+			if !exists {
+				f = &eventWindowFlush{}
+				keyFlushes[p.key] = f
+				keys = append(keys, p.key)
+			}
+			tmpMsg := p.m.Copy()
+			tmpMsg.MetaSet("window_start_timestamp", start.Format(time.RFC3339Nano))
+			tmpMsg.MetaSet("window_end_timestamp", end.Format(time.RFC3339Nano))
+			if w.groupBy != nil {
+				tmpMsg.MetaSet("window_key", p.key)
+			}
+			f.b = append(f.b, tmpMsg)
+			f.ackFns = append(f.ackFns, p.ackFn)
+		}
+		if w.latestWindowStart(p.ts).Add(w.size).After(end) {
+			newPending = append(newPending, p)
+		}
+	}
+
+	w.pending = newPending
+	w.lastFlushedEnd = end
+	w.dirty = true
+
+	for _, k := range keys {
+		w.nextFlushID++
+		w.undelivered[w.nextFlushID] = keyFlushes[k]
+		w.readQueue = append(w.readQueue, w.nextFlushID)
+	}
+	return true
+}
+
+// popReady returns the next flushed window batch that is ready to be read.
+func (w *eventWindowBuffer) popReady() (service.MessageBatch, service.AckFunc, bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	for len(w.readQueue) == 0 {
+		if !w.flushNextWindow() {
+			return nil, nil, false
+		}
+	}
+
+	id := w.readQueue[0]
+	w.readQueue = w.readQueue[1:]
+
+	f := w.undelivered[id]
+	outBatch := make(service.MessageBatch, len(f.b))
+	for i, m := range f.b {
+		outBatch[i] = m.Copy()
+	}
+	return outBatch, w.flushAckFn(id), true
+}
+
+func (w *eventWindowBuffer) flushAckFn(id uint64) service.AckFunc {
+	return func(ctx context.Context, err error) error {
+		w.mut.Lock()
+		f, exists := w.undelivered[id]
+		if !exists {
+			w.mut.Unlock()
+			return nil
+		}
+		if err != nil {
+			// Try again with the next read.
+			w.readQueue = append(w.readQueue, id)
+			w.mut.Unlock()
+			w.notify()
+			return nil
+		}
+		delete(w.undelivered, id)
+		w.dirty = true
+		w.mut.Unlock()
+
+		// The delivered window is removed from the checkpoint with the next
+		// periodic snapshot, and until then a restart would deliver it again.
+		for _, aFn := range f.ackFns {
+			_ = aFn(ctx, nil)
+		}
+		return nil
+	}
+}
+
+func (w *eventWindowBuffer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if err := w.ensureRestored(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		if msgBatch, aFn, ok := w.popReady(); ok {
+			return msgBatch, aFn, nil
+		}
+
+		var idleTimer *time.Timer
+		var idleChan <-chan time.Time
+		if w.idleTimeout > 0 {
+			w.mut.Lock()
+			hasPending := len(w.pending) > 0
+			idleAt := w.lastWrite.Add(w.idleTimeout)
+			w.mut.Unlock()
+			if hasPending {
+				idleTimer = time.NewTimer(idleAt.Sub(w.clock()))
+				idleChan = idleTimer.C
+			}
+		}
+		stopTimer := func() {
+			if idleTimer != nil {
+				idleTimer.Stop()
+			}
+		}
+
+		select {
+		case <-idleChan:
+		case <-w.notifyChan:
+			stopTimer()
+		case <-ctx.Done():
+			stopTimer()
+			return nil, nil, ctx.Err()
+		case <-w.endOfInputChan:
+			stopTimer()
+			if w.checkpoint != nil {
+				// Persist all open windows so that they're resumed the next
+				// time the service starts.
+				err := w.snapshot(ctx)
+				if err == nil {
+					return nil, nil, service.ErrEndOfBuffer
+				}
+				w.logger.Errorf("Failed to write window checkpoint: %v", err)
+			}
+
+			// Nack all pending messages so that we re-consume them on the next
+			// start up.
+			w.mut.Lock()
+			for _, p := range w.pending {
+				_ = p.ackFn(ctx, errWindowClosed)
+			}
+			for _, id := range w.readQueue {
+				for _, aFn := range w.undelivered[id].ackFns {
+					_ = aFn(ctx, errWindowClosed)
+				}
+				delete(w.undelivered, id)
+			}
+			w.pending, w.readQueue = nil, nil
+			w.mut.Unlock()
+			return nil, nil, service.ErrEndOfBuffer
+		}
+	}
+}
+
+func (w *eventWindowBuffer) EndOfInput() {
+	w.closeEndOfInputOnce.Do(func() {
+		close(w.endOfInputChan)
+	})
+}
+
+func (w *eventWindowBuffer) Close(ctx context.Context) error {
+	w.shutOnce.Do(func() {
+		close(w.shutSig)
+	})
+	select {
+	case <-w.loopDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := w.snapshot(ctx); err != nil {
+		w.logger.Errorf("Failed to write window checkpoint: %v", err)
+	}
+	if w.lateOutput != nil {
+		return w.lateOutput.Close(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventWindowBufferConfigs(t *testing.T) {
+	tests := []struct {
+		config           string
+		lintErrContains  string
+		buildErrContains string
+	}{
+		{
+			config: `
+event_window:
+  timestamp_mapping: root = this.ts
+  size: 60m
+`,
+		},
+		{
+			config: `
+event_window:
+  timestamp_mapping: root = this.ts
+`,
+			lintErrContains: "field size is required",
+		},
+		{
+			config: `
+event_window:
+  timestamp_mapping: root = this.ts
+  group_by: root = this.key
+  size: 60m
+  slide: 5m
+  max_out_of_orderness: 1m
+  allowed_lateness: 2m
+  idle_timeout: 10m
+  late_output:
+    drop: {}
+`,
+		},
+		{
+			config: `
+event_window:
+  timestamp_mapping: root = this.ts
+  size: 60m
+  slide: 120m
+`,
+			buildErrContains: "invalid window slide",
+		},
+		{
+			config: `
+event_window:
+  timestamp_mapping: root = this.ts
+  size: 60m
+  max_out_of_orderness: nope
+`,
+			buildErrContains: "failed to parse field 'max_out_of_orderness'",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			env := service.NewStreamBuilder()
+			require.NoError(t, env.SetLoggerYAML(`level: OFF`))
+			err := env.AddConsumerFunc(func(context.Context, *service.Message) error {
+				return nil
+			})
+			require.NoError(t, err)
+			_, err = env.AddProducerFunc()
+			require.NoError(t, err)
+
+			err = env.SetBufferYAML(test.config)
+			if test.lintErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.lintErrContains)
+				return
+			}
+			require.NoError(t, err)
+
+			strm, err := env.Build()
+			require.NoError(t, err)
+
+			cancelledCtx, done := context.WithCancel(context.Background())
+			done()
+			err = strm.Run(cancelledCtx)
+			if test.buildErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.buildErrContains)
+				return
+			}
+			require.EqualError(t, err, "context canceled")
+			require.NoError(t, strm.StopWithin(time.Second))
+		})
+	}
+}
+
+type eventWindowTestCache struct {
+	mut    sync.Mutex
+	values map[string][]byte
+	sets   int
+}
+
+func (c *eventWindowTestCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	v, exists := c.values[key]
+	if !exists {
+		return nil, service.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (c *eventWindowTestCache) Set(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.values[key] = value
+	c.sets++
+	return nil
+}
+
+func (c *eventWindowTestCache) Add(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	return c.Set(ctx, key, value, ttl)
+}
+
+func (c *eventWindowTestCache) Delete(ctx context.Context, key string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+func (c *eventWindowTestCache) Close(ctx context.Context) error {
+	return nil
+}
+
+func (c *eventWindowTestCache) checkpoint() *eventWindowCheckpoint {
+	return &eventWindowCheckpoint{
+		key:      "foo",
+		interval: time.Hour,
+		accessCache: func(ctx context.Context, fn func(c service.Cache)) error {
+			fn(c)
+			return nil
+		},
+	}
+}
+
+type eventWindowTestOutput struct {
+	batches []service.MessageBatch
+}
+
+func (o *eventWindowTestOutput) WriteBatch(ctx context.Context, b service.MessageBatch) error {
+	o.batches = append(o.batches, b)
+	return nil
+}
+
+func (o *eventWindowTestOutput) Close(ctx context.Context) error {
+	return nil
+}
+
+func eventWindowMsgs(ts ...string) service.MessageBatch {
+	var b service.MessageBatch
+	for _, t := range ts {
+		b = append(b, service.NewMessage([]byte(`{"ts":`+t+`}`)))
+	}
+	return b
+}
+
+func eventWindowContents(t testing.TB, b service.MessageBatch) []string {
+	t.Helper()
+	var contents []string
+	for _, m := range b {
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		contents = append(contents, string(mBytes))
+	}
+	return contents
+}
+
+func newTestEventWindowBuffer(t testing.TB, size, slide, maxOutOfOrderness time.Duration) *eventWindowBuffer {
+	t.Helper()
+
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	return newEventWindowBuffer(mapping, nil, func() time.Time {
+		return time.Unix(100, 0).UTC()
+	}, size, slide, 0, maxOutOfOrderness, 0, nil)
+}
+
+func TestEventWindowWatermark(t *testing.T) {
+	w := newTestEventWindowBuffer(t, time.Second, 0, time.Millisecond*500)
+	w.start()
+	defer w.Close(context.Background())
+
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "2.2", "1.5"), noopAck))
+
+	smallWaitCtx, done := context.WithTimeout(context.Background(), time.Millisecond*50)
+	_, _, err := w.ReadBatch(smallWaitCtx)
+	done()
+	require.Error(t, err)
+
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("2.6"), noopAck))
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`, `{"ts":1.5}`}, eventWindowContents(t, resBatch))
+
+	v, _ := resBatch[0].MetaGet("window_start_timestamp")
+	assert.Equal(t, "1970-01-01T00:00:01Z", v)
+	v, _ = resBatch[0].MetaGet("window_end_timestamp")
+	assert.Equal(t, "1970-01-01T00:00:02Z", v)
+
+	require.Len(t, w.pending, 2)
+	assert.Equal(t, "1970-01-01T00:00:02Z", w.lastFlushedEnd.Format(time.RFC3339Nano))
+}
+
+func TestEventWindowSliding(t *testing.T) {
+	w := newTestEventWindowBuffer(t, time.Second*2, time.Second, 0)
+	w.start()
+	defer w.Close(context.Background())
+
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.5", "2.5", "4"), noopAck))
+
+	for _, exp := range [][]string{
+		{`{"ts":1.5}`},
+		{`{"ts":1.5}`, `{"ts":2.5}`},
+		{`{"ts":2.5}`},
+	} {
+		resBatch, _, err := w.ReadBatch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, exp, eventWindowContents(t, resBatch))
+	}
+
+	require.Len(t, w.pending, 1)
+	assert.Equal(t, "1970-01-01T00:00:04Z", w.lastFlushedEnd.Format(time.RFC3339Nano))
+}
+
+func TestEventWindowLateOutput(t *testing.T) {
+	w := newTestEventWindowBuffer(t, time.Second, 0, 0)
+	lateOut := &eventWindowTestOutput{}
+	w.lateOutput = lateOut
+	w.start()
+	defer w.Close(context.Background())
+
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "2.2"), noopAck))
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`}, eventWindowContents(t, resBatch))
+
+	var ackCalls int
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.9", "0.5", "2.9"), func(ctx context.Context, err error) error {
+		ackCalls++
+		return nil
+	}))
+
+	require.Len(t, lateOut.batches, 1)
+	assert.Equal(t, []string{`{"ts":1.9}`, `{"ts":0.5}`}, eventWindowContents(t, lateOut.batches[0]))
+	assert.Equal(t, 0, ackCalls)
+	require.Len(t, w.pending, 2)
+}
+
+func TestEventWindowIdleTimeout(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(100, 0).UTC()
+	var clockMut sync.Mutex
+
+	w := newEventWindowBuffer(mapping, nil, func() time.Time {
+		clockMut.Lock()
+		defer clockMut.Unlock()
+		return currentTS
+	}, time.Second, 0, 0, 0, 0, nil)
+	w.idleTimeout = time.Minute
+	w.start()
+	defer w.Close(context.Background())
+
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "2.2"), noopAck))
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`}, eventWindowContents(t, resBatch))
+
+	clockMut.Lock()
+	currentTS = time.Unix(160, 0).UTC()
+	clockMut.Unlock()
+
+	resBatch, _, err = w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":2.2}`}, eventWindowContents(t, resBatch))
+}
+
+func TestEventWindowRetryDelivery(t *testing.T) {
+	w := newTestEventWindowBuffer(t, time.Second, 0, 0)
+	w.start()
+	defer w.Close(context.Background())
+
+	var ackCalls int
+	var ackErr error
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "1.2", "2.2"), func(ctx context.Context, err error) error {
+		ackCalls++
+		ackErr = err
+		return nil
+	}))
+
+	resBatch, aFn, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`, `{"ts":1.2}`}, eventWindowContents(t, resBatch))
+	require.NoError(t, aFn(context.Background(), errors.New("nope")))
+
+	resBatch, aFn, err = w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`, `{"ts":1.2}`}, eventWindowContents(t, resBatch))
+	require.NoError(t, aFn(context.Background(), nil))
+
+	assert.Equal(t, 0, ackCalls)
+	assert.Len(t, w.undelivered, 0)
+
+	w.EndOfInput()
+	_, _, err = w.ReadBatch(context.Background())
+	assert.True(t, errors.Is(err, service.ErrEndOfBuffer))
+	assert.Equal(t, 1, ackCalls)
+	assert.Equal(t, errWindowClosed, ackErr)
+}
+
+func TestEventWindowCheckpointRestore(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+
+	w := newTestEventWindowBuffer(t, time.Second, 0, 0)
+	w.checkpoint = cache.checkpoint()
+	w.start()
+
+	var ackCalls int
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "1.2", "2.2", "2.3"), func(ctx context.Context, err error) error {
+		ackCalls++
+		assert.NoError(t, err)
+		return nil
+	}))
+
+	// Read the first window but leave it undelivered.
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`, `{"ts":1.2}`}, eventWindowContents(t, resBatch))
+
+	w.EndOfInput()
+	_, _, err = w.ReadBatch(context.Background())
+	assert.True(t, errors.Is(err, service.ErrEndOfBuffer))
+	require.NoError(t, w.Close(context.Background()))
+
+	// All messages are persisted and therefore acknowledged.
+	assert.Equal(t, 1, ackCalls)
+	require.Contains(t, cache.values, "foo")
+
+	w = newTestEventWindowBuffer(t, time.Second, 0, 0)
+	w.checkpoint = cache.checkpoint()
+	lateOut := &eventWindowTestOutput{}
+	w.lateOutput = lateOut
+	w.start()
+	defer w.Close(context.Background())
+
+	// A replay of data from the flushed window is late.
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.5", "3.1"), noopAck))
+	require.Len(t, lateOut.batches, 1)
+	assert.Equal(t, []string{`{"ts":1.5}`}, eventWindowContents(t, lateOut.batches[0]))
+
+	for _, exp := range [][]string{
+		{`{"ts":1.1}`, `{"ts":1.2}`},
+		{`{"ts":2.2}`, `{"ts":2.3}`},
+	} {
+		resBatch, aFn, err := w.ReadBatch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, exp, eventWindowContents(t, resBatch))
+		require.NoError(t, aFn(context.Background(), nil))
+	}
+
+	assert.Len(t, w.undelivered, 0)
+	require.Len(t, w.pending, 1)
+	assert.Equal(t, "1970-01-01T00:00:03Z", w.lastFlushedEnd.Format(time.RFC3339Nano))
+}
+
+func TestEventWindowCheckpointOnlyOnChange(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+
+	w := newTestEventWindowBuffer(t, time.Second, 0, 0)
+	w.checkpoint = cache.checkpoint()
+	w.start()
+	defer w.Close(context.Background())
+
+	var ackCalls int
+	require.NoError(t, w.WriteBatch(context.Background(), eventWindowMsgs("1.1", "1.2", "2.2"), func(ctx context.Context, err error) error {
+		ackCalls++
+		assert.NoError(t, err)
+		return nil
+	}))
+
+	require.NoError(t, w.snapshot(context.Background()))
+	assert.Equal(t, 1, cache.sets)
+	assert.Equal(t, 1, ackCalls)
+
+	// Nothing has changed and so the snapshot is skipped.
+	require.NoError(t, w.snapshot(context.Background()))
+	assert.Equal(t, 1, cache.sets)
+
+	// Delivering a window does not write a snapshot by itself.
+	resBatch, aFn, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"ts":1.1}`, `{"ts":1.2}`}, eventWindowContents(t, resBatch))
+	require.NoError(t, aFn(context.Background(), nil))
+	assert.Equal(t, 1, cache.sets)
+
+	require.NoError(t, w.snapshot(context.Background()))
+	assert.Equal(t, 2, cache.sets)
+
+	var snap eventWindowSnapshot
+	require.NoError(t, json.Unmarshal(cache.values["foo"], &snap))
+	assert.Len(t, snap.Pending, 1)
+	assert.Len(t, snap.Undelivered, 0)
+}
@@ -0,0 +1,632 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+const (
+	joinSideLeft  = "left"
+	joinSideRight = "right"
+)
+
+func joinBufferConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Windowing").
+		Summary("Joins two streams of messages by matching records of each side on a key within a window of time.").
+		Description(`
+Messages consumed by the input (usually a `+"[`broker`](/docs/components/inputs/broker)"+` combining two inputs) are labelled as belonging to either the left or right side of the join with the `+"[`side_mapping`](#side_mapping)"+`, and a key is extracted from each with the `+"[`key_mapping`](#key_mapping)"+`.
+
+When a record arrives it is matched against all pending records of the other side that share its key and have a timestamp within the `+"[`window`](#window)"+` duration of its own. For each match the `+"[`result_mapping`](#result_mapping)"+` is executed against a document of the form `+"`{\"left\":{...},\"right\":{...}}`"+` and the result is emitted. Records remain pending, and can therefore match multiple records of the other side, until their timestamp plus the window has passed according to the system clock.
+
+Pending records are stored within a `+"[cache resource](/docs/components/caches/about)"+`, and since the expiry of records is tracked in memory entries are written with a TTL of twice the window in order to prevent orphaned records from accumulating after a restart.
+
+## Join Types
+
+The `+"[`type`](#type)"+` of the join determines what happens to records that expire without having matched any records of the other side:
+
+- `+"`inner`"+`: Unmatched records are dropped.
+- `+"`left`"+`: Unmatched records of the left side are emitted, with the right side of the result mapping document set to `+"`null`"+`.
+- `+"`right`"+`: Unmatched records of the right side are emitted, with the left side of the result mapping document set to `+"`null`"+`.
+- `+"`outer`"+`: Unmatched records of both sides are emitted.
+
+Emitted messages have the metadata field `+"`join_key`"+` added to them, and unmatched records also have the field `+"`join_unmatched`"+` set to the side of the record.
+
+## Delivery Guarantees
+
+A record is acknowledged once it has been written to the cache and all results produced by its arrival have been delivered. The records of a batch are only written to the cache once the whole batch has been joined, and when a write fails the keys already written are restored, so that a rejected batch isn't joined twice when it is redelivered. Unmatched records emitted on expiry are removed from the cache before they are delivered, and in the event of a delivery failure they are retried until successful. Expiries are tracked in memory and therefore unmatched records that are pending during a restart are not emitted.
+`).
+		Field(service.NewBloblangField("side_mapping").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) that determines which side of the join a message belongs to, resulting in either `left` or `right`.").
+			Example(`root = if meta("kafka_topic") == "orders" { "left" } else { "right" }`).
+			Example(`root = this.type`)).
+		Field(service.NewBloblangField("key_mapping").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) that provides the key used for matching records of each side.").
+			Example(`root = this.order_id`)).
+		Field(service.NewStringEnumField("type", "inner", "left", "right", "outer").
+			Description("The type of join to perform, which determines whether unmatched records are emitted once they expire.").
+			Default("inner")).
+		Field(service.NewStringField("window").
+			Description("A duration string describing the maximum difference in timestamps of records that can be matched, and the length of time that records remain pending.").
+			Example("30s").Example("1h")).
+		Field(service.NewBloblangField("timestamp_mapping").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) applied to each message during ingestion that provides its timestamp. By default the processing time is used.").
+			Default("root = now()").
+			Example("root = this.created_at").
+			Advanced()).
+		Field(service.NewStringField("cache").
+			Description("A [cache resource](/docs/components/caches/about) to store pending records within.")).
+		Field(service.NewBloblangField("result_mapping").
+			Description("A [Bloblang mapping](/docs/guides/bloblang/about) executed for each match (or unmatched record of an outer join) against a document containing the fields `left` and `right`, where the result is emitted. The metadata of the result begins as the metadata of both records, with the left record taking precedence.").
+			Default(`root = this.left.or({}).merge(this.right.or({}))`).
+			Example(`root = this.left
+root.customer = this.right`)).
+		Example("Enriching Orders With Payments", `Given a stream of orders and a stream of payments that may arrive up to ten minutes apart, we can emit each order with its payment attached, along with any orders that were not paid for:`,
+			`
+input:
+  broker:
+    inputs:
+      - kafka:
+          addresses: [ localhost:9092 ]
+          topics: [ orders, payments ]
+          consumer_group: benthos_join
+
+buffer:
+  join:
+    side_mapping: 'root = if meta("kafka_topic") == "orders" { "left" } else { "right" }'
+    key_mapping: root = this.order_id
+    type: left
+    window: 10m
+    cache: pending
+    result_mapping: |
+      root = this.left
+      root.payment = this.right
+
+cache_resources:
+  - label: pending
+    redis:
+      url: tcp://localhost:6379
+`,
+		)
+}
+
+func init() {
+	err := service.RegisterBatchBuffer(
+		"join", joinBufferConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchBuffer, error) {
+			sideMapping, err := conf.FieldBloblang("side_mapping")
+			if err != nil {
+				return nil, err
+			}
+			keyMapping, err := conf.FieldBloblang("key_mapping")
+			if err != nil {
+				return nil, err
+			}
+			tsMapping, err := conf.FieldBloblang("timestamp_mapping")
+			if err != nil {
+				return nil, err
+			}
+			resultMapping, err := conf.FieldBloblang("result_mapping")
+			if err != nil {
+				return nil, err
+			}
+			joinType, err := conf.FieldString("type")
+			if err != nil {
+				return nil, err
+			}
+			window, err := getDuration(conf, true, "window")
+			if err != nil {
+				return nil, err
+			}
+			if window <= 0 {
+				return nil, fmt.Errorf("invalid window '%v' must be greater than zero", window)
+			}
+			cacheName, err := conf.FieldString("cache")
+			if err != nil {
+				return nil, err
+			}
+			return newJoinBuffer(sideMapping, keyMapping, tsMapping, resultMapping, joinType, window, func() time.Time {
+				return time.Now().UTC()
+			}, func(ctx context.Context, fn func(c service.Cache)) error {
+				return mgr.AccessCache(ctx, cacheName, fn)
+			}, mgr.Logger()), nil
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type joinRecord struct {
+	Side      string            `json:"side"`
+	Timestamp time.Time         `json:"timestamp"`
+	Content   []byte            `json:"content"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Matched   bool              `json:"matched,omitempty"`
+}
+
+type joinExpiry struct {
+	at  time.Time
+	key string
+}
+
+// joinAckTracker acknowledges a written batch once the batch has been stored
+// and all results produced by it have been delivered.
+type joinAckTracker struct {
+	mut       sync.Mutex
+	remaining int
+	finished  bool
+	err       error
+	aFn       service.AckFunc
+}
+
+func (t *joinAckTracker) add() {
+	t.mut.Lock()
+	t.remaining++
+	t.mut.Unlock()
+}
+
+func (t *joinAckTracker) resolve(ctx context.Context, err error) {
+	t.mut.Lock()
+	t.remaining--
+	if err != nil {
+		t.err = err
+	}
+	done := t.finished && t.remaining == 0
+	t.mut.Unlock()
+	if done {
+		_ = t.aFn(ctx, t.err)
+	}
+}
+
+func (t *joinAckTracker) finish(ctx context.Context) {
+	t.mut.Lock()
+	t.finished = true
+	done := t.remaining == 0
+	t.mut.Unlock()
+	if done {
+		_ = t.aFn(ctx, t.err)
+	}
+}
+
+type joinResult struct {
+	m       *service.Message
+	tracker *joinAckTracker
+}
+
+type joinBuffer struct {
+	logger *service.Logger
+
+	sideMapping, keyMapping, tsMapping, resultMapping *bloblang.Executor
+
+	joinType    string
+	window      time.Duration
+	clock       utcNowProvider
+	accessCache func(ctx context.Context, fn func(c service.Cache)) error
+
+	// Guards read-modify-write operations on cache entries as well as the
+	// fields below.
+	mut      sync.Mutex
+	expiries []joinExpiry
+	ready    []*joinResult
+
+	// Signalled whenever results are added so that the reader can pick them
+	// up.
+	notifyChan chan struct{}
+
+	endOfInputChan      chan struct{}
+	closeEndOfInputOnce sync.Once
+}
+
+func newJoinBuffer(
+	sideMapping, keyMapping, tsMapping, resultMapping *bloblang.Executor,
+	joinType string,
+	window time.Duration,
+	clock utcNowProvider,
+	accessCache func(ctx context.Context, fn func(c service.Cache)) error,
+	logger *service.Logger,
+) *joinBuffer {
+	return &joinBuffer{
+		logger:         logger,
+		sideMapping:    sideMapping,
+		keyMapping:     keyMapping,
+		tsMapping:      tsMapping,
+		resultMapping:  resultMapping,
+		joinType:       joinType,
+		window:         window,
+		clock:          clock,
+		accessCache:    accessCache,
+		notifyChan:     make(chan struct{}, 1),
+		endOfInputChan: make(chan struct{}),
+	}
+}
+
+func (j *joinBuffer) notify() {
+	select {
+	case j.notifyChan <- struct{}{}:
+	default:
+	}
+}
+
+// emitsUnmatched returns whether unmatched records of a side are emitted on
+// expiry.
+func (j *joinBuffer) emitsUnmatched(side string) bool {
+	switch j.joinType {
+	case "outer":
+		return true
+	case "left":
+		return side == joinSideLeft
+	case "right":
+		return side == joinSideRight
+	}
+	return false
+}
+
+func (j *joinBuffer) getRecords(ctx context.Context, key string) (records []joinRecord, err error) {
+	var data []byte
+	var getErr error
+	if err = j.accessCache(ctx, func(c service.Cache) {
+		data, getErr = c.Get(ctx, key)
+	}); err != nil {
+		return
+	}
+	if getErr != nil {
+		if errors.Is(getErr, service.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, getErr
+	}
+	err = json.Unmarshal(data, &records)
+	return
+}
+
+func (j *joinBuffer) setRecords(ctx context.Context, key string, records []joinRecord) error {
+	var cacheErr error
+	if len(records) == 0 {
+		if err := j.accessCache(ctx, func(c service.Cache) {
+			cacheErr = c.Delete(ctx, key)
+		}); err != nil {
+			return err
+		}
+		if errors.Is(cacheErr, service.ErrKeyNotFound) {
+			cacheErr = nil
+		}
+		return cacheErr
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	ttl := j.window * 2
+	if err := j.accessCache(ctx, func(c service.Cache) {
+		cacheErr = c.Set(ctx, key, data, &ttl)
+	}); err != nil {
+		return err
+	}
+	return cacheErr
+}
+
+func (j *joinBuffer) addExpiry(at time.Time, key string) {
+	i := sort.Search(len(j.expiries), func(i int) bool {
+		return j.expiries[i].at.After(at)
+	})
+	j.expiries = append(j.expiries, joinExpiry{})
+	copy(j.expiries[i+1:], j.expiries[i:])
+	j.expiries[i] = joinExpiry{at: at, key: key}
+}
+
+func joinRecordValue(r *joinRecord) interface{} {
+	if r == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(r.Content, &v); err != nil {
+		return string(r.Content)
+	}
+	return v
+}
+
+// result executes the result mapping for a pair of records, where either may
+// be nil. Returns nil if the mapping deleted the result.
+func (j *joinBuffer) result(key string, left, right *joinRecord) (*service.Message, error) {
+	msg := service.NewMessage(nil)
+	for _, r := range []*joinRecord{right, left} {
+		if r == nil {
+			continue
+		}
+		for k, v := range r.Metadata {
+			msg.MetaSet(k, v)
+		}
+	}
+	msg.SetStructured(map[string]interface{}{
+		joinSideLeft:  joinRecordValue(left),
+		joinSideRight: joinRecordValue(right),
+	})
+
+	res, err := msg.BloblangQuery(j.resultMapping)
+	if err != nil {
+		return nil, fmt.Errorf("result mapping failed: %w", err)
+	}
+	if res != nil {
+		res.MetaSet("join_key", key)
+	}
+	return res, nil
+}
+
+func (j *joinBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageBatch, aFn service.AckFunc) error {
+	type incoming struct {
+		key    string
+		record joinRecord
+	}
+
+	// Extract all mapped values before modifying any state so that a failed
+	// mapping rejects the batch as a whole.
+	records := make([]incoming, len(msgBatch))
+	for i, msg := range msgBatch {
+		sideMsg, err := msgBatch.BloblangQuery(i, j.sideMapping)
+		if err != nil {
+			j.logger.Errorf("Side mapping failed for message: %v", err)
+			return fmt.Errorf("side mapping failed: %w", err)
+		}
+		sideBytes, _ := sideMsg.AsBytes()
+		if side := string(sideBytes); side != joinSideLeft && side != joinSideRight {
+			j.logger.Errorf("Side mapping resulted in an unexpected value: %s", sideBytes)
+			return fmt.Errorf("side mapping resulted in '%s', expected either 'left' or 'right'", sideBytes)
+		}
+		if records[i].key, err = getWindowKey(j.logger, j.keyMapping, i, msgBatch); err != nil {
+			return err
+		}
+		ts, err := getWindowTimestamp(j.logger, j.tsMapping, i, msgBatch)
+		if err != nil {
+			return err
+		}
+		content, err := msg.AsBytes()
+		if err != nil {
+			return err
+		}
+		records[i].record = joinRecord{
+			Side:      string(sideBytes),
+			Timestamp: ts,
+			Content:   content,
+		}
+		_ = msg.MetaWalk(func(k, v string) error {
+			if records[i].record.Metadata == nil {
+				records[i].record.Metadata = map[string]string{}
+			}
+			records[i].record.Metadata[k] = v
+			return nil
+		})
+	}
+
+	tracker := &joinAckTracker{aFn: aFn}
+	var results []*joinResult
+
+	j.mut.Lock()
+
+	// The pending records of each key are staged in memory and only written
+	// back once the whole batch has been joined, so that a failure part way
+	// through doesn't leave some of the batch stored, which would otherwise
+	// be joined again when the batch is redelivered.
+	var keys []string
+	staged := map[string][]joinRecord{}
+	original := map[string][]joinRecord{}
+	for _, in := range records {
+		pending, exists := staged[in.key]
+		if !exists {
+			var err error
+			if pending, err = j.getRecords(ctx, in.key); err != nil {
+				j.mut.Unlock()
+				return fmt.Errorf("failed to read pending records: %w", err)
+			}
+			keys = append(keys, in.key)
+			original[in.key] = append([]joinRecord(nil), pending...)
+		}
+
+		newRecord := in.record
+		for i := range pending {
+			other := &pending[i]
+			if other.Side == newRecord.Side {
+				continue
+			}
+			diff := newRecord.Timestamp.Sub(other.Timestamp)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > j.window {
+				continue
+			}
+
+			left, right := other, &newRecord
+			if newRecord.Side == joinSideLeft {
+				left, right = &newRecord, other
+			}
+			res, err := j.result(in.key, left, right)
+			if err != nil {
+				j.logger.Errorf("Failed to join records: %v", err)
+				continue
+			}
+			other.Matched, newRecord.Matched = true, true
+			if res != nil {
+				tracker.add()
+				results = append(results, &joinResult{m: res, tracker: tracker})
+			}
+		}
+		staged[in.key] = append(pending, newRecord)
+	}
+
+	for i, key := range keys {
+		if err := j.setRecords(ctx, key, staged[key]); err != nil {
+			// Restore the keys that were already written so that the batch is
+			// rejected as a whole.
+			for _, written := range keys[:i] {
+				if rErr := j.setRecords(ctx, written, original[written]); rErr != nil {
+					j.logger.Errorf("Failed to restore pending records of key %v: %v", written, rErr)
+				}
+			}
+			j.mut.Unlock()
+			return fmt.Errorf("failed to write pending records: %w", err)
+		}
+	}
+	for _, in := range records {
+		j.addExpiry(in.record.Timestamp.Add(j.window), in.key)
+	}
+	j.ready = append(j.ready, results...)
+	j.mut.Unlock()
+
+	tracker.finish(ctx)
+	if len(results) > 0 {
+		j.notify()
+	}
+	return nil
+}
+
+// expire removes all records that have expired from the cache, and queues
+// unmatched records as results where the join type demands it. Returns the
+// time at which the next record expires, or a zero time if there are none.
+func (j *joinBuffer) expire(ctx context.Context) (time.Time, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	now := j.clock()
+	for len(j.expiries) > 0 && !j.expiries[0].at.After(now) {
+		key := j.expiries[0].key
+
+		pending, err := j.getRecords(ctx, key)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read pending records: %w", err)
+		}
+
+		remaining := make([]joinRecord, 0, len(pending))
+		var expired []joinRecord
+		for _, r := range pending {
+			if r.Timestamp.Add(j.window).After(now) {
+				remaining = append(remaining, r)
+			} else {
+				expired = append(expired, r)
+			}
+		}
+		if len(expired) > 0 {
+			if err := j.setRecords(ctx, key, remaining); err != nil {
+				return time.Time{}, fmt.Errorf("failed to write pending records: %w", err)
+			}
+		}
+		j.expiries = j.expiries[1:]
+
+		for i, r := range expired {
+			if r.Matched || !j.emitsUnmatched(r.Side) {
+				continue
+			}
+			left, right := &expired[i], (*joinRecord)(nil)
+			if r.Side == joinSideRight {
+				left, right = nil, &expired[i]
+			}
+			res, err := j.result(key, left, right)
+			if err != nil {
+				j.logger.Errorf("Failed to map unmatched record: %v", err)
+				continue
+			}
+			if res != nil {
+				res.MetaSet("join_unmatched", r.Side)
+				j.ready = append(j.ready, &joinResult{m: res})
+			}
+		}
+	}
+
+	if len(j.expiries) > 0 {
+		return j.expiries[0].at, nil
+	}
+	return time.Time{}, nil
+}
+
+func (j *joinBuffer) popReady() []*joinResult {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	results := j.ready
+	j.ready = nil
+	return results
+}
+
+func (j *joinBuffer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	for {
+		nextExpiry, err := j.expire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if results := j.popReady(); len(results) > 0 {
+			outBatch := make(service.MessageBatch, len(results))
+			for i, r := range results {
+				outBatch[i] = r.m.Copy()
+			}
+			return outBatch, func(ctx context.Context, err error) error {
+				var retry []*joinResult
+				for _, r := range results {
+					if r.tracker != nil {
+						r.tracker.resolve(ctx, err)
+					} else if err != nil {
+						// Unmatched records are no longer in the cache and
+						// therefore must be retried.
+						retry = append(retry, r)
+					}
+				}
+				if len(retry) > 0 {
+					j.mut.Lock()
+					j.ready = append(j.ready, retry...)
+					j.mut.Unlock()
+					j.notify()
+				}
+				return nil
+			}, nil
+		}
+
+		var timer *time.Timer
+		var nextExpiryChan <-chan time.Time
+		if !nextExpiry.IsZero() {
+			timer = time.NewTimer(nextExpiry.Sub(j.clock()))
+			nextExpiryChan = timer.C
+		}
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		select {
+		case <-nextExpiryChan:
+		case <-j.notifyChan:
+			stopTimer()
+		case <-ctx.Done():
+			stopTimer()
+			return nil, nil, ctx.Err()
+		case <-j.endOfInputChan:
+			stopTimer()
+			// Pending records remain in the cache and can be matched the next
+			// time the service starts.
+			return nil, nil, service.ErrEndOfBuffer
+		}
+	}
+}
+
+func (j *joinBuffer) EndOfInput() {
+	j.closeEndOfInputOnce.Do(func() {
+		close(j.endOfInputChan)
+	})
+}
+
+func (j *joinBuffer) Close(ctx context.Context) error {
+	return nil
+}
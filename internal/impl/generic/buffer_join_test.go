@@ -0,0 +1,340 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinBufferConfigs(t *testing.T) {
+	tests := []struct {
+		config           string
+		lintErrContains  string
+		buildErrContains string
+	}{
+		{
+			config: `
+join:
+  side_mapping: root = this.side
+  key_mapping: root = this.id
+  window: 1m
+  cache: foo
+`,
+		},
+		{
+			config: `
+join:
+  side_mapping: root = this.side
+  key_mapping: root = this.id
+  cache: foo
+`,
+			lintErrContains: "field window is required",
+		},
+		{
+			config: `
+join:
+  side_mapping: root = this.side
+  key_mapping: root = this.id
+  type: sideways
+  window: 1m
+  cache: foo
+`,
+			lintErrContains: "sideways",
+		},
+		{
+			config: `
+join:
+  side_mapping: root = this.side
+  key_mapping: root = this.id
+  window: nope
+  cache: foo
+`,
+			buildErrContains: "failed to parse field 'window'",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			env := service.NewStreamBuilder()
+			require.NoError(t, env.SetLoggerYAML(`level: OFF`))
+			require.NoError(t, env.AddCacheYAML(`
+label: foo
+memory: {}
+`))
+			err := env.AddConsumerFunc(func(context.Context, *service.Message) error {
+				return nil
+			})
+			require.NoError(t, err)
+			_, err = env.AddProducerFunc()
+			require.NoError(t, err)
+
+			err = env.SetBufferYAML(test.config)
+			if test.lintErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.lintErrContains)
+				return
+			}
+			require.NoError(t, err)
+
+			strm, err := env.Build()
+			require.NoError(t, err)
+
+			cancelledCtx, done := context.WithCancel(context.Background())
+			done()
+			err = strm.Run(cancelledCtx)
+			if test.buildErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.buildErrContains)
+				return
+			}
+			require.EqualError(t, err, "context canceled")
+			require.NoError(t, strm.StopWithin(time.Second))
+		})
+	}
+}
+
+type joinTestClock struct {
+	mut sync.Mutex
+	now time.Time
+}
+
+func (c *joinTestClock) get() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+func (c *joinTestClock) set(t time.Time) {
+	c.mut.Lock()
+	c.now = t
+	c.mut.Unlock()
+}
+
+func newTestJoinBuffer(t testing.TB, joinType string, clock *joinTestClock) (*joinBuffer, *eventWindowTestCache) {
+	t.Helper()
+
+	sideMapping, err := bloblang.Parse(`root = this.side`)
+	require.NoError(t, err)
+	keyMapping, err := bloblang.Parse(`root = this.id`)
+	require.NoError(t, err)
+	tsMapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+	resultMapping, err := bloblang.Parse(`root.id = this.left.id | this.right.id
+root.l = this.left.v
+root.r = this.right.v`)
+	require.NoError(t, err)
+
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+	return newJoinBuffer(sideMapping, keyMapping, tsMapping, resultMapping, joinType, time.Second*10, clock.get, func(ctx context.Context, fn func(c service.Cache)) error {
+		fn(cache)
+		return nil
+	}, nil), cache
+}
+
+func joinTestMsgs(docs ...string) service.MessageBatch {
+	var b service.MessageBatch
+	for _, d := range docs {
+		b = append(b, service.NewMessage([]byte(d)))
+	}
+	return b
+}
+
+func TestJoinBufferInner(t *testing.T) {
+	clock := &joinTestClock{now: time.Unix(100, 0).UTC()}
+	j, cache := newTestJoinBuffer(t, "inner", clock)
+
+	var ackCalls int
+	var ackErr error
+	countingAck := func(ctx context.Context, err error) error {
+		ackCalls++
+		ackErr = err
+		return nil
+	}
+
+	require.NoError(t, j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"left","id":"a","v":1,"ts":100}`,
+		`{"side":"left","id":"b","v":2,"ts":100}`,
+	), countingAck))
+	assert.Equal(t, 1, ackCalls)
+	assert.Len(t, cache.values, 2)
+
+	require.NoError(t, j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"right","id":"a","v":3,"ts":105}`,
+		`{"side":"right","id":"b","v":4,"ts":120}`,
+	), countingAck))
+	assert.Equal(t, 1, ackCalls)
+
+	resBatch, aFn, err := j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 1)
+	mBytes, err := resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","l":1,"r":3}`, string(mBytes))
+	v, _ := resBatch[0].MetaGet("join_key")
+	assert.Equal(t, "a", v)
+
+	require.NoError(t, aFn(context.Background(), errors.New("nope")))
+	assert.Equal(t, 2, ackCalls)
+	assert.EqualError(t, ackErr, "nope")
+
+	// Everything expires without emitting anything.
+	clock.set(time.Unix(131, 0).UTC())
+
+	smallWaitCtx, done := context.WithTimeout(context.Background(), time.Millisecond*50)
+	_, _, err = j.ReadBatch(smallWaitCtx)
+	done()
+	require.Error(t, err)
+
+	assert.Len(t, cache.values, 0)
+	assert.Len(t, j.expiries, 0)
+}
+
+func TestJoinBufferOuter(t *testing.T) {
+	clock := &joinTestClock{now: time.Unix(100, 0).UTC()}
+	j, _ := newTestJoinBuffer(t, "outer", clock)
+
+	require.NoError(t, j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"left","id":"a","v":1,"ts":100}`,
+		`{"side":"right","id":"b","v":2,"ts":101}`,
+		`{"side":"left","id":"c","v":3,"ts":102}`,
+		`{"side":"right","id":"c","v":4,"ts":103}`,
+	), noopAck))
+
+	resBatch, aFn, err := j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 1)
+	mBytes, err := resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"c","l":3,"r":4}`, string(mBytes))
+	require.NoError(t, aFn(context.Background(), nil))
+
+	clock.set(time.Unix(111, 0).UTC())
+
+	resBatch, aFn, err = j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 2)
+
+	mBytes, err = resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","l":1,"r":null}`, string(mBytes))
+	v, _ := resBatch[0].MetaGet("join_unmatched")
+	assert.Equal(t, "left", v)
+
+	mBytes, err = resBatch[1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"b","l":null,"r":2}`, string(mBytes))
+	v, _ = resBatch[1].MetaGet("join_unmatched")
+	assert.Equal(t, "right", v)
+
+	// Failed deliveries of unmatched records are retried.
+	require.NoError(t, aFn(context.Background(), errors.New("nope")))
+
+	resBatch, aFn, err = j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 2)
+	require.NoError(t, aFn(context.Background(), nil))
+
+	j.EndOfInput()
+	_, _, err = j.ReadBatch(context.Background())
+	assert.True(t, errors.Is(err, service.ErrEndOfBuffer))
+}
+
+func TestJoinBufferLeftManyToMany(t *testing.T) {
+	clock := &joinTestClock{now: time.Unix(100, 0).UTC()}
+	j, _ := newTestJoinBuffer(t, "left", clock)
+
+	require.NoError(t, j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"left","id":"a","v":1,"ts":100}`,
+		`{"side":"left","id":"a","v":2,"ts":101}`,
+		`{"side":"right","id":"a","v":3,"ts":102}`,
+		`{"side":"right","id":"b","v":4,"ts":102}`,
+	), noopAck))
+
+	resBatch, _, err := j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 2)
+
+	mBytes, err := resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","l":1,"r":3}`, string(mBytes))
+	mBytes, err = resBatch[1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","l":2,"r":3}`, string(mBytes))
+
+	// Matched left records and unmatched right records are not emitted.
+	clock.set(time.Unix(120, 0).UTC())
+
+	smallWaitCtx, done := context.WithTimeout(context.Background(), time.Millisecond*50)
+	_, _, err = j.ReadBatch(smallWaitCtx)
+	done()
+	require.Error(t, err)
+}
+
+func TestJoinBufferBadSide(t *testing.T) {
+	clock := &joinTestClock{now: time.Unix(100, 0).UTC()}
+	j, cache := newTestJoinBuffer(t, "inner", clock)
+
+	err := j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"left","id":"a","v":1,"ts":100}`,
+		`{"side":"up","id":"a","v":2,"ts":100}`,
+	), noopAck)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected either 'left' or 'right'")
+	assert.Len(t, cache.values, 0)
+}
+
+type joinFailingCache struct {
+	*eventWindowTestCache
+	failKey string
+}
+
+func (c *joinFailingCache) Set(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	if key == c.failKey {
+		return errors.New("nope")
+	}
+	return c.eventWindowTestCache.Set(ctx, key, value, ttl)
+}
+
+func TestJoinBufferWriteFailureRedelivered(t *testing.T) {
+	clock := &joinTestClock{now: time.Unix(100, 0).UTC()}
+	j, cache := newTestJoinBuffer(t, "inner", clock)
+
+	require.NoError(t, j.WriteBatch(context.Background(), joinTestMsgs(
+		`{"side":"left","id":"a","v":1,"ts":100}`,
+	), noopAck))
+
+	failing := &joinFailingCache{eventWindowTestCache: cache, failKey: "b"}
+	j.accessCache = func(ctx context.Context, fn func(c service.Cache)) error {
+		fn(failing)
+		return nil
+	}
+
+	batch := joinTestMsgs(
+		`{"side":"right","id":"a","v":2,"ts":101}`,
+		`{"side":"right","id":"b","v":3,"ts":101}`,
+	)
+	require.Error(t, j.WriteBatch(context.Background(), batch, noopAck))
+	assert.Len(t, j.expiries, 1)
+
+	failing.failKey = ""
+	require.NoError(t, j.WriteBatch(context.Background(), batch, noopAck))
+
+	resBatch, _, err := j.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 1)
+	mBytes, err := resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","l":1,"r":2}`, string(mBytes))
+
+	records, err := j.getRecords(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
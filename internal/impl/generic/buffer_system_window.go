@@ -18,7 +18,7 @@ func tumblingWindowBufferConfig() *service.ConfigSpec {
 		// Stable(). TODO
 		Version("3.53.0").
 		Categories("Windowing").
-		Summary("Chops a stream of messages into tumbling or sliding windows of fixed temporal size, following the system clock.").
+		Summary("Chops a stream of messages into tumbling, sliding or session windows, following the system clock.").
 		Description(`
 A window is a grouping of messages that fit within a discrete measure of time following the system clock. Messages are allocated to a window either by the processing time (the time at which they're ingested) or by the event time, and this is controlled via the `+"[`timestamp_mapping` field](#timestamp_mapping)"+`.
 
@@ -26,12 +26,24 @@ In tumbling mode (default) the beginning of a window immediately follows the end
 
 A window is flushed only once the system clock surpasses its scheduled end. If an `+"[`allowed_lateness`](#allowed_lateness)"+` is specified then the window will not be flushed until the scheduled end plus that length of time.
 
-When a message is added to a window it has a metadata field `+"`window_end_timestamp`"+` added to it containing the timestamp of the end of the window as an RFC3339 string.
+When a window is flushed each message has the metadata fields `+"`window_start_timestamp`"+` and `+"`window_end_timestamp`"+` added to it containing the timestamps of the beginning and end of the window as RFC3339 strings.
 
 ## Sliding Windows
 
 Sliding windows begin from an offset of the prior windows' beginning rather than its end, and therefore messages may belong to multiple windows. In order to produce sliding windows specify a `+"[`slide` duration](#slide)"+`.
 
+## Keyed Windows
+
+By specifying a `+"[`group_by` mapping](#group_by)"+` each message is assigned a key and messages of each key are allocated to independent windows. When a window is flushed the messages of each key are emitted as their own batch, and each message has the metadata field `+"`window_key`"+` added to it containing the key of the window.
+
+## Session Windows
+
+When the `+"[`mode`](#mode)"+` is set to `+"`session`"+` windows do not follow a fixed schedule. Instead, a window is opened for a key when a message arrives with no window currently open, and remains open for as long as messages of that key continue to arrive within the `+"[`gap`](#gap)"+` duration of each other. Once the system clock surpasses the timestamp of the last message of a window plus the gap (and `+"`allowed_lateness`"+`) the window is flushed.
+
+For session windows the `+"`window_start_timestamp`"+` metadata field contains the timestamp of the first message of the window, and `+"`window_end_timestamp`"+` contains the timestamp of the last message plus the gap. Messages that arrive with a timestamp more than the gap before the beginning of the currently open window of their key are dropped.
+
+The number of keys that can be held at a given time can be limited with the `+"[`max_open_keys`](#max_open_keys)"+` field. In session mode, when this limit is reached the window that has been inactive the longest is flushed early in order to make room. In tumbling mode windows can't be flushed early, and so a batch containing messages of a new key that would exceed the limit is rejected, and therefore retried, until windows have been flushed and their keys released.
+
 ## Back Pressure
 
 If back pressure is applied to this buffer either due to output services being unavailable or resources being saturated, windows older than the current and last according to the system clock will be dropped in order to prevent unbounded resource usage. This means you should ensure that under the worst case scenario you have enough system memory to store two windows' worth of data at a given time (plus extra for redundancy and other services).
@@ -54,8 +66,18 @@ The timestamp value assigned to `+"`root`"+` must either be a numerical unix tim
 `).
 			Default("root = now()").
 			Example("root = this.created_at").Example(`root = meta("kafka_timestamp_unix").number()`)).
+		Field(service.NewStringEnumField("mode", "tumbling", "session").
+			Description("The windowing mode to use. In `tumbling` mode windows are of a fixed size (and may slide), whereas in `session` mode a window remains open until no messages have arrived for the `gap` duration.").
+			Default("tumbling").
+			Version("3.65.0")).
+		Field(service.NewBloblangField("group_by").
+			Description("An optional [Bloblang mapping](/docs/guides/bloblang/about) applied to each message during ingestion that provides a key, where messages of each key are allocated to independent windows.").
+			Optional().
+			Example(`root = this.user_id`).Example(`root = meta("kafka_key")`).
+			Version("3.65.0")).
 		Field(service.NewStringField("size").
-			Description("A duration string describing the size of each window. By default windows are aligned to the zeroth minute and zeroth hour on the UTC clock, meaning windows of 1 hour duration will match the turn of each hour in the day, this can be adjusted with the `offset` field.").
+			Description("A duration string describing the size of each window, required in `tumbling` mode. By default windows are aligned to the zeroth minute and zeroth hour on the UTC clock, meaning windows of 1 hour duration will match the turn of each hour in the day, this can be adjusted with the `offset` field.").
+			Default("").
 			Example("30s").Example("10m")).
 		Field(service.NewStringField("slide").
 			Description("An optional duration string describing by how much time the beginning of each window should be offset from the beginning of the previous, and therefore creates sliding windows instead of tumbling. When specified this duration must be smaller than the `size` of the window.").
@@ -69,6 +91,16 @@ The timestamp value assigned to `+"`root`"+` must either be a numerical unix tim
 			Description("An optional duration string describing the length of time to wait after a window has ended before flushing it, allowing late arrivals to be included. Since this windowing buffer uses the system clock an allowed lateness can improve the matching of messages when using event time.").
 			Default("").
 			Example("10s").Example("1m")).
+		Field(service.NewStringField("gap").
+			Description("A duration string describing the period of inactivity after which a window is closed, required in `session` mode.").
+			Default("").
+			Example("30s").Example("10m").
+			Version("3.65.0")).
+		Field(service.NewIntField("max_open_keys").
+			Description("The maximum number of distinct keys that can be held at a given time. In `session` mode the window that has been inactive the longest is flushed early when this limit is reached, whereas in `tumbling` mode batches containing new keys are rejected until windows have been flushed. Set to zero in order to disable the limit.").
+			Default(0).
+			Advanced().
+			Version("3.65.0")).
 		Example("Counting Passengers at Traffic", `Given a stream of messages relating to cars passing through various traffic lights of the form:
 
 `+"```json"+`
@@ -116,8 +148,31 @@ pipeline:
           }
         } else { deleted() }
 `,
-		)
+		).
+		Example("User Sessions", `Given a stream of click events from users of a website, we can use session windows in order to emit a summary of each visit, where a visit ends once a user has been inactive for ten minutes:`,
+			`
+buffer:
+  system_window:
+    mode: session
+    timestamp_mapping: root = this.created_at
+    group_by: root = this.user_id
+    gap: 10m
+    max_open_keys: 100000
 
+pipeline:
+  processors:
+    - bloblang: |
+        root = if batch_index() == 0 {
+          {
+            "user_id": meta("window_key"),
+            "started_at": meta("window_start_timestamp"),
+            "ended_at": meta("window_end_timestamp"),
+            "pages": json("page").from_all().unique(),
+            "clicks": batch_size(),
+          }
+        } else { deleted() }
+`,
+		)
 }
 
 func getDuration(conf *service.ParsedConfig, required bool, name string) (time.Duration, error) {
@@ -139,10 +194,51 @@ func init() {
 	err := service.RegisterBatchBuffer(
 		"system_window", tumblingWindowBufferConfig(),
 		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchBuffer, error) {
-			size, err := getDuration(conf, true, "size")
+			tsMapping, err := conf.FieldBloblang("timestamp_mapping")
 			if err != nil {
 				return nil, err
 			}
+			var groupBy *bloblang.Executor
+			if conf.Contains("group_by") {
+				if groupBy, err = conf.FieldBloblang("group_by"); err != nil {
+					return nil, err
+				}
+			}
+			allowedLateness, err := getDuration(conf, false, "allowed_lateness")
+			if err != nil {
+				return nil, err
+			}
+			mode, err := conf.FieldString("mode")
+			if err != nil {
+				return nil, err
+			}
+			clock := func() time.Time {
+				return time.Now().UTC()
+			}
+
+			maxOpenKeys, err := conf.FieldInt("max_open_keys")
+			if err != nil {
+				return nil, err
+			}
+
+			if mode == "session" {
+				gap, err := getDuration(conf, false, "gap")
+				if err != nil {
+					return nil, err
+				}
+				if gap <= 0 {
+					return nil, errors.New("field 'gap' is required in session mode")
+				}
+				return newSessionWindowBuffer(tsMapping, groupBy, clock, gap, allowedLateness, maxOpenKeys, mgr.Logger())
+			}
+
+			size, err := getDuration(conf, false, "size")
+			if err != nil {
+				return nil, err
+			}
+			if size <= 0 {
+				return nil, errors.New("field 'size' is required in tumbling mode")
+			}
 			slide, err := getDuration(conf, false, "slide")
 			if err != nil {
 				return nil, err
@@ -160,20 +256,10 @@ func init() {
 			if slide > 0 && offset >= slide {
 				return nil, fmt.Errorf("invalid offset '%v' must be lower than the slide '%v'", offset, slide)
 			}
-			allowedLateness, err := getDuration(conf, false, "allowed_lateness")
-			if err != nil {
-				return nil, err
-			}
 			if allowedLateness >= size {
 				return nil, fmt.Errorf("invalid allowed_lateness '%v' must be lower than the size '%v'", allowedLateness, size)
 			}
-			tsMapping, err := conf.FieldBloblang("timestamp_mapping")
-			if err != nil {
-				return nil, err
-			}
-			return newSystemWindowBuffer(tsMapping, func() time.Time {
-				return time.Now().UTC()
-			}, size, slide, offset, allowedLateness, mgr.Logger())
+			return newSystemWindowBuffer(tsMapping, groupBy, clock, size, slide, offset, allowedLateness, maxOpenKeys, mgr.Logger())
 		})
 
 	if err != nil {
@@ -185,16 +271,24 @@ func init() {
 
 type tsMessage struct {
 	ts    time.Time
+	key   string
 	m     *service.Message
 	ackFn service.AckFunc
 }
 
+// windowBatch is a batch of messages from a flushed window along with an
+// aggregated acknowledgement function.
+type windowBatch struct {
+	b     service.MessageBatch
+	ackFn service.AckFunc
+}
+
 type utcNowProvider func() time.Time
 
 type systemWindowBuffer struct {
 	logger *service.Logger
 
-	tsMapping                            *bloblang.Executor
+	tsMapping, groupBy                   *bloblang.Executor
 	clock                                utcNowProvider
 	size, slide, offset, allowedLateness time.Duration
 
@@ -203,6 +297,15 @@ type systemWindowBuffer struct {
 	pending                []*tsMessage
 	pendingMut             sync.Mutex
 
+	// The number of pending messages of each key, which is only tracked when
+	// the number of keys is limited.
+	maxOpenKeys int
+	keyCounts   map[string]int
+
+	// Batches of a flushed window that are yet to be read, which only occurs
+	// when messages are grouped by key.
+	flushed []windowBatch
+
 	closedTimerChan <-chan time.Time
 
 	endOfInputChan      chan struct{}
@@ -210,18 +313,22 @@ type systemWindowBuffer struct {
 }
 
 func newSystemWindowBuffer(
-	tsMapping *bloblang.Executor,
+	tsMapping, groupBy *bloblang.Executor,
 	clock utcNowProvider,
 	size, slide, offset, allowedLateness time.Duration,
+	maxOpenKeys int,
 	logger *service.Logger,
 ) (*systemWindowBuffer, error) {
 	w := &systemWindowBuffer{
 		tsMapping:       tsMapping,
+		groupBy:         groupBy,
 		clock:           clock,
 		size:            size,
 		slide:           slide,
 		allowedLateness: allowedLateness,
 		offset:          offset,
+		maxOpenKeys:     maxOpenKeys,
+		keyCounts:       map[string]int{},
 		logger:          logger,
 		oldestTS:        clock(),
 		endOfInputChan:  make(chan struct{}),
@@ -267,10 +374,10 @@ func (w *systemWindowBuffer) nextSystemWindow() (prevStart, prevEnd, start, end
 	return
 }
 
-func (w *systemWindowBuffer) getTimestamp(i int, batch service.MessageBatch) (ts time.Time, err error) {
+func getWindowTimestamp(logger *service.Logger, tsMapping *bloblang.Executor, i int, batch service.MessageBatch) (ts time.Time, err error) {
 	var tsValueMsg *service.Message
-	if tsValueMsg, err = batch.BloblangQuery(i, w.tsMapping); err != nil {
-		w.logger.Errorf("Timestamp mapping failed for message: %v", err)
+	if tsValueMsg, err = batch.BloblangQuery(i, tsMapping); err != nil {
+		logger.Errorf("Timestamp mapping failed for message: %v", err)
 		err = fmt.Errorf("timestamp mapping failed: %w", err)
 		return
 	}
@@ -283,18 +390,35 @@ func (w *systemWindowBuffer) getTimestamp(i int, batch service.MessageBatch) (ts
 		}
 	}
 	if err != nil {
-		w.logger.Errorf("Timestamp mapping failed for message: unable to parse result as structured value: %v", err)
+		logger.Errorf("Timestamp mapping failed for message: unable to parse result as structured value: %v", err)
 		err = fmt.Errorf("unable to parse result of timestamp mapping as structured value: %w", err)
 		return
 	}
 
 	if ts, err = query.IGetTimestamp(tsValue); err != nil {
-		w.logger.Errorf("Timestamp mapping failed for message: %v", err)
+		logger.Errorf("Timestamp mapping failed for message: %v", err)
 		err = fmt.Errorf("unable to parse result of timestamp mapping as timestamp: %w", err)
 	}
 	return
 }
 
+func getWindowKey(logger *service.Logger, groupBy *bloblang.Executor, i int, batch service.MessageBatch) (string, error) {
+	if groupBy == nil {
+		return "", nil
+	}
+	keyMsg, err := batch.BloblangQuery(i, groupBy)
+	if err != nil {
+		logger.Errorf("Group by mapping failed for message: %v", err)
+		return "", fmt.Errorf("group by mapping failed: %w", err)
+	}
+	keyBytes, err := keyMsg.AsBytes()
+	if err != nil {
+		logger.Errorf("Group by mapping failed for message: %v", err)
+		return "", fmt.Errorf("group by mapping failed: %w", err)
+	}
+	return string(keyBytes), nil
+}
+
 func (w *systemWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageBatch, aFn service.AckFunc) error {
 	w.pendingMut.Lock()
 	defer w.pendingMut.Unlock()
@@ -310,6 +434,7 @@ func (w *systemWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.Me
 				// Reject messages too old to fit into a window by acknowledging
 				// them.
 				_ = pending.ackFn(ctx, nil)
+				w.releaseKey(pending.key)
 				continue
 			}
 			newPending = append(newPending, pending)
@@ -320,15 +445,41 @@ func (w *systemWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.Me
 		w.pending = newPending
 	}
 
+	timestamps := make([]time.Time, len(msgBatch))
+	keys := make([]string, len(msgBatch))
+	var newKeys map[string]struct{}
+	for i := range msgBatch {
+		var err error
+		if timestamps[i], err = getWindowTimestamp(w.logger, w.tsMapping, i, msgBatch); err != nil {
+			return err
+		}
+		if keys[i], err = getWindowKey(w.logger, w.groupBy, i, msgBatch); err != nil {
+			return err
+		}
+		if w.maxOpenKeys <= 0 || !timestamps[i].After(w.latestFlushedWindowEnd) {
+			continue
+		}
+		if _, exists := w.keyCounts[keys[i]]; !exists {
+			if newKeys == nil {
+				newKeys = map[string]struct{}{}
+			}
+			newKeys[keys[i]] = struct{}{}
+		}
+	}
+
+	// Windows can't be flushed early and so the batch is rejected until
+	// enough keys have been released by flushed windows.
+	if len(w.keyCounts)+len(newKeys) > w.maxOpenKeys && len(newKeys) > 0 {
+		w.logger.Debugf("Rejecting batch as it contains %v new keys and %v of a maximum %v are open", len(newKeys), len(w.keyCounts), w.maxOpenKeys)
+		return fmt.Errorf("max_open_keys limit of %v reached", w.maxOpenKeys)
+	}
+
 	messageAdded := false
 	aggregatedAck := batch.NewCombinedAcker(batch.AckFunc(aFn))
 
 	// And now add new messages.
 	for i, msg := range msgBatch {
-		ts, err := w.getTimestamp(i, msgBatch)
-		if err != nil {
-			return err
-		}
+		ts, key := timestamps[i], keys[i]
 
 		// Don't add messages older than our current window start.
 		if !ts.After(w.latestFlushedWindowEnd) {
@@ -337,8 +488,11 @@ func (w *systemWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.Me
 
 		messageAdded = true
 		w.pending = append(w.pending, &tsMessage{
-			ts: ts, m: msg, ackFn: service.AckFunc(aggregatedAck.Derive()),
+			ts: ts, key: key, m: msg, ackFn: service.AckFunc(aggregatedAck.Derive()),
 		})
+		if w.maxOpenKeys > 0 {
+			w.keyCounts[key]++
+		}
 		if ts.Before(w.oldestTS) {
 			w.oldestTS = ts
 		}
@@ -352,7 +506,7 @@ func (w *systemWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.Me
 	return nil
 }
 
-func (w *systemWindowBuffer) flushWindow(ctx context.Context, start, end time.Time) (service.MessageBatch, service.AckFunc, error) {
+func (w *systemWindowBuffer) flushWindow(ctx context.Context, start, end time.Time) []windowBatch {
 	w.pendingMut.Lock()
 	defer w.pendingMut.Unlock()
 
@@ -362,8 +516,11 @@ func (w *systemWindowBuffer) flushWindow(ctx context.Context, start, end time.Ti
 		nextStart = start.Add(w.slide)
 	}
 
-	var flushBatch service.MessageBatch
-	var flushAcks []service.AckFunc
+	// Messages are grouped by key in the order that each key was first seen,
+	// when there's no group_by mapping all keys are empty.
+	var keys []string
+	keyBatches := map[string]service.MessageBatch{}
+	keyAcks := map[string][]service.AckFunc{}
 
 	newPending := make([]*tsMessage, 0, len(w.pending))
 	newOldest := w.clock()
@@ -373,9 +530,16 @@ func (w *systemWindowBuffer) flushWindow(ctx context.Context, start, end time.Ti
 
 		if flush {
 			tmpMsg := pending.m.Copy()
+			tmpMsg.MetaSet("window_start_timestamp", start.Add(-1).Format(time.RFC3339Nano))
 			tmpMsg.MetaSet("window_end_timestamp", end.Format(time.RFC3339Nano))
-			flushBatch = append(flushBatch, tmpMsg)
-			flushAcks = append(flushAcks, pending.ackFn)
+			if w.groupBy != nil {
+				tmpMsg.MetaSet("window_key", pending.key)
+			}
+			if _, exists := keyBatches[pending.key]; !exists {
+				keys = append(keys, pending.key)
+			}
+			keyBatches[pending.key] = append(keyBatches[pending.key], tmpMsg)
+			keyAcks[pending.key] = append(keyAcks[pending.key], pending.ackFn)
 		}
 		if preserve {
 			if pending.ts.Before(newOldest) {
@@ -386,35 +550,79 @@ func (w *systemWindowBuffer) flushWindow(ctx context.Context, start, end time.Ti
 		if !flush && !preserve {
 			_ = pending.ackFn(ctx, nil)
 		}
+		if !preserve {
+			w.releaseKey(pending.key)
+		}
 	}
 
 	w.pending = newPending
 	w.latestFlushedWindowEnd = end
 	w.oldestTS = newOldest
 
-	return flushBatch, func(ctx context.Context, err error) error {
-		for _, aFn := range flushAcks {
+	batches := make([]windowBatch, 0, len(keys))
+	for _, k := range keys {
+		batches = append(batches, windowBatch{
+			b:     keyBatches[k],
+			ackFn: combineWindowAcks(keyAcks[k]),
+		})
+	}
+	return batches
+}
+
+// releaseKey decrements the number of pending messages of a key, which is
+// removed from the open keys once it reaches zero. Must be called whilst
+// holding pendingMut.
+func (w *systemWindowBuffer) releaseKey(key string) {
+	if w.maxOpenKeys <= 0 {
+		return
+	}
+	if w.keyCounts[key]--; w.keyCounts[key] <= 0 {
+		delete(w.keyCounts, key)
+	}
+}
+
+func combineWindowAcks(ackFns []service.AckFunc) service.AckFunc {
+	return func(ctx context.Context, err error) error {
+		for _, aFn := range ackFns {
 			_ = aFn(ctx, err)
 		}
 		return nil
-	}, nil
+	}
+}
+
+// popFlushed returns the next batch of a flushed window, if any.
+func (w *systemWindowBuffer) popFlushed() (service.MessageBatch, service.AckFunc, bool) {
+	if len(w.flushed) == 0 {
+		return nil, nil, false
+	}
+	next := w.flushed[0]
+	w.flushed[0] = windowBatch{}
+	w.flushed = w.flushed[1:]
+	return next.b, next.ackFn, true
 }
 
 var errWindowClosed = errors.New("message rejected as window did not complete")
 
 func (w *systemWindowBuffer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	// Batches of different keys from the last flushed window are still
+	// waiting to be read.
+	if msgBatch, aFn, ok := w.popFlushed(); ok {
+		return msgBatch, aFn, nil
+	}
+
 	prevStart, prevEnd, nextStart, nextEnd := w.nextSystemWindow()
 
 	// We haven't been read since the previous window ended, so create that one
 	// instead in an attempt to back fill.
 	//
-	// Note that we do not need to lock around latestFlushWindowEnd because it's
-	// only written from the reader, and we only expect one active reader at a
-	// given time. If this assumption changes we would need to lock around this
-	// also.
+	// Note that we do not need to lock around latestFlushWindowEnd or flushed
+	// because they're only written from the reader, and we only expect one
+	// active reader at a given time. If this assumption changes we would need
+	// to lock around these also.
 	if w.latestFlushedWindowEnd.Before(prevStart) {
-		if msgBatch, aFn, err := w.flushWindow(ctx, prevStart, prevEnd); len(msgBatch) > 0 || err != nil {
-			return msgBatch, aFn, err
+		w.flushed = w.flushWindow(ctx, prevStart, prevEnd)
+		if msgBatch, aFn, ok := w.popFlushed(); ok {
+			return msgBatch, aFn, nil
 		}
 	}
 
@@ -437,11 +645,13 @@ func (w *systemWindowBuffer) ReadBatch(ctx context.Context) (service.MessageBatc
 				_ = pending.ackFn(ctx, errWindowClosed)
 			}
 			w.pending = nil
+			w.keyCounts = map[string]int{}
 			w.pendingMut.Unlock()
 			return nil, nil, service.ErrEndOfBuffer
 		}
-		if msgBatch, aFn, err := w.flushWindow(ctx, nextStart, nextEnd); len(msgBatch) > 0 || err != nil {
-			return msgBatch, aFn, err
+		w.flushed = w.flushWindow(ctx, nextStart, nextEnd)
+		if msgBatch, aFn, ok := w.popFlushed(); ok {
+			return msgBatch, aFn, nil
 		}
 
 		// Window did not contain any messages, so move onto next.
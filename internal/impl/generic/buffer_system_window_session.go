@@ -0,0 +1,273 @@
+package generic
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+type sessionWindow struct {
+	key         string
+	first, last time.Time
+	pending     []*tsMessage
+}
+
+// sessionWindowBuffer allocates messages of each key to windows that remain
+// open for as long as messages continue to arrive within a gap duration of
+// each other, once the system clock surpasses the last message timestamp plus
+// the gap the window is flushed.
+type sessionWindowBuffer struct {
+	logger *service.Logger
+
+	tsMapping, groupBy   *bloblang.Executor
+	clock                utcNowProvider
+	gap, allowedLateness time.Duration
+	maxOpenKeys          int
+
+	sessions   map[string]*sessionWindow
+	closed     []*sessionWindow
+	pendingMut sync.Mutex
+
+	// Signalled whenever a write occurs so that the reader can reevaluate
+	// which sessions have closed and when the next is due to close.
+	writtenChan chan struct{}
+
+	endOfInputChan      chan struct{}
+	closeEndOfInputOnce sync.Once
+}
+
+func newSessionWindowBuffer(
+	tsMapping, groupBy *bloblang.Executor,
+	clock utcNowProvider,
+	gap, allowedLateness time.Duration,
+	maxOpenKeys int,
+	logger *service.Logger,
+) (*sessionWindowBuffer, error) {
+	return &sessionWindowBuffer{
+		tsMapping:       tsMapping,
+		groupBy:         groupBy,
+		clock:           clock,
+		gap:             gap,
+		allowedLateness: allowedLateness,
+		maxOpenKeys:     maxOpenKeys,
+		logger:          logger,
+		sessions:        map[string]*sessionWindow{},
+		writtenChan:     make(chan struct{}, 1),
+		endOfInputChan:  make(chan struct{}),
+	}, nil
+}
+
+// closeSession moves a session from the open set to the queue of sessions
+// waiting to be read. Must be called whilst holding pendingMut.
+func (w *sessionWindowBuffer) closeSession(s *sessionWindow) {
+	delete(w.sessions, s.key)
+	w.closed = append(w.closed, s)
+}
+
+// evictOldestSession closes the session that has been inactive the longest.
+// Must be called whilst holding pendingMut.
+func (w *sessionWindowBuffer) evictOldestSession() {
+	var oldest *sessionWindow
+	for _, s := range w.sessions {
+		if oldest == nil || s.last.Before(oldest.last) || (s.last.Equal(oldest.last) && s.key < oldest.key) {
+			oldest = s
+		}
+	}
+	if oldest != nil {
+		w.closeSession(oldest)
+	}
+}
+
+func (w *sessionWindowBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageBatch, aFn service.AckFunc) error {
+	w.pendingMut.Lock()
+	defer w.pendingMut.Unlock()
+
+	messageAdded := false
+	aggregatedAck := batch.NewCombinedAcker(batch.AckFunc(aFn))
+
+	for i, msg := range msgBatch {
+		ts, err := getWindowTimestamp(w.logger, w.tsMapping, i, msgBatch)
+		if err != nil {
+			return err
+		}
+		key, err := getWindowKey(w.logger, w.groupBy, i, msgBatch)
+		if err != nil {
+			return err
+		}
+
+		s, exists := w.sessions[key]
+		if exists {
+			if ts.Before(s.first.Add(-w.gap)) {
+				// Don't add messages that precede the open session by more
+				// than the gap, as the session they belong to has closed.
+				continue
+			}
+			if ts.After(s.last.Add(w.gap)) {
+				// The gap has elapsed in event time even though the system
+				// clock hasn't caught up, so close the session and open anew.
+				w.closeSession(s)
+				exists = false
+			}
+		}
+		if !exists {
+			if w.maxOpenKeys > 0 && len(w.sessions) >= w.maxOpenKeys {
+				w.evictOldestSession()
+			}
+			s = &sessionWindow{key: key, first: ts, last: ts}
+			w.sessions[key] = s
+		}
+
+		messageAdded = true
+		s.pending = append(s.pending, &tsMessage{
+			ts: ts, key: key, m: msg, ackFn: service.AckFunc(aggregatedAck.Derive()),
+		})
+		if ts.Before(s.first) {
+			s.first = ts
+		}
+		if ts.After(s.last) {
+			s.last = ts
+		}
+	}
+
+	if !messageAdded {
+		// If none of the messages have fit into a session we reject them by
+		// acknowledging the batch.
+		_ = aFn(ctx, nil)
+	}
+
+	select {
+	case w.writtenChan <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// expireSessions closes all open sessions that have ended according to the
+// system clock and returns the time at which the next open session is due to
+// end, or a zero time if there are no open sessions.
+func (w *sessionWindowBuffer) expireSessions() (nextExpiry time.Time) {
+	w.pendingMut.Lock()
+	defer w.pendingMut.Unlock()
+
+	now := w.clock()
+
+	var expired []*sessionWindow
+	for _, s := range w.sessions {
+		expiry := s.last.Add(w.gap + w.allowedLateness)
+		if !expiry.After(now) {
+			expired = append(expired, s)
+			continue
+		}
+		if nextExpiry.IsZero() || expiry.Before(nextExpiry) {
+			nextExpiry = expiry
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].last.Equal(expired[j].last) {
+			return expired[i].key < expired[j].key
+		}
+		return expired[i].last.Before(expired[j].last)
+	})
+	for _, s := range expired {
+		w.closeSession(s)
+	}
+	return
+}
+
+func (w *sessionWindowBuffer) popClosed() *sessionWindow {
+	w.pendingMut.Lock()
+	defer w.pendingMut.Unlock()
+
+	if len(w.closed) == 0 {
+		return nil
+	}
+	s := w.closed[0]
+	w.closed[0] = nil
+	w.closed = w.closed[1:]
+	return s
+}
+
+func (w *sessionWindowBuffer) flushSession(s *sessionWindow) (service.MessageBatch, service.AckFunc) {
+	startStr := s.first.Format(time.RFC3339Nano)
+	endStr := s.last.Add(w.gap).Format(time.RFC3339Nano)
+
+	flushBatch := make(service.MessageBatch, 0, len(s.pending))
+	flushAcks := make([]service.AckFunc, 0, len(s.pending))
+	for _, pending := range s.pending {
+		tmpMsg := pending.m.Copy()
+		tmpMsg.MetaSet("window_start_timestamp", startStr)
+		tmpMsg.MetaSet("window_end_timestamp", endStr)
+		if w.groupBy != nil {
+			tmpMsg.MetaSet("window_key", pending.key)
+		}
+		flushBatch = append(flushBatch, tmpMsg)
+		flushAcks = append(flushAcks, pending.ackFn)
+	}
+	return flushBatch, combineWindowAcks(flushAcks)
+}
+
+func (w *sessionWindowBuffer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	for {
+		nextExpiry := w.expireSessions()
+		if s := w.popClosed(); s != nil {
+			msgBatch, aFn := w.flushSession(s)
+			return msgBatch, aFn, nil
+		}
+
+		var timer *time.Timer
+		var nextExpiryChan <-chan time.Time
+		if !nextExpiry.IsZero() {
+			timer = time.NewTimer(nextExpiry.Sub(w.clock()))
+			nextExpiryChan = timer.C
+		}
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		select {
+		case <-nextExpiryChan:
+		case <-w.writtenChan:
+			stopTimer()
+		case <-ctx.Done():
+			stopTimer()
+			return nil, nil, ctx.Err()
+		case <-w.endOfInputChan:
+			stopTimer()
+			// Nack all pending messages so that we re-consume them on the next
+			// start up.
+			w.pendingMut.Lock()
+			for _, s := range w.closed {
+				for _, pending := range s.pending {
+					_ = pending.ackFn(ctx, errWindowClosed)
+				}
+			}
+			for _, s := range w.sessions {
+				for _, pending := range s.pending {
+					_ = pending.ackFn(ctx, errWindowClosed)
+				}
+			}
+			w.closed = nil
+			w.sessions = map[string]*sessionWindow{}
+			w.pendingMut.Unlock()
+			return nil, nil, service.ErrEndOfBuffer
+		}
+	}
+}
+
+func (w *sessionWindowBuffer) EndOfInput() {
+	w.closeEndOfInputOnce.Do(func() {
+		close(w.endOfInputChan)
+	})
+}
+
+func (w *sessionWindowBuffer) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,175 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionBatchContents(t testing.TB, b service.MessageBatch) (contents []string, key, start, end string) {
+	t.Helper()
+	for _, m := range b {
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		contents = append(contents, string(mBytes))
+	}
+	key, _ = b[0].MetaGet("window_key")
+	start, _ = b[0].MetaGet("window_start_timestamp")
+	end, _ = b[0].MetaGet("window_end_timestamp")
+	return
+}
+
+func TestSessionWindowBasic(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	groupBy, err := bloblang.Parse(`root = this.user`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 0).UTC()
+	w, err := newSessionWindowBuffer(mapping, groupBy, func() time.Time {
+		return currentTS
+	}, time.Second*2, 0, 0, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","user":"a","ts":9}`)),
+		service.NewMessage([]byte(`{"id":"2","user":"b","ts":9.5}`)),
+		service.NewMessage([]byte(`{"id":"3","user":"a","ts":10}`)),
+	}, noopAck))
+
+	smallWaitCtx, done := context.WithTimeout(context.Background(), time.Millisecond*50)
+	_, _, err = w.ReadBatch(smallWaitCtx)
+	done()
+	require.Error(t, err)
+
+	// Keep the session of a open
+	currentTS = time.Unix(11, 0).UTC()
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"4","user":"a","ts":11}`)),
+	}, noopAck))
+
+	currentTS = time.Unix(11, 600000000).UTC()
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	contents, key, start, end := sessionBatchContents(t, resBatch)
+	assert.Equal(t, []string{`{"id":"2","user":"b","ts":9.5}`}, contents)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, "1970-01-01T00:00:09.5Z", start)
+	assert.Equal(t, "1970-01-01T00:00:11.5Z", end)
+
+	currentTS = time.Unix(13, 0).UTC()
+
+	resBatch, _, err = w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	contents, key, start, end = sessionBatchContents(t, resBatch)
+	assert.Equal(t, []string{
+		`{"id":"1","user":"a","ts":9}`,
+		`{"id":"3","user":"a","ts":10}`,
+		`{"id":"4","user":"a","ts":11}`,
+	}, contents)
+	assert.Equal(t, "a", key)
+	assert.Equal(t, "1970-01-01T00:00:09Z", start)
+	assert.Equal(t, "1970-01-01T00:00:13Z", end)
+
+	assert.Len(t, w.sessions, 0)
+	assert.Len(t, w.closed, 0)
+}
+
+func TestSessionWindowEventTimeGap(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 0).UTC()
+	w, err := newSessionWindowBuffer(mapping, nil, func() time.Time {
+		return currentTS
+	}, time.Second, time.Second*10, 0, nil)
+	require.NoError(t, err)
+
+	var ackCalls int
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","ts":5}`)),
+		service.NewMessage([]byte(`{"id":"2","ts":5.5}`)),
+		service.NewMessage([]byte(`{"id":"3","ts":8}`)),
+		service.NewMessage([]byte(`{"id":"4","ts":2}`)),
+	}, func(ctx context.Context, err error) error {
+		ackCalls++
+		return nil
+	}))
+
+	// The gap between 2 and 3 closes the first session even though the
+	// allowed lateness means the system clock hasn't caught up.
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	contents, key, start, end := sessionBatchContents(t, resBatch)
+	assert.Equal(t, []string{`{"id":"1","ts":5}`, `{"id":"2","ts":5.5}`}, contents)
+	assert.Equal(t, "", key)
+	assert.Equal(t, "1970-01-01T00:00:05Z", start)
+	assert.Equal(t, "1970-01-01T00:00:06.5Z", end)
+
+	// Message 4 precedes the new session by more than the gap and is dropped.
+	require.Len(t, w.sessions, 1)
+	assert.Len(t, w.sessions[""].pending, 1)
+	assert.Equal(t, 0, ackCalls)
+}
+
+func TestSessionWindowMaxOpenKeys(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	groupBy, err := bloblang.Parse(`root = this.user`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 0).UTC()
+	w, err := newSessionWindowBuffer(mapping, groupBy, func() time.Time {
+		return currentTS
+	}, time.Minute, 0, 2, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","user":"a","ts":9}`)),
+		service.NewMessage([]byte(`{"id":"2","user":"b","ts":8}`)),
+		service.NewMessage([]byte(`{"id":"3","user":"a","ts":9.5}`)),
+		service.NewMessage([]byte(`{"id":"4","user":"c","ts":10}`)),
+	}, noopAck))
+
+	assert.Len(t, w.sessions, 2)
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	contents, key, _, _ := sessionBatchContents(t, resBatch)
+	assert.Equal(t, []string{`{"id":"2","user":"b","ts":8}`}, contents)
+	assert.Equal(t, "b", key)
+}
+
+func TestSessionWindowEndOfInput(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 0).UTC()
+	w, err := newSessionWindowBuffer(mapping, nil, func() time.Time {
+		return currentTS
+	}, time.Minute, 0, 0, nil)
+	require.NoError(t, err)
+
+	var ackErr error
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","ts":9}`)),
+	}, func(ctx context.Context, err error) error {
+		ackErr = err
+		return nil
+	}))
+
+	w.EndOfInput()
+
+	_, _, err = w.ReadBatch(context.Background())
+	assert.True(t, errors.Is(err, service.ErrEndOfBuffer))
+	assert.Equal(t, errWindowClosed, ackErr)
+}
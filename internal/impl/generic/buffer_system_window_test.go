@@ -31,7 +31,39 @@ system_window:
 			config: `
 system_window: {}
 `,
-			lintErrContains: "field size is required",
+			buildErrContains: "field 'size' is required in tumbling mode",
+		},
+		{
+			config: `
+system_window:
+  mode: session
+  group_by: root = this.user_id
+  gap: 10m
+  max_open_keys: 100
+`,
+		},
+		{
+			config: `
+system_window:
+  mode: session
+`,
+			buildErrContains: "field 'gap' is required in session mode",
+		},
+		{
+			config: `
+system_window:
+  size: 60m
+  group_by: root = this.user_id
+  max_open_keys: 100
+`,
+		},
+		{
+			config: `
+system_window:
+  mode: nope
+  size: 60m
+`,
+			lintErrContains: "nope",
 		},
 		{
 			config: `
@@ -214,11 +246,11 @@ func TestSystemCurrentWindowCalc(t *testing.T) {
 
 	for i, test := range tests {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			w, err := newSystemWindowBuffer(nil, func() time.Time {
+			w, err := newSystemWindowBuffer(nil, nil, func() time.Time {
 				ts, err := time.Parse(time.RFC3339Nano, test.now)
 				require.NoError(t, err)
 				return ts.UTC()
-			}, test.size, test.slide, test.offset, 0, nil)
+			}, test.size, test.slide, test.offset, 0, 0, nil)
 			require.NoError(t, err)
 
 			prevStart, prevEnd, start, end := w.nextSystemWindow()
@@ -240,9 +272,9 @@ func TestSystemWindowWritePurge(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 1).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, 0, 0, 0, nil)
+	}, time.Second, 0, 0, 0, 0, nil)
 	require.NoError(t, err)
 
 	err = w.WriteBatch(context.Background(), service.MessageBatch{
@@ -269,9 +301,9 @@ func TestSystemWindowCreation(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 1).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, 0, 0, 0, nil)
+	}, time.Second, 0, 0, 0, 0, nil)
 	require.NoError(t, err)
 
 	err = w.WriteBatch(context.Background(), service.MessageBatch{
@@ -341,9 +373,9 @@ func TestSystemWindowCreationSliding(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 0).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, time.Millisecond*500, 0, 0, nil)
+	}, time.Second, time.Millisecond*500, 0, 0, 0, nil)
 	require.NoError(t, err)
 	w.latestFlushedWindowEnd = time.Unix(9, 500_000_000)
 
@@ -426,9 +458,9 @@ func TestSystemWindowAckOneToMany(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 1).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, 0, 0, 0, nil)
+	}, time.Second, 0, 0, 0, 0, nil)
 	require.NoError(t, err)
 
 	var ackCalled int
@@ -498,9 +530,9 @@ func TestSystemWindowAckManyToOne(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 1).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, 0, 0, 0, nil)
+	}, time.Second, 0, 0, 0, 0, nil)
 	require.NoError(t, err)
 
 	ackCalls := map[int]error{}
@@ -557,9 +589,9 @@ func TestSystemWindowParallelReadAndWrites(t *testing.T) {
 	require.NoError(t, err)
 
 	currentTS := time.Unix(10, 500000000).UTC()
-	w, err := newSystemWindowBuffer(mapping, func() time.Time {
+	w, err := newSystemWindowBuffer(mapping, nil, func() time.Time {
 		return currentTS
-	}, time.Second, 0, 0, 0, nil)
+	}, time.Second, 0, 0, 0, 0, nil)
 	require.NoError(t, err)
 
 	var wg sync.WaitGroup
@@ -589,3 +621,108 @@ func TestSystemWindowParallelReadAndWrites(t *testing.T) {
 	close(startChan)
 	wg.Wait()
 }
+
+func TestSystemWindowGroupBy(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	groupBy, err := bloblang.Parse(`root = this.user`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 1).UTC()
+	w, err := newSystemWindowBuffer(mapping, groupBy, func() time.Time {
+		return currentTS
+	}, time.Second, 0, 0, 0, 0, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","user":"b","ts":9.1}`)),
+		service.NewMessage([]byte(`{"id":"2","user":"a","ts":9.2}`)),
+		service.NewMessage([]byte(`{"id":"3","user":"b","ts":9.3}`)),
+		service.NewMessage([]byte(`{"id":"4","user":"a","ts":10.5}`)),
+	}, noopAck))
+
+	type expectedBatch struct {
+		key      string
+		contents []string
+	}
+
+	for _, exp := range []expectedBatch{
+		{key: "b", contents: []string{`{"id":"1","user":"b","ts":9.1}`, `{"id":"3","user":"b","ts":9.3}`}},
+		{key: "a", contents: []string{`{"id":"2","user":"a","ts":9.2}`}},
+	} {
+		resBatch, _, err := w.ReadBatch(context.Background())
+		require.NoError(t, err)
+		require.Len(t, resBatch, len(exp.contents))
+		for i, c := range exp.contents {
+			msgBytes, err := resBatch[i].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, c, string(msgBytes))
+
+			v, _ := resBatch[i].MetaGet("window_key")
+			assert.Equal(t, exp.key, v)
+			v, _ = resBatch[i].MetaGet("window_start_timestamp")
+			assert.Equal(t, "1970-01-01T00:00:09Z", v)
+			v, _ = resBatch[i].MetaGet("window_end_timestamp")
+			assert.Equal(t, "1970-01-01T00:00:10Z", v)
+		}
+	}
+
+	currentTS = time.Unix(11, 0).UTC()
+
+	resBatch, _, err := w.ReadBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resBatch, 1)
+	msgBytes, err := resBatch[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"4","user":"a","ts":10.5}`, string(msgBytes))
+	assert.Len(t, w.pending, 0)
+	assert.Len(t, w.flushed, 0)
+}
+
+func TestSystemWindowGroupByMaxOpenKeys(t *testing.T) {
+	mapping, err := bloblang.Parse(`root = this.ts`)
+	require.NoError(t, err)
+
+	groupBy, err := bloblang.Parse(`root = this.user`)
+	require.NoError(t, err)
+
+	currentTS := time.Unix(10, 1).UTC()
+	w, err := newSystemWindowBuffer(mapping, groupBy, func() time.Time {
+		return currentTS
+	}, time.Second, 0, 0, 0, 2, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"1","user":"a","ts":10.1}`)),
+		service.NewMessage([]byte(`{"id":"2","user":"b","ts":10.2}`)),
+	}, noopAck))
+
+	// Messages of open keys are still accepted.
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"3","user":"a","ts":10.3}`)),
+	}, noopAck))
+
+	// A new key is rejected as a whole batch.
+	err = w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"4","user":"b","ts":10.4}`)),
+		service.NewMessage([]byte(`{"id":"5","user":"c","ts":10.5}`)),
+	}, noopAck)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max_open_keys")
+	assert.Len(t, w.pending, 3)
+
+	currentTS = time.Unix(11, 0).UTC()
+	for i := 0; i < 2; i++ {
+		_, _, err := w.ReadBatch(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Len(t, w.keyCounts, 0)
+
+	// Once the window has been flushed the keys are released.
+	require.NoError(t, w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"4","user":"b","ts":11.4}`)),
+		service.NewMessage([]byte(`{"id":"5","user":"c","ts":11.5}`)),
+	}, noopAck))
+	assert.Len(t, w.keyCounts, 2)
+}
@@ -0,0 +1,653 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/Jeffail/benthos/v3/internal/sketch"
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/Jeffail/gabs/v2"
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	aggregateHLLPrecision       = 12
+	aggregateTDigestCompression = 100
+)
+
+func aggregateProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Utility").
+		Summary("Maintains aggregates of the messages of each key, such as counts, sums and percentiles, within a cache resource.").
+		Description(`
+For each message a key is obtained with the `+"[`key`](#key)"+` field, and each of the `+"[`aggregates`](#aggregates)"+` of that key are updated with a value extracted from the message. The state of the aggregates of each key is stored within a `+"[cache resource](/docs/components/caches/about)"+`, and updates to the state of a key are performed atomically across all processing threads of a Benthos instance.
+
+## Aggregate Types
+
+- `+"`count`"+`: The number of messages, which does not require a value mapping.
+- `+"`sum`"+`: The sum of all values.
+- `+"`min`"+`: The smallest value.
+- `+"`max`"+`: The largest value.
+- `+"`avg`"+`: The mean of all values.
+- `+"`distinct`"+`: An estimate of the number of distinct values, computed with a [HyperLogLog](https://en.wikipedia.org/wiki/HyperLogLog) sketch that has a standard error of roughly 1.6%.
+- `+"`percentile`"+`: An estimate of a percentile of all values, computed with a [t-digest](https://github.com/tdunning/t-digest).
+
+Values of the `+"`distinct`"+` type can be of any type, whereas all other types require numerical values. Messages where a value mapping fails or provides a non-numerical result are flagged as having failed, and can be handled with [error handling patterns](/docs/configuration/error_handling). The `+"`min`"+`, `+"`max`"+`, `+"`avg`"+` and `+"`percentile`"+` types result in `+"`null`"+` when no values have been aggregated.
+
+## Emitting Results
+
+With `+"[`emit`](#emit)"+` set to `+"`enrich`"+` (default) each message has the current aggregates of its key, including its own values, added to it as an object at the `+"[`target_path`](#target_path)"+`.
+
+With `+"`emit`"+` set to `+"`snapshot`"+` the messages are consumed and instead a message is emitted for each key updated since the last snapshot, containing the aggregates of the key along with a field `+"`key`"+`. Snapshots are produced once the `+"[`snapshot_interval`](#snapshot_interval)"+` has elapsed since the previous, either when a batch is processed or, when this processor is a top level processor of a pipeline, periodically whilst no messages are flowing. Processing threads share the keys updated since the last snapshot and therefore each key is only emitted once per snapshot.
+`).
+		Field(service.NewStringField("resource").
+			Description("The [`cache` resource](/docs/components/caches/about) to store aggregate state within.")).
+		Field(service.NewInterpolatedStringField("key").
+			Description("The key to aggregate each message by, which is also used as the cache key.").
+			Example(`${! json("user_id") }`).Example(`${! meta("kafka_key") }`)).
+		Field(service.NewObjectListField("aggregates",
+			service.NewStringField("name").
+				Description("The name of the aggregate, used as the field name of its result."),
+			service.NewStringEnumField("type", "count", "sum", "min", "max", "avg", "distinct", "percentile").
+				Description("The type of aggregate."),
+			service.NewBloblangField("value").
+				Description("A [Bloblang mapping](/docs/guides/bloblang/about) that extracts the value to aggregate from each message. Required for all types other than `count`.").
+				Optional().
+				Example("root = this.price"),
+			service.NewFloatField("percentile").
+				Description("The percentile to compute, between 0 and 100, for the `percentile` type.").
+				Default(50.0),
+		).Description("A list of aggregates to compute for each key.")).
+		Field(service.NewStringEnumField("emit", "enrich", "snapshot").
+			Description("Whether to enrich messages with the current aggregates of their key, or to consume messages and emit snapshots of the aggregates of each updated key.").
+			Default("enrich")).
+		Field(service.NewStringField("target_path").
+			Description("A dot separated path at which the aggregates of a key are placed within each message when `emit` is `enrich`.").
+			Default("aggregates")).
+		Field(service.NewStringField("snapshot_interval").
+			Description("A duration string describing the minimum period of time between snapshots when `emit` is `snapshot`. When empty a snapshot is emitted for each processed batch.").
+			Default("").
+			Example("10s").Example("1m")).
+		Field(service.NewStringField("ttl").
+			Description("An optional duration string describing the TTL of the aggregate state of each key, which is refreshed each time a key is updated. Not all caches support per-key TTLs.").
+			Default("").
+			Example("24h").
+			Advanced()).
+		Example("Running Totals", `Given a stream of purchases we can add the running total, average and 99th percentile price spent by each customer to each purchase:`,
+			`
+pipeline:
+  processors:
+    - aggregate:
+        resource: totals
+        key: ${! json("customer_id") }
+        aggregates:
+          - name: purchases
+            type: count
+          - name: spent
+            type: sum
+            value: root = this.price
+          - name: avg_price
+            type: avg
+            value: root = this.price
+          - name: p99_price
+            type: percentile
+            percentile: 99
+            value: root = this.price
+        target_path: customer_stats
+
+cache_resources:
+  - label: totals
+    redis:
+      url: tcp://localhost:6379
+`,
+		).
+		Example("Unique Visitors", `We can periodically emit the number of unique visitors of each page of a website, where the page views themselves are consumed:`,
+			`
+pipeline:
+  processors:
+    - aggregate:
+        resource: visitors
+        key: ${! json("page") }
+        aggregates:
+          - name: unique_visitors
+            type: distinct
+            value: root = this.visitor_id
+        emit: snapshot
+        snapshot_interval: 1m
+
+cache_resources:
+  - label: visitors
+    memory: {}
+`,
+		)
+}
+
+func init() {
+	err := service.RegisterBatchProcessor(
+		"aggregate", aggregateProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newAggregateProcessorFromConfig(conf, mgr)
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// aggregateLocks provides striped locks for each cache resource in order for
+// updates to the aggregates of a key to be atomic across all aggregate
+// processors that share the resource. The locks of a resource are removed once
+// the last processor using it is closed.
+var (
+	aggregateLocksMut sync.Mutex
+	aggregateLocks    = map[string]*aggregateResourceLocks{}
+)
+
+type aggregateResourceLocks struct {
+	refs    int
+	stripes [256]sync.Mutex
+}
+
+func aggregateLocksAcquire(resource string) *aggregateResourceLocks {
+	aggregateLocksMut.Lock()
+	defer aggregateLocksMut.Unlock()
+
+	locks, exists := aggregateLocks[resource]
+	if !exists {
+		locks = &aggregateResourceLocks{}
+		aggregateLocks[resource] = locks
+	}
+	locks.refs++
+	return locks
+}
+
+func aggregateLocksRelease(resource string) {
+	aggregateLocksMut.Lock()
+	defer aggregateLocksMut.Unlock()
+
+	if locks, exists := aggregateLocks[resource]; exists {
+		if locks.refs--; locks.refs <= 0 {
+			delete(aggregateLocks, resource)
+		}
+	}
+}
+
+type aggregateSpec struct {
+	name       string
+	aType      string
+	value      *bloblang.Executor
+	percentile float64
+}
+
+type aggregateState struct {
+	Count  int64           `json:"count"`
+	Sum    float64         `json:"sum,omitempty"`
+	Min    *float64        `json:"min,omitempty"`
+	Max    *float64        `json:"max,omitempty"`
+	HLL    []byte          `json:"hll,omitempty"`
+	Digest *sketch.TDigest `json:"digest,omitempty"`
+}
+
+type aggregateProcessor struct {
+	log *service.Logger
+
+	key        *service.InterpolatedString
+	aggregates []aggregateSpec
+	ttl        *time.Duration
+
+	targetPath       string
+	snapshot         bool
+	snapshotInterval time.Duration
+
+	resource    string
+	accessCache func(ctx context.Context, fn func(c service.Cache)) error
+	locks       *aggregateResourceLocks
+	closeOnce   sync.Once
+
+	// The keys updated since the last snapshot, which are shared by all
+	// processing threads.
+	snapMut      sync.Mutex
+	lastSnapshot time.Time
+	touched      map[string]struct{}
+}
+
+func newAggregateProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*aggregateProcessor, error) {
+	resource, err := conf.FieldString("resource")
+	if err != nil {
+		return nil, err
+	}
+	key, err := conf.FieldInterpolatedString("key")
+	if err != nil {
+		return nil, err
+	}
+
+	aggConfs, err := conf.FieldObjectList("aggregates")
+	if err != nil {
+		return nil, err
+	}
+	if len(aggConfs) == 0 {
+		return nil, errors.New("at least one aggregate must be specified")
+	}
+	seenNames := map[string]struct{}{}
+	var aggregates []aggregateSpec
+	for i, aConf := range aggConfs {
+		var spec aggregateSpec
+		if spec.name, err = aConf.FieldString("name"); err != nil {
+			return nil, err
+		}
+		if spec.name == "" {
+			return nil, fmt.Errorf("aggregate %v must have a name", i)
+		}
+		if _, exists := seenNames[spec.name]; exists {
+			return nil, fmt.Errorf("aggregate name '%v' is not unique", spec.name)
+		}
+		seenNames[spec.name] = struct{}{}
+
+		if spec.aType, err = aConf.FieldString("type"); err != nil {
+			return nil, err
+		}
+		if aConf.Contains("value") {
+			if spec.value, err = aConf.FieldBloblang("value"); err != nil {
+				return nil, err
+			}
+		} else if spec.aType != "count" {
+			return nil, fmt.Errorf("aggregate '%v' of type %v requires a value mapping", spec.name, spec.aType)
+		}
+		if spec.percentile, err = aConf.FieldFloat("percentile"); err != nil {
+			return nil, err
+		}
+		if spec.percentile < 0 || spec.percentile > 100 {
+			return nil, fmt.Errorf("aggregate '%v' percentile must be between 0 and 100, got %v", spec.name, spec.percentile)
+		}
+		aggregates = append(aggregates, spec)
+	}
+
+	emit, err := conf.FieldString("emit")
+	if err != nil {
+		return nil, err
+	}
+	targetPath, err := conf.FieldString("target_path")
+	if err != nil {
+		return nil, err
+	}
+	snapshotInterval, err := getDuration(conf, false, "snapshot_interval")
+	if err != nil {
+		return nil, err
+	}
+	ttlDur, err := getDuration(conf, false, "ttl")
+	if err != nil {
+		return nil, err
+	}
+	var ttl *time.Duration
+	if ttlDur > 0 {
+		ttl = &ttlDur
+	}
+
+	return newAggregateProcessor(key, aggregates, emit == "snapshot", targetPath, snapshotInterval, ttl, resource, func(ctx context.Context, fn func(c service.Cache)) error {
+		return mgr.AccessCache(ctx, resource, fn)
+	}, mgr.Logger()), nil
+}
+
+func newAggregateProcessor(
+	key *service.InterpolatedString,
+	aggregates []aggregateSpec,
+	snapshot bool,
+	targetPath string,
+	snapshotInterval time.Duration,
+	ttl *time.Duration,
+	resource string,
+	accessCache func(ctx context.Context, fn func(c service.Cache)) error,
+	log *service.Logger,
+) *aggregateProcessor {
+	return &aggregateProcessor{
+		log:              log,
+		key:              key,
+		aggregates:       aggregates,
+		ttl:              ttl,
+		targetPath:       targetPath,
+		snapshot:         snapshot,
+		snapshotInterval: snapshotInterval,
+		resource:         resource,
+		accessCache:      accessCache,
+		locks:            aggregateLocksAcquire(resource),
+		lastSnapshot:     time.Now(),
+		touched:          map[string]struct{}{},
+	}
+}
+
+func (a *aggregateProcessor) lockKey(key string) func() {
+	l := &a.locks.stripes[xxhash.ChecksumString64(key)%uint64(len(a.locks.stripes))]
+	l.Lock()
+	return l.Unlock
+}
+
+func (a *aggregateProcessor) getStates(ctx context.Context, key string) (map[string]*aggregateState, error) {
+	var data []byte
+	var getErr error
+	if err := a.accessCache(ctx, func(c service.Cache) {
+		data, getErr = c.Get(ctx, key)
+	}); err != nil {
+		return nil, err
+	}
+
+	states := map[string]*aggregateState{}
+	if getErr != nil {
+		if errors.Is(getErr, service.ErrKeyNotFound) {
+			return states, nil
+		}
+		return nil, getErr
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("failed to parse aggregate state: %w", err)
+	}
+	return states, nil
+}
+
+func (a *aggregateProcessor) setStates(ctx context.Context, key string, states map[string]*aggregateState) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	var setErr error
+	if err := a.accessCache(ctx, func(c service.Cache) {
+		setErr = c.Set(ctx, key, data, a.ttl)
+	}); err != nil {
+		return err
+	}
+	return setErr
+}
+
+// aggregateValue extracts the value of an aggregate from a message, which is
+// raw bytes for distinct aggregates and a number for all others.
+func aggregateValue(spec aggregateSpec, i int, batch service.MessageBatch) (num float64, raw []byte, err error) {
+	if spec.value == nil {
+		return
+	}
+	var res *service.Message
+	if res, err = batch.BloblangQuery(i, spec.value); err != nil {
+		return
+	}
+	if res == nil {
+		err = errors.New("mapping deleted the value")
+		return
+	}
+	if spec.aType == "distinct" {
+		raw, err = res.AsBytes()
+		return
+	}
+	var v interface{}
+	if v, err = res.AsStructured(); err != nil {
+		return
+	}
+	num, err = query.IGetNumber(v)
+	return
+}
+
+func (s *aggregateState) update(spec aggregateSpec, num float64, raw []byte) error {
+	s.Count++
+	switch spec.aType {
+	case "sum", "avg":
+		s.Sum += num
+	case "min":
+		if s.Min == nil || num < *s.Min {
+			s.Min = &num
+		}
+	case "max":
+		if s.Max == nil || num > *s.Max {
+			s.Max = &num
+		}
+	case "distinct":
+		hll, err := sketch.NewHyperLogLog(aggregateHLLPrecision)
+		if err != nil {
+			return err
+		}
+		if len(s.HLL) > 0 {
+			if err := hll.UnmarshalBinary(s.HLL); err != nil {
+				return err
+			}
+		}
+		hll.Add(raw)
+		if s.HLL, err = hll.MarshalBinary(); err != nil {
+			return err
+		}
+	case "percentile":
+		if s.Digest == nil {
+			var err error
+			if s.Digest, err = sketch.NewTDigest(aggregateTDigestCompression); err != nil {
+				return err
+			}
+		}
+		s.Digest.Add(num)
+	}
+	return nil
+}
+
+func (s *aggregateState) result(spec aggregateSpec) interface{} {
+	switch spec.aType {
+	case "count":
+		return s.Count
+	case "sum":
+		return s.Sum
+	case "min":
+		if s.Min != nil {
+			return *s.Min
+		}
+	case "max":
+		if s.Max != nil {
+			return *s.Max
+		}
+	case "avg":
+		if s.Count > 0 {
+			return s.Sum / float64(s.Count)
+		}
+	case "distinct":
+		if len(s.HLL) == 0 {
+			return int64(0)
+		}
+		hll := &sketch.HyperLogLog{}
+		if err := hll.UnmarshalBinary(s.HLL); err != nil {
+			return nil
+		}
+		return int64(hll.Count())
+	case "percentile":
+		if s.Digest != nil {
+			if v := s.Digest.Quantile(spec.percentile / 100); !math.IsNaN(v) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func (a *aggregateProcessor) results(states map[string]*aggregateState) map[string]interface{} {
+	results := make(map[string]interface{}, len(a.aggregates))
+	for _, spec := range a.aggregates {
+		s, exists := states[spec.name]
+		if !exists {
+			s = &aggregateState{}
+		}
+		results[spec.name] = s.result(spec)
+	}
+	return results
+}
+
+// update applies the values of a message to the aggregates of its key and
+// returns the resulting aggregates.
+func (a *aggregateProcessor) update(ctx context.Context, i int, batch service.MessageBatch) (string, map[string]interface{}, error) {
+	type extracted struct {
+		num float64
+		raw []byte
+	}
+
+	values := make([]extracted, len(a.aggregates))
+	for j, spec := range a.aggregates {
+		num, raw, err := aggregateValue(spec, i, batch)
+		if err != nil {
+			return "", nil, fmt.Errorf("aggregate '%v' value mapping failed: %w", spec.name, err)
+		}
+		values[j] = extracted{num: num, raw: raw}
+	}
+
+	key := batch.InterpolatedString(i, a.key)
+
+	unlock := a.lockKey(key)
+	defer unlock()
+
+	states, err := a.getStates(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	for j, spec := range a.aggregates {
+		s, exists := states[spec.name]
+		if !exists {
+			s = &aggregateState{}
+			states[spec.name] = s
+		}
+		if err := s.update(spec, values[j].num, values[j].raw); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := a.setStates(ctx, key, states); err != nil {
+		return "", nil, err
+	}
+	return key, a.results(states), nil
+}
+
+// snapshotBatch returns a snapshot of the keys updated since the last snapshot
+// if the snapshot interval has elapsed, or nil otherwise.
+func (a *aggregateProcessor) snapshotBatch(ctx context.Context) service.MessageBatch {
+	a.snapMut.Lock()
+	if len(a.touched) == 0 || time.Since(a.lastSnapshot) < a.snapshotInterval {
+		a.snapMut.Unlock()
+		return nil
+	}
+	a.lastSnapshot = time.Now()
+	touched := a.touched
+	a.touched = map[string]struct{}{}
+	a.snapMut.Unlock()
+
+	keys := make([]string, 0, len(touched))
+	for k := range touched {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var failed []string
+	snapBatch := make(service.MessageBatch, 0, len(keys))
+	for _, k := range keys {
+		unlock := a.lockKey(k)
+		states, err := a.getStates(ctx, k)
+		unlock()
+		if err != nil {
+			a.log.Errorf("Failed to read aggregate state for snapshot: %v", err)
+			failed = append(failed, k)
+			continue
+		}
+		results := a.results(states)
+		results["key"] = k
+
+		msg := service.NewMessage(nil)
+		msg.SetStructured(results)
+		msg.MetaSet("aggregate_key", k)
+		snapBatch = append(snapBatch, msg)
+	}
+
+	if len(failed) > 0 {
+		// Keys that could not be read are retried in the next snapshot.
+		a.snapMut.Lock()
+		for _, k := range failed {
+			a.touched[k] = struct{}{}
+		}
+		a.snapMut.Unlock()
+	}
+	return snapBatch
+}
+
+func (a *aggregateProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	var outBatch service.MessageBatch
+	if !a.snapshot {
+		outBatch = batch.Copy()
+	}
+
+	for i := range batch {
+		key, results, err := a.update(ctx, i, batch)
+		if err != nil {
+			a.log.Debugf("Failed to aggregate message: %v", err)
+			if a.snapshot {
+				errMsg := batch[i].Copy()
+				errMsg.SetError(err)
+				outBatch = append(outBatch, errMsg)
+			} else {
+				outBatch[i].SetError(err)
+			}
+			continue
+		}
+
+		if a.snapshot {
+			a.snapMut.Lock()
+			a.touched[key] = struct{}{}
+			a.snapMut.Unlock()
+			continue
+		}
+
+		v, err := outBatch[i].AsStructuredMut()
+		if err != nil {
+			outBatch[i].SetError(fmt.Errorf("failed to parse message as structured: %w", err))
+			continue
+		}
+		gObj := gabs.Wrap(v)
+		if _, err := gObj.SetP(results, a.targetPath); err != nil {
+			outBatch[i].SetError(fmt.Errorf("failed to set aggregates at path '%v': %w", a.targetPath, err))
+			continue
+		}
+		outBatch[i].SetStructured(gObj.Data())
+	}
+
+	if a.snapshot {
+		outBatch = append(outBatch, a.snapshotBatch(ctx)...)
+	}
+	if len(outBatch) == 0 {
+		return nil, nil
+	}
+	return []service.MessageBatch{outBatch}, nil
+}
+
+// FlushInterval returns the snapshot interval when emitting snapshots, which
+// allows pipelines to flush snapshots whilst no messages are flowing.
+func (a *aggregateProcessor) FlushInterval() time.Duration {
+	if !a.snapshot {
+		return 0
+	}
+	return a.snapshotInterval
+}
+
+// Flush returns a snapshot of the keys updated since the last snapshot if the
+// snapshot interval has elapsed.
+func (a *aggregateProcessor) Flush(ctx context.Context) []service.MessageBatch {
+	if !a.snapshot {
+		return nil
+	}
+	if snapBatch := a.snapshotBatch(ctx); len(snapBatch) > 0 {
+		return []service.MessageBatch{snapBatch}
+	}
+	return nil
+}
+
+func (a *aggregateProcessor) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() {
+		aggregateLocksRelease(a.resource)
+	})
+	return nil
+}
@@ -0,0 +1,293 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/bloblang"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateProcessorConfigs(t *testing.T) {
+	tests := []struct {
+		config           string
+		lintErrContains  string
+		buildErrContains string
+	}{
+		{
+			config: `
+aggregate:
+  resource: foo
+  key: ${! json("id") }
+  aggregates:
+    - name: total
+      type: count
+    - name: p90
+      type: percentile
+      percentile: 90
+      value: root = this.v
+`,
+		},
+		{
+			config: `
+aggregate:
+  resource: foo
+  key: ${! json("id") }
+  aggregates:
+    - name: total
+      type: median
+`,
+			lintErrContains: "median",
+		},
+		{
+			config: `
+aggregate:
+  resource: foo
+  key: ${! json("id") }
+  aggregates:
+    - name: total
+      type: sum
+`,
+			buildErrContains: "requires a value mapping",
+		},
+		{
+			config: `
+aggregate:
+  resource: foo
+  key: ${! json("id") }
+  aggregates:
+    - name: total
+      type: count
+    - name: total
+      type: count
+`,
+			buildErrContains: "is not unique",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			env := service.NewStreamBuilder()
+			require.NoError(t, env.SetLoggerYAML(`level: OFF`))
+			require.NoError(t, env.AddCacheYAML(`
+label: foo
+memory: {}
+`))
+			_, err := env.AddProducerFunc()
+			require.NoError(t, err)
+			require.NoError(t, env.AddConsumerFunc(func(context.Context, *service.Message) error {
+				return nil
+			}))
+
+			err = env.AddProcessorYAML(test.config)
+			if test.lintErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.lintErrContains)
+				return
+			}
+			require.NoError(t, err)
+
+			strm, err := env.Build()
+			require.NoError(t, err)
+
+			cancelledCtx, done := context.WithCancel(context.Background())
+			done()
+			err = strm.Run(cancelledCtx)
+			if test.buildErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.buildErrContains)
+				return
+			}
+			require.EqualError(t, err, "context canceled")
+			require.NoError(t, strm.StopWithin(time.Second))
+		})
+	}
+}
+
+func newTestAggregateProcessor(t testing.TB, cache service.Cache, snapshot bool, specs ...aggregateSpec) *aggregateProcessor {
+	t.Helper()
+
+	key, err := service.NewInterpolatedString(`${! json("id") }`)
+	require.NoError(t, err)
+
+	return newAggregateProcessor(key, specs, snapshot, "stats", 0, nil, t.Name(), func(ctx context.Context, fn func(c service.Cache)) error {
+		fn(cache)
+		return nil
+	}, nil)
+}
+
+func aggregateValueSpec(t testing.TB, name, aType, mapping string, percentile float64) aggregateSpec {
+	t.Helper()
+
+	spec := aggregateSpec{name: name, aType: aType, percentile: percentile}
+	if mapping != "" {
+		var err error
+		spec.value, err = bloblang.Parse(mapping)
+		require.NoError(t, err)
+	}
+	return spec
+}
+
+func TestAggregateProcessorEnrich(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+	proc := newTestAggregateProcessor(t, cache, false,
+		aggregateValueSpec(t, "count", "count", "", 0),
+		aggregateValueSpec(t, "sum", "sum", "root = this.v", 0),
+		aggregateValueSpec(t, "min", "min", "root = this.v", 0),
+		aggregateValueSpec(t, "max", "max", "root = this.v", 0),
+		aggregateValueSpec(t, "avg", "avg", "root = this.v", 0),
+		aggregateValueSpec(t, "distinct", "distinct", "root = this.user", 0),
+		aggregateValueSpec(t, "median", "percentile", "root = this.v", 50),
+	)
+
+	batches, err := proc.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"a","v":10,"user":"x"}`)),
+		service.NewMessage([]byte(`{"id":"b","v":5,"user":"x"}`)),
+		service.NewMessage([]byte(`{"id":"a","v":20,"user":"y"}`)),
+		service.NewMessage([]byte(`{"id":"a","v":"nope","user":"y"}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 4)
+
+	var contents []string
+	for _, m := range batches[0] {
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		contents = append(contents, string(mBytes))
+	}
+	assert.Equal(t, []string{
+		`{"id":"a","stats":{"avg":10,"count":1,"distinct":1,"max":10,"median":10,"min":10,"sum":10},"user":"x","v":10}`,
+		`{"id":"b","stats":{"avg":5,"count":1,"distinct":1,"max":5,"median":5,"min":5,"sum":5},"user":"x","v":5}`,
+		`{"id":"a","stats":{"avg":15,"count":2,"distinct":2,"max":20,"median":15,"min":10,"sum":30},"user":"y","v":20}`,
+		`{"id":"a","v":"nope","user":"y"}`,
+	}, contents)
+
+	require.Error(t, batches[0][3].GetError())
+	assert.Contains(t, batches[0][3].GetError().Error(), "aggregate 'sum' value mapping failed")
+
+	// State is shared via the cache with other instances.
+	other := newTestAggregateProcessor(t, cache, false, aggregateValueSpec(t, "count", "count", "", 0))
+	batches, err = other.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"a"}`)),
+	})
+	require.NoError(t, err)
+	mBytes, err := batches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","stats":{"count":3}}`, string(mBytes))
+}
+
+func TestAggregateProcessorSnapshot(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+	proc := newTestAggregateProcessor(t, cache, true,
+		aggregateValueSpec(t, "count", "count", "", 0),
+		aggregateValueSpec(t, "max", "max", "root = this.v", 0),
+	)
+
+	batches, err := proc.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"b","v":1}`)),
+		service.NewMessage([]byte(`{"id":"a","v":2}`)),
+		service.NewMessage([]byte(`{"id":"b","v":3}`)),
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+
+	var contents []string
+	for _, m := range batches[0] {
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		contents = append(contents, string(mBytes))
+	}
+	assert.Equal(t, []string{
+		`{"count":1,"key":"a","max":2}`,
+		`{"count":2,"key":"b","max":3}`,
+	}, contents)
+	v, _ := batches[0][1].MetaGet("aggregate_key")
+	assert.Equal(t, "b", v)
+
+	proc.snapshotInterval = time.Hour
+	batches, err = proc.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"b","v":1}`)),
+	})
+	require.NoError(t, err)
+	assert.Len(t, batches, 0)
+	assert.Nil(t, proc.Flush(context.Background()))
+
+	// Once the interval has elapsed a flush emits the snapshot without any
+	// further messages.
+	proc.snapMut.Lock()
+	proc.lastSnapshot = time.Now().Add(-time.Hour)
+	proc.snapMut.Unlock()
+
+	assert.Equal(t, time.Hour, proc.FlushInterval())
+	batches = proc.Flush(context.Background())
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 1)
+	mBytes, err := batches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":3,"key":"b","max":3}`, string(mBytes))
+	assert.Nil(t, proc.Flush(context.Background()))
+}
+
+func TestAggregateProcessorSnapshotSharedInstance(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+	proc := newTestAggregateProcessor(t, cache, true, aggregateValueSpec(t, "count", "count", "", 0))
+
+	var wg sync.WaitGroup
+	counts := make([]int64, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				batches, err := proc.ProcessBatch(context.Background(), service.MessageBatch{
+					service.NewMessage([]byte(fmt.Sprintf(`{"id":"%v"}`, j%5))),
+				})
+				require.NoError(t, err)
+				counts[i] += int64(len(batches))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	assert.Greater(t, total, int64(0))
+}
+
+func TestAggregateProcessorParallel(t *testing.T) {
+	cache := &eventWindowTestCache{values: map[string][]byte{}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		proc := newTestAggregateProcessor(t, cache, false, aggregateValueSpec(t, "sum", "sum", "root = this.v", 0))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := proc.ProcessBatch(context.Background(), service.MessageBatch{
+					service.NewMessage([]byte(`{"id":"a","v":1}`)),
+				})
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	proc := newTestAggregateProcessor(t, cache, false, aggregateValueSpec(t, "sum", "sum", "root = 0", 0))
+	batches, err := proc.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":"a"}`)),
+	})
+	require.NoError(t, err)
+	mBytes, err := batches[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","stats":{"sum":1000}}`, string(mBytes))
+}
@@ -0,0 +1,401 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+func sampleSeedField() *service.ConfigField {
+	return service.NewIntField("seed").
+		Description("An optional seed for the pseudo-random selection of messages, which results in deterministic sampling when set. When omitted a random seed is used.").
+		Optional().
+		Advanced()
+}
+
+func sampleRandFromConfig(conf *service.ParsedConfig) (*rand.Rand, error) {
+	seed := time.Now().UnixNano()
+	if conf.Contains("seed") {
+		iSeed, err := conf.FieldInt("seed")
+		if err != nil {
+			return nil, err
+		}
+		seed = int64(iSeed)
+	}
+	return rand.New(rand.NewSource(seed)), nil
+}
+
+// reservoirIndexes returns the sorted indexes of a uniformly random selection
+// of up to k items from a set of n items using reservoir sampling.
+func reservoirIndexes(gen *rand.Rand, n, k int) []int {
+	if k >= n {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	if k <= 0 {
+		return nil
+	}
+
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for i := k; i < n; i++ {
+		if j := gen.Intn(i + 1); j < k {
+			indexes[j] = i
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+//------------------------------------------------------------------------------
+
+func reservoirSampleProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Utility").
+		Summary("Retains a uniformly random sample of a fixed number of messages from each batch, dropping the rest.").
+		Description(`
+This processor uses [reservoir sampling](https://en.wikipedia.org/wiki/Reservoir_sampling) in order to select up to `+"[`size`](#size)"+` messages from each batch where each message has an equal chance of being selected. Batches smaller than the size are retained in full, and the order of the retained messages is preserved.
+
+In order to sample messages from windows of time combine this processor with a [batching policy](/docs/configuration/batching) or a [windowed buffer](/docs/configuration/windowed_processing), where each batch represents a window.`).
+		Field(service.NewIntField("size").
+			Description("The maximum number of messages to retain from each batch.")).
+		Field(sampleSeedField()).
+		Example("Debug Sample", `Here we retain ten random messages from each minute of data consumed from Kafka, which gives a cheap overview of a high volume topic:`,
+			`
+input:
+  kafka:
+    addresses: [ localhost:9092 ]
+    topics: [ clicks ]
+    consumer_group: debug_sampler
+    batching:
+      period: 1m
+
+pipeline:
+  processors:
+    - reservoir_sample:
+        size: 10
+`,
+		)
+}
+
+func stratifiedSampleProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Utility").
+		Summary("Retains a uniformly random sample of messages of each key from each batch, where each key has a quota of messages, dropping the rest.").
+		Description(`
+Messages of each batch are grouped by a key obtained with the `+"[`key`](#key)"+` field, and for each group [reservoir sampling](https://en.wikipedia.org/wiki/Reservoir_sampling) is used in order to select up to the quota of messages of that key, where each message of the group has an equal chance of being selected. The quota of each key is taken from `+"[`quotas`](#quotas)"+`, or `+"[`default_quota`](#default_quota)"+` if the key is not present, and the order of the retained messages is preserved.
+
+This is useful for ensuring that rare keys are represented within a sample, or for limiting the influence of very common keys. In order to sample messages from windows of time combine this processor with a [batching policy](/docs/configuration/batching) or a [windowed buffer](/docs/configuration/windowed_processing), where each batch represents a window.`).
+		Field(service.NewInterpolatedStringField("key").
+			Description("The key to group each message by.").
+			Example(`${! json("country") }`).Example(`${! meta("kafka_key") }`)).
+		Field(service.NewIntMapField("quotas").
+			Description("A map of keys to the maximum number of messages of that key to retain from each batch.").
+			Default(map[string]interface{}{}).
+			Example(map[string]interface{}{"GB": 10, "US": 50})).
+		Field(service.NewIntField("default_quota").
+			Description("The maximum number of messages to retain from each batch for keys that are not present within `quotas`. Setting this to zero drops messages of those keys.").
+			Default(1)).
+		Field(sampleSeedField()).
+		Example("Sample By Status", `Here we retain up to five random log messages of each status code, and every log message with a 500 status, from each batch of a minute of logs. The processor is placed within the batching policy of the output so that it is applied to each batch as a whole:`,
+			`
+output:
+  http_client:
+    url: http://localhost:4195/post
+    batching:
+      period: 1m
+      processors:
+        - stratified_sample:
+            key: ${! json("status") }
+            quotas:
+              "500": 1000000
+            default_quota: 5
+`,
+		)
+}
+
+func intervalSampleProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Version("3.65.0").
+		Categories("Utility").
+		Summary("Retains the first N messages of each interval of time, optionally for each key, dropping the rest.").
+		Description(`
+Time is divided into intervals of a fixed `+"[`interval`](#interval)"+` duration aligned to the unix epoch, and the first `+"[`count`](#count)"+` messages processed within each interval are retained. When a `+"[`key`](#key)"+` is specified each key is counted separately.
+
+The counts of this processor are held in memory and are reset at the start of each interval. When running multiple processing threads each thread has its own counts, and therefore up to the count multiplied by the number of threads could be retained within each interval.`).
+		Field(service.NewIntField("count").
+			Description("The number of messages to retain from each interval.")).
+		Field(service.NewDurationField("interval").
+			Description("The duration of each interval.").
+			Example("1s").Example("1m")).
+		Field(service.NewInterpolatedStringField("key").
+			Description("An optional key to count each message by, where the first messages of each key are retained within each interval.").
+			Optional().
+			Example(`${! json("user_id") }`)).
+		Example("Log Throttling", `Here we retain only the first ten error logs of each service every minute, which prevents noisy services from flooding an alerting system:`,
+			`
+pipeline:
+  processors:
+    - interval_sample:
+        count: 10
+        interval: 1m
+        key: ${! json("service") }
+`,
+		)
+}
+
+func init() {
+	err := service.RegisterBatchProcessor(
+		"reservoir_sample", reservoirSampleProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newReservoirSampleProcessorFromConfig(conf)
+		})
+	if err != nil {
+		panic(err)
+	}
+
+	err = service.RegisterBatchProcessor(
+		"stratified_sample", stratifiedSampleProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newStratifiedSampleProcessorFromConfig(conf)
+		})
+	if err != nil {
+		panic(err)
+	}
+
+	err = service.RegisterBatchProcessor(
+		"interval_sample", intervalSampleProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			return newIntervalSampleProcessorFromConfig(conf)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type reservoirSampleProcessor struct {
+	size int
+
+	mut sync.Mutex
+	gen *rand.Rand
+}
+
+func newReservoirSampleProcessorFromConfig(conf *service.ParsedConfig) (*reservoirSampleProcessor, error) {
+	size, err := conf.FieldInt("size")
+	if err != nil {
+		return nil, err
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("size must be greater than zero, got %v", size)
+	}
+	gen, err := sampleRandFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &reservoirSampleProcessor{size: size, gen: gen}, nil
+}
+
+func (r *reservoirSampleProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	r.mut.Lock()
+	indexes := reservoirIndexes(r.gen, len(batch), r.size)
+	r.mut.Unlock()
+
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	if len(indexes) == len(batch) {
+		return []service.MessageBatch{batch}, nil
+	}
+
+	sampled := make(service.MessageBatch, len(indexes))
+	for i, index := range indexes {
+		sampled[i] = batch[index]
+	}
+	return []service.MessageBatch{sampled}, nil
+}
+
+func (r *reservoirSampleProcessor) Close(ctx context.Context) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+type stratifiedSampleProcessor struct {
+	key          *service.InterpolatedString
+	quotas       map[string]int
+	defaultQuota int
+
+	mut sync.Mutex
+	gen *rand.Rand
+}
+
+func newStratifiedSampleProcessorFromConfig(conf *service.ParsedConfig) (*stratifiedSampleProcessor, error) {
+	key, err := conf.FieldInterpolatedString("key")
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := conf.FieldIntMap("quotas")
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range quotas {
+		if v < 0 {
+			return nil, fmt.Errorf("quota of key '%v' must not be negative, got %v", k, v)
+		}
+	}
+	defaultQuota, err := conf.FieldInt("default_quota")
+	if err != nil {
+		return nil, err
+	}
+	if defaultQuota < 0 {
+		return nil, fmt.Errorf("default_quota must not be negative, got %v", defaultQuota)
+	}
+	gen, err := sampleRandFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &stratifiedSampleProcessor{
+		key:          key,
+		quotas:       quotas,
+		defaultQuota: defaultQuota,
+		gen:          gen,
+	}, nil
+}
+
+func (s *stratifiedSampleProcessor) quotaFor(key string) int {
+	if q, exists := s.quotas[key]; exists {
+		return q
+	}
+	return s.defaultQuota
+}
+
+func (s *stratifiedSampleProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	var keys []string
+	groups := map[string][]int{}
+	for i := range batch {
+		key := batch.InterpolatedString(i, s.key)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var retained []int
+	s.mut.Lock()
+	for _, key := range keys {
+		group := groups[key]
+		for _, j := range reservoirIndexes(s.gen, len(group), s.quotaFor(key)) {
+			retained = append(retained, group[j])
+		}
+	}
+	s.mut.Unlock()
+
+	if len(retained) == 0 {
+		return nil, nil
+	}
+	sort.Ints(retained)
+
+	sampled := make(service.MessageBatch, len(retained))
+	for i, index := range retained {
+		sampled[i] = batch[index]
+	}
+	return []service.MessageBatch{sampled}, nil
+}
+
+func (s *stratifiedSampleProcessor) Close(ctx context.Context) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+type intervalSampleProcessor struct {
+	count    int
+	interval time.Duration
+	key      *service.InterpolatedString
+
+	mut         sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+
+	nowFn func() time.Time
+}
+
+func newIntervalSampleProcessorFromConfig(conf *service.ParsedConfig) (*intervalSampleProcessor, error) {
+	count, err := conf.FieldInt("count")
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("count must be greater than zero, got %v", count)
+	}
+	interval, err := conf.FieldDuration("interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be greater than zero")
+	}
+	var key *service.InterpolatedString
+	if conf.Contains("key") {
+		if key, err = conf.FieldInterpolatedString("key"); err != nil {
+			return nil, err
+		}
+	}
+	return &intervalSampleProcessor{
+		count:    count,
+		interval: interval,
+		key:      key,
+		counts:   map[string]int{},
+		nowFn:    time.Now,
+	}, nil
+}
+
+func (s *intervalSampleProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if windowStart := s.nowFn().Truncate(s.interval); !windowStart.Equal(s.windowStart) {
+		s.windowStart = windowStart
+		s.counts = map[string]int{}
+	}
+
+	var sampled service.MessageBatch
+	for i, msg := range batch {
+		var key string
+		if s.key != nil {
+			key = batch.InterpolatedString(i, s.key)
+		}
+		if s.counts[key] >= s.count {
+			continue
+		}
+		s.counts[key]++
+		sampled = append(sampled, msg)
+	}
+
+	if len(sampled) == 0 {
+		return nil, nil
+	}
+	return []service.MessageBatch{sampled}, nil
+}
+
+func (s *intervalSampleProcessor) Close(ctx context.Context) error {
+	return nil
+}
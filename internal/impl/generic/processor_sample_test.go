@@ -0,0 +1,292 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleProcessorConfigs(t *testing.T) {
+	tests := []struct {
+		config           string
+		lintErrContains  string
+		buildErrContains string
+	}{
+		{
+			config: `
+reservoir_sample:
+  size: 10
+  seed: 5
+`,
+		},
+		{
+			config: `
+reservoir_sample:
+  size: 0
+`,
+			buildErrContains: "size must be greater than zero",
+		},
+		{
+			config: `
+stratified_sample:
+  key: ${! json("id") }
+  quotas:
+    foo: 2
+`,
+		},
+		{
+			config: `
+stratified_sample:
+  key: ${! json("id") }
+  default_quota: -1
+`,
+			buildErrContains: "default_quota must not be negative",
+		},
+		{
+			config: `
+interval_sample:
+  count: 5
+  interval: 1s
+`,
+		},
+		{
+			config: `
+interval_sample:
+  count: 5
+  interval: nope
+`,
+			buildErrContains: "nope",
+		},
+		{
+			config: `
+interval_sample:
+  count: 5
+  interval: 1s
+  nope: true
+`,
+			lintErrContains: "nope",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			env := service.NewStreamBuilder()
+			require.NoError(t, env.SetLoggerYAML(`level: OFF`))
+			_, err := env.AddProducerFunc()
+			require.NoError(t, err)
+			require.NoError(t, env.AddConsumerFunc(func(context.Context, *service.Message) error {
+				return nil
+			}))
+
+			err = env.AddProcessorYAML(test.config)
+			if test.lintErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.lintErrContains)
+				return
+			}
+			require.NoError(t, err)
+
+			strm, err := env.Build()
+			require.NoError(t, err)
+
+			cancelledCtx, done := context.WithCancel(context.Background())
+			done()
+			err = strm.Run(cancelledCtx)
+			if test.buildErrContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.buildErrContains)
+				return
+			}
+			require.EqualError(t, err, "context canceled")
+			require.NoError(t, strm.StopWithin(time.Second))
+		})
+	}
+}
+
+func sampleTestBatch(t testing.TB, contents ...string) service.MessageBatch {
+	t.Helper()
+
+	var batch service.MessageBatch
+	for _, c := range contents {
+		batch = append(batch, service.NewMessage([]byte(c)))
+	}
+	return batch
+}
+
+func sampleTestContents(t testing.TB, batches []service.MessageBatch) []string {
+	t.Helper()
+
+	var contents []string
+	for _, b := range batches {
+		for _, m := range b {
+			mBytes, err := m.AsBytes()
+			require.NoError(t, err)
+			contents = append(contents, string(mBytes))
+		}
+	}
+	return contents
+}
+
+func TestReservoirSampleProcessor(t *testing.T) {
+	conf, err := reservoirSampleProcessorConfig().ParseYAML(`
+size: 3
+seed: 10
+`, nil)
+	require.NoError(t, err)
+
+	var inputs []string
+	for i := 0; i < 20; i++ {
+		inputs = append(inputs, strconv.Itoa(i))
+	}
+
+	var results [][]string
+	for i := 0; i < 2; i++ {
+		proc, err := newReservoirSampleProcessorFromConfig(conf)
+		require.NoError(t, err)
+
+		batches, err := proc.ProcessBatch(context.Background(), sampleTestBatch(t, inputs...))
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+
+		contents := sampleTestContents(t, batches)
+		require.Len(t, contents, 3)
+
+		prev := -1
+		for _, c := range contents {
+			v, err := strconv.Atoi(c)
+			require.NoError(t, err)
+			assert.Greater(t, v, prev, "order should be preserved")
+			prev = v
+		}
+		results = append(results, contents)
+	}
+	assert.Equal(t, results[0], results[1], "seeded samples should be deterministic")
+
+	proc, err := newReservoirSampleProcessorFromConfig(conf)
+	require.NoError(t, err)
+
+	batches, err := proc.ProcessBatch(context.Background(), sampleTestBatch(t, "a", "b"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, sampleTestContents(t, batches))
+}
+
+func TestReservoirIndexesUniform(t *testing.T) {
+	counts := make([]int, 10)
+	gen := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		for _, j := range reservoirIndexes(gen, 10, 2) {
+			counts[j]++
+		}
+	}
+	for i, c := range counts {
+		// Each index is expected to be selected 2000 times.
+		assert.InDelta(t, 2000, c, 200, "index %v", i)
+	}
+}
+
+func TestStratifiedSampleProcessor(t *testing.T) {
+	conf, err := stratifiedSampleProcessorConfig().ParseYAML(`
+key: ${! json("k") }
+quotas:
+  a: 2
+  c: 0
+default_quota: 1
+seed: 3
+`, nil)
+	require.NoError(t, err)
+
+	proc, err := newStratifiedSampleProcessorFromConfig(conf)
+	require.NoError(t, err)
+
+	batches, err := proc.ProcessBatch(context.Background(), sampleTestBatch(t,
+		`{"k":"a","v":0}`,
+		`{"k":"b","v":1}`,
+		`{"k":"a","v":2}`,
+		`{"k":"c","v":3}`,
+		`{"k":"a","v":4}`,
+		`{"k":"b","v":5}`,
+		`{"k":"a","v":6}`,
+	))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+
+	keyCounts := map[string]int{}
+	prev := -1
+	for _, c := range sampleTestContents(t, batches) {
+		var obj struct {
+			K string `json:"k"`
+			V int    `json:"v"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(c), &obj))
+		keyCounts[obj.K]++
+
+		assert.Greater(t, obj.V, prev, "order should be preserved")
+		prev = obj.V
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, keyCounts)
+
+	batches, err = proc.ProcessBatch(context.Background(), sampleTestBatch(t, `{"k":"c"}`))
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestIntervalSampleProcessor(t *testing.T) {
+	conf, err := intervalSampleProcessorConfig().ParseYAML(`
+count: 2
+interval: 1m
+key: ${! json("k") }
+`, nil)
+	require.NoError(t, err)
+
+	proc, err := newIntervalSampleProcessorFromConfig(conf)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 1, 1, 10, 0, 30, 0, time.UTC)
+	proc.nowFn = func() time.Time {
+		return now
+	}
+
+	batches, err := proc.ProcessBatch(context.Background(), sampleTestBatch(t,
+		`{"k":"a","v":0}`,
+		`{"k":"a","v":1}`,
+		`{"k":"b","v":2}`,
+		`{"k":"a","v":3}`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"k":"a","v":0}`,
+		`{"k":"a","v":1}`,
+		`{"k":"b","v":2}`,
+	}, sampleTestContents(t, batches))
+
+	now = now.Add(20 * time.Second)
+	batches, err = proc.ProcessBatch(context.Background(), sampleTestBatch(t,
+		`{"k":"a","v":4}`,
+		`{"k":"b","v":5}`,
+		`{"k":"b","v":6}`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"k":"b","v":5}`,
+	}, sampleTestContents(t, batches))
+
+	// Crossing into the next interval resets the counts.
+	now = now.Add(20 * time.Second)
+	batches, err = proc.ProcessBatch(context.Background(), sampleTestBatch(t,
+		`{"k":"a","v":7}`,
+		`{"k":"a","v":8}`,
+		`{"k":"a","v":9}`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"k":"a","v":7}`,
+		`{"k":"a","v":8}`,
+	}, sampleTestContents(t, batches))
+}
@@ -0,0 +1,278 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/protobuf"
+	"github.com/Jeffail/benthos/v3/public/service"
+
+	// nolint:staticcheck // Ignore SA1019 deprecation warning until we can switch to "google.golang.org/protobuf/types/dynamicpb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func grpcClientConfigSpec() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Network").
+		Version("3.65.0").
+		Field(service.NewStringField("address").
+			Description("The address of the server to connect to.").
+			Example("localhost:50051")).
+		Field(service.NewStringField("method").
+			Description("The fully qualified name of the method to invoke.").
+			Example("helloworld.Greeter/SayHello")).
+		Field(service.NewStringListField("import_paths").
+			Description("A list of directories containing .proto files, including all definitions required for parsing the method invoked. When empty the method is resolved using [server reflection](https://github.com/grpc/grpc/blob/master/doc/server-reflection.md), which must be enabled on the server.").
+			Default([]string{})).
+		Field(service.NewMetadataFilterField("metadata").
+			Description("Determine which (if any) metadata values of messages should be sent as request metadata headers.").
+			Optional()).
+		Field(service.NewDurationField("timeout").
+			Description("The maximum period of time to wait for each RPC to complete.").
+			Default("5s")).
+		Field(service.NewTLSToggledField("tls"))
+}
+
+//------------------------------------------------------------------------------
+
+// grpcClient invokes a single method of a gRPC server, converting JSON messages
+// into requests and responses back into JSON.
+type grpcClient struct {
+	address     string
+	methodName  string
+	descriptors []*desc.FileDescriptor
+	tlsConf     *tls.Config
+	metaFilter  *service.MetadataFilter
+	timeout     time.Duration
+
+	// checkMethod returns an error when a method is of a type that cannot be
+	// invoked by the component using the client.
+	checkMethod func(md *desc.MethodDescriptor) error
+
+	connMut     sync.RWMutex
+	conn        *grpc.ClientConn
+	reflClient  *grpcreflect.Client
+	method      *desc.MethodDescriptor
+	stub        grpcdynamic.Stub
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func newGRPCClientFromConfig(conf *service.ParsedConfig, checkMethod func(md *desc.MethodDescriptor) error) (*grpcClient, error) {
+	c := &grpcClient{
+		checkMethod: checkMethod,
+	}
+
+	var err error
+	if c.address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	if c.methodName, err = conf.FieldString("method"); err != nil {
+		return nil, err
+	}
+	if _, _, err = protobuf.SplitMethodName(c.methodName); err != nil {
+		return nil, err
+	}
+
+	importPaths, err := conf.FieldStringList("import_paths")
+	if err != nil {
+		return nil, err
+	}
+	if len(importPaths) > 0 {
+		if c.descriptors, err = protobuf.LoadDescriptors(importPaths); err != nil {
+			return nil, err
+		}
+		md, err := protobuf.FindMethod(c.methodName, c.descriptors)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkMethod(md); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Contains("metadata") {
+		if c.metaFilter, err = conf.FieldMetadataFilter("metadata"); err != nil {
+			return nil, err
+		}
+	}
+	if c.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		c.tlsConf = tlsConf
+	}
+	return c, nil
+}
+
+// Connect dials the server and resolves the method to invoke, either from the
+// parsed .proto files or using server reflection.
+func (c *grpcClient) Connect(ctx context.Context) error {
+	c.connMut.Lock()
+	defer c.connMut.Unlock()
+
+	if c.conn != nil {
+		return nil
+	}
+
+	ctx, done := context.WithTimeout(ctx, c.timeout)
+	defer done()
+
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if c.tlsConf != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(c.tlsConf)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	conn, err := grpc.DialContext(ctx, c.address, opts...)
+	if err != nil {
+		return err
+	}
+
+	var reflClient *grpcreflect.Client
+	descriptors := c.descriptors
+
+	var md *desc.MethodDescriptor
+	if len(descriptors) > 0 {
+		md, err = protobuf.FindMethod(c.methodName, descriptors)
+	} else {
+		reflClient = grpcreflect.NewClient(context.Background(), rpb.NewServerReflectionClient(conn))
+		if md, err = c.resolveWithReflection(reflClient); err == nil {
+			descriptors = []*desc.FileDescriptor{md.GetFile()}
+		}
+	}
+	if err == nil {
+		err = c.checkMethod(md)
+	}
+	if err != nil {
+		if reflClient != nil {
+			reflClient.Reset()
+		}
+		_ = conn.Close()
+		return err
+	}
+
+	anyResolver := dynamic.AnyResolver(dynamic.NewMessageFactoryWithDefaults(), descriptors...)
+
+	c.conn = conn
+	c.reflClient = reflClient
+	c.method = md
+	c.stub = grpcdynamic.NewStub(conn)
+	c.marshaler = &jsonpb.Marshaler{AnyResolver: anyResolver}
+	c.unmarshaler = &jsonpb.Unmarshaler{AnyResolver: anyResolver}
+	return nil
+}
+
+func (c *grpcClient) resolveWithReflection(reflClient *grpcreflect.Client) (*desc.MethodDescriptor, error) {
+	serviceName, methodName, err := protobuf.SplitMethodName(c.methodName)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := reflClient.ResolveService(serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve service '%v' using server reflection: %w", serviceName, err)
+	}
+	md := svc.FindMethodByName(methodName)
+	if md == nil {
+		return nil, fmt.Errorf("method '%v' was not found within service '%v'", methodName, serviceName)
+	}
+	return md, nil
+}
+
+// active returns the method and stub of the current connection, or
+// service.ErrNotConnected if the client is not connected.
+func (c *grpcClient) active() (*desc.MethodDescriptor, grpcdynamic.Stub, error) {
+	c.connMut.RLock()
+	defer c.connMut.RUnlock()
+
+	if c.conn == nil {
+		return nil, grpcdynamic.Stub{}, service.ErrNotConnected
+	}
+	return c.method, c.stub, nil
+}
+
+// callContext returns a context for invoking an RPC, which carries the
+// metadata of a message as request headers.
+func (c *grpcClient) callContext(ctx context.Context, msg *service.Message) (context.Context, context.CancelFunc) {
+	md := metadata.MD{}
+	_ = c.metaFilter.Walk(msg, func(key, value string) error {
+		md.Append(strings.ToLower(key), value)
+		return nil
+	})
+	if len(md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *grpcClient) requestFromMessage(md *desc.MethodDescriptor, msg *service.Message) (*dynamic.Message, error) {
+	msgBytes, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	req := dynamic.NewMessage(md.GetInputType())
+	if err := req.UnmarshalJSONPB(c.unmarshaler, msgBytes); err != nil {
+		return nil, fmt.Errorf("failed to convert message to %v: %w", md.GetInputType().GetFullyQualifiedName(), err)
+	}
+	return req, nil
+}
+
+func (c *grpcClient) responseBytes(res interface{}) ([]byte, error) {
+	dynRes, ok := res.(*dynamic.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type: %T", res)
+	}
+	return dynRes.MarshalJSONPB(c.marshaler)
+}
+
+// Close terminates the connection to the server, if one is open.
+func (c *grpcClient) Close(ctx context.Context) error {
+	c.connMut.Lock()
+	defer c.connMut.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	if c.reflClient != nil {
+		c.reflClient.Reset()
+		c.reflClient = nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func errMethodNotSupported(md *desc.MethodDescriptor, component string) error {
+	var kind string
+	switch {
+	case md.IsClientStreaming() && md.IsServerStreaming():
+		kind = "bidirectional streaming"
+	case md.IsClientStreaming():
+		kind = "client streaming"
+	case md.IsServerStreaming():
+		kind = "server streaming"
+	default:
+		kind = "unary"
+	}
+	return fmt.Errorf("method '%v' is %v, which is not supported by the %v", md.GetFullyQualifiedName(), kind, component)
+}
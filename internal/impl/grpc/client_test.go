@@ -0,0 +1,215 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/protobuf"
+	"github.com/Jeffail/benthos/v3/public/service"
+
+	// nolint:staticcheck // Ignore SA1019 deprecation warning until we can switch to "google.golang.org/protobuf/types/dynamicpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+const testGreeterProto = `
+syntax = "proto3";
+
+package helloworld;
+
+service Greeter {
+  rpc SayHello (HelloRequest) returns (HelloReply) {}
+  rpc SayHelloStream (stream HelloRequest) returns (HelloReply) {}
+  rpc SayHellos (HelloRequest) returns (stream HelloReply) {}
+  rpc Chat (stream HelloRequest) returns (stream HelloReply) {}
+}
+
+message HelloRequest {
+  string name = 1;
+}
+
+message HelloReply {
+  string message = 1;
+}
+`
+
+// testGreeter is a greeter server implemented with dynamic messages, which
+// records the names of requests received.
+type testGreeter struct {
+	svc *desc.ServiceDescriptor
+
+	mut      sync.Mutex
+	received []string
+	tenants  []string
+}
+
+func (g *testGreeter) record(ctx context.Context, req *dynamic.Message) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	g.received = append(g.received, req.GetFieldByName("name").(string))
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		g.tenants = append(g.tenants, md.Get("tenant")...)
+	}
+}
+
+func (g *testGreeter) reply(msg string) *dynamic.Message {
+	res := dynamic.NewMessage(g.svc.FindMethodByName("SayHello").GetOutputType())
+	res.SetFieldByName("message", msg)
+	return res
+}
+
+func (g *testGreeter) request() *dynamic.Message {
+	return dynamic.NewMessage(g.svc.FindMethodByName("SayHello").GetInputType())
+}
+
+func (g *testGreeter) serviceDesc(t testing.TB) *grpc.ServiceDesc {
+	// Embed the file descriptor within the service metadata in order to
+	// support server reflection.
+	fdBytes, err := proto.Marshal(g.svc.GetFile().AsFileDescriptorProto())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(fdBytes)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return &grpc.ServiceDesc{
+		ServiceName: g.svc.GetFullyQualifiedName(),
+		HandlerType: (*interface{})(nil),
+		Metadata:    buf.Bytes(),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "SayHello",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := g.request()
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				g.record(ctx, req)
+				_ = grpc.SetHeader(ctx, metadata.Pairs("greeter", "test"))
+				return g.reply("hello " + req.GetFieldByName("name").(string)), nil
+			},
+		}},
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "SayHelloStream",
+				ClientStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					for {
+						req := g.request()
+						if err := stream.RecvMsg(req); err != nil {
+							if errors.Is(err, io.EOF) {
+								break
+							}
+							return err
+						}
+						g.record(stream.Context(), req)
+					}
+					return stream.SendMsg(g.reply("received"))
+				},
+			},
+			{
+				StreamName:    "SayHellos",
+				ServerStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					req := g.request()
+					if err := stream.RecvMsg(req); err != nil {
+						return err
+					}
+					g.record(stream.Context(), req)
+					for _, greeting := range []string{"hello", "hi", "hey"} {
+						if err := stream.SendMsg(g.reply(greeting + " " + req.GetFieldByName("name").(string))); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// startTestGreeter starts a greeter server with reflection enabled and returns
+// its address along with a directory containing the .proto file of the
+// service.
+func startTestGreeter(t testing.TB) (*testGreeter, string, string) {
+	t.Helper()
+
+	protoDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(protoDir, "greeter.proto"), []byte(testGreeterProto), 0o644))
+
+	fds, err := protobuf.LoadDescriptors([]string{protoDir})
+	require.NoError(t, err)
+
+	g := &testGreeter{svc: fds[0].FindService("helloworld.Greeter")}
+	require.NotNil(t, g.svc)
+
+	server := grpc.NewServer()
+	server.RegisterService(g.serviceDesc(t), g)
+	reflection.Register(server)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return g, listener.Addr().String(), protoDir
+}
+
+func TestGRPCClientConfigErrors(t *testing.T) {
+	protoDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(protoDir, "greeter.proto"), []byte(testGreeterProto), 0o644))
+
+	tests := map[string]struct {
+		method    string
+		processor bool
+	}{
+		"bad method name":               {method: "SayHello"},
+		"missing method":                {method: "helloworld.Greeter/Nope"},
+		"output server streaming":       {method: "helloworld.Greeter/SayHellos"},
+		"output bidirectional":          {method: "helloworld.Greeter/Chat"},
+		"processor client streaming":    {method: "helloworld.Greeter/SayHelloStream", processor: true},
+		"processor bidirectional":       {method: "helloworld.Greeter/Chat", processor: true},
+		"processor missing service":     {method: "helloworld.Nope/SayHello", processor: true},
+		"processor bad method name":     {method: "nope", processor: true},
+		"processor missing method name": {method: "helloworld.Greeter/", processor: true},
+	}
+
+	for name, test := range tests {
+		conf := `
+address: localhost:50051
+method: ` + test.method + `
+import_paths: [ ` + protoDir + ` ]
+`
+		var err error
+		if test.processor {
+			var pConf *service.ParsedConfig
+			pConf, err = grpcClientProcessorConfig().ParseYAML(conf, nil)
+			require.NoError(t, err, name)
+			_, err = newGRPCClientProcessorFromConfig(pConf)
+		} else {
+			var pConf *service.ParsedConfig
+			pConf, err = grpcClientOutputConfig().ParseYAML(conf, nil)
+			require.NoError(t, err, name)
+			_, err = newGRPCClientWriterFromConfig(pConf)
+		}
+		assert.Error(t, err, name)
+	}
+}
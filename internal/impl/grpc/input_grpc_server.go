@@ -0,0 +1,474 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/protobuf"
+	"github.com/Jeffail/benthos/v3/internal/shutdown"
+	"github.com/Jeffail/benthos/v3/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/public/service"
+
+	// nolint:staticcheck // Ignore SA1019 deprecation warning until we can switch to "google.golang.org/protobuf/types/dynamicpb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func grpcServerInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Network").
+		Version("3.65.0").
+		Summary("Serves a unary or client streaming gRPC method described by .proto files, where each request received is converted into a JSON message.").
+		Description(`
+The method served is found by parsing all .proto files within the directories listed in `+"`import_paths`"+`, and requests are converted to JSON following the [protobuf JSON mapping](https://developers.google.com/protocol-buffers/docs/proto3#json).
+
+For unary methods each request is consumed as a single message and the response is returned once the message has been delivered. For client streaming methods each request of the stream is consumed as a single message, and the response is returned once the stream has been closed by the client and all messages have been delivered.
+
+Server streaming and bidirectional streaming methods are not supported.
+
+### Responses
+
+By default an empty response message is returned for each RPC. It's possible to return a response using [synchronous responses](/docs/guides/sync_responses), where the first response message is converted from JSON into the response type of the method. For client streaming methods the response of the final request of the stream is returned.
+
+Metadata of the response message can be returned as gRPC headers by configuring `+"`sync_response.metadata_headers`"+`.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+`+"```text"+`
+- grpc_server_method
+- grpc_server_peer
+- All request metadata headers (only first values are taken)
+`+"```"+`
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`).
+		Field(service.NewStringField("address").
+			Description("The address to listen on.").
+			Default("0.0.0.0:50051")).
+		Field(service.NewStringField("method").
+			Description("The fully qualified name of the method to serve.").
+			Example("helloworld.Greeter/SayHello")).
+		Field(service.NewStringListField("import_paths").
+			Description("A list of directories containing .proto files, including all definitions required for parsing the method served. Defaults to the current directory when empty.").
+			Default([]string{})).
+		Field(service.NewDurationField("timeout").
+			Description("Timeout for requests. If a consumed message takes longer than this to be delivered the RPC fails, but the message may still be delivered.").
+			Default("5s")).
+		Field(service.NewTLSToggledField("tls").
+			Description("TLS settings for the server, where the certificates listed in `client_certs` are served to clients.").
+			Advanced()).
+		Field(service.NewObjectField("sync_response",
+			service.NewMetadataFilterField("metadata_headers").
+				Description("Determine which (if any) metadata values of the response message should be returned as response headers.").
+				Optional(),
+		).
+			Description("Customise messages returned via [synchronous responses](/docs/guides/sync_responses).").
+			Advanced()).
+		Example("Greeter Server", "Here we serve the method `SayHello` of the classic greeter example, returning a greeting built from the name of each request as a synchronous response:",
+			`
+input:
+  grpc_server:
+    address: 0.0.0.0:50051
+    import_paths: [ ./protos ]
+    method: helloworld.Greeter/SayHello
+
+pipeline:
+  processors:
+    - bloblang: 'root.message = "Hello " + this.name'
+
+output:
+  sync_response: {}
+`)
+}
+
+func init() {
+	err := service.RegisterInput(
+		"grpc_server", grpcServerInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			return newGRPCServerInputFromConfig(conf, mgr.Logger())
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// grpcServerRequest is a message consumed from a request along with a channel
+// that receives the result of delivering it.
+type grpcServerRequest struct {
+	msg     *service.Message
+	resChan chan error
+}
+
+type grpcServerInput struct {
+	address    string
+	timeout    time.Duration
+	tlsConf    *tls.Config
+	metaFilter *service.MetadataFilter
+
+	method      *desc.MethodDescriptor
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	server   *grpc.Server
+	listener net.Listener
+
+	reqChan chan grpcServerRequest
+	shutSig *shutdown.Signaller
+}
+
+func newGRPCServerInputFromConfig(conf *service.ParsedConfig, log *service.Logger) (*grpcServerInput, error) {
+	g := &grpcServerInput{
+		log:     log,
+		reqChan: make(chan grpcServerRequest),
+		shutSig: shutdown.NewSignaller(),
+	}
+
+	var err error
+	if g.address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	methodName, err := conf.FieldString("method")
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err = protobuf.SplitMethodName(methodName); err != nil {
+		return nil, err
+	}
+
+	importPaths, err := conf.FieldStringList("import_paths")
+	if err != nil {
+		return nil, err
+	}
+	descriptors, err := protobuf.LoadDescriptors(importPaths)
+	if err != nil {
+		return nil, err
+	}
+	if g.method, err = protobuf.FindMethod(methodName, descriptors); err != nil {
+		return nil, err
+	}
+	if g.method.IsServerStreaming() {
+		return nil, errMethodNotSupported(g.method, "grpc_server input")
+	}
+
+	anyResolver := dynamic.AnyResolver(dynamic.NewMessageFactoryWithDefaults(), descriptors...)
+	g.marshaler = &jsonpb.Marshaler{AnyResolver: anyResolver}
+	g.unmarshaler = &jsonpb.Unmarshaler{AnyResolver: anyResolver}
+
+	if g.timeout, err = conf.FieldDuration("timeout"); err != nil {
+		return nil, err
+	}
+
+	tlsConf, tlsEnabled, err := conf.FieldTLSToggled("tls")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		if tlsConf == nil || len(tlsConf.Certificates) == 0 {
+			return nil, errors.New("tls requires at least one certificate within client_certs")
+		}
+		g.tlsConf = tlsConf
+	}
+
+	if conf.Contains("sync_response", "metadata_headers") {
+		if g.metaFilter, err = conf.FieldMetadataFilter("sync_response", "metadata_headers"); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (g *grpcServerInput) Connect(ctx context.Context) error {
+	g.connMut.Lock()
+	defer g.connMut.Unlock()
+
+	if g.server != nil {
+		return nil
+	}
+	if g.shutSig.ShouldCloseAtLeisure() {
+		return service.ErrEndOfInput
+	}
+
+	listener, err := net.Listen("tcp", g.address)
+	if err != nil {
+		return err
+	}
+
+	var opts []grpc.ServerOption
+	if g.tlsConf != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(g.tlsConf)))
+	}
+	server := grpc.NewServer(opts...)
+
+	serviceDesc := &grpc.ServiceDesc{
+		ServiceName: g.method.GetService().GetFullyQualifiedName(),
+		HandlerType: (*interface{})(nil),
+		Metadata:    g.method.GetFile().GetName(),
+	}
+	if g.method.IsClientStreaming() {
+		serviceDesc.Streams = []grpc.StreamDesc{{
+			StreamName:    g.method.GetName(),
+			Handler:       g.streamHandler,
+			ClientStreams: true,
+		}}
+	} else {
+		serviceDesc.Methods = []grpc.MethodDesc{{
+			MethodName: g.method.GetName(),
+			Handler:    g.unaryHandler,
+		}}
+	}
+	server.RegisterService(serviceDesc, g)
+
+	g.server, g.listener = server, listener
+	go g.loop(server, listener)
+
+	if g.tlsConf != nil {
+		g.log.Infof("Serving gRPC method %v with TLS at: %v", g.method.GetFullyQualifiedName(), listener.Addr())
+	} else {
+		g.log.Infof("Serving gRPC method %v at: %v", g.method.GetFullyQualifiedName(), listener.Addr())
+	}
+	return nil
+}
+
+func (g *grpcServerInput) loop(server *grpc.Server, listener net.Listener) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-g.shutSig.CloseAtLeisureChan()
+
+		// Stop accepting new RPCs and wait for in flight RPCs to finish, which
+		// are abandoned once we are instructed to close immediately.
+		gracefulStopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(gracefulStopped)
+		}()
+		select {
+		case <-gracefulStopped:
+		case <-g.shutSig.CloseNowChan():
+			server.Stop()
+			<-gracefulStopped
+		}
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		g.log.Errorf("Server error: %v", err)
+	}
+	<-stopped
+	g.shutSig.ShutdownComplete()
+}
+
+func (g *grpcServerInput) messageFromRequest(ctx context.Context, req *dynamic.Message) (*service.Message, error) {
+	data, err := req.MarshalJSONPB(g.marshaler)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to marshal request: %v", err)
+	}
+
+	msg := service.NewMessage(data)
+	msg.MetaSet("grpc_server_method", g.method.GetFullyQualifiedName())
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		msg.MetaSet("grpc_server_peer", p.Addr.String())
+	}
+
+	textMap := opentracing.TextMapCarrier{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			if len(v) > 0 {
+				msg.MetaSet(k, v[0])
+				textMap[k] = v[0]
+			}
+		}
+	}
+
+	msgCtx := msg.Context()
+	if parentCtx, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, textMap); err == nil {
+		span := opentracing.StartSpan("input_grpc_server", opentracing.ChildOf(parentCtx))
+		msgCtx = opentracing.ContextWithSpan(msgCtx, span)
+	}
+	return msg.WithContext(msgCtx), nil
+}
+
+// deliver sends a request through the pipeline and returns the synchronous
+// response, which is nil when none were provided.
+func (g *grpcServerInput) deliver(ctx context.Context, req *dynamic.Message) (types.Part, error) {
+	msg, err := g.messageFromRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	store := roundtrip.NewResultStore()
+	msg = msg.WithContext(context.WithValue(msg.Context(), roundtrip.ResultStoreKey, store))
+
+	ctx, done := context.WithTimeout(ctx, g.timeout)
+	defer done()
+
+	resChan := make(chan error, 1)
+	select {
+	case g.reqChan <- grpcServerRequest{msg: msg, resChan: resChan}:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-g.shutSig.CloseAtLeisureChan():
+		return nil, status.Error(codes.Unavailable, "server closing")
+	}
+
+	select {
+	case err := <-resChan:
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-g.shutSig.CloseNowChan():
+		return nil, status.Error(codes.Unavailable, "server closing")
+	}
+
+	for _, resMsg := range store.Get() {
+		if resMsg.Len() > 0 {
+			return resMsg.Get(0), nil
+		}
+	}
+	return nil, nil
+}
+
+// response converts a synchronous response into the response type of the
+// method, and returns any metadata that should be sent as headers.
+func (g *grpcServerInput) response(part types.Part) (*dynamic.Message, metadata.MD, error) {
+	res := dynamic.NewMessage(g.method.GetOutputType())
+	if part == nil {
+		return res, nil, nil
+	}
+
+	if err := res.UnmarshalJSONPB(g.unmarshaler, part.Get()); err != nil {
+		g.log.Errorf("Failed to convert sync response to %v: %v", g.method.GetOutputType().GetFullyQualifiedName(), err)
+		return nil, nil, status.Errorf(codes.Internal, "failed to convert response: %v", err)
+	}
+
+	md := metadata.MD{}
+	if g.metaFilter != nil {
+		resMsg := service.NewMessage(nil)
+		_ = part.Metadata().Iter(func(k, v string) error {
+			resMsg.MetaSet(k, v)
+			return nil
+		})
+		_ = g.metaFilter.Walk(resMsg, func(k, v string) error {
+			md.Set(strings.ToLower(k), v)
+			return nil
+		})
+	}
+	return res, md, nil
+}
+
+func (g *grpcServerInput) unaryHandler(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := dynamic.NewMessage(g.method.GetInputType())
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	resPart, err := g.deliver(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, md, err := g.response(resPart)
+	if err != nil {
+		return nil, err
+	}
+	if len(md) > 0 {
+		if err := grpc.SetHeader(ctx, md); err != nil {
+			g.log.Warnf("Failed to set response headers: %v", err)
+		}
+	}
+	return res, nil
+}
+
+func (g *grpcServerInput) streamHandler(_ interface{}, stream grpc.ServerStream) error {
+	var resPart types.Part
+	for {
+		req := dynamic.NewMessage(g.method.GetInputType())
+		if err := stream.RecvMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+
+		part, err := g.deliver(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if part != nil {
+			resPart = part
+		}
+	}
+
+	res, md, err := g.response(resPart)
+	if err != nil {
+		return err
+	}
+	if len(md) > 0 {
+		if err := stream.SetHeader(md); err != nil {
+			g.log.Warnf("Failed to set response headers: %v", err)
+		}
+	}
+	return stream.SendMsg(res)
+}
+
+//------------------------------------------------------------------------------
+
+func (g *grpcServerInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	select {
+	case req := <-g.reqChan:
+		return req.msg, func(ctx context.Context, err error) error {
+			select {
+			case req.resChan <- err:
+			default:
+			}
+			return nil
+		}, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-g.shutSig.CloseAtLeisureChan():
+		return nil, nil, service.ErrEndOfInput
+	}
+}
+
+func (g *grpcServerInput) Close(ctx context.Context) error {
+	g.connMut.Lock()
+	started := g.server != nil
+	g.shutSig.CloseAtLeisure()
+	g.connMut.Unlock()
+
+	if !started {
+		g.shutSig.ShutdownComplete()
+	}
+	select {
+	case <-g.shutSig.HasClosedChan():
+	case <-ctx.Done():
+		g.shutSig.CloseNow()
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package grpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/protobuf"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func testGRPCServerInput(t *testing.T, method string) (*grpcServerInput, grpcdynamic.Stub, *desc.MethodDescriptor) {
+	t.Helper()
+
+	protoDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(protoDir, "greeter.proto"), []byte(testGreeterProto), 0o644))
+
+	pConf, err := grpcServerInputConfig().ParseYAML(`
+address: 127.0.0.1:0
+method: `+method+`
+import_paths: [ `+protoDir+` ]
+sync_response:
+  metadata_headers:
+    include_prefixes: [ res_ ]
+`, nil)
+	require.NoError(t, err)
+
+	g, err := newGRPCServerInputFromConfig(pConf, nil)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+	require.NoError(t, g.Connect(ctx))
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+		require.NoError(t, g.Close(ctx))
+	})
+
+	cc, err := grpc.Dial(g.listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cc.Close()
+	})
+
+	fds, err := protobuf.LoadDescriptors([]string{protoDir})
+	require.NoError(t, err)
+	md, err := protobuf.FindMethod(method, fds)
+	require.NoError(t, err)
+
+	return g, grpcdynamic.NewStub(cc), md
+}
+
+// setTestSyncResponse adds a response to the result store of a message as a
+// sync_response output would.
+func setTestSyncResponse(t testing.TB, msg *service.Message, content string, meta map[string]string) {
+	t.Helper()
+
+	store, ok := msg.Context().Value(roundtrip.ResultStoreKey).(roundtrip.ResultStore)
+	require.True(t, ok)
+
+	resMsg := message.New([][]byte{[]byte(content)})
+	for k, v := range meta {
+		resMsg.Get(0).Metadata().Set(k, v)
+	}
+	store.Add(resMsg)
+}
+
+func TestGRPCServerInputUnary(t *testing.T) {
+	g, stub, md := testGRPCServerInput(t, "helloworld.Greeter/SayHello")
+
+	go func() {
+		msg, ackFn, err := g.Read(context.Background())
+		require.NoError(t, err)
+
+		mBytes, err := msg.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"foo"}`, string(mBytes))
+
+		v, _ := msg.MetaGet("grpc_server_method")
+		assert.Equal(t, "helloworld.Greeter.SayHello", v)
+		v, _ = msg.MetaGet("x-custom")
+		assert.Equal(t, "bar", v)
+		v, _ = msg.MetaGet("grpc_server_peer")
+		assert.NotEmpty(t, v)
+
+		setTestSyncResponse(t, msg, `{"message":"hello foo"}`, map[string]string{
+			"res_thing": "baz",
+			"other":     "nope",
+		})
+		require.NoError(t, ackFn(context.Background(), nil))
+	}()
+
+	req := dynamic.NewMessage(md.GetInputType())
+	req.SetFieldByName("name", "foo")
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-custom", "bar")
+
+	var header metadata.MD
+	res, err := stub.InvokeRpc(ctx, md, req, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "hello foo", res.(*dynamic.Message).GetFieldByName("message"))
+	assert.Equal(t, []string{"baz"}, header.Get("res_thing"))
+	assert.Empty(t, header.Get("other"))
+}
+
+func TestGRPCServerInputUnaryNoResponse(t *testing.T) {
+	g, stub, md := testGRPCServerInput(t, "helloworld.Greeter.SayHello")
+
+	go func() {
+		_, ackFn, err := g.Read(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, ackFn(context.Background(), nil))
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	res, err := stub.InvokeRpc(ctx, md, dynamic.NewMessage(md.GetInputType()))
+	require.NoError(t, err)
+	assert.Equal(t, "", res.(*dynamic.Message).GetFieldByName("message"))
+}
+
+func TestGRPCServerInputUnaryError(t *testing.T) {
+	g, stub, md := testGRPCServerInput(t, "helloworld.Greeter/SayHello")
+
+	go func() {
+		_, ackFn, err := g.Read(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, ackFn(context.Background(), assert.AnError))
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	_, err := stub.InvokeRpc(ctx, md, dynamic.NewMessage(md.GetInputType()))
+	require.Error(t, err)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGRPCServerInputClientStream(t *testing.T) {
+	g, stub, md := testGRPCServerInput(t, "helloworld.Greeter/SayHelloStream")
+
+	go func() {
+		for _, name := range []string{"foo", "bar", "baz"} {
+			msg, ackFn, err := g.Read(context.Background())
+			require.NoError(t, err)
+
+			mBytes, err := msg.AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, `{"name":"`+name+`"}`, string(mBytes))
+
+			setTestSyncResponse(t, msg, `{"message":"last was `+name+`"}`, nil)
+			require.NoError(t, ackFn(context.Background(), nil))
+		}
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	stream, err := stub.InvokeRpcClientStream(ctx, md)
+	require.NoError(t, err)
+
+	for _, name := range []string{"foo", "bar", "baz"} {
+		req := dynamic.NewMessage(md.GetInputType())
+		req.SetFieldByName("name", name)
+		require.NoError(t, stream.SendMsg(req))
+	}
+
+	res, err := stream.CloseAndReceive()
+	require.NoError(t, err)
+	assert.Equal(t, "last was baz", res.(*dynamic.Message).GetFieldByName("message"))
+}
+
+func TestGRPCServerInputConfigErrors(t *testing.T) {
+	protoDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(protoDir, "greeter.proto"), []byte(testGreeterProto), 0o644))
+
+	for _, method := range []string{
+		"helloworld.Greeter",
+		"helloworld.Nope/SayHello",
+		"helloworld.Greeter/Nope",
+		"helloworld.Greeter/SayHellos",
+		"helloworld.Greeter/Chat",
+	} {
+		pConf, err := grpcServerInputConfig().ParseYAML(`
+address: 127.0.0.1:0
+method: `+method+`
+import_paths: [ `+protoDir+` ]
+`, nil)
+		require.NoError(t, err, method)
+
+		_, err = newGRPCServerInputFromConfig(pConf, nil)
+		assert.Error(t, err, method)
+	}
+
+	pConf, err := grpcServerInputConfig().ParseYAML(`
+method: helloworld.Greeter/SayHello
+import_paths: [ `+protoDir+` ]
+tls:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newGRPCServerInputFromConfig(pConf, nil)
+	assert.Error(t, err)
+}
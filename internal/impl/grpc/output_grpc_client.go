@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/jhump/protoreflect/desc"
+)
+
+func grpcClientOutputConfig() *service.ConfigSpec {
+	return grpcClientConfigSpec().
+		Summary("Invokes a unary or client streaming gRPC method for each message or batch of messages, where messages are JSON documents converted into the request type of the method.").
+		Description(`
+The method is resolved either by parsing the .proto files within the directories listed in `+"`import_paths`"+`, or when no import paths are specified by querying the server using [server reflection](https://github.com/grpc/grpc/blob/master/doc/server-reflection.md). Messages are converted into requests following the [protobuf JSON mapping](https://developers.google.com/protocol-buffers/docs/proto3#json).
+
+For unary methods an RPC is invoked for each message. For client streaming methods an RPC is invoked for each batch of messages, where each message of the batch is sent as a request of the stream, and therefore it's recommended to configure `+"`batching`"+` when invoking client streaming methods.
+
+Server streaming and bidirectional streaming methods are not supported.`).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of batches to be sending in parallel at any given time.").
+			Default(64)).
+		Field(service.NewBatchPolicyField("batching")).
+		Example("Stream Batches", `Here we send batches of up to 100 messages to a client streaming method of a server with reflection enabled, passing the metadata key `+"`tenant`"+` as a header:`,
+			`
+output:
+  grpc_client:
+    address: localhost:50051
+    method: logs.Collector/Push
+    metadata:
+      include_patterns: [ '^tenant$' ]
+    batching:
+      count: 100
+      period: 1s
+`)
+}
+
+func init() {
+	err := service.RegisterBatchOutput("grpc_client", grpcClientOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (
+			output service.BatchOutput,
+			batchPolicy service.BatchPolicy,
+			maxInFlight int,
+			err error,
+		) {
+			if maxInFlight, err = conf.FieldInt("max_in_flight"); err != nil {
+				return
+			}
+			if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+				return
+			}
+			output, err = newGRPCClientWriterFromConfig(conf)
+			return
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type grpcClientWriter struct {
+	client *grpcClient
+}
+
+func newGRPCClientWriterFromConfig(conf *service.ParsedConfig) (*grpcClientWriter, error) {
+	client, err := newGRPCClientFromConfig(conf, func(md *desc.MethodDescriptor) error {
+		if md.IsServerStreaming() {
+			return errMethodNotSupported(md, "grpc_client output")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClientWriter{client: client}, nil
+}
+
+func (g *grpcClientWriter) Connect(ctx context.Context) error {
+	return g.client.Connect(ctx)
+}
+
+func (g *grpcClientWriter) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	md, stub, err := g.client.active()
+	if err != nil {
+		return err
+	}
+
+	if !md.IsClientStreaming() {
+		for _, msg := range batch {
+			req, err := g.client.requestFromMessage(md, msg)
+			if err != nil {
+				return err
+			}
+			callCtx, done := g.client.callContext(ctx, msg)
+			_, err = stub.InvokeRpc(callCtx, md, req)
+			done()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Request headers of a stream are taken from the first message of the
+	// batch.
+	callCtx, done := g.client.callContext(ctx, batch[0])
+	defer done()
+
+	stream, err := stub.InvokeRpcClientStream(callCtx, md)
+	if err != nil {
+		return err
+	}
+	for _, msg := range batch {
+		req, err := g.client.requestFromMessage(md, msg)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				// The server has terminated the stream, and the reason is
+				// obtained from the response.
+				break
+			}
+			return err
+		}
+	}
+	_, err = stream.CloseAndReceive()
+	return err
+}
+
+func (g *grpcClientWriter) Close(ctx context.Context) error {
+	return g.client.Close(ctx)
+}
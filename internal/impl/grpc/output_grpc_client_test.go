@@ -0,0 +1,100 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGRPCClientWriter(t *testing.T, conf string) *grpcClientWriter {
+	t.Helper()
+
+	pConf, err := grpcClientOutputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	w, err := newGRPCClientWriterFromConfig(pConf)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+	require.NoError(t, w.Connect(ctx))
+
+	t.Cleanup(func() {
+		require.NoError(t, w.Close(context.Background()))
+	})
+	return w
+}
+
+func TestGRPCClientOutputUnary(t *testing.T) {
+	greeter, addr, protoDir := startTestGreeter(t)
+
+	w := testGRPCClientWriter(t, `
+address: `+addr+`
+method: helloworld.Greeter/SayHello
+import_paths: [ `+protoDir+` ]
+metadata:
+  include_prefixes: [ ten ]
+`)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	batch := service.MessageBatch{
+		service.NewMessage([]byte(`{"name":"foo"}`)),
+		service.NewMessage([]byte(`{"name":"bar"}`)),
+	}
+	batch[0].MetaSet("tenant", "acme")
+	batch[1].MetaSet("tenant", "umbrella")
+	require.NoError(t, w.WriteBatch(ctx, batch))
+
+	require.Error(t, w.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`not json`)),
+	}))
+
+	greeter.mut.Lock()
+	assert.Equal(t, []string{"foo", "bar"}, greeter.received)
+	assert.Equal(t, []string{"acme", "umbrella"}, greeter.tenants)
+	greeter.mut.Unlock()
+}
+
+func TestGRPCClientOutputClientStream(t *testing.T) {
+	greeter, addr, _ := startTestGreeter(t)
+
+	w := testGRPCClientWriter(t, `
+address: `+addr+`
+method: helloworld.Greeter/SayHelloStream
+`)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, w.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"name":"foo"}`)),
+		service.NewMessage([]byte(`{"name":"bar"}`)),
+		service.NewMessage([]byte(`{"name":"baz"}`)),
+	}))
+
+	greeter.mut.Lock()
+	assert.Equal(t, []string{"foo", "bar", "baz"}, greeter.received)
+	greeter.mut.Unlock()
+}
+
+func TestGRPCClientOutputNotConnected(t *testing.T) {
+	pConf, err := grpcClientOutputConfig().ParseYAML(`
+address: localhost:50051
+method: helloworld.Greeter/SayHello
+`, nil)
+	require.NoError(t, err)
+
+	w, err := newGRPCClientWriterFromConfig(pConf)
+	require.NoError(t, err)
+
+	err = w.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"name":"foo"}`)),
+	})
+	assert.Equal(t, service.ErrNotConnected, err)
+}
@@ -0,0 +1,3 @@
+// Package grpc contains component implementations for serving and invoking
+// gRPC methods described by .proto files or server reflection.
+package grpc
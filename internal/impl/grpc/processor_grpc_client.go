@@ -0,0 +1,154 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func grpcClientProcessorConfig() *service.ConfigSpec {
+	return grpcClientConfigSpec().
+		Categories("Integration").
+		Summary("Invokes a unary or server streaming gRPC method for each message, where messages are JSON documents converted into the request type of the method, and are replaced with the response converted into JSON.").
+		Description(`
+The method is resolved either by parsing the .proto files within the directories listed in `+"`import_paths`"+`, or when no import paths are specified by querying the server using [server reflection](https://github.com/grpc/grpc/blob/master/doc/server-reflection.md). Messages are converted into requests and responses are converted into messages following the [protobuf JSON mapping](https://developers.google.com/protocol-buffers/docs/proto3#json).
+
+For unary methods each message is replaced with the response of the RPC. For server streaming methods each message is replaced with a message for each response of the stream, and a stream without responses results in the message being removed.
+
+Client streaming and bidirectional streaming methods are not supported.
+
+### Metadata
+
+The response headers of each RPC are added to the resulting messages as metadata, where only the first value of each header is taken.
+
+### Error Handling
+
+When an RPC fails the message is unchanged and is flagged as having failed, which can be handled using [error handling patterns](/docs/configuration/error_handling).`).
+		Example("Enrich Documents", `Here we enrich each document with the response of a lookup method, which is invoked with the field `+"`user_id`"+` of the document:`,
+			`
+pipeline:
+  processors:
+    - branch:
+        request_map: 'root.id = this.user_id'
+        processors:
+          - grpc_client:
+              address: localhost:50051
+              method: users.Directory/GetUser
+              import_paths: [ ./protos ]
+        result_map: 'root.user = this'
+`)
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"grpc_client", grpcClientProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newGRPCClientProcessorFromConfig(conf)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type grpcClientProcessor struct {
+	client *grpcClient
+}
+
+func newGRPCClientProcessorFromConfig(conf *service.ParsedConfig) (*grpcClientProcessor, error) {
+	client, err := newGRPCClientFromConfig(conf, func(md *desc.MethodDescriptor) error {
+		if md.IsClientStreaming() {
+			return errMethodNotSupported(md, "grpc_client processor")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClientProcessor{client: client}, nil
+}
+
+func (g *grpcClientProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	if err := g.client.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	md, stub, err := g.client.active()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := g.client.requestFromMessage(md, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, done := g.client.callContext(ctx, msg)
+	defer done()
+
+	var header metadata.MD
+	if !md.IsServerStreaming() {
+		res, err := stub.InvokeRpc(callCtx, md, req, grpc.Header(&header))
+		if err != nil {
+			return nil, err
+		}
+		resMsg, err := g.responseMessage(msg, header, res)
+		if err != nil {
+			return nil, err
+		}
+		return service.MessageBatch{resMsg}, nil
+	}
+
+	stream, err := stub.InvokeRpcServerStream(callCtx, md, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch service.MessageBatch
+	for {
+		res, err := stream.RecvMsg()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if header == nil {
+			if header, err = stream.Header(); err != nil {
+				return nil, err
+			}
+		}
+		resMsg, err := g.responseMessage(msg, header, res)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, resMsg)
+	}
+	return batch, nil
+}
+
+func (g *grpcClientProcessor) responseMessage(msg *service.Message, header metadata.MD, res interface{}) (*service.Message, error) {
+	resBytes, err := g.client.responseBytes(res)
+	if err != nil {
+		return nil, err
+	}
+
+	resMsg := msg.Copy()
+	resMsg.SetBytes(resBytes)
+	for k, v := range header {
+		if len(v) > 0 {
+			resMsg.MetaSet(k, v[0])
+		}
+	}
+	return resMsg, nil
+}
+
+func (g *grpcClientProcessor) Close(ctx context.Context) error {
+	return g.client.Close(ctx)
+}
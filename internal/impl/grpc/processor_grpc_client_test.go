@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGRPCClientProcessor(t *testing.T, conf string) *grpcClientProcessor {
+	t.Helper()
+
+	pConf, err := grpcClientProcessorConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	proc, err := newGRPCClientProcessorFromConfig(pConf)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, proc.Close(context.Background()))
+	})
+	return proc
+}
+
+func TestGRPCClientProcessorUnary(t *testing.T) {
+	greeter, addr, protoDir := startTestGreeter(t)
+
+	for name, conf := range map[string]string{
+		"import paths": `
+address: ` + addr + `
+method: helloworld.Greeter/SayHello
+import_paths: [ ` + protoDir + ` ]
+metadata:
+  include_prefixes: [ ten ]
+`,
+		"reflection": `
+address: ` + addr + `
+method: helloworld.Greeter.SayHello
+metadata:
+  include_prefixes: [ ten ]
+`,
+	} {
+		proc := testGRPCClientProcessor(t, conf)
+
+		ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+		defer done()
+
+		inMsg := service.NewMessage([]byte(`{"name":"foo"}`))
+		inMsg.MetaSet("tenant", "acme")
+		inMsg.MetaSet("other", "nope")
+
+		batch, err := proc.Process(ctx, inMsg)
+		require.NoError(t, err, name)
+		require.Len(t, batch, 1, name)
+
+		resBytes, err := batch[0].AsBytes()
+		require.NoError(t, err, name)
+		assert.Equal(t, `{"message":"hello foo"}`, string(resBytes), name)
+
+		v, _ := batch[0].MetaGet("greeter")
+		assert.Equal(t, "test", v, name)
+		v, _ = batch[0].MetaGet("tenant")
+		assert.Equal(t, "acme", v, name)
+	}
+
+	greeter.mut.Lock()
+	assert.Equal(t, []string{"foo", "foo"}, greeter.received)
+	assert.Equal(t, []string{"acme", "acme"}, greeter.tenants)
+	greeter.mut.Unlock()
+}
+
+func TestGRPCClientProcessorServerStream(t *testing.T) {
+	_, addr, _ := startTestGreeter(t)
+
+	proc := testGRPCClientProcessor(t, `
+address: `+addr+`
+method: helloworld.Greeter/SayHellos
+`)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	batch, err := proc.Process(ctx, service.NewMessage([]byte(`{"name":"bar"}`)))
+	require.NoError(t, err)
+
+	var results []string
+	for _, msg := range batch {
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		results = append(results, string(b))
+	}
+	assert.Equal(t, []string{
+		`{"message":"hello bar"}`,
+		`{"message":"hi bar"}`,
+		`{"message":"hey bar"}`,
+	}, results)
+}
+
+func TestGRPCClientProcessorErrors(t *testing.T) {
+	_, addr, _ := startTestGreeter(t)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	proc := testGRPCClientProcessor(t, `
+address: `+addr+`
+method: helloworld.Greeter/SayHello
+`)
+	_, err := proc.Process(ctx, service.NewMessage([]byte(`{"nope":"bar"}`)))
+	assert.Error(t, err)
+
+	proc = testGRPCClientProcessor(t, `
+address: `+addr+`
+method: helloworld.Greeter/SayHelloStream
+`)
+	_, err = proc.Process(ctx, service.NewMessage([]byte(`{"name":"bar"}`)))
+	assert.EqualError(t, err, "method 'helloworld.Greeter.SayHelloStream' is client streaming, which is not supported by the grpc_client processor")
+
+	proc = testGRPCClientProcessor(t, `
+address: `+addr+`
+method: helloworld.Nope/SayHello
+`)
+	_, err = proc.Process(ctx, service.NewMessage([]byte(`{"name":"bar"}`)))
+	assert.Error(t, err)
+}
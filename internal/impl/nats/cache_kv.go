@@ -0,0 +1,229 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/impl/nats/auth"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+)
+
+func natsKVCacheConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("3.65.0").
+		Summary("Use a NATS JetStream key-value bucket as a cache.").
+		Description(`
+The bucket must already exist. Values are stored without a TTL, as the max age of values is a property of the bucket.
+
+The ` + "`add`" + ` operation only succeeds when a key has no value, or when its latest revision is a delete marker, which makes it safe to use for deduplication across multiple Benthos instances.
+
+` + auth.Description()).
+		Field(urlsField()).
+		Field(bucketField()).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewInternalField(auth.FieldSpec()))
+}
+
+func init() {
+	err := service.RegisterCache(
+		"nats_kv", natsKVCacheConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Cache, error) {
+			return newKVCacheFromConfig(conf, mgr.Logger())
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type kvCache struct {
+	connDetails connectionDetails
+	bucket      string
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	js       nats.JetStreamContext
+	kv       nats.KeyValue
+}
+
+// The JetStream API error code returned when a publish with an expected last
+// subject sequence doesn't match the latest revision of the key.
+const jsErrCodeStreamWrongLastSequence = 10071
+
+func newKVCacheFromConfig(conf *service.ParsedConfig, log *service.Logger) (*kvCache, error) {
+	c := kvCache{
+		log: log,
+	}
+
+	var err error
+	if c.connDetails, err = connectionDetailsFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if c.bucket, err = conf.FieldString("bucket"); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// getKV returns the bucket, connecting to NATS if a connection has not yet
+// been established.
+func (c *kvCache) getKV() (nats.KeyValue, error) {
+	_, _, kv, err := c.getConn()
+	return kv, err
+}
+
+func (c *kvCache) getConn() (*nats.Conn, nats.JetStreamContext, nats.KeyValue, error) {
+	c.connMut.Lock()
+	defer c.connMut.Unlock()
+
+	if c.kv != nil {
+		return c.natsConn, c.js, c.kv, nil
+	}
+
+	natsConn, err := c.connDetails.connect()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		natsConn.Close()
+		return nil, nil, nil, err
+	}
+
+	kv, err := js.KeyValue(c.bucket)
+	if err != nil {
+		natsConn.Close()
+		return nil, nil, nil, err
+	}
+
+	c.natsConn = natsConn
+	c.js = js
+	c.kv = kv
+	return natsConn, js, kv, nil
+}
+
+func (c *kvCache) Get(ctx context.Context, key string) ([]byte, error) {
+	kv, err := c.getKV()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := kv.Get(key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			err = service.ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return entry.Value(), nil
+}
+
+func (c *kvCache) Set(ctx context.Context, key string, value []byte, _ *time.Duration) error {
+	kv, err := c.getKV()
+	if err != nil {
+		return err
+	}
+
+	_, err = kv.Put(key, value)
+	return err
+}
+
+// Add is implemented in the same way as the Create method of the bucket, but
+// publishes with the context and checks the error code of the response, which
+// isn't exposed by the client.
+func (c *kvCache) Add(ctx context.Context, key string, value []byte, _ *time.Duration) error {
+	natsConn, js, _, err := c.getConn()
+	if err != nil {
+		return err
+	}
+
+	subject := "$KV." + c.bucket + "." + key
+	if err = c.publishExpected(ctx, natsConn, subject, value, 0); !errors.Is(err, service.ErrKeyAlreadyExists) {
+		return err
+	}
+
+	// The latest revision of the key might be a delete marker, in which case
+	// the key has no value and can be written.
+	lastMsgGetter, ok := js.(interface {
+		GetLastMsg(name, subject string, opts ...nats.JSOpt) (*nats.RawStreamMsg, error)
+	})
+	if !ok {
+		return err
+	}
+	m, gErr := lastMsgGetter.GetLastMsg("KV_"+c.bucket, subject, nats.Context(ctx))
+	if gErr != nil {
+		return err
+	}
+	if op := m.Header.Get("KV-Operation"); op != "DEL" && op != "PURGE" {
+		return err
+	}
+	return c.publishExpected(ctx, natsConn, subject, value, m.Sequence)
+}
+
+// publishExpected publishes a value to the subject of a key only if the latest
+// revision of the key matches, returning service.ErrKeyAlreadyExists when it
+// doesn't.
+func (c *kvCache) publishExpected(ctx context.Context, natsConn *nats.Conn, subject string, value []byte, revision uint64) error {
+	m := nats.NewMsg(subject)
+	m.Data = value
+	m.Header.Set(nats.ExpectedLastSubjSeqHdr, strconv.FormatUint(revision, 10))
+
+	resp, err := natsConn.RequestMsgWithContext(ctx, m)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			err = nats.ErrNoStreamResponse
+		}
+		return err
+	}
+
+	var pubAck struct {
+		Error *struct {
+			ErrorCode   int    `json:"err_code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Data, &pubAck); err != nil {
+		return nats.ErrInvalidJSAck
+	}
+	if pubAck.Error != nil {
+		if pubAck.Error.ErrorCode == jsErrCodeStreamWrongLastSequence {
+			return service.ErrKeyAlreadyExists
+		}
+		return fmt.Errorf("nats: %s", pubAck.Error.Description)
+	}
+	return nil
+}
+
+func (c *kvCache) Delete(ctx context.Context, key string) error {
+	kv, err := c.getKV()
+	if err != nil {
+		return err
+	}
+	return kv.Delete(key)
+}
+
+func (c *kvCache) Close(ctx context.Context) error {
+	c.connMut.Lock()
+	defer c.connMut.Unlock()
+
+	if c.natsConn != nil {
+		c.natsConn.Close()
+		c.natsConn = nil
+	}
+	c.js = nil
+	c.kv = nil
+	return nil
+}
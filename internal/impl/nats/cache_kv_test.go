@@ -0,0 +1,53 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVCacheAdd(t *testing.T) {
+	url, js := runJetStreamServer(t)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "cache_add"})
+	require.NoError(t, err)
+
+	conf, err := natsKVCacheConfig().ParseYAML(`
+urls: [ `+url+` ]
+bucket: cache_add
+`, nil)
+	require.NoError(t, err)
+
+	c, err := newKVCacheFromConfig(conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close(context.Background()))
+	})
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, c.Add(ctx, "foo", []byte("first"), nil))
+	assert.Equal(t, service.ErrKeyAlreadyExists, c.Add(ctx, "foo", []byte("second"), nil))
+
+	v, err := c.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(v))
+
+	// Keys with a delete marker as their latest revision can be added again.
+	require.NoError(t, kv.Delete("foo"))
+	require.NoError(t, c.Add(ctx, "foo", []byte("third"), nil))
+
+	v, err = c.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(v))
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, c.Add(cancelledCtx, "bar", []byte("nope"), nil))
+}
@@ -0,0 +1,269 @@
+package nats
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/impl/nats/auth"
+	"github.com/Jeffail/benthos/v3/internal/shutdown"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+)
+
+func natsKVInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("3.65.0").
+		Summary("Watches a NATS JetStream key-value bucket for changes, consuming each change as a message.").
+		Description(`
+The bucket must already exist. By default the latest value of each key matching ` + "`key`" + ` is consumed first, followed by any changes made to those keys. Watching a bucket does not track which changes have been delivered, therefore when the input reconnects or restarts the latest value of each key is consumed again.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```text" + `
+- nats_kv_key
+- nats_kv_bucket
+- nats_kv_revision
+- nats_kv_delta
+- nats_kv_operation
+- nats_kv_created
+` + "```" + `
+
+The operation is one of ` + "`PUT`" + `, ` + "`DEL`" + ` or ` + "`PURGE`" + `, where the message of a delete or purge has an empty body.
+
+You can access these metadata fields using
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+` + auth.Description()).
+		Field(urlsField()).
+		Field(bucketField()).
+		Field(service.NewStringField("key").
+			Description("A key to watch for changes, which supports the wildcards `*` and `>`.").
+			Default(">").
+			Example("foo.bar.baz").Example("foo.*.baz").Example("foo.>")).
+		Field(service.NewBoolField("ignore_deletes").
+			Description("Do not consume delete and purge operations.").
+			Default(false)).
+		Field(service.NewBoolField("include_history").
+			Description("Consume all historical values of each key that are retained by the bucket, rather than only the latest.").
+			Default(false)).
+		Field(service.NewBoolField("updates_only").
+			Description("Only consume changes that occur after the watch begins, skipping the existing values of the bucket.").
+			Default(false)).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewInternalField(auth.FieldSpec()))
+}
+
+func init() {
+	err := service.RegisterInput(
+		"nats_kv", natsKVInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			reader, err := newKVReaderFromConfig(conf, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacks(reader), nil
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type kvReader struct {
+	connDetails    connectionDetails
+	bucket         string
+	key            string
+	ignoreDeletes  bool
+	includeHistory bool
+	updatesOnly    bool
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	watcher  nats.KeyWatcher
+
+	// Whether the initial values of the current watcher have been received.
+	initDone bool
+
+	shutSig *shutdown.Signaller
+}
+
+func newKVReaderFromConfig(conf *service.ParsedConfig, log *service.Logger) (*kvReader, error) {
+	r := kvReader{
+		log:     log,
+		shutSig: shutdown.NewSignaller(),
+	}
+
+	var err error
+	if r.connDetails, err = connectionDetailsFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if r.bucket, err = conf.FieldString("bucket"); err != nil {
+		return nil, err
+	}
+	if r.key, err = conf.FieldString("key"); err != nil {
+		return nil, err
+	}
+	if r.ignoreDeletes, err = conf.FieldBool("ignore_deletes"); err != nil {
+		return nil, err
+	}
+	if r.includeHistory, err = conf.FieldBool("include_history"); err != nil {
+		return nil, err
+	}
+	if r.updatesOnly, err = conf.FieldBool("updates_only"); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (r *kvReader) Connect(ctx context.Context) error {
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+
+	if r.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var err error
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			natsConn.Close()
+		}
+	}()
+
+	if natsConn, err = r.connDetails.connect(); err != nil {
+		return err
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return err
+	}
+
+	kv, err := js.KeyValue(r.bucket)
+	if err != nil {
+		return err
+	}
+
+	var opts []nats.WatchOpt
+	if r.ignoreDeletes {
+		opts = append(opts, nats.IgnoreDeletes())
+	}
+	if r.includeHistory {
+		opts = append(opts, nats.IncludeHistory())
+	}
+
+	watcher, err := kv.Watch(r.key, opts...)
+	if err != nil {
+		return err
+	}
+
+	r.log.Infof("Watching NATS key-value bucket %v for changes to key: %v", r.bucket, r.key)
+
+	r.natsConn = natsConn
+	r.watcher = watcher
+	r.initDone = false
+	return nil
+}
+
+func (r *kvReader) disconnect() {
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+
+	if r.watcher != nil {
+		_ = r.watcher.Stop()
+		r.watcher = nil
+	}
+	if r.natsConn != nil {
+		r.natsConn.Close()
+		r.natsConn = nil
+	}
+}
+
+func (r *kvReader) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	r.connMut.Lock()
+	watcher := r.watcher
+	r.connMut.Unlock()
+	if watcher == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	for {
+		var entry nats.KeyValueEntry
+		var open bool
+		select {
+		case entry, open = <-watcher.Updates():
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if !open {
+			r.disconnect()
+			return nil, nil, service.ErrNotConnected
+		}
+
+		// A nil entry marks the end of the initial values of the bucket.
+		if entry == nil {
+			r.connMut.Lock()
+			r.initDone = true
+			r.connMut.Unlock()
+			continue
+		}
+
+		if r.updatesOnly {
+			r.connMut.Lock()
+			initDone := r.initDone
+			r.connMut.Unlock()
+			if !initDone {
+				continue
+			}
+		}
+		return newMessageFromKVEntry(entry), func(ctx context.Context, res error) error {
+			return nil
+		}, nil
+	}
+}
+
+func newMessageFromKVEntry(entry nats.KeyValueEntry) *service.Message {
+	msg := service.NewMessage(entry.Value())
+	msg.MetaSet("nats_kv_key", entry.Key())
+	msg.MetaSet("nats_kv_bucket", entry.Bucket())
+	msg.MetaSet("nats_kv_revision", strconv.FormatUint(entry.Revision(), 10))
+	msg.MetaSet("nats_kv_delta", strconv.FormatUint(entry.Delta(), 10))
+	msg.MetaSet("nats_kv_created", entry.Created().Format(time.RFC3339Nano))
+
+	switch entry.Operation() {
+	case nats.KeyValueDelete:
+		msg.MetaSet("nats_kv_operation", "DEL")
+	case nats.KeyValuePurge:
+		msg.MetaSet("nats_kv_operation", "PURGE")
+	default:
+		msg.MetaSet("nats_kv_operation", "PUT")
+	}
+	return msg
+}
+
+func (r *kvReader) Close(ctx context.Context) error {
+	go func() {
+		r.disconnect()
+		r.shutSig.ShutdownComplete()
+	}()
+	select {
+	case <-r.shutSig.HasClosedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
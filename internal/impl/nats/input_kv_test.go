@@ -0,0 +1,104 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVInputWatch(t *testing.T) {
+	url, js := runJetStreamServer(t)
+
+	tests := []struct {
+		name     string
+		conf     string
+		expected []string
+	}{
+		{
+			name:     "latest values",
+			conf:     ``,
+			expected: []string{"PUT foo.a second", "PUT foo.b third", "DEL foo.a "},
+		},
+		{
+			name:     "include history",
+			conf:     `include_history: true`,
+			expected: []string{"PUT foo.a first", "PUT foo.a second", "PUT foo.b third", "DEL foo.a "},
+		},
+		{
+			name:     "updates only",
+			conf:     `updates_only: true`,
+			expected: []string{"PUT foo.b third", "DEL foo.a "},
+		},
+		{
+			name:     "ignore deletes",
+			conf:     `ignore_deletes: true`,
+			expected: []string{"PUT foo.a second", "PUT foo.b third"},
+		},
+	}
+
+	for i, test := range tests {
+		test := test
+		bucket := fmt.Sprintf("things_%v", i)
+		t.Run(test.name, func(t *testing.T) {
+			kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, History: 5})
+			require.NoError(t, err)
+
+			_, err = kv.PutString("foo.a", "first")
+			require.NoError(t, err)
+			_, err = kv.PutString("foo.a", "second")
+			require.NoError(t, err)
+			_, err = kv.PutString("bar.a", "ignored")
+			require.NoError(t, err)
+
+			ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+			defer done()
+
+			conf, err := natsKVInputConfig().ParseYAML(`
+urls: [ `+url+` ]
+bucket: `+bucket+`
+key: foo.>
+`+test.conf, nil)
+			require.NoError(t, err)
+
+			r, err := newKVReaderFromConfig(conf, nil)
+			require.NoError(t, err)
+			require.NoError(t, r.Connect(ctx))
+			t.Cleanup(func() {
+				assert.NoError(t, r.Close(context.Background()))
+			})
+
+			// Allow the initial values to be delivered before making changes.
+			time.Sleep(time.Millisecond * 500)
+
+			_, err = kv.PutString("foo.b", "third")
+			require.NoError(t, err)
+			require.NoError(t, kv.Delete("foo.a"))
+
+			var actual []string
+			for len(actual) < len(test.expected) {
+				msg, ackFn, err := r.Read(ctx)
+				require.NoError(t, err)
+				require.NoError(t, ackFn(ctx, nil))
+
+				op, _ := msg.MetaGet("nats_kv_operation")
+				key, _ := msg.MetaGet("nats_kv_key")
+				b, err := msg.AsBytes()
+				require.NoError(t, err)
+				actual = append(actual, op+" "+key+" "+string(b))
+
+				bucketMeta, _ := msg.MetaGet("nats_kv_bucket")
+				assert.Equal(t, bucket, bucketMeta)
+				for _, k := range []string{"nats_kv_revision", "nats_kv_delta", "nats_kv_created"} {
+					v, _ := msg.MetaGet(k)
+					assert.NotEmpty(t, v, k)
+				}
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
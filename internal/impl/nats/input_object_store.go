@@ -0,0 +1,305 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/impl/nats/auth"
+	"github.com/Jeffail/benthos/v3/internal/shutdown"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+)
+
+func natsObjectStoreInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("3.65.0").
+		Summary("Reads objects from a NATS JetStream object store bucket, consuming each object as a message.").
+		Description(`
+The bucket must already exist. All objects of the bucket are consumed, after which the input shuts down unless ` + "`watch`" + ` is enabled, in which case objects that are put into the bucket afterwards are also consumed.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```text" + `
+- nats_object_name
+- nats_object_bucket
+- nats_object_size
+- nats_object_digest
+- nats_object_modified
+- All object headers (only first values are taken)
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+` + auth.Description()).
+		Field(urlsField()).
+		Field(bucketField()).
+		Field(service.NewBoolField("watch").
+			Description("Continue to consume objects as they are put into the bucket once all existing objects have been consumed.").
+			Default(false)).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewInternalField(auth.FieldSpec()))
+}
+
+func init() {
+	err := service.RegisterInput(
+		"nats_object_store", natsObjectStoreInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			reader, err := newObjectStoreReaderFromConfig(conf, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacks(reader), nil
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type objectStoreReader struct {
+	connDetails connectionDetails
+	bucket      string
+	watch       bool
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	js       nats.JetStreamContext
+	obs      nats.ObjectStore
+	watcher  nats.ObjectWatcher
+
+	shutSig *shutdown.Signaller
+}
+
+func newObjectStoreReaderFromConfig(conf *service.ParsedConfig, log *service.Logger) (*objectStoreReader, error) {
+	r := objectStoreReader{
+		log:     log,
+		shutSig: shutdown.NewSignaller(),
+	}
+
+	var err error
+	if r.connDetails, err = connectionDetailsFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if r.bucket, err = conf.FieldString("bucket"); err != nil {
+		return nil, err
+	}
+	if r.watch, err = conf.FieldBool("watch"); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (r *objectStoreReader) Connect(ctx context.Context) error {
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+
+	if r.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var err error
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			natsConn.Close()
+		}
+	}()
+
+	if natsConn, err = r.connDetails.connect(); err != nil {
+		return err
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return err
+	}
+
+	obs, err := js.ObjectStore(r.bucket)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := obs.Watch(nats.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+
+	r.log.Infof("Reading objects from NATS object store bucket: %v", r.bucket)
+
+	r.natsConn = natsConn
+	r.js = js
+	r.obs = obs
+	r.watcher = watcher
+	return nil
+}
+
+func (r *objectStoreReader) disconnect() {
+	r.connMut.Lock()
+	defer r.connMut.Unlock()
+
+	if r.watcher != nil {
+		_ = r.watcher.Stop()
+		r.watcher = nil
+	}
+	if r.natsConn != nil {
+		r.natsConn.Close()
+		r.natsConn = nil
+	}
+	r.js = nil
+	r.obs = nil
+}
+
+// The period after which reading the chunks of an object is abandoned if no
+// chunk has arrived and the object has since been deleted or replaced.
+const objectChunkCheckPeriod = time.Second * 5
+
+var errObjectDigestMismatch = errors.New("received a corrupt object, digests do not match")
+
+// readObject reads the chunks of an object through an ordered consumer of the
+// chunks subject and verifies the digest of the result. The object store
+// client of nats.go isn't used as its chunk reader isn't safe under the race
+// detector.
+func (r *objectStoreReader) readObject(ctx context.Context, js nats.JetStreamContext, obs nats.ObjectStore, info *nats.ObjectInfo) ([]byte, error) {
+	if info.Size == 0 {
+		return []byte{}, nil
+	}
+
+	sub, err := js.SubscribeSync(fmt.Sprintf("$O.%v.C.%v", info.Bucket, info.NUID), nats.OrderedConsumer())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	data := make([]byte, 0, info.Size)
+	h := sha256.New()
+	for read := uint32(0); read < info.Chunks; {
+		chunkCtx, done := context.WithTimeout(ctx, objectChunkCheckPeriod)
+		m, err := sub.NextMsgWithContext(chunkCtx)
+		done()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return nil, err
+			}
+			// The chunks of an object that was deleted or replaced whilst we
+			// were reading it are purged, in which case we stop waiting.
+			current, err := obs.GetInfo(info.Name)
+			if err != nil {
+				return nil, err
+			}
+			if current.NUID != info.NUID {
+				return nil, nats.ErrObjectNotFound
+			}
+			continue
+		}
+		data = append(data, m.Data...)
+		_, _ = h.Write(m.Data)
+		read++
+	}
+
+	digest, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(info.Digest, "sha-256="))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h.Sum(nil), digest) {
+		return nil, errObjectDigestMismatch
+	}
+	return data, nil
+}
+
+func (r *objectStoreReader) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	r.connMut.Lock()
+	js, obs, watcher := r.js, r.obs, r.watcher
+	r.connMut.Unlock()
+	if watcher == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	for {
+		var info *nats.ObjectInfo
+		var open bool
+		select {
+		case info, open = <-watcher.Updates():
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if !open {
+			r.disconnect()
+			return nil, nil, service.ErrNotConnected
+		}
+
+		// A nil info marks the end of the existing objects of the bucket.
+		if info == nil {
+			if !r.watch {
+				return nil, nil, service.ErrEndOfInput
+			}
+			continue
+		}
+
+		// Links refer to objects that are consumed separately.
+		if info.Opts != nil && info.Opts.Link != nil {
+			continue
+		}
+
+		data, err := r.readObject(ctx, js, obs, info)
+		if err != nil {
+			if err == nats.ErrObjectNotFound {
+				// The object was deleted or replaced since it was put.
+				continue
+			}
+			return nil, nil, err
+		}
+
+		msg := service.NewMessage(data)
+		msg.MetaSet("nats_object_name", info.Name)
+		msg.MetaSet("nats_object_bucket", info.Bucket)
+		msg.MetaSet("nats_object_size", strconv.FormatUint(info.Size, 10))
+		msg.MetaSet("nats_object_digest", info.Digest)
+		msg.MetaSet("nats_object_modified", info.ModTime.Format(time.RFC3339Nano))
+		for k, v := range info.Headers {
+			if len(v) > 0 {
+				msg.MetaSet(k, v[0])
+			}
+		}
+		return msg, func(ctx context.Context, res error) error {
+			return nil
+		}, nil
+	}
+}
+
+func (r *objectStoreReader) Close(ctx context.Context) error {
+	go func() {
+		r.disconnect()
+		r.shutSig.ShutdownComplete()
+	}()
+	select {
+	case <-r.shutSig.HasClosedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
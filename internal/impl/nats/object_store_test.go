@@ -0,0 +1,144 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectStoreOutputInput(t *testing.T) {
+	url, js := runJetStreamServer(t)
+
+	_, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "blobs"})
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+	defer done()
+
+	outConf, err := natsObjectStoreOutputConfig().ParseYAML(`
+urls: [ `+url+` ]
+bucket: blobs
+name: ${! meta("name") }
+headers:
+  include_prefixes: [ "x_" ]
+`, nil)
+	require.NoError(t, err)
+
+	w, err := newObjectStoreWriterFromConfig(outConf, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, w.Close(context.Background()))
+	})
+
+	// Exceeds the default chunk size in order to test objects of many chunks.
+	largeBody := bytes.Repeat([]byte("abcdefgh"), 100000)
+
+	msg := service.NewMessage([]byte("hello world"))
+	msg.MetaSet("name", "small")
+	msg.MetaSet("x_thing", "foo")
+	msg.MetaSet("other", "bar")
+	require.NoError(t, w.Write(ctx, msg))
+
+	msg = service.NewMessage(largeBody)
+	msg.MetaSet("name", "large")
+	require.NoError(t, w.Write(ctx, msg))
+
+	inConf, err := natsObjectStoreInputConfig().ParseYAML(`
+urls: [ `+url+` ]
+bucket: blobs
+`, nil)
+	require.NoError(t, err)
+
+	r, err := newObjectStoreReaderFromConfig(inConf, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, r.Close(context.Background()))
+	})
+
+	msg, ackFn, err := r.Read(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ackFn(ctx, nil))
+
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+
+	for k, v := range map[string]string{
+		"nats_object_name":   "small",
+		"nats_object_bucket": "blobs",
+		"nats_object_size":   "11",
+	} {
+		act, _ := msg.MetaGet(k)
+		assert.Equal(t, v, act, k)
+	}
+	for _, k := range []string{"nats_object_digest", "nats_object_modified"} {
+		act, _ := msg.MetaGet(k)
+		assert.NotEmpty(t, act, k)
+	}
+	_, exists := msg.MetaGet("other")
+	assert.False(t, exists)
+	headerValue, _ := msg.MetaGet("x_thing")
+	assert.Equal(t, "foo", headerValue)
+
+	msg, _, err = r.Read(ctx)
+	require.NoError(t, err)
+	b, err = msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, largeBody, b)
+
+	_, _, err = r.Read(ctx)
+	assert.Equal(t, service.ErrEndOfInput, err)
+}
+
+func TestObjectStoreInputWatch(t *testing.T) {
+	url, js := runJetStreamServer(t)
+
+	obs, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "blobs"})
+	require.NoError(t, err)
+
+	_, err = obs.PutString("first", "foo")
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+	defer done()
+
+	inConf, err := natsObjectStoreInputConfig().ParseYAML(`
+urls: [ `+url+` ]
+bucket: blobs
+watch: true
+`, nil)
+	require.NoError(t, err)
+
+	r, err := newObjectStoreReaderFromConfig(inConf, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Connect(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, r.Close(context.Background()))
+	})
+
+	msg, _, err := r.Read(ctx)
+	require.NoError(t, err)
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	_, err = obs.PutString("second", "bar")
+	require.NoError(t, err)
+
+	msg, _, err = r.Read(ctx)
+	require.NoError(t, err)
+	b, err = msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(b))
+
+	name, _ := msg.MetaGet("nats_object_name")
+	assert.Equal(t, "second", name)
+}
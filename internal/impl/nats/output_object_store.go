@@ -0,0 +1,190 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/Jeffail/benthos/v3/internal/impl/nats/auth"
+	"github.com/Jeffail/benthos/v3/internal/shutdown"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/nats-io/nats.go"
+)
+
+func natsObjectStoreOutputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Services").
+		Version("3.65.0").
+		Summary("Puts messages as objects into a NATS JetStream object store bucket.").
+		Description(`
+The bucket must already exist. Objects are split into chunks by the object store, which allows storing messages that exceed the max payload size of the NATS server. Putting an object with the name of an existing object replaces it.
+
+` + auth.Description()).
+		Field(urlsField()).
+		Field(bucketField()).
+		Field(service.NewInterpolatedStringField("name").
+			Description("The name of each object.").
+			Example(`${! meta("path") }`).
+			Example(`${! json("doc.id") }.json`)).
+		Field(service.NewMetadataFilterField("headers").
+			Description("Determine which (if any) metadata values of messages should be added to objects as headers.").
+			Optional()).
+		Field(service.NewIntField("max_in_flight").
+			Description("The maximum number of messages to have in flight at a given time. Increase this to improve throughput.").
+			Default(64)).
+		Field(service.NewTLSToggledField("tls")).
+		Field(service.NewInternalField(auth.FieldSpec()))
+}
+
+func init() {
+	err := service.RegisterOutput(
+		"nats_object_store", natsObjectStoreOutputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Output, int, error) {
+			maxInFlight, err := conf.FieldInt("max_in_flight")
+			if err != nil {
+				return nil, 0, err
+			}
+			w, err := newObjectStoreWriterFromConfig(conf, mgr.Logger())
+			return w, maxInFlight, err
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type objectStoreWriter struct {
+	connDetails connectionDetails
+	bucket      string
+	name        *service.InterpolatedString
+	headers     *service.MetadataFilter
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	natsConn *nats.Conn
+	obs      nats.ObjectStore
+
+	shutSig *shutdown.Signaller
+}
+
+func newObjectStoreWriterFromConfig(conf *service.ParsedConfig, log *service.Logger) (*objectStoreWriter, error) {
+	w := objectStoreWriter{
+		log:     log,
+		shutSig: shutdown.NewSignaller(),
+	}
+
+	var err error
+	if w.connDetails, err = connectionDetailsFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if w.bucket, err = conf.FieldString("bucket"); err != nil {
+		return nil, err
+	}
+	if w.name, err = conf.FieldInterpolatedString("name"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("headers") {
+		if w.headers, err = conf.FieldMetadataFilter("headers"); err != nil {
+			return nil, err
+		}
+	}
+	return &w, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (w *objectStoreWriter) Connect(ctx context.Context) error {
+	w.connMut.Lock()
+	defer w.connMut.Unlock()
+
+	if w.natsConn != nil {
+		return nil
+	}
+
+	var natsConn *nats.Conn
+	var err error
+
+	defer func() {
+		if err != nil && natsConn != nil {
+			natsConn.Close()
+		}
+	}()
+
+	if natsConn, err = w.connDetails.connect(); err != nil {
+		return err
+	}
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return err
+	}
+
+	obs, err := js.ObjectStore(w.bucket)
+	if err != nil {
+		return err
+	}
+
+	w.log.Infof("Putting objects into NATS object store bucket: %v", w.bucket)
+
+	w.natsConn = natsConn
+	w.obs = obs
+	return nil
+}
+
+func (w *objectStoreWriter) disconnect() {
+	w.connMut.Lock()
+	defer w.connMut.Unlock()
+
+	if w.natsConn != nil {
+		w.natsConn.Close()
+		w.natsConn = nil
+	}
+	w.obs = nil
+}
+
+//------------------------------------------------------------------------------
+
+func (w *objectStoreWriter) Write(ctx context.Context, msg *service.Message) error {
+	w.connMut.Lock()
+	obs := w.obs
+	w.connMut.Unlock()
+	if obs == nil {
+		return service.ErrNotConnected
+	}
+
+	msgBytes, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+
+	meta := &nats.ObjectMeta{
+		Name: w.name.String(msg),
+	}
+	_ = w.headers.Walk(msg, func(key, value string) error {
+		if meta.Headers == nil {
+			meta.Headers = nats.Header{}
+		}
+		meta.Headers.Set(key, value)
+		return nil
+	})
+
+	_, err = obs.Put(meta, bytes.NewReader(msgBytes), nats.Context(ctx))
+	return err
+}
+
+func (w *objectStoreWriter) Close(ctx context.Context) error {
+	go func() {
+		w.disconnect()
+		w.shutSig.ShutdownComplete()
+	}()
+	select {
+	case <-w.shutSig.HasClosedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
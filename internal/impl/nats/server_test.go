@@ -0,0 +1,42 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+// runJetStreamServer runs an embedded NATS server with JetStream enabled for
+// the duration of a test, returning its URL and a JetStream context.
+func runJetStreamServer(t *testing.T) (string, nats.JetStreamContext) {
+	t.Helper()
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	go s.Start()
+	t.Cleanup(func() {
+		s.Shutdown()
+		s.WaitForShutdown()
+	})
+	require.True(t, s.ReadyForConnections(time.Second*10), "nats server failed to start")
+
+	natsConn, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(natsConn.Close)
+
+	js, err := natsConn.JetStream()
+	require.NoError(t, err)
+
+	return s.ClientURL(), js
+}
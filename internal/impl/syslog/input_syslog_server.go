@@ -0,0 +1,472 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/shutdown"
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+func syslogServerInputConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Network").
+		Version("3.65.0").
+		Summary("Creates a server that receives syslog messages over UDP, TCP or TLS, and emits each message as a structured document.").
+		Description(`
+Messages following either [RFC 5424](https://tools.ietf.org/html/rfc5424) or [RFC 3164](https://tools.ietf.org/html/rfc3164) are supported, and by default the format of each message is detected automatically. Messages are parsed into a structured document that may contain any of the following fields:
+
+- `+"`message`"+` (string)
+- `+"`timestamp`"+` (string, RFC3339)
+- `+"`facility`"+` (int)
+- `+"`severity`"+` (int)
+- `+"`priority`"+` (int)
+- `+"`version`"+` (int, RFC 5424 only)
+- `+"`hostname`"+` (string)
+- `+"`procid`"+` (string)
+- `+"`appname`"+` (string)
+- `+"`msgid`"+` (string)
+- `+"`structureddata`"+` (object, RFC 5424 only)
+
+Where `+"`structureddata`"+` is an object of structured data element IDs to objects of their parameters. Messages that fail to parse are emitted in their raw form and flagged as having failed, which can be handled using [error handling patterns](/docs/configuration/error_handling).
+
+### Framing
+
+When receiving messages over TCP each message is framed following [RFC 6587](https://tools.ietf.org/html/rfc6587), where messages are either prefixed with their length in bytes followed by a space (octet counting), which allows messages to span multiple lines, or are terminated with a line feed (non-transparent). By default the framing of each message is detected automatically. When receiving messages over UDP each datagram is a single message.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+`+"```text"+`
+- syslog_format
+- syslog_remote_addr
+- syslog_tls_subject
+`+"```"+`
+
+Where `+"`syslog_format`"+` is either `+"`rfc5424`"+` or `+"`rfc3164`"+`, and `+"`syslog_tls_subject`"+` is the subject of the client certificate when TLS is enabled and a certificate is presented by the client.
+
+You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`).
+		Field(service.NewStringEnumField("network", "udp", "tcp").
+			Description("The network type to listen on. When set to `tcp` TLS can be enabled with the `tls` field.").
+			Default("udp")).
+		Field(service.NewStringField("address").
+			Description("The address to listen on.").
+			Example("0.0.0.0:514").Example("0.0.0.0:6514")).
+		Field(service.NewStringAnnotatedEnumField("format", map[string]string{
+			formatAuto:    "Detect the format of each message, where messages with a version following the priority are parsed as RFC 5424, and all others as RFC 3164.",
+			formatRFC5424: "Parse messages following RFC 5424.",
+			formatRFC3164: "Parse messages following RFC 3164.",
+		}).
+			Description("The format of messages to parse.").
+			Default(formatAuto)).
+		Field(service.NewStringAnnotatedEnumField("framing", map[string]string{
+			framingAuto:           "Detect the framing of each message, where frames beginning with a digit are octet counted and all others are non-transparent.",
+			framingOctetCounting:  "Messages are prefixed with their length in bytes followed by a space.",
+			framingNonTransparent: "Messages are terminated with a line feed.",
+		}).
+			Description("The framing of messages received over TCP.").
+			Default(framingAuto).
+			Advanced()).
+		Field(service.NewBoolField("best_effort").
+			Description("Whether to emit partially parsed documents for messages that are not entirely valid, rather than the raw message flagged as having failed.").
+			Default(true).
+			Advanced()).
+		Field(service.NewStringField("default_timezone").
+			Description("The timezone of RFC 3164 timestamps, which do not specify one. This value should follow the [time.LoadLocation](https://golang.org/pkg/time/#LoadLocation) format.").
+			Default("UTC").
+			Advanced()).
+		Field(service.NewIntField("max_message_size").
+			Description("The maximum size of a message in bytes. Connections that send larger messages are closed.").
+			Default(65536).
+			Advanced()).
+		Field(service.NewObjectField("tls",
+			service.NewBoolField("enabled").
+				Description("Whether TLS is enabled, which requires the network `tcp`.").
+				Default(false),
+			service.NewStringField("cert_file").
+				Description("The path of the certificate to serve.").
+				Default(""),
+			service.NewStringField("key_file").
+				Description("The path of the key of the certificate to serve.").
+				Default(""),
+			service.NewStringField("client_ca_file").
+				Description("An optional path of a certificate authority, which when set requires clients to present a certificate signed by it.").
+				Default(""),
+		).
+			Description("TLS settings for the server.").
+			Advanced()).
+		Example("Receive Logs Over TLS", `Here we receive syslog messages over TLS and write the messages of the `+"`auth`"+` facility to a file:`,
+			`
+input:
+  syslog_server:
+    network: tcp
+    address: 0.0.0.0:6514
+    tls:
+      enabled: true
+      cert_file: ./server.crt
+      key_file: ./server.key
+
+pipeline:
+  processors:
+    - bloblang: |
+        root = if this.facility != 4 { deleted() }
+
+output:
+  file:
+    path: ./auth.log
+    codec: lines
+`)
+}
+
+func init() {
+	err := service.RegisterInput(
+		"syslog_server", syslogServerInputConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			i, err := newSyslogServerInputFromConfig(conf, mgr.Logger())
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacks(i), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type syslogServerInput struct {
+	network    string
+	address    string
+	format     string
+	framing    string
+	bestEffort bool
+	timezone   *time.Location
+	maxSize    int
+	tlsConf    *tls.Config
+
+	log *service.Logger
+
+	connMut  sync.Mutex
+	listener net.Listener
+	conn     net.PacketConn
+	addr     net.Addr
+
+	msgChan chan *service.Message
+	shutSig *shutdown.Signaller
+}
+
+func newSyslogServerInputFromConfig(conf *service.ParsedConfig, log *service.Logger) (*syslogServerInput, error) {
+	s := &syslogServerInput{
+		log:     log,
+		msgChan: make(chan *service.Message),
+		shutSig: shutdown.NewSignaller(),
+	}
+
+	var err error
+	if s.network, err = conf.FieldString("network"); err != nil {
+		return nil, err
+	}
+	if s.address, err = conf.FieldString("address"); err != nil {
+		return nil, err
+	}
+	if s.format, err = conf.FieldString("format"); err != nil {
+		return nil, err
+	}
+	if s.framing, err = conf.FieldString("framing"); err != nil {
+		return nil, err
+	}
+	if s.bestEffort, err = conf.FieldBool("best_effort"); err != nil {
+		return nil, err
+	}
+
+	tzStr, err := conf.FieldString("default_timezone")
+	if err != nil {
+		return nil, err
+	}
+	if s.timezone, err = time.LoadLocation(tzStr); err != nil {
+		return nil, fmt.Errorf("failed to parse default timezone: %w", err)
+	}
+
+	if s.maxSize, err = conf.FieldInt("max_message_size"); err != nil {
+		return nil, err
+	}
+	if s.maxSize < 1 {
+		return nil, errors.New("max_message_size must be greater than zero")
+	}
+
+	tlsEnabled, err := conf.FieldBool("tls", "enabled")
+	if err != nil {
+		return nil, err
+	}
+	if tlsEnabled {
+		if s.network != "tcp" {
+			return nil, fmt.Errorf("tls cannot be enabled with the network %v", s.network)
+		}
+		if s.tlsConf, err = serverTLSConfig(conf.Namespace("tls")); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func serverTLSConfig(conf *service.ParsedConfig) (*tls.Config, error) {
+	certFile, err := conf.FieldString("cert_file")
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err := conf.FieldString("key_file")
+	if err != nil {
+		return nil, err
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("a cert_file and key_file must be specified when tls is enabled")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	caFile, err := conf.FieldString("client_ca_file")
+	if err != nil {
+		return nil, err
+	}
+	if caFile != "" {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("failed to parse client ca file")
+		}
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConf, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (s *syslogServerInput) Connect(ctx context.Context) error {
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	if s.listener != nil || s.conn != nil {
+		return nil
+	}
+	if s.shutSig.ShouldCloseAtLeisure() {
+		return service.ErrEndOfInput
+	}
+
+	var err error
+	if s.network == "udp" {
+		if s.conn, err = net.ListenPacket("udp", s.address); err != nil {
+			return err
+		}
+		s.addr = s.conn.LocalAddr()
+		go s.udpLoop(s.conn)
+	} else {
+		if s.tlsConf != nil {
+			s.listener, err = tls.Listen("tcp", s.address, s.tlsConf)
+		} else {
+			s.listener, err = net.Listen("tcp", s.address)
+		}
+		if err != nil {
+			return err
+		}
+		s.addr = s.listener.Addr()
+		go s.tcpLoop(s.listener)
+	}
+
+	s.log.Infof("Receiving syslog messages over %v from address: %v", s.network, s.addr)
+	return nil
+}
+
+func (s *syslogServerInput) newMessage(p *parser, frame []byte, remoteAddr, tlsSubject string) *service.Message {
+	doc, format, err := p.Parse(frame)
+
+	var msg *service.Message
+	if err != nil && (!s.bestEffort || doc == nil) {
+		msg = service.NewMessage(frame)
+		msg.SetError(fmt.Errorf("failed to parse %v message: %w", format, err))
+	} else {
+		msg = service.NewMessage(nil)
+		msg.SetStructured(doc)
+	}
+
+	msg.MetaSet("syslog_format", format)
+	msg.MetaSet("syslog_remote_addr", remoteAddr)
+	if tlsSubject != "" {
+		msg.MetaSet("syslog_tls_subject", tlsSubject)
+	}
+	return msg
+}
+
+func (s *syslogServerInput) send(msg *service.Message) bool {
+	select {
+	case s.msgChan <- msg:
+		return true
+	case <-s.shutSig.CloseAtLeisureChan():
+		return false
+	}
+}
+
+func (s *syslogServerInput) udpLoop(conn net.PacketConn) {
+	defer s.shutSig.ShutdownComplete()
+	go func() {
+		<-s.shutSig.CloseAtLeisureChan()
+		conn.Close()
+	}()
+
+	p := newParser(s.format, s.bestEffort, s.timezone)
+	buf := make([]byte, s.maxSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !s.shutSig.ShouldCloseAtLeisure() {
+				s.log.Errorf("Failed to read syslog datagram: %v", err)
+			}
+			return
+		}
+
+		frame := trimFrame(buf[:n])
+		if len(frame) == 0 {
+			continue
+		}
+		frameCopy := make([]byte, len(frame))
+		copy(frameCopy, frame)
+
+		if !s.send(s.newMessage(p, frameCopy, addr.String(), "")) {
+			return
+		}
+	}
+}
+
+func (s *syslogServerInput) tcpLoop(listener net.Listener) {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.shutSig.ShutdownComplete()
+	}()
+
+	go func() {
+		<-s.shutSig.CloseAtLeisureChan()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.shutSig.ShouldCloseAtLeisure() {
+				return
+			}
+			s.log.Errorf("Failed to accept syslog connection: %v", err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-s.shutSig.CloseAtLeisureChan():
+				return
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *syslogServerInput) handleConn(conn net.Conn) {
+	connCtx, connDone := s.shutSig.CloseAtLeisureCtx(context.Background())
+	defer connDone()
+	go func() {
+		<-connCtx.Done()
+		conn.Close()
+	}()
+
+	var tlsSubject string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			s.log.Errorf("Failed TLS handshake with %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			tlsSubject = certs[0].Subject.String()
+		}
+	}
+
+	remoteAddr := conn.RemoteAddr().String()
+	p := newParser(s.format, s.bestEffort, s.timezone)
+	frames := newFrameReader(conn, s.framing, s.maxSize)
+	for {
+		frame, err := frames.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !s.shutSig.ShouldCloseAtLeisure() {
+				s.log.Errorf("Syslog connection from %v dropped due to: %v", remoteAddr, err)
+			}
+			return
+		}
+		if !s.send(s.newMessage(p, frame, remoteAddr, tlsSubject)) {
+			return
+		}
+	}
+}
+
+func trimFrame(frame []byte) []byte {
+	for len(frame) > 0 {
+		switch frame[len(frame)-1] {
+		case '\n', '\r', 0:
+			frame = frame[:len(frame)-1]
+			continue
+		}
+		break
+	}
+	return frame
+}
+
+func (s *syslogServerInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	select {
+	case msg := <-s.msgChan:
+		return msg, func(ctx context.Context, err error) error {
+			return nil
+		}, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-s.shutSig.CloseAtLeisureChan():
+		return nil, nil, service.ErrEndOfInput
+	}
+}
+
+func (s *syslogServerInput) Close(ctx context.Context) error {
+	s.connMut.Lock()
+	started := s.listener != nil || s.conn != nil
+	s.shutSig.CloseAtLeisure()
+	s.connMut.Unlock()
+
+	if !started {
+		s.shutSig.ShutdownComplete()
+	}
+	select {
+	case <-s.shutSig.HasClosedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package syslog
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSyslogServer(t *testing.T, conf string) *syslogServerInput {
+	t.Helper()
+
+	pConf, err := syslogServerInputConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	s, err := newSyslogServerInputFromConfig(pConf, nil)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+	require.NoError(t, s.Connect(ctx))
+
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+		defer done()
+		require.NoError(t, s.Close(ctx))
+	})
+	return s
+}
+
+func readSyslogMessage(t *testing.T, s *syslogServerInput) *service.Message {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	msg, ackFn, err := s.Read(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ackFn(ctx, nil))
+	return msg
+}
+
+func TestSyslogServerConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"tls over udp": `
+network: udp
+address: 127.0.0.1:0
+tls:
+  enabled: true
+  cert_file: foo.crt
+  key_file: foo.key
+`,
+		"tls without cert": `
+network: tcp
+address: 127.0.0.1:0
+tls:
+  enabled: true
+`,
+		"bad timezone": `
+address: 127.0.0.1:0
+default_timezone: Nope/Nowhere
+`,
+		"bad max size": `
+address: 127.0.0.1:0
+max_message_size: 0
+`,
+	}
+
+	for name, conf := range tests {
+		pConf, err := syslogServerInputConfig().ParseYAML(conf, nil)
+		require.NoError(t, err, name)
+
+		_, err = newSyslogServerInputFromConfig(pConf, nil)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSyslogServerUDP(t *testing.T) {
+	s := testSyslogServer(t, `
+network: udp
+address: 127.0.0.1:0
+`)
+
+	conn, err := net.Dial("udp", s.addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<165>1 2003-10-11T22:14:15.003Z mymachine evntslog - ID47 [foo@1 bar=\"baz\"] hello world\n"))
+	require.NoError(t, err)
+
+	msg := readSyslogMessage(t, s)
+	require.NoError(t, msg.GetError())
+
+	doc, err := msg.AsStructured()
+	require.NoError(t, err)
+	docMap := doc.(map[string]interface{})
+	assert.Equal(t, "hello world", docMap["message"])
+	assert.Equal(t, "mymachine", docMap["hostname"])
+	assert.Equal(t, map[string]interface{}{
+		"foo@1": map[string]interface{}{"bar": "baz"},
+	}, docMap["structureddata"])
+
+	format, _ := msg.MetaGet("syslog_format")
+	assert.Equal(t, "rfc5424", format)
+	remoteAddr, _ := msg.MetaGet("syslog_remote_addr")
+	assert.Equal(t, conn.LocalAddr().String(), remoteAddr)
+}
+
+func TestSyslogServerTCP(t *testing.T) {
+	s := testSyslogServer(t, `
+network: tcp
+address: 127.0.0.1:0
+best_effort: false
+`)
+
+	conn, err := net.Dial("tcp", s.addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<34>Oct 11 22:14:15 mymachine su: first\n" +
+		"40 <34>Oct 11 22:14:15 mymachine su: second\n" +
+		"not a syslog message\n"))
+	require.NoError(t, err)
+
+	msg := readSyslogMessage(t, s)
+	require.NoError(t, msg.GetError())
+	doc, err := msg.AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, "first", doc.(map[string]interface{})["message"])
+	format, _ := msg.MetaGet("syslog_format")
+	assert.Equal(t, "rfc3164", format)
+
+	msg = readSyslogMessage(t, s)
+	require.NoError(t, msg.GetError())
+	doc, err = msg.AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, "second", doc.(map[string]interface{})["message"])
+
+	msg = readSyslogMessage(t, s)
+	require.Error(t, msg.GetError())
+	raw, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "not a syslog message", string(raw))
+}
+
+func TestSyslogServerTLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	certFile, keyFile, cert := createTestCert(t, tmpDir)
+
+	s := testSyslogServer(t, `
+network: tcp
+address: 127.0.0.1:0
+tls:
+  enabled: true
+  cert_file: `+certFile+`
+  key_file: `+keyFile+`
+  client_ca_file: `+certFile+`
+`)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+
+	conn, err := tls.Dial("tcp", s.addr.String(), &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+		ServerName:   "localhost",
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<34>Oct 11 22:14:15 mymachine su: secret\n"))
+	require.NoError(t, err)
+
+	msg := readSyslogMessage(t, s)
+	require.NoError(t, msg.GetError())
+	doc, err := msg.AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", doc.(map[string]interface{})["message"])
+
+	subject, _ := msg.MetaGet("syslog_tls_subject")
+	assert.Equal(t, "CN=localhost,O=Benthos", subject)
+}
+
+func createTestCert(t *testing.T, dir string) (certFile, keyFile string, cert tls.Certificate) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization: []string{"Benthos"},
+			CommonName:   "localhost",
+		},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	cert.Leaf, err = x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	return
+}
@@ -0,0 +1,3 @@
+// Package syslog contains component implementations for receiving syslog
+// messages.
+package syslog
@@ -0,0 +1,287 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	syslog "github.com/influxdata/go-syslog/v3"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
+	"github.com/influxdata/go-syslog/v3/rfc5424"
+)
+
+const (
+	formatAuto    = "auto"
+	formatRFC5424 = "rfc5424"
+	formatRFC3164 = "rfc3164"
+
+	framingAuto           = "auto"
+	framingOctetCounting  = "octet_counting"
+	framingNonTransparent = "non_transparent"
+)
+
+// detectFormat returns the format of a syslog message by checking whether the
+// priority is followed by a version, which is only present in RFC 5424
+// messages.
+func detectFormat(msg []byte) string {
+	if len(msg) == 0 || msg[0] != '<' {
+		return formatRFC3164
+	}
+	end := bytes.IndexByte(msg, '>')
+	if end < 0 || end+1 >= len(msg) {
+		return formatRFC3164
+	}
+	rest := msg[end+1:]
+	i := 0
+	for i < len(rest) && i < 3 && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(rest) && rest[i] == ' ' && rest[0] != '0' {
+		return formatRFC5424
+	}
+	return formatRFC3164
+}
+
+// parser parses syslog messages of either format into structured documents.
+// Parsers are not safe for concurrent use.
+type parser struct {
+	format  string
+	rfc5424 syslog.Machine
+	rfc3164 syslog.Machine
+}
+
+func newParser(format string, bestEffort bool, tz *time.Location) *parser {
+	var opts5424 []syslog.MachineOption
+	opts3164 := []syslog.MachineOption{
+		rfc3164.WithRFC3339(),
+		rfc3164.WithYear(rfc3164.CurrentYear{}),
+		rfc3164.WithTimezone(tz),
+	}
+	if bestEffort {
+		opts5424 = append(opts5424, rfc5424.WithBestEffort())
+		opts3164 = append(opts3164, rfc3164.WithBestEffort())
+	}
+	return &parser{
+		format:  format,
+		rfc5424: rfc5424.NewParser(opts5424...),
+		rfc3164: rfc3164.NewParser(opts3164...),
+	}
+}
+
+// Parse a syslog message into a structured document, returning the document
+// along with the format of the message.
+func (p *parser) Parse(msg []byte) (map[string]interface{}, string, error) {
+	format := p.format
+	if format == formatAuto {
+		format = detectFormat(msg)
+	}
+
+	resMap := map[string]interface{}{}
+	switch format {
+	case formatRFC5424:
+		resGen, err := p.rfc5424.Parse(msg)
+		if resGen == nil {
+			return nil, format, err
+		}
+		res := resGen.(*rfc5424.SyslogMessage)
+		if res.Message != nil {
+			resMap["message"] = *res.Message
+		}
+		if res.Timestamp != nil {
+			resMap["timestamp"] = res.Timestamp.Format(time.RFC3339Nano)
+		}
+		if res.Facility != nil {
+			resMap["facility"] = *res.Facility
+		}
+		if res.Severity != nil {
+			resMap["severity"] = *res.Severity
+		}
+		if res.Priority != nil {
+			resMap["priority"] = *res.Priority
+		}
+		if res.Version != 0 {
+			resMap["version"] = res.Version
+		}
+		if res.Hostname != nil {
+			resMap["hostname"] = *res.Hostname
+		}
+		if res.ProcID != nil {
+			resMap["procid"] = *res.ProcID
+		}
+		if res.Appname != nil {
+			resMap["appname"] = *res.Appname
+		}
+		if res.MsgID != nil {
+			resMap["msgid"] = *res.MsgID
+		}
+		if res.StructuredData != nil {
+			sd := make(map[string]interface{}, len(*res.StructuredData))
+			for id, params := range *res.StructuredData {
+				paramsMap := make(map[string]interface{}, len(params))
+				for k, v := range params {
+					paramsMap[k] = v
+				}
+				sd[id] = paramsMap
+			}
+			resMap["structureddata"] = sd
+		}
+		return resMap, format, err
+	case formatRFC3164:
+		resGen, err := p.rfc3164.Parse(msg)
+		if resGen == nil {
+			return nil, format, err
+		}
+		res := resGen.(*rfc3164.SyslogMessage)
+		if res.Message != nil {
+			resMap["message"] = *res.Message
+		}
+		if res.Timestamp != nil {
+			resMap["timestamp"] = res.Timestamp.Format(time.RFC3339Nano)
+		}
+		if res.Facility != nil {
+			resMap["facility"] = *res.Facility
+		}
+		if res.Severity != nil {
+			resMap["severity"] = *res.Severity
+		}
+		if res.Priority != nil {
+			resMap["priority"] = *res.Priority
+		}
+		if res.Hostname != nil {
+			resMap["hostname"] = *res.Hostname
+		}
+		if res.ProcID != nil {
+			resMap["procid"] = *res.ProcID
+		}
+		if res.Appname != nil {
+			resMap["appname"] = *res.Appname
+		}
+		if res.MsgID != nil {
+			resMap["msgid"] = *res.MsgID
+		}
+		return resMap, format, err
+	}
+	return nil, format, fmt.Errorf("format not recognised: %v", format)
+}
+
+//------------------------------------------------------------------------------
+
+var errFrameTooLarge = errors.New("syslog frame exceeds the maximum message size")
+
+// frameReader reads syslog messages from a stream following the framing
+// methods of RFC 6587, where messages are either prefixed with their length
+// (octet counting), or are terminated with a line feed (non-transparent).
+type frameReader struct {
+	r       *bufio.Reader
+	framing string
+	maxSize int
+}
+
+func newFrameReader(r io.Reader, framing string, maxSize int) *frameReader {
+	return &frameReader{
+		r:       bufio.NewReader(r),
+		framing: framing,
+		maxSize: maxSize,
+	}
+}
+
+// Next returns the next frame of the stream, or io.EOF once the stream has
+// ended.
+func (f *frameReader) Next() ([]byte, error) {
+	for {
+		first, err := f.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		framing := f.framing
+		if framing == framingAuto {
+			// Octet counted frames begin with the length of the message,
+			// whereas the messages of non-transparent frames begin with a
+			// priority.
+			framing = framingNonTransparent
+			if first[0] >= '1' && first[0] <= '9' {
+				framing = framingOctetCounting
+			}
+		}
+
+		var frame []byte
+		if framing == framingOctetCounting {
+			frame, err = f.nextOctetCounted()
+		} else {
+			frame, err = f.nextNonTransparent()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) > 0 {
+			return frame, nil
+		}
+	}
+}
+
+// The maximum number of digits read for the octet count of a frame, which is
+// enough for any count that fits within an int32.
+const maxOctetCountDigits = 10
+
+func (f *frameReader) nextOctetCounted() ([]byte, error) {
+	var lenBytes []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(lenBytes) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == ' ' && len(lenBytes) > 0 {
+			break
+		}
+		if b < '0' || b > '9' {
+			return nil, fmt.Errorf("invalid octet count '%s', unexpected character %q", lenBytes, b)
+		}
+		if len(lenBytes) == maxOctetCountDigits {
+			return nil, fmt.Errorf("invalid octet count '%s', exceeds %v digits", lenBytes, maxOctetCountDigits)
+		}
+		lenBytes = append(lenBytes, b)
+	}
+	length, err := strconv.Atoi(string(lenBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid octet count '%s'", lenBytes)
+	}
+	if length > f.maxSize {
+		return nil, errFrameTooLarge
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(f.r, frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (f *frameReader) nextNonTransparent() ([]byte, error) {
+	var frame []byte
+	for {
+		line, isPrefix, err := f.r.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(frame) > 0 {
+				return frame, nil
+			}
+			return nil, err
+		}
+		if len(frame)+len(line) > f.maxSize {
+			return nil, errFrameTooLarge
+		}
+		frame = append(frame, line...)
+		if !isPrefix {
+			return bytes.TrimRight(frame, "\x00"), nil
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		`<165>1 2003-10-11T22:14:15.003Z host app - ID47 - hello`: formatRFC5424,
+		`<34>Oct 11 22:14:15 mymachine su: 'su root' failed`:      formatRFC3164,
+		`<34>2 foo`:   formatRFC5424,
+		`<34>01 foo`:  formatRFC3164,
+		`<34>1`:       formatRFC3164,
+		`no priority`: formatRFC3164,
+		``:            formatRFC3164,
+	}
+	for input, exp := range tests {
+		assert.Equal(t, exp, detectFormat([]byte(input)), input)
+	}
+}
+
+func TestParserAuto(t *testing.T) {
+	p := newParser(formatAuto, true, time.UTC)
+
+	doc, format, err := p.Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event`))
+	require.NoError(t, err)
+	assert.Equal(t, formatRFC5424, format)
+	assert.Equal(t, map[string]interface{}{
+		"message":   "An application event",
+		"timestamp": "2003-10-11T22:14:15.003Z",
+		"facility":  uint8(20),
+		"severity":  uint8(5),
+		"priority":  uint8(165),
+		"version":   uint16(1),
+		"hostname":  "mymachine.example.com",
+		"appname":   "evntslog",
+		"msgid":     "ID47",
+		"structureddata": map[string]interface{}{
+			"exampleSDID@32473": map[string]interface{}{
+				"iut":         "3",
+				"eventSource": "Application",
+			},
+		},
+	}, doc)
+
+	doc, format, err = p.Parse([]byte(`<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed`))
+	require.NoError(t, err)
+	assert.Equal(t, formatRFC3164, format)
+	assert.Equal(t, "'su root' failed", doc["message"])
+	assert.Equal(t, "mymachine", doc["hostname"])
+	assert.Equal(t, "su", doc["appname"])
+	assert.Equal(t, "123", doc["procid"])
+	assert.Equal(t, uint8(4), doc["facility"])
+	assert.Equal(t, uint8(2), doc["severity"])
+}
+
+func TestParserStrict(t *testing.T) {
+	p := newParser(formatRFC5424, false, time.UTC)
+
+	_, format, err := p.Parse([]byte(`<34>Oct 11 22:14:15 mymachine su: 'su root' failed`))
+	require.Error(t, err)
+	assert.Equal(t, formatRFC5424, format)
+}
+
+func TestFrameReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		framing string
+		input   string
+		frames  []string
+	}{
+		{
+			name:    "non transparent",
+			framing: framingNonTransparent,
+			input:   "<34>first\n<34>second\r\n\n<34>third\x00\n<34>last",
+			frames:  []string{"<34>first", "<34>second", "<34>third", "<34>last"},
+		},
+		{
+			name:    "octet counting",
+			framing: framingOctetCounting,
+			input:   "9 <34>first14 <34>multi\nline",
+			frames:  []string{"<34>first", "<34>multi\nline"},
+		},
+		{
+			name:    "auto",
+			framing: framingAuto,
+			input:   "9 <34>first<34>second\n14 <34>multi\nline<34>last\n",
+			frames:  []string{"<34>first", "<34>second", "<34>multi\nline", "<34>last"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r := newFrameReader(bytes.NewReader([]byte(test.input)), test.framing, 1024)
+
+			var frames []string
+			for {
+				frame, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				frames = append(frames, string(frame))
+			}
+			assert.Equal(t, test.frames, frames)
+		})
+	}
+}
+
+func TestFrameReaderErrors(t *testing.T) {
+	r := newFrameReader(bytes.NewReader([]byte("100 <34>too big")), framingAuto, 10)
+	_, err := r.Next()
+	assert.Equal(t, errFrameTooLarge, err)
+
+	r = newFrameReader(bytes.NewReader([]byte("<34>this line is too big\n")), framingAuto, 10)
+	_, err = r.Next()
+	assert.Equal(t, errFrameTooLarge, err)
+
+	r = newFrameReader(bytes.NewReader([]byte("20 <34>truncated")), framingAuto, 100)
+	_, err = r.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	r = newFrameReader(bytes.NewReader([]byte("<34>nope")), framingOctetCounting, 100)
+	_, err = r.Next()
+	assert.Error(t, err)
+
+	r = newFrameReader(bytes.NewReader([]byte("12a <34>nope")), framingOctetCounting, 100)
+	_, err = r.Next()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected character")
+
+	r = newFrameReader(bytes.NewReader(append([]byte("1"), bytes.Repeat([]byte("0"), 1000)...)), framingAuto, 100)
+	_, err = r.Next()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds 10 digits")
+}
@@ -0,0 +1,3 @@
+// Package wasm contains the wasm processor, which depends on the wazero
+// runtime and is therefore only included when building with Go 1.18 or later.
+package wasm
@@ -0,0 +1,320 @@
+//go:build go1.18
+// +build go1.18
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const hostModuleName = "benthos_wasm"
+
+func wasmProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		// Stable(). TODO
+		Categories("Utility").
+		Summary("Executes a function exported by a [WASM](https://webassembly.org/) module for each message.").
+		Description(`
+This processor allows processing logic to be written in any language that compiles to WASM (Rust, TinyGo, AssemblyScript, etc) and loaded at runtime without rebuilding Benthos. Modules are executed with [wazero](https://wazero.io/), a runtime written in pure Go, and are given access to the [WASI](https://wasi.dev/) snapshot preview 1 API without any access to the filesystem, environment or network.
+
+Since wazero requires Go 1.18 or later this processor is only available in builds of Benthos compiled with Go 1.18 or later.
+
+The function executed takes no arguments and returns no values, it instead accesses and modifies the message being processed by calling functions imported from the host module ` + "`" + hostModuleName + "`" + `. A pool of module instances is kept and grown as needed so that each pipeline thread executes its own instance, therefore modules are allowed to hold state between calls but shouldn't expect that state to be shared across threads.
+
+WASI modules that export a function ` + "`_initialize`" + ` or ` + "`_start`" + ` have it called once for each instance in order to initialize the runtime of the language, but these functions must not exit. Modules written in languages that exit at the end of ` + "`main`" + `, such as TinyGo, should therefore be built as reactors.
+
+### ABI
+
+The following functions are exported by the host module ` + "`" + hostModuleName + "`" + `, where all arguments are of type ` + "`i32`" + ` and pointers refer to offsets of the linear memory exported by the module as ` + "`memory`" + `:
+
+| Function | Signature | Description |
+|---|---|---|
+| ` + "`v0_msg_len`" + ` | ` + "`() -> i32`" + ` | Returns the size in bytes of the message contents. |
+| ` + "`v0_msg_read`" + ` | ` + "`(ptr)`" + ` | Copies the message contents into memory at ` + "`ptr`" + `, which must have room for at least ` + "`v0_msg_len`" + ` bytes. |
+| ` + "`v0_msg_set`" + ` | ` + "`(ptr, len)`" + ` | Sets the message contents to ` + "`len`" + ` bytes read from ` + "`ptr`" + `. |
+| ` + "`v0_meta_len`" + ` | ` + "`(key_ptr, key_len) -> i32`" + ` | Returns the size in bytes of a metadata value, or ` + "`-1`" + ` if the key does not exist. |
+| ` + "`v0_meta_read`" + ` | ` + "`(key_ptr, key_len, ptr)`" + ` | Copies a metadata value into memory at ` + "`ptr`" + `, which must have room for at least ` + "`v0_meta_len`" + ` bytes. |
+| ` + "`v0_meta_set`" + ` | ` + "`(key_ptr, key_len, value_ptr, value_len)`" + ` | Sets a metadata value. |
+| ` + "`v0_meta_delete`" + ` | ` + "`(key_ptr, key_len)`" + ` | Deletes a metadata value. |
+| ` + "`v0_set_error`" + ` | ` + "`(ptr, len)`" + ` | Marks the message as failed with an error message, once the function returns any modifications made to the message are discarded and it continues through the pipeline with its original contents and metadata. |
+
+Strings passed to and from the host are UTF-8 encoded and are not null terminated. If the function traps (panics) then the message is also marked as failed and the instance that executed it is discarded.
+
+### Example
+
+The following is a processor written in TinyGo, which can be compiled with ` + "`tinygo build -target=wasip1 -buildmode=c-shared -o uppercase.wasm main.go`" + `:
+
+` + "```go" + `
+package main
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+//go:wasmimport benthos_wasm v0_msg_len
+func msgLen() int32
+
+//go:wasmimport benthos_wasm v0_msg_read
+func msgRead(ptr unsafe.Pointer)
+
+//go:wasmimport benthos_wasm v0_msg_set
+func msgSet(ptr unsafe.Pointer, size int32)
+
+//export process
+func process() {
+	b := make([]byte, msgLen())
+	if len(b) == 0 {
+		return
+	}
+	msgRead(unsafe.Pointer(&b[0]))
+	b = bytes.ToUpper(b)
+	msgSet(unsafe.Pointer(&b[0]), int32(len(b)))
+}
+
+func main() {}
+` + "```" + ``).
+		Field(service.NewStringField("path").
+			Description("The path of the WASM module to load.").
+			Example("./uppercase.wasm")).
+		Field(service.NewStringField("function").
+			Description("The name of the function exported by the module to execute for each message.").
+			Default("process")).
+		Version("3.65.0")
+}
+
+func init() {
+	err := service.RegisterProcessor(
+		"wasm", wasmProcessorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newWasmProcessorFromConfig(conf)
+		})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func newWasmProcessorFromConfig(conf *service.ParsedConfig) (*wasmProcessor, error) {
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return nil, err
+	}
+	function, err := conf.FieldString("function")
+	if err != nil {
+		return nil, err
+	}
+	wasmBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+	return newWasmProcessor(wasmBytes, function)
+}
+
+// wasmCall holds the message being processed by a single execution of the
+// module function, it is made available to host functions via the context.
+type wasmCall struct {
+	msg *service.Message
+	err error
+}
+
+type wasmCallKey struct{}
+
+func callFromContext(ctx context.Context) *wasmCall {
+	return ctx.Value(wasmCallKey{}).(*wasmCall)
+}
+
+type wasmProcessor struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	modConf  wazero.ModuleConfig
+	function string
+
+	// Instances are pooled rather than shared as they aren't safe to call
+	// concurrently. Since a processor is shared by all pipeline threads the
+	// pool grows to the number of threads.
+	mut       sync.Mutex
+	instances []api.Module
+}
+
+func newWasmProcessor(wasmBytes []byte, function string) (*wasmProcessor, error) {
+	ctx := context.Background()
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	p := &wasmProcessor{
+		runtime:  r,
+		function: function,
+		modConf: wazero.NewModuleConfig().
+			WithName("").
+			WithStartFunctions("_initialize", "_start"),
+	}
+
+	if err := p.init(ctx, wasmBytes); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *wasmProcessor) init(ctx context.Context, wasmBytes []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	if _, err := hostModuleBuilder(p.runtime).Instantiate(ctx); err != nil {
+		return fmt.Errorf("failed to instantiate host module: %w", err)
+	}
+
+	var err error
+	if p.compiled, err = p.runtime.CompileModule(ctx, wasmBytes); err != nil {
+		return fmt.Errorf("failed to compile module: %w", err)
+	}
+	if _, exists := p.compiled.ExportedFunctions()[p.function]; !exists {
+		return fmt.Errorf("module does not export function '%v'", p.function)
+	}
+
+	// Instantiate once up front so that issues such as missing imports are
+	// caught at construction.
+	mod, err := p.instantiate(ctx)
+	if err != nil {
+		return err
+	}
+	p.instances = append(p.instances, mod)
+	return nil
+}
+
+func (p *wasmProcessor) instantiate(ctx context.Context) (api.Module, error) {
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, p.modConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module: %w", err)
+	}
+	if mod.IsClosed() {
+		return nil, errors.New("module exited during initialisation")
+	}
+	return mod, nil
+}
+
+func (p *wasmProcessor) getInstance(ctx context.Context) (api.Module, error) {
+	p.mut.Lock()
+	if n := len(p.instances); n > 0 {
+		mod := p.instances[n-1]
+		p.instances = p.instances[:n-1]
+		p.mut.Unlock()
+		return mod, nil
+	}
+	p.mut.Unlock()
+	return p.instantiate(ctx)
+}
+
+func (p *wasmProcessor) putInstance(mod api.Module) {
+	p.mut.Lock()
+	p.instances = append(p.instances, mod)
+	p.mut.Unlock()
+}
+
+func (p *wasmProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	mod, err := p.getInstance(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	call := &wasmCall{msg: msg.Copy()}
+	if _, err := mod.ExportedFunction(p.function).Call(context.WithValue(ctx, wasmCallKey{}, call)); err != nil {
+		// The instance may have been left in a broken state.
+		_ = mod.Close(context.Background())
+		return nil, fmt.Errorf("failed to execute function '%v': %w", p.function, err)
+	}
+	p.putInstance(mod)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return service.MessageBatch{call.msg}, nil
+}
+
+func (p *wasmProcessor) Close(ctx context.Context) error {
+	p.mut.Lock()
+	p.instances = nil
+	p.mut.Unlock()
+	return p.runtime.Close(ctx)
+}
+
+//------------------------------------------------------------------------------
+
+func readBytes(m api.Module, ptr, size uint32) []byte {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Errorf("out of range memory read of %v bytes at offset %v", size, ptr))
+	}
+	// Views of memory are only valid until the module next executes.
+	bCopy := make([]byte, len(b))
+	copy(bCopy, b)
+	return bCopy
+}
+
+func writeBytes(m api.Module, ptr uint32, b []byte) {
+	if !m.Memory().Write(ptr, b) {
+		panic(fmt.Errorf("out of range memory write of %v bytes at offset %v", len(b), ptr))
+	}
+}
+
+func msgBytes(call *wasmCall) []byte {
+	b, err := call.msg.AsBytes()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func hostModuleBuilder(r wazero.Runtime) wazero.HostModuleBuilder {
+	b := r.NewHostModuleBuilder(hostModuleName)
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context) uint32 {
+		return uint32(len(msgBytes(callFromContext(ctx))))
+	}).Export("v0_msg_len")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr uint32) {
+		writeBytes(m, ptr, msgBytes(callFromContext(ctx)))
+	}).Export("v0_msg_read")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		callFromContext(ctx).msg.SetBytes(readBytes(m, ptr, size))
+	}).Export("v0_msg_set")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, keyPtr, keyLen uint32) int32 {
+		v, exists := callFromContext(ctx).msg.MetaGet(string(readBytes(m, keyPtr, keyLen)))
+		if !exists {
+			return -1
+		}
+		return int32(len(v))
+	}).Export("v0_meta_len")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, keyPtr, keyLen, ptr uint32) {
+		v, _ := callFromContext(ctx).msg.MetaGet(string(readBytes(m, keyPtr, keyLen)))
+		writeBytes(m, ptr, []byte(v))
+	}).Export("v0_meta_read")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, keyPtr, keyLen, valuePtr, valueLen uint32) {
+		callFromContext(ctx).msg.MetaSet(string(readBytes(m, keyPtr, keyLen)), string(readBytes(m, valuePtr, valueLen)))
+	}).Export("v0_meta_set")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, keyPtr, keyLen uint32) {
+		callFromContext(ctx).msg.MetaDelete(string(readBytes(m, keyPtr, keyLen)))
+	}).Export("v0_meta_delete")
+
+	b.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		callFromContext(ctx).err = errors.New(string(readBytes(m, ptr, size)))
+	}).Export("v0_set_error")
+
+	return b
+}
@@ -0,0 +1,321 @@
+//go:build go1.18
+// +build go1.18
+
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The following helpers assemble a WASM binary by hand, which saves us from
+// needing a compiler toolchain in order to run tests.
+
+func wasmU32(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func wasmI32Const(v int32) []byte {
+	b := []byte{0x41}
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func wasmVec(items ...[]byte) []byte {
+	b := wasmU32(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func wasmStr(s string) []byte {
+	return append(wasmU32(uint32(len(s))), s...)
+}
+
+func wasmSection(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, wasmU32(uint32(len(payload)))...), payload...)
+}
+
+func wasmCode(instrs ...[]byte) []byte {
+	// Each function declares a single i32 local.
+	body := []byte{0x01, 0x01, 0x7f}
+	for _, instr := range instrs {
+		body = append(body, instr...)
+	}
+	body = append(body, 0x0b)
+	return append(wasmU32(uint32(len(body))), body...)
+}
+
+func wasmCallFn(idx uint32) []byte {
+	return append([]byte{0x10}, wasmU32(idx)...)
+}
+
+func wasmData(offset int32, data string) []byte {
+	b := append([]byte{0x00}, wasmI32Const(offset)...)
+	b = append(b, 0x0b)
+	return append(b, wasmStr(data)...)
+}
+
+const (
+	fnMsgLen uint32 = iota
+	fnMsgRead
+	fnMsgSet
+	fnMetaLen
+	fnMetaRead
+	fnMetaSet
+	fnMetaDelete
+	fnSetError
+)
+
+// testModule exports the following functions:
+//
+// process: prefixes the message with "hello " and sets the metadata key
+// processed to "yes", or errors if the message is empty.
+//
+// greet: sets the message to "hello " followed by the value of the metadata
+// key name and then deletes it, or errors if the key is missing.
+//
+// trap: traps.
+func testModule() []byte {
+	var (
+		i32      = byte(0x7f)
+		localGet = []byte{0x20, 0x00}
+		localTee = []byte{0x22, 0x00}
+		ifEmpty  = []byte{0x04, 0x40}
+		end      = []byte{0x0b}
+		ret      = []byte{0x0f}
+	)
+
+	types := wasmSection(1, wasmVec(
+		[]byte{0x60, 0x00, 0x01, i32},                // 0: () -> i32
+		[]byte{0x60, 0x01, i32, 0x00},                // 1: (i32)
+		[]byte{0x60, 0x02, i32, i32, 0x00},           // 2: (i32, i32)
+		[]byte{0x60, 0x02, i32, i32, 0x01, i32},      // 3: (i32, i32) -> i32
+		[]byte{0x60, 0x03, i32, i32, i32, 0x00},      // 4: (i32, i32, i32)
+		[]byte{0x60, 0x04, i32, i32, i32, i32, 0x00}, // 5: (i32, i32, i32, i32)
+		[]byte{0x60, 0x00, 0x00},                     // 6: ()
+	))
+
+	importFn := func(name string, typeIdx byte) []byte {
+		return append(append(wasmStr(hostModuleName), wasmStr(name)...), 0x00, typeIdx)
+	}
+	imports := wasmSection(2, wasmVec(
+		importFn("v0_msg_len", 0),
+		importFn("v0_msg_read", 1),
+		importFn("v0_msg_set", 2),
+		importFn("v0_meta_len", 3),
+		importFn("v0_meta_read", 4),
+		importFn("v0_meta_set", 5),
+		importFn("v0_meta_delete", 2),
+		importFn("v0_set_error", 2),
+	))
+
+	funcs := wasmSection(3, wasmVec([]byte{6}, []byte{6}, []byte{6}))
+	memory := wasmSection(5, wasmVec([]byte{0x00, 0x01}))
+	exports := wasmSection(7, wasmVec(
+		append(wasmStr("memory"), 0x02, 0x00),
+		append(wasmStr("process"), 0x00, 8),
+		append(wasmStr("greet"), 0x00, 9),
+		append(wasmStr("trap"), 0x00, 10),
+	))
+
+	code := wasmSection(10, wasmVec(
+		// process
+		wasmCode(
+			wasmCallFn(fnMsgLen), localTee, []byte{0x45}, ifEmpty,
+			wasmI32Const(16), wasmI32Const(13), wasmCallFn(fnSetError), ret,
+			end,
+			wasmI32Const(1024), wasmCallFn(fnMsgRead),
+			wasmI32Const(1018), localGet, wasmI32Const(6), []byte{0x6a}, wasmCallFn(fnMsgSet),
+			wasmI32Const(0), wasmI32Const(9), wasmI32Const(9), wasmI32Const(3), wasmCallFn(fnMetaSet),
+		),
+		// greet
+		wasmCode(
+			wasmI32Const(32), wasmI32Const(4), wasmCallFn(fnMetaLen), localTee,
+			wasmI32Const(0), []byte{0x48}, ifEmpty,
+			wasmI32Const(48), wasmI32Const(7), wasmCallFn(fnSetError), ret,
+			end,
+			wasmI32Const(32), wasmI32Const(4), wasmI32Const(1024), wasmCallFn(fnMetaRead),
+			wasmI32Const(1018), localGet, wasmI32Const(6), []byte{0x6a}, wasmCallFn(fnMsgSet),
+			wasmI32Const(32), wasmI32Const(4), wasmCallFn(fnMetaDelete),
+		),
+		// trap
+		wasmCode([]byte{0x00}),
+	))
+
+	data := wasmSection(11, wasmVec(
+		wasmData(0, "processedyes"),
+		wasmData(16, "empty message"),
+		wasmData(32, "name"),
+		wasmData(48, "no name"),
+		wasmData(1018, "hello "),
+	))
+
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, section := range [][]byte{types, imports, funcs, memory, exports, code, data} {
+		b = append(b, section...)
+	}
+	return b
+}
+
+func TestWasmProcessor(t *testing.T) {
+	proc, err := newWasmProcessor(testModule(), "process")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, proc.Close(context.Background()))
+	}()
+
+	inMsg := service.NewMessage([]byte("world"))
+	inMsg.MetaSet("foo", "bar")
+
+	res, err := proc.Process(context.Background(), inMsg)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	b, err := res[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+
+	v, _ := res[0].MetaGet("processed")
+	assert.Equal(t, "yes", v)
+	v, _ = res[0].MetaGet("foo")
+	assert.Equal(t, "bar", v)
+
+	// The input message must not be modified.
+	b, err = inMsg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(b))
+	_, exists := inMsg.MetaGet("processed")
+	assert.False(t, exists)
+
+	_, err = proc.Process(context.Background(), service.NewMessage(nil))
+	require.Error(t, err)
+	assert.Equal(t, "empty message", err.Error())
+}
+
+func TestWasmProcessorMetadata(t *testing.T) {
+	proc, err := newWasmProcessor(testModule(), "greet")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, proc.Close(context.Background()))
+	}()
+
+	inMsg := service.NewMessage([]byte("ignored"))
+	inMsg.MetaSet("name", "benthos")
+
+	res, err := proc.Process(context.Background(), inMsg)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	b, err := res[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello benthos", string(b))
+
+	_, exists := res[0].MetaGet("name")
+	assert.False(t, exists)
+
+	_, err = proc.Process(context.Background(), service.NewMessage([]byte("ignored")))
+	require.Error(t, err)
+	assert.Equal(t, "no name", err.Error())
+}
+
+func TestWasmProcessorTrap(t *testing.T) {
+	proc, err := newWasmProcessor(testModule(), "trap")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, proc.Close(context.Background()))
+	}()
+
+	for i := 0; i < 2; i++ {
+		_, err = proc.Process(context.Background(), service.NewMessage([]byte("hello")))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to execute function 'trap'")
+	}
+	assert.Empty(t, proc.instances)
+}
+
+func TestWasmProcessorParallel(t *testing.T) {
+	proc, err := newWasmProcessor(testModule(), "process")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, proc.Close(context.Background()))
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				content := fmt.Sprintf("%v-%v", i, j)
+				res, err := proc.Process(context.Background(), service.NewMessage([]byte(content)))
+				require.NoError(t, err)
+				require.Len(t, res, 1)
+
+				b, err := res[0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, "hello "+content, string(b))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, len(proc.instances), 10)
+}
+
+func TestWasmProcessorConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	modPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(modPath, testModule(), 0o644))
+
+	conf, err := wasmProcessorConfig().ParseYAML(fmt.Sprintf(`
+path: %v
+function: greet
+`, modPath), nil)
+	require.NoError(t, err)
+
+	proc, err := newWasmProcessorFromConfig(conf)
+	require.NoError(t, err)
+	assert.Equal(t, "greet", proc.function)
+	require.NoError(t, proc.Close(context.Background()))
+
+	conf, err = wasmProcessorConfig().ParseYAML(fmt.Sprintf(`
+path: %v
+function: nope
+`, modPath), nil)
+	require.NoError(t, err)
+
+	_, err = newWasmProcessorFromConfig(conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not export function 'nope'")
+
+	_, err = newWasmProcessor([]byte("not a module"), "process")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to compile module")
+}
@@ -0,0 +1,202 @@
+package lineage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// Edge describes a message (the child) that was derived from another message
+// (the parent) by a component. Edges of input messages have an empty parent.
+type Edge struct {
+	Parent    string    `json:"parent,omitempty"`
+	Child     string    `json:"child"`
+	Label     string    `json:"label"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Recorder receives the edges of derived messages.
+type Recorder interface {
+	RecordEdges(edges ...Edge)
+}
+
+// Node is the lineage information attached to a message.
+type Node struct {
+	id       string
+	origins  []string
+	recorder Recorder
+}
+
+// ID returns the unique identifier of the message.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Origins returns the sorted identifiers of the input messages that the message
+// originated from.
+func (n *Node) Origins() []string {
+	return n.origins
+}
+
+type nodeKey struct{}
+
+// NewID returns a new unique lineage identifier.
+func NewID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Get returns the lineage node attached to a message part, or nil if the part
+// isn't being tracked.
+func Get(p types.Part) *Node {
+	n, _ := message.GetContext(p).Value(nodeKey{}).(*Node)
+	return n
+}
+
+func withNode(p types.Part, n *Node) types.Part {
+	return message.WithContext(context.WithValue(message.GetContext(p), nodeKey{}, n), p)
+}
+
+// Root begins tracking the lineage of a message part that has entered the
+// stream, where the part becomes its own origin. Edges of the part and any
+// messages derived from it are sent to the recorder, which can be nil. Parts
+// that are already tracked are returned unchanged.
+func Root(label string, p types.Part, recorder Recorder) types.Part {
+	if Get(p) != nil {
+		return p
+	}
+	id := NewID()
+	if recorder != nil {
+		recorder.RecordEdges(Edge{
+			Child:     id,
+			Label:     label,
+			Timestamp: time.Now(),
+		})
+	}
+	return withNode(p, &Node{
+		id:       id,
+		origins:  []string{id},
+		recorder: recorder,
+	})
+}
+
+// RootBatch begins tracking the lineage of each part of a message batch.
+func RootBatch(label string, msg types.Message, recorder Recorder) {
+	parts := make([]types.Part, msg.Len())
+	_ = msg.Iter(func(i int, p types.Part) error {
+		parts[i] = Root(label, p, recorder)
+		return nil
+	})
+	msg.SetAll(parts)
+}
+
+// Derive marks a message part as having been derived from one or more parent
+// parts by a component, giving the child a new identifier along with the
+// combined origins of its parents, and recording an edge for each parent. If
+// none of the parents are tracked then the child is returned unchanged.
+//
+// The child can be a shallow copy of a parent, as the lineage of the parents is
+// resolved before the child is modified.
+func Derive(label string, child types.Part, parents ...types.Part) types.Part {
+	var nodes []*Node
+	for _, p := range parents {
+		if n := Get(p); n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		return child
+	}
+
+	id := NewID()
+
+	var origins []string
+	if len(nodes) == 1 {
+		origins = nodes[0].origins
+	} else {
+		seen := map[string]struct{}{}
+		for _, n := range nodes {
+			for _, o := range n.origins {
+				if _, exists := seen[o]; !exists {
+					seen[o] = struct{}{}
+					origins = append(origins, o)
+				}
+			}
+		}
+		sort.Strings(origins)
+	}
+
+	var recorder Recorder
+	for _, n := range nodes {
+		if n.recorder != nil {
+			recorder = n.recorder
+			break
+		}
+	}
+	if recorder != nil {
+		now := time.Now()
+		edges := make([]Edge, 0, len(nodes))
+		for _, n := range nodes {
+			edges = append(edges, Edge{
+				Parent:    n.id,
+				Child:     id,
+				Label:     label,
+				Timestamp: now,
+			})
+		}
+		recorder.RecordEdges(edges...)
+	}
+
+	return withNode(child, &Node{
+		id:       id,
+		origins:  origins,
+		recorder: recorder,
+	})
+}
+
+// DeriveEach replaces each part of a message batch with a child derived from
+// it, which is useful for components that copy messages to multiple
+// destinations.
+func DeriveEach(label string, msg types.Message) {
+	tracked := false
+	parts := make([]types.Part, msg.Len())
+	_ = msg.Iter(func(i int, p types.Part) error {
+		if parts[i] = Derive(label, p, p); parts[i] != p {
+			tracked = true
+		}
+		return nil
+	})
+	if tracked {
+		msg.SetAll(parts)
+	}
+}
+
+// ComponentLabel returns the label of the component that a manager belongs
+// to, or a fallback label if the manager does not have one.
+func ComponentLabel(mgr types.Manager, fallback string) string {
+	if m, ok := mgr.(interface {
+		Label() string
+	}); ok {
+		if l := m.Label(); l != "" {
+			return l
+		}
+	}
+	return fallback
+}
+
+// DeriveFromBatch marks a message part as having been derived from all parts
+// of a message batch, which is useful for components that combine batches.
+func DeriveFromBatch(label string, child types.Part, msg types.Message) types.Part {
+	parents := make([]types.Part, 0, msg.Len())
+	_ = msg.Iter(func(i int, p types.Part) error {
+		parents = append(parents, p)
+		return nil
+	})
+	return Derive(label, child, parents...)
+}
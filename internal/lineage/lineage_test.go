@@ -0,0 +1,102 @@
+package lineage_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/lineage"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type edgeCollector struct {
+	mut   sync.Mutex
+	edges []lineage.Edge
+}
+
+func (c *edgeCollector) RecordEdges(edges ...lineage.Edge) {
+	c.mut.Lock()
+	c.edges = append(c.edges, edges...)
+	c.mut.Unlock()
+}
+
+func TestLineageUntracked(t *testing.T) {
+	part := message.NewPart([]byte("foo"))
+	assert.Nil(t, lineage.Get(part))
+
+	child := lineage.Derive("foo", part.Copy(), part)
+	assert.Nil(t, lineage.Get(child))
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	lineage.DeriveEach("foo", msg)
+	assert.Nil(t, lineage.Get(msg.Get(0)))
+	assert.Nil(t, lineage.Get(msg.Get(1)))
+}
+
+func TestLineageDerive(t *testing.T) {
+	rec := &edgeCollector{}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	lineage.RootBatch("in", msg, rec)
+
+	fooNode, barNode := lineage.Get(msg.Get(0)), lineage.Get(msg.Get(1))
+	require.NotNil(t, fooNode)
+	require.NotNil(t, barNode)
+	assert.Len(t, fooNode.ID(), 16)
+	assert.NotEqual(t, fooNode.ID(), barNode.ID())
+	assert.Equal(t, []string{fooNode.ID()}, fooNode.Origins())
+
+	// Rooting a tracked part again does nothing.
+	assert.Equal(t, msg.Get(0), lineage.Root("in", msg.Get(0), rec))
+
+	split := lineage.Derive("splitter", msg.Get(0).Copy(), msg.Get(0))
+	splitNode := lineage.Get(split)
+	require.NotNil(t, splitNode)
+	assert.NotEqual(t, fooNode.ID(), splitNode.ID())
+	assert.Equal(t, []string{fooNode.ID()}, splitNode.Origins())
+
+	combined := lineage.DeriveFromBatch("archiver", message.NewPart(nil), msg)
+	combinedNode := lineage.Get(combined)
+	require.NotNil(t, combinedNode)
+
+	expOrigins := []string{fooNode.ID(), barNode.ID()}
+	if expOrigins[0] > expOrigins[1] {
+		expOrigins[0], expOrigins[1] = expOrigins[1], expOrigins[0]
+	}
+	assert.Equal(t, expOrigins, combinedNode.Origins())
+
+	var stripped []lineage.Edge
+	for _, e := range rec.edges {
+		assert.False(t, e.Timestamp.IsZero())
+		stripped = append(stripped, lineage.Edge{Parent: e.Parent, Child: e.Child, Label: e.Label})
+	}
+	assert.Equal(t, []lineage.Edge{
+		{Child: fooNode.ID(), Label: "in"},
+		{Child: barNode.ID(), Label: "in"},
+		{Parent: fooNode.ID(), Child: splitNode.ID(), Label: "splitter"},
+		{Parent: fooNode.ID(), Child: combinedNode.ID(), Label: "archiver"},
+		{Parent: barNode.ID(), Child: combinedNode.ID(), Label: "archiver"},
+	}, stripped)
+}
+
+func TestLineageDeriveEach(t *testing.T) {
+	msg := message.New([][]byte{[]byte("foo")})
+	lineage.RootBatch("in", msg, nil)
+	rootNode := lineage.Get(msg.Get(0))
+	require.NotNil(t, rootNode)
+
+	copyA, copyB := msg.Copy(), msg.Copy()
+	lineage.DeriveEach("a", copyA)
+	lineage.DeriveEach("b", copyB)
+
+	nodeA, nodeB := lineage.Get(copyA.Get(0)), lineage.Get(copyB.Get(0))
+	require.NotNil(t, nodeA)
+	require.NotNil(t, nodeB)
+	assert.NotEqual(t, nodeA.ID(), nodeB.ID())
+	assert.Equal(t, []string{rootNode.ID()}, nodeA.Origins())
+	assert.Equal(t, []string{rootNode.ID()}, nodeB.Origins())
+
+	// The original message is unchanged.
+	assert.Equal(t, rootNode, lineage.Get(msg.Get(0)))
+}
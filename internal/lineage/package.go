@@ -0,0 +1,10 @@
+// Package lineage implements message-level lineage tracking, where each message
+// of a stream carries a compact identifier along with the set of identifiers of
+// the input messages it originated from. Components that derive new messages
+// from others (splitting, archiving, grouping, branching, etc) record edges
+// between parent and child messages, which can be emitted in order to
+// reconstruct the full lineage graph of any given message.
+//
+// When lineage tracking is disabled messages do not carry lineage information
+// and all functions within this package are cheap no-ops.
+package lineage
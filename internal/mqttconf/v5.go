@@ -0,0 +1,99 @@
+package mqttconf
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// Protocol versions that can be selected with the protocol_version field.
+const (
+	ProtocolV311 = "3.1.1"
+	ProtocolV5   = "5"
+)
+
+// V5Message returns the will as an MQTT 5 will message, or nil if the will is
+// not enabled.
+func (w *Will) V5Message() *paho.WillMessage {
+	if !w.Enabled {
+		return nil
+	}
+	return &paho.WillMessage{
+		Retain:  w.Retained,
+		QoS:     w.QoS,
+		Topic:   w.Topic,
+		Payload: []byte(w.Payload),
+	}
+}
+
+// closeNotifyConn is a net.Conn that signals when it has been closed, which is
+// how an MQTT 5 client communicates that it has stopped.
+type closeNotifyConn struct {
+	net.Conn
+
+	closeOnce  sync.Once
+	closedChan chan struct{}
+}
+
+func (c *closeNotifyConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		close(c.closedChan)
+	})
+	return err
+}
+
+// DialV5 establishes a network connection for an MQTT 5 client with the first
+// of a list of broker URLs that can be reached. The URL schemes tcp and mqtt
+// result in a plain connection, and the schemes ssl, tls, tcps and mqtts result
+// in a TLS connection. When a TLS config is provided it is used for all
+// connections.
+//
+// The returned channel is closed once the connection has been closed.
+func DialV5(ctx context.Context, urls []string, tlsConf *tls.Config) (net.Conn, <-chan struct{}, error) {
+	if len(urls) == 0 {
+		return nil, nil, errors.New("no broker urls provided")
+	}
+
+	var dialer net.Dialer
+	var err error
+	for _, u := range urls {
+		var brokerURL *url.URL
+		if brokerURL, err = url.Parse(u); err != nil {
+			err = fmt.Errorf("failed to parse broker url '%v': %w", u, err)
+			continue
+		}
+
+		var conn net.Conn
+		switch brokerURL.Scheme {
+		case "tcp", "mqtt":
+			if tlsConf != nil {
+				conn, err = (&tls.Dialer{NetDialer: &dialer, Config: tlsConf}).DialContext(ctx, "tcp", brokerURL.Host)
+			} else {
+				conn, err = dialer.DialContext(ctx, "tcp", brokerURL.Host)
+			}
+		case "ssl", "tls", "tcps", "mqtts":
+			conf := tlsConf
+			if conf == nil {
+				conf = &tls.Config{}
+			}
+			conn, err = (&tls.Dialer{NetDialer: &dialer, Config: conf}).DialContext(ctx, "tcp", brokerURL.Host)
+		default:
+			err = fmt.Errorf("broker url scheme '%v' is not supported with protocol version %v", brokerURL.Scheme, ProtocolV5)
+		}
+		if err == nil {
+			notifyConn := &closeNotifyConn{
+				Conn:       conn,
+				closedChan: make(chan struct{}),
+			}
+			return notifyConn, notifyConn.closedChan, nil
+		}
+	}
+	return nil, nil, err
+}
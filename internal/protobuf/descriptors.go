@@ -0,0 +1,99 @@
+// Package protobuf contains utilities for loading protobuf descriptors from
+// .proto files at runtime.
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+// LoadDescriptors walks each import path (or the current directory when none
+// are provided) and parses all .proto files found within them.
+func LoadDescriptors(importPaths []string) ([]*desc.FileDescriptor, error) {
+	var parser protoparse.Parser
+	if len(importPaths) == 0 {
+		importPaths = []string{"."}
+	} else {
+		parser.ImportPaths = importPaths
+	}
+
+	var files []string
+	for _, importPath := range importPaths {
+		if err := filepath.Walk(importPath, func(path string, info os.FileInfo, ferr error) error {
+			if ferr != nil || info.IsDir() {
+				return ferr
+			}
+			if filepath.Ext(info.Name()) == ".proto" {
+				rPath, ferr := filepath.Rel(importPath, path)
+				if ferr != nil {
+					return fmt.Errorf("failed to get relative path: %v", ferr)
+				}
+				files = append(files, rPath)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	fds, err := parser.ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .proto file: %v", err)
+	}
+	if len(fds) == 0 {
+		return nil, fmt.Errorf("no .proto files were found in the paths '%v'", importPaths)
+	}
+
+	return fds, err
+}
+
+// FindMessage returns the descriptor of a message by its fully qualified name
+// from a slice of file descriptors, or nil if it does not exist.
+func FindMessage(message string, fds []*desc.FileDescriptor) *desc.MessageDescriptor {
+	var msg *desc.MessageDescriptor
+	for _, fd := range fds {
+		msg = fd.FindMessage(message)
+		if msg != nil {
+			break
+		}
+	}
+	return msg
+}
+
+// SplitMethodName splits a fully qualified method name into the names of its
+// service and method. Both the form `package.Service/Method`, as used by gRPC,
+// and `package.Service.Method` are supported.
+func SplitMethodName(method string) (service, name string, err error) {
+	method = strings.TrimPrefix(method, "/")
+	i := strings.LastIndex(method, "/")
+	if i < 0 {
+		i = strings.LastIndex(method, ".")
+	}
+	if i <= 0 || i == len(method)-1 {
+		return "", "", fmt.Errorf("method '%v' must be of the form package.Service/Method", method)
+	}
+	return method[:i], method[i+1:], nil
+}
+
+// FindMethod returns the descriptor of a method by its fully qualified name
+// from a slice of file descriptors, or nil if it does not exist.
+func FindMethod(method string, fds []*desc.FileDescriptor) (*desc.MethodDescriptor, error) {
+	serviceName, methodName, err := SplitMethodName(method)
+	if err != nil {
+		return nil, err
+	}
+	for _, fd := range fds {
+		if svc := fd.FindService(serviceName); svc != nil {
+			if md := svc.FindMethodByName(methodName); md != nil {
+				return md, nil
+			}
+			return nil, fmt.Errorf("method '%v' was not found within service '%v'", methodName, serviceName)
+		}
+	}
+	return nil, fmt.Errorf("service '%v' was not found", serviceName)
+}
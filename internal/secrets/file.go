@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileProvider reads secrets from files, which is useful for secrets mounted
+// into a container by an orchestrator such as Kubernetes. Without a key the
+// entire contents of the file (with trailing newlines trimmed) is the secret,
+// otherwise the file is parsed as JSON or YAML and the key is a dot path to
+// the secret value within it.
+type FileProvider struct{}
+
+// Lookup reads a secret from a file.
+func (FileProvider) Lookup(ctx context.Context, path, key string) (string, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return strings.TrimRight(string(fileBytes), "\r\n"), nil
+	}
+
+	var root interface{}
+	if err := yaml.Unmarshal(fileBytes, &root); err != nil {
+		return "", fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	return valueAtKey(root, key)
+}
+
+func valueAtKey(root interface{}, key string) (string, error) {
+	v := gabs.Wrap(root).Path(key).Data()
+	switch t := v.(type) {
+	case nil:
+		return "", fmt.Errorf("key %v not found", key)
+	case string:
+		return t, nil
+	case map[string]interface{}, []interface{}:
+		jBytes, err := json.Marshal(t)
+		if err != nil {
+			return "", err
+		}
+		return string(jBytes), nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
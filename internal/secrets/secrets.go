@@ -0,0 +1,272 @@
+// Package secrets provides a mechanism for resolving secret references within
+// configs from external providers, of the form ${secret:provider:path#key}.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var secretRegex = regexp.MustCompile(`\${secret:([0-9A-Za-z_\-]+):([^#}]+)(#([^}]+))?}`)
+
+// Provider is a source of secret values.
+type Provider interface {
+	// Lookup obtains the value of a secret at a path, where key is optional
+	// and identifies a single field of a secret that contains multiple
+	// values.
+	Lookup(ctx context.Context, path, key string) (string, error)
+}
+
+// ErrProviderNotFound is returned when a secret reference targets a provider
+// that has not been registered.
+var ErrProviderNotFound = errors.New("secrets provider not found")
+
+type reference struct {
+	provider string
+	path     string
+	key      string
+}
+
+// Store resolves secret references with a collection of providers and keeps
+// track of the values that have been resolved, allowing them to be refreshed
+// and redacted.
+type Store struct {
+	providersMut sync.RWMutex
+	providers    map[string]Provider
+
+	resolvedMut sync.RWMutex
+	resolved    map[string]string
+	refs        map[string]reference
+}
+
+// NewStore creates a secrets store with the built-in file and vault providers
+// registered.
+func NewStore() *Store {
+	return &Store{
+		providers: map[string]Provider{
+			"file":  FileProvider{},
+			"vault": envVaultProvider{},
+		},
+		resolved: map[string]string{},
+		refs:     map[string]reference{},
+	}
+}
+
+// RegisterProvider adds a provider to the store under a name, replacing any
+// existing provider of the same name.
+func (s *Store) RegisterProvider(name string, p Provider) {
+	s.providersMut.Lock()
+	s.providers[name] = p
+	s.providersMut.Unlock()
+}
+
+func (s *Store) lookup(ctx context.Context, ref reference) (string, error) {
+	s.providersMut.RLock()
+	p, exists := s.providers[ref.provider]
+	s.providersMut.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("%w: %v", ErrProviderNotFound, ref.provider)
+	}
+	return p.Lookup(ctx, ref.path, ref.key)
+}
+
+// Replace searches a blob of data for secret references and replaces them with
+// the value obtained from the targeted provider. References escaped with double
+// brackets (e.g. ${{secret:file:foo}}) are ignored and left in place for the
+// environment variable escaping to handle.
+//
+// When interpolate is not nil it is applied to the data before the values of
+// secrets are inserted, with the references held out of it, so that
+// environment variables can be interpolated without expanding the values of
+// secrets or resolving references within the values of variables.
+func (s *Store) Replace(ctx context.Context, inBytes []byte, interpolate func([]byte) []byte) ([]byte, error) {
+	matches := secretRegex.FindAllSubmatchIndex(inBytes, -1)
+	if len(matches) == 0 {
+		if interpolate != nil {
+			return interpolate(inBytes), nil
+		}
+		return inBytes, nil
+	}
+
+	values := make([]string, len(matches))
+	for i, m := range matches {
+		refStr := string(inBytes[m[0]:m[1]])
+		ref := reference{
+			provider: string(inBytes[m[2]:m[3]]),
+			path:     string(inBytes[m[4]:m[5]]),
+		}
+		if m[8] != -1 {
+			ref.key = string(inBytes[m[8]:m[9]])
+		}
+
+		value, err := s.lookup(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret %v: %w", refStr, err)
+		}
+
+		s.resolvedMut.Lock()
+		s.resolved[refStr] = value
+		s.refs[refStr] = ref
+		s.resolvedMut.Unlock()
+
+		// Escape newlines, otherwise there's no way that they would work
+		// within a config.
+		values[i] = strings.ReplaceAll(value, "\n", "\\n")
+	}
+
+	var buf bytes.Buffer
+	lastIndex := 0
+	for i, m := range matches {
+		buf.Write(inBytes[lastIndex:m[0]])
+		if interpolate == nil {
+			buf.WriteString(values[i])
+		} else {
+			// References are held out of the interpolation with placeholders
+			// containing null bytes, which can't otherwise occur within a
+			// config.
+			buf.WriteString(secretPlaceholder(i))
+		}
+		lastIndex = m[1]
+	}
+	buf.Write(inBytes[lastIndex:])
+	if interpolate == nil {
+		return buf.Bytes(), nil
+	}
+
+	outBytes := interpolate(buf.Bytes())
+	for i, v := range values {
+		outBytes = bytes.ReplaceAll(outBytes, []byte(secretPlaceholder(i)), []byte(v))
+	}
+	return outBytes, nil
+}
+
+func secretPlaceholder(i int) string {
+	return fmt.Sprintf("\x00secret%v\x00", i)
+}
+
+// Escape converts all secret references within a blob of data into their
+// escaped form (e.g. ${{secret:file:foo}}), which allows environment variables
+// to be replaced without resolving secrets, and leaves the references in place
+// once the environment variable escaping has been handled.
+func Escape(inBytes []byte) []byte {
+	return secretRegex.ReplaceAllFunc(inBytes, func(ref []byte) []byte {
+		escaped := make([]byte, 0, len(ref)+2)
+		escaped = append(escaped, "${"...)
+		escaped = append(escaped, ref[1:]...)
+		return append(escaped, '}')
+	})
+}
+
+// Refresh looks up all secrets that have previously been resolved and returns
+// the references of those where the value has changed.
+func (s *Store) Refresh(ctx context.Context) (changed []string, err error) {
+	s.resolvedMut.RLock()
+	refs := make(map[string]reference, len(s.refs))
+	for k, v := range s.refs {
+		refs[k] = v
+	}
+	s.resolvedMut.RUnlock()
+
+	for refStr, ref := range refs {
+		value, lErr := s.lookup(ctx, ref)
+		if lErr != nil {
+			err = fmt.Errorf("failed to refresh secret %v: %w", refStr, lErr)
+			continue
+		}
+
+		s.resolvedMut.Lock()
+		if s.resolved[refStr] != value {
+			s.resolved[refStr] = value
+			changed = append(changed, refStr)
+		}
+		s.resolvedMut.Unlock()
+	}
+	sort.Strings(changed)
+	return
+}
+
+// Redact replaces all occurrences of the values of resolved secrets within a
+// string with their references. Values are replaced longest first so that a
+// secret that contains another isn't partially redacted.
+func (s *Store) Redact(str string) string {
+	s.resolvedMut.RLock()
+	redactions := make([][2]string, 0, len(s.resolved)*2)
+	for refStr, value := range s.resolved {
+		if value == "" {
+			continue
+		}
+		redactions = append(redactions, [2]string{value, refStr})
+		if escaped := strings.ReplaceAll(value, "\n", "\\n"); escaped != value {
+			redactions = append(redactions, [2]string{escaped, refStr})
+		}
+	}
+	s.resolvedMut.RUnlock()
+
+	sort.Slice(redactions, func(i, j int) bool {
+		if len(redactions[i][0]) != len(redactions[j][0]) {
+			return len(redactions[i][0]) > len(redactions[j][0])
+		}
+		return redactions[i][0] < redactions[j][0]
+	})
+	for _, r := range redactions {
+		str = strings.ReplaceAll(str, r[0], r[1])
+	}
+	return str
+}
+
+// RedactYAML walks a YAML node and redacts the values of resolved secrets from
+// all scalars.
+func (s *Store) RedactYAML(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		if redacted := s.Redact(node.Value); redacted != node.Value {
+			node.Value = redacted
+			node.Style = 0
+			node.Tag = "!!str"
+		}
+		return
+	}
+	for _, c := range node.Content {
+		s.RedactYAML(c)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var globalStore = NewStore()
+
+// RegisterProvider adds a provider to the global secrets store.
+func RegisterProvider(name string, p Provider) {
+	globalStore.RegisterProvider(name, p)
+}
+
+// Replace resolves secret references within a blob of data using the global
+// secrets store, applying interpolate (when not nil) beforehand.
+func Replace(ctx context.Context, inBytes []byte, interpolate func([]byte) []byte) ([]byte, error) {
+	return globalStore.Replace(ctx, inBytes, interpolate)
+}
+
+// Refresh looks up all secrets previously resolved by the global secrets store
+// and returns the references of those that have changed.
+func Refresh(ctx context.Context) ([]string, error) {
+	return globalStore.Refresh(ctx)
+}
+
+// Redact replaces the values of secrets resolved by the global secrets store
+// within a string with their references.
+func Redact(str string) string {
+	return globalStore.Redact(str)
+}
+
+// RedactYAML redacts the values of secrets resolved by the global secrets store
+// from the scalars of a YAML node.
+func RedactYAML(node *yaml.Node) {
+	globalStore.RedactYAML(node)
+}
@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider reads secrets from a HashiCorp Vault KV secrets engine. Paths
+// are of the form `<mount>/<secret path>`, e.g. `secret/myapp/db`, and keys
+// identify a field within the secret. When a key is omitted the entire secret
+// is returned as a JSON object.
+type VaultProvider struct {
+	Address   string
+	Token     string
+	Namespace string
+
+	// KVVersion is the version of the KV secrets engine, either 1 or 2.
+	KVVersion int
+
+	Client *http.Client
+}
+
+// NewVaultProviderFromEnv creates a vault provider configured with the standard
+// Vault environment variables VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE. The
+// version of the KV engine defaults to 2 and can be set with VAULT_KV_VERSION.
+func NewVaultProviderFromEnv() *VaultProvider {
+	v := &VaultProvider{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		KVVersion: 2,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if v.Address == "" {
+		v.Address = "http://127.0.0.1:8200"
+	}
+	if os.Getenv("VAULT_KV_VERSION") == "1" {
+		v.KVVersion = 1
+	}
+	return v
+}
+
+// envVaultProvider configures a vault provider from environment variables at
+// the time of each lookup, as variables may be set after initialisation (e.g.
+// from a dotenv file).
+type envVaultProvider struct{}
+
+func (envVaultProvider) Lookup(ctx context.Context, path, key string) (string, error) {
+	return NewVaultProviderFromEnv().Lookup(ctx, path, key)
+}
+
+func (v *VaultProvider) secretURL(path string) (string, error) {
+	path = strings.Trim(path, "/")
+	if v.KVVersion == 1 {
+		return fmt.Sprintf("%v/v1/%v", strings.TrimSuffix(v.Address, "/"), path), nil
+	}
+	slashIndex := strings.Index(path, "/")
+	if slashIndex == -1 {
+		return "", fmt.Errorf("expected path of the form <mount>/<secret>, got %v", path)
+	}
+	mount, secret := path[:slashIndex], path[slashIndex+1:]
+	return fmt.Sprintf("%v/v1/%v/data/%v", strings.TrimSuffix(v.Address, "/"), mount, secret), nil
+}
+
+// Lookup reads a secret from Vault.
+func (v *VaultProvider) Lookup(ctx context.Context, path, key string) (string, error) {
+	secretURL, err := v.secretURL(path)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if v.Token != "" {
+		req.Header.Set("X-Vault-Token", v.Token)
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %v: %s", res.StatusCode, strings.TrimSpace(string(resBytes)))
+	}
+
+	var resBody struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(resBytes, &resBody); err != nil {
+		return "", fmt.Errorf("failed to parse vault response: %w", err)
+	}
+
+	data := resBody.Data
+	if v.KVVersion != 1 {
+		var ok bool
+		if data, ok = resBody.Data["data"].(map[string]interface{}); !ok {
+			return "", errors.New("vault response did not contain KV version 2 data")
+		}
+	}
+	if key == "" {
+		jBytes, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		return string(jBytes), nil
+	}
+	return valueAtKey(data, key)
+}
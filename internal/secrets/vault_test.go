@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProviderKVv2(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "footoken" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "foons", r.Header.Get("X-Vault-Namespace"))
+		if r.URL.Path != "/v1/secret/data/myapp/db" {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"user":"foo","password":"hunter2"},"metadata":{"version":3}}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	v := &VaultProvider{
+		Address:   ts.URL,
+		Token:     "footoken",
+		Namespace: "foons",
+		KVVersion: 2,
+	}
+
+	res, err := v.Lookup(context.Background(), "secret/myapp/db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", res)
+
+	res, err = v.Lookup(context.Background(), "secret/myapp/db", "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"password":"hunter2","user":"foo"}`, res)
+
+	_, err = v.Lookup(context.Background(), "secret/myapp/nope", "password")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vault returned status 404")
+
+	_, err = v.Lookup(context.Background(), "secret/myapp/db", "nope")
+	require.EqualError(t, err, "key nope not found")
+
+	v.Token = "bartoken"
+	_, err = v.Lookup(context.Background(), "secret/myapp/db", "password")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+func TestVaultProviderKVv1(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/myapp" {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"password":"hunter2"}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	store := NewStore()
+	store.RegisterProvider("vault", &VaultProvider{
+		Address:   ts.URL,
+		KVVersion: 1,
+	})
+
+	res, err := store.Replace(context.Background(), []byte(`password: ${secret:vault:kv/myapp#password}`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "password: hunter2", string(res))
+}
@@ -0,0 +1,222 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	// Each new layer of a scalable filter doubles the capacity of the last.
+	bloomGrowthFactor = 2
+
+	// Each new layer of a scalable filter halves the false positive rate of
+	// the last, which bounds the compound rate of all layers to the target.
+	bloomTighteningRatio = 0.5
+
+	bloomSerialisationVersion = 1
+)
+
+type bloomLayer struct {
+	hashes   uint32
+	capacity uint64
+	count    uint64
+	bits     []uint64
+}
+
+func newBloomLayer(capacity uint64, fpRate float64) *bloomLayer {
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	words := uint64(math.Ceil(m / 64))
+	if words == 0 {
+		words = 1
+	}
+	k := uint32(math.Round(m / float64(capacity) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &bloomLayer{
+		hashes:   k,
+		capacity: capacity,
+		bits:     make([]uint64, words),
+	}
+}
+
+func (l *bloomLayer) test(h1, h2 uint64) bool {
+	m := uint64(len(l.bits)) * 64
+	for i := uint64(0); i < uint64(l.hashes); i++ {
+		bit := (h1 + i*h2) % m
+		if l.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *bloomLayer) add(h1, h2 uint64) {
+	m := uint64(len(l.bits)) * 64
+	for i := uint64(0); i < uint64(l.hashes); i++ {
+		bit := (h1 + i*h2) % m
+		l.bits[bit/64] |= 1 << (bit % 64)
+	}
+	l.count++
+}
+
+// ScalableBloomFilter tests whether values have been added to it with a
+// bounded rate of false positives and no false negatives. The filter grows by
+// adding layers of increasing capacity as it fills, and therefore doesn't
+// require the total number of values to be known in advance.
+type ScalableBloomFilter struct {
+	initialCapacity uint64
+	fpRate          float64
+	layers          []*bloomLayer
+}
+
+// NewScalableBloomFilter creates an empty filter with an initial capacity and
+// a target false positive rate between 0 and 1 (exclusive).
+func NewScalableBloomFilter(initialCapacity int, fpRate float64) (*ScalableBloomFilter, error) {
+	if initialCapacity < 1 {
+		return nil, fmt.Errorf("initial capacity must be greater than zero, got %v", initialCapacity)
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1, got %v", fpRate)
+	}
+	return &ScalableBloomFilter{
+		initialCapacity: uint64(initialCapacity),
+		fpRate:          fpRate,
+	}, nil
+}
+
+func bloomHashes(v []byte) (h1, h2 uint64) {
+	h1 = xxhash.Checksum64(v)
+	// Ensure the second hash is odd so that probes never collapse onto a
+	// single bit.
+	h2 = xxhash.Checksum64S(v, h1) | 1
+	return
+}
+
+// Test returns true if the value has possibly been added to the filter, and
+// false if it definitely hasn't.
+func (b *ScalableBloomFilter) Test(v []byte) bool {
+	h1, h2 := bloomHashes(v)
+	for i := len(b.layers) - 1; i >= 0; i-- {
+		if b.layers[i].test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAndAdd returns the result of Test for a value and adds it to the filter
+// if it wasn't already present.
+func (b *ScalableBloomFilter) TestAndAdd(v []byte) bool {
+	h1, h2 := bloomHashes(v)
+	for i := len(b.layers) - 1; i >= 0; i-- {
+		if b.layers[i].test(h1, h2) {
+			return true
+		}
+	}
+
+	var layer *bloomLayer
+	if n := len(b.layers); n > 0 && b.layers[n-1].count < b.layers[n-1].capacity {
+		layer = b.layers[n-1]
+	} else {
+		capacity := b.initialCapacity * uint64(math.Pow(bloomGrowthFactor, float64(n)))
+		fpRate := b.fpRate * (1 - bloomTighteningRatio) * math.Pow(bloomTighteningRatio, float64(n))
+		layer = newBloomLayer(capacity, fpRate)
+		b.layers = append(b.layers, layer)
+	}
+	layer.add(h1, h2)
+	return false
+}
+
+// Count returns the number of distinct values added to the filter, which may
+// be an underestimate due to false positives.
+func (b *ScalableBloomFilter) Count() uint64 {
+	var c uint64
+	for _, l := range b.layers {
+		c += l.count
+	}
+	return c
+}
+
+// MarshalBinary serialises the filter.
+func (b *ScalableBloomFilter) MarshalBinary() ([]byte, error) {
+	size := 1 + 8 + 8 + 4
+	for _, l := range b.layers {
+		size += 4 + 8 + 8 + 8 + len(l.bits)*8
+	}
+
+	data := make([]byte, size)
+	data[0] = bloomSerialisationVersion
+	binary.BigEndian.PutUint64(data[1:], b.initialCapacity)
+	binary.BigEndian.PutUint64(data[9:], math.Float64bits(b.fpRate))
+	binary.BigEndian.PutUint32(data[17:], uint32(len(b.layers)))
+
+	offset := 21
+	for _, l := range b.layers {
+		binary.BigEndian.PutUint32(data[offset:], l.hashes)
+		binary.BigEndian.PutUint64(data[offset+4:], l.capacity)
+		binary.BigEndian.PutUint64(data[offset+12:], l.count)
+		binary.BigEndian.PutUint64(data[offset+20:], uint64(len(l.bits)))
+		offset += 28
+		for _, w := range l.bits {
+			binary.BigEndian.PutUint64(data[offset:], w)
+			offset += 8
+		}
+	}
+	return data, nil
+}
+
+var errBloomMalformed = errors.New("serialised filter is malformed")
+
+// UnmarshalBinary replaces the filter with a serialised one.
+func (b *ScalableBloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 21 {
+		return errBloomMalformed
+	}
+	if data[0] != bloomSerialisationVersion {
+		return fmt.Errorf("serialised filter version %v not recognised", data[0])
+	}
+
+	initialCapacity := binary.BigEndian.Uint64(data[1:])
+	fpRate := math.Float64frombits(binary.BigEndian.Uint64(data[9:]))
+	nLayers := binary.BigEndian.Uint32(data[17:])
+	if initialCapacity == 0 || fpRate <= 0 || fpRate >= 1 || nLayers > 64 {
+		return errBloomMalformed
+	}
+	data = data[21:]
+
+	layers := make([]*bloomLayer, 0, nLayers)
+	for i := uint32(0); i < nLayers; i++ {
+		if len(data) < 28 {
+			return errBloomMalformed
+		}
+		l := &bloomLayer{
+			hashes:   binary.BigEndian.Uint32(data),
+			capacity: binary.BigEndian.Uint64(data[4:]),
+			count:    binary.BigEndian.Uint64(data[12:]),
+		}
+		words := binary.BigEndian.Uint64(data[20:])
+		data = data[28:]
+		if l.hashes == 0 || words == 0 || words > uint64(len(data))/8 {
+			return errBloomMalformed
+		}
+		l.bits = make([]uint64, words)
+		for j := range l.bits {
+			l.bits[j] = binary.BigEndian.Uint64(data[j*8:])
+		}
+		data = data[words*8:]
+		layers = append(layers, l)
+	}
+	if len(data) > 0 {
+		return errBloomMalformed
+	}
+
+	b.initialCapacity = initialCapacity
+	b.fpRate = fpRate
+	b.layers = layers
+	return nil
+}
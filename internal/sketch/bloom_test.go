@@ -0,0 +1,64 @@
+package sketch
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScalableBloomFilterFalsePositives(t *testing.T) {
+	b, err := NewScalableBloomFilter(1000, 0.01)
+	require.NoError(t, err)
+
+	collisions := 0
+	for i := 0; i < 20000; i++ {
+		if b.TestAndAdd([]byte(strconv.Itoa(i))) {
+			collisions++
+		}
+	}
+	assert.Less(t, collisions, 200)
+	for i := 0; i < 20000; i++ {
+		require.True(t, b.Test([]byte(strconv.Itoa(i))), i)
+		require.True(t, b.TestAndAdd([]byte(strconv.Itoa(i))), i)
+	}
+	assert.Equal(t, uint64(20000-collisions), b.Count())
+	assert.Greater(t, len(b.layers), 1)
+
+	falsePositives := 0
+	for i := 20000; i < 120000; i++ {
+		if b.Test([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/100000, 0.01)
+}
+
+func TestScalableBloomFilterSerialise(t *testing.T) {
+	b, err := NewScalableBloomFilter(100, 0.001)
+	require.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		b.TestAndAdd([]byte(strconv.Itoa(i)))
+	}
+
+	data, err := b.MarshalBinary()
+	require.NoError(t, err)
+
+	c := &ScalableBloomFilter{}
+	require.NoError(t, c.UnmarshalBinary(data))
+	assert.Equal(t, b, c)
+
+	for i := 0; i < 500; i++ {
+		assert.True(t, c.Test([]byte(strconv.Itoa(i))), i)
+	}
+
+	assert.Error(t, c.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, c.UnmarshalBinary(nil))
+
+	_, err = NewScalableBloomFilter(0, 0.1)
+	assert.Error(t, err)
+	_, err = NewScalableBloomFilter(10, 1)
+	assert.Error(t, err)
+}
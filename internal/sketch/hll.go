@@ -0,0 +1,106 @@
+package sketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// HyperLogLog estimates the number of distinct values added to it.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// NewHyperLogLog creates an empty HyperLogLog with a precision between 4 and
+// 16, where the sketch consumes 2^precision bytes and has a standard error of
+// roughly 1.04/sqrt(2^precision).
+func NewHyperLogLog(precision int) (*HyperLogLog, error) {
+	if precision < 4 || precision > 16 {
+		return nil, fmt.Errorf("precision must be between 4 and 16, got %v", precision)
+	}
+	return &HyperLogLog{
+		precision: uint8(precision),
+		registers: make([]uint8, 1<<precision),
+	}, nil
+}
+
+// Add a value to the sketch.
+func (h *HyperLogLog) Add(v []byte) {
+	hash := xxhash.Checksum64(v)
+
+	idx := hash >> (64 - h.precision)
+	rho := uint8(bits.LeadingZeros64((hash<<h.precision)|(1<<(h.precision-1)))) + 1
+	if rho > h.registers[idx] {
+		h.registers[idx] = rho
+	}
+}
+
+// Merge the registers of another sketch of the same precision into this one.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other.precision != h.precision {
+		return fmt.Errorf("cannot merge sketches of precision %v and %v", h.precision, other.precision)
+	}
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Count returns the estimated number of distinct values added to the sketch.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Small range correction via linear counting.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary serialises the sketch.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(h.registers)+1)
+	b = append(b, h.precision)
+	return append(b, h.registers...), nil
+}
+
+// UnmarshalBinary replaces the sketch with a serialised one.
+func (h *HyperLogLog) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("serialised sketch is empty")
+	}
+	precision := b[0]
+	if precision < 4 || precision > 16 || len(b)-1 != 1<<precision {
+		return errors.New("serialised sketch is malformed")
+	}
+	h.precision = precision
+	h.registers = append([]uint8(nil), b[1:]...)
+	return nil
+}
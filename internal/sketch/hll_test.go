@@ -0,0 +1,51 @@
+package sketch
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		h, err := NewHyperLogLog(14)
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			h.Add([]byte(strconv.Itoa(i)))
+			h.Add([]byte(strconv.Itoa(i)))
+		}
+		assert.InEpsilon(t, float64(n)+1, float64(h.Count())+1, 0.03, "n = %v", n)
+	}
+}
+
+func TestHyperLogLogMergeAndSerialise(t *testing.T) {
+	a, err := NewHyperLogLog(12)
+	require.NoError(t, err)
+	b, err := NewHyperLogLog(12)
+	require.NoError(t, err)
+
+	for i := 0; i < 5000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i + 2500)))
+	}
+	require.NoError(t, a.Merge(b))
+	assert.InEpsilon(t, 7500, float64(a.Count()), 0.05)
+
+	data, err := a.MarshalBinary()
+	require.NoError(t, err)
+
+	c := &HyperLogLog{}
+	require.NoError(t, c.UnmarshalBinary(data))
+	assert.Equal(t, a.Count(), c.Count())
+
+	d, err := NewHyperLogLog(10)
+	require.NoError(t, err)
+	assert.Error(t, a.Merge(d))
+	assert.Error(t, c.UnmarshalBinary(data[:10]))
+
+	_, err = NewHyperLogLog(20)
+	assert.Error(t, err)
+}
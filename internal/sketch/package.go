@@ -0,0 +1,4 @@
+// Package sketch implements probabilistic data structures that summarise large
+// streams of values within a small and bounded amount of memory, and can be
+// serialised in order to be stored within caches.
+package sketch
@@ -0,0 +1,189 @@
+package sketch
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+type centroid struct {
+	mean  float64
+	count float64
+}
+
+// TDigest estimates quantiles of the values added to it, with greater
+// accuracy towards the extreme quantiles.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	unmerged    []centroid
+	count       float64
+	min, max    float64
+}
+
+// NewTDigest creates an empty TDigest with a compression factor, which bounds
+// the number of centroids retained. A compression of 100 is a common choice.
+func NewTDigest(compression float64) (*TDigest, error) {
+	if compression < 1 {
+		return nil, errors.New("compression must be at least 1")
+	}
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}, nil
+}
+
+// Add a value to the digest.
+func (t *TDigest) Add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	t.unmerged = append(t.unmerged, centroid{mean: v, count: 1})
+	t.count++
+	if v < t.min {
+		t.min = v
+	}
+	if v > t.max {
+		t.max = v
+	}
+	if len(t.unmerged) > int(t.compression)*5 {
+		t.compress()
+	}
+}
+
+// Count returns the number of values added to the digest.
+func (t *TDigest) Count() float64 {
+	return t.count
+}
+
+func (t *TDigest) scale(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.unmerged...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	current := all[0]
+	weightSoFar := 0.0
+	kLeft := t.scale(0)
+
+	for _, next := range all[1:] {
+		q := (weightSoFar + current.count + next.count) / t.count
+		if t.scale(q)-kLeft <= 1 {
+			current.mean += (next.mean - current.mean) * next.count / (current.count + next.count)
+			current.count += next.count
+			continue
+		}
+		weightSoFar += current.count
+		kLeft = t.scale(weightSoFar / t.count)
+		merged = append(merged, current)
+		current = next
+	}
+	merged = append(merged, current)
+
+	t.centroids = merged
+	t.unmerged = nil
+}
+
+// Quantile returns the estimated value at a quantile between 0 and 1, or NaN
+// if the digest is empty.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	if len(t.centroids) == 1 {
+		return t.centroids[0].mean
+	}
+
+	target := q * t.count
+
+	// Values before the centre of the first centroid are interpolated from
+	// the minimum.
+	first := t.centroids[0]
+	if target < first.count/2 {
+		return t.min + (first.mean-t.min)*target/(first.count/2)
+	}
+
+	cumulative := first.count / 2
+	for i := 1; i < len(t.centroids); i++ {
+		prev, next := t.centroids[i-1], t.centroids[i]
+		step := (prev.count + next.count) / 2
+		if target < cumulative+step {
+			return prev.mean + (next.mean-prev.mean)*(target-cumulative)/step
+		}
+		cumulative += step
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	remaining := last.count / 2
+	if remaining == 0 {
+		return t.max
+	}
+	return last.mean + (t.max-last.mean)*math.Min(1, (target-cumulative)/remaining)
+}
+
+type tdigestJSON struct {
+	Compression float64      `json:"compression"`
+	Min         float64      `json:"min"`
+	Max         float64      `json:"max"`
+	Centroids   [][2]float64 `json:"centroids"`
+}
+
+// MarshalJSON serialises the digest.
+func (t *TDigest) MarshalJSON() ([]byte, error) {
+	t.compress()
+	j := tdigestJSON{
+		Compression: t.compression,
+		Min:         t.min,
+		Max:         t.max,
+		Centroids:   make([][2]float64, len(t.centroids)),
+	}
+	if len(t.centroids) == 0 {
+		j.Min, j.Max = 0, 0
+	}
+	for i, c := range t.centroids {
+		j.Centroids[i] = [2]float64{c.mean, c.count}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON replaces the digest with a serialised one.
+func (t *TDigest) UnmarshalJSON(b []byte) error {
+	var j tdigestJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Compression < 1 {
+		return errors.New("compression must be at least 1")
+	}
+	t.compression = j.Compression
+	t.unmerged = nil
+	t.centroids = make([]centroid, len(j.Centroids))
+	t.count = 0
+	for i, c := range j.Centroids {
+		t.centroids[i] = centroid{mean: c[0], count: c[1]}
+		t.count += c[1]
+	}
+	t.min, t.max = j.Min, j.Max
+	if len(t.centroids) == 0 {
+		t.min, t.max = math.Inf(1), math.Inf(-1)
+	}
+	return nil
+}
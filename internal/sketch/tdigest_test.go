@@ -0,0 +1,67 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTDigestQuantiles(t *testing.T) {
+	td, err := NewTDigest(100)
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(td.Quantile(0.5)))
+
+	r := rand.New(rand.NewSource(1))
+	for _, i := range r.Perm(10001) {
+		td.Add(float64(i))
+	}
+
+	assert.Equal(t, float64(10001), td.Count())
+	assert.Equal(t, float64(0), td.Quantile(0))
+	assert.Equal(t, float64(10000), td.Quantile(1))
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assert.InDelta(t, q*10000, td.Quantile(q), 10000*0.005, "q = %v", q)
+	}
+}
+
+func TestTDigestSingleValue(t *testing.T) {
+	td, err := NewTDigest(100)
+	require.NoError(t, err)
+
+	td.Add(5)
+	assert.Equal(t, float64(5), td.Quantile(0.5))
+	assert.Equal(t, float64(5), td.Quantile(0.99))
+}
+
+func TestTDigestSerialise(t *testing.T) {
+	td, err := NewTDigest(50)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		td.Add(float64(i))
+	}
+
+	data, err := json.Marshal(td)
+	require.NoError(t, err)
+
+	other := &TDigest{}
+	require.NoError(t, json.Unmarshal(data, other))
+	assert.Equal(t, td.Count(), other.Count())
+	assert.Equal(t, td.Quantile(0.5), other.Quantile(0.5))
+
+	other.Add(2000)
+	assert.Equal(t, float64(2000), other.Quantile(1))
+
+	empty, err := NewTDigest(50)
+	require.NoError(t, err)
+	data, err = json.Marshal(empty)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, other))
+	assert.True(t, math.IsNaN(other.Quantile(0.5)))
+
+	_, err = NewTDigest(0)
+	assert.Error(t, err)
+}
@@ -5,12 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
 	"github.com/Jeffail/benthos/v3/internal/bloblang/parser"
-	"github.com/Jeffail/benthos/v3/internal/component/metrics"
+	imetrics "github.com/Jeffail/benthos/v3/internal/component/metrics"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/service/test"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/fatih/color"
 	"github.com/nsf/jsondiff"
@@ -29,23 +35,27 @@ type FieldConfig struct {
 
 // TestConfig defines a unit test for the template.
 type TestConfig struct {
-	Name     string    `yaml:"name"`
-	Config   yaml.Node `yaml:"config"`
-	Expected yaml.Node `yaml:"expected,omitempty"`
+	Name              string                 `yaml:"name"`
+	Config            yaml.Node              `yaml:"config"`
+	Expected          yaml.Node              `yaml:"expected,omitempty"`
+	ExpectedResources yaml.Node              `yaml:"expected_resources,omitempty"`
+	InputBatch        []test.InputPart       `yaml:"input_batch,omitempty"`
+	OutputBatches     [][]test.ConditionsMap `yaml:"output_batches,omitempty"`
 }
 
 // Config describes a Benthos component template.
 type Config struct {
-	Name           string        `yaml:"name"`
-	Type           string        `yaml:"type"`
-	Status         string        `yaml:"status"`
-	Categories     []string      `yaml:"categories"`
-	Summary        string        `yaml:"summary"`
-	Description    string        `yaml:"description"`
-	Fields         []FieldConfig `yaml:"fields"`
-	Mapping        string        `yaml:"mapping"`
-	MetricsMapping string        `yaml:"metrics_mapping"`
-	Tests          []TestConfig  `yaml:"tests"`
+	Name             string        `yaml:"name"`
+	Type             string        `yaml:"type"`
+	Status           string        `yaml:"status"`
+	Categories       []string      `yaml:"categories"`
+	Summary          string        `yaml:"summary"`
+	Description      string        `yaml:"description"`
+	Fields           []FieldConfig `yaml:"fields"`
+	Mapping          string        `yaml:"mapping"`
+	ResourcesMapping string        `yaml:"resources_mapping"`
+	MetricsMapping   string        `yaml:"metrics_mapping"`
+	Tests            []TestConfig  `yaml:"tests"`
 }
 
 // FieldSpec creates a documentation field spec from a template field config.
@@ -106,6 +116,7 @@ func (c Config) compile() (*compiled, error) {
 	if err != nil {
 		return nil, err
 	}
+	var resourcesMapping *mapping.Executor
 	mapping, err := bloblang.GlobalEnvironment().NewMapping(c.Mapping)
 	if err != nil {
 		var perr *parser.Error
@@ -114,13 +125,22 @@ func (c Config) compile() (*compiled, error) {
 		}
 		return nil, fmt.Errorf("parse mapping: %w", err)
 	}
-	var metricsMapping *metrics.Mapping
+	if c.ResourcesMapping != "" {
+		if resourcesMapping, err = bloblang.GlobalEnvironment().NewMapping(c.ResourcesMapping); err != nil {
+			var perr *parser.Error
+			if errors.As(err, &perr) {
+				return nil, fmt.Errorf("parse resources mapping: %v", perr.ErrorAtPositionStructured("", []rune(c.ResourcesMapping)))
+			}
+			return nil, fmt.Errorf("parse resources mapping: %w", err)
+		}
+	}
+	var metricsMapping *imetrics.Mapping
 	if c.MetricsMapping != "" {
-		if metricsMapping, err = metrics.NewMapping(types.NoopMgr(), c.MetricsMapping, log.Noop()); err != nil {
+		if metricsMapping, err = imetrics.NewMapping(types.NoopMgr(), c.MetricsMapping, log.Noop()); err != nil {
 			return nil, fmt.Errorf("parse metrics mapping: %w", err)
 		}
 	}
-	return &compiled{spec, mapping, metricsMapping}, nil
+	return &compiled{spec, mapping, resourcesMapping, metricsMapping}, nil
 }
 
 func diffYAMLNodesAsJSON(expNode, actNode *yaml.Node) (string, error) {
@@ -176,6 +196,101 @@ func (c Config) Test() ([]string, error) {
 				return nil, fmt.Errorf("test '%v': mismatch between expected and actual resulting config: %v", test.Name, diff)
 			}
 		}
+
+		resConf, err := compiled.ExpandResourcesToNode(&test.Config)
+		if err != nil {
+			return nil, fmt.Errorf("test '%v': %w", test.Name, err)
+		}
+		if resConf != nil {
+			for _, lint := range lintResourcesNode(docs.NewLintContext(), resConf) {
+				failures = append(failures, fmt.Sprintf("test '%v': lint error in resulting resources: line %v: %v", test.Name, lint.Line, lint.What))
+			}
+		}
+		if len(test.ExpectedResources.Content) > 0 {
+			if resConf == nil {
+				return nil, fmt.Errorf("test '%v': expected resources but the template does not have a resources mapping", test.Name)
+			}
+			diff, err := diffYAMLNodesAsJSON(&test.ExpectedResources, resConf)
+			if err != nil {
+				return nil, fmt.Errorf("test '%v': %w", test.Name, err)
+			}
+			if diff != "" {
+				diff = color.New(color.Reset).SprintFunc()(diff)
+				return nil, fmt.Errorf("test '%v': mismatch between expected and actual resulting resources: %v", test.Name, diff)
+			}
+		}
+
+		if len(test.InputBatch) > 0 || len(test.OutputBatches) > 0 {
+			if len(failures) > 0 {
+				// Executing an invalid config is unlikely to be helpful.
+				continue
+			}
+			msgFailures, err := c.testMessages(test, outConf, resConf)
+			if err != nil {
+				return nil, fmt.Errorf("test '%v': %w", test.Name, err)
+			}
+			failures = append(failures, msgFailures...)
+		}
+	}
+	return failures, nil
+}
+
+type staticProcProvider []types.Processor
+
+func (s staticProcProvider) Provide(string, map[string]string) ([]types.Processor, error) {
+	return s, nil
+}
+
+func (s staticProcProvider) ProvideBloblang(string) ([]types.Processor, error) {
+	return nil, errors.New("target mappings are not supported within template tests")
+}
+
+// testMessages executes the resulting config of a processor template against
+// the input batch of a test and checks the output batches.
+func (c Config) testMessages(tConf TestConfig, conf, resConf *yaml.Node) ([]string, error) {
+	if docs.Type(c.Type) != docs.TypeProcessor {
+		return nil, fmt.Errorf("input and output batches are only supported for processor templates, not %v", c.Type)
+	}
+
+	mgr, err := manager.NewV2(manager.NewResourceConfig(), types.NoopMgr(), log.Noop(), metrics.Noop())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		mgr.CloseAsync()
+		_ = mgr.WaitForClose(time.Second)
+	}()
+
+	if err := storeResources(mgr, resConf); err != nil {
+		return nil, err
+	}
+
+	procConf := processor.NewConfig()
+	if err := conf.Decode(&procConf); err != nil {
+		return nil, err
+	}
+	proc, err := mgr.NewProcessor(procConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise processor: %w", err)
+	}
+	defer func() {
+		proc.CloseAsync()
+		_ = proc.WaitForClose(time.Second)
+	}()
+
+	tCase := test.NewCase()
+	tCase.Name = tConf.Name
+	tCase.InputBatch = tConf.InputBatch
+	tCase.OutputBatches = tConf.OutputBatches
+
+	caseFailures, err := tCase.Execute(staticProcProvider{proc})
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	for _, f := range caseFailures {
+		failures = append(failures, fmt.Sprintf("test '%v': %v", tConf.Name, f.Reason))
 	}
 	return failures, nil
 }
@@ -211,8 +326,16 @@ func FieldConfigSpec() docs.FieldSpecs {
 	return docs.FieldSpecs{
 		docs.FieldString("name", "The name of the field."),
 		docs.FieldString("description", "A description of the field.").HasDefault(""),
-		docs.FieldString("type", "The scalar type of the field.").HasOptions(
-			"string", "int", "float", "bool",
+		docs.FieldString("type", "The type of the field, which is either a scalar type or a component type. Fields of a component type accept a Benthos config for that component, which is linted as such, and can be embedded within the resulting config of the template.").HasAnnotatedOptions(
+			"string", "standard string type",
+			"int", "standard integer type",
+			"float", "standard float type",
+			"bool", "a boolean true/false",
+			"input", "an input config",
+			"output", "an output config",
+			"processor", "a processor config",
+			"cache", "a cache config",
+			"rate_limit", "a rate limit config",
 		).LintOptions(),
 		docs.FieldString("kind", "The kind of the field.").HasOptions(
 			"scalar", "map", "list",
@@ -247,13 +370,19 @@ func ConfigSpec() docs.FieldSpecs {
 		docs.FieldBloblang(
 			"mapping", "A [Bloblang](/docs/guides/bloblang/about) mapping that translates the fields of the template into a valid Benthos configuration for the target component type.",
 		),
-		metrics.MappingFieldSpec(),
+		docs.FieldBloblang(
+			"resources_mapping", "An optional [Bloblang](/docs/guides/bloblang/about) mapping that translates the fields of the template into resources that are added alongside the component. The result must be an object containing any of the fields `cache_resources`, `rate_limit_resources`, `processor_resources`, `input_resources` and `output_resources`, where each resource has a unique `label`. Resources with a label that already exists are not replaced.",
+		).HasDefault("").AtVersion("3.65.0"),
+		imetrics.MappingFieldSpec(),
 		docs.FieldCommon(
-			"tests", "Optional unit test definitions for the template that verify certain configurations produce valid configs. These tests are executed with the command `benthos template lint`.",
+			"tests", "Optional unit test definitions for the template that verify certain configurations produce valid configs, and optionally that the resulting processor behaves as expected when executed against messages. These tests are executed with the command `benthos template lint`.",
 		).Array().WithChildren(
 			docs.FieldString("name", "A name to identify the test."),
 			docs.FieldCommon("config", "A configuration to run this test with, the config resulting from applying the template with this config will be linted.").HasType(docs.FieldTypeObject),
 			docs.FieldCommon("expected", "An optional configuration describing the expected result of applying the template, when specified the result will be diffed and any mismatching fields will be reported as a test error.").HasType(docs.FieldTypeObject).Optional(),
+			docs.FieldCommon("expected_resources", "An optional configuration describing the expected resources resulting from the `resources_mapping` of the template, when specified the result will be diffed and any mismatching fields will be reported as a test error.").HasType(docs.FieldTypeObject).Optional().AtVersion("3.65.0"),
+			docs.FieldCommon("input_batch", "An optional batch of messages to execute the resulting processor against, following the same format as [config unit tests](/docs/configuration/unit_testing). This is only supported for processor templates.").Array().HasType(docs.FieldTypeObject).Optional().AtVersion("3.65.0"),
+			docs.FieldCommon("output_batches", "An optional list of batches of [output conditions](/docs/configuration/unit_testing#output-conditions) that are checked against the result of executing the processor with the `input_batch`.").ArrayOfArrays().HasType(docs.FieldTypeObject).Optional().AtVersion("3.65.0"),
 		).HasDefault([]interface{}{}),
 	}
 }
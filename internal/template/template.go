@@ -1,6 +1,8 @@
 package template
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"sync"
@@ -87,15 +89,18 @@ func InitTemplates(templatesPaths ...string) ([]string, error) {
 
 // Compiled is a template that has been compiled from a config.
 type compiled struct {
-	spec           docs.ComponentSpec
-	mapping        *mapping.Executor
-	metricsMapping *metrics.Mapping
+	spec             docs.ComponentSpec
+	mapping          *mapping.Executor
+	resourcesMapping *mapping.Executor
+	metricsMapping   *metrics.Mapping
 }
 
-// ExpandToNode attempts to apply the template to a provided YAML node and
-// returns the new expanded configuration.
-func (c *compiled) ExpandToNode(node *yaml.Node) (*yaml.Node, error) {
-	generic, err := c.spec.Config.Children.YAMLToMap(node, docs.ToValueConfig{})
+func (c *compiled) fieldsMessage(node *yaml.Node) (types.Message, error) {
+	// Fields of a component type (processors, outputs, etc) are decoded as
+	// generic structures so that they can be embedded within the result.
+	generic, err := c.spec.Config.Children.YAMLToMap(node, docs.ToValueConfig{
+		FallbackToInterface: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("invalid config for template component: %w", err)
 	}
@@ -106,8 +111,11 @@ func (c *compiled) ExpandToNode(node *yaml.Node) (*yaml.Node, error) {
 		return nil, err
 	}
 	msg.Append(part)
+	return msg, nil
+}
 
-	newPart, err := c.mapping.MapPart(0, msg)
+func mapToNode(exec *mapping.Executor, msg types.Message) (*yaml.Node, error) {
+	newPart, err := exec.MapPart(0, msg)
 	if err != nil {
 		return nil, fmt.Errorf("mapping failed for template component: %w", err)
 	}
@@ -125,6 +133,99 @@ func (c *compiled) ExpandToNode(node *yaml.Node) (*yaml.Node, error) {
 	return &resultNode, nil
 }
 
+// ExpandToNode attempts to apply the template to a provided YAML node and
+// returns the new expanded configuration.
+func (c *compiled) ExpandToNode(node *yaml.Node) (*yaml.Node, error) {
+	msg, err := c.fieldsMessage(node)
+	if err != nil {
+		return nil, err
+	}
+	return mapToNode(c.mapping, msg)
+}
+
+// ExpandResourcesToNode attempts to apply the resources mapping of the template
+// to a provided YAML node and returns the resulting resources configuration. If
+// the template does not have a resources mapping then nil is returned.
+func (c *compiled) ExpandResourcesToNode(node *yaml.Node) (*yaml.Node, error) {
+	if c.resourcesMapping == nil {
+		return nil, nil
+	}
+	msg, err := c.fieldsMessage(node)
+	if err != nil {
+		return nil, err
+	}
+	resNode, err := mapToNode(c.resourcesMapping, msg)
+	if err != nil {
+		return nil, fmt.Errorf("resources: %w", err)
+	}
+	return resNode, nil
+}
+
+//------------------------------------------------------------------------------
+
+// resourceTypes maps the fields of a resources config to their component type.
+var resourceTypes = []struct {
+	field string
+	ctype docs.Type
+}{
+	{"cache_resources", docs.TypeCache},
+	{"rate_limit_resources", docs.TypeRateLimit},
+	{"processor_resources", docs.TypeProcessor},
+	{"input_resources", docs.TypeInput},
+	{"output_resources", docs.TypeOutput},
+}
+
+func lintResourcesNode(ctx docs.LintContext, node *yaml.Node) (lints []docs.Lint) {
+	if node.Kind != yaml.MappingNode {
+		return []docs.Lint{docs.NewLintError(node.Line, "expected object value")}
+	}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		var ctype docs.Type
+		for _, rt := range resourceTypes {
+			if rt.field == key.Value {
+				ctype = rt.ctype
+			}
+		}
+		if ctype == "" {
+			lints = append(lints, docs.NewLintError(key.Line, fmt.Sprintf("field %v not recognised", key.Value)))
+			continue
+		}
+		if value.Kind != yaml.SequenceNode {
+			lints = append(lints, docs.NewLintError(value.Line, "expected array value"))
+			continue
+		}
+		for _, resNode := range value.Content {
+			lints = append(lints, docs.LintYAML(ctx, ctype, resNode)...)
+		}
+	}
+	return
+}
+
+// storeResources adds any resources emitted by a template to a manager.
+// Templates are often instantiated multiple times (once per processing thread,
+// for example) and therefore resources that already exist are left untouched.
+func storeResources(nm bundle.NewManagement, node *yaml.Node) error {
+	if node == nil {
+		return nil
+	}
+
+	conf := manager.NewResourceConfig()
+	if err := node.Decode(&conf); err != nil {
+		return fmt.Errorf("resources: %w", err)
+	}
+
+	t, ok := nm.(*manager.Type)
+	if !ok {
+		return errors.New("resources: the manager of this component does not support template resources")
+	}
+	if err := t.StoreNewResources(context.Background(), conf); err != nil {
+		return fmt.Errorf("resources: %w", err)
+	}
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 // RegisterTemplate attempts to add a template component to the global list of
@@ -160,12 +261,20 @@ func registerCacheTemplate(tmpl *compiled, set *bundle.CacheSet) error {
 		if err != nil {
 			return nil, err
 		}
+		resNode, err := tmpl.ExpandResourcesToNode(c.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
 
 		conf := cache.NewConfig()
 		if err := newNode.Decode(&conf); err != nil {
 			return nil, err
 		}
 
+		if err := storeResources(nm, resNode); err != nil {
+			return nil, err
+		}
+
 		if tmpl.metricsMapping != nil {
 			nm = WithMetricsMapping(nm, tmpl.metricsMapping)
 		}
@@ -179,6 +288,10 @@ func registerInputTemplate(tmpl *compiled, set *bundle.InputSet) error {
 		if err != nil {
 			return nil, err
 		}
+		resNode, err := tmpl.ExpandResourcesToNode(c.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
 
 		conf := input.NewConfig()
 		if err := newNode.Decode(&conf); err != nil {
@@ -188,6 +301,10 @@ func registerInputTemplate(tmpl *compiled, set *bundle.InputSet) error {
 		// Tempate processors inserted _before_ configured processors.
 		conf.Processors = append(conf.Processors, c.Processors...)
 
+		if err := storeResources(nm, resNode); err != nil {
+			return nil, err
+		}
+
 		if tmpl.metricsMapping != nil {
 			nm = WithMetricsMapping(nm, tmpl.metricsMapping)
 		}
@@ -201,6 +318,10 @@ func registerOutputTemplate(tmpl *compiled, set *bundle.OutputSet) error {
 		if err != nil {
 			return nil, err
 		}
+		resNode, err := tmpl.ExpandResourcesToNode(c.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
 
 		conf := output.NewConfig()
 		if err := newNode.Decode(&conf); err != nil {
@@ -210,6 +331,10 @@ func registerOutputTemplate(tmpl *compiled, set *bundle.OutputSet) error {
 		// Tempate processors inserted _after_ configured processors.
 		conf.Processors = append(c.Processors, conf.Processors...)
 
+		if err := storeResources(nm, resNode); err != nil {
+			return nil, err
+		}
+
 		if tmpl.metricsMapping != nil {
 			nm = WithMetricsMapping(nm, tmpl.metricsMapping)
 		}
@@ -223,12 +348,20 @@ func registerProcessorTemplate(tmpl *compiled, set *bundle.ProcessorSet) error {
 		if err != nil {
 			return nil, err
 		}
+		resNode, err := tmpl.ExpandResourcesToNode(c.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
 
 		conf := processor.NewConfig()
 		if err := newNode.Decode(&conf); err != nil {
 			return nil, err
 		}
 
+		if err := storeResources(nm, resNode); err != nil {
+			return nil, err
+		}
+
 		if tmpl.metricsMapping != nil {
 			nm = WithMetricsMapping(nm, tmpl.metricsMapping)
 		}
@@ -242,12 +375,20 @@ func registerRateLimitTemplate(tmpl *compiled, set *bundle.RateLimitSet) error {
 		if err != nil {
 			return nil, err
 		}
+		resNode, err := tmpl.ExpandResourcesToNode(c.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
 
 		conf := ratelimit.NewConfig()
 		if err := newNode.Decode(&conf); err != nil {
 			return nil, err
 		}
 
+		if err := storeResources(nm, resNode); err != nil {
+			return nil, err
+		}
+
 		if tmpl.metricsMapping != nil {
 			nm = WithMetricsMapping(nm, tmpl.metricsMapping)
 		}
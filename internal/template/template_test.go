@@ -1,14 +1,23 @@
 package template_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/template"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/types"
 	_ "github.com/Jeffail/benthos/v3/public/components/all"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestTemplateTesting(t *testing.T) {
@@ -28,3 +37,154 @@ func TestTemplateTesting(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateTestingMessageFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmplPath := filepath.Join(tmpDir, "foo.yaml")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`
+name: message_failures_test
+type: processor
+fields:
+  - name: value
+    type: string
+mapping: |
+  root.bloblang = "root = %q".format(this.value)
+tests:
+  - name: Wrong content
+    config:
+      value: foo
+    input_batch:
+      - content: hello world
+    output_batches:
+      - - content_equals: bar
+`), 0o644))
+
+	conf, lints, err := template.ReadConfig(tmplPath)
+	require.NoError(t, err)
+	assert.Empty(t, lints)
+
+	testErrs, err := conf.Test()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"test 'Wrong content': batch 0 message 0: content_equals: content mismatch\n  expected: bar\n  received: foo",
+	}, testErrs)
+}
+
+func TestTemplateResources(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmplPath := filepath.Join(tmpDir, "foo.yaml")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`
+name: resources_test
+type: processor
+fields:
+  - name: key
+    type: string
+  - name: then
+    type: processor
+mapping: |
+  root.for_each = [
+    { "cache": { "resource": "resources_test_cache", "operator": "set", "key": this.key, "value": "${! content() }" } },
+    this.then,
+  ]
+resources_mapping: |
+  root.cache_resources = [
+    { "label": "resources_test_cache", "memory": {} }
+  ]
+`), 0o644))
+
+	lints, err := template.InitTemplates(tmplPath)
+	require.NoError(t, err)
+	assert.Empty(t, lints)
+
+	mgr, err := manager.NewV2(manager.NewResourceConfig(), types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	var pConf processor.Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+resources_test:
+  key: foo
+  then:
+    bloblang: 'root = content().uppercase()'
+`), &pConf))
+
+	// The template is instantiated twice, the second time should reuse the
+	// existing cache resource.
+	for i := 0; i < 2; i++ {
+		proc, err := mgr.NewProcessor(pConf)
+		require.NoError(t, err)
+
+		msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte("hello world")}))
+		require.Nil(t, res)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "HELLO WORLD", string(msgs[0].Get(0).Get()))
+	}
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "resources_test_cache", func(c types.Cache) {
+		v, err := c.Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(v))
+	}))
+}
+
+func TestTemplateResourcesWithinResource(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmplPath := filepath.Join(tmpDir, "foo.yaml")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`
+name: resources_within_resource_test
+type: processor
+fields:
+  - name: key
+    type: string
+mapping: |
+  root.cache = { "resource": "resources_within_resource_test_cache", "operator": "set", "key": this.key, "value": "${! content() }" }
+resources_mapping: |
+  root.cache_resources = [
+    { "label": "resources_within_resource_test_cache", "memory": {} }
+  ]
+`), 0o644))
+
+	lints, err := template.InitTemplates(tmplPath)
+	require.NoError(t, err)
+	assert.Empty(t, lints)
+
+	resConf := manager.NewResourceConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(`
+processor_resources:
+  - label: foo
+    resources_within_resource_test:
+      key: foo
+  - label: bar
+    resources_within_resource_test:
+      key: bar
+`), &resConf))
+
+	var mgr *manager.Type
+	mgrErrChan := make(chan error, 1)
+	go func() {
+		var err error
+		mgr, err = manager.NewV2(resConf, types.NoopMgr(), log.Noop(), metrics.Noop())
+		mgrErrChan <- err
+	}()
+	select {
+	case err := <-mgrErrChan:
+		require.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for manager")
+	}
+
+	for _, label := range []string{"foo", "bar"} {
+		require.NoError(t, mgr.AccessProcessor(context.Background(), label, func(p types.Processor) {
+			msgs, res := p.ProcessMessage(message.New([][]byte{[]byte("hello " + label)}))
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+		}))
+	}
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "resources_within_resource_test_cache", func(c types.Cache) {
+		for _, label := range []string{"foo", "bar"} {
+			v, err := c.Get(label)
+			require.NoError(t, err)
+			assert.Equal(t, "hello "+label, string(v))
+		}
+	}))
+}
@@ -2,10 +2,12 @@ package broker
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/component/output"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
@@ -27,6 +29,7 @@ type FanOut struct {
 
 	outputTSChans []chan types.Transaction
 	outputs       []types.Output
+	outputLabels  []string
 
 	ctx        context.Context
 	close      func()
@@ -50,7 +53,9 @@ func NewFanOut(
 	}
 
 	o.outputTSChans = make([]chan types.Transaction, len(o.outputs))
+	o.outputLabels = make([]string, len(o.outputs))
 	for i := range o.outputTSChans {
+		o.outputLabels[i] = fmt.Sprintf("outputs.%v", i)
 		o.outputTSChans[i] = make(chan types.Transaction)
 		if err := o.outputs[i].Consume(o.outputTSChans[i]); err != nil {
 			return nil, err
@@ -62,6 +67,16 @@ func NewFanOut(
 	return o, nil
 }
 
+// WithOutputLabels sets the labels of each output, which are used in order to
+// identify the copies of messages sent to each output when tracking message
+// lineage. This must be set before calling Consume.
+func (o *FanOut) WithOutputLabels(labels []string) *FanOut {
+	if len(labels) == len(o.outputLabels) {
+		o.outputLabels = labels
+	}
+	return o
+}
+
 // WithMaxInFlight sets the maximum number of in-flight messages this broker
 // supports. This must be set before calling Consume.
 func (o *FanOut) WithMaxInFlight(i int) *FanOut {
@@ -155,6 +170,7 @@ func (o *FanOut) loop() {
 			var owg errgroup.Group
 			for target := range o.outputTSChans {
 				msgCopy, i := ts.Payload.Copy(), target
+				lineage.DeriveEach(o.outputLabels[i], msgCopy)
 				owg.Go(func() error {
 					throt := throttle.New(throttle.OptCloseChan(o.ctx.Done()))
 					resChan := make(chan types.Response)
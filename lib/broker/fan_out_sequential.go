@@ -2,10 +2,12 @@ package broker
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/component/output"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
@@ -28,6 +30,7 @@ type FanOutSequential struct {
 
 	outputTSChans []chan types.Transaction
 	outputs       []types.Output
+	outputLabels  []string
 
 	ctx        context.Context
 	close      func()
@@ -51,7 +54,9 @@ func NewFanOutSequential(
 	}
 
 	o.outputTSChans = make([]chan types.Transaction, len(o.outputs))
+	o.outputLabels = make([]string, len(o.outputs))
 	for i := range o.outputTSChans {
+		o.outputLabels[i] = fmt.Sprintf("outputs.%v", i)
 		o.outputTSChans[i] = make(chan types.Transaction)
 		if err := o.outputs[i].Consume(o.outputTSChans[i]); err != nil {
 			return nil, err
@@ -63,6 +68,16 @@ func NewFanOutSequential(
 	return o, nil
 }
 
+// WithOutputLabels sets the labels of each output, which are used in order to
+// identify the copies of messages sent to each output when tracking message
+// lineage. This must be set before calling Consume.
+func (o *FanOutSequential) WithOutputLabels(labels []string) *FanOutSequential {
+	if len(labels) == len(o.outputLabels) {
+		o.outputLabels = labels
+	}
+	return o
+}
+
 // WithMaxInFlight sets the maximum number of in-flight messages this broker
 // supports. This must be set before calling Consume.
 func (o *FanOutSequential) WithMaxInFlight(i int) *FanOutSequential {
@@ -142,6 +157,7 @@ func (o *FanOutSequential) loop() {
 
 			for i := range o.outputTSChans {
 				msgCopy := ts.Payload.Copy()
+				lineage.DeriveEach(o.outputLabels[i], msgCopy)
 
 				throt := throttle.New(throttle.OptCloseChan(o.ctx.Done()))
 				resChan := make(chan types.Response)
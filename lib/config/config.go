@@ -23,11 +23,12 @@ type Type struct {
 	HTTP                   api.Config `json:"http" yaml:"http"`
 	stream.Config          `json:",inline" yaml:",inline"`
 	manager.ResourceConfig `json:",inline" yaml:",inline"`
-	Logger                 log.Config     `json:"logger" yaml:"logger"`
-	Metrics                metrics.Config `json:"metrics" yaml:"metrics"`
-	Tracer                 tracer.Config  `json:"tracer" yaml:"tracer"`
-	SystemCloseTimeout     string         `json:"shutdown_timeout" yaml:"shutdown_timeout"`
-	Tests                  []interface{}  `json:"tests,omitempty" yaml:"tests,omitempty"`
+	Logger                 log.Config             `json:"logger" yaml:"logger"`
+	Metrics                metrics.Config         `json:"metrics" yaml:"metrics"`
+	Tracer                 tracer.Config          `json:"tracer" yaml:"tracer"`
+	SystemCloseTimeout     string                 `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	Tests                  []interface{}          `json:"tests,omitempty" yaml:"tests,omitempty"`
+	Profiles               map[string]interface{} `json:"profiles,omitempty" yaml:"profiles,omitempty"`
 }
 
 // New returns a new configuration with default values.
@@ -21,6 +21,11 @@ var observabilityFields = docs.FieldSpecs{
 // benthos config.
 var TestsField = docs.FieldCommon("tests", "Optional unit tests for the config, to be run with the `benthos test` subcommand.").Array().HasType(docs.FieldTypeUnknown).HasDefault([]interface{}{})
 
+// ProfilesField describes the optional named profiles field at the root of a
+// benthos config, where each profile is a partial config that can be merged on
+// top of the root config with the `--profile` flag.
+var ProfilesField = docs.FieldAdvanced("profiles", "Optional named partial configs that can be merged on top of the root config by selecting them with the `--profile` flag.").Map().HasType(docs.FieldTypeUnknown).HasDefault(map[string]interface{}{})
+
 // Spec returns a docs.FieldSpec for an entire Benthos configuration.
 func Spec() docs.FieldSpecs {
 	fields := docs.FieldSpecs{httpField}
@@ -28,6 +33,7 @@ func Spec() docs.FieldSpecs {
 	fields = append(fields, manager.Spec()...)
 	fields = append(fields, observabilityFields...)
 	fields = append(fields, TestsField)
+	fields = append(fields, ProfilesField)
 	return fields
 }
 
@@ -37,5 +43,6 @@ func SpecWithoutStream() docs.FieldSpecs {
 	fields = append(fields, manager.Spec()...)
 	fields = append(fields, observabilityFields...)
 	fields = append(fields, TestsField)
+	fields = append(fields, ProfilesField)
 	return fields
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
@@ -28,11 +29,98 @@ func LintV2(ctx docs.LintContext, rawBytes []byte) ([]string, error) {
 		return nil, err
 	}
 
+	lints := Spec().LintYAML(ctx, &rawNode)
+	lints = append(lints, LintProfilesYAML(ctx, Spec(), &rawNode)...)
+
 	var lintStrs []string
-	for _, lint := range Spec().LintYAML(ctx, &rawNode) {
+	for _, lint := range lints {
 		if lint.Level == docs.LintError {
 			lintStrs = append(lintStrs, fmt.Sprintf("line %v: %v", lint.Line, lint.What))
 		}
 	}
 	return lintStrs, nil
 }
+
+// LintProfilesYAML walks the profiles field of a config, if present, and lints
+// each profile as a partial config against the provided spec.
+func LintProfilesYAML(ctx docs.LintContext, spec docs.FieldSpecs, node *yaml.Node) []docs.Lint {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var lints []docs.Lint
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value != ProfilesField.Name {
+			continue
+		}
+		profiles := node.Content[i+1]
+		for j := 0; j < len(profiles.Content)-1; j += 2 {
+			lints = append(lints, spec.LintYAML(ctx, profiles.Content[j+1])...)
+		}
+	}
+	return lints
+}
+
+// ErrFixWithComments is returned by FixV2 when a config that requires fixes
+// contains comments, as the fixed config is encoded from scratch and comments
+// can't reliably be kept alongside the nodes they describe.
+var ErrFixWithComments = errors.New("unable to fix a config that contains comments, as they would not be preserved")
+
+// FixV2 attempts to automatically fix problems within a user config, such as
+// the use of deprecated fields and fields that match their default values.
+// Returns the fixed config and a slice of descriptions of each fix applied.
+// When no fixes are applied the config is returned unchanged, and when fixes
+// are required for a config that contains comments ErrFixWithComments is
+// returned instead.
+func FixV2(ctx docs.LintContext, rawBytes []byte) ([]byte, []string, error) {
+	if bytes.HasPrefix(rawBytes, []byte("# BENTHOS LINT DISABLE")) {
+		return rawBytes, nil, nil
+	}
+
+	var rawNode yaml.Node
+	if err := yaml.Unmarshal(rawBytes, &rawNode); err != nil {
+		return nil, nil, err
+	}
+	if rawNode.Kind == 0 {
+		return rawBytes, nil, nil
+	}
+
+	hasComments := yamlHasComments(&rawNode)
+
+	var fixStrs []string
+	for _, fix := range Spec().FixYAML(ctx, &rawNode) {
+		fixStrs = append(fixStrs, fmt.Sprintf("line %v: %v", fix.Line, fix.What))
+	}
+	if len(fixStrs) == 0 {
+		return rawBytes, nil, nil
+	}
+	if hasComments {
+		return nil, nil, ErrFixWithComments
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&rawNode); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), fixStrs, nil
+}
+
+func yamlHasComments(node *yaml.Node) bool {
+	if node.HeadComment != "" || node.LineComment != "" || node.FootComment != "" {
+		return true
+	}
+	for _, child := range node.Content {
+		if yamlHasComments(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"gopkg.in/yaml.v3"
+)
+
+// LintRule is a custom linting rule written as a Bloblang mapping, allowing
+// organisations to enforce their own conventions on configs. The mapping is
+// executed against the parsed structure of a config and should result in
+// either nothing (the config passes), a string describing a lint, an object
+// with the fields `what` and (optionally) `path`, where path is a dot path
+// that is used to derive the line of the lint, or an array of these.
+type LintRule struct {
+	name string
+	exec *mapping.Executor
+}
+
+// NewLintRule parses a Bloblang mapping into a custom linting rule.
+func NewLintRule(name, blobl string) (*LintRule, error) {
+	return newLintRule(bloblang.GlobalEnvironment(), name, blobl)
+}
+
+// ReadLintRule reads a file containing a Bloblang mapping and parses it into a
+// custom linting rule named after the file. Imports within the mapping are
+// resolved relative to the file.
+func ReadLintRule(path string) (*LintRule, error) {
+	ruleBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return newLintRule(bloblang.GlobalEnvironment().WithImporterRelativeToFile(path), name, string(ruleBytes))
+}
+
+func newLintRule(env *bloblang.Environment, name, blobl string) (*LintRule, error) {
+	exec, err := env.NewMapping(blobl)
+	if err != nil {
+		return nil, fmt.Errorf("rule %v: %w", name, err)
+	}
+	return &LintRule{name: name, exec: exec}, nil
+}
+
+// Name returns the name of the rule.
+func (r *LintRule) Name() string {
+	return r.name
+}
+
+// Lint executes the rule against a user config and returns a slice of lint
+// results.
+func (r *LintRule) Lint(rawBytes []byte) ([]string, error) {
+	var rawNode yaml.Node
+	if err := yaml.Unmarshal(rawBytes, &rawNode); err != nil {
+		return nil, err
+	}
+
+	var root interface{}
+	if err := rawNode.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	res, err := r.exec.Exec(query.FunctionContext{
+		Maps:     r.exec.Maps(),
+		Vars:     map[string]interface{}{},
+		MsgBatch: message.New(nil),
+	}.WithValue(root))
+	if err != nil {
+		return nil, fmt.Errorf("rule %v: %w", r.name, err)
+	}
+	return r.resultToLints(&rawNode, res)
+}
+
+func (r *LintRule) resultToLints(root *yaml.Node, res interface{}) ([]string, error) {
+	switch t := res.(type) {
+	case nil, query.Nothing, query.Delete:
+		return nil, nil
+	case string:
+		return []string{fmt.Sprintf("%v: %v", r.name, t)}, nil
+	case []interface{}:
+		var lints []string
+		for _, v := range t {
+			l, err := r.resultToLints(root, v)
+			if err != nil {
+				return nil, err
+			}
+			lints = append(lints, l...)
+		}
+		return lints, nil
+	case map[string]interface{}:
+		what, ok := t["what"].(string)
+		if !ok {
+			return nil, fmt.Errorf("rule %v: expected a string field what in result object", r.name)
+		}
+		if path, _ := t["path"].(string); path != "" {
+			if node, err := docs.GetYAMLPath(root, strings.Split(path, ".")...); err == nil {
+				return []string{fmt.Sprintf("line %v: %v: %v", node.Line, r.name, what)}, nil
+			}
+		}
+		return []string{fmt.Sprintf("%v: %v", r.name, what)}, nil
+	}
+	return nil, fmt.Errorf("rule %v: expected a string, object or array result, got %T", r.name, res)
+}
@@ -4,8 +4,11 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/config"
 	_ "github.com/Jeffail/benthos/v3/public/components/all"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 //------------------------------------------------------------------------------
@@ -168,3 +171,228 @@ tests:
 }
 
 //------------------------------------------------------------------------------
+
+func TestConfigFixes(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        string
+		res         string
+		fixes       []string
+		errContains string
+	}{
+		{
+			name: "nothing to fix",
+			conf: `input:
+  stdin: {} # read stdin
+`,
+			res: `input:
+  stdin: {} # read stdin
+`,
+		},
+		{
+			name: "default values removed",
+			conf: `input:
+  stdin:
+    codec: lines
+    max_buffer: 1000000
+pipeline:
+  threads: 1
+`,
+			res: `input:
+  stdin: {}
+pipeline: {}
+`,
+			fixes: []string{
+				"line 3: removed field codec as it matches the default value",
+				"line 4: removed field max_buffer as it matches the default value",
+				"line 6: removed field threads as it matches the default value",
+			},
+		},
+		{
+			name: "comments prevent fixes",
+			conf: `input:
+  # the input
+  stdin:
+    codec: lines
+`,
+			errContains: "contains comments",
+		},
+		{
+			name: "switch conditions",
+			conf: `pipeline:
+  processors:
+    - switch:
+        - condition:
+            bloblang: this.foo == "bar"
+          processors:
+            - bloblang: root = "meow"
+        - condition:
+            static: true
+          processors:
+            - bloblang: root = "woof"
+`,
+			res: `pipeline:
+  processors:
+    - switch:
+        - check: this.foo == "bar"
+          processors:
+            - bloblang: root = "meow"
+        - processors:
+            - bloblang: root = "woof"
+`,
+			fixes: []string{
+				"line 4: replaced deprecated field condition with an equivalent check",
+				"line 8: removed deprecated field condition as it always passes",
+			},
+		},
+		{
+			name: "switch output outputs",
+			conf: `output:
+  switch:
+    outputs:
+      - condition:
+          bloblang: this.foo == "bar"
+        fallthrough: true
+        output:
+          stdout: {}
+      - condition:
+          static: true
+        fallthrough: false
+        output:
+          drop: {}
+`,
+			res: `output:
+  switch:
+    cases:
+      - check: this.foo == "bar"
+        continue: true
+        output:
+          stdout: {}
+      - output:
+          drop: {}
+`,
+			fixes: []string{
+				"line 3: replaced deprecated field outputs with equivalent cases",
+			},
+		},
+		{
+			name: "workflow stages",
+			conf: `pipeline:
+  processors:
+    - workflow:
+        stages:
+          foo:
+            premap:
+              .: doc.body
+            processors:
+              - bloblang: root.foo = "yep"
+            postmap:
+              doc.foo_result: foo
+          bar:
+            premap:
+              in: doc.foo_result
+            processors:
+              - bloblang: root.out = this.in.uppercase()
+            postmap_optional:
+              doc.bar_result: out
+`,
+			res: `pipeline:
+  processors:
+    - workflow:
+        order:
+          - - foo
+          - - bar
+        branches:
+          foo:
+            request_map: |-
+              root = this.doc.body
+              root = if this.doc.body == null { deleted() }
+            processors:
+              - bloblang: root.foo = "yep"
+            result_map: root.doc.foo_result = this.foo.not_null()
+          bar:
+            request_map: |-
+              root.in = this.doc.foo_result
+              root = if this.doc.foo_result == null { deleted() }
+            processors:
+              - bloblang: root.out = this.in.uppercase()
+            result_map: root.doc.bar_result = if this.out != null { this.out }
+`,
+			fixes: []string{
+				"line 4: replaced deprecated field stages with equivalent branches",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, fixes, err := config.FixV2(docs.NewLintContext(), []byte(test.conf))
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.res, string(res))
+			assert.Equal(t, test.fixes, fixes)
+
+			lints, err := config.LintV2(docs.NewLintContext(), res)
+			require.NoError(t, err)
+			assert.Empty(t, lints)
+		})
+	}
+}
+
+func TestConfigLintRules(t *testing.T) {
+	rule, err := config.NewLintRule("no_stdout", `
+root = match {
+  this.output.exists("stdout") => { "what": "stdout outputs are forbidden", "path": "output.stdout" },
+  this.input.exists("stdin") => "stdin inputs are forbidden",
+}
+`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		conf  string
+		lints []string
+	}{
+		{
+			name: "passes",
+			conf: `input:
+  kafka: {}
+output:
+  drop: {}
+`,
+		},
+		{
+			name: "lint with path",
+			conf: `input:
+  kafka: {}
+output:
+  stdout:
+    codec: lines
+`,
+			lints: []string{"line 5: no_stdout: stdout outputs are forbidden"},
+		},
+		{
+			name: "lint without path",
+			conf: `input:
+  stdin: {}
+output:
+  drop: {}
+`,
+			lints: []string{"no_stdout: stdin inputs are forbidden"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			lints, err := rule.Lint([]byte(test.conf))
+			require.NoError(t, err)
+			assert.Equal(t, test.lints, lints)
+		})
+	}
+}
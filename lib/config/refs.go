@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"unicode/utf8"
 
+	"github.com/Jeffail/benthos/v3/internal/secrets"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"gopkg.in/yaml.v3"
 )
@@ -31,6 +33,19 @@ func ReadWithJSONPointers(path string, replaceEnvs bool) ([]byte, error) {
 //
 // If any non-fatal errors occur lints are returned along with the result.
 func ReadWithJSONPointersLinted(path string, replaceEnvs bool) (configBytes []byte, lints []string, err error) {
+	return readWithJSONPointersLinted(path, replaceEnvs, false)
+}
+
+// ReadWithJSONPointersAndSecretsLinted is the same as
+// ReadWithJSONPointersLinted with environment variables replaced, but also
+// resolves secret references of the form ${secret:provider:path#key}. Secrets
+// should only be resolved when a config is going to be run, as resolving them
+// requires access to the secret providers.
+func ReadWithJSONPointersAndSecretsLinted(path string) (configBytes []byte, lints []string, err error) {
+	return readWithJSONPointersLinted(path, true, true)
+}
+
+func readWithJSONPointersLinted(path string, replaceEnvs, resolveSecrets bool) (configBytes []byte, lints []string, err error) {
 	configBytes, err = os.ReadFile(path)
 	if err != nil {
 		return nil, nil, err
@@ -41,7 +56,9 @@ func ReadWithJSONPointersLinted(path string, replaceEnvs bool) (configBytes []by
 	}
 
 	if replaceEnvs {
-		configBytes = text.ReplaceEnvVariables(configBytes)
+		if configBytes, err = replaceVariables(configBytes, resolveSecrets); err != nil {
+			return nil, lints, err
+		}
 	}
 
 	var gen interface{}
@@ -49,7 +66,7 @@ func ReadWithJSONPointersLinted(path string, replaceEnvs bool) (configBytes []by
 		return nil, lints, err
 	}
 
-	refFound, err := refWalk(path, 0, gen, gen)
+	refFound, err := refWalk(path, 0, gen, gen, resolveSecrets)
 	if err != nil {
 		return nil, lints, err
 	}
@@ -63,6 +80,16 @@ func ReadWithJSONPointersLinted(path string, replaceEnvs bool) (configBytes []by
 	return configBytes, lints, nil
 }
 
+// replaceVariables replaces environment variables within a config and
+// optionally resolves secrets, which are resolved after the environment
+// variables so that the values of neither are interpolated further.
+func replaceVariables(rawBytes []byte, resolveSecrets bool) ([]byte, error) {
+	if !resolveSecrets {
+		return text.ReplaceEnvVariables(secrets.Escape(rawBytes)), nil
+	}
+	return secrets.Replace(context.Background(), rawBytes, text.ReplaceEnvVariables)
+}
+
 //------------------------------------------------------------------------------
 
 // JSONPointer parses a JSON pointer path (https://tools.ietf.org/html/rfc6901)
@@ -137,7 +164,7 @@ func getRefVal(obj interface{}) interface{} {
 	return nil
 }
 
-func expandRefVal(path string, level int, root, v interface{}) (interface{}, error) {
+func expandRefVal(path string, level int, root, v interface{}, resolveSecrets bool) (interface{}, error) {
 	if level == refLimit {
 		return nil, ErrExceededRefLimit
 	}
@@ -168,7 +195,9 @@ func expandRefVal(path string, level int, root, v interface{}) (interface{}, err
 		if err != nil {
 			return nil, fmt.Errorf("failed to read relative $ref path '%v' in config '%v': %v", rPath, path, err)
 		}
-		configBytes = text.ReplaceEnvVariables(configBytes)
+		if configBytes, err = replaceVariables(configBytes, resolveSecrets); err != nil {
+			return nil, fmt.Errorf("relative $ref path '%v' in config '%v': %w", rPath, path, err)
+		}
 
 		var gen interface{}
 		if err := yaml.Unmarshal(configBytes, &gen); err != nil {
@@ -185,27 +214,27 @@ func expandRefVal(path string, level int, root, v interface{}) (interface{}, err
 		}
 	}
 	if rVal := getRefVal(nextObj); rVal != nil {
-		return expandRefVal(path, level+1, root, rVal)
+		return expandRefVal(path, level+1, root, rVal, resolveSecrets)
 	}
 
-	if _, err = refWalk(path, level+1, root, nextObj); err != nil {
+	if _, err = refWalk(path, level+1, root, nextObj, resolveSecrets); err != nil {
 		return nil, err
 	}
 	return nextObj, nil
 }
 
-func refWalk(path string, level int, root, obj interface{}) (refFound bool, err error) {
+func refWalk(path string, level int, root, obj interface{}, resolveSecrets bool) (refFound bool, err error) {
 	switch x := obj.(type) {
 	case map[string]interface{}:
 		for k, v := range x {
 			if rv := getRefVal(v); rv != nil {
-				if x[k], err = expandRefVal(path, level, root, rv); err != nil {
+				if x[k], err = expandRefVal(path, level, root, rv, resolveSecrets); err != nil {
 					return
 				}
 				refFound = true
 			} else {
 				var rFound bool
-				if rFound, err = refWalk(path, level, root, v); err != nil {
+				if rFound, err = refWalk(path, level, root, v, resolveSecrets); err != nil {
 					return
 				} else if rFound {
 					refFound = true
@@ -215,13 +244,13 @@ func refWalk(path string, level int, root, obj interface{}) (refFound bool, err
 	case map[interface{}]interface{}:
 		for k, v := range x {
 			if rv := getRefVal(v); rv != nil {
-				if x[k], err = expandRefVal(path, level, root, rv); err != nil {
+				if x[k], err = expandRefVal(path, level, root, rv, resolveSecrets); err != nil {
 					return
 				}
 				refFound = true
 			} else {
 				var rFound bool
-				if rFound, err = refWalk(path, level, root, v); err != nil {
+				if rFound, err = refWalk(path, level, root, v, resolveSecrets); err != nil {
 					return
 				} else if rFound {
 					refFound = true
@@ -231,13 +260,13 @@ func refWalk(path string, level int, root, obj interface{}) (refFound bool, err
 	case []interface{}:
 		for i, v := range x {
 			if rv := getRefVal(v); rv != nil {
-				if x[i], err = expandRefVal(path, level, root, rv); err != nil {
+				if x[i], err = expandRefVal(path, level, root, rv, resolveSecrets); err != nil {
 					return
 				}
 				refFound = true
 			} else {
 				var rFound bool
-				if rFound, err = refWalk(path, level, root, v); err != nil {
+				if rFound, err = refWalk(path, level, root, v, resolveSecrets); err != nil {
 					return
 				} else if rFound {
 					refFound = true
@@ -34,6 +34,15 @@ Consumes data from files on disk, emitting messages according to a chosen codec.
 			docs.FieldDeprecated("delimiter"),
 			docs.FieldDeprecated("multipart"),
 			docs.FieldAdvanced("delete_on_finish", "Whether to delete consumed files from the disk once they are fully consumed."),
+			docs.FieldAdvanced(
+				"follow",
+				"An experimental mode whereby files are tailed rather than consumed once, new files that match the target paths are consumed as they appear, and files that are rotated or truncated are consumed again from their beginning. Follow mode cannot be used along with `delete_on_finish`.",
+			).WithChildren(
+				docs.FieldAdvanced("enabled", "Whether follow mode is enabled."),
+				docs.FieldAdvanced("poll_interval", "The interval between each check of a file for new data, rotation and truncation once it has been fully consumed.", "100ms", "1s"),
+				docs.FieldAdvanced("rescan_interval", "The interval between each scan of the target paths for new files.", "1s", "1m"),
+				docs.FieldAdvanced("cache", "An optional [cache resource](/docs/components/caches/about) for storing the position of each file, which is updated once messages are acknowledged and allows the input to resume where it left off after a restart."),
+			).AtVersion("3.65.0"),
 		},
 		Description: `
 ### Metadata
@@ -45,7 +54,15 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](/docs/configuration/interpolation#metadata).`,
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+### Follow Mode
+
+When ` + "`follow.enabled`" + ` is set to ` + "`true`" + ` the input consumes files as they grow, continuing indefinitely. Files are identified by their device and inode numbers, and when a path is found to point to a different file (due to log rotation) or the file becomes smaller than the data already consumed (due to truncation) the new contents of the path are consumed from the beginning. Files that are rotated away are fully consumed before moving on to their replacement.
+
+Codecs that only emit messages once a file has been read in full, such as ` + "`all-bytes`" + `, ` + "`tar`" + ` and ` + "`gzip`" + `, can't tail a file, and so with these codecs each file is consumed in full and then again whenever it is rotated, truncated or grows, where messages previously consumed from a file that has grown are skipped.
+
+When a ` + "`follow.cache`" + ` is configured the position of each file is stored within it once all messages up to that position have been acknowledged, and therefore a restarted input resumes exactly where it left off without duplicates or data loss. Files consumed with the codecs ` + "`lines`, `delim`, `chunker` and `regex`" + ` are resumed by seeking to the byte offset of the last acknowledged message. Since other codecs such as ` + "`gzip/lines`" + ` read ahead and transform the bytes of a file their position is stored as a count of messages consumed, and therefore those files are read from the beginning on restart, with previously consumed messages skipped.`,
 		Categories: []Category{
 			CategoryLocal,
 		},
@@ -58,6 +75,24 @@ input:
   file:
     paths: [ ./data/*.csv ]
     codec: csv
+`,
+			},
+			{
+				Title:   "Tail Log Files",
+				Summary: "In order to ship application logs we can follow a directory of log files, storing the position of each file in a cache so that restarts resume where they left off:",
+				Config: `
+input:
+  file:
+    paths: [ /var/log/app/*.log ]
+    codec: lines
+    follow:
+      enabled: true
+      cache: positions
+
+cache_resources:
+  - label: positions
+    file:
+      directory: /var/lib/benthos/positions
 `,
 			},
 		},
@@ -68,13 +103,14 @@ input:
 
 // FileConfig contains configuration values for the File input type.
 type FileConfig struct {
-	Path           string   `json:"path" yaml:"path"`
-	Paths          []string `json:"paths" yaml:"paths"`
-	Codec          string   `json:"codec" yaml:"codec"`
-	Multipart      bool     `json:"multipart" yaml:"multipart"`
-	MaxBuffer      int      `json:"max_buffer" yaml:"max_buffer"`
-	Delim          string   `json:"delimiter" yaml:"delimiter"`
-	DeleteOnFinish bool     `json:"delete_on_finish" yaml:"delete_on_finish"`
+	Path           string           `json:"path" yaml:"path"`
+	Paths          []string         `json:"paths" yaml:"paths"`
+	Codec          string           `json:"codec" yaml:"codec"`
+	Multipart      bool             `json:"multipart" yaml:"multipart"`
+	MaxBuffer      int              `json:"max_buffer" yaml:"max_buffer"`
+	Delim          string           `json:"delimiter" yaml:"delimiter"`
+	DeleteOnFinish bool             `json:"delete_on_finish" yaml:"delete_on_finish"`
+	Follow         FileFollowConfig `json:"follow" yaml:"follow"`
 }
 
 // NewFileConfig creates a new FileConfig with default values.
@@ -88,6 +124,12 @@ func NewFileConfig() FileConfig {
 		MaxBuffer:      1000000,
 		Delim:          "",
 		DeleteOnFinish: false,
+		Follow: FileFollowConfig{
+			Enabled:        false,
+			PollInterval:   "1s",
+			RescanInterval: "5s",
+			Cache:          "",
+		},
 	}
 }
 
@@ -104,6 +146,13 @@ func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type
 	if conf.File.Multipart && !strings.HasSuffix(conf.File.Codec, "/multipart") {
 		conf.File.Codec += "/multipart"
 	}
+	if conf.File.Follow.Enabled {
+		rdr, err := newFileFollowConsumer(conf.File, mgr, log)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsyncReader(TypeFile, true, reader.NewAsyncPreserver(rdr), log, stats)
+	}
 	rdr, err := newFileConsumer(conf.File, log)
 	if err != nil {
 		return nil, err
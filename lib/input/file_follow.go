@@ -0,0 +1,626 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/codec"
+	"github.com/Jeffail/benthos/v3/internal/filepath"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// FileFollowConfig contains configuration fields for the follow mode of the
+// File input type.
+type FileFollowConfig struct {
+	Enabled        bool   `json:"enabled" yaml:"enabled"`
+	PollInterval   string `json:"poll_interval" yaml:"poll_interval"`
+	RescanInterval string `json:"rescan_interval" yaml:"rescan_interval"`
+	Cache          string `json:"cache" yaml:"cache"`
+}
+
+//------------------------------------------------------------------------------
+
+// fileFollowCheckpoint is the position of a file stored within a cache. Files
+// consumed with codecs that report the exact bytes consumed by each message
+// are resumed by seeking to the byte offset. Other codecs read ahead of (or
+// decompress) the underlying bytes, and therefore those files are resumed by
+// skipping the number of messages consumed, with the offset only used for
+// detecting truncated files.
+type fileFollowCheckpoint struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Count  uint64 `json:"count"`
+}
+
+// fileTailReader reads a file and, when blocking is enabled, rather than
+// returning io.EOF once the end of the file is reached blocks until more data
+// is written to it. An io.EOF is returned once the file has been drained and is
+// either truncated, or the path of the file no longer points to it due to being
+// rotated or removed.
+type fileTailReader struct {
+	file         *os.File
+	path         string
+	info         os.FileInfo
+	blocking     bool
+	pollInterval time.Duration
+	closedChan   <-chan struct{}
+
+	offset    int64
+	truncated bool
+	rotated   bool
+}
+
+func (t *fileTailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.file.Read(p)
+		if n > 0 {
+			t.offset += int64(n)
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if !t.blocking {
+			return 0, io.EOF
+		}
+
+		if info, err := t.file.Stat(); err == nil && info.Size() < t.offset {
+			t.truncated = true
+			return 0, io.EOF
+		}
+
+		if info, err := os.Stat(t.path); err != nil || !os.SameFile(info, t.info) {
+			// Data might have been written between our last read and the
+			// rotation, so drain what's left before finishing.
+			if n, _ = t.file.Read(p); n > 0 {
+				t.offset += int64(n)
+				return n, nil
+			}
+			t.rotated = true
+			return 0, io.EOF
+		}
+
+		select {
+		case <-time.After(t.pollInterval):
+		case <-t.closedChan:
+			return 0, types.ErrTypeClosed
+		}
+	}
+}
+
+func (t *fileTailReader) Close() error {
+	return t.file.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// fileFollowAcks tracks the acknowledgements of messages consumed from a file
+// in order to determine the highest position where all prior messages have
+// been acknowledged.
+type fileFollowAcks struct {
+	mut       sync.Mutex
+	committed uint64
+	pending   map[uint64]int64
+}
+
+func (a *fileFollowAcks) ack(seq uint64, offset int64) (fileFollowCheckpoint, bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	a.pending[seq] = offset
+
+	var cp fileFollowCheckpoint
+	advanced := false
+	for {
+		offset, exists := a.pending[a.committed+1]
+		if !exists {
+			break
+		}
+		delete(a.pending, a.committed+1)
+		a.committed++
+		cp.Offset = offset
+		advanced = true
+	}
+	cp.Count = a.committed
+	return cp, advanced
+}
+
+//------------------------------------------------------------------------------
+
+// fileFollowCodecStreams returns whether a codec emits messages as data is
+// read, and therefore whether files can be tailed with it. Codecs that can't be
+// streamed only emit messages once a file has been read in full, such as
+// all-bytes, or block on data that doesn't yet exist, such as gzip.
+func fileFollowCodecStreams(codec string) bool {
+	if codec == "auto" {
+		return false
+	}
+	for _, c := range strings.Split(codec, "/") {
+		if c == "all-bytes" || c == "tar" || c == "gzip" || strings.HasSuffix(c, "-gzip") {
+			return false
+		}
+	}
+	return true
+}
+
+// fileFollowCodecSeeks returns whether a codec reports the exact byte offset
+// of each message within a file, and therefore whether files consumed with it
+// can be resumed by seeking to the offset of a checkpoint.
+func fileFollowCodecSeeks(codec string) bool {
+	if codec == "lines" {
+		return true
+	}
+	for _, prefix := range []string{"delim:", "chunker:", "regex:"} {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type fileFollowMsg struct {
+	msg   types.Message
+	ackFn reader.AsyncAckFn
+}
+
+type fileFollowConsumer struct {
+	log log.Modular
+	mgr types.Manager
+
+	paths          []string
+	scannerCtor    codec.ReaderConstructor
+	streamed       bool
+	seekable       bool
+	pollInterval   time.Duration
+	rescanInterval time.Duration
+	cache          string
+
+	msgChan chan fileFollowMsg
+
+	tailersMut  sync.Mutex
+	tailers     map[string]struct{}
+	generations map[string]uint64
+	tailersWG   sync.WaitGroup
+
+	// Serialises checkpoint writes so that a checkpoint of a rotated file
+	// can't be written after one of its replacement.
+	checkpointMut sync.Mutex
+
+	ctx        context.Context
+	done       func()
+	startOnce  sync.Once
+	closedChan chan struct{}
+}
+
+func newFileFollowConsumer(conf FileConfig, mgr types.Manager, log log.Modular) (*fileFollowConsumer, error) {
+	if conf.DeleteOnFinish {
+		return nil, errors.New("delete_on_finish cannot be used with follow mode")
+	}
+
+	// Ensures that the paths are valid patterns.
+	if _, err := filepath.Globs(conf.Paths); err != nil {
+		return nil, err
+	}
+
+	codecConf := codec.NewReaderConfig()
+	codecConf.MaxScanTokenSize = conf.MaxBuffer
+	ctor, err := codec.GetReader(conf.Codec, codecConf)
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval, err := time.ParseDuration(conf.Follow.PollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse follow poll interval: %w", err)
+	}
+	rescanInterval, err := time.ParseDuration(conf.Follow.RescanInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse follow rescan interval: %w", err)
+	}
+
+	if conf.Follow.Cache != "" {
+		if err := interop.ProbeCache(context.Background(), mgr, conf.Follow.Cache); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &fileFollowConsumer{
+		log:            log,
+		mgr:            mgr,
+		paths:          conf.Paths,
+		scannerCtor:    ctor,
+		streamed:       fileFollowCodecStreams(conf.Codec),
+		seekable:       fileFollowCodecSeeks(conf.Codec),
+		pollInterval:   pollInterval,
+		rescanInterval: rescanInterval,
+		cache:          conf.Follow.Cache,
+		msgChan:        make(chan fileFollowMsg),
+		tailers:        map[string]struct{}{},
+		generations:    map[string]uint64{},
+		closedChan:     make(chan struct{}),
+	}
+	f.ctx, f.done = context.WithCancel(context.Background())
+	return f, nil
+}
+
+// ConnectWithContext begins scanning for and tailing files.
+func (f *fileFollowConsumer) ConnectWithContext(ctx context.Context) error {
+	f.startOnce.Do(func() {
+		go f.loop()
+	})
+	return nil
+}
+
+func (f *fileFollowConsumer) loop() {
+	defer func() {
+		f.tailersWG.Wait()
+		close(f.closedChan)
+	}()
+
+	ticker := time.NewTicker(f.rescanInterval)
+	defer ticker.Stop()
+
+	for {
+		f.scan()
+		select {
+		case <-ticker.C:
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *fileFollowConsumer) scan() {
+	paths, err := filepath.Globs(f.paths)
+	if err != nil {
+		f.log.Errorf("Failed to expand paths: %v\n", err)
+		return
+	}
+
+	f.tailersMut.Lock()
+	defer f.tailersMut.Unlock()
+
+	for _, path := range paths {
+		if _, exists := f.tailers[path]; exists {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		f.tailers[path] = struct{}{}
+		f.tailersWG.Add(1)
+		go f.tail(path)
+	}
+}
+
+// fileFollowState is the state of a file being consumed, which is carried
+// across each consumption of the file until it is rotated or truncated.
+type fileFollowState struct {
+	id   string
+	gen  uint64
+	acks *fileFollowAcks
+
+	// The number of messages read from the file and the offset of the bytes
+	// read.
+	seq    uint64
+	offset int64
+
+	info      os.FileInfo
+	truncated bool
+	rotated   bool
+}
+
+func (f *fileFollowConsumer) tail(path string) {
+	defer func() {
+		f.tailersMut.Lock()
+		delete(f.tailers, path)
+		f.tailersMut.Unlock()
+		f.tailersWG.Done()
+	}()
+
+	var state *fileFollowState
+	useCheckpoint := true
+	for {
+		file, err := os.Open(path)
+		if err != nil {
+			// The file has likely been rotated and not yet replaced, in
+			// which case a replacement is picked up by a later scan.
+			if !os.IsNotExist(err) {
+				f.log.Errorf("Failed to open file '%v': %v\n", path, err)
+			}
+			return
+		}
+
+		if state, err = f.consumeFile(path, file, state, useCheckpoint); err != nil {
+			if f.ctx.Err() == nil {
+				f.log.Errorf("Failed to consume file '%v': %v\n", path, err)
+			}
+			return
+		}
+		if f.ctx.Err() != nil {
+			return
+		}
+
+		// Files consumed with codecs that can't be streamed are read in
+		// full, and so we wait for a change before reading them again.
+		if !f.streamed && !f.waitForChange(path, state) {
+			return
+		}
+
+		if state.truncated {
+			// The stored checkpoint might not yet reflect the truncation
+			// and so it's ignored.
+			f.log.Infof("File '%v' was truncated, consuming from the beginning\n", path)
+			state, useCheckpoint = nil, false
+		} else if state.rotated {
+			f.log.Infof("File '%v' was rotated or removed\n", path)
+			state, useCheckpoint = nil, true
+		}
+	}
+}
+
+// waitForChange blocks until a file that has been read in full is either
+// rotated, truncated or has grown, and sets the state accordingly. Returns
+// false if the input is closed before a change is found.
+func (f *fileFollowConsumer) waitForChange(path string, state *fileFollowState) bool {
+	for {
+		info, err := os.Stat(path)
+		if err != nil || !os.SameFile(info, state.info) {
+			state.rotated = true
+			return true
+		}
+		if info.Size() < state.offset {
+			state.truncated = true
+			return true
+		}
+		if info.Size() > state.offset {
+			return true
+		}
+		select {
+		case <-time.After(f.pollInterval):
+		case <-f.ctx.Done():
+			return false
+		}
+	}
+}
+
+func (f *fileFollowConsumer) nextGeneration(path string) uint64 {
+	f.tailersMut.Lock()
+	defer f.tailersMut.Unlock()
+	f.generations[path]++
+	return f.generations[path]
+}
+
+func (f *fileFollowConsumer) getCheckpoint(path string) (cp fileFollowCheckpoint, exists bool) {
+	var getErr error
+	if err := interop.AccessCache(f.ctx, f.mgr, f.cache, func(cache types.Cache) {
+		var cpBytes []byte
+		if cpBytes, getErr = cache.Get(path); getErr == nil {
+			getErr = json.Unmarshal(cpBytes, &cp)
+		}
+	}); err != nil {
+		f.log.Errorf("Failed to access cache for file checkpoints: %v\n", err)
+		return cp, false
+	}
+	if getErr != nil {
+		if !errors.Is(getErr, types.ErrKeyNotFound) {
+			f.log.Errorf("Failed to read checkpoint of file '%v': %v\n", path, getErr)
+		}
+		return cp, false
+	}
+	return cp, true
+}
+
+func (f *fileFollowConsumer) setCheckpoint(path string, gen uint64, cp fileFollowCheckpoint) {
+	f.checkpointMut.Lock()
+	defer f.checkpointMut.Unlock()
+
+	// Acknowledgements might arrive after a file has been rotated or
+	// truncated, in which case the checkpoint of the new file takes priority.
+	f.tailersMut.Lock()
+	currentGen := f.generations[path]
+	f.tailersMut.Unlock()
+	if currentGen != gen {
+		return
+	}
+
+	cpBytes, err := json.Marshal(cp)
+	if err != nil {
+		f.log.Errorf("Failed to marshal checkpoint of file '%v': %v\n", path, err)
+		return
+	}
+
+	var setErr error
+	if err := interop.AccessCache(context.Background(), f.mgr, f.cache, func(cache types.Cache) {
+		setErr = cache.Set(path, cpBytes)
+	}); err != nil {
+		f.log.Errorf("Failed to access cache for file checkpoints: %v\n", err)
+		return
+	}
+	if setErr != nil {
+		f.log.Errorf("Failed to write checkpoint of file '%v': %v\n", path, setErr)
+	}
+}
+
+func (f *fileFollowConsumer) consumeFile(path string, file *os.File, state *fileFollowState, useCheckpoint bool) (*fileFollowState, error) {
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Messages that were already read from the file are skipped, which is
+	// either because the file is being read again after growing, or because
+	// a checkpoint of the file was found. Files consumed with codecs that
+	// report byte offsets are instead resumed from the offset of a checkpoint.
+	var skip uint64
+	var seek int64
+	if id := fileIdentity(info); state == nil || state.id != id || !os.SameFile(info, state.info) {
+		state = &fileFollowState{
+			id:  id,
+			gen: f.nextGeneration(path),
+		}
+		if f.cache != "" {
+			if useCheckpoint {
+				if cp, exists := f.getCheckpoint(path); exists && cp.ID == id && cp.Offset <= info.Size() {
+					skip = cp.Count
+					if f.seekable {
+						seek = cp.Offset
+					}
+				}
+			}
+			if skip == 0 {
+				f.setCheckpoint(path, state.gen, fileFollowCheckpoint{ID: id})
+			}
+		}
+		state.acks = &fileFollowAcks{
+			committed: skip,
+			pending:   map[uint64]int64{},
+		}
+	} else {
+		skip = state.seq
+	}
+	state.info = info
+	state.seq, state.offset = 0, 0
+	if seek > 0 {
+		if _, err := file.Seek(seek, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		// Messages prior to the offset are never read and so the sequence
+		// continues from the count of the checkpoint.
+		state.seq, state.offset = skip, seek
+	}
+
+	tailer := &fileTailReader{
+		file:         file,
+		path:         path,
+		info:         info,
+		blocking:     f.streamed,
+		pollInterval: f.pollInterval,
+		closedChan:   f.ctx.Done(),
+		offset:       seek,
+	}
+	scanner, err := f.scannerCtor(path, tailer, func(ctx context.Context, err error) error {
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	defer scanner.Close(context.Background())
+
+	var offsetReader codec.ByteOffsetReader
+	if f.seekable {
+		offsetReader, _ = scanner.(codec.ByteOffsetReader)
+	}
+
+	if seek > 0 {
+		f.log.Infof("Consuming from file '%v' at offset %v\n", path, seek)
+	} else if skip > 0 {
+		f.log.Infof("Consuming from file '%v' after %v messages\n", path, skip)
+	} else {
+		f.log.Infof("Consuming from file '%v'\n", path)
+	}
+
+	id, gen, acks := state.id, state.gen, state.acks
+	ack := func(seq uint64, offset int64) {
+		if cp, advanced := acks.ack(seq, offset); advanced && f.cache != "" {
+			cp.ID = id
+			f.setCheckpoint(path, gen, cp)
+		}
+	}
+
+	for {
+		parts, codecAckFn, err := scanner.Next(f.ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				state.offset = tailer.offset
+				state.truncated, state.rotated = tailer.truncated, tailer.rotated
+				return state, nil
+			}
+			return nil, err
+		}
+
+		state.seq++
+		if state.seq <= skip {
+			_ = codecAckFn(f.ctx, nil)
+			continue
+		}
+
+		msg := message.New(nil)
+		for _, part := range parts {
+			if len(part.Get()) > 0 {
+				part.Metadata().Set("path", path)
+				msg.Append(part)
+			}
+		}
+
+		msgSeq, msgOffset := state.seq, tailer.offset
+		if offsetReader != nil {
+			msgOffset = seek + offsetReader.ByteOffset()
+		}
+		if msg.Len() == 0 {
+			_ = codecAckFn(f.ctx, nil)
+			ack(msgSeq, msgOffset)
+			continue
+		}
+
+		select {
+		case f.msgChan <- fileFollowMsg{
+			msg: msg,
+			ackFn: func(ctx context.Context, res types.Response) error {
+				err := codecAckFn(ctx, res.Error())
+				if res.Error() == nil {
+					ack(msgSeq, msgOffset)
+				}
+				return err
+			},
+		}:
+		case <-f.ctx.Done():
+			return state, nil
+		}
+	}
+}
+
+// ReadWithContext attempts to read a new message from the tailed files.
+func (f *fileFollowConsumer) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	select {
+	case m := <-f.msgChan:
+		return m.msg, m.ackFn, nil
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	case <-f.ctx.Done():
+		return nil, nil, types.ErrTypeClosed
+	}
+}
+
+// CloseAsync begins cleaning up resources used by this reader asynchronously.
+func (f *fileFollowConsumer) CloseAsync() {
+	f.done()
+	f.startOnce.Do(func() {
+		close(f.closedChan)
+	})
+}
+
+// WaitForClose will block until either the reader is closed or a specified
+// timeout occurs.
+func (f *fileFollowConsumer) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-f.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package input
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns a string that uniquely identifies a file on disk
+// regardless of its path, which is the device and inode of the file.
+func fileIdentity(info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%v:%v", stat.Dev, stat.Ino)
+	}
+	return ""
+}
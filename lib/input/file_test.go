@@ -1,14 +1,20 @@
 package input
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/cache"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
@@ -217,3 +223,238 @@ func TestFileDirectory(t *testing.T) {
 		t.Errorf("Wrong result: %v != %v", act, exp)
 	}
 }
+
+func fileFollowTestConfig(paths ...string) Config {
+	conf := NewConfig()
+	conf.File.Paths = paths
+	conf.File.Follow.Enabled = true
+	conf.File.Follow.PollInterval = "10ms"
+	conf.File.Follow.RescanInterval = "10ms"
+	return conf
+}
+
+func fileFollowRead(t *testing.T, f Type) types.Transaction {
+	t.Helper()
+
+	select {
+	case ts, open := <-f.TransactionChan():
+		require.True(t, open)
+		return ts
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for message")
+	}
+	return types.Transaction{}
+}
+
+func fileFollowReadAndAck(t *testing.T, f Type) string {
+	t.Helper()
+
+	ts := fileFollowRead(t, f)
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for response")
+	}
+	return string(ts.Payload.Get(0).Get())
+}
+
+func fileFollowAppend(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestFileFollowGrowthAndNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	fooPath := filepath.Join(dir, "foo.log")
+	fileFollowAppend(t, fooPath, "foo1\nfoo2\n")
+
+	f, err := NewFile(fileFollowTestConfig(filepath.Join(dir, "*.log")), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		f.CloseAsync()
+		assert.NoError(t, f.WaitForClose(time.Second))
+	})
+
+	ts := fileFollowRead(t, f)
+	assert.Equal(t, "foo1", string(ts.Payload.Get(0).Get()))
+	assert.Equal(t, fooPath, ts.Payload.Get(0).Metadata().Get("path"))
+	ts.ResponseChan <- response.NewAck()
+	assert.Equal(t, "foo2", fileFollowReadAndAck(t, f))
+
+	// Partial lines are not consumed until they're complete.
+	fileFollowAppend(t, fooPath, "fo")
+	fileFollowAppend(t, fooPath, "o3\n")
+	assert.Equal(t, "foo3", fileFollowReadAndAck(t, f))
+
+	fileFollowAppend(t, filepath.Join(dir, "bar.log"), "bar1\n")
+	assert.Equal(t, "bar1", fileFollowReadAndAck(t, f))
+
+	fileFollowAppend(t, filepath.Join(dir, "baz.txt"), "baz1\n")
+	fileFollowAppend(t, fooPath, "foo4\n")
+	assert.Equal(t, "foo4", fileFollowReadAndAck(t, f))
+}
+
+func TestFileFollowRotationAndTruncation(t *testing.T) {
+	dir := t.TempDir()
+	fooPath := filepath.Join(dir, "foo.log")
+	fileFollowAppend(t, fooPath, "foo1\n")
+
+	f, err := NewFile(fileFollowTestConfig(filepath.Join(dir, "*.log")), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		f.CloseAsync()
+		assert.NoError(t, f.WaitForClose(time.Second))
+	})
+
+	assert.Equal(t, "foo1", fileFollowReadAndAck(t, f))
+
+	// Data written to the rotated file before the new file appears is still
+	// consumed.
+	rotatedPath := filepath.Join(dir, "foo.log.1")
+	require.NoError(t, os.Rename(fooPath, rotatedPath))
+	fileFollowAppend(t, rotatedPath, "foo2\n")
+	assert.Equal(t, "foo2", fileFollowReadAndAck(t, f))
+
+	fileFollowAppend(t, fooPath, "bar1\n")
+	assert.Equal(t, "bar1", fileFollowReadAndAck(t, f))
+
+	require.NoError(t, os.Truncate(fooPath, 0))
+	fileFollowAppend(t, fooPath, "baz\n")
+	assert.Equal(t, "baz", fileFollowReadAndAck(t, f))
+}
+
+func TestFileFollowCheckpoints(t *testing.T) {
+	for _, codec := range []string{"lines", "gzip/lines"} {
+		codec := codec
+		t.Run(codec, func(t *testing.T) {
+			dir := t.TempDir()
+			fooPath := filepath.Join(dir, "foo.log")
+
+			var buf bytes.Buffer
+			var w io.Writer = &buf
+			var zw *gzip.Writer
+			if codec == "gzip/lines" {
+				zw = gzip.NewWriter(&buf)
+				w = zw
+			}
+			_, err := w.Write([]byte("foo1\nfoo2\nfoo3\nfoo4\n"))
+			require.NoError(t, err)
+			if zw != nil {
+				require.NoError(t, zw.Close())
+			}
+			require.NoError(t, os.WriteFile(fooPath, buf.Bytes(), 0o644))
+
+			memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+			mgr := &fakeProcMgr{caches: map[string]types.Cache{"positions": memCache}}
+
+			conf := fileFollowTestConfig(fooPath)
+			conf.File.Codec = codec
+			conf.File.Follow.Cache = "positions"
+
+			f, err := NewFile(conf, mgr, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+
+			assert.Equal(t, "foo1", fileFollowReadAndAck(t, f))
+
+			// The third message is acknowledged before the second, and
+			// therefore the position only covers the first message.
+			ts2 := fileFollowRead(t, f)
+			assert.Equal(t, "foo2", string(ts2.Payload.Get(0).Get()))
+			assert.Equal(t, "foo3", fileFollowReadAndAck(t, f))
+
+			assert.Eventually(t, func() bool {
+				cpBytes, err := memCache.Get(fooPath)
+				if err != nil {
+					return false
+				}
+				var cp fileFollowCheckpoint
+				require.NoError(t, json.Unmarshal(cpBytes, &cp))
+				if codec == "lines" && cp.Offset != 5 {
+					return false
+				}
+				return cp.Count == 1
+			}, time.Second, time.Millisecond*10)
+
+			f.CloseAsync()
+			go func() {
+				ts2.ResponseChan <- response.NewNoack()
+			}()
+			assert.NoError(t, f.WaitForClose(time.Second*5))
+
+			if codec == "lines" {
+				// Files consumed with lines are resumed from the byte offset
+				// of the checkpoint, and so changes to the data already
+				// consumed aren't seen.
+				file, err := os.OpenFile(fooPath, os.O_WRONLY, 0o644)
+				require.NoError(t, err)
+				_, err = file.WriteAt([]byte("a\nbc\n"), 0)
+				require.NoError(t, err)
+				require.NoError(t, file.Close())
+			}
+
+			f, err = NewFile(conf, mgr, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				f.CloseAsync()
+				assert.NoError(t, f.WaitForClose(time.Second))
+			})
+
+			assert.Equal(t, "foo2", fileFollowReadAndAck(t, f))
+			assert.Equal(t, "foo3", fileFollowReadAndAck(t, f))
+			assert.Equal(t, "foo4", fileFollowReadAndAck(t, f))
+
+			assert.Eventually(t, func() bool {
+				cpBytes, err := memCache.Get(fooPath)
+				if err != nil {
+					return false
+				}
+				var cp fileFollowCheckpoint
+				require.NoError(t, json.Unmarshal(cpBytes, &cp))
+				return cp.Count == 4
+			}, time.Second, time.Millisecond*10)
+		})
+	}
+}
+
+func TestFileFollowConfigErrors(t *testing.T) {
+	conf := fileFollowTestConfig("foo.log")
+	conf.File.DeleteOnFinish = true
+	_, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "delete_on_finish")
+
+	conf = fileFollowTestConfig("foo.log")
+	conf.File.Follow.Cache = "nope"
+	_, err = NewFile(conf, &fakeProcMgr{}, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+}
+
+func TestFileFollowWholeFileCodec(t *testing.T) {
+	dir := t.TempDir()
+	fooPath := filepath.Join(dir, "foo.json")
+	fileFollowAppend(t, fooPath, `{"id":1}`)
+
+	conf := fileFollowTestConfig(fooPath)
+	conf.File.Codec = "all-bytes"
+
+	f, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		f.CloseAsync()
+		assert.NoError(t, f.WaitForClose(time.Second))
+	})
+
+	assert.Equal(t, `{"id":1}`, fileFollowReadAndAck(t, f))
+
+	// Replacing the file results in it being consumed again.
+	tmpPath := filepath.Join(dir, "foo.json.tmp")
+	fileFollowAppend(t, tmpPath, `{"id":2}`)
+	require.NoError(t, os.Rename(tmpPath, fooPath))
+	assert.Equal(t, `{"id":2}`, fileFollowReadAndAck(t, f))
+}
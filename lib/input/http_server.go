@@ -3,6 +3,8 @@ package input
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +12,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +20,7 @@ import (
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/http/auth"
 	httpdocs "github.com/Jeffail/benthos/v3/internal/http/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
 	imetadata "github.com/Jeffail/benthos/v3/internal/metadata"
@@ -65,6 +69,10 @@ This endpoint expects POST requests where the entire request body is consumed as
 
 If the request contains a multipart ` + "`content-type`" + ` header as per [rfc1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html) then the multiple parts are consumed as a batch of messages, where each body part is a message of the batch.
 
+#### ` + "`routes`" + `
+
+A list of additional endpoints that behave the same as ` + "`path`" + `, where each route may optionally specify its own allowed verbs. The route of each request is added to messages as the metadata field ` + "`http_server_route`" + `.
+
 #### ` + "`ws_path` (defaults to `/post/ws`)" + `
 
 Creates a websocket connection, where payloads received on the socket are passed through the pipeline as a batch of one message.
@@ -73,6 +81,12 @@ You may specify an optional ` + "`ws_welcome_message`" + `, which is a static pa
 
 It's also possible to specify a ` + "`ws_rate_limit_message`" + `, which is a static payload to be sent to clients that have triggered the servers rate limit.
 
+### Authentication
+
+Requests to all endpoints can be authenticated using basic authentication, bearer JSON Web Tokens validated against a JSON Web Key Set, and HMAC signatures of the request body such as those sent with webhooks, as configured within the field ` + "`auth`" + `. Claims of JSON Web Tokens can be added to messages as metadata with the field ` + "`auth.jwt.claims_to_metadata`" + `.
+
+When TLS is enabled clients can be required to present a certificate signed by a certificate authority by specifying a ` + "`client_ca_file`" + `, in which case the subject of the client certificate is added to messages as the metadata field ` + "`http_server_tls_subject`" + `.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -80,11 +94,14 @@ This input adds the following metadata fields to each message:
 ` + "``` text" + `
 - http_server_user_agent
 - http_server_request_path
+- http_server_route
 - http_server_verb
+- http_server_tls_subject
 - All headers (only first values are taken)
 - All query parameters
 - All path parameters
 - All cookies
+- Any configured JSON Web Token claims
 ` + "```" + `
 
 You can access these metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`,
@@ -92,6 +109,10 @@ You can access these metadata fields using [function interpolation](/docs/config
 			docs.FieldCommon("address", "An alternative address to host from. If left empty the service wide address is used."),
 			docs.FieldCommon("path", "The endpoint path to listen for POST requests."),
 			docs.FieldCommon("ws_path", "The endpoint path to create websocket connections from."),
+			docs.FieldAdvanced("routes", "A list of additional endpoints to listen for requests on.").Array().WithChildren(
+				docs.FieldString("path", "The endpoint path of the route.", "/orders", "/customers/{id}").HasDefault(""),
+				docs.FieldString("allowed_verbs", "An array of verbs that are allowed for the route, which defaults to `allowed_verbs` when empty.").Array().HasDefault([]string{}),
+			).AtVersion("3.65.0"),
 			docs.FieldAdvanced("ws_welcome_message", "An optional message to deliver to fresh websocket connections."),
 			docs.FieldAdvanced("ws_rate_limit_message", "An optional message to delivery to websocket connections that are rate limited."),
 			docs.FieldCommon("allowed_verbs", "An array of verbs that are allowed for the `path` endpoint.").AtVersion("3.33.0").Array(),
@@ -99,7 +120,9 @@ You can access these metadata fields using [function interpolation](/docs/config
 			docs.FieldCommon("rate_limit", "An optional [rate limit](/docs/components/rate_limits/about) to throttle requests by."),
 			docs.FieldAdvanced("cert_file", "Enable TLS by specifying a certificate and key file. Only valid with a custom `address`."),
 			docs.FieldAdvanced("key_file", "Enable TLS by specifying a certificate and key file. Only valid with a custom `address`."),
+			docs.FieldAdvanced("client_ca_file", "An optional certificate authority file, which when specified requires clients to present a certificate signed by it. Only valid when TLS is enabled.").AtVersion("3.65.0"),
 			corsSpec,
+			httpdocs.ServerAuthFieldSpec(),
 			docs.FieldAdvanced("sync_response", "Customise messages returned via [synchronous responses](/docs/guides/sync_responses).").WithChildren(
 				docs.FieldCommon(
 					"status",
@@ -139,11 +162,19 @@ func NewHTTPServerResponseConfig() HTTPServerResponseConfig {
 	}
 }
 
+// HTTPServerRouteConfig contains configuration for an additional endpoint of
+// the HTTPServer input type.
+type HTTPServerRouteConfig struct {
+	Path         string   `json:"path" yaml:"path"`
+	AllowedVerbs []string `json:"allowed_verbs" yaml:"allowed_verbs"`
+}
+
 // HTTPServerConfig contains configuration for the HTTPServer input type.
 type HTTPServerConfig struct {
 	Address            string                   `json:"address" yaml:"address"`
 	Path               string                   `json:"path" yaml:"path"`
 	WSPath             string                   `json:"ws_path" yaml:"ws_path"`
+	Routes             []HTTPServerRouteConfig  `json:"routes" yaml:"routes"`
 	WSWelcomeMessage   string                   `json:"ws_welcome_message" yaml:"ws_welcome_message"`
 	WSRateLimitMessage string                   `json:"ws_rate_limit_message" yaml:"ws_rate_limit_message"`
 	AllowedVerbs       []string                 `json:"allowed_verbs" yaml:"allowed_verbs"`
@@ -151,7 +182,9 @@ type HTTPServerConfig struct {
 	RateLimit          string                   `json:"rate_limit" yaml:"rate_limit"`
 	CertFile           string                   `json:"cert_file" yaml:"cert_file"`
 	KeyFile            string                   `json:"key_file" yaml:"key_file"`
+	ClientCAFile       string                   `json:"client_ca_file" yaml:"client_ca_file"`
 	CORS               httpdocs.ServerCORS      `json:"cors" yaml:"cors"`
+	Auth               auth.Config              `json:"auth" yaml:"auth"`
 	Response           HTTPServerResponseConfig `json:"sync_response" yaml:"sync_response"`
 }
 
@@ -161,17 +194,20 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		Address:            "",
 		Path:               "/post",
 		WSPath:             "/post/ws",
+		Routes:             []HTTPServerRouteConfig{},
 		WSWelcomeMessage:   "",
 		WSRateLimitMessage: "",
 		AllowedVerbs: []string{
 			"POST",
 		},
-		Timeout:   "5s",
-		RateLimit: "",
-		CertFile:  "",
-		KeyFile:   "",
-		CORS:      httpdocs.NewServerCORS(),
-		Response:  NewHTTPServerResponseConfig(),
+		Timeout:      "5s",
+		RateLimit:    "",
+		CertFile:     "",
+		KeyFile:      "",
+		ClientCAFile: "",
+		CORS:         httpdocs.NewServerCORS(),
+		Auth:         auth.NewConfig(),
+		Response:     NewHTTPServerResponseConfig(),
 	}
 }
 
@@ -195,6 +231,11 @@ type HTTPServer struct {
 	responseStatus  *field.Expression
 	responseHeaders map[string]*field.Expression
 	metaFilter      *imetadata.IncludeFilter
+	auth            *auth.Authenticator
+
+	// The paths of all endpoints registered with the service-wide HTTP server,
+	// which are replaced with handlers that do nothing once closed.
+	registeredPaths []string
 
 	handlerWG    sync.WaitGroup
 	transactions chan types.Transaction
@@ -207,6 +248,7 @@ type HTTPServer struct {
 	mCount         metrics.StatCounter
 	mLatency       metrics.StatTimer
 	mRateLimited   metrics.StatCounter
+	mUnauthorized  metrics.StatCounter
 	mWSRateLimited metrics.StatCounter
 	mRcvd          metrics.StatCounter
 	mPartsRcvd     metrics.StatCounter
@@ -249,6 +291,24 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		return nil, errors.New("must provide at least one allowed verb")
 	}
 
+	if len(conf.HTTPServer.ClientCAFile) > 0 {
+		if server == nil || (len(conf.HTTPServer.CertFile) == 0 && len(conf.HTTPServer.KeyFile) == 0) {
+			return nil, errors.New("a client_ca_file can only be specified with a custom address and TLS enabled")
+		}
+		caBytes, err := os.ReadFile(conf.HTTPServer.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("failed to parse client ca file")
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}
+	}
+
 	h := HTTPServer{
 		shutSig:         shutdown.NewSignaller(),
 		conf:            conf.HTTPServer,
@@ -266,6 +326,7 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		mCount:         stats.GetCounter("count"),
 		mLatency:       stats.GetTimer("latency"),
 		mRateLimited:   stats.GetCounter("rate_limited"),
+		mUnauthorized:  stats.GetCounter("unauthorized"),
 		mWSRateLimited: stats.GetCounter("ws.rate_limited"),
 		mRcvd:          stats.GetCounter("batch.received"),
 		mPartsRcvd:     stats.GetCounter("received"),
@@ -292,26 +353,28 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		return nil, fmt.Errorf("failed to construct metadata filter: %w", err)
 	}
 
-	postHdlr := httputil.GzipHandler(h.postHandler)
-	wsHdlr := httputil.GzipHandler(h.wsHandler)
-	if mux != nil {
-		if len(h.conf.Path) > 0 {
-			mux.HandleFunc(h.conf.Path, postHdlr)
-		}
-		if len(h.conf.WSPath) > 0 {
-			mux.HandleFunc(h.conf.WSPath, wsHdlr)
-		}
-	} else {
-		if len(h.conf.Path) > 0 {
-			mgr.RegisterEndpoint(
-				h.conf.Path, "Post a message into Benthos.", postHdlr,
-			)
+	if h.auth, err = h.conf.Auth.NewAuthenticator(); err != nil {
+		return nil, fmt.Errorf("bad auth configuration: %w", err)
+	}
+
+	if len(h.conf.Path) > 0 {
+		h.registerHandler(h.conf.Path, "Post a message into Benthos.", h.postHandler(h.conf.Path, verbs))
+	}
+	for i, route := range h.conf.Routes {
+		if len(route.Path) == 0 {
+			return nil, fmt.Errorf("route %v must specify a path", i)
 		}
-		if len(h.conf.WSPath) > 0 {
-			mgr.RegisterEndpoint(
-				h.conf.WSPath, "Post messages via websocket into Benthos.", wsHdlr,
-			)
+		routeVerbs := verbs
+		if len(route.AllowedVerbs) > 0 {
+			routeVerbs = map[string]struct{}{}
+			for _, v := range route.AllowedVerbs {
+				routeVerbs[v] = struct{}{}
+			}
 		}
+		h.registerHandler(route.Path, "Post a message into Benthos.", h.postHandler(route.Path, routeVerbs))
+	}
+	if len(h.conf.WSPath) > 0 {
+		h.registerHandler(h.conf.WSPath, "Post messages via websocket into Benthos.", h.wsHandler)
 	}
 
 	if h.conf.RateLimit != "" {
@@ -326,7 +389,68 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 
 //------------------------------------------------------------------------------
 
-func (h *HTTPServer) extractMessageFromRequest(r *http.Request) (types.Message, error) {
+func (h *HTTPServer) registerHandler(path, desc string, handler http.HandlerFunc) {
+	handler = httputil.GzipHandler(handler)
+	if h.mux != nil {
+		h.mux.HandleFunc(path, handler)
+		return
+	}
+	h.mgr.RegisterEndpoint(path, desc, handler)
+	h.registeredPaths = append(h.registeredPaths, path)
+}
+
+// authenticate a request, returning metadata extracted from its credentials,
+// or writes an unauthorized response and returns false if it fails.
+func (h *HTTPServer) authenticate(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	authMeta, err := h.auth.Authenticate(w, r)
+	if err != nil {
+		if errors.Is(err, auth.ErrUnauthorized) {
+			h.mUnauthorized.Incr(1)
+			h.log.Debugf("Request authentication failed: %v\n", err)
+			h.auth.WriteUnauthorized(w)
+		} else if errors.Is(err, auth.ErrBodyTooLarge) {
+			h.log.Debugf("Request authentication failed: %v\n", err)
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			h.log.Warnf("Request authentication failed: %v\n", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return authMeta, true
+}
+
+// addRequestMetadata adds metadata common to all endpoints to a message.
+func addRequestMetadata(meta types.Metadata, r *http.Request, route string, authMeta map[string]string) {
+	meta.Set("http_server_user_agent", r.UserAgent())
+	meta.Set("http_server_request_path", r.URL.Path)
+	meta.Set("http_server_route", route)
+	meta.Set("http_server_verb", r.Method)
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		meta.Set("http_server_tls_subject", r.TLS.PeerCertificates[0].Subject.String())
+	}
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			meta.Set(k, v[0])
+		}
+	}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			meta.Set(k, v[0])
+		}
+	}
+	for k, v := range mux.Vars(r) {
+		meta.Set(k, v)
+	}
+	for _, c := range r.Cookies() {
+		meta.Set(c.Name, c.Value)
+	}
+	for k, v := range authMeta {
+		meta.Set(k, v)
+	}
+}
+
+func (h *HTTPServer) extractMessageFromRequest(r *http.Request, route string, authMeta map[string]string) (types.Message, error) {
 	msg := message.New(nil)
 
 	contentType := r.Header.Get("Content-Type")
@@ -364,25 +488,7 @@ func (h *HTTPServer) extractMessageFromRequest(r *http.Request) (types.Message,
 	}
 
 	meta := metadata.New(nil)
-	meta.Set("http_server_user_agent", r.UserAgent())
-	meta.Set("http_server_request_path", r.URL.Path)
-	meta.Set("http_server_verb", r.Method)
-	for k, v := range r.Header {
-		if len(v) > 0 {
-			meta.Set(k, v[0])
-		}
-	}
-	for k, v := range r.URL.Query() {
-		if len(v) > 0 {
-			meta.Set(k, v[0])
-		}
-	}
-	for k, v := range mux.Vars(r) {
-		meta.Set(k, v)
-	}
-	for _, c := range r.Cookies() {
-		meta.Set(c.Name, c.Value)
-	}
+	addRequestMetadata(meta, r, route, authMeta)
 	message.SetAllMetadata(msg, meta)
 
 	textMapGeneric := map[string]interface{}{}
@@ -396,16 +502,27 @@ func (h *HTTPServer) extractMessageFromRequest(r *http.Request) (types.Message,
 	return msg, nil
 }
 
-func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
+func (h *HTTPServer) postHandler(route string, allowedVerbs map[string]struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.handlePost(route, allowedVerbs, w, r)
+	}
+}
+
+func (h *HTTPServer) handlePost(route string, allowedVerbs map[string]struct{}, w http.ResponseWriter, r *http.Request) {
 	h.handlerWG.Add(1)
 	defer h.handlerWG.Done()
 	defer r.Body.Close()
 
-	if _, exists := h.allowedVerbs[r.Method]; !exists {
+	if _, exists := allowedVerbs[r.Method]; !exists {
 		http.Error(w, "Incorrect method", http.StatusMethodNotAllowed)
 		return
 	}
 
+	authMeta, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
 	if h.conf.RateLimit != "" {
 		var tUntil time.Duration
 		var err error
@@ -428,7 +545,7 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	msg, err := h.extractMessageFromRequest(r)
+	msg, err := h.extractMessageFromRequest(r, route, authMeta)
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		h.log.Warnf("Request read failed: %v\n", err)
@@ -571,6 +688,11 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 	h.handlerWG.Add(1)
 	defer h.handlerWG.Done()
 
+	authMeta, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
 	var err error
 	defer func() {
 		if err != nil {
@@ -630,24 +752,7 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 
 		msg := message.New([][]byte{msgBytes})
 
-		meta := msg.Get(0).Metadata()
-		meta.Set("http_server_user_agent", r.UserAgent())
-		for k, v := range r.Header {
-			if len(v) > 0 {
-				meta.Set(k, v[0])
-			}
-		}
-		for k, v := range r.URL.Query() {
-			if len(v) > 0 {
-				meta.Set(k, v[0])
-			}
-		}
-		for k, v := range mux.Vars(r) {
-			meta.Set(k, v)
-		}
-		for _, c := range r.Cookies() {
-			meta.Set(c.Name, c.Value)
-		}
+		addRequestMetadata(msg.Get(0).Metadata(), r, h.conf.WSPath, authMeta)
 		tracing.InitSpans("input_http_server_websocket", msg)
 
 		store := roundtrip.NewResultStore()
@@ -702,11 +807,8 @@ func (h *HTTPServer) loop() {
 				h.log.Errorf("Failed to gracefully terminate http_server: %v\n", err)
 			}
 		} else {
-			if len(h.conf.Path) > 0 {
-				h.mgr.RegisterEndpoint(h.conf.Path, "Does nothing.", http.NotFound)
-			}
-			if len(h.conf.WSPath) > 0 {
-				h.mgr.RegisterEndpoint(h.conf.WSPath, "Does nothing.", http.NotFound)
+			for _, path := range h.registeredPaths {
+				h.mgr.RegisterEndpoint(path, "Does nothing.", http.NotFound)
 			}
 		}
 
@@ -1109,3 +1109,123 @@ func TestHTTPSyncResponseHeadersStatus(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestHTTPServerRoutes(t *testing.T) {
+	reg := apiRegGorillaMutWrapper{mut: mux.NewRouter()}
+	mgr, err := manager.New(manager.NewConfig(), reg, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	conf := input.NewConfig()
+	conf.HTTPServer.Path = "/post"
+	conf.HTTPServer.Routes = []input.HTTPServerRouteConfig{
+		{Path: "/users/{id}"},
+		{Path: "/orders", AllowedVerbs: []string{"PUT"}},
+	}
+
+	server, err := input.NewHTTPServer(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	defer func() {
+		server.CloseAsync()
+		assert.NoError(t, server.WaitForClose(time.Second))
+	}()
+
+	testServer := httptest.NewServer(reg.mut)
+	defer testServer.Close()
+
+	for _, test := range []struct {
+		verb, path, route string
+	}{
+		{verb: "POST", path: "/post", route: "/post"},
+		{verb: "POST", path: "/users/foo", route: "/users/{id}"},
+		{verb: "PUT", path: "/orders", route: "/orders"},
+	} {
+		go func(verb, path string) {
+			req, cerr := http.NewRequest(verb, testServer.URL+path, bytes.NewReader([]byte("hello world")))
+			if !assert.NoError(t, cerr) {
+				return
+			}
+			resp, cerr := http.DefaultClient.Do(req)
+			if assert.NoError(t, cerr) {
+				resp.Body.Close()
+			}
+		}(test.verb, test.path)
+
+		select {
+		case tran := <-server.TransactionChan():
+			meta := tran.Payload.Get(0).Metadata()
+			assert.Equal(t, test.route, meta.Get("http_server_route"))
+			assert.Equal(t, test.path, meta.Get("http_server_request_path"))
+			assert.Equal(t, test.verb, meta.Get("http_server_verb"))
+			if test.route == "/users/{id}" {
+				assert.Equal(t, "foo", meta.Get("id"))
+			}
+			select {
+			case tran.ResponseChan <- response.NewAck():
+			case <-time.After(time.Second * 5):
+				t.Fatal("timed out")
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	}
+
+	resp, err := http.Post(testServer.URL+"/orders", "text/plain", bytes.NewReader([]byte("hello world")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestHTTPServerBasicAuth(t *testing.T) {
+	reg := apiRegGorillaMutWrapper{mut: mux.NewRouter()}
+	mgr, err := manager.New(manager.NewConfig(), reg, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	conf := input.NewConfig()
+	conf.HTTPServer.Path = "/post"
+	conf.HTTPServer.Auth.Basic.Enabled = true
+	conf.HTTPServer.Auth.Basic.Username = "foo"
+	conf.HTTPServer.Auth.Basic.Password = "bar"
+
+	server, err := input.NewHTTPServer(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	defer func() {
+		server.CloseAsync()
+		assert.NoError(t, server.WaitForClose(time.Second))
+	}()
+
+	testServer := httptest.NewServer(reg.mut)
+	defer testServer.Close()
+
+	resp, err := http.Post(testServer.URL+"/post", "text/plain", bytes.NewReader([]byte("nope")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+
+	go func() {
+		req, cerr := http.NewRequest("POST", testServer.URL+"/post", bytes.NewReader([]byte("hello world")))
+		if !assert.NoError(t, cerr) {
+			return
+		}
+		req.SetBasicAuth("foo", "bar")
+		resp, cerr := http.DefaultClient.Do(req)
+		if assert.NoError(t, cerr) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}()
+
+	select {
+	case tran := <-server.TransactionChan():
+		assert.Equal(t, "hello world", string(tran.Payload.Get(0).Get()))
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+}
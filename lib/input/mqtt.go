@@ -1,6 +1,8 @@
 package input
 
 import (
+	"fmt"
+
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/mqttconf"
 	"github.com/Jeffail/benthos/v3/lib/input/reader"
@@ -31,10 +33,26 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](/docs/configuration/interpolation#metadata).`,
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+### MQTT 5
+
+Setting ` + "`protocol_version`" + ` to ` + "`5`" + ` connects to brokers with version 5 of the MQTT protocol, in which case the fields ` + "`mqtt_duplicate`" + ` and ` + "`mqtt_retained`" + ` are not added. Instead the user properties of messages are added as metadata, along with the following fields when they are present:
+
+` + "``` text" + `
+- mqtt_response_topic
+- mqtt_correlation_data
+- mqtt_content_type
+- mqtt_message_expiry
+` + "```" + `
+
+Shared subscriptions, where messages are distributed amongst all subscribers of a group, can be used by consuming from topics of the form ` + "`$share/<group>/<topic>`" + `. This allows multiple Benthos instances to consume the same topics in parallel.
+
+With MQTT 5 a broker only persists the session of a client when requested, therefore when ` + "`clean_session`" + ` is ` + "`false`" + ` the session is requested to never expire.`,
 		FieldSpecs: docs.FieldSpecs{
 			docs.FieldCommon("urls", "A list of URLs to connect to. If an item of the list contains commas it will be expanded into multiple URLs.").Array(),
-			docs.FieldCommon("topics", "A list of topics to consume from.").Array(),
+			docs.FieldString("protocol_version", "The version of the MQTT protocol to connect with.").HasOptions("3.1.1", "5").HasDefault("3.1.1").AtVersion("3.65.0"),
+			docs.FieldCommon("topics", "A list of topics to consume from. With MQTT 5 shared subscriptions are supported with topics of the form `$share/<group>/<topic>`.", []string{"foo/bar"}, []string{"$share/benthos/foo/#"}).Array(),
 			docs.FieldCommon("client_id", "An identifier for the client connection."),
 			docs.FieldString("dynamic_client_id_suffix", "Append a dynamically generated suffix to the specified `client_id` on each run of the pipeline. This can be useful when clustering Benthos producers.").Optional().Advanced().HasAnnotatedOptions(
 				"nanoid", "append a nanoid of length 21 characters",
@@ -46,6 +64,7 @@ You can access these metadata fields using
 			docs.FieldAdvanced("user", "A username to assume for the connection."),
 			docs.FieldAdvanced("password", "A password to provide for the connection."),
 			docs.FieldAdvanced("keepalive", "Max seconds of inactivity before a keepalive message is sent."),
+			docs.FieldInt("topic_alias_maximum", "The maximum number of topic aliases that the broker may use when sending messages, which reduces the size of messages sent on the same topics. This field is only used with MQTT 5, where a value of zero disables topic aliases.").Advanced().HasDefault(0).AtVersion("3.65.0"),
 			tls.FieldSpec().AtVersion("3.45.0"),
 			docs.FieldDeprecated("stale_connection_timeout"),
 		},
@@ -59,7 +78,16 @@ You can access these metadata fields using
 
 // NewMQTT creates a new MQTT input type.
 func NewMQTT(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	m, err := reader.NewMQTT(conf.MQTT, log, stats)
+	var m reader.Async
+	var err error
+	switch conf.MQTT.ProtocolVersion {
+	case mqttconf.ProtocolV311, "":
+		m, err = reader.NewMQTT(conf.MQTT, log, stats)
+	case mqttconf.ProtocolV5:
+		m, err = reader.NewMQTTV5(conf.MQTT, log, stats)
+	default:
+		err = fmt.Errorf("protocol_version not recognised: %v", conf.MQTT.ProtocolVersion)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +69,7 @@ input:
 					return "", false
 				}
 				return "the condition field is deprecated in favour of check", cmp.Equal(field, iDefault)
-			}),
+			}).Fixer(docs.FixConditionToCheck),
 			docs.FieldCommon("restart_input", "Whether the input should be reopened if it closes itself before the condition has resolved to true."),
 		},
 		Categories: []Category{
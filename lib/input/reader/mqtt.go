@@ -23,6 +23,7 @@ import (
 // MQTTConfig contains configuration fields for the MQTT input type.
 type MQTTConfig struct {
 	URLs                   []string      `json:"urls" yaml:"urls"`
+	ProtocolVersion        string        `json:"protocol_version" yaml:"protocol_version"`
 	QoS                    uint8         `json:"qos" yaml:"qos"`
 	Topics                 []string      `json:"topics" yaml:"topics"`
 	ClientID               string        `json:"client_id" yaml:"client_id"`
@@ -34,6 +35,7 @@ type MQTTConfig struct {
 	ConnectTimeout         string        `json:"connect_timeout" yaml:"connect_timeout"`
 	StaleConnectionTimeout string        `json:"stale_connection_timeout" yaml:"stale_connection_timeout"`
 	KeepAlive              int64         `json:"keepalive" yaml:"keepalive"`
+	TopicAliasMaximum      int           `json:"topic_alias_maximum" yaml:"topic_alias_maximum"`
 	TLS                    tls.Config    `json:"tls" yaml:"tls"`
 }
 
@@ -41,6 +43,7 @@ type MQTTConfig struct {
 func NewMQTTConfig() MQTTConfig {
 	return MQTTConfig{
 		URLs:                   []string{"tcp://localhost:1883"},
+		ProtocolVersion:        mqttconf.ProtocolV311,
 		QoS:                    1,
 		Topics:                 []string{"benthos_topic"},
 		ClientID:               "benthos_input",
@@ -51,6 +54,7 @@ func NewMQTTConfig() MQTTConfig {
 		ConnectTimeout:         "30s",
 		StaleConnectionTimeout: "",
 		KeepAlive:              30,
+		TopicAliasMaximum:      0,
 		TLS:                    tls.NewConfig(),
 	}
 }
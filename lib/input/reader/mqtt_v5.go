@@ -0,0 +1,300 @@
+package reader
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/mqttconf"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/eclipse/paho.golang/paho"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+//------------------------------------------------------------------------------
+
+// MQTTV5 is an input type that reads MQTT Pub/Sub messages using version 5 of
+// the MQTT protocol.
+type MQTTV5 struct {
+	client         *paho.Client
+	msgChan        chan *paho.Publish
+	connClosedChan <-chan struct{}
+	cMut           sync.Mutex
+
+	connectTimeout time.Duration
+	tlsConf        *tls.Config
+
+	conf MQTTConfig
+
+	interruptChan chan struct{}
+	interruptOnce sync.Once
+
+	urls []string
+
+	stats metrics.Type
+	log   log.Modular
+}
+
+// NewMQTTV5 creates a new MQTT input type that uses version 5 of the MQTT
+// protocol.
+func NewMQTTV5(
+	conf MQTTConfig, log log.Modular, stats metrics.Type,
+) (*MQTTV5, error) {
+	m := &MQTTV5{
+		conf:          conf,
+		interruptChan: make(chan struct{}),
+		stats:         stats,
+		log:           log,
+	}
+
+	var err error
+	if m.connectTimeout, err = time.ParseDuration(conf.ConnectTimeout); err != nil {
+		return nil, fmt.Errorf("unable to parse connect timeout duration string: %w", err)
+	}
+	if conf.TopicAliasMaximum < 0 || conf.TopicAliasMaximum > math.MaxUint16 {
+		return nil, fmt.Errorf("topic_alias_maximum must be between 0 and %v", math.MaxUint16)
+	}
+
+	switch m.conf.DynamicClientIDSuffix {
+	case "nanoid":
+		nid, err := gonanoid.New()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate nanoid: %w", err)
+		}
+		m.conf.ClientID += nid
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown dynamic_client_id_suffix: %v", m.conf.DynamicClientIDSuffix)
+	}
+
+	if err := m.conf.Will.Validate(); err != nil {
+		return nil, err
+	}
+
+	if m.conf.TLS.Enabled {
+		if m.tlsConf, err = m.conf.TLS.Get(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, u := range conf.URLs {
+		for _, splitURL := range strings.Split(u, ",") {
+			if len(splitURL) > 0 {
+				m.urls = append(m.urls, splitURL)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection to an MQTT server.
+func (m *MQTTV5) Connect() error {
+	return m.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a connection to an MQTT server.
+func (m *MQTTV5) ConnectWithContext(ctx context.Context) error {
+	m.cMut.Lock()
+	defer m.cMut.Unlock()
+
+	if m.client != nil {
+		return nil
+	}
+
+	connectCtx, done := context.WithTimeout(ctx, m.connectTimeout)
+	defer done()
+
+	conn, connClosedChan, err := mqttconf.DialV5(connectCtx, m.urls, m.tlsConf)
+	if err != nil {
+		return err
+	}
+
+	msgChan := make(chan *paho.Publish)
+	client := paho.NewClient(paho.ClientConfig{
+		ClientID: m.conf.ClientID,
+		Conn:     conn,
+		Router: paho.NewSingleHandlerRouter(func(pb *paho.Publish) {
+			select {
+			case msgChan <- pb:
+			case <-connClosedChan:
+			case <-m.interruptChan:
+			}
+		}),
+		PacketTimeout:              m.connectTimeout,
+		EnableManualAcknowledgment: true,
+		OnServerDisconnect: func(d *paho.Disconnect) {
+			reason := strconv.Itoa(int(d.ReasonCode))
+			if d.Properties != nil && d.Properties.ReasonString != "" {
+				reason = d.Properties.ReasonString
+			}
+			m.log.Errorf("Connection closed by server due to: %v\n", reason)
+		},
+		OnClientError: func(err error) {
+			m.log.Errorf("Connection lost due to: %v\n", err)
+		},
+	})
+
+	connectPacket := &paho.Connect{
+		ClientID:    m.conf.ClientID,
+		KeepAlive:   uint16(m.conf.KeepAlive),
+		CleanStart:  m.conf.CleanSession,
+		WillMessage: m.conf.Will.V5Message(),
+		Properties:  &paho.ConnectProperties{},
+	}
+	if !m.conf.CleanSession {
+		// Without a session expiry interval the session ends along with the
+		// connection, whereas a session is expected to persist.
+		sessionExpiry := uint32(math.MaxUint32)
+		connectPacket.Properties.SessionExpiryInterval = &sessionExpiry
+	}
+	if m.conf.TopicAliasMaximum > 0 {
+		topicAliasMax := uint16(m.conf.TopicAliasMaximum)
+		connectPacket.Properties.TopicAliasMaximum = &topicAliasMax
+	}
+	if m.conf.User != "" {
+		connectPacket.Username = m.conf.User
+		connectPacket.UsernameFlag = true
+	}
+	if m.conf.Password != "" {
+		connectPacket.Password = []byte(m.conf.Password)
+		connectPacket.PasswordFlag = true
+	}
+
+	if _, err = client.Connect(connectCtx, connectPacket); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	subscribePacket := &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{},
+	}
+	for _, topic := range m.conf.Topics {
+		subscribePacket.Subscriptions[topic] = paho.SubscribeOptions{QoS: m.conf.QoS}
+	}
+	if _, err = client.Subscribe(connectCtx, subscribePacket); err != nil {
+		_ = client.Disconnect(&paho.Disconnect{})
+		return fmt.Errorf("failed to subscribe to topics '%v': %w", m.conf.Topics, err)
+	}
+
+	m.log.Infof("Receiving MQTT 5 messages from topics: %v\n", m.conf.Topics)
+
+	m.client = client
+	m.msgChan = msgChan
+	m.connClosedChan = connClosedChan
+	return nil
+}
+
+// ReadWithContext attempts to read a new message from an MQTT broker.
+func (m *MQTTV5) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	m.cMut.Lock()
+	client, msgChan, connClosedChan := m.client, m.msgChan, m.connClosedChan
+	m.cMut.Unlock()
+
+	if client == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	select {
+	case <-connClosedChan:
+		m.cMut.Lock()
+		if m.client == client {
+			m.client = nil
+			m.msgChan = nil
+			m.connClosedChan = nil
+		}
+		m.cMut.Unlock()
+		return nil, nil, types.ErrNotConnected
+	case pb := <-msgChan:
+		msg := message.New([][]byte{pb.Payload})
+		mqttV5AddMetadata(msg.Get(0).Metadata(), pb)
+
+		return msg, func(ctx context.Context, res types.Response) error {
+			if res.Error() == nil {
+				// Acknowledgements are sent in the order that messages were
+				// received, therefore a message that is never acknowledged
+				// blocks the acknowledgement of all subsequent messages.
+				_ = client.Ack(pb)
+			}
+			return nil
+		}, nil
+	case <-ctx.Done():
+	case <-m.interruptChan:
+		return nil, nil, types.ErrTypeClosed
+	}
+	return nil, nil, types.ErrTimeout
+}
+
+func mqttV5AddMetadata(meta types.Metadata, pb *paho.Publish) {
+	props := pb.Properties
+	if props == nil {
+		props = &paho.PublishProperties{}
+	}
+
+	// User properties are added first so that they can't override the fields
+	// of the message.
+	for _, prop := range props.User {
+		meta.Set(prop.Key, prop.Value)
+	}
+
+	// The client doesn't expose the duplicate and retained flags of messages.
+	meta.Set("mqtt_qos", strconv.Itoa(int(pb.QoS)))
+	meta.Set("mqtt_topic", pb.Topic)
+	meta.Set("mqtt_message_id", strconv.Itoa(int(pb.PacketID)))
+
+	if props.ResponseTopic != "" {
+		meta.Set("mqtt_response_topic", props.ResponseTopic)
+	}
+	if len(props.CorrelationData) > 0 {
+		meta.Set("mqtt_correlation_data", string(props.CorrelationData))
+	}
+	if props.ContentType != "" {
+		meta.Set("mqtt_content_type", props.ContentType)
+	}
+	if props.MessageExpiry != nil {
+		meta.Set("mqtt_message_expiry", strconv.FormatUint(uint64(*props.MessageExpiry), 10))
+	}
+}
+
+// Read attempts to read a new message from an MQTT broker.
+func (m *MQTTV5) Read() (types.Message, error) {
+	msg, _, err := m.ReadWithContext(context.Background())
+	return msg, err
+}
+
+// Acknowledge instructs whether messages have been successfully propagated.
+func (m *MQTTV5) Acknowledge(err error) error {
+	return nil
+}
+
+// CloseAsync shuts down the MQTT input and stops processing requests.
+func (m *MQTTV5) CloseAsync() {
+	m.interruptOnce.Do(func() {
+		close(m.interruptChan)
+	})
+	go func() {
+		m.cMut.Lock()
+		if m.client != nil {
+			_ = m.client.Disconnect(&paho.Disconnect{})
+			m.client = nil
+		}
+		m.cMut.Unlock()
+	}()
+}
+
+// WaitForClose blocks until the MQTT input has closed down.
+func (m *MQTTV5) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,168 @@
+package reader
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readMQTTV5Packet(t *testing.T, conn net.Conn, packetType byte) *packets.ControlPacket {
+	t.Helper()
+
+	for {
+		cp, err := packets.ReadPacket(conn)
+		require.NoError(t, err)
+		if cp.Type == packets.PINGREQ {
+			continue
+		}
+		require.Equal(t, packetType, cp.Type)
+		return cp
+	}
+}
+
+func TestMQTTV5Reader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	connectChan := make(chan *packets.Connect, 1)
+	subscribeChan := make(chan *packets.Subscribe, 1)
+	ackChan := make(chan uint16, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		connectChan <- readMQTTV5Packet(t, conn, packets.CONNECT).Content.(*packets.Connect)
+		_, err = (&packets.Connack{Properties: &packets.Properties{}}).WriteTo(conn)
+		require.NoError(t, err)
+
+		sub := readMQTTV5Packet(t, conn, packets.SUBSCRIBE).Content.(*packets.Subscribe)
+		subscribeChan <- sub
+		_, err = (&packets.Suback{
+			PacketID:   sub.PacketID,
+			Reasons:    []byte{packets.SubackGrantedQoS1},
+			Properties: &packets.Properties{},
+		}).WriteTo(conn)
+		require.NoError(t, err)
+
+		alias, expiry := uint16(1), uint32(60)
+		_, err = (&packets.Publish{
+			PacketID: 1,
+			QoS:      1,
+			Topic:    "foo/bar",
+			Payload:  []byte("hello world"),
+			Properties: &packets.Properties{
+				TopicAlias:      &alias,
+				ResponseTopic:   "foo/replies",
+				CorrelationData: []byte("req1"),
+				ContentType:     "text/plain",
+				MessageExpiry:   &expiry,
+				User: []packets.User{
+					{Key: "device", Value: "d1"},
+					{Key: "mqtt_topic", Value: "not the topic"},
+				},
+			},
+		}).WriteTo(conn)
+		require.NoError(t, err)
+
+		_, err = (&packets.Publish{
+			PacketID:   2,
+			QoS:        1,
+			Payload:    []byte("hello again"),
+			Properties: &packets.Properties{TopicAlias: &alias},
+		}).WriteTo(conn)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			ackChan <- readMQTTV5Packet(t, conn, packets.PUBACK).Content.(*packets.Puback).PacketID
+		}
+		_, _ = packets.ReadPacket(conn)
+	}()
+
+	conf := NewMQTTConfig()
+	conf.URLs = []string{"tcp://" + ln.Addr().String()}
+	conf.Topics = []string{"$share/benthos/foo/#"}
+	conf.ClientID = "foo_client"
+	conf.CleanSession = false
+	conf.TopicAliasMaximum = 10
+
+	m, err := NewMQTTV5(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, m.ConnectWithContext(ctx))
+	defer func() {
+		m.CloseAsync()
+		require.NoError(t, m.WaitForClose(time.Second))
+	}()
+
+	connect := <-connectChan
+	assert.Equal(t, byte(5), connect.ProtocolVersion)
+	assert.Equal(t, "foo_client", connect.ClientID)
+	assert.False(t, connect.CleanStart)
+	require.NotNil(t, connect.Properties.SessionExpiryInterval)
+	assert.Equal(t, uint32(0xFFFFFFFF), *connect.Properties.SessionExpiryInterval)
+	require.NotNil(t, connect.Properties.TopicAliasMaximum)
+	assert.Equal(t, uint16(10), *connect.Properties.TopicAliasMaximum)
+
+	sub := <-subscribeChan
+	assert.Equal(t, map[string]packets.SubOptions{
+		"$share/benthos/foo/#": {QoS: 1},
+	}, sub.Subscriptions)
+
+	msg, ackFn, err := m.ReadWithContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(msg.Get(0).Get()))
+
+	meta := msg.Get(0).Metadata()
+	assert.Equal(t, "foo/bar", meta.Get("mqtt_topic"))
+	assert.Equal(t, "1", meta.Get("mqtt_qos"))
+	assert.Equal(t, "1", meta.Get("mqtt_message_id"))
+	assert.Equal(t, "foo/replies", meta.Get("mqtt_response_topic"))
+	assert.Equal(t, "req1", meta.Get("mqtt_correlation_data"))
+	assert.Equal(t, "text/plain", meta.Get("mqtt_content_type"))
+	assert.Equal(t, "60", meta.Get("mqtt_message_expiry"))
+	assert.Equal(t, "d1", meta.Get("device"))
+	require.NoError(t, ackFn(ctx, response.NewAck()))
+
+	msg, ackFn, err = m.ReadWithContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello again", string(msg.Get(0).Get()))
+
+	meta = msg.Get(0).Metadata()
+	assert.Equal(t, "foo/bar", meta.Get("mqtt_topic"))
+	assert.Equal(t, "", meta.Get("mqtt_response_topic"))
+	assert.Equal(t, "", meta.Get("device"))
+	require.NoError(t, ackFn(ctx, response.NewAck()))
+
+	for _, id := range []uint16{1, 2} {
+		select {
+		case ackedID := <-ackChan:
+			assert.Equal(t, id, ackedID)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for acknowledgement")
+		}
+	}
+}
+
+func TestMQTTV5ReaderBadConfig(t *testing.T) {
+	conf := NewMQTTConfig()
+	conf.TopicAliasMaximum = 70000
+
+	_, err := NewMQTTV5(conf, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "topic_alias_maximum")
+}
@@ -2,7 +2,10 @@ package reader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +36,11 @@ type RedisStreamsConfig struct {
 	CommitPeriod    string   `json:"commit_period" yaml:"commit_period"`
 	Timeout         string   `json:"timeout" yaml:"timeout"`
 
+	PendingCheckPeriod string `json:"pending_check_period" yaml:"pending_check_period"`
+	ClaimMinIdle       string `json:"claim_min_idle" yaml:"claim_min_idle"`
+	MaxDeliveryCount   int64  `json:"max_delivery_count" yaml:"max_delivery_count"`
+	DeadLetterStream   string `json:"dead_letter_stream" yaml:"dead_letter_stream"`
+
 	// TODO: V4 remove this.
 	Batching batch.PolicyConfig `json:"batching" yaml:"batching"`
 }
@@ -51,15 +59,37 @@ func NewRedisStreamsConfig() RedisStreamsConfig {
 		StartFromOldest: true,
 		CommitPeriod:    "1s",
 		Timeout:         "1s",
+
+		PendingCheckPeriod: "30s",
+		ClaimMinIdle:       "",
+		MaxDeliveryCount:   0,
+		DeadLetterStream:   "",
 	}
 }
 
 //------------------------------------------------------------------------------
 
 type pendingRedisStreamMsg struct {
-	payload types.Message
-	stream  string
-	id      string
+	payload       types.Message
+	stream        string
+	id            string
+	values        map[string]interface{}
+	deliveryCount int64
+}
+
+// redisStreamDeadLetter is an entry that is to be moved to the dead letter
+// stream, which is collected whilst holding pendingMsgsMut and sent after
+// releasing it.
+type redisStreamDeadLetter struct {
+	stream        string
+	xmsg          redis.XMessage
+	deliveryCount int64
+	reason        string
+}
+
+type redisStreamEntry struct {
+	stream string
+	id     string
 }
 
 // RedisStreams is an input type that reads Redis Streams messages.
@@ -69,8 +99,14 @@ type RedisStreams struct {
 	pendingMsgs    []pendingRedisStreamMsg
 	pendingMsgsMut sync.Mutex
 
-	timeout      time.Duration
-	commitPeriod time.Duration
+	// Entries owned by this consumer that have been read and are yet to be
+	// acknowledged, protected by pendingMsgsMut.
+	inFlight map[redisStreamEntry]struct{}
+
+	timeout            time.Duration
+	commitPeriod       time.Duration
+	pendingCheckPeriod time.Duration
+	claimMinIdle       time.Duration
 
 	conf RedisStreamsConfig
 
@@ -81,6 +117,10 @@ type RedisStreams struct {
 
 	deprecatedAckFns []AsyncAckFn
 
+	mPending      metrics.StatGaugeVec
+	mClaimed      metrics.StatCounter
+	mDeadLettered metrics.StatCounter
+
 	stats metrics.Type
 	log   log.Modular
 
@@ -98,9 +138,14 @@ func NewRedisStreams(
 		stats:      stats,
 		log:        log,
 		backlogs:   make(map[string]string, len(conf.Streams)),
+		inFlight:   map[redisStreamEntry]struct{}{},
 		ackSend:    make(map[string][]string, len(conf.Streams)),
 		closeChan:  make(chan struct{}),
 		closedChan: make(chan struct{}),
+
+		mPending:      stats.GetGaugeVec("pending_entries", []string{"stream"}),
+		mClaimed:      stats.GetCounter("claimed"),
+		mDeadLettered: stats.GetCounter("dead_lettered"),
 	}
 
 	for _, str := range conf.Streams {
@@ -125,6 +170,24 @@ func NewRedisStreams(
 		}
 	}
 
+	if tout := conf.PendingCheckPeriod; len(tout) > 0 {
+		var err error
+		if r.pendingCheckPeriod, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse pending check period string: %v", err)
+		}
+	}
+
+	if tout := conf.ClaimMinIdle; len(tout) > 0 {
+		var err error
+		if r.claimMinIdle, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse claim min idle string: %v", err)
+		}
+	}
+
+	if conf.MaxDeliveryCount > 0 && r.claimMinIdle <= 0 {
+		return nil, errors.New("a max_delivery_count can only be specified when claim_min_idle is set")
+	}
+
 	go r.loop()
 	return r, nil
 }
@@ -144,11 +207,21 @@ func (r *RedisStreams) loop() {
 		close(r.closedChan)
 	}()
 	commitTimer := time.NewTicker(r.commitPeriod)
+	defer commitTimer.Stop()
+
+	var pendingCheckChan <-chan time.Time
+	if r.pendingCheckPeriod > 0 {
+		pendingCheckTimer := time.NewTicker(r.pendingCheckPeriod)
+		defer pendingCheckTimer.Stop()
+		pendingCheckChan = pendingCheckTimer.C
+	}
 
 	closed := false
 	for !closed {
 		select {
 		case <-commitTimer.C:
+		case <-pendingCheckChan:
+			r.checkPending()
 		case <-r.closeChan:
 			closed = true
 		}
@@ -156,6 +229,234 @@ func (r *RedisStreams) loop() {
 	}
 }
 
+// checkPending updates the metrics of the pending entries list of each stream,
+// and claims entries that have been idle for longer than claimMinIdle.
+func (r *RedisStreams) checkPending() {
+	r.cMut.Lock()
+	client := r.client
+	r.cMut.Unlock()
+
+	if client == nil {
+		return
+	}
+
+	for _, str := range r.conf.Streams {
+		pending, err := client.XPending(str, r.conf.ConsumerGroup).Result()
+		if err != nil {
+			r.log.Errorf("Failed to obtain pending entries of stream %v: %v\n", str, err)
+			continue
+		}
+		r.mPending.With(str).Set(pending.Count)
+
+		if r.claimMinIdle <= 0 || pending.Count == 0 {
+			continue
+		}
+		if err := r.claimPending(client, str); err != nil {
+			r.log.Errorf("Failed to claim pending entries of stream %v: %v\n", str, err)
+		}
+	}
+}
+
+// claimPending claims up to limit entries of a stream that have been idle for
+// longer than claimMinIdle and are either owned by other consumers or owned by
+// this consumer but no longer in flight (e.g. left over from a previous run),
+// which are either consumed again or, once they exceed the max delivery count,
+// moved to the dead letter stream.
+//
+// Entries are found with XPENDING and claimed with XCLAIM rather than
+// XAUTOCLAIM, as the delivery count of each entry is required and this remains
+// compatible with Redis v5.0.
+func (r *RedisStreams) claimPending(client redis.UniversalClient, stream string) error {
+	r.pendingMsgsMut.Lock()
+	ownInFlight := map[string]struct{}{}
+	for e := range r.inFlight {
+		if e.stream == stream {
+			ownInFlight[e.id] = struct{}{}
+		}
+	}
+	r.pendingMsgsMut.Unlock()
+
+	var claimIDs []string
+	deliveryCounts := map[string]int64{}
+
+	start := "-"
+	for int64(len(claimIDs)) < r.conf.Limit {
+		entries, err := client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  r.conf.ConsumerGroup,
+			Start:  start,
+			End:    "+",
+			Count:  r.conf.Limit,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Idle < r.claimMinIdle {
+				continue
+			}
+			if _, exists := ownInFlight[e.ID]; exists && e.Consumer == r.conf.ClientID {
+				continue
+			}
+			claimIDs = append(claimIDs, e.ID)
+			deliveryCounts[e.ID] = e.RetryCount
+			if int64(len(claimIDs)) >= r.conf.Limit {
+				break
+			}
+		}
+		if int64(len(entries)) < r.conf.Limit {
+			break
+		}
+		if start, err = nextStreamID(entries[len(entries)-1].ID); err != nil {
+			return err
+		}
+	}
+	if len(claimIDs) == 0 {
+		return nil
+	}
+
+	// Claiming entries with a minimum idle time ensures that an entry is only
+	// claimed by one consumer when several are recovering entries at once.
+	claimed, err := client.XClaim(&redis.XClaimArgs{
+		Stream:   stream,
+		Group:    r.conf.ConsumerGroup,
+		Consumer: r.conf.ClientID,
+		MinIdle:  r.claimMinIdle,
+		Messages: claimIDs,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	var deadLetters []redisStreamDeadLetter
+	var nClaimed int64
+
+	r.pendingMsgsMut.Lock()
+	for _, xmsg := range claimed {
+		entry := redisStreamEntry{stream: stream, id: xmsg.ID}
+		if _, exists := r.inFlight[entry]; exists {
+			// The entry has been read from the backlog of this consumer since
+			// the pending entries were listed.
+			continue
+		}
+		// XCLAIM increments the delivery count of the entry.
+		msg, dl, ok := r.acceptEntry(stream, xmsg, deliveryCounts[xmsg.ID]+1)
+		if dl != nil {
+			deadLetters = append(deadLetters, *dl)
+		}
+		if ok {
+			r.pendingMsgs = append(r.pendingMsgs, msg)
+			nClaimed++
+		}
+	}
+	r.pendingMsgsMut.Unlock()
+
+	r.deadLetterAll(client, deadLetters)
+	if nClaimed > 0 {
+		r.mClaimed.Incr(nClaimed)
+		r.log.Debugf("Claimed %v idle pending entries of stream %v\n", nClaimed, stream)
+	}
+	return nil
+}
+
+// acceptEntry converts an entry that has been delivered to this consumer into
+// a message and marks it as in flight. Entries that exceed the max delivery
+// count are instead returned as a dead letter so that they aren't delivered
+// indefinitely, as are entries that lack the body key when a dead letter
+// stream is configured, otherwise those are skipped. Must be called whilst
+// holding pendingMsgsMut.
+func (r *RedisStreams) acceptEntry(stream string, xmsg redis.XMessage, deliveryCount int64) (pendingRedisStreamMsg, *redisStreamDeadLetter, bool) {
+	msg, ok := r.toPendingMsg(stream, xmsg)
+	if !ok {
+		if r.conf.DeadLetterStream == "" {
+			return pendingRedisStreamMsg{}, nil, false
+		}
+		return pendingRedisStreamMsg{}, &redisStreamDeadLetter{
+			stream:        stream,
+			xmsg:          xmsg,
+			deliveryCount: deliveryCount,
+			reason:        fmt.Sprintf("missing body key %v", r.conf.BodyKey),
+		}, false
+	}
+	if r.exceedsMaxDeliveries(deliveryCount) {
+		return pendingRedisStreamMsg{}, &redisStreamDeadLetter{
+			stream:        stream,
+			xmsg:          xmsg,
+			deliveryCount: deliveryCount,
+			reason:        fmt.Sprintf("exceeded %v deliveries", r.conf.MaxDeliveryCount),
+		}, false
+	}
+	msg.deliveryCount = deliveryCount
+	r.inFlight[redisStreamEntry{stream: stream, id: xmsg.ID}] = struct{}{}
+	return msg, nil, true
+}
+
+func (r *RedisStreams) exceedsMaxDeliveries(deliveryCount int64) bool {
+	return r.conf.MaxDeliveryCount > 0 && deliveryCount > r.conf.MaxDeliveryCount
+}
+
+// deadLetterAll moves entries to the dead letter stream, and must be called
+// without holding pendingMsgsMut.
+func (r *RedisStreams) deadLetterAll(client redis.UniversalClient, deadLetters []redisStreamDeadLetter) {
+	for _, dl := range deadLetters {
+		if err := r.deadLetter(client, dl); err != nil {
+			r.log.Errorf("Failed to dead letter entry %v of stream %v: %v\n", dl.xmsg.ID, dl.stream, err)
+		}
+	}
+}
+
+// deadLetter adds an entry to the dead letter stream, when configured, and
+// acknowledges it so that it is no longer delivered.
+func (r *RedisStreams) deadLetter(client redis.UniversalClient, dl redisStreamDeadLetter) error {
+	if r.conf.DeadLetterStream != "" {
+		values := make(map[string]interface{}, len(dl.xmsg.Values)+3)
+		for k, v := range dl.xmsg.Values {
+			values[k] = v
+		}
+		values["dead_letter_source_stream"] = dl.stream
+		values["dead_letter_source_id"] = dl.xmsg.ID
+		values["dead_letter_delivery_count"] = dl.deliveryCount
+		if err := client.XAdd(&redis.XAddArgs{
+			Stream: r.conf.DeadLetterStream,
+			Values: values,
+		}).Err(); err != nil {
+			return err
+		}
+	} else {
+		r.log.Warnf("Dropping entry %v of stream %v: %v\n", dl.xmsg.ID, dl.stream, dl.reason)
+	}
+	if err := client.XAck(dl.stream, r.conf.ConsumerGroup, dl.xmsg.ID).Err(); err != nil {
+		return err
+	}
+	r.mDeadLettered.Incr(1)
+	return nil
+}
+
+// nextStreamID returns the smallest stream entry ID that is greater than id,
+// allowing XPENDING ranges to be paginated without exclusive ranges, which
+// require Redis v6.2.
+func nextStreamID(id string) (string, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid stream entry id: %v", id)
+	}
+	ms, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream entry id: %v", id)
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream entry id: %v", id)
+	}
+	if seq == math.MaxUint64 {
+		ms++
+		seq = 0
+	} else {
+		seq++
+	}
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq, 10), nil
+}
+
 func (r *RedisStreams) addAsyncAcks(stream string, ids ...string) {
 	r.aMut.Lock()
 	if acks, exists := r.ackSend[stream]; exists {
@@ -189,6 +490,14 @@ func (r *RedisStreams) sendAcks() {
 		if err := r.client.XAck(str, r.conf.ConsumerGroup, ids...).Err(); err != nil {
 			r.log.Errorf("Failed to ack stream %v: %v\n", str, err)
 		}
+
+		// Entries that failed to be acknowledged are no longer tracked and
+		// therefore are claimed again once idle.
+		r.pendingMsgsMut.Lock()
+		for _, id := range ids {
+			delete(r.inFlight, redisStreamEntry{stream: str, id: id})
+		}
+		r.pendingMsgsMut.Unlock()
 	}
 }
 
@@ -250,6 +559,11 @@ func (r *RedisStreams) read() (pendingRedisStreamMsg, error) {
 		return msg, types.ErrNotConnected
 	}
 
+	var deadLetters []redisStreamDeadLetter
+	defer func() {
+		r.deadLetterAll(client, deadLetters)
+	}()
+
 	r.pendingMsgsMut.Lock()
 	defer r.pendingMsgsMut.Unlock()
 	if len(r.pendingMsgs) > 0 {
@@ -287,43 +601,33 @@ func (r *RedisStreams) read() (pendingRedisStreamMsg, error) {
 
 	pendingMsgs := []pendingRedisStreamMsg{}
 	for _, strRes := range res {
+		var deliveryCounts map[string]int64
 		if _, exists := r.backlogs[strRes.Stream]; exists {
 			if len(strRes.Messages) > 0 {
 				r.backlogs[strRes.Stream] = strRes.Messages[len(strRes.Messages)-1].ID
+				if deliveryCounts, err = r.backlogDeliveryCounts(client, strRes); err != nil {
+					r.log.Errorf("Failed to obtain delivery counts of stream %v: %v\n", strRes.Stream, err)
+				}
 			} else {
 				delete(r.backlogs, strRes.Stream)
 			}
 		}
 		for _, xmsg := range strRes.Messages {
-			body, exists := xmsg.Values[r.conf.BodyKey]
-			if !exists {
+			if _, exists := r.inFlight[redisStreamEntry{stream: strRes.Stream, id: xmsg.ID}]; exists {
+				// Already claimed from the backlog of this consumer.
 				continue
 			}
-			delete(xmsg.Values, r.conf.BodyKey)
-
-			var bodyBytes []byte
-			switch t := body.(type) {
-			case string:
-				bodyBytes = []byte(t)
-			case []byte:
-				bodyBytes = t
-			}
-			if bodyBytes == nil {
-				continue
+			deliveryCount, exists := deliveryCounts[xmsg.ID]
+			if !exists {
+				deliveryCount = 1
 			}
-
-			part := message.NewPart(bodyBytes)
-			part.Metadata().Set("redis_stream", xmsg.ID)
-			for k, v := range xmsg.Values {
-				part.Metadata().Set(k, fmt.Sprintf("%v", v))
+			nextMsg, dl, ok := r.acceptEntry(strRes.Stream, xmsg, deliveryCount)
+			if dl != nil {
+				deadLetters = append(deadLetters, *dl)
 			}
-
-			nextMsg := pendingRedisStreamMsg{
-				payload: message.New(nil),
-				stream:  strRes.Stream,
-				id:      xmsg.ID,
+			if !ok {
+				continue
 			}
-			nextMsg.payload.Append(part)
 			if msg.payload == nil {
 				msg = nextMsg
 			} else {
@@ -339,6 +643,66 @@ func (r *RedisStreams) read() (pendingRedisStreamMsg, error) {
 	return msg, nil
 }
 
+// backlogDeliveryCounts returns the delivery counts of entries read from the
+// backlog of this consumer, which includes the current delivery.
+func (r *RedisStreams) backlogDeliveryCounts(client redis.UniversalClient, strRes redis.XStream) (map[string]int64, error) {
+	if r.conf.MaxDeliveryCount <= 0 {
+		return nil, nil
+	}
+	entries, err := client.XPendingExt(&redis.XPendingExtArgs{
+		Stream:   strRes.Stream,
+		Group:    r.conf.ConsumerGroup,
+		Start:    strRes.Messages[0].ID,
+		End:      strRes.Messages[len(strRes.Messages)-1].ID,
+		Count:    int64(len(strRes.Messages)),
+		Consumer: r.conf.ClientID,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		counts[e.ID] = e.RetryCount
+	}
+	return counts, nil
+}
+
+func (r *RedisStreams) toPendingMsg(stream string, xmsg redis.XMessage) (pendingRedisStreamMsg, bool) {
+	body, exists := xmsg.Values[r.conf.BodyKey]
+	if !exists {
+		return pendingRedisStreamMsg{}, false
+	}
+
+	var bodyBytes []byte
+	switch t := body.(type) {
+	case string:
+		bodyBytes = []byte(t)
+	case []byte:
+		bodyBytes = t
+	}
+	if bodyBytes == nil {
+		return pendingRedisStreamMsg{}, false
+	}
+
+	part := message.NewPart(bodyBytes)
+	part.Metadata().Set("redis_stream", xmsg.ID)
+	for k, v := range xmsg.Values {
+		if k == r.conf.BodyKey {
+			continue
+		}
+		part.Metadata().Set(k, fmt.Sprintf("%v", v))
+	}
+
+	msg := pendingRedisStreamMsg{
+		payload: message.New(nil),
+		stream:  stream,
+		id:      xmsg.ID,
+		values:  xmsg.Values,
+	}
+	msg.payload.Append(part)
+	return msg, true
+}
+
 // ReadWithContext attempts to pop a message from a Redis list.
 func (r *RedisStreams) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
 	msg, err := r.read()
@@ -356,13 +720,39 @@ func (r *RedisStreams) ReadWithContext(ctx context.Context) (types.Message, Asyn
 		}
 	}
 	return msg.payload, func(rctx context.Context, res types.Response) error {
-		if res.Error() != nil {
+		if res.Error() == nil {
+			r.addAsyncAcks(msg.stream, msg.id)
+			return nil
+		}
+
+		// Entries that are consumed again locally aren't redelivered by Redis
+		// and so their delivery count is tracked here instead.
+		msg.deliveryCount++
+		if !r.exceedsMaxDeliveries(msg.deliveryCount) {
 			r.pendingMsgsMut.Lock()
 			r.pendingMsgs = append(r.pendingMsgs, msg)
 			r.pendingMsgsMut.Unlock()
-		} else {
-			r.addAsyncAcks(msg.stream, msg.id)
+			return nil
 		}
+
+		r.cMut.Lock()
+		client := r.client
+		r.cMut.Unlock()
+		if client == nil {
+			// The entry remains pending and is claimed again once idle.
+			return nil
+		}
+
+		r.deadLetterAll(client, []redisStreamDeadLetter{{
+			stream:        msg.stream,
+			xmsg:          redis.XMessage{ID: msg.id, Values: msg.values},
+			deliveryCount: msg.deliveryCount,
+			reason:        fmt.Sprintf("exceeded %v deliveries", r.conf.MaxDeliveryCount),
+		}})
+
+		r.pendingMsgsMut.Lock()
+		delete(r.inFlight, redisStreamEntry{stream: msg.stream, id: msg.id})
+		r.pendingMsgsMut.Unlock()
 		return nil
 	}, nil
 }
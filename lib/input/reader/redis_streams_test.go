@@ -0,0 +1,35 @@
+package reader
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStreamsNextStreamID(t *testing.T) {
+	for input, exp := range map[string]string{
+		"0-0":                                "0-1",
+		"1526919030474-55":                   "1526919030474-56",
+		"1526919030474-18446744073709551615": "1526919030475-0",
+	} {
+		act, err := nextStreamID(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, exp, act, input)
+	}
+
+	for _, input := range []string{"", "123", "foo-1", "1-bar"} {
+		_, err := nextStreamID(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestRedisStreamsBadClaimConfig(t *testing.T) {
+	conf := NewRedisStreamsConfig()
+	conf.MaxDeliveryCount = 3
+
+	_, err := NewRedisStreams(conf, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+}
@@ -21,7 +21,30 @@ Pulls messages from Redis (v5.0+) streams with the XREADGROUP command. The
 		Description: `
 Redis stream entries are key/value pairs, as such it is necessary to specify the
 key that contains the body of the message. All other keys/value pairs are saved
-as metadata fields.`,
+as metadata fields.
+
+### Pending Entries
+
+Entries that are consumed but never acknowledged, for example because the
+consumer that read them crashed, remain within the pending entries list of the
+consumer group. By setting ` + "`claim_min_idle`" + ` this input periodically claims
+pending entries that have been idle for at least that duration, and consumes
+them again. This includes entries of this consumer that are no longer in flight,
+such as those left over from a previous run with the same ` + "`client_id`" + `.
+
+Entries that have already been delivered ` + "`max_delivery_count`" + ` times are
+not consumed again, whether they are claimed, read from the backlog of this
+consumer on start up or rejected by the pipeline, and are instead added to the
+` + "`dead_letter_stream`" + ` (when specified) and acknowledged. Entries that lack
+the ` + "`body_key`" + ` are added to the ` + "`dead_letter_stream`" + ` and
+acknowledged regardless of the delivery count when it is specified, otherwise
+they are skipped and remain pending. Entries added to the dead letter stream
+contain the original key/value pairs as well as the keys
+` + "`dead_letter_source_stream`" + `, ` + "`dead_letter_source_id`" + ` and
+` + "`dead_letter_delivery_count`" + `.
+
+The size of the pending entries list of each stream is emitted as the gauge
+` + "`pending_entries`" + ` with the label ` + "`stream`" + `.`,
 		FieldSpecs: redis.ConfigDocs().Add(
 			func() docs.FieldSpec {
 				b := batch.FieldSpec()
@@ -37,6 +60,10 @@ as metadata fields.`,
 			docs.FieldAdvanced("start_from_oldest", "If an offset is not found for a stream, determines whether to consume from the oldest available offset, otherwise messages are consumed from the latest offset."),
 			docs.FieldAdvanced("commit_period", "The period of time between each commit of the current offset. Offsets are always committed during shutdown."),
 			docs.FieldAdvanced("timeout", "The length of time to poll for new messages before reattempting."),
+			docs.FieldAdvanced("pending_check_period", "The period of time between each check of the pending entries list of each stream, where metrics are updated and idle entries are claimed. An empty string disables the check.").AtVersion("3.65.0"),
+			docs.FieldAdvanced("claim_min_idle", "The minimum length of time that pending entries of other consumers must be idle before they are claimed and consumed again. An empty string disables claiming entries.", "5m").AtVersion("3.65.0"),
+			docs.FieldAdvanced("max_delivery_count", "The maximum number of times that an entry is delivered before it is moved to the `dead_letter_stream` instead of being consumed again. Zero disables the limit. Requires `claim_min_idle` to be set.").AtVersion("3.65.0"),
+			docs.FieldAdvanced("dead_letter_stream", "A stream to add entries to once they exceed the `max_delivery_count` or when they lack the `body_key`. When empty entries that exceed the `max_delivery_count` are dropped and entries that lack the `body_key` are skipped.").AtVersion("3.65.0"),
 		),
 		Categories: []Category{
 			CategoryServices,
@@ -35,12 +35,16 @@ func (f *fakeInput) WaitForClose(time.Duration) error {
 //------------------------------------------------------------------------------
 
 type fakeProcMgr struct {
-	ins map[string]types.Input
+	ins    map[string]types.Input
+	caches map[string]types.Cache
 }
 
 func (f *fakeProcMgr) RegisterEndpoint(path, desc string, h http.HandlerFunc) {
 }
 func (f *fakeProcMgr) GetCache(name string) (types.Cache, error) {
+	if c, exists := f.caches[name]; exists {
+		return c, nil
+	}
 	return nil, types.ErrCacheNotFound
 }
 func (f *fakeProcMgr) GetCondition(name string) (types.Condition, error) {
@@ -66,6 +66,10 @@ type Type struct {
 	plugins      map[string]interface{}
 	resourceLock *sync.RWMutex
 
+	// Resources that are currently being initialized by a store call, each
+	// pending channel is closed once the store has finished.
+	pending map[resourceKey]chan struct{}
+
 	// Collections of component constructors
 	env      *bundle.Environment
 	bloblEnv *bloblang.Environment
@@ -119,6 +123,8 @@ func NewV2(conf ResourceConfig, apiReg APIReg, log log.Modular, stats metrics.Ty
 		plugins:      map[string]interface{}{},
 		resourceLock: &sync.RWMutex{},
 
+		pending: map[resourceKey]chan struct{}{},
+
 		// Environment defaults to global (everything that was imported).
 		env:      bundle.GlobalEnvironment,
 		bloblEnv: bloblang.GlobalEnvironment(),
@@ -235,6 +241,196 @@ func NewV2(conf ResourceConfig, apiReg APIReg, log log.Modular, stats metrics.Ty
 	return t, nil
 }
 
+// StoreNewResources attempts to store each resource of a config that does not
+// already exist, resources with a name that is already taken (or reserved by a
+// resource being initialized) are left untouched. The existence check and
+// reservation of names happens atomically, and resources are initialized
+// outside of the resource lock, which allows this method to be called by
+// components that are themselves being initialized as a resource.
+func (t *Type) StoreNewResources(ctx context.Context, conf ResourceConfig) error {
+	conf, err := conf.collapsed()
+	if err != nil {
+		return err
+	}
+
+	t.resourceLock.Lock()
+	for k := range conf.Manager.Caches {
+		_, exists := t.caches[k]
+		if !t.reserveNewLocked(docs.TypeCache, k, exists) {
+			delete(conf.Manager.Caches, k)
+		}
+	}
+	for k := range conf.Manager.RateLimits {
+		_, exists := t.rateLimits[k]
+		if !t.reserveNewLocked(docs.TypeRateLimit, k, exists) {
+			delete(conf.Manager.RateLimits, k)
+		}
+	}
+	for k := range conf.Manager.Processors {
+		_, exists := t.processors[k]
+		if !t.reserveNewLocked(docs.TypeProcessor, k, exists) {
+			delete(conf.Manager.Processors, k)
+		}
+	}
+	for k := range conf.Manager.Inputs {
+		_, exists := t.inputs[k]
+		if !t.reserveNewLocked(docs.TypeInput, k, exists) {
+			delete(conf.Manager.Inputs, k)
+		}
+	}
+	for k := range conf.Manager.Outputs {
+		_, exists := t.outputs[k]
+		if !t.reserveNewLocked(docs.TypeOutput, k, exists) {
+			delete(conf.Manager.Outputs, k)
+		}
+	}
+	t.resourceLock.Unlock()
+
+	// Once a store fails the remaining reservations are released without
+	// initializing their resources.
+	for k, conf := range conf.Manager.RateLimits {
+		if err != nil {
+			t.release(docs.TypeRateLimit, k)
+			continue
+		}
+		err = t.storeReservedRateLimit(k, conf)
+	}
+	for k, conf := range conf.Manager.Caches {
+		if err != nil {
+			t.release(docs.TypeCache, k)
+			continue
+		}
+		err = t.storeReservedCache(k, conf)
+	}
+	for k, conf := range conf.Manager.Processors {
+		if err != nil {
+			t.release(docs.TypeProcessor, k)
+			continue
+		}
+		err = t.storeReservedProcessor(k, conf)
+	}
+	for k, conf := range conf.Manager.Inputs {
+		if err != nil {
+			t.release(docs.TypeInput, k)
+			continue
+		}
+		err = t.storeReservedInput(k, conf)
+	}
+	for k, conf := range conf.Manager.Outputs {
+		if err != nil {
+			t.release(docs.TypeOutput, k)
+			continue
+		}
+		err = t.storeReservedOutput(k, conf)
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+type resourceKey struct {
+	kind docs.Type
+	name string
+}
+
+// reserveNewLocked reserves the name of a resource that is about to be
+// initialized unless it already exists or is pending. Returns true if the
+// reservation was made. The resource lock must be held by the caller.
+func (t *Type) reserveNewLocked(kind docs.Type, name string, exists bool) bool {
+	key := resourceKey{kind: kind, name: name}
+	if _, isPending := t.pending[key]; exists || isPending {
+		return false
+	}
+	t.pending[key] = make(chan struct{})
+	return true
+}
+
+// reserve blocks until any pending store of a resource has finished and then
+// reserves its name, calling prepare with the resource lock held in order to
+// remove an existing resource of the same name. The name is not reserved if
+// prepare returns an error.
+func (t *Type) reserve(ctx context.Context, kind docs.Type, name string, prepare func() error) error {
+	key := resourceKey{kind: kind, name: name}
+	for {
+		t.resourceLock.Lock()
+		pending, isPending := t.pending[key]
+		if !isPending {
+			break
+		}
+		t.resourceLock.Unlock()
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer t.resourceLock.Unlock()
+	if err := prepare(); err != nil {
+		return err
+	}
+	t.pending[key] = make(chan struct{})
+	return nil
+}
+
+// releaseLocked removes the reservation of a resource name and wakes any
+// components waiting to access it. The resource lock must be held by the
+// caller.
+func (t *Type) releaseLocked(kind docs.Type, name string) {
+	key := resourceKey{kind: kind, name: name}
+	if pending, exists := t.pending[key]; exists {
+		close(pending)
+		delete(t.pending, key)
+	}
+}
+
+func (t *Type) release(kind docs.Type, name string) {
+	t.resourceLock.Lock()
+	t.releaseLocked(kind, name)
+	t.resourceLock.Unlock()
+}
+
+// access calls fn with the resource lock held, where fn returns false if the
+// resource does not exist. When the resource is pending then the access waits
+// until its store has finished, or the context is cancelled.
+func (t *Type) access(ctx context.Context, kind docs.Type, name string, fn func() bool) error {
+	key := resourceKey{kind: kind, name: name}
+	for {
+		found, pending := func() (bool, chan struct{}) {
+			t.resourceLock.RLock()
+			defer t.resourceLock.RUnlock()
+			if fn() {
+				return true, nil
+			}
+			return false, t.pending[key]
+		}()
+		if found {
+			return nil
+		}
+		if pending == nil {
+			return ErrResourceNotFound(name)
+		}
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (t *Type) isPending(kind docs.Type, name string) bool {
+	t.resourceLock.RLock()
+	_, exists := t.pending[resourceKey{kind: kind, name: name}]
+	t.resourceLock.RUnlock()
+	return exists
+}
+
+func checkResourceLabel(label, name string) error {
+	if label != "" && label != name {
+		return fmt.Errorf("label '%v' must be empty or match the resource name '%v'", label, name)
+	}
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 // ForStream returns a variant of this manager to be used by a particular stream
@@ -412,20 +608,21 @@ func (t *Type) NewBuffer(conf buffer.Config) (buffer.Type, error) {
 // executes a closure function with the cache as an argument. Returns an error
 // if the cache does not exist (or is otherwise inaccessible).
 //
+// If the resource is currently being stored then this call blocks until it is
+// ready, or until the context is cancelled.
+//
 // During the execution of the provided closure it is guaranteed that the
 // resource will not be closed or removed. However, it is possible for the
 // resource to be accessed by any number of components in parallel.
 func (t *Type) AccessCache(ctx context.Context, name string, fn func(types.Cache)) error {
-	// TODO: Eventually use ctx to cancel blocking on the mutex lock. Needs
-	// profiling for heavy use within a busy loop.
-	t.resourceLock.RLock()
-	defer t.resourceLock.RUnlock()
-	c, ok := t.caches[name]
-	if !ok || c == nil {
-		return ErrResourceNotFound(name)
-	}
-	fn(c)
-	return nil
+	return t.access(ctx, docs.TypeCache, name, func() bool {
+		c := t.caches[name]
+		if c == nil {
+			return false
+		}
+		fn(c)
+		return true
+	})
 }
 
 // NewCache attempts to create a new cache component from a config.
@@ -442,19 +639,54 @@ func (t *Type) NewCache(conf cache.Config) (types.Cache, error) {
 // has the same name it is closed and removed _before_ the new one is
 // initialized in order to avoid duplicate connections.
 func (t *Type) StoreCache(ctx context.Context, name string, conf cache.Config) error {
+	if err := t.reserve(ctx, docs.TypeCache, name, func() error {
+		if c := t.caches[name]; c != nil {
+			// If a previous resource exists with the same name then we do NOT
+			// allow it to be replaced unless it can be successfully closed.
+			// This ensures that we do not leak connections.
+			if err := closeWithContext(ctx, c); err != nil {
+				return err
+			}
+		}
+		delete(t.caches, name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return t.storeReservedCache(name, conf)
+}
+
+// storeReservedCache initializes a cache resource outside of the resource lock,
+// as its constructor might access or store other resources, and installs it
+// under a name that has already been reserved.
+func (t *Type) storeReservedCache(name string, conf cache.Config) error {
+	newCache, err := t.forComponent("resource.cache." + name).NewCache(conf)
+
 	t.resourceLock.Lock()
 	defer t.resourceLock.Unlock()
 
-	c, ok := t.caches[name]
-	if ok && c != nil {
-		// If a previous resource exists with the same name then we do NOT allow
-		// it to be replaced unless it can be successfully closed. This ensures
-		// that we do not leak connections.
-		if err := closeWithContext(ctx, c); err != nil {
-			return err
-		}
+	t.releaseLocked(docs.TypeCache, name)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create cache resource '%v' of type '%v': %w",
+			name, conf.Type, err,
+		)
+	}
+	if c := t.caches[name]; c != nil {
+		// A swap of the same name finished first.
+		c.CloseAsync()
 	}
+	t.caches[name] = newCache
+	return nil
+}
 
+// SwapCache attempts to replace a cache resource with a new one under the same
+// name. Unlike StoreCache the new cache is initialized _before_ the existing
+// resource is removed, and the existing resource remains accessible until the
+// swap. Once all in-flight accesses of the existing resource have finished it
+// is replaced and then closed. If the new cache fails to initialize then the
+// existing resource remains in place.
+func (t *Type) SwapCache(ctx context.Context, name string, conf cache.Config) error {
 	newCache, err := t.forComponent("resource.cache." + name).NewCache(conf)
 	if err != nil {
 		return fmt.Errorf(
@@ -463,7 +695,16 @@ func (t *Type) StoreCache(ctx context.Context, name string, conf cache.Config) e
 		)
 	}
 
+	t.resourceLock.Lock()
+	c := t.caches[name]
 	t.caches[name] = newCache
+	t.resourceLock.Unlock()
+
+	if c != nil {
+		if err := closeWithContext(ctx, c); err != nil {
+			return fmt.Errorf("failed to close replaced cache resource '%v': %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -473,20 +714,21 @@ func (t *Type) StoreCache(ctx context.Context, name string, conf cache.Config) e
 // executes a closure function with the input as an argument. Returns an error
 // if the input does not exist (or is otherwise inaccessible).
 //
+// If the resource is currently being stored then this call blocks until it is
+// ready, or until the context is cancelled.
+//
 // During the execution of the provided closure it is guaranteed that the
 // resource will not be closed or removed. However, it is possible for the
 // resource to be accessed by any number of components in parallel.
 func (t *Type) AccessInput(ctx context.Context, name string, fn func(types.Input)) error {
-	// TODO: Eventually use ctx to cancel blocking on the mutex lock. Needs
-	// profiling for heavy use within a busy loop.
-	t.resourceLock.RLock()
-	defer t.resourceLock.RUnlock()
-	i, ok := t.inputs[name]
-	if !ok || i == nil {
-		return ErrResourceNotFound(name)
-	}
-	fn(i)
-	return nil
+	return t.access(ctx, docs.TypeInput, name, func() bool {
+		i := t.inputs[name]
+		if i == nil {
+			return false
+		}
+		fn(i)
+		return true
+	})
 }
 
 // NewInput attempts to create a new input component from a config.
@@ -505,31 +747,51 @@ func (t *Type) NewInput(conf input.Config, hasBatchProc bool, pipelines ...types
 // has the same name it is closed and removed _before_ the new one is
 // initialized in order to avoid duplicate connections.
 func (t *Type) StoreInput(ctx context.Context, name string, conf input.Config) error {
-	t.resourceLock.Lock()
-	defer t.resourceLock.Unlock()
+	if err := checkResourceLabel(conf.Label, name); err != nil {
+		return err
+	}
 
-	i, ok := t.inputs[name]
-	if ok && i != nil {
-		// If a previous resource exists with the same name then we do NOT allow
-		// it to be replaced unless it can be successfully closed. This ensures
-		// that we do not leak connections.
-		if err := closeWithContext(ctx, i); err != nil {
-			return err
+	if err := t.reserve(ctx, docs.TypeInput, name, func() error {
+		if i := t.inputs[name]; i != nil {
+			// If a previous resource exists with the same name then we do NOT
+			// allow it to be replaced unless it can be successfully closed.
+			// This ensures that we do not leak connections.
+			if err := closeWithContext(ctx, i); err != nil {
+				return err
+			}
 		}
+		delete(t.inputs, name)
+		return nil
+	}); err != nil {
+		return err
 	}
+	return t.storeReservedInput(name, conf)
+}
 
-	if conf.Label != "" && conf.Label != name {
-		return fmt.Errorf("label '%v' must be empty or match the resource name '%v'", conf.Label, name)
+// storeReservedInput initializes an input resource outside of the resource lock,
+// as its constructor might access or store other resources, and installs it
+// under a name that has already been reserved.
+func (t *Type) storeReservedInput(name string, conf input.Config) error {
+	var newInput types.Input
+	err := checkResourceLabel(conf.Label, name)
+	if err == nil {
+		newInput, err = t.forComponent("resource.input."+name).NewInput(conf, false)
 	}
 
-	newInput, err := t.forComponent("resource.input."+name).NewInput(conf, false)
+	t.resourceLock.Lock()
+	defer t.resourceLock.Unlock()
+
+	t.releaseLocked(docs.TypeInput, name)
 	if err != nil {
 		return fmt.Errorf(
 			"failed to create input resource '%v' of type '%v': %w",
 			name, conf.Type, err,
 		)
 	}
-
+	if i := t.inputs[name]; i != nil {
+		// A swap of the same name finished first.
+		i.CloseAsync()
+	}
 	t.inputs[name] = newInput
 	return nil
 }
@@ -541,20 +803,21 @@ func (t *Type) StoreInput(ctx context.Context, name string, conf input.Config) e
 // Returns an error if the processor does not exist (or is otherwise
 // inaccessible).
 //
+// If the resource is currently being stored then this call blocks until it is
+// ready, or until the context is cancelled.
+//
 // During the execution of the provided closure it is guaranteed that the
 // resource will not be closed or removed. However, it is possible for the
 // resource to be accessed by any number of components in parallel.
 func (t *Type) AccessProcessor(ctx context.Context, name string, fn func(types.Processor)) error {
-	// TODO: Eventually use ctx to cancel blocking on the mutex lock. Needs
-	// profiling for heavy use within a busy loop.
-	t.resourceLock.RLock()
-	defer t.resourceLock.RUnlock()
-	p, ok := t.processors[name]
-	if !ok || p == nil {
-		return ErrResourceNotFound(name)
-	}
-	fn(p)
-	return nil
+	return t.access(ctx, docs.TypeProcessor, name, func() bool {
+		p := t.processors[name]
+		if p == nil {
+			return false
+		}
+		fn(p)
+		return true
+	})
 }
 
 // NewProcessor attempts to create a new processor component from a config.
@@ -571,21 +834,64 @@ func (t *Type) NewProcessor(conf processor.Config) (types.Processor, error) {
 // resource has the same name it is closed and removed _before_ the new one is
 // initialized in order to avoid duplicate connections.
 func (t *Type) StoreProcessor(ctx context.Context, name string, conf processor.Config) error {
+	if err := checkResourceLabel(conf.Label, name); err != nil {
+		return err
+	}
+
+	if err := t.reserve(ctx, docs.TypeProcessor, name, func() error {
+		if p := t.processors[name]; p != nil {
+			// If a previous resource exists with the same name then we do NOT
+			// allow it to be replaced unless it can be successfully closed.
+			// This ensures that we do not leak connections.
+			if err := closeWithContext(ctx, p); err != nil {
+				return err
+			}
+		}
+		delete(t.processors, name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return t.storeReservedProcessor(name, conf)
+}
+
+// storeReservedProcessor initializes a processor resource outside of the
+// resource lock, as its constructor might access or store other resources, and
+// installs it under a name that has already been reserved.
+func (t *Type) storeReservedProcessor(name string, conf processor.Config) error {
+	var newProcessor types.Processor
+	err := checkResourceLabel(conf.Label, name)
+	if err == nil {
+		newProcessor, err = t.forComponent("resource.processor." + name).NewProcessor(conf)
+	}
+
 	t.resourceLock.Lock()
 	defer t.resourceLock.Unlock()
 
-	p, ok := t.processors[name]
-	if ok && p != nil {
-		// If a previous resource exists with the same name then we do NOT allow
-		// it to be replaced unless it can be successfully closed. This ensures
-		// that we do not leak connections.
-		if err := closeWithContext(ctx, p); err != nil {
-			return err
-		}
+	t.releaseLocked(docs.TypeProcessor, name)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create processor resource '%v' of type '%v': %w",
+			name, conf.Type, err,
+		)
 	}
+	if p := t.processors[name]; p != nil {
+		// A swap of the same name finished first.
+		p.CloseAsync()
+	}
+	t.processors[name] = newProcessor
+	return nil
+}
 
-	if conf.Label != "" && conf.Label != name {
-		return fmt.Errorf("label '%v' must be empty or match the resource name '%v'", conf.Label, name)
+// SwapProcessor attempts to replace a processor resource with a new one under
+// the same name. Unlike StoreProcessor the new processor is initialized
+// _before_ the existing resource is removed, and the existing resource remains
+// accessible until the swap. Once all in-flight accesses of the existing
+// resource have finished it is replaced and then closed. If the new processor
+// fails to initialize then the existing resource remains in place.
+func (t *Type) SwapProcessor(ctx context.Context, name string, conf processor.Config) error {
+	if err := checkResourceLabel(conf.Label, name); err != nil {
+		return err
 	}
 
 	newProcessor, err := t.forComponent("resource.processor." + name).NewProcessor(conf)
@@ -596,7 +902,16 @@ func (t *Type) StoreProcessor(ctx context.Context, name string, conf processor.C
 		)
 	}
 
+	t.resourceLock.Lock()
+	p := t.processors[name]
 	t.processors[name] = newProcessor
+	t.resourceLock.Unlock()
+
+	if p != nil {
+		if err := closeWithContext(ctx, p); err != nil {
+			return fmt.Errorf("failed to close replaced processor resource '%v': %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -606,20 +921,21 @@ func (t *Type) StoreProcessor(ctx context.Context, name string, conf processor.C
 // executes a closure function with the output as an argument. Returns an error
 // if the output does not exist (or is otherwise inaccessible).
 //
+// If the resource is currently being stored then this call blocks until it is
+// ready, or until the context is cancelled.
+//
 // During the execution of the provided closure it is guaranteed that the
 // resource will not be closed or removed. However, it is possible for the
 // resource to be accessed by any number of components in parallel.
 func (t *Type) AccessOutput(ctx context.Context, name string, fn func(types.OutputWriter)) error {
-	// TODO: Eventually use ctx to cancel blocking on the mutex lock. Needs
-	// profiling for heavy use within a busy loop.
-	t.resourceLock.RLock()
-	defer t.resourceLock.RUnlock()
-	o, ok := t.outputs[name]
-	if !ok || o == nil {
-		return ErrResourceNotFound(name)
-	}
-	fn(o)
-	return nil
+	return t.access(ctx, docs.TypeOutput, name, func() bool {
+		o := t.outputs[name]
+		if o == nil {
+			return false
+		}
+		fn(o)
+		return true
+	})
 }
 
 // NewOutput attempts to create a new output component from a config.
@@ -636,35 +952,57 @@ func (t *Type) NewOutput(conf output.Config, pipelines ...types.PipelineConstruc
 // has the same name it is closed and removed _before_ the new one is
 // initialized in order to avoid duplicate connections.
 func (t *Type) StoreOutput(ctx context.Context, name string, conf output.Config) error {
-	t.resourceLock.Lock()
-	defer t.resourceLock.Unlock()
-
-	o, ok := t.outputs[name]
-	if ok && o != nil {
-		// If a previous resource exists with the same name then we do NOT allow
-		// it to be replaced unless it can be successfully closed. This ensures
-		// that we do not leak connections.
-		if err := closeWithContext(ctx, o); err != nil {
-			return err
-		}
+	if err := checkResourceLabel(conf.Label, name); err != nil {
+		return err
 	}
 
-	if conf.Label != "" && conf.Label != name {
-		return fmt.Errorf("label '%v' must be empty or match the resource name '%v'", conf.Label, name)
+	if err := t.reserve(ctx, docs.TypeOutput, name, func() error {
+		if o := t.outputs[name]; o != nil {
+			// If a previous resource exists with the same name then we do NOT
+			// allow it to be replaced unless it can be successfully closed.
+			// This ensures that we do not leak connections.
+			if err := closeWithContext(ctx, o); err != nil {
+				return err
+			}
+		}
+		delete(t.outputs, name)
+		return nil
+	}); err != nil {
+		return err
 	}
+	return t.storeReservedOutput(name, conf)
+}
 
-	tmpOutput, err := t.forComponent("resource.output." + name).NewOutput(conf)
+// storeReservedOutput initializes an output resource outside of the resource
+// lock, as its constructor might access or store other resources, and installs
+// it under a name that has already been reserved.
+func (t *Type) storeReservedOutput(name string, conf output.Config) error {
+	var newOutput *outputWrapper
+	err := checkResourceLabel(conf.Label, name)
 	if err == nil {
-		if t.outputs[name], err = wrapOutput(tmpOutput); err != nil {
-			tmpOutput.CloseAsync()
+		var tmpOutput types.Output
+		if tmpOutput, err = t.forComponent("resource.output." + name).NewOutput(conf); err == nil {
+			if newOutput, err = wrapOutput(tmpOutput); err != nil {
+				tmpOutput.CloseAsync()
+			}
 		}
 	}
+
+	t.resourceLock.Lock()
+	defer t.resourceLock.Unlock()
+
+	t.releaseLocked(docs.TypeOutput, name)
 	if err != nil {
 		return fmt.Errorf(
 			"failed to create output resource '%v' of type '%v': %w",
 			name, conf.Type, err,
 		)
 	}
+	if o := t.outputs[name]; o != nil {
+		// A swap of the same name finished first.
+		o.CloseAsync()
+	}
+	t.outputs[name] = newOutput
 	return nil
 }
 
@@ -675,20 +1013,21 @@ func (t *Type) StoreOutput(ctx context.Context, name string, conf output.Config)
 // argument. Returns an error if the rate limit does not exist (or is otherwise
 // inaccessible).
 //
+// If the resource is currently being stored then this call blocks until it is
+// ready, or until the context is cancelled.
+//
 // During the execution of the provided closure it is guaranteed that the
 // resource will not be closed or removed. However, it is possible for the
 // resource to be accessed by any number of components in parallel.
 func (t *Type) AccessRateLimit(ctx context.Context, name string, fn func(types.RateLimit)) error {
-	// TODO: Eventually use ctx to cancel blocking on the mutex lock. Needs
-	// profiling for heavy use within a busy loop.
-	t.resourceLock.RLock()
-	defer t.resourceLock.RUnlock()
-	r, ok := t.rateLimits[name]
-	if !ok || r == nil {
-		return ErrResourceNotFound(name)
-	}
-	fn(r)
-	return nil
+	return t.access(ctx, docs.TypeRateLimit, name, func() bool {
+		r := t.rateLimits[name]
+		if r == nil {
+			return false
+		}
+		fn(r)
+		return true
+	})
 }
 
 // NewRateLimit attempts to create a new rate limit component from a config.
@@ -705,19 +1044,54 @@ func (t *Type) NewRateLimit(conf ratelimit.Config) (types.RateLimit, error) {
 // resource has the same name it is closed and removed _before_ the new one is
 // initialized in order to avoid duplicate connections.
 func (t *Type) StoreRateLimit(ctx context.Context, name string, conf ratelimit.Config) error {
+	if err := t.reserve(ctx, docs.TypeRateLimit, name, func() error {
+		if r := t.rateLimits[name]; r != nil {
+			// If a previous resource exists with the same name then we do NOT
+			// allow it to be replaced unless it can be successfully closed.
+			// This ensures that we do not leak connections.
+			if err := closeWithContext(ctx, r); err != nil {
+				return err
+			}
+		}
+		delete(t.rateLimits, name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return t.storeReservedRateLimit(name, conf)
+}
+
+// storeReservedRateLimit initializes a rate limit resource outside of the
+// resource lock, as its constructor might access or store other resources, and
+// installs it under a name that has already been reserved.
+func (t *Type) storeReservedRateLimit(name string, conf ratelimit.Config) error {
+	newRateLimit, err := t.forComponent("resource.rate_limit." + name).NewRateLimit(conf)
+
 	t.resourceLock.Lock()
 	defer t.resourceLock.Unlock()
 
-	r, ok := t.rateLimits[name]
-	if ok && r != nil {
-		// If a previous resource exists with the same name then we do NOT allow
-		// it to be replaced unless it can be successfully closed. This ensures
-		// that we do not leak connections.
-		if err := closeWithContext(ctx, r); err != nil {
-			return err
-		}
+	t.releaseLocked(docs.TypeRateLimit, name)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create rate limit resource '%v' of type '%v': %w",
+			name, conf.Type, err,
+		)
+	}
+	if r := t.rateLimits[name]; r != nil {
+		// A swap of the same name finished first.
+		r.CloseAsync()
 	}
+	t.rateLimits[name] = newRateLimit
+	return nil
+}
 
+// SwapRateLimit attempts to replace a rate limit resource with a new one under
+// the same name. Unlike StoreRateLimit the new rate limit is initialized
+// _before_ the existing resource is removed, and the existing resource remains
+// accessible until the swap. Once all in-flight accesses of the existing
+// resource have finished it is replaced and then closed. If the new rate limit
+// fails to initialize then the existing resource remains in place.
+func (t *Type) SwapRateLimit(ctx context.Context, name string, conf ratelimit.Config) error {
 	newRateLimit, err := t.forComponent("resource.rate_limit." + name).NewRateLimit(conf)
 	if err != nil {
 		return fmt.Errorf(
@@ -726,7 +1100,16 @@ func (t *Type) StoreRateLimit(ctx context.Context, name string, conf ratelimit.C
 		)
 	}
 
+	t.resourceLock.Lock()
+	r := t.rateLimits[name]
 	t.rateLimits[name] = newRateLimit
+	t.resourceLock.Unlock()
+
+	if r != nil {
+		if err := closeWithContext(ctx, r); err != nil {
+			return fmt.Errorf("failed to close replaced rate limit resource '%v': %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -834,6 +1217,9 @@ func (t *Type) GetInput(name string) (types.Input, error) {
 	if c, exists := t.inputs[name]; exists {
 		return c, nil
 	}
+	if t.isPending(docs.TypeInput, name) {
+		return nil, nil
+	}
 	return nil, types.ErrInputNotFound
 }
 
@@ -842,6 +1228,9 @@ func (t *Type) GetCache(name string) (types.Cache, error) {
 	if c, exists := t.caches[name]; exists {
 		return c, nil
 	}
+	if t.isPending(docs.TypeCache, name) {
+		return nil, nil
+	}
 	return nil, types.ErrCacheNotFound
 }
 
@@ -858,6 +1247,9 @@ func (t *Type) GetProcessor(name string) (types.Processor, error) {
 	if p, exists := t.processors[name]; exists {
 		return p, nil
 	}
+	if t.isPending(docs.TypeProcessor, name) {
+		return nil, nil
+	}
 	return nil, types.ErrProcessorNotFound
 }
 
@@ -866,6 +1258,9 @@ func (t *Type) GetRateLimit(name string) (types.RateLimit, error) {
 	if rl, exists := t.rateLimits[name]; exists {
 		return rl, nil
 	}
+	if t.isPending(docs.TypeRateLimit, name) {
+		return nil, nil
+	}
 	return nil, types.ErrRateLimitNotFound
 }
 
@@ -874,6 +1269,9 @@ func (t *Type) GetOutput(name string) (types.OutputWriter, error) {
 	if c, exists := t.outputs[name]; exists {
 		return c, nil
 	}
+	if t.isPending(docs.TypeOutput, name) {
+		return nil, nil
+	}
 	return nil, types.ErrOutputNotFound
 }
 
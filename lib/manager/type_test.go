@@ -3,6 +3,7 @@ package manager_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/cache"
@@ -10,6 +11,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/input"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/processor"
@@ -133,6 +135,38 @@ func TestManagerCacheListErrors(t *testing.T) {
 	require.EqualError(t, err, "cache resource has an empty label")
 }
 
+func TestManagerCacheSwap(t *testing.T) {
+	cFoo := cache.NewConfig()
+	cFoo.Label = "foo"
+
+	conf := manager.NewResourceConfig()
+	conf.ResourceCaches = append(conf.ResourceCaches, cFoo)
+
+	mgr, err := manager.NewV2(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "foo", func(c types.Cache) {
+		require.NoError(t, c.Set("key", []byte("first")))
+	}))
+
+	badConf := cache.NewConfig()
+	badConf.Type = "notexist"
+	require.Error(t, mgr.SwapCache(context.Background(), "foo", badConf))
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "foo", func(c types.Cache) {
+		v, err := c.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(v))
+	}))
+
+	require.NoError(t, mgr.SwapCache(context.Background(), "foo", cache.NewConfig()))
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "foo", func(c types.Cache) {
+		_, err := c.Get("key")
+		assert.Equal(t, types.ErrKeyNotFound, err)
+	}))
+}
+
 func TestManagerBadCache(t *testing.T) {
 	testLog := log.Noop()
 
@@ -146,6 +180,66 @@ func TestManagerBadCache(t *testing.T) {
 	}
 }
 
+func TestManagerCacheRestoreAccess(t *testing.T) {
+	cFoo := cache.NewConfig()
+	cFoo.Label = "foo"
+
+	conf := manager.NewResourceConfig()
+	conf.ResourceCaches = append(conf.ResourceCaches, cFoo)
+
+	mgr, err := manager.NewV2(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, mgr.StoreCache(context.Background(), "foo", cache.NewConfig()))
+		}
+	}()
+
+	// Accesses during a store of the same name must wait for the new resource
+	// rather than report it missing.
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		require.NoError(t, mgr.AccessCache(context.Background(), "foo", func(types.Cache) {}))
+	}
+}
+
+func TestManagerStoreNewResourcesRollback(t *testing.T) {
+	mgr, err := manager.NewV2(manager.NewResourceConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	cFoo := cache.NewConfig()
+	cFoo.Label = "foo"
+
+	pBar := processor.NewConfig()
+	pBar.Label = "bar"
+	pBar.Type = "notexist"
+
+	oBaz := output.NewConfig()
+	oBaz.Label = "baz"
+	oBaz.Type = output.TypeDrop
+
+	conf := manager.NewResourceConfig()
+	conf.ResourceCaches = append(conf.ResourceCaches, cFoo)
+	conf.ResourceProcessors = append(conf.ResourceProcessors, pBar)
+	conf.ResourceOutputs = append(conf.ResourceOutputs, oBaz)
+
+	require.Error(t, mgr.StoreNewResources(context.Background(), conf))
+
+	require.NoError(t, mgr.AccessCache(context.Background(), "foo", func(types.Cache) {}))
+	assert.Equal(t, manager.ErrResourceNotFound("bar"), mgr.AccessProcessor(context.Background(), "bar", func(types.Processor) {}))
+	assert.Equal(t, manager.ErrResourceNotFound("baz"), mgr.AccessOutput(context.Background(), "baz", func(types.OutputWriter) {}))
+
+	mgr.CloseAsync()
+	require.NoError(t, mgr.WaitForClose(time.Second))
+}
+
 func TestManagerRateLimit(t *testing.T) {
 	conf := manager.NewConfig()
 	conf.RateLimits["foo"] = ratelimit.NewConfig()
@@ -266,6 +360,30 @@ func TestManagerProcessor(t *testing.T) {
 	}
 }
 
+func TestManagerProcessorSwap(t *testing.T) {
+	conf := manager.NewConfig()
+	conf.Processors["foo"] = processor.NewConfig()
+
+	mgr, err := manager.New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	badLabelConf := processor.NewConfig()
+	badLabelConf.Label = "bar"
+	require.EqualError(t, mgr.SwapProcessor(context.Background(), "foo", badLabelConf), "label 'bar' must be empty or match the resource name 'foo'")
+
+	newConf := processor.NewConfig()
+	newConf.Type = processor.TypeBloblang
+	newConf.Bloblang = `root = "swapped"`
+	require.NoError(t, mgr.SwapProcessor(context.Background(), "foo", newConf))
+
+	require.NoError(t, mgr.AccessProcessor(context.Background(), "foo", func(p types.Processor) {
+		msgs, res := p.ProcessMessage(message.New([][]byte{[]byte("hello")}))
+		require.Nil(t, res)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "swapped", string(msgs[0].Get(0).Get()))
+	}))
+}
+
 func TestManagerProcessorList(t *testing.T) {
 	cFoo := processor.NewConfig()
 	cFoo.Label = "foo"
@@ -50,7 +50,7 @@ Allows you to configure a [batching policy](/docs/configuration/batching).`,
 					return "", false
 				}
 				return "field condition is deprecated in favour of check", m["type"] == "static" && m["static"] == false
-			}),
+			}).Fixer(docs.FixConditionToCheck),
 			docs.FieldAdvanced(
 				"processors",
 				"A list of [processors](/docs/components/processors/about) to apply to a batch as it is flushed. This allows you to aggregate and archive the batch however you see fit. Please note that all resulting messages are flushed as a single batch, therefore splitting the batch into smaller batches using these processors is a no-op.",
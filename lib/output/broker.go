@@ -7,6 +7,7 @@ import (
 	"github.com/Jeffail/benthos/v3/internal/component/output"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/broker"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
@@ -161,6 +162,8 @@ func NewBroker(
 	}
 
 	outputs := make([]types.Output, lOutputs)
+	outputLabels := make([]string, lOutputs)
+	brokerLabel := lineage.ComponentLabel(mgr, "output")
 
 	_, isThreaded := map[string]struct{}{
 		"round_robin": {},
@@ -174,6 +177,7 @@ func NewBroker(
 			if isThreaded {
 				pipes = pipelines
 			}
+			outputLabels[j*len(outputConfs)+i] = fmt.Sprintf("%v.broker.outputs.%v", brokerLabel, i)
 			oMgr, oLog, oStats := interop.LabelChild(fmt.Sprintf("broker.outputs.%v", i), mgr, log, stats)
 			oStats = metrics.Combine(stats, oStats)
 			if outputs[j*len(outputConfs)+i], err = New(oConf, oMgr, oLog, oStats, pipes...); err != nil {
@@ -194,12 +198,12 @@ func NewBroker(
 	case "fan_out":
 		var bTmp *broker.FanOut
 		if bTmp, err = broker.NewFanOut(outputs, log, stats); err == nil {
-			b = bTmp.WithMaxInFlight(maxInFlight)
+			b = bTmp.WithMaxInFlight(maxInFlight).WithOutputLabels(outputLabels)
 		}
 	case "fan_out_sequential":
 		var bTmp *broker.FanOutSequential
 		if bTmp, err = broker.NewFanOutSequential(outputs, log, stats); err == nil {
-			b = bTmp.WithMaxInFlight(maxInFlight)
+			b = bTmp.WithMaxInFlight(maxInFlight).WithOutputLabels(outputLabels)
 		}
 	case "round_robin":
 		b, err = broker.NewRoundRobin(outputs, stats)
@@ -26,6 +26,29 @@ Both the ` + "`id` and `index`" + ` fields can be dynamically set using function
 interpolations described [here](/docs/configuration/interpolation#bloblang-queries). When
 sending batched messages these interpolations are performed per message part.
 
+### Errors
+
+The response of each bulk request is inspected for the status of each document. Documents rejected with a status of ` + "`429`" + ` or ` + "`5xx`" + ` are retried according to the retry fields of this output, without resending the documents that succeeded. Documents that are rejected with any other status, such as a mapping or validation error, are not retried and are instead reported as errors of only those messages. This means that when this output is wrapped within a ` + "[`fallback`](/docs/components/outputs/fallback)" + ` output only the rejected documents are routed to the next output.
+
+### Updates
+
+When the action is ` + "`update`" + ` messages are used as partial documents that are merged into existing documents. Setting ` + "`doc_as_upsert`" + ` to ` + "`true`" + ` causes messages to be indexed as new documents when a document with the ID doesn't yet exist.
+
+Alternatively, a ` + "`script`" + ` mapping can be provided in order to update documents with a script, where ` + "`doc_as_upsert`" + ` causes the message to be indexed when a document with the ID doesn't exist rather than running the script:
+
+` + "```yaml" + `
+output:
+  elasticsearch:
+    urls: [ http://localhost:9200 ]
+    index: visits
+    action: update
+    id: ${! json("user.id") }
+    doc_as_upsert: true
+    script: |
+      root.source = "ctx._source.visits += params.count"
+      root.params.count = this.visits
+` + "```" + `
+
 ### AWS
 
 It's possible to enable AWS connectivity with this output using the ` + "`aws`" + `
@@ -36,7 +59,19 @@ false for connections to succeed.`,
 		FieldSpecs: docs.FieldSpecs{
 			docs.FieldCommon("urls", "A list of URLs to connect to. If an item of the list contains commas it will be expanded into multiple URLs.", []string{"http://localhost:9200"}).Array(),
 			docs.FieldCommon("index", "The index to place messages.").IsInterpolated(),
-			docs.FieldAdvanced("action", "The action to take on the document.").IsInterpolated().HasOptions("index", "update", "delete"),
+			docs.FieldAdvanced("action", "The action to take on the document.").IsInterpolated().HasAnnotatedOptions(
+				"index", "Index the document, replacing any existing document with the same ID.",
+				"create", "Index the document only when a document with the same ID doesn't exist, otherwise the message fails with a `409` status.",
+				"update", "Update an existing document, either by merging the message into it or by running a `script`.",
+				"delete", "Delete the document.",
+			),
+			docs.FieldBool("doc_as_upsert", "When the action is `update`, whether the message should be indexed as a new document when a document with the ID doesn't exist.").Advanced().HasDefault(false).AtVersion("3.65.0"),
+			docs.FieldBloblang(
+				"script", "An optional [Bloblang mapping](/docs/guides/bloblang/about) that results in a script to update documents with when the action is `update`. The mapping must result in either a string containing the source of the script, or an object with a string field `source` and optional fields `lang` and `params`.",
+				`root = "ctx._source.counter += 1"`,
+				`root.source = "ctx._source.tags.add(params.tag)"
+root.params.tag = this.tag`,
+			).Advanced().HasDefault("").AtVersion("3.65.0"),
 			docs.FieldAdvanced("pipeline", "An optional pipeline id to preprocess incoming documents.").IsInterpolated(),
 			docs.FieldCommon("id", "The ID for indexed messages. Interpolation should be used in order to create a unique ID for each message.").IsInterpolated(),
 			docs.FieldCommon("type", "The document type."),
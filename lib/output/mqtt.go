@@ -1,7 +1,10 @@
 package output
 
 import (
+	"fmt"
+
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/metadata"
 	"github.com/Jeffail/benthos/v3/internal/mqttconf"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -20,10 +23,28 @@ Pushes messages to an MQTT broker.`,
 		Description: `
 The ` + "`topic`" + ` field can be dynamically set using function interpolations
 described [here](/docs/configuration/interpolation#bloblang-queries). When sending batched
-messages these interpolations are performed per message part.`,
+messages these interpolations are performed per message part.
+
+### MQTT 5
+
+Setting ` + "`protocol_version`" + ` to ` + "`5`" + ` connects to brokers with version 5 of the MQTT protocol, which enables the fields ` + "`user_properties`" + `, ` + "`response_topic`" + `, ` + "`correlation_data`" + `, ` + "`message_expiry`" + ` and ` + "`topic_alias_maximum`" + `.
+
+The fields ` + "`response_topic`" + ` and ` + "`correlation_data`" + ` allow messages to be sent as requests following the request/response pattern of MQTT 5, and can also be used to respond to requests consumed with the ` + "[`mqtt` input](/docs/components/inputs/mqtt)" + `:
+
+` + "```yaml" + `
+output:
+  mqtt:
+    urls: [ tcp://localhost:1883 ]
+    protocol_version: "5"
+    topic: ${! meta("mqtt_response_topic") }
+    correlation_data: ${! meta("mqtt_correlation_data") }
+` + "```" + `
+
+When ` + "`topic_alias_maximum`" + ` is set messages sent on the same topic are given a topic alias, up to the maximum number of aliases allowed by both the config and the broker, in which case the topic is only included in the first message that is sent with each alias.`,
 		Async: true,
 		FieldSpecs: docs.FieldSpecs{
 			docs.FieldCommon("urls", "A list of URLs to connect to. If an item of the list contains commas it will be expanded into multiple URLs.", []string{"tcp://localhost:1883"}).Array(),
+			docs.FieldString("protocol_version", "The version of the MQTT protocol to connect with.").HasOptions("3.1.1", "5").HasDefault("3.1.1").AtVersion("3.65.0"),
 			docs.FieldCommon("topic", "The topic to publish messages to."),
 			docs.FieldCommon("client_id", "An identifier for the client connection."),
 			docs.FieldString("dynamic_client_id_suffix", "Append a dynamically generated suffix to the specified `client_id` on each run of the pipeline. This can be useful when clustering Benthos producers.").Optional().Advanced().HasAnnotatedOptions(
@@ -40,6 +61,11 @@ messages these interpolations are performed per message part.`,
 			docs.FieldAdvanced("keepalive", "Max seconds of inactivity before a keepalive message is sent."),
 			tls.FieldSpec().AtVersion("3.45.0"),
 			docs.FieldCommon("max_in_flight", "The maximum number of messages to have in flight at a given time. Increase this to improve throughput."),
+			docs.FieldAdvanced("user_properties", "Specify criteria for which metadata values are added to messages as user properties. This field is only used with MQTT 5.").WithChildren(metadata.IncludeFilterDocs()...).AtVersion("3.65.0"),
+			docs.FieldString("response_topic", "An optional topic to add to messages that responses should be sent to. This field is only used with MQTT 5.", `${! meta("reply_to") }`).IsInterpolated().Advanced().HasDefault("").AtVersion("3.65.0"),
+			docs.FieldString("correlation_data", "Optional correlation data to add to messages, which is returned within responses in order to identify the request being responded to. This field is only used with MQTT 5.", `${! meta("request_id") }`).IsInterpolated().Advanced().HasDefault("").AtVersion("3.65.0"),
+			docs.FieldString("message_expiry", "An optional duration after which messages expire, and are no longer delivered by the broker. The duration is rounded down to whole seconds. This field is only used with MQTT 5.", "60s", "1h").Advanced().HasDefault("").AtVersion("3.65.0"),
+			docs.FieldInt("topic_alias_maximum", "The maximum number of topic aliases to use when sending messages, which reduces the size of messages sent on the same topics. This field is only used with MQTT 5, where a value of zero disables topic aliases.").Advanced().HasDefault(0).AtVersion("3.65.0"),
 		},
 		Categories: []Category{
 			CategoryServices,
@@ -51,7 +77,16 @@ messages these interpolations are performed per message part.`,
 
 // NewMQTT creates a new MQTT output type.
 func NewMQTT(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	w, err := writer.NewMQTTV2(conf.MQTT, mgr, log, stats)
+	var w AsyncSink
+	var err error
+	switch conf.MQTT.ProtocolVersion {
+	case mqttconf.ProtocolV311, "":
+		w, err = writer.NewMQTTV2(conf.MQTT, mgr, log, stats)
+	case mqttconf.ProtocolV5:
+		w, err = writer.NewMQTTV5(conf.MQTT, mgr, log, stats)
+	default:
+		err = fmt.Errorf("protocol_version not recognised: %v", conf.MQTT.ProtocolVersion)
+	}
 	if err != nil {
 		return nil, err
 	}
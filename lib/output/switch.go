@@ -112,7 +112,7 @@ behavior is false, which will drop the message.`,
 			).OmitWhen(func(v, _ interface{}) (string, bool) {
 				arr, ok := v.([]interface{})
 				return "field outputs is deprecated in favour of cases", ok && len(arr) == 0
-			}),
+			}).Fixer(fixSwitchOutputs),
 		).Linter(func(ctx docs.LintContext, line, col int, value interface{}) []docs.Lint {
 			if _, ok := value.(map[string]interface{}); !ok {
 				return nil
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"sync"
 
+	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/shutdown"
 	"github.com/Jeffail/benthos/v3/lib/condition"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/util/throttle"
 	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
 )
 
 //------------------------------------------------------------------------------
@@ -64,6 +66,74 @@ func (s *SwitchConfigOutput) UnmarshalYAML(unmarshal func(interface{}) error) er
 
 //------------------------------------------------------------------------------
 
+func fixSwitchOutputs(ctx docs.LintContext, parent, value *yaml.Node) []docs.Lint {
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		if parent.Content[i].Value == "cases" && len(parent.Content[i+1].Content) > 0 {
+			return nil
+		}
+	}
+	if value.Kind != yaml.SequenceNode || len(value.Content) == 0 {
+		return nil
+	}
+
+	casesNode := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, outputNode := range value.Content {
+		if outputNode.Kind != yaml.MappingNode {
+			return nil
+		}
+		caseNode := &yaml.Node{Kind: yaml.MappingNode}
+		for j := 0; j < len(outputNode.Content)-1; j += 2 {
+			key, field := outputNode.Content[j], outputNode.Content[j+1]
+			switch key.Value {
+			case "condition":
+				query, ok := docs.ConditionYAMLToBloblang(field)
+				if !ok {
+					return nil
+				}
+				if query == "true" {
+					continue
+				}
+				key.Value = "check"
+				field = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: query}
+			case "fallthrough":
+				if field.Value != "true" {
+					continue
+				}
+				key.Value = "continue"
+			case "output":
+			default:
+				return nil
+			}
+			caseNode.Content = append(caseNode.Content, key, field)
+		}
+		casesNode.Content = append(casesNode.Content, caseNode)
+	}
+
+	var outputsLine int
+	newNodes := make([]*yaml.Node, 0, len(parent.Content))
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		switch parent.Content[i].Value {
+		case "cases":
+			continue
+		case "outputs":
+			outputsLine = parent.Content[i].Line
+			newNodes = append(newNodes,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "cases", HeadComment: parent.Content[i].HeadComment},
+				casesNode,
+			)
+			continue
+		}
+		newNodes = append(newNodes, parent.Content[i], parent.Content[i+1])
+	}
+	parent.Content = newNodes
+
+	return []docs.Lint{
+		docs.NewLintFix(outputsLine, "replaced deprecated field outputs with equivalent cases"),
+	}
+}
+
+//------------------------------------------------------------------------------
+
 func (o *Switch) loopDeprecated() {
 	var (
 		wg         = sync.WaitGroup{}
@@ -8,7 +8,10 @@ import (
 	"strings"
 	"time"
 
+	ibatch "github.com/Jeffail/benthos/v3/internal/batch"
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
 	"github.com/Jeffail/benthos/v3/internal/interop"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
@@ -42,6 +45,8 @@ type ElasticsearchConfig struct {
 	Healthcheck     bool                 `json:"healthcheck" yaml:"healthcheck"`
 	ID              string               `json:"id" yaml:"id"`
 	Action          string               `json:"action" yaml:"action"`
+	DocAsUpsert     bool                 `json:"doc_as_upsert" yaml:"doc_as_upsert"`
+	Script          string               `json:"script" yaml:"script"`
 	Index           string               `json:"index" yaml:"index"`
 	Pipeline        string               `json:"pipeline" yaml:"pipeline"`
 	Routing         string               `json:"routing" yaml:"routing"`
@@ -68,6 +73,8 @@ func NewElasticsearchConfig() ElasticsearchConfig {
 		Sniff:       true,
 		Healthcheck: true,
 		Action:      "index",
+		DocAsUpsert: false,
+		Script:      "",
 		ID:          `${!count("elastic_ids")}-${!timestamp_unix()}`,
 		Index:       "benthos_index",
 		Pipeline:    "",
@@ -108,6 +115,7 @@ type Elasticsearch struct {
 	indexStr    *field.Expression
 	pipelineStr *field.Expression
 	routingStr  *field.Expression
+	script      *mapping.Executor
 
 	eJSONErr metrics.StatCounter
 
@@ -148,6 +156,11 @@ func NewElasticsearchV2(conf ElasticsearchConfig, mgr types.Manager, log log.Mod
 	if e.routingStr, err = interop.NewBloblangField(mgr, conf.Routing); err != nil {
 		return nil, fmt.Errorf("failed to parse routing key expression: %v", err)
 	}
+	if conf.Script != "" {
+		if e.script, err = interop.NewBloblangMapping(mgr, conf.Script); err != nil {
+			return nil, fmt.Errorf("failed to parse script mapping: %v", err)
+		}
+	}
 
 	for _, u := range conf.URLs {
 		for _, splitURL := range strings.Split(u, ",") {
@@ -241,6 +254,9 @@ func (e *Elasticsearch) Connect() error {
 }
 
 func shouldRetry(s int) bool {
+	if s == http.StatusTooManyRequests {
+		return true
+	}
 	if s >= 500 && s <= 599 {
 		return true
 	}
@@ -254,66 +270,71 @@ type pendingBulkIndex struct {
 	Routing  string
 	Type     string
 	Doc      interface{}
+	Script   *elastic.Script
 	ID       string
 }
 
-// WriteWithContext will attempt to write a message to Elasticsearch, wait for
-// acknowledgement, and returns an error if applicable.
-func (e *Elasticsearch) WriteWithContext(ctx context.Context, msg types.Message) error {
-	return e.Write(msg)
+// pendingBulkRequest is a bulkable request along with the index of the message
+// it was built from.
+type pendingBulkRequest struct {
+	msgIndex int
+	req      elastic.BulkableRequest
 }
 
-// Write will attempt to write a message to Elasticsearch, wait for
+// WriteWithContext will attempt to write a message to Elasticsearch, wait for
 // acknowledgement, and returns an error if applicable.
-func (e *Elasticsearch) Write(msg types.Message) error {
+func (e *Elasticsearch) WriteWithContext(ctx context.Context, msg types.Message) error {
 	if e.client == nil {
 		return types.ErrNotConnected
 	}
 
 	boff := e.backoffCtor()
 
-	requests := make([]*pendingBulkIndex, msg.Len())
-	if err := msg.Iter(func(i int, part types.Part) error {
-		jObj, ierr := part.JSON()
-		if ierr != nil {
-			e.eJSONErr.Incr(1)
-			e.log.Errorf("Failed to marshal message into JSON document: %v\n", ierr)
-			return fmt.Errorf("failed to marshal message into JSON document: %w", ierr)
+	// Documents that can't be written without changes, either because they
+	// can't be converted into a request or because they were rejected with a
+	// status that isn't retried, are reported as failed within a batch error.
+	var batchErr *ibatch.Error
+	failed := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = ibatch.NewError(msg, err)
 		}
-		requests[i] = &pendingBulkIndex{
-			Action:   e.actionStr.String(i, msg),
-			Index:    e.indexStr.String(i, msg),
-			Pipeline: e.pipelineStr.String(i, msg),
-			Routing:  e.routingStr.String(i, msg),
-			Type:     e.conf.Type,
-			Doc:      jObj,
-			ID:       e.idStr.String(i, msg),
-		}
-		return nil
-	}); err != nil {
-		return err
+		batchErr.Failed(i, err)
 	}
 
-	b := e.client.Bulk()
-	for _, v := range requests {
-		bulkReq, err := e.buildBulkableRequest(v)
-		if err != nil {
-			return err
+	var requests []pendingBulkRequest
+	_ = msg.Iter(func(i int, part types.Part) error {
+		p, err := e.buildPendingBulkIndex(i, msg)
+		if err == nil {
+			var bulkReq elastic.BulkableRequest
+			if bulkReq, err = e.buildBulkableRequest(p); err == nil {
+				requests = append(requests, pendingBulkRequest{
+					msgIndex: i,
+					req:      bulkReq,
+				})
+				return nil
+			}
 		}
-		b.Add(bulkReq)
-	}
+		e.log.Errorf("Failed to create request for message: %v\n", err)
+		failed(i, err)
+		return nil
+	})
 
 	lastErrReason := "no reason given"
-	for b.NumberOfActions() != 0 {
-		result, err := b.Do(context.Background())
+	for len(requests) > 0 {
+		b := e.client.Bulk()
+		for _, v := range requests {
+			b.Add(v.req)
+		}
+
+		result, err := b.Do(ctx)
 		if err != nil {
 			return err
 		}
 		if !result.Errors {
-			return nil
+			break
 		}
 
-		var newRequests []*pendingBulkIndex
+		var retryRequests []pendingBulkRequest
 		for i, resp := range result.Items {
 			for _, item := range resp {
 				if item.Status >= 200 && item.Status <= 299 {
@@ -323,38 +344,51 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 				reason := "no reason given"
 				if item.Error != nil {
 					reason = item.Error.Reason
-					lastErrReason = fmt.Sprintf("status [%v]: %v", item.Status, reason)
 				}
+				lastErrReason = fmt.Sprintf("status [%v]: %v", item.Status, reason)
 
 				e.log.Errorf("Elasticsearch message '%v' rejected with status [%v]: %v\n", item.Id, item.Status, reason)
-				if !shouldRetry(item.Status) {
-					return fmt.Errorf("failed to send message '%v': %v", item.Id, reason)
-				}
 
-				// IMPORTANT: i exactly matches the index of our source requests
+				// IMPORTANT: i exactly matches the index of our pending requests
 				// and when we re-run our bulk request with errored requests
 				// that must remain true.
-				sourceReq := requests[i]
-				bulkReq, err := e.buildBulkableRequest(sourceReq)
-				if err != nil {
-					return err
+				if !shouldRetry(item.Status) {
+					failed(requests[i].msgIndex, fmt.Errorf("failed to send message '%v': %v", item.Id, lastErrReason))
+					continue
 				}
-				b.Add(bulkReq)
-				newRequests = append(newRequests, sourceReq)
+				retryRequests = append(retryRequests, requests[i])
 			}
 		}
-		requests = newRequests
+		if requests = retryRequests; len(requests) == 0 {
+			break
+		}
 
 		wait := boff.NextBackOff()
 		if wait == backoff.Stop {
-			return fmt.Errorf("retries exhausted for messages, aborting with last error reported as: %v", lastErrReason)
+			for _, v := range requests {
+				failed(v.msgIndex, fmt.Errorf("retries exhausted for message, aborting with last error reported as: %v", lastErrReason))
+			}
+			break
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-		time.Sleep(wait)
 	}
 
+	if batchErr != nil {
+		return batchErr
+	}
 	return nil
 }
 
+// Write will attempt to write a message to Elasticsearch, wait for
+// acknowledgement, and returns an error if applicable.
+func (e *Elasticsearch) Write(msg types.Message) error {
+	return e.WriteWithContext(context.Background(), msg)
+}
+
 // CloseAsync shuts down the Elasticsearch writer and stops processing messages.
 func (e *Elasticsearch) CloseAsync() {
 }
@@ -364,17 +398,94 @@ func (e *Elasticsearch) WaitForClose(timeout time.Duration) error {
 	return nil
 }
 
+func (e *Elasticsearch) buildPendingBulkIndex(i int, msg types.Message) (*pendingBulkIndex, error) {
+	p := &pendingBulkIndex{
+		Action:   e.actionStr.String(i, msg),
+		Index:    e.indexStr.String(i, msg),
+		Pipeline: e.pipelineStr.String(i, msg),
+		Routing:  e.routingStr.String(i, msg),
+		Type:     e.conf.Type,
+		ID:       e.idStr.String(i, msg),
+	}
+	if p.Action == "delete" {
+		return p, nil
+	}
+
+	var err error
+	if p.Doc, err = msg.Get(i).JSON(); err != nil {
+		e.eJSONErr.Incr(1)
+		return nil, fmt.Errorf("failed to marshal message into JSON document: %w", err)
+	}
+
+	if e.script != nil && p.Action == "update" {
+		if p.Script, err = e.buildScript(i, msg, p.Doc); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Build a script from the result of the script mapping, which is either the
+// source of the script or an object containing the source along with the
+// language and parameters of the script.
+func (e *Elasticsearch) buildScript(i int, msg types.Message, doc interface{}) (*elastic.Script, error) {
+	v, err := e.script.Exec(query.FunctionContext{
+		Maps:     map[string]query.Function{},
+		Vars:     map[string]interface{}{},
+		Index:    i,
+		MsgBatch: msg,
+	}.WithValue(doc))
+	if err != nil {
+		return nil, fmt.Errorf("script mapping failed: %w", err)
+	}
+
+	switch t := v.(type) {
+	case string:
+		return elastic.NewScript(t), nil
+	case map[string]interface{}:
+		source, ok := t["source"].(string)
+		if !ok {
+			return nil, fmt.Errorf("script mapping returned an object without a string field 'source'")
+		}
+		script := elastic.NewScript(source)
+		if lang, exists := t["lang"]; exists {
+			langStr, ok := lang.(string)
+			if !ok {
+				return nil, fmt.Errorf("script mapping returned a non-string field 'lang': %T", lang)
+			}
+			script = script.Lang(langStr)
+		}
+		if params, exists := t["params"]; exists {
+			paramsObj, ok := params.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("script mapping returned a non-object field 'params': %T", params)
+			}
+			script = script.Params(paramsObj)
+		}
+		return script, nil
+	}
+	return nil, fmt.Errorf("script mapping returned unexpected result: %T", v)
+}
+
 // Build a bulkable request for a given pending bulk index item.
 func (e *Elasticsearch) buildBulkableRequest(p *pendingBulkIndex) (elastic.BulkableRequest, error) {
 	// TODO: V4 the type field should be optional and not used
 	switch p.Action {
 	case "update":
-		return elastic.NewBulkUpdateRequest().
+		r := elastic.NewBulkUpdateRequest().
 			Index(p.Index).
 			Routing(p.Routing).
 			Type(p.Type).
-			Id(p.ID).
-			Doc(p.Doc), nil
+			Id(p.ID)
+		if p.Script != nil {
+			r = r.Script(p.Script)
+			if e.conf.DocAsUpsert {
+				r = r.Upsert(p.Doc)
+			}
+		} else {
+			r = r.Doc(p.Doc).DocAsUpsert(e.conf.DocAsUpsert)
+		}
+		return r, nil
 	case "delete":
 		return elastic.NewBulkDeleteRequest().
 			Index(p.Index).
@@ -389,6 +500,14 @@ func (e *Elasticsearch) buildBulkableRequest(p *pendingBulkIndex) (elastic.Bulka
 			Type(p.Type).
 			Id(p.ID).
 			Doc(p.Doc), nil
+	case "create":
+		return elastic.NewBulkCreateRequest().
+			Index(p.Index).
+			Pipeline(p.Pipeline).
+			Routing(p.Routing).
+			Type(p.Type).
+			Id(p.ID).
+			Doc(p.Doc), nil
 	default:
 		return nil, fmt.Errorf("elasticsearch action '%s' is not allowed", p.Action)
 	}
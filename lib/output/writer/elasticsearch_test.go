@@ -0,0 +1,273 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	ibatch "github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBulkAction struct {
+	Op     string
+	ID     string
+	Source map[string]interface{}
+}
+
+// fakeBulkServer serves bulk requests by responding to each action with the
+// next status listed for its document ID, or 201 once none remain.
+func fakeBulkServer(t *testing.T, statuses map[string][]int) (*httptest.Server, func() [][]fakeBulkAction) {
+	t.Helper()
+
+	var mut sync.Mutex
+	var requests [][]fakeBulkAction
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_bulk", r.URL.Path)
+
+		var actions []fakeBulkAction
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &meta))
+			for op, fields := range meta {
+				action := fakeBulkAction{Op: op}
+				action.ID, _ = fields["_id"].(string)
+				if op != "delete" {
+					require.True(t, scanner.Scan())
+					require.NoError(t, json.Unmarshal(scanner.Bytes(), &action.Source))
+				}
+				actions = append(actions, action)
+			}
+		}
+
+		mut.Lock()
+		requests = append(requests, actions)
+		var items []map[string]interface{}
+		hasErrors := false
+		for _, action := range actions {
+			status := 201
+			if s := statuses[action.ID]; len(s) > 0 {
+				status, statuses[action.ID] = s[0], s[1:]
+			}
+			item := map[string]interface{}{
+				"_index": "test",
+				"_id":    action.ID,
+				"status": status,
+			}
+			if status > 299 {
+				hasErrors = true
+				item["error"] = map[string]interface{}{
+					"type":   "test_exception",
+					"reason": "nope",
+				}
+			}
+			items = append(items, map[string]interface{}{action.Op: item})
+		}
+		mut.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"took":   1,
+			"errors": hasErrors,
+			"items":  items,
+		}))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() [][]fakeBulkAction {
+		mut.Lock()
+		defer mut.Unlock()
+		return requests
+	}
+}
+
+func testElasticsearchConf(url string) ElasticsearchConfig {
+	conf := NewElasticsearchConfig()
+	conf.URLs = []string{url}
+	conf.Sniff = false
+	conf.Healthcheck = false
+	conf.ID = `${! json("id") }`
+	conf.Index = "test"
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+	conf.Backoff.MaxElapsedTime = "1s"
+	return conf
+}
+
+func TestElasticsearchPerDocumentErrors(t *testing.T) {
+	srv, requests := fakeBulkServer(t, map[string][]int{
+		"b": {503, 429},
+		"c": {400},
+		"d": {409},
+	})
+
+	e, err := NewElasticsearch(testElasticsearchConf(srv.URL), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+		[]byte(`{"id":"c"}`),
+		[]byte(`{"id":"d"}`),
+		[]byte(`not json`),
+	})
+	err = e.Write(msg)
+	require.Error(t, err)
+
+	var batchErr *ibatch.Error
+	require.True(t, errors.As(err, &batchErr))
+
+	var failedIndexes []int
+	batchErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			failedIndexes = append(failedIndexes, i)
+		}
+		return true
+	})
+	assert.Equal(t, []int{2, 3, 4}, failedIndexes)
+
+	var ids [][]string
+	for _, req := range requests() {
+		var reqIDs []string
+		for _, action := range req {
+			reqIDs = append(reqIDs, action.ID)
+		}
+		ids = append(ids, reqIDs)
+	}
+	assert.Equal(t, [][]string{
+		{"a", "b", "c", "d"},
+		{"b"},
+		{"b"},
+	}, ids)
+}
+
+func TestElasticsearchRetriesExhausted(t *testing.T) {
+	srv, _ := fakeBulkServer(t, map[string][]int{
+		"b": {503, 503, 503, 503, 503, 503, 503, 503, 503, 503},
+	})
+
+	conf := testElasticsearchConf(srv.URL)
+	conf.MaxRetries = 2
+
+	e, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	err = e.Write(message.New([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+	}))
+	require.Error(t, err)
+
+	var batchErr *ibatch.Error
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.IndexedErrors())
+	assert.Contains(t, err.Error(), "retries exhausted")
+}
+
+func TestElasticsearchActions(t *testing.T) {
+	srv, requests := fakeBulkServer(t, nil)
+
+	conf := testElasticsearchConf(srv.URL)
+	conf.Action = `${! meta("action") }`
+	conf.DocAsUpsert = true
+
+	e, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"a","v":1}`),
+		[]byte(`{"id":"b","v":2}`),
+		[]byte(`{"id":"c","v":3}`),
+	})
+	msg.Get(0).Metadata().Set("action", "create")
+	msg.Get(1).Metadata().Set("action", "update")
+	msg.Get(2).Metadata().Set("action", "delete")
+	require.NoError(t, e.Write(msg))
+
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, []fakeBulkAction{
+		{Op: "create", ID: "a", Source: map[string]interface{}{"id": "a", "v": 1.0}},
+		{Op: "update", ID: "b", Source: map[string]interface{}{
+			"doc":           map[string]interface{}{"id": "b", "v": 2.0},
+			"doc_as_upsert": true,
+		}},
+		{Op: "delete", ID: "c"},
+	}, reqs[0])
+}
+
+func TestElasticsearchScriptedUpdate(t *testing.T) {
+	srv, requests := fakeBulkServer(t, nil)
+
+	conf := testElasticsearchConf(srv.URL)
+	conf.Action = "update"
+	conf.DocAsUpsert = true
+	conf.Script = `
+root = if this.id == "a" {
+  "ctx._source.counter += 1"
+} else {
+  {
+    "source": "ctx._source.counter += params.count",
+    "lang": "painless",
+    "params": { "count": this.v }
+  }
+}
+`
+
+	e, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	require.NoError(t, e.Write(message.New([][]byte{
+		[]byte(`{"id":"a","v":1}`),
+		[]byte(`{"id":"b","v":2}`),
+	})))
+
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, []fakeBulkAction{
+		{Op: "update", ID: "a", Source: map[string]interface{}{
+			"script": map[string]interface{}{"source": "ctx._source.counter += 1"},
+			"upsert": map[string]interface{}{"id": "a", "v": 1.0},
+		}},
+		{Op: "update", ID: "b", Source: map[string]interface{}{
+			"script": map[string]interface{}{
+				"source": "ctx._source.counter += params.count",
+				"lang":   "painless",
+				"params": map[string]interface{}{"count": 2.0},
+			},
+			"upsert": map[string]interface{}{"id": "b", "v": 2.0},
+		}},
+	}, reqs[0])
+}
+
+func TestElasticsearchBadScript(t *testing.T) {
+	srv, requests := fakeBulkServer(t, nil)
+
+	conf := testElasticsearchConf(srv.URL)
+	conf.Action = "update"
+	conf.Script = `root.lang = "painless"`
+
+	e, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	err = e.Write(message.New([][]byte{[]byte(`{"id":"a"}`)}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without a string field 'source'")
+	assert.Empty(t, requests())
+}
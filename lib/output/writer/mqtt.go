@@ -10,6 +10,7 @@ import (
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/metadata"
 	"github.com/Jeffail/benthos/v3/internal/mqttconf"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -23,38 +24,50 @@ import (
 
 // MQTTConfig contains configuration fields for the MQTT output type.
 type MQTTConfig struct {
-	URLs                  []string      `json:"urls" yaml:"urls"`
-	QoS                   uint8         `json:"qos" yaml:"qos"`
-	Retained              bool          `json:"retained" yaml:"retained"`
-	RetainedInterpolated  string        `json:"retained_interpolated" yaml:"retained_interpolated"`
-	Topic                 string        `json:"topic" yaml:"topic"`
-	ClientID              string        `json:"client_id" yaml:"client_id"`
-	DynamicClientIDSuffix string        `json:"dynamic_client_id_suffix" yaml:"dynamic_client_id_suffix"`
-	Will                  mqttconf.Will `json:"will" yaml:"will"`
-	User                  string        `json:"user" yaml:"user"`
-	Password              string        `json:"password" yaml:"password"`
-	ConnectTimeout        string        `json:"connect_timeout" yaml:"connect_timeout"`
-	WriteTimeout          string        `json:"write_timeout" yaml:"write_timeout"`
-	KeepAlive             int64         `json:"keepalive" yaml:"keepalive"`
-	MaxInFlight           int           `json:"max_in_flight" yaml:"max_in_flight"`
-	TLS                   tls.Config    `json:"tls" yaml:"tls"`
+	URLs                  []string                     `json:"urls" yaml:"urls"`
+	ProtocolVersion       string                       `json:"protocol_version" yaml:"protocol_version"`
+	QoS                   uint8                        `json:"qos" yaml:"qos"`
+	Retained              bool                         `json:"retained" yaml:"retained"`
+	RetainedInterpolated  string                       `json:"retained_interpolated" yaml:"retained_interpolated"`
+	Topic                 string                       `json:"topic" yaml:"topic"`
+	ClientID              string                       `json:"client_id" yaml:"client_id"`
+	DynamicClientIDSuffix string                       `json:"dynamic_client_id_suffix" yaml:"dynamic_client_id_suffix"`
+	Will                  mqttconf.Will                `json:"will" yaml:"will"`
+	User                  string                       `json:"user" yaml:"user"`
+	Password              string                       `json:"password" yaml:"password"`
+	ConnectTimeout        string                       `json:"connect_timeout" yaml:"connect_timeout"`
+	WriteTimeout          string                       `json:"write_timeout" yaml:"write_timeout"`
+	KeepAlive             int64                        `json:"keepalive" yaml:"keepalive"`
+	MaxInFlight           int                          `json:"max_in_flight" yaml:"max_in_flight"`
+	TLS                   tls.Config                   `json:"tls" yaml:"tls"`
+	UserProperties        metadata.IncludeFilterConfig `json:"user_properties" yaml:"user_properties"`
+	ResponseTopic         string                       `json:"response_topic" yaml:"response_topic"`
+	CorrelationData       string                       `json:"correlation_data" yaml:"correlation_data"`
+	MessageExpiry         string                       `json:"message_expiry" yaml:"message_expiry"`
+	TopicAliasMaximum     int                          `json:"topic_alias_maximum" yaml:"topic_alias_maximum"`
 }
 
 // NewMQTTConfig creates a new MQTTConfig with default values.
 func NewMQTTConfig() MQTTConfig {
 	return MQTTConfig{
-		URLs:           []string{"tcp://localhost:1883"},
-		QoS:            1,
-		Topic:          "benthos_topic",
-		ClientID:       "benthos_output",
-		Will:           mqttconf.EmptyWill(),
-		User:           "",
-		Password:       "",
-		ConnectTimeout: "30s",
-		WriteTimeout:   "3s",
-		MaxInFlight:    1,
-		KeepAlive:      30,
-		TLS:            tls.NewConfig(),
+		URLs:              []string{"tcp://localhost:1883"},
+		ProtocolVersion:   mqttconf.ProtocolV311,
+		QoS:               1,
+		Topic:             "benthos_topic",
+		ClientID:          "benthos_output",
+		Will:              mqttconf.EmptyWill(),
+		User:              "",
+		Password:          "",
+		ConnectTimeout:    "30s",
+		WriteTimeout:      "3s",
+		MaxInFlight:       1,
+		KeepAlive:         30,
+		TLS:               tls.NewConfig(),
+		UserProperties:    metadata.NewIncludeFilterConfig(),
+		ResponseTopic:     "",
+		CorrelationData:   "",
+		MessageExpiry:     "",
+		TopicAliasMaximum: 0,
 	}
 }
 
@@ -0,0 +1,359 @@
+package writer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/metadata"
+	"github.com/Jeffail/benthos/v3/internal/mqttconf"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/eclipse/paho.golang/paho"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+//------------------------------------------------------------------------------
+
+// MQTTV5 is an output type that serves MQTT messages using version 5 of the
+// MQTT protocol.
+type MQTTV5 struct {
+	log   log.Modular
+	stats metrics.Type
+
+	connectTimeout time.Duration
+	writeTimeout   time.Duration
+	messageExpiry  *uint32
+	tlsConf        *tls.Config
+
+	urls            []string
+	conf            MQTTConfig
+	topic           *field.Expression
+	retained        *field.Expression
+	responseTopic   *field.Expression
+	correlationData *field.Expression
+	userProps       *metadata.IncludeFilter
+
+	client  *paho.Client
+	aliases *mqttV5TopicAliases
+	connMut sync.RWMutex
+}
+
+// NewMQTTV5 creates a new MQTT output type that uses version 5 of the MQTT
+// protocol.
+func NewMQTTV5(
+	conf MQTTConfig,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (*MQTTV5, error) {
+	m := &MQTTV5{
+		log:   log,
+		stats: stats,
+		conf:  conf,
+	}
+
+	var err error
+	if m.connectTimeout, err = time.ParseDuration(conf.ConnectTimeout); err != nil {
+		return nil, fmt.Errorf("unable to parse connect timeout duration string: %w", err)
+	}
+	if m.writeTimeout, err = time.ParseDuration(conf.WriteTimeout); err != nil {
+		return nil, fmt.Errorf("unable to parse write timeout duration string: %w", err)
+	}
+	if conf.MessageExpiry != "" {
+		var expiry time.Duration
+		if expiry, err = time.ParseDuration(conf.MessageExpiry); err != nil {
+			return nil, fmt.Errorf("unable to parse message expiry duration string: %w", err)
+		}
+		if expiry < time.Second || expiry.Seconds() > math.MaxUint32 {
+			return nil, fmt.Errorf("message expiry must be between one second and %v seconds", uint32(math.MaxUint32))
+		}
+		expirySeconds := uint32(expiry.Seconds())
+		m.messageExpiry = &expirySeconds
+	}
+	if conf.TopicAliasMaximum < 0 || conf.TopicAliasMaximum > math.MaxUint16 {
+		return nil, fmt.Errorf("topic_alias_maximum must be between 0 and %v", math.MaxUint16)
+	}
+
+	if m.topic, err = interop.NewBloblangField(mgr, conf.Topic); err != nil {
+		return nil, fmt.Errorf("failed to parse topic expression: %v", err)
+	}
+	if conf.RetainedInterpolated != "" {
+		if m.retained, err = interop.NewBloblangField(mgr, conf.RetainedInterpolated); err != nil {
+			return nil, fmt.Errorf("failed to parse retained expression: %v", err)
+		}
+	}
+	if conf.ResponseTopic != "" {
+		if m.responseTopic, err = interop.NewBloblangField(mgr, conf.ResponseTopic); err != nil {
+			return nil, fmt.Errorf("failed to parse response topic expression: %v", err)
+		}
+	}
+	if conf.CorrelationData != "" {
+		if m.correlationData, err = interop.NewBloblangField(mgr, conf.CorrelationData); err != nil {
+			return nil, fmt.Errorf("failed to parse correlation data expression: %v", err)
+		}
+	}
+	if m.userProps, err = conf.UserProperties.CreateFilter(); err != nil {
+		return nil, fmt.Errorf("failed to construct user properties filter: %w", err)
+	}
+
+	switch m.conf.DynamicClientIDSuffix {
+	case "nanoid":
+		nid, err := gonanoid.New()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate nanoid: %w", err)
+		}
+		m.conf.ClientID += nid
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown dynamic_client_id_suffix: %v", m.conf.DynamicClientIDSuffix)
+	}
+
+	if err := m.conf.Will.Validate(); err != nil {
+		return nil, err
+	}
+
+	if m.conf.TLS.Enabled {
+		if m.tlsConf, err = m.conf.TLS.Get(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, u := range conf.URLs {
+		for _, splitURL := range strings.Split(u, ",") {
+			if len(splitURL) > 0 {
+				m.urls = append(m.urls, splitURL)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// mqttV5TopicAliases assigns topic aliases to topics for the lifetime of a
+// connection. An alias is only used in place of its topic once a message that
+// establishes the alias has been written, as messages written in parallel may
+// otherwise reach the broker before the alias is known.
+type mqttV5TopicAliases struct {
+	mut         sync.Mutex
+	max         uint16
+	aliases     map[string]uint16
+	established map[uint16]bool
+}
+
+func newMQTTV5TopicAliases(max uint16) *mqttV5TopicAliases {
+	return &mqttV5TopicAliases{
+		max:         max,
+		aliases:     map[string]uint16{},
+		established: map[uint16]bool{},
+	}
+}
+
+// get returns the alias of a topic, assigning one if there are aliases
+// remaining, and whether the topic can be omitted from a message. An alias of
+// zero means the topic has no alias.
+func (a *mqttV5TopicAliases) get(topic string) (alias uint16, omitTopic bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if alias, exists := a.aliases[topic]; exists {
+		return alias, a.established[alias]
+	}
+	if len(a.aliases) >= int(a.max) {
+		return 0, false
+	}
+	alias = uint16(len(a.aliases) + 1)
+	a.aliases[topic] = alias
+	return alias, false
+}
+
+func (a *mqttV5TopicAliases) setEstablished(alias uint16) {
+	a.mut.Lock()
+	a.established[alias] = true
+	a.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext establishes a connection to an MQTT server.
+func (m *MQTTV5) ConnectWithContext(ctx context.Context) error {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+
+	if m.client != nil {
+		return nil
+	}
+
+	connectCtx, done := context.WithTimeout(ctx, m.connectTimeout)
+	defer done()
+
+	conn, _, err := mqttconf.DialV5(connectCtx, m.urls, m.tlsConf)
+	if err != nil {
+		return err
+	}
+
+	var client *paho.Client
+	client = paho.NewClient(paho.ClientConfig{
+		ClientID:      m.conf.ClientID,
+		Conn:          conn,
+		PacketTimeout: m.writeTimeout,
+		OnServerDisconnect: func(d *paho.Disconnect) {
+			reason := strconv.Itoa(int(d.ReasonCode))
+			if d.Properties != nil && d.Properties.ReasonString != "" {
+				reason = d.Properties.ReasonString
+			}
+			m.log.Errorf("Connection closed by server due to: %v\n", reason)
+			m.dropClient(client)
+		},
+		OnClientError: func(err error) {
+			m.log.Errorf("Connection lost due to: %v\n", err)
+			m.dropClient(client)
+		},
+	})
+
+	connectPacket := &paho.Connect{
+		ClientID:    m.conf.ClientID,
+		KeepAlive:   uint16(m.conf.KeepAlive),
+		CleanStart:  true,
+		WillMessage: m.conf.Will.V5Message(),
+	}
+	if m.conf.User != "" {
+		connectPacket.Username = m.conf.User
+		connectPacket.UsernameFlag = true
+	}
+	if m.conf.Password != "" {
+		connectPacket.Password = []byte(m.conf.Password)
+		connectPacket.PasswordFlag = true
+	}
+
+	connack, err := client.Connect(connectCtx, connectPacket)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	// The number of aliases is limited by both the config and the broker,
+	// which doesn't accept aliases unless it specifies a maximum.
+	aliasMax := uint16(m.conf.TopicAliasMaximum)
+	if connack.Properties == nil || connack.Properties.TopicAliasMaximum == nil {
+		aliasMax = 0
+	} else if brokerMax := *connack.Properties.TopicAliasMaximum; brokerMax < aliasMax {
+		aliasMax = brokerMax
+	}
+
+	m.client = client
+	m.aliases = newMQTTV5TopicAliases(aliasMax)
+	return nil
+}
+
+// Connect establishes a connection to an MQTT server.
+func (m *MQTTV5) Connect() error {
+	return m.ConnectWithContext(context.Background())
+}
+
+func (m *MQTTV5) dropClient(client *paho.Client) {
+	m.connMut.Lock()
+	if m.client == client {
+		m.client = nil
+		m.aliases = nil
+	}
+	m.connMut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// WriteWithContext attempts to write a message by pushing it to an MQTT broker.
+func (m *MQTTV5) WriteWithContext(ctx context.Context, msg types.Message) error {
+	m.connMut.RLock()
+	client, aliases := m.client, m.aliases
+	m.connMut.RUnlock()
+
+	if client == nil {
+		return types.ErrNotConnected
+	}
+
+	return IterateBatchedSend(msg, func(i int, p types.Part) error {
+		retained := m.conf.Retained
+		if m.retained != nil {
+			var parseErr error
+			retained, parseErr = strconv.ParseBool(m.retained.String(i, msg))
+			if parseErr != nil {
+				m.log.Errorf("Error parsing boolean value from retained flag: %v \n", parseErr)
+			}
+		}
+
+		pub := &paho.Publish{
+			QoS:     m.conf.QoS,
+			Retain:  retained,
+			Topic:   m.topic.String(i, msg),
+			Payload: p.Get(),
+			Properties: &paho.PublishProperties{
+				MessageExpiry: m.messageExpiry,
+			},
+		}
+		if m.responseTopic != nil {
+			pub.Properties.ResponseTopic = m.responseTopic.String(i, msg)
+		}
+		if m.correlationData != nil {
+			pub.Properties.CorrelationData = m.correlationData.Bytes(i, msg)
+		}
+		_ = m.userProps.Iter(p.Metadata(), func(k, v string) error {
+			pub.Properties.User.Add(k, v)
+			return nil
+		})
+
+		alias, omitTopic := aliases.get(pub.Topic)
+		if alias > 0 {
+			pub.Properties.TopicAlias = &alias
+			if omitTopic {
+				pub.Topic = ""
+			}
+		}
+
+		writeCtx, done := context.WithTimeout(ctx, m.writeTimeout)
+		defer done()
+
+		if _, err := client.Publish(writeCtx, pub); err != nil {
+			return err
+		}
+		if alias > 0 && !omitTopic {
+			aliases.setEstablished(alias)
+		}
+		return nil
+	})
+}
+
+// Write attempts to write a message by pushing it to an MQTT broker.
+func (m *MQTTV5) Write(msg types.Message) error {
+	return m.WriteWithContext(context.Background(), msg)
+}
+
+// CloseAsync shuts down the MQTT output and stops processing messages.
+func (m *MQTTV5) CloseAsync() {
+	go func() {
+		m.connMut.Lock()
+		if m.client != nil {
+			_ = m.client.Disconnect(&paho.Disconnect{})
+			m.client = nil
+			m.aliases = nil
+		}
+		m.connMut.Unlock()
+	}()
+}
+
+// WaitForClose blocks until the MQTT output has closed down.
+func (m *MQTTV5) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
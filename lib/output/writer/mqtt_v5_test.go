@@ -0,0 +1,151 @@
+package writer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMQTTV5Writer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	publishChan := make(chan *packets.Publish, 3)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		aliasMax := uint16(5)
+		for {
+			cp, err := packets.ReadPacket(conn)
+			if err != nil {
+				return
+			}
+			switch p := cp.Content.(type) {
+			case *packets.Connect:
+				_, err = (&packets.Connack{
+					Properties: &packets.Properties{TopicAliasMaximum: &aliasMax},
+				}).WriteTo(conn)
+			case *packets.Publish:
+				publishChan <- p
+				_, err = (&packets.Puback{
+					PacketID:   p.PacketID,
+					Properties: &packets.Properties{},
+				}).WriteTo(conn)
+			case *packets.Pingreq:
+				_, err = (&packets.Pingresp{}).WriteTo(conn)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	conf := NewMQTTConfig()
+	conf.URLs = []string{"tcp://" + ln.Addr().String()}
+	conf.Topic = `${! meta("topic") }`
+	conf.UserProperties.IncludePrefixes = []string{"device"}
+	conf.ResponseTopic = "foo/replies"
+	conf.CorrelationData = `${! meta("id") }`
+	conf.MessageExpiry = "1m"
+	conf.TopicAliasMaximum = 10
+
+	m, err := NewMQTTV5(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, m.ConnectWithContext(ctx))
+	defer func() {
+		m.CloseAsync()
+		require.NoError(t, m.WaitForClose(time.Second))
+	}()
+
+	for i, topic := range []string{"foo/bar", "foo/bar", "foo/baz"} {
+		msg := message.New([][]byte{[]byte("hello world")})
+		msg.Get(0).Metadata().
+			Set("topic", topic).
+			Set("id", string(rune('a'+i))).
+			Set("device", "d1").
+			Set("other", "nope")
+		require.NoError(t, m.WriteWithContext(ctx, msg))
+	}
+
+	var pubs []*packets.Publish
+	for i := 0; i < 3; i++ {
+		select {
+		case p := <-publishChan:
+			pubs = append(pubs, p)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+
+	for i, p := range pubs {
+		assert.Equal(t, "hello world", string(p.Payload))
+		assert.Equal(t, byte(1), p.QoS)
+		assert.Equal(t, "foo/replies", p.Properties.ResponseTopic)
+		assert.Equal(t, string(rune('a'+i)), string(p.Properties.CorrelationData))
+		require.NotNil(t, p.Properties.MessageExpiry)
+		assert.Equal(t, uint32(60), *p.Properties.MessageExpiry)
+		assert.Equal(t, []packets.User{{Key: "device", Value: "d1"}}, p.Properties.User)
+		require.NotNil(t, p.Properties.TopicAlias)
+	}
+
+	assert.Equal(t, "foo/bar", pubs[0].Topic)
+	assert.Equal(t, uint16(1), *pubs[0].Properties.TopicAlias)
+	assert.Equal(t, "", pubs[1].Topic)
+	assert.Equal(t, uint16(1), *pubs[1].Properties.TopicAlias)
+	assert.Equal(t, "foo/baz", pubs[2].Topic)
+	assert.Equal(t, uint16(2), *pubs[2].Properties.TopicAlias)
+}
+
+func TestMQTTV5TopicAliases(t *testing.T) {
+	aliases := newMQTTV5TopicAliases(2)
+
+	alias, omit := aliases.get("foo")
+	assert.Equal(t, uint16(1), alias)
+	assert.False(t, omit)
+
+	// Not yet established, therefore the topic is still sent.
+	alias, omit = aliases.get("foo")
+	assert.Equal(t, uint16(1), alias)
+	assert.False(t, omit)
+
+	aliases.setEstablished(1)
+	alias, omit = aliases.get("foo")
+	assert.Equal(t, uint16(1), alias)
+	assert.True(t, omit)
+
+	alias, omit = aliases.get("bar")
+	assert.Equal(t, uint16(2), alias)
+	assert.False(t, omit)
+
+	// No aliases remaining.
+	alias, omit = aliases.get("baz")
+	assert.Equal(t, uint16(0), alias)
+	assert.False(t, omit)
+}
+
+func TestMQTTV5WriterBadConfig(t *testing.T) {
+	conf := NewMQTTConfig()
+	conf.MessageExpiry = "10ms"
+
+	_, err := NewMQTTV5(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "message expiry")
+}
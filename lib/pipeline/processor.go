@@ -5,6 +5,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	iprocessor "github.com/Jeffail/benthos/v3/internal/component/processor"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/processor"
@@ -66,6 +67,13 @@ func (p *Processor) loop() {
 		close(p.closed)
 	}()
 
+	var flushChan <-chan time.Time
+	if interval := p.flushInterval(); interval > 0 {
+		flushTicker := time.NewTicker(interval)
+		defer flushTicker.Stop()
+		flushChan = flushTicker.C
+	}
+
 	var open bool
 	for atomic.LoadInt32(&p.running) == 1 {
 		var tran types.Transaction
@@ -74,6 +82,9 @@ func (p *Processor) loop() {
 			if !open {
 				return
 			}
+		case <-flushChan:
+			p.flush()
+			continue
 		case <-p.closeChan:
 			return
 		}
@@ -104,6 +115,44 @@ func (p *Processor) loop() {
 	}
 }
 
+// flushInterval returns the shortest flush interval of the processors of this
+// pipeline, or zero if none of them need flushing.
+func (p *Processor) flushInterval() time.Duration {
+	var interval time.Duration
+	for _, proc := range p.msgProcessors {
+		f, ok := proc.(iprocessor.Flusher)
+		if !ok {
+			continue
+		}
+		if i := f.FlushInterval(); i > 0 && (interval == 0 || i < interval) {
+			interval = i
+		}
+	}
+	return interval
+}
+
+// flush obtains the messages due to be emitted by each processor that supports
+// flushing and sends them through the processors that follow it. These
+// messages do not originate from an input and therefore their acknowledgements
+// are discarded.
+func (p *Processor) flush() {
+	for i, proc := range p.msgProcessors {
+		f, ok := proc.(iprocessor.Flusher)
+		if !ok || f.FlushInterval() <= 0 {
+			continue
+		}
+		msgs := f.Flush()
+		if len(msgs) == 0 {
+			continue
+		}
+		resultMsgs, _ := processor.ExecuteAll(p.msgProcessors[i+1:], msgs...)
+		if len(resultMsgs) == 0 {
+			continue
+		}
+		p.dispatchMessages(resultMsgs, make(chan types.Response, 1))
+	}
+}
+
 // dispatchMessages attempts to send a multiple messages results of processors
 // over the shared messages channel. This send is retried until success.
 func (p *Processor) dispatchMessages(msgs []types.Message, ogResChan chan<- types.Response) {
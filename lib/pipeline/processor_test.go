@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -403,3 +404,57 @@ func TestProcessorMultiMsgsOddSync(t *testing.T) {
 		t.Error("Expected mockproc to have waited for close")
 	}
 }
+
+type mockFlushProcessor struct {
+	mockMsgProcessor
+	flushed int32
+}
+
+func (m *mockFlushProcessor) FlushInterval() time.Duration {
+	return time.Millisecond
+}
+
+func (m *mockFlushProcessor) Flush() []types.Message {
+	if !atomic.CompareAndSwapInt32(&m.flushed, 0, 1) {
+		return nil
+	}
+	return []types.Message{message.New([][]byte{[]byte("flushed")})}
+}
+
+func TestProcessorPipelineFlush(t *testing.T) {
+	mockProc := &mockFlushProcessor{}
+	mockProc.dropChan = make(chan bool)
+
+	proc := NewProcessor(
+		log.Noop(),
+		metrics.Noop(),
+		mockProc,
+	)
+
+	tChan := make(chan types.Transaction)
+	if err := proc.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case procT, open := <-proc.TransactionChan():
+		if !open {
+			t.Fatal("Closed early")
+		}
+		if exp, act := [][]byte{[]byte("flushed")}, message.GetAllBytes(procT.Payload); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong message received: %s != %s", act, exp)
+		}
+		select {
+		case procT.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
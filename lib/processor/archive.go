@@ -12,6 +12,7 @@ import (
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
@@ -273,6 +274,7 @@ type Archive struct {
 
 	log   log.Modular
 	stats metrics.Type
+	label string
 }
 
 // NewArchive returns a Archive processor.
@@ -294,6 +296,7 @@ func NewArchive(
 		archive: archiver,
 		log:     log,
 		stats:   stats,
+		label:   lineage.ComponentLabel(mgr, TypeArchive),
 
 		mCount:     stats.GetCounter("count"),
 		mErr:       stats.GetCounter("error"),
@@ -372,6 +375,7 @@ func (d *Archive) ProcessMessage(msg types.Message) ([]types.Message, types.Resp
 	} else {
 		d.mSucc.Incr(1)
 		newPart = batch.WithCollapsedCount(newPart, msg.Len())
+		newPart = lineage.DeriveFromBatch(d.label, newPart, msg)
 		newMsg.SetAll([]types.Part{newPart})
 	}
 	for _, s := range spans {
@@ -4,17 +4,23 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
+
 	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
 	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/retries"
 )
 
 //------------------------------------------------------------------------------
@@ -52,6 +58,35 @@ root.bar.id = this.user.id`,
 	this
 }`,
 	).HasDefault(""),
+	docs.FieldString(
+		"timeout",
+		"An optional maximum period to wait for the child processors of this branch to complete, after which the branch fails. The child processors are not interrupted, but their results are discarded. In order to bound the resources held by child processors that never complete, the branch fails immediately without executing its child processors whilst 10 previous executions that timed out are still running.",
+		"5s", "1m",
+	).Advanced().HasDefault("").AtVersion("3.65.0"),
+}.Merge(branchRetryFields()).Merge(docs.FieldSpecs{
+	docs.FieldString(
+		"on_error",
+		"Determines how a failure of this branch affects the remaining branches of a [`workflow`](/docs/components/processors/workflow). This field has no effect when the branch is used outside of a workflow.",
+	).HasAnnotatedOptions(
+		"fail", "The failure is recorded and, when the workflow has no `meta_path`, the message is flagged as having failed. Dependent branches are still executed.",
+		"skip", "The failure is recorded as with `fail`, and all branches that depend on the result of this branch are skipped for the failed messages.",
+		"continue", "The failure is recorded within the `meta_path` structure but the message is not flagged as having failed.",
+	).Advanced().HasDefault("fail").AtVersion("3.65.0"),
+})
+
+func branchRetryFields() docs.FieldSpecs {
+	specs := docs.FieldSpecs{
+		docs.FieldInt(
+			"max_retries",
+			"The maximum number of times to retry the child processors of this branch for messages that fail. If set to zero failed messages are not retried.",
+		).Advanced().HasDefault(0).AtVersion("3.65.0"),
+	}
+	for _, spec := range retries.FieldSpecs() {
+		if spec.Name == "backoff" {
+			specs = append(specs, spec.ChildDefaultAndTypesFromStruct(retries.NewConfig().Backoff).AtVersion("3.65.0"))
+		}
+	}
+	return specs
 }
 
 func init() {
@@ -87,6 +122,15 @@ the message will remain unchanged. Under any of these conditions standard
 [error handling methods](/docs/configuration/error_handling) can be used in
 order to filter, DLQ or recover the failed messages.
 
+### Timeouts and Retries
+
+The child processors of a branch can be given a maximum period in which to
+complete with the field ` + "`timeout`" + `, and messages that fail can be
+retried with an exponential backoff by setting ` + "`max_retries`" + ` to a
+value greater than zero. Only the messages that failed are retried, unless the
+child processors fail (or time out) for the entire batch, in which case the
+entire batch is retried.
+
 ### Conditional Branching
 
 If the root of your request map is set to ` + "`deleted()`" + ` then the branch
@@ -186,20 +230,32 @@ pipeline:
 
 // BranchConfig contains configuration fields for the Branch processor.
 type BranchConfig struct {
-	RequestMap string   `json:"request_map" yaml:"request_map"`
-	Processors []Config `json:"processors" yaml:"processors"`
-	ResultMap  string   `json:"result_map" yaml:"result_map"`
+	RequestMap  string         `json:"request_map" yaml:"request_map"`
+	Processors  []Config       `json:"processors" yaml:"processors"`
+	ResultMap   string         `json:"result_map" yaml:"result_map"`
+	Timeout     string         `json:"timeout" yaml:"timeout"`
+	RetryConfig retries.Config `json:",inline" yaml:",inline"`
+	OnError     string         `json:"on_error" yaml:"on_error"`
 }
 
 // NewBranchConfig returns a BranchConfig with default values.
 func NewBranchConfig() BranchConfig {
 	return BranchConfig{
-		RequestMap: "",
-		Processors: []Config{},
-		ResultMap:  "",
+		RequestMap:  "",
+		Processors:  []Config{},
+		ResultMap:   "",
+		Timeout:     "",
+		RetryConfig: retries.NewConfig(),
+		OnError:     branchOnErrorFail,
 	}
 }
 
+const (
+	branchOnErrorFail     = "fail"
+	branchOnErrorSkip     = "skip"
+	branchOnErrorContinue = "continue"
+)
+
 // Sanitise the configuration into a minimal structure that can be printed
 // without changing the intent.
 func (b BranchConfig) Sanitise() (map[string]interface{}, error) {
@@ -214,6 +270,14 @@ func (b BranchConfig) Sanitise() (map[string]interface{}, error) {
 		"request_map": b.RequestMap,
 		"processors":  procConfs,
 		"result_map":  b.ResultMap,
+		"timeout":     b.Timeout,
+		"max_retries": b.RetryConfig.MaxRetries,
+		"backoff": map[string]interface{}{
+			"initial_interval": b.RetryConfig.Backoff.InitialInterval,
+			"max_interval":     b.RetryConfig.Backoff.MaxInterval,
+			"max_elapsed_time": b.RetryConfig.Backoff.MaxElapsedTime,
+		},
+		"on_error": b.OnError,
 	}, nil
 }
 
@@ -225,11 +289,23 @@ func (b BranchConfig) Sanitise() (map[string]interface{}, error) {
 type Branch struct {
 	log   log.Modular
 	stats metrics.Type
+	label string
 
 	requestMap *mapping.Executor
 	resultMap  *mapping.Executor
 	children   []types.Processor
 
+	timeout   time.Duration
+	retryCtor func() backoff.BackOff
+	onError   string
+
+	// The number of child processor executions that timed out and are still
+	// running.
+	abandoned int64
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+
 	// Metrics
 	mCount     metrics.StatCounter
 	mErr       metrics.StatCounter
@@ -238,6 +314,7 @@ type Branch struct {
 	mErrAlign  metrics.StatCounter
 	mErrReq    metrics.StatCounter
 	mErrRes    metrics.StatCounter
+	mRetry     metrics.StatCounter
 	mSent      metrics.StatCounter
 	mBatchSent metrics.StatCounter
 }
@@ -269,6 +346,9 @@ func newBranch(
 		children: children,
 		log:      log,
 		stats:    stats,
+		label:    lineage.ComponentLabel(mgr, TypeBranch),
+
+		closeChan: make(chan struct{}),
 
 		mCount:     stats.GetCounter("count"),
 		mErr:       stats.GetCounter("error"),
@@ -277,11 +357,31 @@ func newBranch(
 		mErrAlign:  stats.GetCounter("error_result_alignment"),
 		mErrReq:    stats.GetCounter("error_request_map"),
 		mErrRes:    stats.GetCounter("error_result_map"),
+		mRetry:     stats.GetCounter("retry"),
 		mSent:      stats.GetCounter("sent"),
 		mBatchSent: stats.GetCounter("batch.sent"),
 	}
 
 	var err error
+	if conf.Timeout != "" {
+		if b.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout: %w", err)
+		}
+	}
+	if conf.RetryConfig.MaxRetries > 0 {
+		if b.retryCtor, err = conf.RetryConfig.GetCtor(); err != nil {
+			return nil, err
+		}
+	}
+	switch conf.OnError {
+	case branchOnErrorFail, branchOnErrorSkip, branchOnErrorContinue:
+		b.onError = conf.OnError
+	case "":
+		b.onError = branchOnErrorFail
+	default:
+		return nil, fmt.Errorf("on_error value not recognised: %v", conf.OnError)
+	}
+
 	if len(conf.RequestMap) > 0 {
 		if b.requestMap, err = interop.NewBloblangMapping(mgr, conf.RequestMap); err != nil {
 			return nil, fmt.Errorf("failed to parse request mapping: %w", err)
@@ -455,28 +555,19 @@ func (b *Branch) createResult(parts []types.Part, referenceMsg types.Message) ([
 				// Skip if the message part is deleted.
 				skipped = append(skipped, i)
 			} else {
-				newParts = append(newParts, newPart)
+				newParts = append(newParts, lineage.Derive(b.label, newPart, parts[i]))
 			}
 		} else {
-			newParts = append(newParts, parts[i])
+			newParts = append(newParts, lineage.Derive(b.label, parts[i], parts[i]))
 		}
 	}
 	parts = newParts
 
 	// Execute child processors
-	var procResults []types.Message
+	var procResults []types.Part
 	var err error
 	if len(parts) > 0 {
-		var res types.Response
-		msg := message.New(nil)
-		msg.SetAll(parts)
-		if procResults, res = ExecuteAll(b.children, msg); res != nil && res.Error() != nil {
-			err = fmt.Errorf("child processors failed: %v", res.Error())
-		}
-		if len(procResults) == 0 {
-			err = errors.New("child processors resulted in zero messages")
-		}
-		if err != nil {
+		if procResults, err = b.executeChildren(parts); err != nil {
 			b.mErrProc.Incr(1)
 			b.mErr.Incr(1)
 			b.log.Errorf("Child processors failed: %v\n", err)
@@ -506,6 +597,154 @@ func (b *Branch) createResult(parts []types.Part, referenceMsg types.Message) ([
 	return alignedResult, mapErrs, nil
 }
 
+// The maximum number of child processor executions of a branch that timed out
+// and are still running before further executions fail immediately.
+const branchMaxAbandoned = 10
+
+// executeChildOnce applies the child processors to a batch of parts, failing if
+// they do not complete within the timeout of the branch.
+func (b *Branch) executeChildOnce(parts []types.Part) ([]types.Part, error) {
+	exec := func() ([]types.Part, error) {
+		msg := message.New(nil)
+		msg.SetAll(parts)
+
+		procResults, res := ExecuteAll(b.children, msg)
+		if res != nil && res.Error() != nil {
+			return nil, fmt.Errorf("child processors failed: %v", res.Error())
+		}
+		if len(procResults) == 0 {
+			return nil, errors.New("child processors resulted in zero messages")
+		}
+
+		var resParts []types.Part
+		for _, m := range procResults {
+			m.Iter(func(i int, p types.Part) error {
+				resParts = append(resParts, p)
+				return nil
+			})
+		}
+		return resParts, nil
+	}
+	if b.timeout <= 0 {
+		return exec()
+	}
+
+	// Child processors can't be interrupted, and therefore executions that
+	// time out are left running. In order to bound the number of executions
+	// left running we fail immediately once the limit is reached.
+	if n := atomic.LoadInt64(&b.abandoned); n >= branchMaxAbandoned {
+		return nil, fmt.Errorf("child processors of %v previous executions that timed out are still running", n)
+	}
+
+	type execResult struct {
+		parts []types.Part
+		err   error
+	}
+	const (
+		execRunning int32 = iota
+		execFinished
+		execAbandoned
+	)
+	var state int32
+	resChan := make(chan execResult, 1)
+	go func() {
+		resParts, err := exec()
+		resChan <- execResult{resParts, err}
+		if !atomic.CompareAndSwapInt32(&state, execRunning, execFinished) {
+			atomic.AddInt64(&b.abandoned, -1)
+		}
+	}()
+
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-resChan:
+		return res.parts, res.err
+	case <-timer.C:
+	}
+
+	atomic.AddInt64(&b.abandoned, 1)
+	if !atomic.CompareAndSwapInt32(&state, execRunning, execAbandoned) {
+		// The execution finished in the meantime.
+		atomic.AddInt64(&b.abandoned, -1)
+		res := <-resChan
+		return res.parts, res.err
+	}
+	return nil, fmt.Errorf("child processors timed out after %v", b.timeout)
+}
+
+// executeChildren applies the child processors to a batch of parts, retrying
+// failed parts according to the retry configuration of the branch.
+func (b *Branch) executeChildren(parts []types.Part) ([]types.Part, error) {
+	if b.retryCtor == nil {
+		return b.executeChildOnce(parts)
+	}
+	boff := b.retryCtor()
+
+	results := make([]types.Part, len(parts))
+	pending := make([]int, len(parts))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for attempt := 0; ; attempt++ {
+		reqParts := make([]types.Part, len(pending))
+		for i, index := range pending {
+			// Copy the request so that the original is preserved for retries.
+			reqParts[i] = parts[index].Copy()
+		}
+
+		resParts, err := b.executeChildOnce(reqParts)
+		if err == nil && len(resParts) != len(reqParts) {
+			if attempt == 0 {
+				// Let the result alignment report the mismatch.
+				return resParts, nil
+			}
+			err = fmt.Errorf(
+				"message count from branch processors does not match request on retry, started with %v messages, finished with %v",
+				len(reqParts), len(resParts),
+			)
+		}
+
+		var failed []int
+		if err != nil {
+			failed = pending
+		} else {
+			for i, index := range pending {
+				results[index] = resParts[i]
+				if len(GetFail(resParts[i])) > 0 {
+					failed = append(failed, index)
+				}
+			}
+		}
+		if len(failed) == 0 {
+			return results, nil
+		}
+
+		wait := boff.NextBackOff()
+		if wait == backoff.Stop {
+			if err != nil {
+				return nil, err
+			}
+			return results, nil
+		}
+
+		b.log.Debugf("Retrying %v messages after branch failure\n", len(failed))
+		b.mRetry.Incr(1)
+		pending = failed
+
+		select {
+		case <-time.After(wait):
+		case <-b.closeChan:
+			if err != nil {
+				return nil, err
+			}
+			return results, nil
+		}
+	}
+}
+
 // overlayResult attempts to merge the result of a process_map with the original
 // payload as per the map specified in the postmap and postmap_optional fields.
 func (b *Branch) overlayResult(payload types.Message, results []types.Part) ([]branchMapError, error) {
@@ -545,7 +784,7 @@ func (b *Branch) overlayResult(payload types.Message, results []types.Part) ([]b
 
 			// TODO: Allow filtering here?
 			if newPart != nil {
-				parts[i] = newPart
+				parts[i] = lineage.Derive(b.label, newPart, payload.Get(i), result)
 			}
 		}
 
@@ -557,15 +796,7 @@ func (b *Branch) overlayResult(payload types.Message, results []types.Part) ([]b
 	return failed, nil
 }
 
-func alignBranchResult(length int, skipped, failed []int, result []types.Message) ([]types.Part, error) {
-	resMsgParts := []types.Part{}
-	for _, m := range result {
-		m.Iter(func(i int, p types.Part) error {
-			resMsgParts = append(resMsgParts, p)
-			return nil
-		})
-	}
-
+func alignBranchResult(length int, skipped, failed []int, resMsgParts []types.Part) ([]types.Part, error) {
 	skippedOrFailed := make([]int, len(skipped)+len(failed))
 	i := copy(skippedOrFailed, skipped)
 	copy(skippedOrFailed[i:], failed)
@@ -600,6 +831,9 @@ func alignBranchResult(length int, skipped, failed []int, result []types.Message
 
 // CloseAsync shuts down the processor and stops processing requests.
 func (b *Branch) CloseAsync() {
+	b.closeOnce.Do(func() {
+		close(b.closeChan)
+	})
 	for _, child := range b.children {
 		child.CloseAsync()
 	}
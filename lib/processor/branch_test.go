@@ -1,12 +1,14 @@
 package processor
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -328,3 +330,172 @@ func TestBranchBasic(t *testing.T) {
 		})
 	}
 }
+
+func TestBranchRetries(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeBloblang
+	procConf.Bloblang = `root = if this.id == 1 && count("branch_retries_test") < 3 {
+  throw("not yet")
+} else {
+  this
+}`
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+	conf.Branch.ResultMap = `root.result = this.id`
+	conf.Branch.RetryConfig.MaxRetries = 5
+	conf.Branch.RetryConfig.Backoff.InitialInterval = "1ms"
+	conf.Branch.RetryConfig.Backoff.MaxInterval = "1ms"
+
+	proc, err := NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	outMsgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"id":0}`),
+		[]byte(`{"id":1}`),
+	}))
+	require.Nil(t, res)
+	require.Len(t, outMsgs, 1)
+	require.Equal(t, 2, outMsgs[0].Len())
+
+	assert.Equal(t, `{"id":0,"result":0}`, string(outMsgs[0].Get(0).Get()))
+	assert.Equal(t, `{"id":1,"result":1}`, string(outMsgs[0].Get(1).Get()))
+	assert.Empty(t, GetFail(outMsgs[0].Get(0)))
+	assert.Empty(t, GetFail(outMsgs[0].Get(1)))
+
+	proc.CloseAsync()
+	assert.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestBranchRetriesExhausted(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeBloblang
+	procConf.Bloblang = `root = throw("nope")`
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+	conf.Branch.ResultMap = `root.result = this`
+	conf.Branch.RetryConfig.MaxRetries = 2
+	conf.Branch.RetryConfig.Backoff.InitialInterval = "1ms"
+	conf.Branch.RetryConfig.Backoff.MaxInterval = "1ms"
+
+	proc, err := NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	outMsgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`{"id":0}`)}))
+	require.Nil(t, res)
+	require.Len(t, outMsgs, 1)
+
+	assert.Equal(t, `{"id":0}`, string(outMsgs[0].Get(0).Get()))
+	assert.Contains(t, GetFail(outMsgs[0].Get(0)), "nope")
+
+	proc.CloseAsync()
+	assert.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestBranchTimeout(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeSleep
+	procConf.Sleep.Duration = "500ms"
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+	conf.Branch.ResultMap = `root.result = this`
+	conf.Branch.Timeout = "10ms"
+
+	proc, err := NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	outMsgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`{"id":0}`)}))
+	require.Nil(t, res)
+	require.Len(t, outMsgs, 1)
+
+	assert.Equal(t, `{"id":0}`, string(outMsgs[0].Get(0).Get()))
+	assert.Contains(t, GetFail(outMsgs[0].Get(0)), "child processors timed out after 10ms")
+
+	proc.CloseAsync()
+	assert.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestBranchTimeoutAbandonedLimit(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeSleep
+	procConf.Sleep.Duration = "500ms"
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+	conf.Branch.Timeout = "1ms"
+
+	proc, err := newBranch(conf.Branch, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	for i := 0; i < branchMaxAbandoned; i++ {
+		_, err = proc.executeChildOnce([]types.Part{message.NewPart([]byte(`{}`))})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out")
+	}
+
+	_, err = proc.executeChildOnce([]types.Part{message.NewPart([]byte(`{}`))})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "previous executions that timed out are still running")
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&proc.abandoned) == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	proc.CloseAsync()
+	assert.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestBranchRetriesClose(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeBloblang
+	procConf.Bloblang = `root = throw("nope")`
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+	conf.Branch.ResultMap = `root.result = this`
+	conf.Branch.RetryConfig.MaxRetries = 2
+	conf.Branch.RetryConfig.Backoff.InitialInterval = "1h"
+	conf.Branch.RetryConfig.Backoff.MaxInterval = "1h"
+
+	proc, err := NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	go func() {
+		<-time.After(time.Millisecond * 50)
+		proc.CloseAsync()
+	}()
+
+	outMsgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`{"id":0}`)}))
+	require.Nil(t, res)
+	require.Len(t, outMsgs, 1)
+	assert.Contains(t, GetFail(outMsgs[0].Get(0)), "nope")
+
+	assert.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestBranchBadConfig(t *testing.T) {
+	procConf := NewConfig()
+	procConf.Type = TypeBloblang
+	procConf.Bloblang = `root = this`
+
+	conf := NewConfig()
+	conf.Type = TypeBranch
+	conf.Branch.Processors = append(conf.Branch.Processors, procConf)
+
+	conf.Branch.OnError = "nope"
+	_, err := NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.EqualError(t, err, "on_error value not recognised: nope")
+
+	conf.Branch.OnError = "skip"
+	conf.Branch.Timeout = "nope"
+	_, err = NewBranch(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse timeout")
+}
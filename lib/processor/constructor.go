@@ -160,6 +160,7 @@ const (
 	TypeHashSample   = "hash_sample"
 	TypeHTTP         = "http"
 	TypeInsertPart   = "insert_part"
+	TypeJavaScript   = "javascript"
 	TypeJMESPath     = "jmespath"
 	TypeJQ           = "jq"
 	TypeJSON         = "json"
@@ -233,6 +234,7 @@ type Config struct {
 	HashSample   HashSampleConfig   `json:"hash_sample" yaml:"hash_sample"`
 	HTTP         HTTPConfig         `json:"http" yaml:"http"`
 	InsertPart   InsertPartConfig   `json:"insert_part" yaml:"insert_part"`
+	JavaScript   JavaScriptConfig   `json:"javascript" yaml:"javascript"`
 	JMESPath     JMESPathConfig     `json:"jmespath" yaml:"jmespath"`
 	JQ           JQConfig           `json:"jq" yaml:"jq"`
 	JSON         JSONConfig         `json:"json" yaml:"json"`
@@ -306,6 +308,7 @@ func NewConfig() Config {
 		HashSample:   NewHashSampleConfig(),
 		HTTP:         NewHTTPConfig(),
 		InsertPart:   NewInsertPartConfig(),
+		JavaScript:   NewJavaScriptConfig(),
 		JMESPath:     NewJMESPathConfig(),
 		JQ:           NewJQConfig(),
 		JSON:         NewJSONConfig(),
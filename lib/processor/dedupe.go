@@ -3,8 +3,10 @@ package processor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
@@ -71,13 +73,55 @@ sink followed by a restart of the Benthos instance.
 If you intend to preserve at-least-once delivery guarantees you can avoid this
 problem by using a memory based cache. This is a compromise that can achieve
 effective deduplication but parallel deployments of the pipeline as well as
-service restarts increase the chances of duplicates passing undetected.`,
+service restarts increase the chances of duplicates passing undetected.
+
+## Probabilistic Mode
+
+Storing every key within a cache can consume large amounts of memory when
+deduplicating hundreds of millions of messages. By setting
+` + "`probabilistic.enabled` to `true`" + ` messages are instead deduplicated with
+[scalable Bloom filters](https://en.wikipedia.org/wiki/Bloom_filter) held in
+memory, which consume a small fraction of the space at the cost of a
+configurable rate of false positives, where a unique message is mistakenly
+dropped as a duplicate. Duplicates are never mistakenly allowed through.
+
+When a ` + "`probabilistic.ttl`" + ` is set the period is split into a number of time
+buckets, each with its own filter, and the filter of the oldest bucket is
+discarded as each new bucket is started.
+
+The filters can be persisted periodically to a file or a cache resource with
+the ` + "`probabilistic.persist`" + ` fields, in which case they are restored when
+the processor is started so that restarts retain recent history. Messages seen
+after the last persist are forgotten after a crash.`,
+		Examples: []docs.AnnotatedExample{
+			{
+				Title: "Probabilistic Deduplication",
+				Summary: `
+Drop messages with an ` + "`id`" + ` seen within the last 24 hours using Bloom
+filters, persisting them to disk every minute.`,
+				Config: `
+pipeline:
+  processors:
+    - dedupe:
+        key: ${! json("id") }
+        probabilistic:
+          enabled: true
+          false_positive_rate: 0.0001
+          expected_items: 100000000
+          ttl: 24h
+          persist:
+            path: /var/lib/benthos/dedupe.bin
+            interval: 1m
+`,
+			},
+		},
 		FieldSpecs: docs.FieldSpecs{
 			docs.FieldCommon("cache", "The [`cache` resource](/docs/components/caches/about) to target with this processor."),
 			docs.FieldCommon("hash", "The hash type to used.").HasOptions("none", "xxhash"),
 			docs.FieldCommon("key", "An optional key to use for deduplication (instead of the entire message contents).").IsInterpolated(),
 			docs.FieldCommon("drop_on_err", "Whether messages should be dropped when the cache returns an error."),
 			docs.FieldAdvanced("parts", "An array of message indexes within the batch to deduplicate based on. If left empty all messages are included. This field is only applicable when batching messages [at the input level](/docs/configuration/batching).").Array(),
+			docs.FieldObject("probabilistic", "Deduplicate messages using Bloom filters rather than a cache resource, as described in [probabilistic mode](#probabilistic-mode).").WithChildren(dedupeProbabilisticFields...).Advanced().AtVersion("3.65.0"),
 		},
 	}
 }
@@ -86,11 +130,12 @@ service restarts increase the chances of duplicates passing undetected.`,
 
 // DedupeConfig contains configuration fields for the Dedupe processor.
 type DedupeConfig struct {
-	Cache          string `json:"cache" yaml:"cache"`
-	HashType       string `json:"hash" yaml:"hash"`
-	Parts          []int  `json:"parts" yaml:"parts"` // message parts to hash
-	Key            string `json:"key" yaml:"key"`
-	DropOnCacheErr bool   `json:"drop_on_err" yaml:"drop_on_err"`
+	Cache          string                    `json:"cache" yaml:"cache"`
+	HashType       string                    `json:"hash" yaml:"hash"`
+	Parts          []int                     `json:"parts" yaml:"parts"` // message parts to hash
+	Key            string                    `json:"key" yaml:"key"`
+	DropOnCacheErr bool                      `json:"drop_on_err" yaml:"drop_on_err"`
+	Probabilistic  DedupeProbabilisticConfig `json:"probabilistic" yaml:"probabilistic"`
 }
 
 // NewDedupeConfig returns a DedupeConfig with default values.
@@ -101,6 +146,7 @@ func NewDedupeConfig() DedupeConfig {
 		Parts:          []int{0}, // only consider the 1st part
 		Key:            "",
 		DropOnCacheErr: true,
+		Probabilistic:  NewDedupeProbabilisticConfig(),
 	}
 }
 
@@ -160,6 +206,14 @@ type Dedupe struct {
 	cacheName  string
 	hasherFunc hasherFunc
 
+	filter          *dedupeFilter
+	persister       dedupePersister
+	persistInterval time.Duration
+
+	closeOnce  sync.Once
+	closeChan  chan struct{}
+	closedChan chan struct{}
+
 	mCount     metrics.StatCounter
 	mErrHash   metrics.StatCounter
 	mErrCache  metrics.StatCounter
@@ -183,11 +237,41 @@ func NewDedupe(
 		return nil, fmt.Errorf("failed to parse key expression: %v", err)
 	}
 
-	if err := interop.ProbeCache(context.Background(), mgr, conf.Dedupe.Cache); err != nil {
+	var filter *dedupeFilter
+	var persister dedupePersister
+	var persistInterval time.Duration
+	if pConf := conf.Dedupe.Probabilistic; pConf.Enabled {
+		if conf.Dedupe.Cache != "" {
+			return nil, errors.New("field cache cannot be set when probabilistic mode is enabled")
+		}
+		if filter, err = newDedupeFilter(pConf); err != nil {
+			return nil, err
+		}
+		if persister, err = newDedupePersister(pConf.Persist, mgr); err != nil {
+			return nil, err
+		}
+		if persister != nil {
+			if persistInterval, err = time.ParseDuration(pConf.Persist.Interval); err != nil {
+				return nil, fmt.Errorf("failed to parse persist interval: %v", err)
+			}
+			if persistInterval <= 0 {
+				return nil, errors.New("persist interval must be greater than zero")
+			}
+			data, err := persister.load()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load persisted filters: %v", err)
+			}
+			if data != nil {
+				if err := filter.UnmarshalBinary(data); err != nil {
+					return nil, fmt.Errorf("failed to restore persisted filters: %v", err)
+				}
+			}
+		}
+	} else if err := interop.ProbeCache(context.Background(), mgr, conf.Dedupe.Cache); err != nil {
 		return nil, err
 	}
 
-	return &Dedupe{
+	d := &Dedupe{
 		conf:  conf,
 		log:   log,
 		stats: stats,
@@ -198,6 +282,13 @@ func NewDedupe(
 		cacheName:  conf.Dedupe.Cache,
 		hasherFunc: hFunc,
 
+		filter:          filter,
+		persister:       persister,
+		persistInterval: persistInterval,
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+
 		mCount:     stats.GetCounter("count"),
 		mErrHash:   stats.GetCounter("error.hash"),
 		mErrCache:  stats.GetCounter("error.cache"),
@@ -205,7 +296,40 @@ func NewDedupe(
 		mDropped:   stats.GetCounter("dropped"),
 		mSent:      stats.GetCounter("sent"),
 		mBatchSent: stats.GetCounter("batch.sent"),
-	}, nil
+	}
+	if persister != nil {
+		go d.persistLoop()
+	} else {
+		close(d.closedChan)
+	}
+	return d, nil
+}
+
+func (d *Dedupe) persistFilter() {
+	data, err := d.filter.MarshalBinary()
+	if err == nil {
+		err = d.persister.save(data)
+	}
+	if err != nil {
+		d.log.Errorf("Failed to persist filters: %v\n", err)
+	}
+}
+
+func (d *Dedupe) persistLoop() {
+	defer close(d.closedChan)
+
+	ticker := time.NewTicker(d.persistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.persistFilter()
+		case <-d.closeChan:
+			d.persistFilter()
+			return
+		}
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -250,6 +374,17 @@ func (d *Dedupe) ProcessMessage(msg types.Message) ([]types.Message, types.Respo
 			d.mDropped.Incr(1)
 			return nil, response.NewAck()
 		}
+	} else if d.filter != nil {
+		if d.filter.TestAndAdd(hasher.Bytes()) {
+			for _, s := range spans {
+				s.LogKV(
+					"event", "dropped",
+					"type", "deduplicated",
+				)
+			}
+			d.mDropped.Incr(1)
+			return nil, response.NewAck()
+		}
 	} else {
 		var err error
 		if cerr := interop.AccessCache(context.Background(), d.mgr, d.cacheName, func(cache types.Cache) {
@@ -292,10 +427,18 @@ func (d *Dedupe) ProcessMessage(msg types.Message) ([]types.Message, types.Respo
 
 // CloseAsync shuts down the processor and stops processing requests.
 func (d *Dedupe) CloseAsync() {
+	d.closeOnce.Do(func() {
+		close(d.closeChan)
+	})
 }
 
 // WaitForClose blocks until the processor has closed down.
 func (d *Dedupe) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-d.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
 	return nil
 }
 
@@ -0,0 +1,340 @@
+package processor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/sketch"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+var dedupeProbabilisticFields = docs.FieldSpecs{
+	docs.FieldBool("enabled", "Whether to deduplicate using Bloom filters held in memory rather than a cache resource. When enabled the field `cache` must be left empty."),
+	docs.FieldFloat("false_positive_rate", "The target rate at which unique messages are mistakenly identified as duplicates and dropped."),
+	docs.FieldInt("expected_items", "The number of unique messages expected within the `ttl` (or in total when `ttl` is empty). The filters grow beyond this when necessary, but sizing them correctly keeps memory usage and lookups optimal."),
+	docs.FieldString("ttl", "An optional duration after which messages are forgotten, allowing duplicates to pass. When empty messages are remembered indefinitely.", "24h", "1h"),
+	docs.FieldInt("buckets", "When a `ttl` is set the period is split into this many filters, and the oldest filter is discarded each time a new one is started. Messages are therefore forgotten after a duration of between `ttl` minus one bucket and `ttl`."),
+	docs.FieldObject("persist", "Optionally persist the filters periodically in order to retain recent history across restarts.").WithChildren(
+		docs.FieldString("path", "A path on disk to persist the filters to.", "/var/lib/benthos/dedupe.bin"),
+		docs.FieldString("cache", "A [`cache` resource](/docs/components/caches/about) to persist the filters to, this cannot be combined with `path`."),
+		docs.FieldString("key", "The key to store the filters under when persisting to a cache."),
+		docs.FieldString("interval", "The period between each persist of the filters, the filters are also persisted when the processor is shut down."),
+	),
+}
+
+// DedupePersistConfig contains configuration fields for persisting the filters
+// of the probabilistic mode of the dedupe processor.
+type DedupePersistConfig struct {
+	Path     string `json:"path" yaml:"path"`
+	Cache    string `json:"cache" yaml:"cache"`
+	Key      string `json:"key" yaml:"key"`
+	Interval string `json:"interval" yaml:"interval"`
+}
+
+// DedupeProbabilisticConfig contains configuration fields for the
+// probabilistic mode of the dedupe processor.
+type DedupeProbabilisticConfig struct {
+	Enabled           bool                `json:"enabled" yaml:"enabled"`
+	FalsePositiveRate float64             `json:"false_positive_rate" yaml:"false_positive_rate"`
+	ExpectedItems     int                 `json:"expected_items" yaml:"expected_items"`
+	TTL               string              `json:"ttl" yaml:"ttl"`
+	Buckets           int                 `json:"buckets" yaml:"buckets"`
+	Persist           DedupePersistConfig `json:"persist" yaml:"persist"`
+}
+
+// NewDedupeProbabilisticConfig returns a DedupeProbabilisticConfig with default
+// values.
+func NewDedupeProbabilisticConfig() DedupeProbabilisticConfig {
+	return DedupeProbabilisticConfig{
+		Enabled:           false,
+		FalsePositiveRate: 0.001,
+		ExpectedItems:     1000000,
+		TTL:               "",
+		Buckets:           4,
+		Persist: DedupePersistConfig{
+			Path:     "",
+			Cache:    "",
+			Key:      "benthos_dedupe_filters",
+			Interval: "30s",
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dedupeBucket struct {
+	start  time.Time
+	filter *sketch.ScalableBloomFilter
+}
+
+// dedupeFilter is a set of time bucketed Bloom filters where keys are added
+// to the newest bucket and tested against all of them. Once the newest bucket
+// is older than the bucket period a new one is started, and the oldest is
+// discarded when there are more buckets than the limit.
+type dedupeFilter struct {
+	capacity     int
+	fpRate       float64
+	bucketPeriod time.Duration
+	maxBuckets   int
+	nowFn        func() time.Time
+
+	mut     sync.Mutex
+	buckets []*dedupeBucket
+}
+
+func newDedupeFilter(conf DedupeProbabilisticConfig) (*dedupeFilter, error) {
+	if conf.FalsePositiveRate <= 0 || conf.FalsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false_positive_rate must be between 0 and 1, got %v", conf.FalsePositiveRate)
+	}
+	if conf.ExpectedItems < 1 {
+		return nil, fmt.Errorf("expected_items must be greater than zero, got %v", conf.ExpectedItems)
+	}
+
+	f := &dedupeFilter{
+		capacity:   conf.ExpectedItems,
+		fpRate:     conf.FalsePositiveRate,
+		maxBuckets: 1,
+		nowFn:      time.Now,
+	}
+	if conf.TTL != "" {
+		ttl, err := time.ParseDuration(conf.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ttl: %v", err)
+		}
+		if conf.Buckets < 1 {
+			return nil, fmt.Errorf("buckets must be greater than zero, got %v", conf.Buckets)
+		}
+		if ttl < time.Duration(conf.Buckets) {
+			return nil, fmt.Errorf("ttl %v is too short for %v buckets", ttl, conf.Buckets)
+		}
+		f.maxBuckets = conf.Buckets
+		f.bucketPeriod = ttl / time.Duration(conf.Buckets)
+
+		// Keys are spread across the buckets, and a key is tested against all
+		// of them, so each bucket gets a share of the capacity and error rate.
+		if f.capacity = conf.ExpectedItems / conf.Buckets; f.capacity < 1 {
+			f.capacity = 1
+		}
+		f.fpRate = conf.FalsePositiveRate / float64(conf.Buckets)
+	}
+	return f, nil
+}
+
+func (f *dedupeFilter) newBucket(start time.Time) *dedupeBucket {
+	// Parameters are validated at construction.
+	filter, _ := sketch.NewScalableBloomFilter(f.capacity, f.fpRate)
+	return &dedupeBucket{start: start, filter: filter}
+}
+
+// rotate ensures the newest bucket is current and that expired buckets are
+// discarded. Must be called whilst holding mut.
+func (f *dedupeFilter) rotate(now time.Time) {
+	if len(f.buckets) == 0 {
+		f.buckets = append(f.buckets, f.newBucket(now))
+		return
+	}
+	if f.bucketPeriod <= 0 {
+		return
+	}
+
+	newest := f.buckets[len(f.buckets)-1]
+	if now.Sub(newest.start) >= f.bucketPeriod*time.Duration(f.maxBuckets) {
+		// Everything has expired, start afresh.
+		f.buckets = []*dedupeBucket{f.newBucket(now)}
+		return
+	}
+	for now.Sub(newest.start) >= f.bucketPeriod {
+		newest = f.newBucket(newest.start.Add(f.bucketPeriod))
+		f.buckets = append(f.buckets, newest)
+	}
+	if excess := len(f.buckets) - f.maxBuckets; excess > 0 {
+		for i := 0; i < excess; i++ {
+			f.buckets[i] = nil
+		}
+		f.buckets = f.buckets[excess:]
+	}
+}
+
+// TestAndAdd returns true if the key has possibly been seen within the ttl,
+// otherwise the key is added and false is returned.
+func (f *dedupeFilter) TestAndAdd(key []byte) bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	f.rotate(f.nowFn())
+	for _, b := range f.buckets[:len(f.buckets)-1] {
+		if b.filter.Test(key) {
+			return true
+		}
+	}
+	return f.buckets[len(f.buckets)-1].filter.TestAndAdd(key)
+}
+
+// MarshalBinary serialises the buckets of the filter.
+func (f *dedupeFilter) MarshalBinary() ([]byte, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	data := make([]byte, 4, 1024)
+	binary.BigEndian.PutUint32(data, uint32(len(f.buckets)))
+
+	var header [16]byte
+	for _, b := range f.buckets {
+		filterBytes, err := b.filter.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint64(header[:], uint64(b.start.UnixNano()))
+		binary.BigEndian.PutUint64(header[8:], uint64(len(filterBytes)))
+		data = append(data, header[:]...)
+		data = append(data, filterBytes...)
+	}
+	return data, nil
+}
+
+var errDedupeFiltersMalformed = errors.New("persisted filters are malformed")
+
+// UnmarshalBinary replaces the buckets of the filter with serialised ones,
+// buckets that have since expired are discarded.
+func (f *dedupeFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errDedupeFiltersMalformed
+	}
+	n := binary.BigEndian.Uint32(data)
+	data = data[4:]
+
+	var buckets []*dedupeBucket
+	for i := uint32(0); i < n; i++ {
+		if len(data) < 16 {
+			return errDedupeFiltersMalformed
+		}
+		start := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+		size := binary.BigEndian.Uint64(data[8:])
+		data = data[16:]
+		if size > uint64(len(data)) {
+			return errDedupeFiltersMalformed
+		}
+		filter := &sketch.ScalableBloomFilter{}
+		if err := filter.UnmarshalBinary(data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+		buckets = append(buckets, &dedupeBucket{start: start, filter: filter})
+	}
+	if len(data) > 0 {
+		return errDedupeFiltersMalformed
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if len(buckets) > f.maxBuckets {
+		buckets = buckets[len(buckets)-f.maxBuckets:]
+	}
+	if f.bucketPeriod > 0 {
+		now := f.nowFn()
+		for len(buckets) > 0 && now.Sub(buckets[0].start) >= f.bucketPeriod*time.Duration(f.maxBuckets) {
+			buckets = buckets[1:]
+		}
+	}
+	f.buckets = buckets
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// dedupePersister stores and retrieves serialised filters, load returns nil
+// data when nothing has been persisted yet.
+type dedupePersister interface {
+	load() ([]byte, error)
+	save(data []byte) error
+}
+
+type dedupeFilePersister struct {
+	path string
+}
+
+func (p dedupeFilePersister) load() ([]byte, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (p dedupeFilePersister) save(data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	if _, err = tmpFile.Write(data); err == nil {
+		err = tmpFile.Sync()
+	}
+	if cerr := tmpFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Renaming ensures that an interrupted write never corrupts the
+		// previously persisted filters.
+		err = os.Rename(tmpPath, p.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
+
+type dedupeCachePersister struct {
+	mgr   types.Manager
+	cache string
+	key   string
+}
+
+func (p dedupeCachePersister) load() (data []byte, err error) {
+	if cerr := interop.AccessCache(context.Background(), p.mgr, p.cache, func(c types.Cache) {
+		data, err = c.Get(p.key)
+	}); cerr != nil {
+		return nil, cerr
+	}
+	if err == types.ErrKeyNotFound {
+		return nil, nil
+	}
+	return
+}
+
+func (p dedupeCachePersister) save(data []byte) (err error) {
+	if cerr := interop.AccessCache(context.Background(), p.mgr, p.cache, func(c types.Cache) {
+		err = c.Set(p.key, data)
+	}); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func newDedupePersister(conf DedupePersistConfig, mgr types.Manager) (dedupePersister, error) {
+	if conf.Path != "" && conf.Cache != "" {
+		return nil, errors.New("persist fields path and cache cannot both be set")
+	}
+	if conf.Path != "" {
+		return dedupeFilePersister{path: conf.Path}, nil
+	}
+	if conf.Cache != "" {
+		if err := interop.ProbeCache(context.Background(), mgr, conf.Cache); err != nil {
+			return nil, err
+		}
+		if conf.Key == "" {
+			return nil, errors.New("persist field key must not be empty")
+		}
+		return dedupeCachePersister{mgr: mgr, cache: conf.Cache, key: conf.Key}, nil
+	}
+	return nil, nil
+}
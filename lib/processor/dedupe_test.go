@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -15,6 +16,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -462,3 +465,190 @@ func randStringRunes(n int) string {
 	}
 	return string(b)
 }
+
+func TestDedupeProbabilistic(t *testing.T) {
+	conf := NewConfig()
+	conf.Dedupe.Key = `${! json("id") }`
+	conf.Dedupe.Probabilistic.Enabled = true
+	conf.Dedupe.Probabilistic.ExpectedItems = 1000
+
+	proc, err := NewDedupe(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		input   string
+		dropped bool
+	}{
+		{input: `{"id":"foo"}`},
+		{input: `{"id":"bar"}`},
+		{input: `{"id":"foo"}`, dropped: true},
+		{input: `{"id":"baz"}`},
+		{input: `{"id":"bar"}`, dropped: true},
+	} {
+		msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(test.input)}))
+		if test.dropped {
+			assert.Empty(t, msgs, test.input)
+			assert.Equal(t, response.NewAck(), res, test.input)
+		} else {
+			assert.Len(t, msgs, 1, test.input)
+			assert.Nil(t, res, test.input)
+		}
+	}
+
+	proc.CloseAsync()
+	require.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestDedupeProbabilisticTTL(t *testing.T) {
+	pConf := NewDedupeProbabilisticConfig()
+	pConf.ExpectedItems = 100
+	pConf.TTL = "40s"
+	pConf.Buckets = 4
+
+	filter, err := newDedupeFilter(pConf)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	filter.nowFn = func() time.Time { return now }
+
+	assert.False(t, filter.TestAndAdd([]byte("foo")))
+
+	now = now.Add(15 * time.Second)
+	assert.False(t, filter.TestAndAdd([]byte("bar")))
+	assert.True(t, filter.TestAndAdd([]byte("foo")))
+
+	// Foo was added to the first bucket, which expires after 40 seconds.
+	now = now.Add(25 * time.Second)
+	assert.False(t, filter.TestAndAdd([]byte("foo")))
+	assert.True(t, filter.TestAndAdd([]byte("bar")))
+	assert.Len(t, filter.buckets, 4)
+
+	// Everything has expired.
+	now = now.Add(time.Minute)
+	assert.False(t, filter.TestAndAdd([]byte("foo")))
+	assert.False(t, filter.TestAndAdd([]byte("bar")))
+	assert.Len(t, filter.buckets, 1)
+}
+
+func TestDedupeProbabilisticPersistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.bin")
+
+	conf := NewConfig()
+	conf.Dedupe.Probabilistic.Enabled = true
+	conf.Dedupe.Probabilistic.ExpectedItems = 1000
+	conf.Dedupe.Probabilistic.TTL = "1h"
+	conf.Dedupe.Probabilistic.Persist.Path = path
+
+	proc, err := NewDedupe(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{[]byte("foo")}))
+	assert.Len(t, msgs, 1)
+
+	proc.CloseAsync()
+	require.NoError(t, proc.WaitForClose(time.Second))
+	assert.FileExists(t, path)
+
+	proc, err = NewDedupe(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{[]byte("foo")}))
+	assert.Empty(t, msgs)
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{[]byte("bar")}))
+	assert.Len(t, msgs, 1)
+
+	proc.CloseAsync()
+	require.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestDedupeProbabilisticPersistCache(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Dedupe.Probabilistic.Enabled = true
+	conf.Dedupe.Probabilistic.ExpectedItems = 1000
+	conf.Dedupe.Probabilistic.Persist.Cache = "foocache"
+	conf.Dedupe.Probabilistic.Persist.Interval = "10ms"
+
+	proc, err := NewDedupe(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{[]byte("foo")}))
+	assert.Len(t, msgs, 1)
+
+	assert.Eventually(t, func() bool {
+		_, err := memCache.Get("benthos_dedupe_filters")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	proc.CloseAsync()
+	require.NoError(t, proc.WaitForClose(time.Second))
+
+	proc, err = NewDedupe(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{[]byte("foo")}))
+	assert.Empty(t, msgs)
+
+	proc.CloseAsync()
+	require.NoError(t, proc.WaitForClose(time.Second))
+}
+
+func TestDedupeProbabilisticBadConfig(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		fn     func(c *DedupeConfig)
+		errStr string
+	}{
+		{
+			name: "cache and probabilistic",
+			fn: func(c *DedupeConfig) {
+				c.Cache = "foocache"
+			},
+			errStr: "field cache cannot be set",
+		},
+		{
+			name: "bad false positive rate",
+			fn: func(c *DedupeConfig) {
+				c.Probabilistic.FalsePositiveRate = 1.5
+			},
+			errStr: "false_positive_rate must be between 0 and 1",
+		},
+		{
+			name: "bad ttl",
+			fn: func(c *DedupeConfig) {
+				c.Probabilistic.TTL = "nope"
+			},
+			errStr: "failed to parse ttl",
+		},
+		{
+			name: "both persist targets",
+			fn: func(c *DedupeConfig) {
+				c.Probabilistic.Persist.Path = "/tmp/foo"
+				c.Probabilistic.Persist.Cache = "foocache"
+			},
+			errStr: "cannot both be set",
+		},
+		{
+			name: "missing persist cache",
+			fn: func(c *DedupeConfig) {
+				c.Probabilistic.Persist.Cache = "foocache"
+			},
+			errStr: "cache resource 'foocache' was not found",
+		},
+	} {
+		conf := NewConfig()
+		conf.Dedupe.Probabilistic.Enabled = true
+		test.fn(&conf.Dedupe)
+
+		_, err := NewDedupe(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+		require.Error(t, err, test.name)
+		assert.Contains(t, err.Error(), test.errStr, test.name)
+	}
+}
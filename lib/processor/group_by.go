@@ -9,6 +9,7 @@ import (
 	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/condition"
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -81,7 +82,7 @@ output:
 					return "", false
 				}
 				return "field condition is deprecated in favour of check", cmp.Equal(v, iDefault)
-			}),
+			}).Fixer(docs.FixConditionToCheck),
 			docs.FieldCommon(
 				"processors",
 				"A list of [processors](/docs/components/processors/about/) to execute on the newly formed group.",
@@ -132,6 +133,7 @@ type group struct {
 type GroupBy struct {
 	log   log.Modular
 	stats metrics.Type
+	label string
 
 	groups     []group
 	mGroupPass []metrics.StatCounter
@@ -189,6 +191,7 @@ func NewGroupBy(
 	return &GroupBy{
 		log:   log,
 		stats: stats,
+		label: lineage.ComponentLabel(mgr, TypeGroupBy),
 
 		groups:     groups,
 		mGroupPass: groupCtrs,
@@ -229,7 +232,7 @@ func (g *GroupBy) ProcessMessage(msg types.Message) ([]types.Message, types.Resp
 						"type", groupStr,
 					)
 					spans[i].SetTag("group", groupStr)
-					groups[j].Append(p.Copy())
+					groups[j].Append(lineage.Derive(g.label, p.Copy(), p))
 					g.mGroupPass[j].Incr(1)
 					return nil
 				}
@@ -246,7 +249,7 @@ func (g *GroupBy) ProcessMessage(msg types.Message) ([]types.Message, types.Resp
 						"type", groupStr,
 					)
 					spans[i].SetTag("group", groupStr)
-					groups[j].Append(p.Copy())
+					groups[j].Append(lineage.Derive(g.label, p.Copy(), p))
 					g.mGroupPass[j].Incr(1)
 					return nil
 				}
@@ -258,7 +261,7 @@ func (g *GroupBy) ProcessMessage(msg types.Message) ([]types.Message, types.Resp
 			"type", "default",
 		)
 		spans[i].SetTag("group", "default")
-		groupless.Append(p.Copy())
+		groupless.Append(lineage.Derive(g.label, p.Copy(), p))
 		g.mGroupDefault.Incr(1)
 		return nil
 	})
@@ -7,6 +7,7 @@ import (
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
@@ -88,6 +89,7 @@ func NewGroupByValueConfig() GroupByValueConfig {
 type GroupByValue struct {
 	log   log.Modular
 	stats metrics.Type
+	label string
 
 	value *field.Expression
 
@@ -108,6 +110,7 @@ func NewGroupByValue(
 	return &GroupByValue{
 		log:   log,
 		stats: stats,
+		label: lineage.ComponentLabel(mgr, TypeGroupByValue),
 
 		value: value,
 
@@ -136,6 +139,7 @@ func (g *GroupByValue) ProcessMessage(msg types.Message) ([]types.Message, types
 
 	msg.Iter(func(i int, p types.Part) error {
 		v := g.value.String(i, msg)
+		p = lineage.Derive(g.label, p, p)
 		spans[i].LogKV(
 			"event", "grouped",
 			"type", v,
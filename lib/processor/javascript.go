@@ -0,0 +1,831 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/tracing"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/dop251/goja"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeJavaScript] = TypeSpec{
+		constructor: NewJavaScript,
+		Categories: []Category{
+			CategoryMapping,
+		},
+		Status:  docs.StatusExperimental,
+		Version: "3.65.0",
+		Summary: `
+Executes a JavaScript program on messages, with access to message contents and
+metadata through a sandboxed API.`,
+		Description: `
+This processor is useful for logic that is awkward to express in
+[Bloblang](/docs/guides/bloblang/about), such as complex loops, heavy use of
+regular expressions, or libraries written in JavaScript. Programs are executed
+with [goja](https://github.com/dop251/goja), an ECMAScript 5.1 engine (with
+partial support for ES6) written in pure Go, and have no access to the
+filesystem, network or environment other than through the
+[functions](#javascript-functions) provided.
+
+The program is executed once for each message. A pool of virtual machines is
+kept and grown as needed so that each pipeline thread executes programs with its
+own virtual machine, and global variables persist between executions of the
+same virtual machine, therefore expensive initialisation can be done once by
+checking whether a global has already been defined.
+
+If a program throws an exception or exceeds the ` + "`timeout`" + ` then the message
+remains unchanged and is flagged as having failed, allowing you to use
+[standard processor error handling patterns](/docs/configuration/error_handling).`,
+		Footnotes: `
+## JavaScript Functions
+
+The following functions are available within programs as members of the global
+object ` + "`benthos`" + `:
+
+` + "### `content`" + `
+
+Signature: ` + "`content() string`" + `
+
+Returns the contents of the message as a string.
+
+` + "### `contentBytes`" + `
+
+Signature: ` + "`contentBytes() ArrayBuffer`" + `
+
+Returns the contents of the message as an ArrayBuffer.
+
+` + "### `json`" + `
+
+Signature: ` + "`json() any`" + `
+
+Parses the contents of the message as JSON and returns the result, throwing an
+exception if the contents are not valid JSON.
+
+` + "### `setContent`" + `
+
+Signature: ` + "`setContent(value)`" + `
+
+Sets the contents of the message to a string, ArrayBuffer or Uint8Array.
+
+` + "### `setJSON`" + `
+
+Signature: ` + "`setJSON(value)`" + `
+
+Sets the contents of the message to a value serialised as JSON.
+
+` + "### `meta`" + `
+
+Signature: ` + "`meta(key) string`" + `
+
+Returns the value of a metadata key of the message, or ` + "`null`" + ` if it does not
+exist. When called without a key an object containing all metadata key/value
+pairs of the message is returned.
+
+` + "### `setMeta`" + `
+
+Signature: ` + "`setMeta(key, value)`" + `
+
+Sets a metadata key of the message, the value is converted into a string.
+
+` + "### `deleteMeta`" + `
+
+Signature: ` + "`deleteMeta(key)`" + `
+
+Deletes a metadata key of the message.
+
+` + "### `cacheGet`" + `
+
+Signature: ` + "`cacheGet(resource, key) string`" + `
+
+Returns the value of a key from a [cache resource](/docs/components/caches/about)
+listed in the field ` + "`cache_resources`" + `, or ` + "`null`" + ` if the key does not
+exist.
+
+` + "### `cacheSet`" + `
+
+Signature: ` + "`cacheSet(resource, key, value, ttl)`" + `
+
+Sets a key of a [cache resource](/docs/components/caches/about) listed in the
+field ` + "`cache_resources`" + `. The TTL is an optional duration string, and is
+ignored by caches that do not support per-key TTLs.
+
+` + "### `cacheDelete`" + `
+
+Signature: ` + "`cacheDelete(resource, key)`" + `
+
+Deletes a key of a [cache resource](/docs/components/caches/about) listed in the
+field ` + "`cache_resources`" + `.
+
+` + "### `fetch`" + `
+
+Signature: ` + "`fetch(url, options) object`" + `
+
+Performs an HTTP request, which is only permitted when ` + "`http.enabled`" + ` is
+` + "`true`" + `, and only to hosts listed in ` + "`http.allowed_hosts`" + ` when it is
+not empty, which also applies to any redirects that are followed. The options
+are optional and may contain the fields ` + "`method`" + `, ` + "`headers`" + ` and
+` + "`body`" + `. The result is an object containing the fields ` + "`status`" + `,
+` + "`headers`" + ` and ` + "`body`" + `, where the body is a string. The request is
+synchronous and throws an exception if it fails or the response body exceeds
+` + "`http.max_response_size`" + `, but responses with error status codes do not.
+
+Calls to ` + "`console.log`" + ` are also supported and print the arguments as a
+Benthos log at the ` + "`INFO`" + ` level.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("code", "A JavaScript program to execute for each message. Either this field or `file` must be specified.",
+				`benthos.setJSON({ "upper": benthos.content().toUpperCase() });`,
+			),
+			docs.FieldCommon("file", "A path to a file containing a JavaScript program to execute for each message. Either this field or `code` must be specified.",
+				"./transform.js",
+			),
+			docs.FieldCommon("timeout", "The maximum period of time that a program may execute for each message before it is interrupted and the message is flagged as failed."),
+			docs.FieldString("cache_resources", "A list of [`cache` resources](/docs/components/caches/about) that programs are permitted to access.").Array().Advanced(),
+			docs.FieldAdvanced("http", "Controls access to the `fetch` function.").WithChildren(
+				docs.FieldAdvanced("enabled", "Whether programs are permitted to perform HTTP requests."),
+				docs.FieldString("allowed_hosts", "An optional list of hosts, with an optional port, that requests are restricted to. When empty requests to any host are permitted.", []string{"example.com", "localhost:8080"}).Array().Advanced(),
+				docs.FieldAdvanced("timeout", "The maximum period of time to wait for a response to each request."),
+				docs.FieldAdvanced("max_response_size", "The maximum size in bytes of response bodies, where requests with larger responses throw an exception."),
+			),
+			PartsFieldSpec,
+		},
+		Examples: []docs.AnnotatedExample{
+			{
+				Title: "Structured Mapping",
+				Summary: `
+Loops and regular expressions can be used in order to extract fields from
+unstructured text. Given documents of the form ` + "`{\"log\":\"user=bev action=login\"}`" + `,
+we can expand each key/value pair of the log into a field of the document,
+keeping a tally of the number of messages seen by each virtual machine in
+metadata:`,
+				Config: `
+pipeline:
+  processors:
+    - javascript:
+        code: |
+          if (typeof count === "undefined") {
+            var count = 0;
+          }
+          count++;
+
+          var doc = benthos.json();
+          var re = /(\w+)=(\S+)/g;
+          var match;
+          while ((match = re.exec(doc.log)) !== null) {
+            doc[match[1]] = match[2];
+          }
+          benthos.setJSON(doc);
+          benthos.setMeta("count", count);
+`,
+			},
+			{
+				Title: "Enrichment",
+				Summary: `
+Cache resources and HTTP requests can be used in order to enrich documents,
+here we look up the name of a user from an API and cache the result:`,
+				Config: `
+pipeline:
+  processors:
+    - javascript:
+        cache_resources: [ users ]
+        http:
+          enabled: true
+          allowed_hosts: [ users.example.com ]
+        code: |
+          var doc = benthos.json();
+          var name = benthos.cacheGet("users", doc.user_id);
+          if (name === null) {
+            var res = benthos.fetch("http://users.example.com/users/" + doc.user_id);
+            if (res.status !== 200) {
+              throw new Error("failed to look up user: " + res.status);
+            }
+            name = JSON.parse(res.body).name;
+            benthos.cacheSet("users", doc.user_id, name, "1h");
+          }
+          doc.user_name = name;
+          benthos.setJSON(doc);
+
+cache_resources:
+  - label: users
+    memory: {}
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JavaScriptHTTPConfig contains configuration fields for the HTTP requests
+// performed by JavaScript programs.
+type JavaScriptHTTPConfig struct {
+	Enabled         bool     `json:"enabled" yaml:"enabled"`
+	AllowedHosts    []string `json:"allowed_hosts" yaml:"allowed_hosts"`
+	Timeout         string   `json:"timeout" yaml:"timeout"`
+	MaxResponseSize int64    `json:"max_response_size" yaml:"max_response_size"`
+}
+
+// JavaScriptConfig contains configuration fields for the JavaScript processor.
+type JavaScriptConfig struct {
+	Code           string               `json:"code" yaml:"code"`
+	File           string               `json:"file" yaml:"file"`
+	Timeout        string               `json:"timeout" yaml:"timeout"`
+	CacheResources []string             `json:"cache_resources" yaml:"cache_resources"`
+	HTTP           JavaScriptHTTPConfig `json:"http" yaml:"http"`
+	Parts          []int                `json:"parts" yaml:"parts"`
+}
+
+// NewJavaScriptConfig returns a JavaScriptConfig with default values.
+func NewJavaScriptConfig() JavaScriptConfig {
+	return JavaScriptConfig{
+		Code:           "",
+		File:           "",
+		Timeout:        "5s",
+		CacheResources: []string{},
+		HTTP: JavaScriptHTTPConfig{
+			Enabled:         false,
+			AllowedHosts:    []string{},
+			Timeout:         "5s",
+			MaxResponseSize: 10 * 1024 * 1024,
+		},
+		Parts: []int{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JavaScript is a processor that executes a JavaScript program for each
+// message.
+type JavaScript struct {
+	parts   []int
+	program *goja.Program
+	timeout time.Duration
+
+	caches          map[string]struct{}
+	httpEnabled     bool
+	allowedHosts    map[string]struct{}
+	maxResponseSize int64
+	httpClient      *http.Client
+
+	mgr   types.Manager
+	log   log.Modular
+	stats metrics.Type
+
+	// Virtual machines aren't safe to use concurrently and so they're pooled.
+	// Since a processor is shared by all pipeline threads the pool grows to
+	// the number of threads.
+	vmsMut sync.Mutex
+	vms    []*javaScriptVM
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewJavaScript returns a JavaScript processor.
+func NewJavaScript(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	code, name := conf.JavaScript.Code, "code"
+	if conf.JavaScript.File != "" {
+		if code != "" {
+			return nil, errors.New("only one of code or file may be specified")
+		}
+		codeBytes, err := os.ReadFile(conf.JavaScript.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		code, name = string(codeBytes), conf.JavaScript.File
+	}
+	if code == "" {
+		return nil, errors.New("either code or file must be specified")
+	}
+
+	program, err := goja.Compile(name, code, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile program: %w", err)
+	}
+
+	j := &JavaScript{
+		parts:        conf.JavaScript.Parts,
+		program:      program,
+		caches:       map[string]struct{}{},
+		httpEnabled:  conf.JavaScript.HTTP.Enabled,
+		allowedHosts: map[string]struct{}{},
+
+		mgr:   mgr,
+		log:   log,
+		stats: stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}
+
+	if j.timeout, err = time.ParseDuration(conf.JavaScript.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %w", err)
+	}
+
+	for _, c := range conf.JavaScript.CacheResources {
+		if err := interop.ProbeCache(context.Background(), mgr, c); err != nil {
+			return nil, err
+		}
+		j.caches[c] = struct{}{}
+	}
+
+	if j.httpEnabled {
+		httpTimeout, err := time.ParseDuration(conf.JavaScript.HTTP.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse http timeout: %w", err)
+		}
+		if j.maxResponseSize = conf.JavaScript.HTTP.MaxResponseSize; j.maxResponseSize <= 0 {
+			return nil, errors.New("http max_response_size must be greater than zero")
+		}
+		j.httpClient = &http.Client{
+			Timeout: httpTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+				return j.checkURL(req.URL)
+			},
+		}
+		for _, h := range conf.JavaScript.HTTP.AllowedHosts {
+			j.allowedHosts[h] = struct{}{}
+		}
+	}
+	return j, nil
+}
+
+//------------------------------------------------------------------------------
+
+var errJavaScriptTimeout = errors.New("program execution timed out")
+
+// javaScriptVM is a virtual machine along with the message part being
+// processed by its current execution.
+type javaScriptVM struct {
+	vm   *goja.Runtime
+	part types.Part
+
+	// ctx is cancelled once the current execution finishes or times out, and
+	// bounds any blocking calls made by the program such as fetch.
+	ctx context.Context
+
+	// broken is set when an execution panicked, after which the state of the
+	// virtual machine cannot be trusted and it's discarded.
+	broken bool
+
+	jsonParse     goja.Callable
+	jsonStringify goja.Callable
+}
+
+func (j *JavaScript) getVM() (*javaScriptVM, error) {
+	j.vmsMut.Lock()
+	if n := len(j.vms); n > 0 {
+		v := j.vms[n-1]
+		j.vms = j.vms[:n-1]
+		j.vmsMut.Unlock()
+		return v, nil
+	}
+	j.vmsMut.Unlock()
+	return j.newVM()
+}
+
+func (j *JavaScript) putVM(v *javaScriptVM) {
+	if v.broken {
+		return
+	}
+	v.part = nil
+	v.ctx = nil
+	j.vmsMut.Lock()
+	j.vms = append(j.vms, v)
+	j.vmsMut.Unlock()
+}
+
+func (j *JavaScript) newVM() (*javaScriptVM, error) {
+	v := &javaScriptVM{vm: goja.New()}
+
+	jsonObj := v.vm.Get("JSON").ToObject(v.vm)
+	var ok bool
+	if v.jsonParse, ok = goja.AssertFunction(jsonObj.Get("parse")); !ok {
+		return nil, errors.New("failed to obtain JSON.parse function")
+	}
+	if v.jsonStringify, ok = goja.AssertFunction(jsonObj.Get("stringify")); !ok {
+		return nil, errors.New("failed to obtain JSON.stringify function")
+	}
+
+	fns := map[string]func(goja.FunctionCall) goja.Value{
+		"content":      v.fnContent,
+		"contentBytes": v.fnContentBytes,
+		"json":         v.fnJSON,
+		"setContent":   v.fnSetContent,
+		"setJSON":      v.fnSetJSON,
+		"meta":         v.fnMeta,
+		"setMeta":      v.fnSetMeta,
+		"deleteMeta":   v.fnDeleteMeta,
+		"cacheGet":     j.fnCacheGet(v),
+		"cacheSet":     j.fnCacheSet(v),
+		"cacheDelete":  j.fnCacheDelete(v),
+		"fetch":        j.fnFetch(v),
+	}
+	benthosObj := v.vm.NewObject()
+	for k, fn := range fns {
+		if err := benthosObj.Set(k, fn); err != nil {
+			return nil, err
+		}
+	}
+	if err := v.vm.Set("benthos", benthosObj); err != nil {
+		return nil, err
+	}
+
+	consoleObj := v.vm.NewObject()
+	if err := consoleObj.Set("log", func(call goja.FunctionCall) goja.Value {
+		args := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = arg.String()
+		}
+		j.log.Infoln(strings.Join(args, " "))
+		return goja.Undefined()
+	}); err != nil {
+		return nil, err
+	}
+	if err := v.vm.Set("console", consoleObj); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// run executes the program against a message part, which is only modified when
+// the program succeeds.
+func (j *JavaScript) run(v *javaScriptVM, part types.Part) error {
+	v.part = part.Copy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v.ctx = ctx
+
+	var finishedMut sync.Mutex
+	finished := false
+	timer := time.AfterFunc(j.timeout, func() {
+		finishedMut.Lock()
+		if !finished {
+			v.vm.Interrupt(errJavaScriptTimeout)
+			cancel()
+		}
+		finishedMut.Unlock()
+	})
+
+	err := j.runProgram(v)
+
+	finishedMut.Lock()
+	finished = true
+	finishedMut.Unlock()
+	timer.Stop()
+	v.vm.ClearInterrupt()
+
+	if err != nil {
+		var exception *goja.Exception
+		if errors.As(err, &exception) {
+			return errors.New(exception.Value().String())
+		}
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return fmt.Errorf("%v after %v", interrupted.Value(), j.timeout)
+		}
+		return err
+	}
+
+	part.Set(v.part.Get())
+	part.SetMetadata(v.part.Metadata())
+	return nil
+}
+
+// runProgram executes the program and recovers from any panic that escapes it,
+// such that a program can never take down the process.
+func (j *JavaScript) runProgram(v *javaScriptVM) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v.broken = true
+			err = fmt.Errorf("program execution panicked: %v", r)
+		}
+	}()
+	_, err = v.vm.RunProgram(j.program)
+	return
+}
+
+//------------------------------------------------------------------------------
+
+func (v *javaScriptVM) throw(err error) {
+	panic(v.vm.NewGoError(err))
+}
+
+func (v *javaScriptVM) fnContent(call goja.FunctionCall) goja.Value {
+	return v.vm.ToValue(string(v.part.Get()))
+}
+
+func (v *javaScriptVM) fnContentBytes(call goja.FunctionCall) goja.Value {
+	b := make([]byte, len(v.part.Get()))
+	copy(b, v.part.Get())
+	return v.vm.ToValue(v.vm.NewArrayBuffer(b))
+}
+
+func (v *javaScriptVM) fnJSON(call goja.FunctionCall) goja.Value {
+	res, err := v.jsonParse(goja.Undefined(), v.vm.ToValue(string(v.part.Get())))
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (v *javaScriptVM) fnSetContent(call goja.FunctionCall) goja.Value {
+	arg := call.Argument(0)
+	switch t := arg.Export().(type) {
+	case string:
+		v.part.Set([]byte(t))
+	case goja.ArrayBuffer:
+		v.part.Set(append([]byte(nil), t.Bytes()...))
+	case []byte:
+		v.part.Set(append([]byte(nil), t...))
+	default:
+		// Typed arrays are a view of an underlying buffer.
+		if obj, ok := arg.(*goja.Object); ok {
+			if buf, ok := obj.Get("buffer").Export().(goja.ArrayBuffer); ok {
+				b := buf.Bytes()
+				offset := obj.Get("byteOffset").ToInteger()
+				length := obj.Get("byteLength").ToInteger()
+				if offset < 0 || length < 0 || offset > int64(len(b)) || length > int64(len(b))-offset {
+					panic(v.vm.NewTypeError("invalid byteOffset %v and byteLength %v for a buffer of %v bytes", offset, length, len(b)))
+				}
+				v.part.Set(append([]byte(nil), b[offset:offset+length]...))
+				return goja.Undefined()
+			}
+		}
+		panic(v.vm.NewTypeError("expected string, ArrayBuffer or Uint8Array, got %v", arg))
+	}
+	return goja.Undefined()
+}
+
+func (v *javaScriptVM) fnSetJSON(call goja.FunctionCall) goja.Value {
+	res, err := v.jsonStringify(goja.Undefined(), call.Argument(0))
+	if err != nil {
+		panic(err)
+	}
+	if goja.IsUndefined(res) {
+		panic(v.vm.NewTypeError("value cannot be serialised as JSON"))
+	}
+	v.part.Set([]byte(res.String()))
+	return goja.Undefined()
+}
+
+func (v *javaScriptVM) fnMeta(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) == 0 {
+		obj := v.vm.NewObject()
+		_ = v.part.Metadata().Iter(func(k, value string) error {
+			return obj.Set(k, value)
+		})
+		return obj
+	}
+	key := call.Argument(0).String()
+	var exists bool
+	_ = v.part.Metadata().Iter(func(k, _ string) error {
+		if k == key {
+			exists = true
+		}
+		return nil
+	})
+	if !exists {
+		return goja.Null()
+	}
+	return v.vm.ToValue(v.part.Metadata().Get(key))
+}
+
+func (v *javaScriptVM) fnSetMeta(call goja.FunctionCall) goja.Value {
+	v.part.Metadata().Set(call.Argument(0).String(), call.Argument(1).String())
+	return goja.Undefined()
+}
+
+func (v *javaScriptVM) fnDeleteMeta(call goja.FunctionCall) goja.Value {
+	v.part.Metadata().Delete(call.Argument(0).String())
+	return goja.Undefined()
+}
+
+//------------------------------------------------------------------------------
+
+func (j *JavaScript) accessCache(v *javaScriptVM, name string, fn func(c types.Cache) error) {
+	if _, exists := j.caches[name]; !exists {
+		v.throw(fmt.Errorf("cache resource '%v' is not listed in cache_resources", name))
+	}
+	var err error
+	if cerr := interop.AccessCache(context.Background(), j.mgr, name, func(c types.Cache) {
+		err = fn(c)
+	}); cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		v.throw(err)
+	}
+}
+
+func (j *JavaScript) fnCacheGet(v *javaScriptVM) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		res := goja.Null()
+		j.accessCache(v, call.Argument(0).String(), func(c types.Cache) error {
+			b, err := c.Get(call.Argument(1).String())
+			if err != nil {
+				if errors.Is(err, types.ErrKeyNotFound) {
+					return nil
+				}
+				return err
+			}
+			res = v.vm.ToValue(string(b))
+			return nil
+		})
+		return res
+	}
+}
+
+func (j *JavaScript) fnCacheSet(v *javaScriptVM) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		var ttl *time.Duration
+		if ttlArg := call.Argument(3); !goja.IsUndefined(ttlArg) && !goja.IsNull(ttlArg) {
+			td, err := time.ParseDuration(ttlArg.String())
+			if err != nil {
+				v.throw(fmt.Errorf("failed to parse ttl: %w", err))
+			}
+			ttl = &td
+		}
+		key, value := call.Argument(1).String(), []byte(call.Argument(2).String())
+		j.accessCache(v, call.Argument(0).String(), func(c types.Cache) error {
+			if cttl, ok := c.(types.CacheWithTTL); ok {
+				return cttl.SetWithTTL(key, value, ttl)
+			}
+			return c.Set(key, value)
+		})
+		return goja.Undefined()
+	}
+}
+
+func (j *JavaScript) fnCacheDelete(v *javaScriptVM) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		j.accessCache(v, call.Argument(0).String(), func(c types.Cache) error {
+			return c.Delete(call.Argument(1).String())
+		})
+		return goja.Undefined()
+	}
+}
+
+// checkURL returns an error if a request to a URL is not permitted, which is
+// checked for the URL of a fetch as well as each redirect that follows.
+func (j *JavaScript) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url scheme not supported: %v", u.Scheme)
+	}
+	if len(j.allowedHosts) > 0 {
+		_, hostAllowed := j.allowedHosts[u.Host]
+		_, hostnameAllowed := j.allowedHosts[u.Hostname()]
+		if !hostAllowed && !hostnameAllowed {
+			return fmt.Errorf("host '%v' is not listed in allowed_hosts", u.Host)
+		}
+	}
+	return nil
+}
+
+func (j *JavaScript) fnFetch(v *javaScriptVM) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if !j.httpEnabled {
+			v.throw(errors.New("http requests are not enabled"))
+		}
+
+		u, err := url.Parse(call.Argument(0).String())
+		if err != nil {
+			v.throw(err)
+		}
+		if err := j.checkURL(u); err != nil {
+			v.throw(err)
+		}
+
+		method := "GET"
+		var body io.Reader
+		headers := map[string]string{}
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			if m := opts.Get("method"); m != nil && !goja.IsUndefined(m) {
+				method = m.String()
+			}
+			if b := opts.Get("body"); b != nil && !goja.IsUndefined(b) && !goja.IsNull(b) {
+				body = bytes.NewReader([]byte(b.String()))
+			}
+			if h, ok := opts.Get("headers").(*goja.Object); ok {
+				for _, k := range h.Keys() {
+					headers[k] = h.Get(k).String()
+				}
+			}
+		}
+
+		req, err := http.NewRequestWithContext(v.ctx, method, u.String(), body)
+		if err != nil {
+			v.throw(err)
+		}
+		for k, hv := range headers {
+			req.Header.Set(k, hv)
+		}
+
+		res, err := j.httpClient.Do(req)
+		if err != nil {
+			v.throw(err)
+		}
+		defer res.Body.Close()
+
+		// Read one byte beyond the limit in order to detect bodies that exceed
+		// it rather than silently truncating them.
+		resBody, err := io.ReadAll(io.LimitReader(res.Body, j.maxResponseSize+1))
+		if err != nil {
+			v.throw(err)
+		}
+		if int64(len(resBody)) > j.maxResponseSize {
+			v.throw(fmt.Errorf("response body exceeds max_response_size of %v bytes", j.maxResponseSize))
+		}
+
+		resHeaders := v.vm.NewObject()
+		for k := range res.Header {
+			_ = resHeaders.Set(k, res.Header.Get(k))
+		}
+
+		resObj := v.vm.NewObject()
+		_ = resObj.Set("status", res.StatusCode)
+		_ = resObj.Set("headers", resHeaders)
+		_ = resObj.Set("body", string(resBody))
+		return resObj
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (j *JavaScript) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	j.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	v, err := j.getVM()
+	if err != nil {
+		j.mErr.Incr(1)
+		j.log.Errorf("Failed to create virtual machine: %v\n", err)
+		newMsg.Iter(func(i int, p types.Part) error {
+			FlagErr(p, err)
+			return nil
+		})
+		return []types.Message{newMsg}, nil
+	}
+	defer func() {
+		j.putVM(v)
+	}()
+
+	IteratePartsWithSpanV2(TypeJavaScript, j.parts, newMsg, func(i int, span *tracing.Span, part types.Part) error {
+		if v.broken {
+			var err error
+			if v, err = j.getVM(); err != nil {
+				j.mErr.Incr(1)
+				return err
+			}
+		}
+		if err := j.run(v, part); err != nil {
+			j.mErr.Incr(1)
+			j.log.Debugf("Failed to execute program: %v\n", err)
+			return err
+		}
+		return nil
+	})
+
+	j.mBatchSent.Incr(1)
+	j.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (j *JavaScript) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (j *JavaScript) WaitForClose(_ time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,439 @@
+package processor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJavaScriptContentAndMetadata(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Code = `
+var doc = benthos.json();
+var re = /(\w+)=(\S+)/g;
+var match;
+while ((match = re.exec(doc.log)) !== null) {
+  doc[match[1]] = match[2];
+}
+doc.source = benthos.meta("source");
+doc.missing = benthos.meta("nope");
+doc.meta_count = Object.keys(benthos.meta()).length;
+benthos.setJSON(doc);
+benthos.setMeta("fields", Object.keys(doc).length);
+benthos.deleteMeta("source");
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	input := message.New([][]byte{
+		[]byte(`{"log":"user=bev action=login"}`),
+	})
+	input.Get(0).Metadata().Set("source", "foo").Set("other", "bar")
+
+	msgs, res := proc.ProcessMessage(input)
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	out := msgs[0].Get(0)
+	assert.JSONEq(t, `{
+  "log":"user=bev action=login",
+  "user":"bev",
+  "action":"login",
+  "source":"foo",
+  "missing":null,
+  "meta_count":2
+}`, string(out.Get()))
+	assert.Equal(t, "6", out.Metadata().Get("fields"))
+	assert.Equal(t, "", out.Metadata().Get("source"))
+	assert.Equal(t, "bar", out.Metadata().Get("other"))
+	assert.Empty(t, GetFail(out))
+
+	// The input message must not be modified.
+	assert.Equal(t, `{"log":"user=bev action=login"}`, string(input.Get(0).Get()))
+	assert.Equal(t, "foo", input.Get(0).Metadata().Get("source"))
+}
+
+func TestJavaScriptBytes(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Code = `
+var b = new Uint8Array(benthos.contentBytes());
+switch (benthos.meta("mode")) {
+case "buffer":
+  b[0] = 72;
+  benthos.setContent(b.buffer);
+  break;
+case "view":
+  benthos.setContent(b.subarray(1, 3));
+  break;
+default:
+  benthos.setContent(benthos.content().toUpperCase());
+}
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	input := message.New([][]byte{
+		[]byte(`hello`),
+		[]byte(`hello`),
+		[]byte(`hello`),
+	})
+	input.Get(0).Metadata().Set("mode", "buffer")
+	input.Get(1).Metadata().Set("mode", "view")
+
+	msgs, res := proc.ProcessMessage(input)
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, [][]byte{
+		[]byte(`Hello`),
+		[]byte(`el`),
+		[]byte(`HELLO`),
+	}, message.GetAllBytes(msgs[0]))
+}
+
+func TestJavaScriptErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Timeout = "100ms"
+	conf.JavaScript.Code = `
+switch (benthos.content()) {
+case "throw":
+  benthos.setContent("changed");
+  throw new Error("nope");
+case "loop":
+  while (true) {}
+case "json":
+  benthos.json();
+  break;
+case "fetch":
+  benthos.fetch("http://example.com");
+  break;
+case "cache":
+  benthos.cacheGet("foo", "bar");
+  break;
+case "bounds":
+  benthos.setContent({ buffer: new ArrayBuffer(4), byteOffset: 10, byteLength: 5 });
+  break;
+default:
+  benthos.setContent("ok");
+}
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	input := message.New([][]byte{
+		[]byte(`throw`),
+		[]byte(`loop`),
+		[]byte(`json`),
+		[]byte(`fetch`),
+		[]byte(`cache`),
+		[]byte(`bounds`),
+		[]byte(`fine`),
+	})
+	input.Get(0).Metadata().Set("foo", "bar")
+
+	msgs, res := proc.ProcessMessage(input)
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.Equal(t, [][]byte{
+		[]byte(`throw`),
+		[]byte(`loop`),
+		[]byte(`json`),
+		[]byte(`fetch`),
+		[]byte(`cache`),
+		[]byte(`bounds`),
+		[]byte(`ok`),
+	}, message.GetAllBytes(msgs[0]))
+
+	assert.Equal(t, "Error: nope", GetFail(msgs[0].Get(0)))
+	assert.Equal(t, "bar", msgs[0].Get(0).Metadata().Get("foo"))
+	assert.Equal(t, "program execution timed out after 100ms", GetFail(msgs[0].Get(1)))
+	assert.Contains(t, GetFail(msgs[0].Get(2)), "SyntaxError")
+	assert.Contains(t, GetFail(msgs[0].Get(3)), "http requests are not enabled")
+	assert.Contains(t, GetFail(msgs[0].Get(4)), "cache resource 'foo' is not listed in cache_resources")
+	assert.Contains(t, GetFail(msgs[0].Get(5)), "TypeError: invalid byteOffset 10 and byteLength 5 for a buffer of 4 bytes")
+	assert.Empty(t, GetFail(msgs[0].Get(6)))
+}
+
+func TestJavaScriptPanicRecovered(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Code = `
+if (benthos.content() === "panic") {
+  boom();
+}
+benthos.setContent("ok");
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	j := proc.(*JavaScript)
+	v, err := j.getVM()
+	require.NoError(t, err)
+	require.NoError(t, v.vm.Set("boom", func(call goja.FunctionCall) goja.Value {
+		var b []byte
+		return v.vm.ToValue(b[call.Argument(0).ToInteger()+1])
+	}))
+	j.putVM(v)
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`panic`),
+		[]byte(`fine`),
+	}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.Contains(t, GetFail(msgs[0].Get(0)), "program execution panicked")
+	assert.Equal(t, "ok", string(msgs[0].Get(1).Get()))
+	assert.Empty(t, GetFail(msgs[0].Get(1)))
+
+	// The virtual machine that panicked is discarded.
+	for _, pooled := range j.vms {
+		assert.NotEqual(t, v, pooled)
+	}
+}
+
+func TestJavaScriptFetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Timeout = "100ms"
+	conf.JavaScript.HTTP.Enabled = true
+	conf.JavaScript.HTTP.Timeout = "10s"
+	conf.JavaScript.Code = `benthos.fetch(benthos.content());`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	start := time.Now()
+	msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(srv.URL)}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.Less(t, int64(time.Since(start)), int64(time.Second*5))
+	assert.NotEmpty(t, GetFail(msgs[0].Get(0)))
+}
+
+func TestJavaScriptGlobalsPersist(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Code = `
+if (typeof count === "undefined") {
+  var count = 0;
+}
+count++;
+benthos.setContent(String(count));
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	for _, exp := range []string{"1", "2", "3"} {
+		msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`hello`)}))
+		require.Nil(t, res)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, exp, string(msgs[0].Get(0).Get()))
+	}
+}
+
+func TestJavaScriptParallel(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.Code = `benthos.setContent("hello " + benthos.content());`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`world`)}))
+				require.Nil(t, res)
+				require.Len(t, msgs, 1)
+				assert.Equal(t, "hello world", string(msgs[0].Get(0).Get()))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, len(proc.(*JavaScript).vms), 10)
+}
+
+func TestJavaScriptCache(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, memCache.Set("foo", []byte("from cache")))
+
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"things": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.CacheResources = []string{"things"}
+	conf.JavaScript.Code = `
+var key = benthos.content();
+var value = benthos.cacheGet("things", key);
+if (value === null) {
+  benthos.cacheSet("things", key, "set " + key, "1h");
+  benthos.setContent("missing");
+} else {
+  benthos.cacheDelete("things", key);
+  benthos.setContent(value);
+}
+`
+
+	proc, err := New(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`foo`),
+		[]byte(`bar`),
+	}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, [][]byte{
+		[]byte(`from cache`),
+		[]byte(`missing`),
+	}, message.GetAllBytes(msgs[0]))
+
+	_, err = memCache.Get("foo")
+	assert.Equal(t, types.ErrKeyNotFound, err)
+
+	v, err := memCache.Get("bar")
+	require.NoError(t, err)
+	assert.Equal(t, "set bar", string(v))
+
+	conf.JavaScript.CacheResources = []string{"nope"}
+	_, err = New(conf, mgr, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+}
+
+func TestJavaScriptFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect_allowed":
+			http.Redirect(w, r, "/things", http.StatusFound)
+			return
+		case "/redirect_denied":
+			http.Redirect(w, r, "http://example.com/things", http.StatusFound)
+			return
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("a", 101)))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Foo") + " " + string(body)))
+	}))
+	defer srv.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.HTTP.Enabled = true
+	conf.JavaScript.HTTP.AllowedHosts = []string{strings.TrimPrefix(srv.URL, "http://")}
+	conf.JavaScript.HTTP.MaxResponseSize = 100
+	conf.JavaScript.Code = `
+var res = benthos.fetch(benthos.content(), {
+  method: "POST",
+  headers: { "X-Foo": "bar" },
+  body: "hello"
+});
+benthos.setJSON({
+  status: res.status,
+  method: res.headers["X-Method"],
+  body: res.body
+});
+`
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte(srv.URL + "/things"),
+		[]byte(`http://example.com/things`),
+		[]byte(`file:///etc/passwd`),
+		[]byte(srv.URL + "/redirect_allowed"),
+		[]byte(srv.URL + "/redirect_denied"),
+		[]byte(srv.URL + "/large"),
+	}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.JSONEq(t, `{"status":202,"method":"POST","body":"/things bar hello"}`, string(msgs[0].Get(0).Get()))
+	assert.Contains(t, GetFail(msgs[0].Get(1)), "host 'example.com' is not listed in allowed_hosts")
+	assert.Contains(t, GetFail(msgs[0].Get(2)), "url scheme not supported: file")
+	assert.JSONEq(t, `{"status":202,"method":"GET","body":"/things bar "}`, string(msgs[0].Get(3).Get()))
+	assert.Contains(t, GetFail(msgs[0].Get(4)), "host 'example.com' is not listed in allowed_hosts")
+	assert.Contains(t, GetFail(msgs[0].Get(5)), "response body exceeds max_response_size of 100 bytes")
+}
+
+func TestJavaScriptConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "script.js")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(`benthos.setContent("from file");`), 0o644))
+
+	conf := NewConfig()
+	conf.Type = TypeJavaScript
+	conf.JavaScript.File = scriptPath
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`hello`)}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "from file", string(msgs[0].Get(0).Get()))
+
+	conf.JavaScript.Code = `benthos.setContent("from code");`
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only one of code or file")
+
+	conf.JavaScript.File = ""
+	conf.JavaScript.Code = ""
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "either code or file")
+
+	conf.JavaScript.Code = `this is not javascript`
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to compile program")
+}
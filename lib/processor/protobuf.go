@@ -3,11 +3,10 @@ package processor
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/protobuf"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -18,8 +17,6 @@ import (
 	// nolint:staticcheck // Ignore SA1019 deprecation warning until we can switch to "google.golang.org/protobuf/types/dynamicpb"
 	"github.com/golang/protobuf/proto"
 
-	"github.com/jhump/protoreflect/desc"
-	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
 )
 
@@ -186,12 +183,12 @@ func newProtobufToJSONOperator(message string, importPaths []string) (protobufOp
 		return nil, errors.New("message field must not be empty")
 	}
 
-	descriptors, err := loadDescriptors(importPaths)
+	descriptors, err := protobuf.LoadDescriptors(importPaths)
 	if err != nil {
 		return nil, err
 	}
 
-	m := getMessageFromDescriptors(message, descriptors)
+	m := protobuf.FindMessage(message, descriptors)
 	if m == nil {
 		return nil, fmt.Errorf("unable to find message '%v' definition within '%v'", message, importPaths)
 	}
@@ -221,12 +218,12 @@ func newProtobufFromJSONOperator(message string, importPaths []string) (protobuf
 		return nil, errors.New("message field must not be empty")
 	}
 
-	descriptors, err := loadDescriptors(importPaths)
+	descriptors, err := protobuf.LoadDescriptors(importPaths)
 	if err != nil {
 		return nil, err
 	}
 
-	m := getMessageFromDescriptors(message, descriptors)
+	m := protobuf.FindMessage(message, descriptors)
 	if m == nil {
 		return nil, fmt.Errorf("unable to find message '%v' definition within '%v'", message, importPaths)
 	}
@@ -261,55 +258,6 @@ func strToProtobufOperator(opStr, message string, importPaths []string) (protobu
 	return nil, fmt.Errorf("operator not recognised: %v", opStr)
 }
 
-func loadDescriptors(importPaths []string) ([]*desc.FileDescriptor, error) {
-	var parser protoparse.Parser
-	if len(importPaths) == 0 {
-		importPaths = []string{"."}
-	} else {
-		parser.ImportPaths = importPaths
-	}
-
-	var files []string
-	for _, importPath := range importPaths {
-		if err := filepath.Walk(importPath, func(path string, info os.FileInfo, ferr error) error {
-			if ferr != nil || info.IsDir() {
-				return ferr
-			}
-			if filepath.Ext(info.Name()) == ".proto" {
-				rPath, ferr := filepath.Rel(importPath, path)
-				if ferr != nil {
-					return fmt.Errorf("failed to get relative path: %v", ferr)
-				}
-				files = append(files, rPath)
-			}
-			return nil
-		}); err != nil {
-			return nil, err
-		}
-	}
-
-	fds, err := parser.ParseFiles(files...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse .proto file: %v", err)
-	}
-	if len(fds) == 0 {
-		return nil, fmt.Errorf("no .proto files were found in the paths '%v'", importPaths)
-	}
-
-	return fds, err
-}
-
-func getMessageFromDescriptors(message string, fds []*desc.FileDescriptor) *desc.MessageDescriptor {
-	var msg *desc.MessageDescriptor
-	for _, fd := range fds {
-		msg = fd.FindMessage(message)
-		if msg != nil {
-			break
-		}
-	}
-	return msg
-}
-
 //------------------------------------------------------------------------------
 
 // Protobuf is a processor that performs an operation on an Protobuf payload.
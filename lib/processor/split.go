@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -56,6 +57,7 @@ func NewSplitConfig() SplitConfig {
 type Split struct {
 	log   log.Modular
 	stats metrics.Type
+	label string
 
 	size     int
 	byteSize int
@@ -73,6 +75,7 @@ func NewSplit(
 	return &Split{
 		log:   log,
 		stats: stats,
+		label: lineage.ComponentLabel(mgr, TypeSplit),
 
 		size:     conf.Split.Size,
 		byteSize: conf.Split.ByteSize,
@@ -112,7 +115,7 @@ func (s *Split) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 				s.log.Warnf("A single message exceeds the target batch byte size of '%v', actual size: '%v'", s.byteSize, len(p.Get()))
 			}
 		}
-		nextMsg.Append(p)
+		nextMsg.Append(lineage.Derive(s.label, p, p))
 		byteSize += len(p.Get())
 		return nil
 	})
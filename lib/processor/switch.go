@@ -45,7 +45,7 @@ At the end of switch processing the resulting batch will follow the same orderin
 					return "", false
 				}
 				return "field condition is deprecated in favour of check", m["type"] == "static" && m["static"] == true
-			}),
+			}).Fixer(docs.FixConditionToCheck),
 			docs.FieldBloblang(
 				"check",
 				"A [Bloblang query](/docs/guides/bloblang/about/) that should return a boolean value indicating whether a message should have the processors of this case executed on it. If left empty the case always passes. If the check mapping throws an error the message will be flagged [as having failed](/docs/configuration/error_handling) and will not be tested against any other cases.",
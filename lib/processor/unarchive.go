@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
@@ -357,6 +358,7 @@ type Unarchive struct {
 
 	log   log.Modular
 	stats metrics.Type
+	label string
 
 	mCount     metrics.StatCounter
 	mErr       metrics.StatCounter
@@ -379,6 +381,7 @@ func NewUnarchive(
 		unarchive: dcor,
 		log:       log,
 		stats:     stats,
+		label:     lineage.ComponentLabel(mgr, TypeUnarchive),
 
 		mCount:     stats.GetCounter("count"),
 		mErr:       stats.GetCounter("error"),
@@ -421,6 +424,9 @@ func (d *Unarchive) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 
 		newParts, err := d.unarchive(part)
 		if err == nil {
+			for j := range newParts {
+				newParts[j] = lineage.Derive(d.label, newParts[j], part)
+			}
 			newMsg.Append(newParts...)
 		} else {
 			d.mErr.Incr(1)
@@ -54,7 +54,7 @@ If following a loop execution the number of messages in a batch is reduced to ze
 					return "", false
 				}
 				return "field condition is deprecated in favour of check", cmp.Equal(v, iDefault)
-			}),
+			}).Fixer(docs.FixConditionToCheck),
 			docs.FieldCommon("processors", "A list of child processors to execute on each loop.").Array().HasType(docs.FieldTypeProcessor),
 		},
 	}
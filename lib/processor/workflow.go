@@ -73,10 +73,13 @@ The object is of the following form:
 	"skipped": [ "bar" ],
 	"failed": {
 		"baz": "the error message from the branch"
-	}
+	},
+	"path": [ [ "foo", "baz" ] ]
 }
 ` + "```" + `
 
+The field ` + "`path`" + ` lists the tiers of branches that were executed for the message in the order that they were executed, where the branches of each tier were executed in parallel. Branches that were skipped for the message are omitted.
+
 If a message already has a meta object at the given path when it is processed then the object is used in order to determine which branches have already been performed on the message (or skipped) and can therefore be skipped on this run.
 
 This is a useful pattern when replaying messages that have failed some branches previously. For example, given the above example object the branches foo and bar would automatically be skipped, and baz would be reattempted.
@@ -107,6 +110,16 @@ For example, if our meta object is stored at the path ` + "`meta.workflow`" + `
 
 However, if structured metadata is disabled by setting the field ` + "`meta_path`" + ` to empty then the workflow processor instead adds a general error flag to messages when any executed branch fails. In this case it's possible to handle failures using [standard error handling patterns][configuration.error-handling].
 
+### Branch Failure Policies
+
+Each branch can be configured with a ` + "`timeout`" + `, and with ` + "`max_retries`" + ` and a ` + "`backoff`" + ` in order to retry messages that fail before the failure is recorded. The field ` + "`on_error`" + ` of a branch determines how a failure affects the rest of the workflow:
+
+- ` + "`fail`" + ` (default) records the failure and continues executing all remaining branches.
+- ` + "`skip`" + ` records the failure and skips all branches that depend on the result of the failed branch (directly or transitively) for the failed messages, these are recorded as skipped.
+- ` + "`continue`" + ` records the failure within the structured metadata, but the message is not flagged as having failed when ` + "`meta_path`" + ` is empty.
+
+Dependencies between branches are determined by the mappings of each branch, as with automatic DAG resolution, even when an explicit ` + "`order`" + ` is provided.
+
 [dag_wiki]: https://en.wikipedia.org/wiki/Directed_acyclic_graph
 [processors.switch]: /docs/components/processors/switch
 [processors.http]: /docs/components/processors/http
@@ -237,7 +250,7 @@ processor_resources:
 		},
 		FieldSpecs: docs.FieldSpecs{
 			docs.FieldString("meta_path", "A [dot path](/docs/configuration/field_paths) indicating where to store and reference [structured metadata](#structured-metadata) about the workflow execution."),
-			docs.FieldDeprecated("stages").Map(),
+			docs.FieldDeprecated("stages").Map().Fixer(fixWorkflowStages),
 			docs.FieldString(
 				"order",
 				"An explicit declaration of branch ordered tiers, which describes the order in which parallel tiers of branches should be executed. Branches should be identified by the name as they are configured in the field `branches`. It's also possible to specify branch processors configured [as a resource](#resources).",
@@ -393,6 +406,7 @@ type resultTracker struct {
 	succeeded map[string]struct{}
 	skipped   map[string]struct{}
 	failed    map[string]string
+	path      [][]string
 	sync.Mutex
 }
 
@@ -427,6 +441,12 @@ func (r *resultTracker) Failed(k, why string) {
 	r.Unlock()
 }
 
+func (r *resultTracker) Executed(tier []string) {
+	r.Lock()
+	r.path = append(r.path, tier)
+	r.Unlock()
+}
+
 func (r *resultTracker) ToObject() map[string]interface{} {
 	succeeded := make([]interface{}, 0, len(r.succeeded))
 	skipped := make([]interface{}, 0, len(r.skipped))
@@ -458,9 +478,42 @@ func (r *resultTracker) ToObject() map[string]interface{} {
 	if len(failed) > 0 {
 		m["failed"] = failed
 	}
+	if len(r.path) > 0 {
+		path := make([]interface{}, len(r.path))
+		for i, tier := range r.path {
+			tierArr := make([]interface{}, len(tier))
+			for j, id := range tier {
+				tierArr[j] = id
+			}
+			path[i] = tierArr
+		}
+		m["path"] = path
+	}
 	return m
 }
 
+// Returns a map of branch IDs to the IDs of branches from earlier tiers of the
+// DAG that they depend on, for each branch that has a dependency.
+func workflowBranchDeps(dag [][]string, children map[string]*Branch) map[string][]string {
+	deps := map[string][]string{}
+	earlier := map[string]*Branch{}
+	for _, tier := range dag {
+		for _, id := range tier {
+			if b, exists := children[id]; exists {
+				if d := getBranchDeps(id, b.targetsUsed(), earlier); len(d) > 0 {
+					deps[id] = d
+				}
+			}
+		}
+		for _, id := range tier {
+			if b, exists := children[id]; exists {
+				earlier[id] = b
+			}
+		}
+	}
+	return deps
+}
+
 // Returns a map of enrichment IDs that should be skipped for this payload.
 func (w *Workflow) skipFromMeta(root interface{}) map[string]struct{} {
 	skipList := map[string]struct{}{}
@@ -551,9 +604,40 @@ func (w *Workflow) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 		records[i] = trackerFromTree(dag)
 	}
 
+	// Branches that are skipped for each message due to the failure of a
+	// branch they depend on with an on_error policy of skip.
+	var deps map[string][]string
+	skipOnFail := make([]map[string]struct{}, payload.Len())
+	for _, b := range children {
+		if b.onError == branchOnErrorSkip {
+			deps = workflowBranchDeps(dag, children)
+			break
+		}
+	}
+	for i := range skipOnFail {
+		skipOnFail[i] = map[string]struct{}{}
+	}
+
 	for _, layer := range dag {
 		results := make([][]types.Part, len(layer))
 		errors := make([]error, len(layer))
+		executed := make([][]bool, len(layer))
+
+		// Determine which messages have a failed dependency for each branch
+		// prior to executing them in parallel.
+		depFailed := make([][]bool, len(layer))
+		for i, id := range layer {
+			depFailed[i] = make([]bool, payload.Len())
+			for j := range skipOnFail {
+				for _, dep := range deps[id] {
+					if _, exists := skipOnFail[j][dep]; exists {
+						depFailed[i][j] = true
+						skipOnFail[j][id] = struct{}{}
+						break
+					}
+				}
+			}
+		}
 
 		wg := sync.WaitGroup{}
 		wg.Add(len(layer))
@@ -561,13 +645,15 @@ func (w *Workflow) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 			go func(id string, index int) {
 				branchMsg, branchSpans := tracing.WithChildSpans(id, propMsg.Copy())
 
+				executed[index] = make([]bool, branchMsg.Len())
 				branchParts := make([]types.Part, branchMsg.Len())
 				branchMsg.Iter(func(partIndex int, part types.Part) error {
 					// Remove errors so that they aren't propagated into the
 					// branch.
 					ClearFail(part)
-					if _, exists := skipOnMeta[partIndex][id]; !exists {
+					if _, exists := skipOnMeta[partIndex][id]; !exists && !depFailed[index][partIndex] {
 						branchParts[partIndex] = part
+						executed[index][partIndex] = true
 					}
 					return nil
 				})
@@ -591,6 +677,8 @@ func (w *Workflow) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 		wg.Wait()
 
 		for i, id := range layer {
+			skipDependants := children[id].onError == branchOnErrorSkip
+
 			var failed []branchMapError
 			err := errors[i]
 			if err == nil {
@@ -604,14 +692,40 @@ func (w *Workflow) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 				w.log.Errorf("Failed to perform enrichment '%v': %v\n", id, err)
 				for j := range records {
 					records[j].Failed(id, err.Error())
+					if skipDependants {
+						skipOnFail[j][id] = struct{}{}
+					}
 				}
 				continue
 			}
 			for _, e := range failed {
 				records[e.index].Failed(id, e.err.Error())
 			}
+			if skipDependants {
+				for j, r := range records {
+					r.Lock()
+					_, isFailed := r.failed[id]
+					r.Unlock()
+					if isFailed {
+						skipOnFail[j][id] = struct{}{}
+					}
+				}
+			}
 			w.incrStageSucc(id)
 		}
+
+		for j, r := range records {
+			var tier []string
+			for i, id := range layer {
+				if executed[i][j] {
+					tier = append(tier, id)
+				}
+			}
+			if len(tier) > 0 {
+				sort.Strings(tier)
+				r.Executed(tier)
+			}
+		}
 	}
 
 	// Finally, set the meta records of each document.
@@ -639,11 +753,14 @@ func (w *Workflow) ProcessMessage(msg types.Message) ([]types.Message, types.Res
 		})
 	} else {
 		payload.Iter(func(i int, p types.Part) error {
-			if lf := len(records[i].failed); lf > 0 {
-				failed := make([]string, 0, lf)
-				for k := range records[i].failed {
-					failed = append(failed, k)
+			var failed []string
+			for k := range records[i].failed {
+				if b, exists := children[k]; exists && b.onError == branchOnErrorContinue {
+					continue
 				}
+				failed = append(failed, k)
+			}
+			if len(failed) > 0 {
 				sort.Strings(failed)
 				FlagErr(p, fmt.Errorf("workflow branches failed: %v", failed))
 			}
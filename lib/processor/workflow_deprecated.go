@@ -2,16 +2,22 @@ package processor
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/internal/interop"
 	"github.com/Jeffail/benthos/v3/internal/tracing"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/gabs/v2"
+	"github.com/quipo/dependencysolver"
+	"gopkg.in/yaml.v3"
 )
 
 //------------------------------------------------------------------------------
@@ -377,3 +383,219 @@ func (w *workflowDeprecated) WaitForClose(timeout time.Duration) error {
 }
 
 //------------------------------------------------------------------------------
+
+func dotPathToBloblang(context, path string) string {
+	if path == "" || path == "." {
+		return context
+	}
+	var b strings.Builder
+	b.WriteString(context)
+	for _, seg := range gabs.DotPathToSlice(path) {
+		b.WriteByte('.')
+		if len(bloblPathSegment.FindString(seg)) == len(seg) {
+			b.WriteString(seg)
+		} else {
+			b.WriteString(strconv.Quote(seg))
+		}
+	}
+	return b.String()
+}
+
+var bloblPathSegment = regexp.MustCompile(`[a-zA-Z0-9_]+`)
+
+func sortedPathKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Converts the premap fields of a deprecated stage into an equivalent branch
+// request_map, where a missing mandatory source results in the message not
+// being processed by the branch.
+func stagePremapToBloblang(premap, premapOptional map[string]string) string {
+	if len(premap) == 0 && len(premapOptional) == 0 {
+		return ""
+	}
+	var lines, guards []string
+	for _, k := range sortedPathKeys(premap) {
+		src := dotPathToBloblang("this", premap[k])
+		lines = append(lines, fmt.Sprintf("%v = %v", dotPathToBloblang("root", k), src))
+		guards = append(guards, src+" == null")
+	}
+	for _, k := range sortedPathKeys(premapOptional) {
+		src := dotPathToBloblang("this", premapOptional[k])
+		lines = append(lines, fmt.Sprintf("%v = if %v != null { %v }", dotPathToBloblang("root", k), src, src))
+	}
+	if len(guards) > 0 {
+		lines = append(lines, fmt.Sprintf("root = if %v { deleted() }", strings.Join(guards, " || ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Converts the postmap fields of a deprecated stage into an equivalent branch
+// result_map, where a missing mandatory source results in an error.
+func stagePostmapToBloblang(postmap, postmapOptional map[string]string) string {
+	if len(postmap) == 0 && len(postmapOptional) == 0 {
+		return "root = this"
+	}
+	var lines []string
+	for _, k := range sortedPathKeys(postmap) {
+		src := dotPathToBloblang("this", postmap[k])
+		if src != "this" {
+			src += ".not_null()"
+		}
+		lines = append(lines, fmt.Sprintf("%v = %v", dotPathToBloblang("root", k), src))
+	}
+	for _, k := range sortedPathKeys(postmapOptional) {
+		src := dotPathToBloblang("this", postmapOptional[k])
+		lines = append(lines, fmt.Sprintf("%v = if %v != null { %v }", dotPathToBloblang("root", k), src, src))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normaliseStagePath(p string) string {
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+// fixWorkflowStages attempts to replace the deprecated stages of a workflow
+// with equivalent branches, where the order of execution is resolved from the
+// stages and made explicit.
+//
+// TODO: V4 Remove this
+func fixWorkflowStages(ctx docs.LintContext, parent, value *yaml.Node) []docs.Lint {
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		switch parent.Content[i].Value {
+		case "branches", "order", "branch_resources":
+			if len(parent.Content[i+1].Content) > 0 {
+				return nil
+			}
+		}
+	}
+	if value.Kind != yaml.MappingNode || len(value.Content) == 0 {
+		return nil
+	}
+
+	type stageConf struct {
+		Dependencies    []string          `yaml:"dependencies"`
+		Conditions      []yaml.Node       `yaml:"conditions"`
+		Parts           []int             `yaml:"parts"`
+		Premap          map[string]string `yaml:"premap"`
+		PremapOptional  map[string]string `yaml:"premap_optional"`
+		Postmap         map[string]string `yaml:"postmap"`
+		PostmapOptional map[string]string `yaml:"postmap_optional"`
+	}
+
+	branchesNode := &yaml.Node{Kind: yaml.MappingNode}
+	var entries []dependencysolver.Entry
+
+	stages := map[string]stageConf{}
+	for i := 0; i < len(value.Content)-1; i += 2 {
+		var conf stageConf
+		if err := value.Content[i+1].Decode(&conf); err != nil {
+			return nil
+		}
+		if len(conf.Conditions) > 0 || len(conf.Parts) > 0 {
+			return nil
+		}
+		stages[value.Content[i].Value] = conf
+	}
+
+	for i := 0; i < len(value.Content)-1; i += 2 {
+		id, stageNode := value.Content[i].Value, value.Content[i+1]
+		conf := stages[id]
+
+		wanted := append([]string{}, conf.Dependencies...)
+		for _, v := range conf.Premap {
+			wanted = append(wanted, normaliseStagePath(v))
+		}
+		for _, v := range conf.PremapOptional {
+			wanted = append(wanted, normaliseStagePath(v))
+		}
+
+		var deps []string
+		for k, v := range stages {
+			if k == id {
+				continue
+			}
+			var provided []string
+			for p := range v.Postmap {
+				provided = append(provided, normaliseStagePath(p))
+			}
+			for p := range v.PostmapOptional {
+				provided = append(provided, normaliseStagePath(p))
+			}
+		depSearch:
+			for _, tp := range provided {
+				for _, tn := range wanted {
+					if strings.HasPrefix(tn, tp) {
+						deps = append(deps, k)
+						break depSearch
+					}
+				}
+			}
+		}
+		entries = append(entries, dependencysolver.Entry{ID: id, Deps: deps})
+
+		branchNode := &yaml.Node{Kind: yaml.MappingNode}
+		if reqMap := stagePremapToBloblang(conf.Premap, conf.PremapOptional); reqMap != "" {
+			branchNode.Content = append(branchNode.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "request_map"},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: reqMap},
+			)
+		}
+		for j := 0; j < len(stageNode.Content)-1; j += 2 {
+			if stageNode.Content[j].Value == "processors" {
+				branchNode.Content = append(branchNode.Content, stageNode.Content[j], stageNode.Content[j+1])
+			}
+		}
+		branchNode.Content = append(branchNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "result_map"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: stagePostmapToBloblang(conf.Postmap, conf.PostmapOptional)},
+		)
+		branchesNode.Content = append(branchesNode.Content, value.Content[i], branchNode)
+	}
+
+	layers := dependencysolver.LayeredTopologicalSort(entries)
+	resolved := 0
+	for _, l := range layers {
+		sort.Strings(l)
+		resolved += len(l)
+	}
+	if resolved != len(stages) {
+		return nil
+	}
+	orderNode := &yaml.Node{Kind: yaml.SequenceNode}
+	if err := orderNode.Encode(layers); err != nil {
+		return nil
+	}
+
+	var stagesLine int
+	newNodes := make([]*yaml.Node, 0, len(parent.Content)+2)
+	for i := 0; i < len(parent.Content)-1; i += 2 {
+		switch parent.Content[i].Value {
+		case "branches", "order", "branch_resources":
+			continue
+		case "stages":
+			stagesLine = parent.Content[i].Line
+			newNodes = append(newNodes,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "order", HeadComment: parent.Content[i].HeadComment},
+				orderNode,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "branches"},
+				branchesNode,
+			)
+			continue
+		}
+		newNodes = append(newNodes, parent.Content[i], parent.Content[i+1])
+	}
+	parent.Content = newNodes
+
+	return []docs.Lint{
+		docs.NewLintFix(stagesLine, "replaced deprecated field stages with equivalent branches"),
+	}
+}
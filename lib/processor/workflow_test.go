@@ -295,9 +295,9 @@ func TestWorkflows(t *testing.T) {
 				msg(`{"foo":"5"}`),
 			},
 			output: []mockMsg{
-				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"}}}}`),
-				msg(`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"}}}}`),
-				msg(`{"bar":5,"foo":"5","meta":{"workflow":{"succeeded":["0"]}}}`),
+				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"]]}}}`),
+				msg(`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"},"path":[["0"]]}}}`),
+				msg(`{"bar":5,"foo":"5","meta":{"workflow":{"path":[["0"]],"succeeded":["0"]}}}`),
 			},
 		},
 		{
@@ -324,9 +324,9 @@ func TestWorkflows(t *testing.T) {
 				msg(`{"foo":"5"}`),
 			},
 			output: []mockMsg{
-				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`),
-				msg(`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`),
-				msg(`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"succeeded":["0","1","2"]}}}`),
+				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`),
+				msg(`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`),
+				msg(`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"path":[["0"],["1"],["2"]],"succeeded":["0","1","2"]}}}`),
 			},
 		},
 		{
@@ -353,9 +353,9 @@ func TestWorkflows(t *testing.T) {
 				msg(`{"meta":{"workflow":{"succeeded":["1"]}},"baz":9}`),
 			},
 			output: []mockMsg{
-				msg(`{"baz":2,"buz":4,"meta":{"workflow":{"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`),
-				msg(`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`),
-				msg(`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`),
+				msg(`{"baz":2,"buz":4,"meta":{"workflow":{"path":[["2"]],"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`),
+				msg(`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"path":[["1"],["2"]],"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`),
+				msg(`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"],["2"]],"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`),
 			},
 		},
 		{
@@ -383,8 +383,8 @@ func TestWorkflows(t *testing.T) {
 				msg(`not even a json object`),
 			},
 			output: []mockMsg{
-				msg(`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"succeeded":["0","1","2"]}}}`),
-				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"}}}}`),
+				msg(`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"path":[["0","1"],["2"]],"succeeded":["0","1","2"]}}}`),
+				msg(`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"},"path":[["0","1"],["2"]]}}}`),
 				msg(
 					`not even a json object`,
 					FailFlagKey,
@@ -418,14 +418,14 @@ func TestWorkflows(t *testing.T) {
 			},
 			output: []mockMsg{
 				msg(
-					`{"id":0,"meta":{"workflow":{"succeeded":["0"]}},"name":"first","result":"FIRST"}`,
+					`{"id":0,"meta":{"workflow":{"path":[["0"]],"succeeded":["0"]}},"name":"first","result":"FIRST"}`,
 					FailFlagKey, "this is a pre-existing failure",
 				),
 				msg(
-					`{"failme":true,"id":1,"meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): this is a branch error"}}},"name":"second"}`,
+					`{"failme":true,"id":1,"meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): this is a branch error"},"path":[["0"]]}},"name":"second"}`,
 				),
 				msg(
-					`{"failme":true,"id":2,"meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): this is a branch error"}}},"name":"third"}`,
+					`{"failme":true,"id":2,"meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): this is a branch error"},"path":[["0"]]}},"name":"third"}`,
 					FailFlagKey, "this is a pre-existing failure",
 				),
 			},
@@ -519,9 +519,9 @@ func TestWorkflowsWithResources(t *testing.T) {
 				`{"foo":"5"}`,
 			},
 			output: []string{
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"}}}}`,
-				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"}}}}`,
-				`{"bar":5,"foo":"5","meta":{"workflow":{"succeeded":["0"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"]]}}}`,
+				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"},"path":[["0"]]}}}`,
+				`{"bar":5,"foo":"5","meta":{"workflow":{"path":[["0"]],"succeeded":["0"]}}}`,
 			},
 		},
 		{
@@ -551,9 +551,9 @@ func TestWorkflowsWithResources(t *testing.T) {
 				`{"foo":"5"}`,
 			},
 			output: []string{
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-				`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"succeeded":["0","1","2"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+				`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"path":[["0"],["1"],["2"]],"succeeded":["0","1","2"]}}}`,
 			},
 		},
 		{
@@ -583,9 +583,9 @@ func TestWorkflowsWithResources(t *testing.T) {
 				`{"meta":{"workflow":{"succeeded":["1"]}},"baz":9}`,
 			},
 			output: []string{
-				`{"baz":2,"buz":4,"meta":{"workflow":{"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`,
-				`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`,
-				`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`,
+				`{"baz":2,"buz":4,"meta":{"workflow":{"path":[["2"]],"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`,
+				`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"path":[["1"],["2"]],"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`,
+				`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"],["2"]],"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`,
 			},
 		},
 		{
@@ -616,8 +616,8 @@ func TestWorkflowsWithResources(t *testing.T) {
 				`not even a json object`,
 			},
 			output: []string{
-				`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"succeeded":["0","1","2"]}}}`,
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"}}}}`,
+				`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"path":[["0","1"],["2"]],"succeeded":["0","1","2"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"},"path":[["0","1"],["2"]]}}}`,
 				`not even a json object`,
 			},
 		},
@@ -687,9 +687,9 @@ func TestWorkflowsParallel(t *testing.T) {
 		`{"foo":"5"}`,
 	}
 	output := []string{
-		`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-		`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-		`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"succeeded":["0","1","2"]}}}`,
+		`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+		`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+		`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"path":[["0"],["1"],["2"]],"succeeded":["0","1","2"]}}}`,
 	}
 
 	conf := NewConfig()
@@ -766,9 +766,9 @@ func TestWorkflowsWithOrderResources(t *testing.T) {
 				`{"foo":"5"}`,
 			},
 			output: []string{
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"}}}}`,
-				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"}}}}`,
-				`{"bar":5,"foo":"5","meta":{"workflow":{"succeeded":["0"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"]]}}}`,
+				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax"},"path":[["0"]]}}}`,
+				`{"bar":5,"foo":"5","meta":{"workflow":{"path":[["0"]],"succeeded":["0"]}}}`,
 			},
 		},
 		{
@@ -803,9 +803,9 @@ func TestWorkflowsWithOrderResources(t *testing.T) {
 				`{"foo":"5"}`,
 			},
 			output: []string{
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"}}}}`,
-				`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"succeeded":["0","1","2"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+				`{"foo":"not a number","meta":{"workflow":{"failed":{"0":"result mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: strconv.ParseFloat: parsing \"not a number\": invalid syntax","1":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.baz`" + `: value is null"},"path":[["0"],["1"],["2"]]}}}`,
+				`{"bar":5,"baz":10,"buz":12,"foo":"5","meta":{"workflow":{"path":[["0"],["1"],["2"]],"succeeded":["0","1","2"]}}}`,
 			},
 		},
 		{
@@ -840,9 +840,9 @@ func TestWorkflowsWithOrderResources(t *testing.T) {
 				`{"meta":{"workflow":{"succeeded":["1"]}},"baz":9}`,
 			},
 			output: []string{
-				`{"baz":2,"buz":4,"meta":{"workflow":{"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`,
-				`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`,
-				`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`,
+				`{"baz":2,"buz":4,"meta":{"workflow":{"path":[["2"]],"previous":{"apply":["2"]},"skipped":["0","1"],"succeeded":["2"]}}}`,
+				`{"bar":3,"baz":8,"buz":10,"meta":{"workflow":{"path":[["1"],["2"]],"previous":{"skipped":["0"]},"skipped":["0"],"succeeded":["1","2"]}}}`,
+				`{"baz":9,"buz":11,"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null"},"path":[["0"],["2"]],"previous":{"succeeded":["1"]},"skipped":["1"],"succeeded":["2"]}}}`,
 			},
 		},
 		{
@@ -877,8 +877,8 @@ func TestWorkflowsWithOrderResources(t *testing.T) {
 				`not even a json object`,
 			},
 			output: []string{
-				`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"succeeded":["0","1","2"]}}}`,
-				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"}}}}`,
+				`{"bar":4,"baz":5,"buz":9,"foo":2,"meta":{"workflow":{"path":[["0","1"],["2"]],"succeeded":["0","1","2"]}}}`,
+				`{"meta":{"workflow":{"failed":{"0":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","1":"request mapping failed: failed assignment (line 1): field ` + "`this.foo`" + `: value is null","2":"request mapping failed: failed assignment (line 1): field ` + "`this.bar`" + `: value is null"},"path":[["0","1"],["2"]]}}}`,
 				`not even a json object`,
 			},
 		},
@@ -917,3 +917,103 @@ func TestWorkflowsWithOrderResources(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkflowsOnError(t *testing.T) {
+	newBranchConf := func(requestMap, procMap, resultMap, onError string) BranchConfig {
+		branchConf := NewBranchConfig()
+		branchConf.RequestMap = requestMap
+		branchConf.ResultMap = resultMap
+		branchConf.OnError = onError
+		proc := NewConfig()
+		proc.Type = TypeBloblang
+		proc.Bloblang = BloblangConfig(procMap)
+		branchConf.Processors = append(branchConf.Processors, proc)
+		return branchConf
+	}
+
+	tests := map[string]struct {
+		onError  string
+		metaPath string
+		output   []string
+		errs     []string
+	}{
+		"fail": {
+			onError:  "fail",
+			metaPath: "meta.workflow",
+			output: []string{
+				`{"a":2,"b":3,"c":4,"id":1,"meta":{"workflow":{"path":[["a"],["b"],["c"]],"succeeded":["a","b","c"]}}}`,
+				`{"b":1,"c":2,"fail":true,"id":2,"meta":{"workflow":{"failed":{"a":"processors failed: failed assignment (line 1): nope"},"path":[["a"],["b"],["c"]],"succeeded":["b","c"]}}}`,
+			},
+			errs: []string{"", ""},
+		},
+		"skip": {
+			onError:  "skip",
+			metaPath: "meta.workflow",
+			output: []string{
+				`{"a":2,"b":3,"c":4,"id":1,"meta":{"workflow":{"path":[["a"],["b"],["c"]],"succeeded":["a","b","c"]}}}`,
+				`{"fail":true,"id":2,"meta":{"workflow":{"failed":{"a":"processors failed: failed assignment (line 1): nope"},"path":[["a"]],"skipped":["b","c"]}}}`,
+			},
+			errs: []string{"", ""},
+		},
+		"fail without meta": {
+			onError: "fail",
+			output: []string{
+				`{"a":2,"b":3,"c":4,"id":1}`,
+				`{"b":1,"c":2,"fail":true,"id":2}`,
+			},
+			errs: []string{"", "workflow branches failed: [a]"},
+		},
+		"continue without meta": {
+			onError: "continue",
+			output: []string{
+				`{"a":2,"b":3,"c":4,"id":1}`,
+				`{"b":1,"c":2,"fail":true,"id":2}`,
+			},
+			errs: []string{"", ""},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			conf := NewConfig()
+			conf.Workflow.MetaPath = test.metaPath
+			conf.Workflow.Branches["a"] = newBranchConf(
+				`root = this`,
+				`root = if this.fail.or(false) { throw("nope") } else { this.id + 1 }`,
+				`root.a = this`,
+				test.onError,
+			)
+			conf.Workflow.Branches["b"] = newBranchConf(
+				`root = this.a.or(0)`,
+				`root = this + 1`,
+				`root.b = this`,
+				"fail",
+			)
+			conf.Workflow.Branches["c"] = newBranchConf(
+				`root = this.b`,
+				`root = this + 1`,
+				`root.c = this`,
+				"fail",
+			)
+
+			p, err := NewWorkflow(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+
+			msgs, res := p.ProcessMessage(message.New([][]byte{
+				[]byte(`{"id":1}`),
+				[]byte(`{"id":2,"fail":true}`),
+			}))
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+			require.Equal(t, len(test.output), msgs[0].Len())
+			for i, exp := range test.output {
+				assert.Equal(t, exp, string(msgs[0].Get(i).Get()), "part: %v", i)
+				assert.Equal(t, test.errs[i], GetFail(msgs[0].Get(i)), "part: %v", i)
+			}
+
+			p.CloseAsync()
+			assert.NoError(t, p.WaitForClose(time.Second))
+		})
+	}
+}
@@ -365,7 +365,7 @@ func deprecatedExecute(configPath, testSuffix string) {
 	}
 
 	if depFlags.lintConfig {
-		confReader := readConfig(configPath, false, nil, nil, nil)
+		confReader := readConfig([]string{configPath}, "", false, false, nil, nil, nil)
 		lints, err := confReader.Read(&conf)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
@@ -376,7 +376,7 @@ func deprecatedExecute(configPath, testSuffix string) {
 
 	// If the user wants the configuration to be printed we do so and then exit.
 	if depFlags.showConfigJSON || depFlags.showConfigYAML {
-		readConfig(configPath, false, nil, nil, nil)
+		readConfig([]string{configPath}, "", false, false, nil, nil, nil)
 		cmdDeprecatedPrintConfig(&conf, depFlags.examples, depFlags.showAll, depFlags.showConfigJSON)
 	}
 
@@ -410,6 +410,6 @@ func deprecatedExecute(configPath, testSuffix string) {
 		if len(depFlags.streamsDir) > 0 {
 			dirs = append(dirs, depFlags.streamsDir)
 		}
-		os.Exit(cmdService(configPath, nil, nil, "", depFlags.strictConfig, false, false, depFlags.streamsMode, dirs))
+		os.Exit(cmdService([]string{configPath}, "", nil, nil, "", depFlags.strictConfig, false, false, 0, depFlags.streamsMode, dirs))
 	}
 }
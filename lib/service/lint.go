@@ -19,6 +19,7 @@ import (
 
 var red = color.New(color.FgRed).SprintFunc()
 var yellow = color.New(color.FgYellow).SprintFunc()
+var green = color.New(color.FgGreen).SprintFunc()
 
 func resolveLintPath(path string) (string, bool) {
 	recurse := false
@@ -37,12 +38,92 @@ type pathLint struct {
 	source string
 	line   int
 	lint   string
+	fix    string
 	err    string
 }
 
-func lintFile(path string, rejectDeprecated bool) (pathLints []pathLint) {
+type lintOptions struct {
+	rejectDeprecated bool
+	fix              bool
+	rules            []*config.LintRule
+}
+
+func lintRules(source string, line int, configBytes []byte, rules []*config.LintRule) (pathLints []pathLint) {
+	for _, rule := range rules {
+		lints, err := rule.Lint(configBytes)
+		if err != nil {
+			pathLints = append(pathLints, pathLint{
+				source: source,
+				line:   line,
+				err:    err.Error(),
+			})
+			continue
+		}
+		for _, l := range lints {
+			pathLints = append(pathLints, pathLint{
+				source: source,
+				line:   line,
+				lint:   l,
+			})
+		}
+	}
+	return
+}
+
+func fixFile(path string, rejectDeprecated bool) (pathLints []pathLint) {
+	info, err := os.Stat(path)
+	if err != nil {
+		pathLints = append(pathLints, pathLint{
+			source: path,
+			err:    err.Error(),
+		})
+		return
+	}
+	rawBytes, err := os.ReadFile(path)
+	if err != nil {
+		pathLints = append(pathLints, pathLint{
+			source: path,
+			err:    err.Error(),
+		})
+		return
+	}
+
+	lintCtx := docs.NewLintContext()
+	lintCtx.RejectDeprecated = rejectDeprecated
+	fixedBytes, fixes, err := config.FixV2(lintCtx, rawBytes)
+	if err != nil {
+		pathLints = append(pathLints, pathLint{
+			source: path,
+			err:    err.Error(),
+		})
+		return
+	}
+	if len(fixes) == 0 {
+		return
+	}
+	if err := os.WriteFile(path, fixedBytes, info.Mode()); err != nil {
+		pathLints = append(pathLints, pathLint{
+			source: path,
+			err:    err.Error(),
+		})
+		return
+	}
+	for _, f := range fixes {
+		pathLints = append(pathLints, pathLint{
+			source: path,
+			fix:    f,
+		})
+	}
+	return
+}
+
+func lintFile(path string, opts lintOptions) (pathLints []pathLint) {
+	if opts.fix {
+		pathLints = fixFile(path, opts.rejectDeprecated)
+	}
+
 	conf := config.New()
-	lints, err := config.ReadV2(path, true, rejectDeprecated, &conf)
+	lints, err := config.ReadV2(path, true, opts.rejectDeprecated, &conf)
 	if err != nil {
 		pathLints = append(pathLints, pathLint{
 			source: path,
@@ -56,10 +137,22 @@ func lintFile(path string, rejectDeprecated bool) (pathLints []pathLint) {
 			lint:   l,
 		})
 	}
+
+	if len(opts.rules) > 0 {
+		configBytes, err := config.ReadWithJSONPointers(path, true)
+		if err != nil {
+			pathLints = append(pathLints, pathLint{
+				source: path,
+				err:    err.Error(),
+			})
+			return
+		}
+		pathLints = append(pathLints, lintRules(path, 0, configBytes, opts.rules)...)
+	}
 	return
 }
 
-func lintMDSnippets(path string, rejectDeprecated bool) (pathLints []pathLint) {
+func lintMDSnippets(path string, opts lintOptions) (pathLints []pathLint) {
 	rawBytes, err := os.ReadFile(path)
 	if err != nil {
 		pathLints = append(pathLints, pathLint{
@@ -99,7 +192,7 @@ func lintMDSnippets(path string, rejectDeprecated bool) (pathLints []pathLint) {
 			})
 		} else {
 			lintCtx := docs.NewLintContext()
-			lintCtx.RejectDeprecated = rejectDeprecated
+			lintCtx.RejectDeprecated = opts.rejectDeprecated
 			lints, err := config.LintV2(lintCtx, configBytes)
 			if err != nil {
 				pathLints = append(pathLints, pathLint{
@@ -115,6 +208,7 @@ func lintMDSnippets(path string, rejectDeprecated bool) (pathLints []pathLint) {
 					lint:   l,
 				})
 			}
+			pathLints = append(pathLints, lintRules(path, snippetLine, configBytes, opts.rules)...)
 		}
 
 		if nextSnippet = bytes.Index(rawBytes[endOfSnippet:], []byte("```yaml")); nextSnippet != -1 {
@@ -137,13 +231,42 @@ Exits with a status code 1 if any linting errors are detected:
   benthos lint ./configs/...
 
 If a path ends with '...' then Benthos will walk the target and lint any
-files with the .yaml or .yml extension.`[1:],
+files with the .yaml or .yml extension.
+
+When the --fix flag is set Benthos will attempt to automatically fix problems
+within config files (markdown snippets are left untouched), rewriting them in
+place. Deprecated fields are replaced with modern equivalents where possible,
+and fields that match their default value are removed. Files are rewritten
+with standard formatting, and therefore files containing comments are not
+fixed. Any problems that could not be fixed are reported as usual.
+
+Custom linting rules can be added with the --rules flag, where each rule is a
+file containing a Bloblang mapping that is executed against the parsed config.
+The mapping should result in either nothing, a string describing a lint, an
+object with the fields 'what' and (optionally) 'path', or an array of these:
+
+  benthos lint --rules ./rules/no_stdout.blobl ./configs/...
+
+Where ./rules/no_stdout.blobl might look like this:
+
+  root = if this.output.exists("stdout") {
+    { "what": "stdout outputs are not allowed", "path": "output.stdout" }
+  }`[1:],
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  "deprecated",
 				Value: false,
 				Usage: "Print linting errors for the presence of deprecated fields.",
 			},
+			&cli.BoolFlag{
+				Name:  "fix",
+				Value: false,
+				Usage: "Attempt to automatically fix linting errors and rewrite config files in place.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "rules",
+				Usage: "A list of paths (globs supported) to Bloblang mappings to execute as custom linting rules.",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			var targets []string
@@ -171,11 +294,31 @@ files with the .yaml or .yml extension.`[1:],
 					targets = append(targets, p)
 				}
 			}
-			if conf := c.String("config"); len(conf) > 0 {
-				targets = append(targets, conf)
+			for _, conf := range configPathsFlag(c) {
+				if len(conf) > 0 {
+					targets = append(targets, conf)
+				}
 			}
 
-			rejectDeprecated := c.Bool("deprecated")
+			opts := lintOptions{
+				rejectDeprecated: c.Bool("deprecated"),
+				fix:              c.Bool("fix"),
+			}
+			for _, rulesGlob := range c.StringSlice("rules") {
+				rulePaths, err := filepath.Glob(rulesGlob)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to resolve rules path glob: %v\n", err)
+					os.Exit(1)
+				}
+				for _, rulePath := range rulePaths {
+					rule, err := config.ReadLintRule(rulePath)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to read rule: %v\n", err)
+						os.Exit(1)
+					}
+					opts.rules = append(opts.rules, rule)
+				}
+			}
 
 			var pathLintMut sync.Mutex
 			var pathLints []pathLint
@@ -194,9 +337,9 @@ files with the .yaml or .yml extension.`[1:],
 						}
 						var lints []pathLint
 						if path.Ext(target) == ".md" {
-							lints = lintMDSnippets(target, rejectDeprecated)
+							lints = lintMDSnippets(target, opts)
 						} else {
-							lints = lintFile(target, rejectDeprecated)
+							lints = lintFile(target, opts)
 						}
 						if len(lints) > 0 {
 							pathLintMut.Lock()
@@ -207,10 +350,13 @@ files with the .yaml or .yml extension.`[1:],
 				}(i)
 			}
 			wg.Wait()
-			if len(pathLints) == 0 {
-				os.Exit(0)
-			}
+			failed := false
 			for _, lint := range pathLints {
+				if len(lint.fix) > 0 {
+					fmt.Fprintf(os.Stderr, "%v: %v\n", lint.source, green("fixed "+lint.fix))
+					continue
+				}
+				failed = true
 				message := yellow(lint.lint)
 				if len(lint.err) > 0 {
 					message = red(lint.err)
@@ -221,6 +367,9 @@ files with the .yaml or .yml extension.`[1:],
 					fmt.Fprintf(os.Stderr, "%v: %v\n", lint.source, message)
 				}
 			}
+			if !failed {
+				os.Exit(0)
+			}
 			os.Exit(1)
 			return nil
 		},
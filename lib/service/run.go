@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"runtime/debug"
+	"strings"
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang/parser"
 	"github.com/Jeffail/benthos/v3/internal/cli/studio"
@@ -109,6 +111,46 @@ func cmdVersion() {
 
 //------------------------------------------------------------------------------
 
+// configPaths is a flag value that collects each value of a flag that can be
+// specified multiple times. Unlike cli.StringSliceFlag values are not split by
+// commas, which are valid within file paths.
+type configPaths []string
+
+// The flag values of aliases are copied with a serialized form, which replaces
+// the paths rather than appending to them.
+const configPathsSerializedPrefix = "configpaths:"
+
+func (c *configPaths) Set(value string) error {
+	if strings.HasPrefix(value, configPathsSerializedPrefix) {
+		var paths []string
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(value, configPathsSerializedPrefix)), &paths); err != nil {
+			return err
+		}
+		*c = paths
+		return nil
+	}
+	*c = append(*c, value)
+	return nil
+}
+
+func (c *configPaths) String() string {
+	return strings.Join(*c, ", ")
+}
+
+func (c *configPaths) Serialize() string {
+	pathsBytes, _ := json.Marshal([]string(*c))
+	return configPathsSerializedPrefix + string(pathsBytes)
+}
+
+func configPathsFlag(c *cli.Context) []string {
+	if paths, ok := c.Generic("config").(*configPaths); ok && paths != nil {
+		return *paths
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
 // RunWithOpts runs the Benthos service after first applying opt funcs, which
 // are used for specify service customisations.
 func RunWithOpts(opts ...func()) {
@@ -145,11 +187,16 @@ func Run() {
 			Aliases: []string{"s"},
 			Usage:   "set a field (identified by a dot path) in the main configuration file, e.g. `\"metrics.type=prometheus\"`",
 		},
-		&cli.StringFlag{
+		&cli.GenericFlag{
 			Name:    "config",
 			Aliases: []string{"c"},
-			Value:   "",
-			Usage:   "a path to a configuration file",
+			Value:   &configPaths{},
+			Usage:   "a path to a configuration file, can be specified multiple times in order to merge subsequent files on top of the first",
+		},
+		&cli.StringFlag{
+			Name:  "profile",
+			Value: "",
+			Usage: "the name of a profile from the profiles field of the config to merge on top of it",
 		},
 		&cli.StringSliceFlag{
 			Name:    "resources",
@@ -172,6 +219,11 @@ func Run() {
 			Value:   false,
 			Usage:   "EXPERIMENTAL: watch config files for changes and automatically apply them",
 		},
+		&cli.DurationFlag{
+			Name:  "secrets-refresh",
+			Value: 0,
+			Usage: "EXPERIMENTAL: the period at which secrets referenced within config files are refreshed, where configs referencing changed secrets are reloaded, disabled by default",
+		},
 	}
 	if len(customFlags) > 0 {
 		flags = append(flags, customFlags...)
@@ -232,13 +284,15 @@ Either run Benthos as a stream processor or choose a command:
 				os.Exit(1)
 			}
 			os.Exit(cmdService(
-				c.String("config"),
+				configPathsFlag(c),
+				c.String("profile"),
 				c.StringSlice("resources"),
 				c.StringSlice("set"),
 				c.String("log.level"),
 				!c.Bool("chilled"),
 				c.Bool("watcher"),
 				false,
+				c.Duration("secrets-refresh"),
 				false,
 				nil,
 			))
@@ -253,9 +307,31 @@ This simple command is useful for sanity checking a config if it isn't
 behaving as expected, as it shows you a normalised version after environment
 variables have been resolved:
 
-  benthos -c ./config.yaml echo | less`[1:],
+  benthos -c ./config.yaml echo | less
+
+When multiple config files or a profile are used the --resolved flag prints
+the merged config without defaults, where each value is annotated with the
+file it originated from:
+
+  benthos -c ./base.yaml -c ./prod.yaml --profile eu echo --resolved`[1:],
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "resolved",
+						Value: false,
+						Usage: "Print the merged config fields that were explicitly set, annotated with the file each originated from",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					confReader := readConfig(c.String("config"), false, c.StringSlice("resources"), nil, c.StringSlice("set"))
+					confReader := readConfig(configPathsFlag(c), c.String("profile"), false, false, c.StringSlice("resources"), nil, c.StringSlice("set"))
+					if c.Bool("resolved") {
+						resolvedYAML, err := confReader.ResolvedYAML()
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
+							os.Exit(1)
+						}
+						fmt.Println(string(resolvedYAML))
+						return nil
+					}
 					if _, err := confReader.Read(&conf); err != nil {
 						fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
 						os.Exit(1)
@@ -309,13 +385,15 @@ https://benthos.dev/docs/guides/streams_mode/about`[1:],
 				},
 				Action: func(c *cli.Context) error {
 					os.Exit(cmdService(
-						c.String("config"),
+						configPathsFlag(c),
+						c.String("profile"),
 						c.StringSlice("resources"),
 						c.StringSlice("set"),
 						c.String("log.level"),
 						!c.Bool("chilled"),
 						c.Bool("watcher"),
 						!c.Bool("no-api"),
+						c.Duration("secrets-refresh"),
 						true,
 						c.Args().Slice(),
 					))
@@ -356,7 +434,7 @@ https://benthos.dev/docs/guides/streams_mode/about`[1:],
 		}
 
 		deprecatedExecute(*configPath, testSuffix)
-		os.Exit(cmdService(*configPath, nil, nil, "", false, false, false, false, nil))
+		os.Exit(cmdService([]string{*configPath}, "", nil, nil, "", false, false, false, 0, false, nil))
 		return nil
 	}
 
@@ -16,6 +16,7 @@ import (
 
 	iconfig "github.com/Jeffail/benthos/v3/internal/config"
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/secrets"
 	"github.com/Jeffail/benthos/v3/lib/api"
 	"github.com/Jeffail/benthos/v3/lib/config"
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -75,6 +76,33 @@ type stoppable interface {
 	Stop(timeout time.Duration) error
 }
 
+// pausable is implemented by streams that can be paused, resumed and drained
+// of in-flight messages.
+type pausable interface {
+	Pause() bool
+	Resume() bool
+	IsPaused() bool
+	Drain(timeout time.Duration) error
+}
+
+// streamsPausable adapts a streams manager to the pausable interface by
+// applying each operation to all of its streams.
+type streamsPausable struct {
+	*strmmgr.Type
+}
+
+func (s streamsPausable) Pause() bool {
+	return s.PauseAll()
+}
+
+func (s streamsPausable) Resume() bool {
+	return s.ResumeAll()
+}
+
+func (s streamsPausable) Drain(timeout time.Duration) error {
+	return s.DrainAll(timeout)
+}
+
 // ManagerInitFunc is a function to be called once the Benthos service manager,
 // which manages resources shared across all components, is initialised. This is
 // a useful time to add additional resources that might be required for custom
@@ -96,7 +124,11 @@ func OptOnManagerInit(fn ManagerInitFunc) func() {
 
 //------------------------------------------------------------------------------
 
-func readConfig(path string, streamsMode bool, resourcesPaths, streamsPaths, overrides []string) *iconfig.Reader {
+func readConfig(paths []string, profile string, streamsMode, resolveSecrets bool, resourcesPaths, streamsPaths, overrides []string) *iconfig.Reader {
+	var path string
+	if len(paths) > 0 {
+		path, paths = paths[0], paths[1:]
+	}
 	if path == "" {
 		// Iterate default config paths
 		for _, dpath := range []string{
@@ -112,12 +144,17 @@ func readConfig(path string, streamsMode bool, resourcesPaths, streamsPaths, ove
 		}
 	}
 	opts := []iconfig.OptFunc{
+		iconfig.OptAddOverlays(paths...),
+		iconfig.OptSetProfile(profile),
 		iconfig.OptAddOverrides(overrides...),
 		iconfig.OptTestSuffix(testSuffix),
 	}
 	if streamsMode {
 		opts = append(opts, iconfig.OptSetStreamPaths(streamsPaths...))
 	}
+	if resolveSecrets {
+		opts = append(opts, iconfig.OptResolveSecrets())
+	}
 	return iconfig.NewReader(path, resourcesPaths, opts...)
 }
 
@@ -125,6 +162,7 @@ func readConfig(path string, streamsMode bool, resourcesPaths, streamsPaths, ove
 
 func initStreamsMode(
 	strict, watching, enableAPI bool,
+	secretsRefresh time.Duration,
 	confReader *iconfig.Reader,
 	strmAPITimeout time.Duration,
 	manager *manager.Type,
@@ -187,6 +225,12 @@ func initStreamsMode(
 			os.Exit(1)
 		}
 	}
+	if secretsRefresh > 0 {
+		if err := confReader.BeginSecretsRefresh(manager, strict, secretsRefresh); err != nil {
+			logger.Errorf("Failed to create secrets refresh: %v", err)
+			os.Exit(1)
+		}
+	}
 	return streamMgr
 }
 
@@ -208,6 +252,51 @@ func (s *swappableStopper) Stop(timeout time.Duration) error {
 	return s.current.Stop(timeout)
 }
 
+func (s *swappableStopper) Pause() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if p, ok := s.current.(pausable); ok {
+		return p.Pause()
+	}
+	return false
+}
+
+func (s *swappableStopper) Resume() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if p, ok := s.current.(pausable); ok {
+		return p.Resume()
+	}
+	return false
+}
+
+func (s *swappableStopper) IsPaused() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if p, ok := s.current.(pausable); ok {
+		return p.IsPaused()
+	}
+	return false
+}
+
+func (s *swappableStopper) Drain(timeout time.Duration) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.stopped {
+		return nil
+	}
+
+	s.stopped = true
+	if p, ok := s.current.(pausable); ok {
+		return p.Drain(timeout)
+	}
+	return s.current.Stop(timeout)
+}
+
 func (s *swappableStopper) Replace(fn func() (stoppable, error)) error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -232,6 +321,7 @@ func (s *swappableStopper) Replace(fn func() (stoppable, error)) error {
 
 func initNormalMode(
 	strict, watching bool,
+	secretsRefresh time.Duration,
 	confReader *iconfig.Reader,
 	manager *manager.Type,
 	logger log.Modular,
@@ -240,17 +330,20 @@ func initNormalMode(
 	stoppedChan = make(chan struct{})
 
 	streamInit := func() (stoppable, error) {
-		return stream.New(
-			conf.Config,
+		opts := []func(*stream.Type){
 			stream.OptSetLogger(logger),
 			stream.OptSetStats(stats),
 			stream.OptSetManager(manager),
 			stream.OptOnClose(func() {
-				if !watching {
+				if !watching && secretsRefresh <= 0 {
 					close(stoppedChan)
 				}
 			}),
-		)
+		}
+		if tout, err := time.ParseDuration(conf.SystemCloseTimeout); err == nil {
+			opts = append(opts, stream.OptSetShutdownTimeout(tout))
+		}
+		return stream.New(conf.Config, opts...)
 	}
 
 	var stoppableStream swappableStopper
@@ -284,21 +377,29 @@ func initNormalMode(
 			os.Exit(1)
 		}
 	}
+	if secretsRefresh > 0 {
+		if err := confReader.BeginSecretsRefresh(manager, strict, secretsRefresh); err != nil {
+			logger.Errorf("Failed to create secrets refresh: %v", err)
+			os.Exit(1)
+		}
+	}
 
 	newStream = &stoppableStream
 	return
 }
 
 func cmdService(
-	confPath string,
+	confPaths []string,
+	profile string,
 	resourcesPaths []string,
 	confOverrides []string,
 	overrideLogLevel string,
 	strict, watching, enableStreamsAPI bool,
+	secretsRefresh time.Duration,
 	streamsMode bool,
 	streamsPaths []string,
 ) int {
-	confReader := readConfig(confPath, streamsMode, resourcesPaths, streamsPaths, confOverrides)
+	confReader := readConfig(confPaths, profile, streamsMode, true, resourcesPaths, streamsPaths, confOverrides)
 
 	lints, err := confReader.Read(&conf)
 	if err != nil {
@@ -367,6 +468,9 @@ func cmdService(
 			RemoveTypeField: true,
 		})
 	}
+	if err == nil {
+		secrets.RedactYAML(&sanitNode)
+	}
 	if err != nil {
 		logger.Warnf("Failed to generate sanitised config: %v\n", err)
 	}
@@ -399,9 +503,9 @@ func cmdService(
 
 	// Create data streams.
 	if streamsMode {
-		stoppableStream = initStreamsMode(strict, watching, enableStreamsAPI, confReader, strmAPITimeout, manager, logger, stats)
+		stoppableStream = initStreamsMode(strict, watching, enableStreamsAPI, secretsRefresh, confReader, strmAPITimeout, manager, logger, stats)
 	} else {
-		stoppableStream, dataStreamClosedChan = initNormalMode(strict, watching, confReader, manager, logger, stats)
+		stoppableStream, dataStreamClosedChan = initNormalMode(strict, watching, secretsRefresh, confReader, manager, logger, stats)
 	}
 
 	// Start HTTP server.
@@ -467,18 +571,51 @@ func cmdService(
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+	pauseChan, drainChan := make(chan os.Signal, 1), make(chan os.Signal, 1)
+	notifyControlSignals(pauseChan, drainChan)
+
+	var pausableStream pausable
+	if streamsMode {
+		if strmMgr, ok := stoppableStream.(*strmmgr.Type); ok {
+			pausableStream = streamsPausable{strmMgr}
+		}
+	} else if p, ok := stoppableStream.(pausable); ok {
+		pausableStream = p
+	}
+
 	// Wait for termination signal
-	select {
-	case <-sigChan:
-		logger.Infoln("Received SIGTERM, the service is closing.")
-	case <-dataStreamClosedChan:
-		logger.Infoln("Pipeline has terminated. Shutting down the service.")
-	case <-httpServerClosedChan:
-		logger.Infoln("HTTP Server has terminated. Shutting down the service.")
-	case <-optContext.Done():
-		logger.Infoln("Run context was cancelled. Shutting down the service.")
-	}
-	return 0
+	for {
+		select {
+		case <-pauseChan:
+			if pausableStream == nil {
+				continue
+			}
+			if pausableStream.IsPaused() {
+				pausableStream.Resume()
+				logger.Infoln("Received SIGUSR1, resumed consuming messages.")
+			} else {
+				pausableStream.Pause()
+				logger.Infoln("Received SIGUSR1, paused consuming messages.")
+			}
+			continue
+		case <-drainChan:
+			logger.Infoln("Received SIGUSR2, draining in-flight messages before closing the service.")
+			if pausableStream != nil {
+				if err := pausableStream.Drain(exitTimeout); err != nil {
+					logger.Errorf("Failed to drain in-flight messages: %v\n", err)
+				}
+			}
+		case <-sigChan:
+			logger.Infoln("Received SIGTERM, the service is closing.")
+		case <-dataStreamClosedChan:
+			logger.Infoln("Pipeline has terminated. Shutting down the service.")
+		case <-httpServerClosedChan:
+			logger.Infoln("HTTP Server has terminated. Shutting down the service.")
+		case <-optContext.Done():
+			logger.Infoln("Run context was cancelled. Shutting down the service.")
+		}
+		return 0
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -0,0 +1,17 @@
+//go:build !windows && !wasm && !plan9
+// +build !windows,!wasm,!plan9
+
+package service
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyControlSignals relays SIGUSR1 signals, which toggle whether streams are
+// paused, and SIGUSR2 signals, which drain streams before shutting down.
+func notifyControlSignals(pauseChan, drainChan chan<- os.Signal) {
+	signal.Notify(pauseChan, syscall.SIGUSR1)
+	signal.Notify(drainChan, syscall.SIGUSR2)
+}
@@ -0,0 +1,12 @@
+//go:build windows || wasm || plan9
+// +build windows wasm plan9
+
+package service
+
+import (
+	"os"
+)
+
+// notifyControlSignals does nothing as user defined signals are not supported
+// on this platform.
+func notifyControlSignals(pauseChan, drainChan chan<- os.Signal) {}
@@ -18,6 +18,7 @@ type Config struct {
 	Buffer   buffer.Config   `json:"buffer" yaml:"buffer"`
 	Pipeline pipeline.Config `json:"pipeline" yaml:"pipeline"`
 	Output   output.Config   `json:"output" yaml:"output"`
+	Lineage  LineageConfig   `json:"lineage" yaml:"lineage"`
 }
 
 // NewConfig returns a new configuration with default values.
@@ -27,6 +28,7 @@ func NewConfig() Config {
 		Buffer:   buffer.NewConfig(),
 		Pipeline: pipeline.NewConfig(),
 		Output:   output.NewConfig(),
+		Lineage:  NewLineageConfig(),
 	}
 }
 
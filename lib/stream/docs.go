@@ -14,5 +14,6 @@ func Spec() docs.FieldSpecs {
 			docs.FieldCommon("processors", "A list of processors to apply to messages.").Array().HasType(docs.FieldTypeProcessor),
 		),
 		docs.FieldCommon("output", "An output to sink messages to.").HasType(docs.FieldTypeOutput),
+		lineageFieldSpec(),
 	}
 }
@@ -0,0 +1,199 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jeffail/benthos/v3/internal/lineage"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// inputGate sits between the input layer of a stream and the remaining layers
+// and allows the flow of new transactions to be paused and resumed without
+// closing the input. Pausing only blocks the reading of new transactions, and
+// the number of transactions yet to be acknowledged is counted in order to
+// determine when all in-flight data has been resolved.
+type inputGate struct {
+	inFlight int64
+
+	mut        sync.Mutex
+	paused     bool
+	pauseChan  chan struct{}
+	resumeChan chan struct{}
+
+	stopOnce  sync.Once
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	closeChan chan struct{}
+
+	transactions chan types.Transaction
+
+	lineageEnabled  bool
+	lineageLabel    string
+	lineageRecorder lineage.Recorder
+}
+
+func newInputGate() *inputGate {
+	return &inputGate{
+		pauseChan:    make(chan struct{}),
+		resumeChan:   make(chan struct{}),
+		stopChan:     make(chan struct{}),
+		closeChan:    make(chan struct{}),
+		transactions: make(chan types.Transaction),
+	}
+}
+
+// WithLineage causes the gate to begin tracking the lineage of each message
+// that passes through it. This must be set before calling Consume.
+func (g *inputGate) WithLineage(label string, recorder lineage.Recorder) *inputGate {
+	g.lineageEnabled = true
+	g.lineageLabel = label
+	g.lineageRecorder = recorder
+	return g
+}
+
+// Consume starts forwarding transactions from an input channel.
+func (g *inputGate) Consume(tChan <-chan types.Transaction) {
+	go g.loop(tChan)
+}
+
+// TransactionChan returns a channel of transactions that have passed through
+// the gate.
+func (g *inputGate) TransactionChan() <-chan types.Transaction {
+	return g.transactions
+}
+
+// Pause stops the gate from reading new transactions from the input. Returns
+// false if the gate was already paused.
+func (g *inputGate) Pause() bool {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	close(g.pauseChan)
+	g.resumeChan = make(chan struct{})
+	return true
+}
+
+// Resume allows the gate to continue reading transactions from the input.
+// Returns false if the gate was not paused.
+func (g *inputGate) Resume() bool {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resumeChan)
+	g.pauseChan = make(chan struct{})
+	return true
+}
+
+// IsPaused returns whether the gate is currently paused.
+func (g *inputGate) IsPaused() bool {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return g.paused
+}
+
+// InFlight returns the number of transactions that have passed through the
+// gate and are yet to be acknowledged.
+func (g *inputGate) InFlight() int64 {
+	return atomic.LoadInt64(&g.inFlight)
+}
+
+// StopIfPaused stops the gate from reading any further transactions only if
+// it is currently paused, as otherwise the gate stops naturally once the input
+// has closed. Acknowledgements of in-flight transactions are still forwarded.
+func (g *inputGate) StopIfPaused() {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if g.paused {
+		g.stopOnce.Do(func() {
+			close(g.stopChan)
+		})
+	}
+}
+
+// CloseAsync closes the gate regardless of whether the input has closed, any
+// transaction that is in the process of being forwarded or acknowledged is
+// abandoned.
+func (g *inputGate) CloseAsync() {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+	})
+	g.closeOnce.Do(func() {
+		close(g.closeChan)
+	})
+}
+
+//------------------------------------------------------------------------------
+
+// relayAck forwards the response of a transaction that passed through the gate
+// to the input it was read from, and exits once the response is forwarded or
+// the gate is closed.
+func (g *inputGate) relayAck(resChan <-chan types.Response, upstream chan<- types.Response) {
+	select {
+	case res := <-resChan:
+		select {
+		case upstream <- res:
+		case <-g.closeChan:
+			return
+		}
+	case <-g.closeChan:
+		return
+	}
+	atomic.AddInt64(&g.inFlight, -1)
+}
+
+//------------------------------------------------------------------------------
+
+func (g *inputGate) loop(tChan <-chan types.Transaction) {
+	defer close(g.transactions)
+
+	for {
+		g.mut.Lock()
+		paused, pauseChan, resumeChan := g.paused, g.pauseChan, g.resumeChan
+		g.mut.Unlock()
+
+		if paused {
+			select {
+			case <-resumeChan:
+			case <-g.stopChan:
+				return
+			}
+			continue
+		}
+
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-tChan:
+			if !open {
+				return
+			}
+		case <-pauseChan:
+			continue
+		case <-g.stopChan:
+			return
+		}
+
+		resChan := make(chan types.Response)
+		atomic.AddInt64(&g.inFlight, 1)
+		go g.relayAck(resChan, tran.ResponseChan)
+
+		payload := tran.Payload
+		if g.lineageEnabled {
+			payload = payload.Copy()
+			lineage.RootBatch(g.lineageLabel, payload, g.lineageRecorder)
+		}
+
+		select {
+		case g.transactions <- types.NewTransaction(payload, resChan):
+		case <-g.closeChan:
+			return
+		}
+	}
+}
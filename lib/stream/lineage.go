@@ -0,0 +1,192 @@
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// LineageConfig describes whether the lineage of messages is tracked throughout
+// a stream, and optionally an output to send lineage edges to.
+type LineageConfig struct {
+	Enabled bool           `json:"enabled" yaml:"enabled"`
+	Output  *output.Config `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// NewLineageConfig returns a LineageConfig with default values.
+func NewLineageConfig() LineageConfig {
+	return LineageConfig{
+		Enabled: false,
+		Output:  nil,
+	}
+}
+
+func lineageFieldSpec() docs.FieldSpec {
+	return docs.FieldAdvanced(
+		"lineage", "Track the lineage of messages throughout the stream, for more information read the [message lineage documentation](/docs/configuration/lineage).",
+	).WithChildren(
+		docs.FieldBool("enabled", "Whether to track the lineage of messages.").HasDefault(false),
+		docs.FieldCommon("output", "An optional output to write lineage edges to.").HasType(docs.FieldTypeOutput).Optional(),
+	).AtVersion("3.65.0")
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	lineageEdgeBufferSize = 1024
+	lineageMaxBatchSize   = 64
+)
+
+// lineageEmitter receives lineage edges from the components of a stream and
+// writes them as message batches to an output.
+type lineageEmitter struct {
+	log      log.Modular
+	mDropped metrics.StatCounter
+	mErr     metrics.StatCounter
+
+	out   output.Type
+	tChan chan types.Transaction
+
+	mut    sync.RWMutex
+	closed bool
+	edges  chan lineage.Edge
+
+	forceOnce sync.Once
+	forceChan chan struct{}
+	loopDone  chan struct{}
+}
+
+func newLineageEmitter(out output.Type, log log.Modular, stats metrics.Type) (*lineageEmitter, error) {
+	e := &lineageEmitter{
+		log:       log,
+		mDropped:  stats.GetCounter("lineage_dropped"),
+		mErr:      stats.GetCounter("lineage_error"),
+		out:       out,
+		tChan:     make(chan types.Transaction),
+		edges:     make(chan lineage.Edge, lineageEdgeBufferSize),
+		forceChan: make(chan struct{}),
+		loopDone:  make(chan struct{}),
+	}
+	if err := out.Consume(e.tChan); err != nil {
+		return nil, err
+	}
+	go e.loop()
+	return e, nil
+}
+
+// RecordEdges queues edges to be written to the output, edges are dropped if
+// the queue is full.
+func (e *lineageEmitter) RecordEdges(edges ...lineage.Edge) {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+	if e.closed {
+		return
+	}
+	for _, edge := range edges {
+		select {
+		case e.edges <- edge:
+		default:
+			e.mDropped.Incr(1)
+		}
+	}
+}
+
+func (e *lineageEmitter) send(edges []lineage.Edge) {
+	msg := message.New(nil)
+	for _, edge := range edges {
+		part := message.NewPart(nil)
+		edgeBytes, err := json.Marshal(edge)
+		if err != nil {
+			e.log.Errorf("Failed to serialise lineage edge: %v\n", err)
+			continue
+		}
+		part.Set(edgeBytes)
+		msg.Append(part)
+	}
+
+	resChan := make(chan types.Response)
+	select {
+	case e.tChan <- types.NewTransaction(msg, resChan):
+	case <-e.forceChan:
+		return
+	}
+	select {
+	case res := <-resChan:
+		if err := res.Error(); err != nil {
+			e.mErr.Incr(int64(len(edges)))
+			e.log.Errorf("Failed to write lineage edges: %v\n", err)
+		}
+	case <-e.forceChan:
+	}
+}
+
+func (e *lineageEmitter) loop() {
+	defer func() {
+		close(e.tChan)
+		close(e.loopDone)
+	}()
+
+	for {
+		edge, open := <-e.edges
+		if !open {
+			return
+		}
+		edges := []lineage.Edge{edge}
+
+	batchLoop:
+		for len(edges) < lineageMaxBatchSize {
+			select {
+			case edge, open := <-e.edges:
+				if !open {
+					e.send(edges)
+					return
+				}
+				edges = append(edges, edge)
+			default:
+				break batchLoop
+			}
+		}
+		e.send(edges)
+	}
+}
+
+// CloseAsync stops accepting edges and begins flushing those that remain to
+// the output before closing it.
+func (e *lineageEmitter) CloseAsync() {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if !e.closed {
+		e.closed = true
+		close(e.edges)
+	}
+}
+
+// WaitForClose blocks until the remaining edges are flushed and the output is
+// closed.
+func (e *lineageEmitter) WaitForClose(timeout time.Duration) error {
+	started := time.Now()
+	select {
+	case <-e.loopDone:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return e.out.WaitForClose(timeout - time.Since(started))
+}
+
+// forceClose abandons any edges that are yet to be written and closes the
+// output.
+func (e *lineageEmitter) forceClose() {
+	e.CloseAsync()
+	e.forceOnce.Do(func() {
+		close(e.forceChan)
+	})
+	e.out.CloseAsync()
+}
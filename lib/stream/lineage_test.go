@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTypeLineage(t *testing.T) {
+	tmpDir := t.TempDir()
+	outPath := filepath.Join(tmpDir, "out.jsonl")
+	edgesPath := filepath.Join(tmpDir, "edges.jsonl")
+
+	conf := NewConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(fmt.Sprintf(`
+input:
+  label: foo_input
+  generate:
+    count: 1
+    interval: ""
+    mapping: 'root = [ "a", "b", "c" ]'
+pipeline:
+  processors:
+    - label: foo_unarchive
+      unarchive:
+        format: json_array
+    - bloblang: |
+        root.value = this
+        root.id = lineage_id()
+        root.origins = lineage_origins()
+output:
+  file:
+    path: %v
+    codec: lines
+lineage:
+  enabled: true
+  output:
+    file:
+      path: %v
+      codec: lines
+`, outPath, edgesPath)), &conf))
+
+	mgr, err := manager.NewV2(manager.NewResourceConfig(), types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	closedChan := make(chan struct{})
+	strm, err := New(conf, OptSetManager(mgr), OptOnClose(func() {
+		close(closedChan)
+	}))
+	require.NoError(t, err)
+
+	select {
+	case <-closedChan:
+	case <-time.After(time.Second * 10):
+		t.Fatal("timed out")
+	}
+	require.NoError(t, strm.Stop(time.Second*10))
+
+	type result struct {
+		Value   string   `json:"value"`
+		ID      string   `json:"id"`
+		Origins []string `json:"origins"`
+	}
+	outBytes, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+
+	var results []result
+	for _, line := range strings.Split(strings.TrimSpace(string(outBytes)), "\n") {
+		var r result
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		results = append(results, r)
+	}
+	require.Len(t, results, 3)
+
+	edgesBytes, err := os.ReadFile(edgesPath)
+	require.NoError(t, err)
+
+	type edge struct {
+		Parent string `json:"parent"`
+		Child  string `json:"child"`
+		Label  string `json:"label"`
+	}
+	var edges []edge
+	for _, line := range strings.Split(strings.TrimSpace(string(edgesBytes)), "\n") {
+		var e edge
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		edges = append(edges, e)
+	}
+	require.Len(t, edges, 4)
+
+	root := edges[0]
+	assert.Equal(t, "", root.Parent)
+	assert.Equal(t, "foo_input", root.Label)
+
+	for i, r := range results {
+		assert.Equal(t, []string{root.Child}, r.Origins, i)
+		assert.NotEqual(t, root.Child, r.ID, i)
+		assert.Contains(t, edges[1:], edge{
+			Parent: root.Child,
+			Child:  r.ID,
+			Label:  "foo_unarchive",
+		}, i)
+	}
+}
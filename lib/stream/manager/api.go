@@ -58,11 +58,31 @@ func (m *Type) registerEndpoints(enableCrud bool) {
 		"GET a structured JSON object containing metrics for the stream.",
 		m.HandleStreamStats,
 	)
+	m.manager.RegisterEndpoint(
+		"/streams/{id}/pause",
+		"POST: Pause a stream, no new messages are consumed from its inputs until it is resumed but in-flight messages are still acknowledged.",
+		m.HandleStreamPause,
+	)
+	m.manager.RegisterEndpoint(
+		"/streams/{id}/resume",
+		"POST: Resume a paused stream.",
+		m.HandleStreamResume,
+	)
+	m.manager.RegisterEndpoint(
+		"/streams/{id}/drain",
+		"POST: Pause a stream and wait for all in-flight messages to be acknowledged before stopping and removing it.",
+		m.HandleStreamDrain,
+	)
 	m.manager.RegisterEndpoint(
 		"/resources/{type}/{id}",
 		"POST: Create or replace a given resource configuration of a specified type. Types supported are `cache`, `input`, `output`, `processor` and `rate_limit`.",
 		m.HandleResourceCRUD,
 	)
+	m.manager.RegisterEndpoint(
+		"/resources/{type}/{id}/swap",
+		"POST: Hot swap an existing resource with a new configuration without restarting the streams that use it. Types supported are `cache`, `processor` and `rate_limit`.",
+		m.HandleResourceSwap,
+	)
 }
 
 // ConfigSet is a map of stream configurations mapped by ID, which can be YAML
@@ -116,6 +136,7 @@ func (m *Type) HandleStreamsCRUD(w http.ResponseWriter, r *http.Request) {
 
 	type confInfo struct {
 		Active    bool    `json:"active"`
+		Paused    bool    `json:"paused"`
 		Uptime    float64 `json:"uptime"`
 		UptimeStr string  `json:"uptime_str"`
 	}
@@ -125,6 +146,7 @@ func (m *Type) HandleStreamsCRUD(w http.ResponseWriter, r *http.Request) {
 	for id, strInfo := range m.streams {
 		infos[id] = confInfo{
 			Active:    strInfo.IsRunning(),
+			Paused:    strInfo.IsPaused(),
 			Uptime:    strInfo.Uptime().Seconds(),
 			UptimeStr: strInfo.Uptime().String(),
 		}
@@ -374,11 +396,13 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 			var bodyBytes []byte
 			if bodyBytes, serverErr = json.Marshal(struct {
 				Active    bool        `json:"active"`
+				Paused    bool        `json:"paused"`
 				Uptime    float64     `json:"uptime"`
 				UptimeStr string      `json:"uptime_str"`
 				Config    interface{} `json:"config"`
 			}{
 				Active:    info.IsRunning(),
+				Paused:    info.IsPaused(),
 				Uptime:    info.Uptime().Seconds(),
 				UptimeStr: info.Uptime().String(),
 				Config:    sanit,
@@ -433,6 +457,18 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 // HandleResourceCRUD is an http.HandleFunc for performing CRUD operations on
 // resource components.
 func (m *Type) HandleResourceCRUD(w http.ResponseWriter, r *http.Request) {
+	m.handleResource(w, r, false)
+}
+
+// HandleResourceSwap is an http.HandleFunc for hot swapping cache, processor
+// and rate limit resources. The new resource is initialized before the
+// existing resource is replaced and closed, allowing components that use the
+// resource to continue without being restarted.
+func (m *Type) HandleResourceSwap(w http.ResponseWriter, r *http.Request) {
+	m.handleResource(w, r, true)
+}
+
+func (m *Type) handleResource(w http.ResponseWriter, r *http.Request, swap bool) {
 	var serverErr, requestErr error
 	defer func() {
 		if r.Body != nil {
@@ -480,9 +516,17 @@ func (m *Type) HandleResourceCRUD(w http.ResponseWriter, r *http.Request) {
 			if requestErr = n.Decode(&cacheConf); requestErr != nil {
 				return
 			}
+			if swap {
+				serverErr = newMgr.SwapCache(ctx, id, cacheConf)
+				return
+			}
 			serverErr = newMgr.StoreCache(ctx, id, cacheConf)
 		}
 	case docs.TypeInput:
+		if swap {
+			http.Error(w, "Resources of type `input` cannot be swapped", http.StatusBadRequest)
+			return
+		}
 		storeFn = func(n *yaml.Node) {
 			inputConf := input.NewConfig()
 			if requestErr = n.Decode(&inputConf); requestErr != nil {
@@ -491,6 +535,10 @@ func (m *Type) HandleResourceCRUD(w http.ResponseWriter, r *http.Request) {
 			serverErr = newMgr.StoreInput(ctx, id, inputConf)
 		}
 	case docs.TypeOutput:
+		if swap {
+			http.Error(w, "Resources of type `output` cannot be swapped", http.StatusBadRequest)
+			return
+		}
 		storeFn = func(n *yaml.Node) {
 			outputConf := output.NewConfig()
 			if requestErr = n.Decode(&outputConf); requestErr != nil {
@@ -504,6 +552,10 @@ func (m *Type) HandleResourceCRUD(w http.ResponseWriter, r *http.Request) {
 			if requestErr = n.Decode(&procConf); requestErr != nil {
 				return
 			}
+			if swap {
+				serverErr = newMgr.SwapProcessor(ctx, id, procConf)
+				return
+			}
 			serverErr = newMgr.StoreProcessor(ctx, id, procConf)
 		}
 	case docs.TypeRateLimit:
@@ -512,9 +564,17 @@ func (m *Type) HandleResourceCRUD(w http.ResponseWriter, r *http.Request) {
 			if requestErr = n.Decode(&rlConf); requestErr != nil {
 				return
 			}
+			if swap {
+				serverErr = newMgr.SwapRateLimit(ctx, id, rlConf)
+				return
+			}
 			serverErr = newMgr.StoreRateLimit(ctx, id, rlConf)
 		}
 	default:
+		if swap {
+			http.Error(w, "Var `type` must be set to one of `cache`, `processor` or `rate_limit`", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Var `type` must be set to one of `cache`, `input`, `output`, `processor` or `rate_limit`", http.StatusBadRequest)
 		return
 	}
@@ -612,26 +672,93 @@ func (m *Type) HandleStreamStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleStreamPause is an http.HandleFunc for pausing a stream.
+func (m *Type) HandleStreamPause(w http.ResponseWriter, r *http.Request) {
+	m.handleStreamControl(w, r, func(id string) error {
+		return m.Pause(id)
+	})
+}
+
+// HandleStreamResume is an http.HandleFunc for resuming a paused stream.
+func (m *Type) HandleStreamResume(w http.ResponseWriter, r *http.Request) {
+	m.handleStreamControl(w, r, func(id string) error {
+		return m.Resume(id)
+	})
+}
+
+// HandleStreamDrain is an http.HandleFunc for draining a stream of in-flight
+// messages before stopping and removing it.
+func (m *Type) HandleStreamDrain(w http.ResponseWriter, r *http.Request) {
+	m.handleStreamControl(w, r, func(id string) error {
+		timeout := m.apiTimeout
+		if timeoutStr := r.URL.Query().Get("timeout"); len(timeoutStr) > 0 {
+			dur, err := time.ParseDuration(timeoutStr)
+			if err != nil {
+				return err
+			}
+			timeout = dur
+		}
+		return m.Drain(id, timeout)
+	})
+}
+
+func (m *Type) handleStreamControl(w http.ResponseWriter, r *http.Request, fn func(id string) error) {
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if r.Method != "POST" {
+		m.logger.Debugf("Stream request control Error: verb not supported: %v\n", r.Method)
+		http.Error(w, fmt.Sprintf("Error: verb not supported: %v", r.Method), http.StatusBadRequest)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		http.Error(w, "Var `id` must be set", http.StatusBadRequest)
+		return
+	}
+
+	if err := fn(id); err != nil {
+		if err == ErrStreamDoesNotExist {
+			http.Error(w, "Stream not found", http.StatusNotFound)
+			return
+		}
+		m.logger.Errorf("Stream control Error: %v\n", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadGateway)
+	}
+}
+
 // HandleStreamReady is an http.HandleFunc for providing a ready check across
 // all streams.
 func (m *Type) HandleStreamReady(w http.ResponseWriter, r *http.Request) {
-	var notReady []string
+	var notReady, paused []string
 
 	m.lock.Lock()
 	for k, v := range m.streams {
-		if !v.IsReady() {
+		if v.IsPaused() {
+			paused = append(paused, k)
+		} else if !v.IsReady() {
 			notReady = append(notReady, k)
 		}
 	}
 	m.lock.Unlock()
 
-	if len(notReady) == 0 {
+	if len(notReady) == 0 && len(paused) == 0 {
 		w.Write([]byte("OK"))
 		return
 	}
 
+	sort.Strings(notReady)
+	sort.Strings(paused)
+
 	w.WriteHeader(http.StatusServiceUnavailable)
-	fmt.Fprintf(w, "streams %v are not connected\n", strings.Join(notReady, ", "))
+	if len(notReady) > 0 {
+		fmt.Fprintf(w, "streams %v are not connected\n", strings.Join(notReady, ", "))
+	}
+	if len(paused) > 0 {
+		fmt.Fprintf(w, "streams %v are paused\n", strings.Join(paused, ", "))
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -37,7 +37,12 @@ func router(m *manager.Type) *mux.Router {
 	router.HandleFunc("/streams", m.HandleStreamsCRUD)
 	router.HandleFunc("/streams/{id}", m.HandleStreamCRUD)
 	router.HandleFunc("/streams/{id}/stats", m.HandleStreamStats)
+	router.HandleFunc("/streams/{id}/pause", m.HandleStreamPause)
+	router.HandleFunc("/streams/{id}/resume", m.HandleStreamResume)
+	router.HandleFunc("/streams/{id}/drain", m.HandleStreamDrain)
+	router.HandleFunc("/ready", m.HandleStreamReady)
 	router.HandleFunc("/resources/{type}/{id}", m.HandleResourceCRUD)
+	router.HandleFunc("/resources/{type}/{id}/swap", m.HandleResourceSwap)
 	return router
 }
 
@@ -81,6 +86,7 @@ func genYAMLRequest(verb, url string, payload interface{}) *http.Request {
 
 type listItemBody struct {
 	Active    bool    `json:"active"`
+	Paused    bool    `json:"paused"`
 	Uptime    float64 `json:"uptime"`
 	UptimeStr string  `json:"uptime_str"`
 }
@@ -97,6 +103,7 @@ func parseListBody(data *bytes.Buffer) listBody {
 
 type getBody struct {
 	Active    bool          `json:"active"`
+	Paused    bool          `json:"paused"`
 	Uptime    float64       `json:"uptime"`
 	UptimeStr string        `json:"uptime_str"`
 	Config    stream.Config `json:"config"`
@@ -293,6 +300,67 @@ func TestTypeAPIBasicOperations(t *testing.T) {
 	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
 }
 
+func TestTypeAPIPauseResumeDrain(t *testing.T) {
+	mgr := manager.New(
+		manager.OptSetLogger(log.Noop()),
+		manager.OptSetStats(metrics.Noop()),
+		manager.OptSetManager(types.NoopMgr()),
+		manager.OptSetAPITimeout(time.Second*10),
+	)
+
+	r := router(mgr)
+
+	request := genRequest("POST", "/streams/foo/pause", nil)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+
+	require.NoError(t, mgr.Create("foo", harmlessConf()))
+
+	request = genRequest("GET", "/streams/foo/pause", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+
+	request = genRequest("POST", "/streams/foo/pause", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	request = genRequest("GET", "/streams/foo", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.True(t, parseGetBody(t, response.Body).Paused)
+
+	request = genRequest("GET", "/ready", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Equal(t, "streams foo are paused\n", response.Body.String())
+
+	request = genRequest("POST", "/streams/foo/resume", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	request = genRequest("GET", "/streams", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.False(t, parseListBody(response.Body)["foo"].Paused)
+
+	request = genRequest("POST", "/streams/foo/drain?timeout=5s", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	request = genRequest("GET", "/streams/foo", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+}
+
 func TestTypeAPIPatch(t *testing.T) {
 	mgr := manager.New(
 		manager.OptSetLogger(log.Noop()),
@@ -924,3 +992,92 @@ func TestTypeAPISetResources(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, `{"id":"second","content":"hello world 2"}`, string(file2Bytes))
 }
+
+func TestTypeAPISwapResources(t *testing.T) {
+	bmgr, err := bmanager.NewV2(bmanager.NewResourceConfig(), types.DudMgr{}, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan := make(chan types.Transaction)
+	bmgr.SetPipe("feed_in", tChan)
+
+	mgr := manager.New(
+		manager.OptSetLogger(log.Noop()),
+		manager.OptSetStats(metrics.Noop()),
+		manager.OptSetManager(bmgr),
+		manager.OptSetAPITimeout(time.Millisecond*100),
+	)
+
+	tmpDir := t.TempDir()
+
+	dir1 := filepath.Join(tmpDir, "dir1")
+	require.NoError(t, os.MkdirAll(dir1, 0o750))
+
+	dir2 := filepath.Join(tmpDir, "dir2")
+	require.NoError(t, os.MkdirAll(dir2, 0o750))
+
+	r := router(mgr)
+
+	cacheConf := cache.NewConfig()
+	cacheConf.Type = cache.TypeFile
+	cacheConf.File.Directory = dir1
+
+	request := genYAMLRequest("POST", "/resources/cache/foocache/swap?chilled=true", cacheConf)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	streamConf := stream.NewConfig()
+	streamConf.Input.Type = input.TypeInproc
+	streamConf.Input.Inproc = "feed_in"
+	streamConf.Output.Type = output.TypeCache
+	streamConf.Output.Cache.Key = `${! json("id") }`
+	streamConf.Output.Cache.Target = "foocache"
+
+	request = genYAMLRequest("POST", "/streams/foo?chilled=true", streamConf)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	sendMsg := func(content string) {
+		t.Helper()
+		resChan := make(chan types.Response)
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte(content)}), resChan):
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+		select {
+		case res := <-resChan:
+			require.NoError(t, res.Error())
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	}
+
+	sendMsg(`{"id":"first"}`)
+
+	cacheConf.File.Directory = dir2
+
+	request = genYAMLRequest("POST", "/resources/cache/foocache/swap?chilled=true", cacheConf)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	sendMsg(`{"id":"second"}`)
+
+	file1Bytes, err := os.ReadFile(filepath.Join(dir1, "first"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"first"}`, string(file1Bytes))
+
+	file2Bytes, err := os.ReadFile(filepath.Join(dir2, "second"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"second"}`, string(file2Bytes))
+
+	outputConf := output.NewConfig()
+	outputConf.Type = output.TypeDrop
+
+	request = genYAMLRequest("POST", "/resources/output/fooout/swap?chilled=true", outputConf)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+}
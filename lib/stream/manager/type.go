@@ -56,6 +56,11 @@ func (s *StreamStatus) IsReady() bool {
 	return s.strm.IsReady()
 }
 
+// IsPaused returns a boolean indicating whether the stream is currently paused.
+func (s *StreamStatus) IsPaused() bool {
+	return s.strm.IsPaused()
+}
+
 // Uptime returns a time.Duration indicating the current uptime of the stream.
 func (s *StreamStatus) Uptime() time.Duration {
 	if stoppedAfter := atomic.LoadInt64(&s.stoppedAfter); stoppedAfter > 0 {
@@ -311,11 +316,114 @@ func (m *Type) Delete(id string, timeout time.Duration) error {
 	return nil
 }
 
+// Pause stops a stream from consuming new messages from its inputs without
+// closing them, in-flight messages continue to be processed and acknowledged.
+// Pausing a stream that is already paused has no effect.
+func (m *Type) Pause(id string) error {
+	wrapper, err := m.Read(id)
+	if err != nil {
+		return err
+	}
+	wrapper.strm.Pause()
+	return nil
+}
+
+// Resume allows a paused stream to continue consuming messages. Resuming a
+// stream that is not paused has no effect.
+func (m *Type) Resume(id string) error {
+	wrapper, err := m.Read(id)
+	if err != nil {
+		return err
+	}
+	wrapper.strm.Resume()
+	return nil
+}
+
+// Drain pauses a stream and waits for all of its in-flight messages to be
+// acknowledged before stopping and removing it. Returns an error if the stream
+// was not found, or if clean shutdown fails in the specified period of time.
+func (m *Type) Drain(id string, timeout time.Duration) error {
+	wrapper, err := m.Read(id)
+	if err != nil {
+		return err
+	}
+
+	if err := wrapper.strm.Drain(timeout); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	if m.streams[id] == wrapper {
+		delete(m.streams, id)
+	}
+	m.lock.Unlock()
+
+	return nil
+}
+
+// PauseAll pauses all active streams. Returns false if all streams were
+// already paused.
+func (m *Type) PauseAll() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	changed := false
+	for _, v := range m.streams {
+		if v.strm.Pause() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ResumeAll resumes all paused streams. Returns false if no streams were
+// paused.
+func (m *Type) ResumeAll() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	changed := false
+	for _, v := range m.streams {
+		if v.strm.Resume() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// IsPaused returns true if there is at least one active stream and all active
+// streams are paused.
+func (m *Type) IsPaused() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, v := range m.streams {
+		if !v.IsPaused() {
+			return false
+		}
+	}
+	return len(m.streams) > 0
+}
+
+// DrainAll attempts to drain all active streams of their in-flight messages
+// before shutting them down and closing the stream manager.
+func (m *Type) DrainAll(timeout time.Duration) error {
+	return m.stopAll(func(strm *stream.Type) error {
+		return strm.Drain(timeout)
+	})
+}
+
 //------------------------------------------------------------------------------
 
 // Stop attempts to gracefully shut down all active streams and close the
 // stream manager.
 func (m *Type) Stop(timeout time.Duration) error {
+	return m.stopAll(func(strm *stream.Type) error {
+		return strm.Stop(timeout)
+	})
+}
+
+func (m *Type) stopAll(stopFn func(strm *stream.Type) error) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
@@ -323,7 +431,7 @@ func (m *Type) Stop(timeout time.Duration) error {
 
 	for k, v := range m.streams {
 		go func(id string, strm *StreamStatus) {
-			if err := strm.strm.Stop(timeout); err != nil {
+			if err := stopFn(strm.strm); err != nil {
 				resultChan <- id
 			} else {
 				resultChan <- ""
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"net/http"
 	"runtime/pprof"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/internal/lineage"
 	"github.com/Jeffail/benthos/v3/lib/buffer"
 	"github.com/Jeffail/benthos/v3/lib/input"
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -27,10 +29,14 @@ type Type struct {
 	conf Config
 
 	inputLayer    input.Type
+	inputGate     *inputGate
 	bufferLayer   buffer.Type
 	pipelineLayer pipeline.Type
 	outputLayer   output.Type
 
+	lineageEmitter  *lineageEmitter
+	shutdownTimeout time.Duration
+
 	complementaryProcs []types.ProcessorConstructorFunc
 
 	manager types.Manager
@@ -38,16 +44,20 @@ type Type struct {
 	logger  log.Modular
 
 	onClose func()
+
+	stopOnce sync.Once
+	stopErr  error
 }
 
 // New creates a new stream.Type.
 func New(conf Config, opts ...func(*Type)) (*Type, error) {
 	t := &Type{
-		conf:    conf,
-		stats:   metrics.Noop(),
-		logger:  log.Noop(),
-		manager: types.NoopMgr(),
-		onClose: func() {},
+		conf:            conf,
+		stats:           metrics.Noop(),
+		logger:          log.Noop(),
+		manager:         types.NoopMgr(),
+		onClose:         func() {},
+		shutdownTimeout: time.Second * 20,
 	}
 	for _, opt := range opts {
 		opt(t)
@@ -57,24 +67,25 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 	}
 
 	healthCheck := func(w http.ResponseWriter, r *http.Request) {
-		connected := true
-		if !t.inputLayer.Connected() {
-			connected = false
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("input not connected\n"))
+		var notReady string
+		switch {
+		case t.inputGate.IsPaused():
+			notReady = "stream paused\n"
+		case !t.inputLayer.Connected():
+			notReady = "input not connected\n"
+		case !t.outputLayer.Connected():
+			notReady = "output not connected\n"
 		}
-		if !t.outputLayer.Connected() {
-			connected = false
+		if notReady != "" {
 			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("output not connected\n"))
-		}
-		if connected {
-			w.Write([]byte("OK"))
+			w.Write([]byte(notReady))
+			return
 		}
+		w.Write([]byte("OK"))
 	}
 	t.manager.RegisterEndpoint(
 		"/ready",
-		"Returns 200 OK if all inputs and outputs are connected, otherwise a 503 is returned.",
+		"Returns 200 OK if all inputs and outputs are connected and the stream is not paused, otherwise a 503 is returned.",
 		healthCheck,
 	)
 	return t, nil
@@ -123,6 +134,14 @@ func OptSetManager(mgr types.Manager) func(*Type) {
 	}
 }
 
+// OptSetShutdownTimeout sets the maximum period of time to wait for the
+// lineage output to flush once the output layer has closed by itself.
+func OptSetShutdownTimeout(timeout time.Duration) func(*Type) {
+	return func(t *Type) {
+		t.shutdownTimeout = timeout
+	}
+}
+
 // OptOnClose sets a closure to be called when the stream closes.
 func OptOnClose(onClose func()) func(*Type) {
 	return func(t *Type) {
@@ -138,6 +157,59 @@ func (t *Type) IsReady() bool {
 	return t.inputLayer.Connected() && t.outputLayer.Connected()
 }
 
+// Pause stops the stream from consuming new messages from its inputs, whilst
+// keeping the inputs connected and allowing in-flight messages to continue
+// through the stream and be acknowledged. Returns false if the stream was
+// already paused.
+func (t *Type) Pause() bool {
+	if !t.inputGate.Pause() {
+		return false
+	}
+	t.logger.Infoln("Stream paused, no new messages will be consumed until it is resumed.")
+	return true
+}
+
+// Resume allows a paused stream to continue consuming messages from its
+// inputs. Returns false if the stream was not paused.
+func (t *Type) Resume() bool {
+	if !t.inputGate.Resume() {
+		return false
+	}
+	t.logger.Infoln("Stream resumed.")
+	return true
+}
+
+// IsPaused returns a boolean indicating whether the stream is paused.
+func (t *Type) IsPaused() bool {
+	return t.inputGate.IsPaused()
+}
+
+// InFlight returns the number of messages that have been consumed from the
+// inputs of the stream and are yet to be acknowledged.
+func (t *Type) InFlight() int64 {
+	return t.inputGate.InFlight()
+}
+
+// Drain pauses the stream and waits for all in-flight messages to be
+// acknowledged before stopping the stream within the specified timeout period.
+// If the in-flight messages are not resolved in time then the stream is
+// stopped regardless, the same as Stop.
+func (t *Type) Drain(timeout time.Duration) error {
+	started := time.Now()
+	t.inputGate.Pause()
+	t.logger.Infoln("Draining stream, waiting for in-flight messages to be acknowledged.")
+
+	// Allow at least a quarter of the timeout for the stream to close.
+	drainDeadline := started.Add(timeout - timeout/4)
+	for t.inputGate.InFlight() > 0 && time.Now().Before(drainDeadline) {
+		<-time.After(time.Millisecond * 10)
+	}
+	if n := t.inputGate.InFlight(); n > 0 {
+		t.logger.Warnf("Unable to drain %v in-flight messages within target time.\n", n)
+	}
+	return t.Stop(timeout - time.Since(started))
+}
+
 func (t *Type) start() (err error) {
 	// Constructors
 	iMgr, iLog, iStats := interop.LabelChild("input", t.manager, t.logger, t.stats)
@@ -164,7 +236,29 @@ func (t *Type) start() (err error) {
 	// Start chaining components
 	var nextTranChan <-chan types.Transaction
 
-	nextTranChan = t.inputLayer.TransactionChan()
+	t.inputGate = newInputGate()
+	if t.conf.Lineage.Enabled {
+		var recorder lineage.Recorder
+		if t.conf.Lineage.Output != nil {
+			lMgr, lLog, lStats := interop.LabelChild("lineage", t.manager, t.logger, t.stats)
+			var lOutput output.Type
+			if lOutput, err = output.New(*t.conf.Lineage.Output, lMgr, lLog, lStats); err != nil {
+				return
+			}
+			if t.lineageEmitter, err = newLineageEmitter(lOutput, lLog, t.stats); err != nil {
+				return
+			}
+			recorder = t.lineageEmitter
+		}
+		inputLabel := t.conf.Input.Label
+		if inputLabel == "" {
+			inputLabel = "input"
+		}
+		t.inputGate.WithLineage(inputLabel, recorder)
+	}
+	t.inputGate.Consume(t.inputLayer.TransactionChan())
+
+	nextTranChan = t.inputGate.TransactionChan()
 	if t.bufferLayer != nil {
 		if err = t.bufferLayer.Consume(nextTranChan); err != nil {
 			return
@@ -184,6 +278,12 @@ func (t *Type) start() (err error) {
 	go func(out output.Type) {
 		for {
 			if err := out.WaitForClose(time.Second); err == nil {
+				if t.lineageEmitter != nil {
+					if err := t.closeLineage(t.shutdownTimeout); err != nil {
+						t.logger.Errorf("Lineage output failed to close within %v, forcing close\n", t.shutdownTimeout)
+						t.lineageEmitter.forceClose()
+					}
+				}
 				t.onClose()
 				return
 			}
@@ -199,6 +299,7 @@ func (t *Type) start() (err error) {
 // before shutting down.
 func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 	t.inputLayer.CloseAsync()
+	t.inputGate.StopIfPaused()
 	started := time.Now()
 	if err = t.inputLayer.WaitForClose(timeout); err != nil {
 		return
@@ -240,7 +341,7 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 		return
 	}
 
-	return nil
+	return t.closeLineage(timeout - time.Since(started))
 }
 
 // stopOrdered attempts to close all components of the stream in the order of
@@ -249,6 +350,7 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 // stopGracefully, which should be attempted first.
 func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 	t.inputLayer.CloseAsync()
+	t.inputGate.StopIfPaused()
 	started := time.Now()
 	if err = t.inputLayer.WaitForClose(timeout); err != nil {
 		return
@@ -287,7 +389,7 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 		return
 	}
 
-	return nil
+	return t.closeLineage(timeout - time.Since(started))
 }
 
 // stopUnorderd attempts to close all components in parallel without allowing
@@ -295,6 +397,7 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 // should only be attempted if both stopGracefully and stopOrdered failed.
 func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 	t.inputLayer.CloseAsync()
+	t.inputGate.CloseAsync()
 	if t.bufferLayer != nil {
 		t.bufferLayer.CloseAsync()
 	}
@@ -302,6 +405,9 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 		t.pipelineLayer.CloseAsync()
 	}
 	t.outputLayer.CloseAsync()
+	if t.lineageEmitter != nil {
+		t.lineageEmitter.forceClose()
+	}
 
 	started := time.Now()
 	if err = t.inputLayer.WaitForClose(timeout); err != nil {
@@ -338,18 +444,41 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 		return
 	}
 
-	return nil
+	return t.closeLineage(timeout - time.Since(started))
+}
+
+// closeLineage flushes and closes the lineage output, if there is one, which
+// must happen after the output layer has closed.
+func (t *Type) closeLineage(timeout time.Duration) error {
+	if t.lineageEmitter == nil {
+		return nil
+	}
+	t.lineageEmitter.CloseAsync()
+	if timeout < 0 {
+		return types.ErrTimeout
+	}
+	return t.lineageEmitter.WaitForClose(timeout)
 }
 
 // Stop attempts to close the stream within the specified timeout period.
 // Initially the attempt is graceful, but as the timeout draws close the attempt
-// becomes progressively less graceful.
+// becomes progressively less graceful. Only the first call has any effect,
+// subsequent calls return the result of the first.
 func (t *Type) Stop(timeout time.Duration) error {
+	t.stopOnce.Do(func() {
+		t.stopErr = t.stop(timeout)
+	})
+	return t.stopErr
+}
+
+func (t *Type) stop(timeout time.Duration) error {
 	tOutUnordered := timeout / 4
 	tOutGraceful := timeout - tOutUnordered
 
 	err := t.stopGracefully(tOutGraceful)
 	if err == nil {
+		// Release any acknowledgements that were abandoned by the input.
+		t.inputGate.CloseAsync()
 		return nil
 	}
 	if err == types.ErrTimeout {
@@ -6,9 +6,12 @@ import (
 
 	"github.com/Jeffail/benthos/v3/lib/input"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -116,3 +119,111 @@ func TestTypeCloseUnordered(t *testing.T) {
 	require.NoError(t, err)
 	assert.NoError(t, strm.stopUnordered(time.Minute))
 }
+
+func TestTypePauseResumeDrain(t *testing.T) {
+	mgr, err := manager.NewV2(manager.NewResourceConfig(), types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	inChan := make(chan types.Transaction)
+	mgr.SetPipe("foo_in", inChan)
+
+	conf := NewConfig()
+	conf.Input.Type = input.TypeInproc
+	conf.Input.Inproc = "foo_in"
+	conf.Output.Type = output.TypeInproc
+	conf.Output.Inproc = "foo_out"
+
+	strm, err := New(conf, OptSetManager(mgr))
+	require.NoError(t, err)
+
+	var outChan <-chan types.Transaction
+	require.Eventually(t, func() bool {
+		outChan, err = mgr.GetPipe("foo_out")
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	sendMsg := func(content string) <-chan types.Response {
+		t.Helper()
+		resChan := make(chan types.Response)
+		select {
+		case inChan <- types.NewTransaction(message.New([][]byte{[]byte(content)}), resChan):
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+		return resChan
+	}
+
+	recvMsg := func(content string) types.Transaction {
+		t.Helper()
+		select {
+		case tran := <-outChan:
+			assert.Equal(t, content, string(tran.Payload.Get(0).Get()))
+			return tran
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+		return types.Transaction{}
+	}
+
+	ackMsg := func(tran types.Transaction, resChan <-chan types.Response) {
+		t.Helper()
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+		select {
+		case res := <-resChan:
+			assert.NoError(t, res.Error())
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	}
+
+	firstRes := sendMsg("first")
+	firstTran := recvMsg("first")
+	assert.Equal(t, int64(1), strm.InFlight())
+
+	assert.True(t, strm.Pause())
+	assert.False(t, strm.Pause())
+	assert.True(t, strm.IsPaused())
+
+	// Acknowledgements of in-flight messages still pass through while paused.
+	ackMsg(firstTran, firstRes)
+	assert.Eventually(t, func() bool {
+		return strm.InFlight() == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	secondRes := sendMsg("second")
+	select {
+	case <-outChan:
+		t.Fatal("received message whilst paused")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	assert.True(t, strm.Resume())
+	assert.False(t, strm.Resume())
+	assert.False(t, strm.IsPaused())
+
+	secondTran := recvMsg("second")
+
+	drainErr := make(chan error)
+	go func() {
+		drainErr <- strm.Drain(time.Second * 10)
+	}()
+
+	<-time.After(time.Millisecond * 50)
+	assert.True(t, strm.IsPaused())
+	ackMsg(secondTran, secondRes)
+
+	select {
+	case err := <-drainErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	assert.Equal(t, int64(0), strm.InFlight())
+
+	// Stopping a drained stream again has no further effect.
+	assert.NoError(t, strm.Stop(time.Second))
+}
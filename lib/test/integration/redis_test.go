@@ -2,14 +2,18 @@ package integration
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/integration"
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/go-redis/redis/v7"
 	"github.com/ory/dockertest/v3"
 	"github.com/stretchr/testify/assert"
@@ -99,6 +103,141 @@ input:
 		})
 	})
 
+	t.Run("streams claim pending", func(t *testing.T) {
+		t.Parallel()
+
+		client := redis.NewClient(&redis.Options{
+			Addr: fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+		})
+		defer client.Close()
+
+		require.NoError(t, client.XGroupCreateMkStream("stream-claim", "group-claim", "0").Err())
+		for _, body := range []string{"foo", "bar"} {
+			require.NoError(t, client.XAdd(&redis.XAddArgs{
+				Stream: "stream-claim",
+				Values: map[string]interface{}{"body": body},
+			}).Err())
+		}
+
+		// Read both entries with a consumer that never acknowledges them, and
+		// deliver the second entry again so that it exceeds the max delivery
+		// count.
+		res, err := client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    "group-claim",
+			Consumer: "crashed",
+			Streams:  []string{"stream-claim", ">"},
+		}).Result()
+		require.NoError(t, err)
+		require.Len(t, res[0].Messages, 2)
+		require.NoError(t, client.XClaim(&redis.XClaimArgs{
+			Stream:   "stream-claim",
+			Group:    "group-claim",
+			Consumer: "crashed",
+			Messages: []string{res[0].Messages[1].ID},
+		}).Err())
+
+		conf := reader.NewRedisStreamsConfig()
+		conf.URL = fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+		conf.Streams = []string{"stream-claim"}
+		conf.ConsumerGroup = "group-claim"
+		conf.ClientID = "recovering"
+		conf.PendingCheckPeriod = "100ms"
+		conf.ClaimMinIdle = "100ms"
+		conf.MaxDeliveryCount = 2
+		conf.DeadLetterStream = "stream-claim-dlq"
+
+		r, err := reader.NewRedisStreams(conf, log.Noop(), metrics.Noop())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			r.CloseAsync()
+			assert.NoError(t, r.WaitForClose(time.Second))
+		})
+
+		ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+		defer done()
+		require.NoError(t, r.ConnectWithContext(ctx))
+
+		var msgBody string
+		for msgBody == "" {
+			msg, ackFn, err := r.ReadWithContext(ctx)
+			if err == types.ErrTimeout {
+				continue
+			}
+			require.NoError(t, err)
+			msgBody = string(msg.Get(0).Get())
+			require.NoError(t, ackFn(ctx, response.NewAck()))
+		}
+		assert.Equal(t, "foo", msgBody)
+
+		assert.Eventually(t, func() bool {
+			pending, err := client.XPending("stream-claim", "group-claim").Result()
+			return err == nil && pending.Count == 0
+		}, time.Second*5, time.Millisecond*100)
+
+		dlq, err := client.XRange("stream-claim-dlq", "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, dlq, 1)
+		assert.Equal(t, "bar", dlq[0].Values["body"])
+		assert.Equal(t, "stream-claim", dlq[0].Values["dead_letter_source_stream"])
+		assert.Equal(t, res[0].Messages[1].ID, dlq[0].Values["dead_letter_source_id"])
+		assert.Equal(t, "2", dlq[0].Values["dead_letter_delivery_count"])
+	})
+
+	t.Run("streams nack dead letter", func(t *testing.T) {
+		t.Parallel()
+
+		client := redis.NewClient(&redis.Options{
+			Addr: fmt.Sprintf("localhost:%v", resource.GetPort("6379/tcp")),
+		})
+		defer client.Close()
+
+		require.NoError(t, client.XGroupCreateMkStream("stream-nack", "group-nack", "0").Err())
+		require.NoError(t, client.XAdd(&redis.XAddArgs{
+			Stream: "stream-nack",
+			Values: map[string]interface{}{"body": "foo"},
+		}).Err())
+
+		conf := reader.NewRedisStreamsConfig()
+		conf.URL = fmt.Sprintf("tcp://localhost:%v", resource.GetPort("6379/tcp"))
+		conf.Streams = []string{"stream-nack"}
+		conf.ConsumerGroup = "group-nack"
+		conf.ClientID = "nacking"
+		conf.ClaimMinIdle = "1m"
+		conf.MaxDeliveryCount = 2
+		conf.DeadLetterStream = "stream-nack-dlq"
+
+		r, err := reader.NewRedisStreams(conf, log.Noop(), metrics.Noop())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			r.CloseAsync()
+			assert.NoError(t, r.WaitForClose(time.Second))
+		})
+
+		ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+		defer done()
+		require.NoError(t, r.ConnectWithContext(ctx))
+
+		for i := 0; i < 2; i++ {
+			msg, ackFn, err := r.ReadWithContext(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "foo", string(msg.Get(0).Get()))
+			require.NoError(t, ackFn(ctx, response.NewError(errors.New("nope"))))
+		}
+
+		_, _, err = r.ReadWithContext(ctx)
+		assert.Equal(t, types.ErrTimeout, err)
+
+		pending, err := client.XPending("stream-nack", "group-nack").Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
+
+		dlq, err := client.XRange("stream-nack-dlq", "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, dlq, 1)
+		assert.Equal(t, "foo", dlq[0].Values["body"])
+		assert.Equal(t, "3", dlq[0].Values["dead_letter_delivery_count"])
+	})
+
 	t.Run("pubsub", func(t *testing.T) {
 		t.Parallel()
 		template := `
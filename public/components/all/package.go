@@ -12,6 +12,7 @@ import (
 	_ "github.com/Jeffail/benthos/v3/internal/impl/confluent"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/gcp"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/generic"
+	_ "github.com/Jeffail/benthos/v3/internal/impl/grpc"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/kafka"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/maxmind"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/mongodb"
@@ -20,6 +21,8 @@ import (
 	_ "github.com/Jeffail/benthos/v3/internal/impl/parquet"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/pulsar"
 	_ "github.com/Jeffail/benthos/v3/internal/impl/sql"
+	_ "github.com/Jeffail/benthos/v3/internal/impl/syslog"
+	_ "github.com/Jeffail/benthos/v3/internal/impl/wasm"
 	"github.com/Jeffail/benthos/v3/internal/template"
 
 	// Import all (supported) sql drivers
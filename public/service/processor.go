@@ -123,6 +123,38 @@ func (a *airGapBatchProcessor) ProcessBatch(ctx context.Context, msgs []types.Pa
 	return newBatches, nil
 }
 
+// batchProcessorFlusher is implemented by batch processors that emit batches
+// periodically regardless of the batches being processed. This is currently for
+// internal use only.
+type batchProcessorFlusher interface {
+	FlushInterval() time.Duration
+	Flush(ctx context.Context) []MessageBatch
+}
+
+func (a *airGapBatchProcessor) FlushInterval() time.Duration {
+	if f, ok := a.p.(batchProcessorFlusher); ok {
+		return f.FlushInterval()
+	}
+	return 0
+}
+
+func (a *airGapBatchProcessor) Flush(ctx context.Context) [][]types.Part {
+	f, ok := a.p.(batchProcessorFlusher)
+	if !ok {
+		return nil
+	}
+	outputBatches := f.Flush(ctx)
+	newBatches := make([][]types.Part, len(outputBatches))
+	for i, batch := range outputBatches {
+		newBatch := make([]types.Part, len(batch))
+		for j, msg := range batch {
+			newBatch[j] = msg.part
+		}
+		newBatches[i] = newBatch
+	}
+	return newBatches
+}
+
 func (a *airGapBatchProcessor) Close(ctx context.Context) error {
 	return a.p.Close(context.Background())
 }
@@ -82,21 +82,79 @@ func (s *Stream) Run(ctx context.Context) (err error) {
 // messages on the next start up, but never results in dropped messages as long
 // as the input source supports at-least-once delivery.
 func (s *Stream) StopWithin(timeout time.Duration) error {
+	strm, err := s.getStream()
+	if err != nil {
+		return err
+	}
+
+	stopAt := time.Now().Add(timeout)
+	return s.closeResources(stopAt, strm.Stop(timeout))
+}
+
+// DrainWithin pauses the stream and waits for all in-flight messages to be
+// acknowledged before closing the stream within the specified timeout period.
+// If the in-flight messages are not resolved in time then the stream is closed
+// regardless, the same as StopWithin.
+func (s *Stream) DrainWithin(timeout time.Duration) error {
+	strm, err := s.getStream()
+	if err != nil {
+		return err
+	}
+
+	stopAt := time.Now().Add(timeout)
+	return s.closeResources(stopAt, strm.Drain(timeout))
+}
+
+// Pause stops the stream from consuming new messages from its inputs without
+// closing them. Messages that are already in-flight continue to be processed
+// and acknowledged. Pausing a stream that is already paused has no effect.
+func (s *Stream) Pause() error {
+	strm, err := s.getStream()
+	if err != nil {
+		return err
+	}
+	strm.Pause()
+	return nil
+}
+
+// Resume allows a paused stream to continue consuming messages from its
+// inputs. Resuming a stream that is not paused has no effect.
+func (s *Stream) Resume() error {
+	strm, err := s.getStream()
+	if err != nil {
+		return err
+	}
+	strm.Resume()
+	return nil
+}
+
+// IsPaused returns whether the stream is currently paused.
+func (s *Stream) IsPaused() bool {
+	strm, err := s.getStream()
+	if err != nil {
+		return false
+	}
+	return strm.IsPaused()
+}
+
+func (s *Stream) getStream() (*stream.Type, error) {
 	s.strmMut.Lock()
 	strm := s.strm
 	s.strmMut.Unlock()
 	if strm == nil {
-		return errors.New("stream has not been run yet")
+		return nil, errors.New("stream has not been run yet")
 	}
+	return strm, nil
+}
 
-	stopAt := time.Now().Add(timeout)
-	if err := strm.Stop(timeout); err != nil {
+func (s *Stream) closeResources(stopAt time.Time, stopErr error) error {
+	if stopErr != nil {
 		// Still attempt to shut down other resources but do not block.
 		go func() {
 			s.mgr.CloseAsync()
 			s.stats.Close()
 		}()
-		return err
+		return stopErr
 	}
 
 	s.mgr.CloseAsync()
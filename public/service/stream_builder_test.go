@@ -250,6 +250,66 @@ file:
 	outMut.Unlock()
 }
 
+func TestStreamBuilderPauseResumeDrain(t *testing.T) {
+	b := service.NewStreamBuilder()
+	require.NoError(t, b.SetLoggerYAML("level: NONE"))
+
+	pushFn, err := b.AddProducerFunc()
+	require.NoError(t, err)
+
+	outChan := make(chan string, 10)
+	require.NoError(t, b.AddConsumerFunc(func(_ context.Context, m *service.Message) error {
+		b, err := m.AsBytes()
+		assert.NoError(t, err)
+		outChan <- string(b)
+		return nil
+	}))
+
+	strm, err := b.Build()
+	require.NoError(t, err)
+
+	require.Error(t, strm.Pause())
+	assert.False(t, strm.IsPaused())
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- strm.Run(context.Background())
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.Eventually(t, func() bool {
+		return strm.Pause() == nil
+	}, time.Second*5, time.Millisecond*10)
+	assert.True(t, strm.IsPaused())
+
+	pushErr := make(chan error, 1)
+	go func() {
+		pushErr <- pushFn(ctx, service.NewMessage([]byte("hello world")))
+	}()
+
+	select {
+	case <-outChan:
+		t.Fatal("received message whilst paused")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	require.NoError(t, strm.Resume())
+	assert.False(t, strm.IsPaused())
+
+	select {
+	case msg := <-outChan:
+		assert.Equal(t, "hello world", msg)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	require.NoError(t, <-pushErr)
+
+	require.NoError(t, strm.DrainWithin(time.Second*5))
+	require.NoError(t, <-runErr)
+}
+
 func TestStreamBuilderBatchConsumerFunc(t *testing.T) {
 	tmpDir := t.TempDir()
 